@@ -44,6 +44,16 @@ type ResultsStats struct {
 	CompilationTime  time.Duration
 	BytesProcessed   int64
 	RecordsProcessed int64
+	// TruncationReason names the budget the script exceeded if its results are incomplete.
+	TruncationReason string
+	// MissingAgents lists the agents whose data is missing from partial results.
+	MissingAgents []MissingAgent
+}
+
+// MissingAgent is an agent whose data is missing from the results, and the tables it affects.
+type MissingAgent struct {
+	AgentID string
+	Tables  []string
 }
 
 // ScriptResults tracks the results of a script, and provides mechanisms to cancel, etc.
@@ -315,6 +325,11 @@ func (s *ScriptResults) handleStats(ctx context.Context, qes *vizierpb.QueryExec
 	s.stats.RecordsProcessed += qes.RecordsProcessed
 	s.stats.CompilationTime = time.Duration(qes.Timing.CompilationTimeNs) * time.Nanosecond
 	s.stats.ExecutionTime = time.Duration(qes.Timing.ExecutionTimeNs) * time.Nanosecond
+	s.stats.TruncationReason = qes.TruncationReason
+	s.stats.MissingAgents = nil
+	for _, m := range qes.MissingAgents {
+		s.stats.MissingAgents = append(s.stats.MissingAgents, MissingAgent{AgentID: m.AgentID, Tables: m.Tables})
+	}
 	return nil
 }
 
@@ -19,6 +19,9 @@
 #include <memory>
 #include <string>
 
+#include <absl/container/flat_hash_map.h>
+#include <absl/synchronization/mutex.h>
+
 #include "src/carnot/carnot.h"
 #include "src/carnot/engine_state.h"
 #include "src/carnot/exec/exec_graph.h"
@@ -63,6 +66,8 @@ class CarnotImpl final : public Carnot {
 
   Status ExecutePlan(const planpb::Plan& plan, const sole::uuid& query_id, bool analyze) override;
 
+  Status CancelQuery(const sole::uuid& query_id) override;
+
   void RegisterAgentMetadataCallback(AgentMetadataCallbackFunc func) override {
     agent_md_callback_ = func;
   };
@@ -102,6 +107,11 @@ class CarnotImpl final : public Carnot {
 
   // The id of the agent that owns this Carnot instance.
   sole::uuid agent_id_;
+
+  // The exec states of the queries that are executing, so that they can be cancelled.
+  absl::Mutex running_queries_lock_;
+  absl::flat_hash_map<sole::uuid, exec::ExecState*> running_queries_
+      ABSL_GUARDED_BY(running_queries_lock_);
 };
 
 Status CarnotImpl::Init(const sole::uuid& agent_id, std::unique_ptr<udf::Registry> func_registry,
@@ -270,6 +280,16 @@ Status SendFinalExecutionStatsToOutgoingConns(
   return Status::OK();
 }
 
+Status CarnotImpl::CancelQuery(const sole::uuid& query_id) {
+  absl::MutexLock lock(&running_queries_lock_);
+  auto it = running_queries_.find(query_id);
+  if (it == running_queries_.end()) {
+    return error::NotFound("Query $0 is not executing", query_id.str());
+  }
+  it->second->Cancel();
+  return Status::OK();
+}
+
 Status CarnotImpl::ExecutePlan(const planpb::Plan& logical_plan, const sole::uuid& query_id,
                                bool analyze) {
   auto timer = ElapsedTimer();
@@ -280,6 +300,18 @@ Status CarnotImpl::ExecutePlan(const planpb::Plan& logical_plan, const sole::uui
   // For each of the plan fragments in the plan, execute the query.
   std::vector<std::string> output_table_strs;
   auto exec_state = engine_state_->CreateExecState(query_id);
+  {
+    absl::MutexLock lock(&running_queries_lock_);
+    running_queries_[query_id] = exec_state.get();
+  }
+  DEFER({
+    absl::MutexLock lock(&running_queries_lock_);
+    running_queries_.erase(query_id);
+  });
+  exec_state->set_max_rows_scanned(logical_plan.plan_options().max_rows_scanned_per_agent());
+  exec_state->set_timeout(std::chrono::nanoseconds(logical_plan.plan_options().timeout_ns()));
+  exec_state->set_partial_results_deadline(
+      std::chrono::nanoseconds(logical_plan.plan_options().partial_results_deadline_ns()));
 
   // TODO(michellenguyen/zasgar, PP-2579): We should periodically update the metadata state for
   // long-running queries after a certain time duration or number of row batches processed. For now,
@@ -363,14 +395,20 @@ Status CarnotImpl::ExecutePlan(const planpb::Plan& logical_plan, const sole::uui
 
   // Compute bytes processed and records processed across all agents for all queries,
   // regardless of flags.
+  bool scan_truncated = exec_state->scan_truncated();
   for (const auto& agent_stats : input_agent_stats) {
     bytes_processed += agent_stats.bytes_processed();
     rows_processed += agent_stats.records_processed();
+    scan_truncated |= agent_stats.scan_truncated();
   }
 
   agent_operator_exec_stats.set_execution_time_ns(exec_time_ns);
   agent_operator_exec_stats.set_bytes_processed(bytes_processed);
   agent_operator_exec_stats.set_records_processed(rows_processed);
+  agent_operator_exec_stats.set_scan_truncated(scan_truncated);
+  for (int64_t source_id : exec_state->incomplete_grpc_sources()) {
+    agent_operator_exec_stats.add_incomplete_grpc_source_ids(source_id);
+  }
 
   std::vector<queryresultspb::AgentExecutionStats> all_agent_stats;
   if (analyze) {
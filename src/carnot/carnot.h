@@ -72,6 +72,14 @@ class Carnot : public NotCopyable {
   virtual Status ExecutePlan(const planpb::Plan& plan, const sole::uuid& query_id,
                              bool analyze = false) = 0;
 
+  /**
+   * Cancels the query if it is executing, which then returns a Cancelled error. Can be called
+   * from any thread.
+   *
+   * @return a NotFound error if the query isn't executing.
+   */
+  virtual Status CancelQuery(const sole::uuid& query_id) = 0;
+
   /**
    * Registers the callback for updating the agents metadata state.
    */
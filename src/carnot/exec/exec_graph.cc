@@ -140,12 +140,23 @@ Status ExecutionGraph::CheckUpstreamGRPCConnectionHealth(GRPCSourceNode* source_
         exec_state_->query_id().str());
   }
 
+  SystemTimePoint now = std::chrono::system_clock::now();
+  auto delta = now - query_start_time_;
+
+  // In partial results mode, stop waiting for upstream agents that haven't finished in time.
+  auto partial_results_deadline = exec_state_->partial_results_deadline();
+  if (partial_results_deadline.count() > 0 && delta >= partial_results_deadline &&
+      !source_node->NextBatchReady() && source_node->HasBatchesRemaining()) {
+    return error::DeadlineExceeded(
+        "Error executing query $0: GRPC source node $1 did not finish within the partial results "
+        "deadline of $2 ms",
+        exec_state_->query_id().str(), source_node->DebugString(),
+        std::chrono::duration_cast<std::chrono::milliseconds>(partial_results_deadline).count());
+  }
+
   if (source_node->upstream_initiated_connection()) {
     return Status::OK();
   }
-
-  SystemTimePoint now = std::chrono::system_clock::now();
-  auto delta = now - query_start_time_;
   // Wait a certain amount of time before determining the connection has taken too long to
   // establish.
   if (delta < upstream_result_connection_timeout_ms_) {
@@ -171,6 +182,16 @@ Status ExecutionGraph::CheckDownstreamGRPCConnectionsHealth() {
   return Status::OK();
 }
 
+Status ExecutionGraph::CheckCancelled() {
+  if (exec_state_->cancelled()) {
+    return error::Cancelled("Query $0 was cancelled", exec_state_->query_id().str());
+  }
+  if (exec_state_->deadline_exceeded()) {
+    return error::DeadlineExceeded("Query $0 ran past its timeout", exec_state_->query_id().str());
+  }
+  return Status::OK();
+}
+
 Status ExecutionGraph::ExecuteSources() {
   absl::flat_hash_set<SourceNode*> running_sources;
 
@@ -187,6 +208,7 @@ Status ExecutionGraph::ExecuteSources() {
 
   // Run all sources to completion, or exit if the query encounters an error.
   while (running_sources.size()) {
+    PL_RETURN_IF_ERROR(CheckCancelled());
     absl::flat_hash_set<SourceNode*> completed_sources_execute_loop;
 
     for (SourceNode* source : running_sources) {
@@ -197,6 +219,7 @@ Status ExecutionGraph::ExecuteSources() {
               "GRPCSourceNode connection to remote sink not healthy, terminating that source and "
               "proceeding with the rest of the query. Message: $0",
               s.msg());
+          exec_state_->AddIncompleteGRPCSource(source_to_id.at(source));
           PL_RETURN_IF_ERROR(source->SendEndOfStream(exec_state_));
           completed_sources_execute_loop.insert(source);
           continue;
@@ -246,6 +269,7 @@ Status ExecutionGraph::ExecuteSources() {
       timer.Start();
       YieldWithTimeout();
       timer.Stop();
+      PL_RETURN_IF_ERROR(CheckCancelled());
 
       absl::flat_hash_set<SourceNode*> completed_sources_wait_loop;
 
@@ -264,6 +288,7 @@ Status ExecutionGraph::ExecuteSources() {
                 "GRPCSourceNode connection to remote sink not healthy, terminating that source and "
                 "proceeding with the rest of the query. Message: $0",
                 s.msg());
+            exec_state_->AddIncompleteGRPCSource(source_to_id.at(source));
             PL_RETURN_IF_ERROR(source->SendEndOfStream(exec_state_));
             completed_sources_wait_loop.insert(source);
             continue;
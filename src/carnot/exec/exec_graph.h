@@ -224,6 +224,8 @@ class ExecutionGraph {
   }
 
   Status ExecuteSources();
+  // Returns an error if the query has been cancelled or has run past its timeout.
+  Status CheckCancelled();
 
   ExecState* exec_state_;
   ObjectPool pool_{"exec_graph_pool"};
@@ -813,6 +813,67 @@ TEST_F(GRPCExecGraphTest, pending_row_batches_closed_connection_no_eos) {
   EXPECT_OK(s);
 }
 
+TEST_F(GRPCExecGraphTest, partial_results_deadline) {
+  // The upstream agent connected and sent some data, but never finished.
+  ExecutionGraph e{std::chrono::milliseconds(1), std::chrono::milliseconds(1)};
+  exec_state_->set_partial_results_deadline(std::chrono::milliseconds(1));
+  auto s = e.Init(schema_, plan_state_.get(), exec_state_.get(), plan_fragment_.get(),
+                  /* collect_exec_node_stats */ false);
+  ASSERT_OK(s);
+
+  auto grpc_sources = e.grpc_sources();
+  ASSERT_EQ(1, grpc_sources.size());
+  auto src_id = *(grpc_sources.begin());
+  auto grpc_src = static_cast<GRPCSourceNode*>(e.node(src_id).ConsumeValueOrDie());
+  grpc_src->set_upstream_initiated_connection();
+  auto req1 = std::make_unique<carnotpb::TransferResultChunkRequest>();
+
+  RowDescriptor output_rd({types::DataType::INT64});
+  auto rb1 = RowBatchBuilder(output_rd, 2, /*eow*/ false, /*eos*/ false)
+                 .AddColumn<types::Int64Value>({2, 3})
+                 .get();
+  ASSERT_OK(rb1.ToProto(req1->mutable_query_result()->mutable_row_batch()));
+  ASSERT_OK(grpc_src->EnqueueRowBatch(std::move(req1)));
+
+  // The query continues without the upstream agent once the deadline passes.
+  s = e.Execute();
+  EXPECT_OK(s);
+  EXPECT_EQ(std::vector<int64_t>({src_id}), exec_state_->incomplete_grpc_sources());
+}
+
+TEST_F(GRPCExecGraphTest, timeout) {
+  // The upstream agent connected, but never finishes sending its data.
+  ExecutionGraph e{std::chrono::milliseconds(1), std::chrono::milliseconds(1)};
+  exec_state_->set_timeout(std::chrono::milliseconds(1));
+  auto s = e.Init(schema_, plan_state_.get(), exec_state_.get(), plan_fragment_.get(),
+                  /* collect_exec_node_stats */ false);
+  ASSERT_OK(s);
+
+  auto grpc_sources = e.grpc_sources();
+  ASSERT_EQ(1, grpc_sources.size());
+  auto grpc_src = static_cast<GRPCSourceNode*>(e.node(*(grpc_sources.begin())).ConsumeValueOrDie());
+  grpc_src->set_upstream_initiated_connection();
+
+  s = e.Execute();
+  EXPECT_TRUE(error::IsDeadlineExceeded(s)) << s.ToString();
+}
+
+TEST_F(GRPCExecGraphTest, cancelled) {
+  ExecutionGraph e{std::chrono::milliseconds(1), std::chrono::milliseconds(1)};
+  auto s = e.Init(schema_, plan_state_.get(), exec_state_.get(), plan_fragment_.get(),
+                  /* collect_exec_node_stats */ false);
+  ASSERT_OK(s);
+
+  auto grpc_sources = e.grpc_sources();
+  ASSERT_EQ(1, grpc_sources.size());
+  auto grpc_src = static_cast<GRPCSourceNode*>(e.node(*(grpc_sources.begin())).ConsumeValueOrDie());
+  grpc_src->set_upstream_initiated_connection();
+
+  exec_state_->Cancel();
+  s = e.Execute();
+  EXPECT_TRUE(error::IsCancelled(s)) << s.ToString();
+}
+
 TEST_F(GRPCExecGraphTest, upstream_never_connected_no_active_sources) {
   // Make a GRPC source, but don't initiate the connection.
   // Set timeouts to be 0 so that we definitely time out on connections being established.
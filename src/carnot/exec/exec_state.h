@@ -20,8 +20,11 @@
 
 #include <arrow/memory_pool.h>
 
+#include <atomic>
+#include <chrono>
 #include <map>
 #include <memory>
+#include <optional>
 #include <string>
 #include <utility>
 #include <vector>
@@ -147,6 +150,47 @@ class ExecState {
     }
   }
 
+  // Sets the max number of rows the memory sources of this query may scan on this agent.
+  // 0 means unlimited.
+  void set_max_rows_scanned(int64_t max_rows_scanned) { max_rows_scanned_ = max_rows_scanned; }
+
+  // Memory sources call this with the number of rows they read from their table.
+  void AddRowsScanned(int64_t rows) { rows_scanned_ += rows; }
+
+  bool scan_budget_exhausted() const {
+    return max_rows_scanned_ > 0 && rows_scanned_ >= max_rows_scanned_;
+  }
+
+  // A memory source calls this when it stops early because the scan budget is exhausted.
+  void set_scan_truncated() { scan_truncated_ = true; }
+  bool scan_truncated() const { return scan_truncated_; }
+
+  // Cancel stops the query the next time its sources are run. Unlike the rest of the exec state,
+  // this can be called from any thread.
+  void Cancel() { cancelled_ = true; }
+  bool cancelled() const { return cancelled_; }
+
+  // Sets how long the query may run, starting now, before it is stopped. 0 means unlimited.
+  void set_timeout(std::chrono::nanoseconds timeout) {
+    if (timeout.count() > 0) {
+      deadline_ = std::chrono::steady_clock::now() + timeout;
+    }
+  }
+  bool deadline_exceeded() const {
+    return deadline_.has_value() && std::chrono::steady_clock::now() >= deadline_.value();
+  }
+
+  // Sets how long the GRPC sources of this query wait for their upstream agents before the query
+  // continues without them. 0 means they wait until the upstream agent finishes or disconnects.
+  void set_partial_results_deadline(std::chrono::nanoseconds deadline) {
+    partial_results_deadline_ = deadline;
+  }
+  std::chrono::nanoseconds partial_results_deadline() const { return partial_results_deadline_; }
+
+  // Records a GRPC source that was stopped before it received all of its data.
+  void AddIncompleteGRPCSource(int64_t source_id) { incomplete_grpc_sources_.push_back(source_id); }
+  const std::vector<int64_t>& incomplete_grpc_sources() const { return incomplete_grpc_sources_; }
+
   void set_metadata_state(std::shared_ptr<const md::AgentMetadataState> metadata_state) {
     metadata_state_ = metadata_state;
   }
@@ -179,6 +223,16 @@ class ExecState {
   bool current_source_set_ = false;
   std::map<int64_t, bool> source_id_to_keep_running_map_;
 
+  int64_t max_rows_scanned_ = 0;
+  int64_t rows_scanned_ = 0;
+  bool scan_truncated_ = false;
+
+  std::chrono::nanoseconds partial_results_deadline_{0};
+  std::vector<int64_t> incomplete_grpc_sources_;
+
+  std::atomic<bool> cancelled_ = false;
+  std::optional<std::chrono::steady_clock::time_point> deadline_;
+
   std::vector<std::unique_ptr<carnotpb::ResultSinkService::StubInterface>> result_sink_stubs_pool_;
   // Mapping of remote address to stub that serves that address.
   absl::flat_hash_map<std::string, carnotpb::ResultSinkService::StubInterface*>
@@ -84,6 +84,13 @@ Status MemorySourceNode::CloseImpl(ExecState*) {
 StatusOr<std::unique_ptr<RowBatch>> MemorySourceNode::GetNextRowBatch(ExecState* exec_state) {
   DCHECK(table_ != nullptr);
 
+  // Stop scanning once the query has used up its row budget on this agent. The budget is
+  // checked per batch, so the batch that crosses it is still returned.
+  if (exec_state->scan_budget_exhausted()) {
+    exec_state->set_scan_truncated();
+    return RowBatch::WithZeroRows(*output_descriptor_, /* eow */ true, /* eos */ true);
+  }
+
   if (infinite_stream_ && wait_for_valid_next_) {
     // If it's an infinite_stream that has read out all the current data in the table, we have to
     // keep around the last batch the infinite stream output and keep checking if the next batch
@@ -108,6 +115,7 @@ StatusOr<std::unique_ptr<RowBatch>> MemorySourceNode::GetNextRowBatch(ExecState*
 
   rows_processed_ += row_batch->num_rows();
   bytes_processed_ += row_batch->NumBytes();
+  exec_state->AddRowsScanned(row_batch->num_rows());
   auto next_batch = table_->NextBatch(current_batch_, stop_);
   if (infinite_stream_ && !next_batch.IsValid()) {
     wait_for_valid_next_ = true;
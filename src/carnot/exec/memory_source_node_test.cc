@@ -104,6 +104,32 @@ TEST_F(MemorySourceNodeTest, basic) {
   EXPECT_EQ(sizeof(int64_t) * 5, tester.node()->BytesProcessed());
 }
 
+TEST_F(MemorySourceNodeTest, scan_budget) {
+  auto op_proto = planpb::testutils::CreateTestSource1PB();
+  std::unique_ptr<plan::Operator> plan_node = plan::MemorySourceOperator::FromProto(op_proto, 1);
+  RowDescriptor output_rd({types::DataType::TIME64NS});
+  exec_state_->set_max_rows_scanned(2);
+
+  auto tester = exec::ExecNodeTester<MemorySourceNode, plan::MemorySourceOperator>(
+      *plan_node, output_rd, std::vector<RowDescriptor>({}), exec_state_.get());
+  EXPECT_TRUE(tester.node()->HasBatchesRemaining());
+  // The batch that crosses the budget is still returned.
+  tester.GenerateNextResult().ExpectRowBatch(
+      RowBatchBuilder(output_rd, 3, /*eow*/ false, /*eos*/ false)
+          .AddColumn<types::Time64NSValue>({1, 2, 3})
+          .get());
+  EXPECT_FALSE(exec_state_->scan_truncated());
+  EXPECT_TRUE(tester.node()->HasBatchesRemaining());
+  tester.GenerateNextResult().ExpectRowBatch(
+      RowBatchBuilder(output_rd, 0, /*eow*/ true, /*eos*/ true)
+          .AddColumn<types::Time64NSValue>({})
+          .get());
+  EXPECT_FALSE(tester.node()->HasBatchesRemaining());
+  tester.Close();
+  EXPECT_EQ(3, tester.node()->RowsProcessed());
+  EXPECT_TRUE(exec_state_->scan_truncated());
+}
+
 TEST_F(MemorySourceNodeTest, empty_table) {
   auto op_proto = planpb::testutils::CreateTestSource1PB("empty");
   std::unique_ptr<plan::Operator> plan_node = plan::MemorySourceOperator::FromProto(op_proto, 1);
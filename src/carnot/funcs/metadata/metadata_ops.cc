@@ -75,6 +75,25 @@ void RegisterMetadataOpsOrDie(px::carnot::udf::Registry* registry) {
   registry->RegisterOrDie<UPIDToPodStatusUDF>("upid_to_pod_status");
   registry->RegisterOrDie<UPIDToServiceNameUDF>("upid_to_service_name");
   registry->RegisterOrDie<UPIDToServiceIDUDF>("upid_to_service_id");
+  registry->RegisterOrDie<UPIDToDeploymentNameUDF>("upid_to_deployment_name");
+  registry->RegisterOrDie<UPIDToDeploymentIDUDF>("upid_to_deployment_id");
+  registry->RegisterOrDie<DeploymentIDToDeploymentNameUDF>("deployment_id_to_deployment_name");
+  registry->RegisterOrDie<DeploymentNameToDeploymentIDUDF>("deployment_name_to_deployment_id");
+  registry->RegisterOrDie<UPIDToStatefulSetNameUDF>("upid_to_statefulset_name");
+  registry->RegisterOrDie<UPIDToStatefulSetIDUDF>("upid_to_statefulset_id");
+  registry->RegisterOrDie<StatefulSetIDToStatefulSetNameUDF>("statefulset_id_to_statefulset_name");
+  registry->RegisterOrDie<StatefulSetNameToStatefulSetIDUDF>("statefulset_name_to_statefulset_id");
+  registry->RegisterOrDie<UPIDToDaemonSetNameUDF>("upid_to_daemonset_name");
+  registry->RegisterOrDie<UPIDToDaemonSetIDUDF>("upid_to_daemonset_id");
+  registry->RegisterOrDie<DaemonSetIDToDaemonSetNameUDF>("daemonset_id_to_daemonset_name");
+  registry->RegisterOrDie<DaemonSetNameToDaemonSetIDUDF>("daemonset_name_to_daemonset_id");
+  registry->RegisterOrDie<UPIDToJobNameUDF>("upid_to_job_name");
+  registry->RegisterOrDie<UPIDToJobIDUDF>("upid_to_job_id");
+  registry->RegisterOrDie<JobIDToJobNameUDF>("job_id_to_job_name");
+  registry->RegisterOrDie<JobNameToJobIDUDF>("job_name_to_job_id");
+  registry->RegisterOrDie<UPIDToPodLabelsUDF>("upid_to_pod_labels");
+  registry->RegisterOrDie<UPIDToPodAnnotationsUDF>("upid_to_pod_annotations");
+  registry->RegisterOrDie<ServiceNameToLabelsUDF>("service_name_to_labels");
   registry->RegisterOrDie<UPIDToStringUDF>("upid_to_string");
   registry->RegisterOrDie<HostnameUDF>("_exec_hostname");
   registry->RegisterOrDie<HostNumCPUsUDF>("_exec_host_num_cpus");
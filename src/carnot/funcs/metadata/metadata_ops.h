@@ -294,6 +294,33 @@ inline const px::md::PodInfo* UPIDtoPod(const px::md::AgentMetadataState* md,
   return pod_info;
 }
 
+// Returns the pod of the process, if it is owned by a workload of the given kind, such as a
+// "Deployment".
+inline const px::md::PodInfo* UPIDToWorkloadPod(const px::md::AgentMetadataState* md,
+                                                types::UInt128Value upid_value,
+                                                std::string_view kind) {
+  auto pod_info = UPIDtoPod(md, upid_value);
+  if (pod_info == nullptr || pod_info->workload_kind() != kind) {
+    return nullptr;
+  }
+  return pod_info;
+}
+
+inline types::StringValue WorkloadIDToName(const px::md::AgentMetadataState* md,
+                                           std::string_view workload_id, std::string_view kind) {
+  const auto* workload_info = md->k8s_metadata_state().WorkloadInfoByID(workload_id);
+  if (workload_info == nullptr || workload_info->kind() != kind) {
+    return "";
+  }
+  return absl::Substitute("$0/$1", workload_info->ns(), workload_info->name());
+}
+
+inline types::StringValue WorkloadNameToID(const px::md::AgentMetadataState* md,
+                                           std::string_view workload_name, std::string_view kind) {
+  PL_ASSIGN_OR(auto workload_name_view, internal::K8sName(workload_name), return "");
+  return md->k8s_metadata_state().WorkloadIDByName(kind, workload_name_view);
+}
+
 inline types::StringValue StringifyVector(const std::vector<std::string>& vec) {
   if (vec.size() == 1) {
     return std::string(vec[0]);
@@ -566,6 +593,386 @@ class UPIDToServiceNameUDF : public ScalarUDF {
   static udfspb::UDFSourceExecutor Executor() { return udfspb::UDFSourceExecutor::UDF_PEM; }
 };
 
+/**
+ * @brief Returns the deployment ID for the pod associated with the input upid.
+ */
+class UPIDToDeploymentIDUDF : public ScalarUDF {
+ public:
+  StringValue Exec(FunctionContext* ctx, UInt128Value upid_value) {
+    auto md = GetMetadataState(ctx);
+    auto pod_info = UPIDToWorkloadPod(md, upid_value, "Deployment");
+    if (pod_info == nullptr) {
+      return "";
+    }
+    return pod_info->workload_uid();
+  }
+
+  static udf::ScalarUDFDocBuilder Doc() {
+    return udf::ScalarUDFDocBuilder("Get the Deployment ID from a UPID.")
+        .Details(
+            "Gets the Kubernetes Deployment ID for the process with the given Unique Process ID "
+            "(UPID). If the process isn't owned by a Kubernetes Deployment, this function returns "
+            "an empty string.")
+        .Example("df.deployment_id = px.upid_to_deployment_id(df.upid)")
+        .Arg("upid", "The UPID of the process to get the deployment ID for.")
+        .Returns("The Kubernetes Deployment ID for the UPID passed in.");
+  }
+
+  // This UDF can currently only run on PEMs, because only PEMs have the UPID information.
+  static udfspb::UDFSourceExecutor Executor() { return udfspb::UDFSourceExecutor::UDF_PEM; }
+};
+
+/**
+ * @brief Returns the deployment name for the pod associated with the input upid.
+ */
+class UPIDToDeploymentNameUDF : public ScalarUDF {
+ public:
+  StringValue Exec(FunctionContext* ctx, UInt128Value upid_value) {
+    auto md = GetMetadataState(ctx);
+    auto pod_info = UPIDToWorkloadPod(md, upid_value, "Deployment");
+    if (pod_info == nullptr) {
+      return "";
+    }
+    return absl::Substitute("$0/$1", pod_info->ns(), pod_info->workload_name());
+  }
+
+  static udf::ScalarUDFDocBuilder Doc() {
+    return udf::ScalarUDFDocBuilder("Get the Deployment Name from a UPID.")
+        .Details(
+            "Gets the Kubernetes Deployment Name for the process with the given Unique Process ID "
+            "(UPID). If the process isn't owned by a Kubernetes Deployment, this function returns "
+            "an empty string.")
+        .Example("df.deployment = px.upid_to_deployment_name(df.upid)")
+        .Arg("upid", "The UPID of the process to get the deployment name for.")
+        .Returns("The Kubernetes Deployment Name for the UPID passed in.");
+  }
+
+  // This UDF can currently only run on PEMs, because only PEMs have the UPID information.
+  static udfspb::UDFSourceExecutor Executor() { return udfspb::UDFSourceExecutor::UDF_PEM; }
+};
+
+class DeploymentIDToDeploymentNameUDF : public ScalarUDF {
+ public:
+  StringValue Exec(FunctionContext* ctx, StringValue deployment_id) {
+    auto md = GetMetadataState(ctx);
+    return WorkloadIDToName(md, deployment_id, "Deployment");
+  }
+
+  static udf::ScalarUDFDocBuilder Doc() {
+    return udf::ScalarUDFDocBuilder("Convert the kubernetes Deployment ID to Deployment name.")
+        .Details(
+            "Converts the kubernetes Deployment ID to the name of the Deployment. If the ID "
+            "is not found in our mapping, then returns an empty string.")
+        .Example("df.deployment = px.deployment_id_to_deployment_name(df.deployment_id)")
+        .Arg("deployment_id", "The Deployment ID to get the Deployment name for.")
+        .Returns("The Deployment name or an empty string if deployment_id not found.");
+  }
+};
+
+class DeploymentNameToDeploymentIDUDF : public ScalarUDF {
+ public:
+  StringValue Exec(FunctionContext* ctx, StringValue deployment_name) {
+    auto md = GetMetadataState(ctx);
+    // This UDF expects the Deployment name to be in the format of "<ns>/<deployment-name>".
+    return WorkloadNameToID(md, deployment_name, "Deployment");
+  }
+
+  static udf::ScalarUDFDocBuilder Doc() {
+    return udf::ScalarUDFDocBuilder("Convert the Deployment name to the Deployment ID.")
+        .Details(
+            "Converts the Deployment name to the corresponding kubernetes Deployment ID. "
+            "If the name is not found in our mapping, the function returns an empty string.")
+        .Example("df.deployment_id = px.deployment_name_to_deployment_id(df.deployment)")
+        .Arg("deployment_name", "The Deployment to get the Deployment ID.")
+        .Returns("The kubernetes Deployment ID for the Deployment passed in.");
+  }
+};
+
+/**
+ * @brief Returns the statefulset ID for the pod associated with the input upid.
+ */
+class UPIDToStatefulSetIDUDF : public ScalarUDF {
+ public:
+  StringValue Exec(FunctionContext* ctx, UInt128Value upid_value) {
+    auto md = GetMetadataState(ctx);
+    auto pod_info = UPIDToWorkloadPod(md, upid_value, "StatefulSet");
+    if (pod_info == nullptr) {
+      return "";
+    }
+    return pod_info->workload_uid();
+  }
+
+  static udf::ScalarUDFDocBuilder Doc() {
+    return udf::ScalarUDFDocBuilder("Get the StatefulSet ID from a UPID.")
+        .Details(
+            "Gets the Kubernetes StatefulSet ID for the process with the given Unique Process ID "
+            "(UPID). If the process isn't owned by a Kubernetes StatefulSet, this function returns "
+            "an empty string.")
+        .Example("df.statefulset_id = px.upid_to_statefulset_id(df.upid)")
+        .Arg("upid", "The UPID of the process to get the statefulset ID for.")
+        .Returns("The Kubernetes StatefulSet ID for the UPID passed in.");
+  }
+
+  // This UDF can currently only run on PEMs, because only PEMs have the UPID information.
+  static udfspb::UDFSourceExecutor Executor() { return udfspb::UDFSourceExecutor::UDF_PEM; }
+};
+
+/**
+ * @brief Returns the statefulset name for the pod associated with the input upid.
+ */
+class UPIDToStatefulSetNameUDF : public ScalarUDF {
+ public:
+  StringValue Exec(FunctionContext* ctx, UInt128Value upid_value) {
+    auto md = GetMetadataState(ctx);
+    auto pod_info = UPIDToWorkloadPod(md, upid_value, "StatefulSet");
+    if (pod_info == nullptr) {
+      return "";
+    }
+    return absl::Substitute("$0/$1", pod_info->ns(), pod_info->workload_name());
+  }
+
+  static udf::ScalarUDFDocBuilder Doc() {
+    return udf::ScalarUDFDocBuilder("Get the StatefulSet Name from a UPID.")
+        .Details(
+            "Gets the Kubernetes StatefulSet Name for the process with the given Unique Process ID "
+            "(UPID). If the process isn't owned by a Kubernetes StatefulSet, this function returns "
+            "an empty string.")
+        .Example("df.statefulset = px.upid_to_statefulset_name(df.upid)")
+        .Arg("upid", "The UPID of the process to get the statefulset name for.")
+        .Returns("The Kubernetes StatefulSet Name for the UPID passed in.");
+  }
+
+  // This UDF can currently only run on PEMs, because only PEMs have the UPID information.
+  static udfspb::UDFSourceExecutor Executor() { return udfspb::UDFSourceExecutor::UDF_PEM; }
+};
+
+class StatefulSetIDToStatefulSetNameUDF : public ScalarUDF {
+ public:
+  StringValue Exec(FunctionContext* ctx, StringValue statefulset_id) {
+    auto md = GetMetadataState(ctx);
+    return WorkloadIDToName(md, statefulset_id, "StatefulSet");
+  }
+
+  static udf::ScalarUDFDocBuilder Doc() {
+    return udf::ScalarUDFDocBuilder("Convert the kubernetes StatefulSet ID to StatefulSet name.")
+        .Details(
+            "Converts the kubernetes StatefulSet ID to the name of the StatefulSet. If the ID "
+            "is not found in our mapping, then returns an empty string.")
+        .Example("df.statefulset = px.statefulset_id_to_statefulset_name(df.statefulset_id)")
+        .Arg("statefulset_id", "The StatefulSet ID to get the StatefulSet name for.")
+        .Returns("The StatefulSet name or an empty string if statefulset_id not found.");
+  }
+};
+
+class StatefulSetNameToStatefulSetIDUDF : public ScalarUDF {
+ public:
+  StringValue Exec(FunctionContext* ctx, StringValue statefulset_name) {
+    auto md = GetMetadataState(ctx);
+    // This UDF expects the StatefulSet name to be in the format of "<ns>/<statefulset-name>".
+    return WorkloadNameToID(md, statefulset_name, "StatefulSet");
+  }
+
+  static udf::ScalarUDFDocBuilder Doc() {
+    return udf::ScalarUDFDocBuilder("Convert the StatefulSet name to the StatefulSet ID.")
+        .Details(
+            "Converts the StatefulSet name to the corresponding kubernetes StatefulSet ID. "
+            "If the name is not found in our mapping, the function returns an empty string.")
+        .Example("df.statefulset_id = px.statefulset_name_to_statefulset_id(df.statefulset)")
+        .Arg("statefulset_name", "The StatefulSet to get the StatefulSet ID.")
+        .Returns("The kubernetes StatefulSet ID for the StatefulSet passed in.");
+  }
+};
+
+/**
+ * @brief Returns the daemonset ID for the pod associated with the input upid.
+ */
+class UPIDToDaemonSetIDUDF : public ScalarUDF {
+ public:
+  StringValue Exec(FunctionContext* ctx, UInt128Value upid_value) {
+    auto md = GetMetadataState(ctx);
+    auto pod_info = UPIDToWorkloadPod(md, upid_value, "DaemonSet");
+    if (pod_info == nullptr) {
+      return "";
+    }
+    return pod_info->workload_uid();
+  }
+
+  static udf::ScalarUDFDocBuilder Doc() {
+    return udf::ScalarUDFDocBuilder("Get the DaemonSet ID from a UPID.")
+        .Details(
+            "Gets the Kubernetes DaemonSet ID for the process with the given Unique Process ID "
+            "(UPID). If the process isn't owned by a Kubernetes DaemonSet, this function returns "
+            "an empty string.")
+        .Example("df.daemonset_id = px.upid_to_daemonset_id(df.upid)")
+        .Arg("upid", "The UPID of the process to get the daemonset ID for.")
+        .Returns("The Kubernetes DaemonSet ID for the UPID passed in.");
+  }
+
+  // This UDF can currently only run on PEMs, because only PEMs have the UPID information.
+  static udfspb::UDFSourceExecutor Executor() { return udfspb::UDFSourceExecutor::UDF_PEM; }
+};
+
+/**
+ * @brief Returns the daemonset name for the pod associated with the input upid.
+ */
+class UPIDToDaemonSetNameUDF : public ScalarUDF {
+ public:
+  StringValue Exec(FunctionContext* ctx, UInt128Value upid_value) {
+    auto md = GetMetadataState(ctx);
+    auto pod_info = UPIDToWorkloadPod(md, upid_value, "DaemonSet");
+    if (pod_info == nullptr) {
+      return "";
+    }
+    return absl::Substitute("$0/$1", pod_info->ns(), pod_info->workload_name());
+  }
+
+  static udf::ScalarUDFDocBuilder Doc() {
+    return udf::ScalarUDFDocBuilder("Get the DaemonSet Name from a UPID.")
+        .Details(
+            "Gets the Kubernetes DaemonSet Name for the process with the given Unique Process ID "
+            "(UPID). If the process isn't owned by a Kubernetes DaemonSet, this function returns "
+            "an empty string.")
+        .Example("df.daemonset = px.upid_to_daemonset_name(df.upid)")
+        .Arg("upid", "The UPID of the process to get the daemonset name for.")
+        .Returns("The Kubernetes DaemonSet Name for the UPID passed in.");
+  }
+
+  // This UDF can currently only run on PEMs, because only PEMs have the UPID information.
+  static udfspb::UDFSourceExecutor Executor() { return udfspb::UDFSourceExecutor::UDF_PEM; }
+};
+
+class DaemonSetIDToDaemonSetNameUDF : public ScalarUDF {
+ public:
+  StringValue Exec(FunctionContext* ctx, StringValue daemonset_id) {
+    auto md = GetMetadataState(ctx);
+    return WorkloadIDToName(md, daemonset_id, "DaemonSet");
+  }
+
+  static udf::ScalarUDFDocBuilder Doc() {
+    return udf::ScalarUDFDocBuilder("Convert the kubernetes DaemonSet ID to DaemonSet name.")
+        .Details(
+            "Converts the kubernetes DaemonSet ID to the name of the DaemonSet. If the ID "
+            "is not found in our mapping, then returns an empty string.")
+        .Example("df.daemonset = px.daemonset_id_to_daemonset_name(df.daemonset_id)")
+        .Arg("daemonset_id", "The DaemonSet ID to get the DaemonSet name for.")
+        .Returns("The DaemonSet name or an empty string if daemonset_id not found.");
+  }
+};
+
+class DaemonSetNameToDaemonSetIDUDF : public ScalarUDF {
+ public:
+  StringValue Exec(FunctionContext* ctx, StringValue daemonset_name) {
+    auto md = GetMetadataState(ctx);
+    // This UDF expects the DaemonSet name to be in the format of "<ns>/<daemonset-name>".
+    return WorkloadNameToID(md, daemonset_name, "DaemonSet");
+  }
+
+  static udf::ScalarUDFDocBuilder Doc() {
+    return udf::ScalarUDFDocBuilder("Convert the DaemonSet name to the DaemonSet ID.")
+        .Details(
+            "Converts the DaemonSet name to the corresponding kubernetes DaemonSet ID. "
+            "If the name is not found in our mapping, the function returns an empty string.")
+        .Example("df.daemonset_id = px.daemonset_name_to_daemonset_id(df.daemonset)")
+        .Arg("daemonset_name", "The DaemonSet to get the DaemonSet ID.")
+        .Returns("The kubernetes DaemonSet ID for the DaemonSet passed in.");
+  }
+};
+
+/**
+ * @brief Returns the job ID for the pod associated with the input upid.
+ */
+class UPIDToJobIDUDF : public ScalarUDF {
+ public:
+  StringValue Exec(FunctionContext* ctx, UInt128Value upid_value) {
+    auto md = GetMetadataState(ctx);
+    auto pod_info = UPIDToWorkloadPod(md, upid_value, "Job");
+    if (pod_info == nullptr) {
+      return "";
+    }
+    return pod_info->workload_uid();
+  }
+
+  static udf::ScalarUDFDocBuilder Doc() {
+    return udf::ScalarUDFDocBuilder("Get the Job ID from a UPID.")
+        .Details(
+            "Gets the Kubernetes Job ID for the process with the given Unique Process ID "
+            "(UPID). If the process isn't owned by a Kubernetes Job, this function returns "
+            "an empty string.")
+        .Example("df.job_id = px.upid_to_job_id(df.upid)")
+        .Arg("upid", "The UPID of the process to get the job ID for.")
+        .Returns("The Kubernetes Job ID for the UPID passed in.");
+  }
+
+  // This UDF can currently only run on PEMs, because only PEMs have the UPID information.
+  static udfspb::UDFSourceExecutor Executor() { return udfspb::UDFSourceExecutor::UDF_PEM; }
+};
+
+/**
+ * @brief Returns the job name for the pod associated with the input upid.
+ */
+class UPIDToJobNameUDF : public ScalarUDF {
+ public:
+  StringValue Exec(FunctionContext* ctx, UInt128Value upid_value) {
+    auto md = GetMetadataState(ctx);
+    auto pod_info = UPIDToWorkloadPod(md, upid_value, "Job");
+    if (pod_info == nullptr) {
+      return "";
+    }
+    return absl::Substitute("$0/$1", pod_info->ns(), pod_info->workload_name());
+  }
+
+  static udf::ScalarUDFDocBuilder Doc() {
+    return udf::ScalarUDFDocBuilder("Get the Job Name from a UPID.")
+        .Details(
+            "Gets the Kubernetes Job Name for the process with the given Unique Process ID "
+            "(UPID). If the process isn't owned by a Kubernetes Job, this function returns "
+            "an empty string.")
+        .Example("df.job = px.upid_to_job_name(df.upid)")
+        .Arg("upid", "The UPID of the process to get the job name for.")
+        .Returns("The Kubernetes Job Name for the UPID passed in.");
+  }
+
+  // This UDF can currently only run on PEMs, because only PEMs have the UPID information.
+  static udfspb::UDFSourceExecutor Executor() { return udfspb::UDFSourceExecutor::UDF_PEM; }
+};
+
+class JobIDToJobNameUDF : public ScalarUDF {
+ public:
+  StringValue Exec(FunctionContext* ctx, StringValue job_id) {
+    auto md = GetMetadataState(ctx);
+    return WorkloadIDToName(md, job_id, "Job");
+  }
+
+  static udf::ScalarUDFDocBuilder Doc() {
+    return udf::ScalarUDFDocBuilder("Convert the kubernetes Job ID to Job name.")
+        .Details(
+            "Converts the kubernetes Job ID to the name of the Job. If the ID "
+            "is not found in our mapping, then returns an empty string.")
+        .Example("df.job = px.job_id_to_job_name(df.job_id)")
+        .Arg("job_id", "The Job ID to get the Job name for.")
+        .Returns("The Job name or an empty string if job_id not found.");
+  }
+};
+
+class JobNameToJobIDUDF : public ScalarUDF {
+ public:
+  StringValue Exec(FunctionContext* ctx, StringValue job_name) {
+    auto md = GetMetadataState(ctx);
+    // This UDF expects the Job name to be in the format of "<ns>/<job-name>".
+    return WorkloadNameToID(md, job_name, "Job");
+  }
+
+  static udf::ScalarUDFDocBuilder Doc() {
+    return udf::ScalarUDFDocBuilder("Convert the Job name to the Job ID.")
+        .Details(
+            "Converts the Job name to the corresponding kubernetes Job ID. "
+            "If the name is not found in our mapping, the function returns an empty string.")
+        .Example("df.job_id = px.job_name_to_job_id(df.job)")
+        .Arg("job_name", "The Job to get the Job ID.")
+        .Returns("The kubernetes Job ID for the Job passed in.");
+  }
+};
+
 /**
  * @brief Returns the node name for the pod associated with the input upid.
  */
@@ -1088,6 +1495,104 @@ inline types::StringValue PodInfoToPodStatus(const px::md::PodInfo* pod_info) {
   return sb.GetString();
 }
 
+inline types::StringValue StringifyMap(const absl::flat_hash_map<std::string, std::string>& m) {
+  rapidjson::Document d;
+  d.SetObject();
+  for (const auto& [k, v] : m) {
+    d.AddMember(internal::StringRef(k), internal::StringRef(v), d.GetAllocator());
+  }
+  rapidjson::StringBuffer sb;
+  rapidjson::Writer<rapidjson::StringBuffer> writer(sb);
+  d.Accept(writer);
+  return sb.GetString();
+}
+
+/**
+ * @brief Returns the allow-listed labels of the pod associated with the input upid.
+ */
+class UPIDToPodLabelsUDF : public ScalarUDF {
+ public:
+  StringValue Exec(FunctionContext* ctx, UInt128Value upid_value) {
+    auto md = GetMetadataState(ctx);
+    auto pod_info = UPIDtoPod(md, upid_value);
+    if (pod_info == nullptr) {
+      return "";
+    }
+    return StringifyMap(pod_info->labels());
+  }
+
+  static udf::ScalarUDFDocBuilder Doc() {
+    return udf::ScalarUDFDocBuilder("Get the labels of the pod from a UPID.")
+        .Details(
+            "Gets the Kubernetes labels of the pod running the process with the given Unique "
+            "Process ID (UPID), as a JSON object. Only the label keys allowed by the metadata "
+            "service are included. Use px.pluck to get the value of a single label.")
+        .Example("df.app = px.pluck(px.upid_to_pod_labels(df.upid), 'app')")
+        .Arg("upid", "The UPID of the process to get the pod labels for.")
+        .Returns("The Kubernetes labels of the pod as a JSON object.");
+  }
+
+  // This UDF can currently only run on PEMs, because only PEMs have the UPID information.
+  static udfspb::UDFSourceExecutor Executor() { return udfspb::UDFSourceExecutor::UDF_PEM; }
+};
+
+/**
+ * @brief Returns the allow-listed annotations of the pod associated with the input upid.
+ */
+class UPIDToPodAnnotationsUDF : public ScalarUDF {
+ public:
+  StringValue Exec(FunctionContext* ctx, UInt128Value upid_value) {
+    auto md = GetMetadataState(ctx);
+    auto pod_info = UPIDtoPod(md, upid_value);
+    if (pod_info == nullptr) {
+      return "";
+    }
+    return StringifyMap(pod_info->annotations());
+  }
+
+  static udf::ScalarUDFDocBuilder Doc() {
+    return udf::ScalarUDFDocBuilder("Get the annotations of the pod from a UPID.")
+        .Details(
+            "Gets the Kubernetes annotations of the pod running the process with the given "
+            "Unique Process ID (UPID), as a JSON object. Only the annotation keys allowed by the "
+            "metadata service are included.")
+        .Example("df.owner = px.pluck(px.upid_to_pod_annotations(df.upid), 'example.com/owner')")
+        .Arg("upid", "The UPID of the process to get the pod annotations for.")
+        .Returns("The Kubernetes annotations of the pod as a JSON object.");
+  }
+
+  // This UDF can currently only run on PEMs, because only PEMs have the UPID information.
+  static udfspb::UDFSourceExecutor Executor() { return udfspb::UDFSourceExecutor::UDF_PEM; }
+};
+
+/**
+ * @brief Returns the allow-listed labels of the service with the given name.
+ */
+class ServiceNameToLabelsUDF : public ScalarUDF {
+ public:
+  StringValue Exec(FunctionContext* ctx, StringValue service_name) {
+    auto md = GetMetadataState(ctx);
+    // This UDF expects the service name to be in the format of "<ns>/<service-name>".
+    PL_ASSIGN_OR(auto service_name_view, internal::K8sName(service_name), return "");
+    auto service_id = md->k8s_metadata_state().ServiceIDByName(service_name_view);
+    auto service_info = md->k8s_metadata_state().ServiceInfoByID(service_id);
+    if (service_info == nullptr) {
+      return "";
+    }
+    return StringifyMap(service_info->labels());
+  }
+
+  static udf::ScalarUDFDocBuilder Doc() {
+    return udf::ScalarUDFDocBuilder("Get the labels of a service.")
+        .Details(
+            "Gets the Kubernetes labels of the service with the given name, as a JSON object. "
+            "Only the label keys allowed by the metadata service are included.")
+        .Example("df.team = px.pluck(px.service_name_to_labels(df.service), 'team')")
+        .Arg("service_name", "The name of the service to get the labels for.")
+        .Returns("The Kubernetes labels of the service as a JSON object.");
+  }
+};
+
 class PodNameToPodStatusUDF : public ScalarUDF {
  public:
   /**
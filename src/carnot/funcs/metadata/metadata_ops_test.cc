@@ -631,6 +631,169 @@ TEST_F(MetadataOpsTest, has_service_name_test) {
   udf_tester.ForInput("[]", "4").Expect(false);
 }
 
+// Adds workloads to the metadata state, and makes the running pod part of a StatefulSet and the
+// terminating pod part of a Job.
+class WorkloadMetadataOpsTest : public MetadataOpsTest {
+ protected:
+  void SetUp() override {
+    MetadataOpsTest::SetUp();
+
+    updates_->enqueue(px::metadatapb::testutils::CreateRunningDeploymentUpdatePB());
+    updates_->enqueue(px::metadatapb::testutils::CreateRunningStatefulSetUpdatePB());
+    updates_->enqueue(px::metadatapb::testutils::CreateRunningDaemonSetUpdatePB());
+    updates_->enqueue(px::metadatapb::testutils::CreateRunningJobUpdatePB());
+
+    auto running_pod = px::metadatapb::testutils::CreateRunningPodUpdatePB();
+    auto running_pod_workload = running_pod->mutable_pod_update()->mutable_workload();
+    running_pod_workload->set_uid("7_uid");
+    running_pod_workload->set_name("running_statefulset");
+    running_pod_workload->set_kind("StatefulSet");
+    updates_->enqueue(std::move(running_pod));
+
+    auto terminating_pod = px::metadatapb::testutils::CreateTerminatingPodUpdatePB();
+    auto terminating_pod_workload = terminating_pod->mutable_pod_update()->mutable_workload();
+    terminating_pod_workload->set_uid("9_uid");
+    terminating_pod_workload->set_name("running_job");
+    terminating_pod_workload->set_kind("Job");
+    updates_->enqueue(std::move(terminating_pod));
+
+    ASSERT_OK(px::md::ApplyK8sUpdates(11, metadata_state_.get(), &md_filter_, updates_.get()));
+  }
+};
+
+TEST_F(WorkloadMetadataOpsTest, upid_to_deployment_name_test) {
+  auto function_ctx = std::make_unique<FunctionContext>(metadata_state_, nullptr);
+  auto udf_tester = px::carnot::udf::UDFTester<UPIDToDeploymentNameUDF>(std::move(function_ctx));
+  // Neither pod is part of a Deployment.
+  auto upid1 = types::UInt128Value(528280977975, 89101);
+  udf_tester.ForInput(upid1).Expect("");
+  auto upid2 = types::UInt128Value(528280977975, 468);
+  udf_tester.ForInput(upid2).Expect("");
+}
+
+TEST_F(WorkloadMetadataOpsTest, upid_to_statefulset_id_test) {
+  auto function_ctx = std::make_unique<FunctionContext>(metadata_state_, nullptr);
+  auto udf_tester = px::carnot::udf::UDFTester<UPIDToStatefulSetIDUDF>(std::move(function_ctx));
+  auto upid1 = types::UInt128Value(528280977975, 89101);
+  udf_tester.ForInput(upid1).Expect("7_uid");
+  auto upid2 = types::UInt128Value(528280977975, 468);
+  udf_tester.ForInput(upid2).Expect("");
+  auto upid3 = types::UInt128Value(528280977975, 123);
+  udf_tester.ForInput(upid3).Expect("");
+}
+
+TEST_F(WorkloadMetadataOpsTest, upid_to_statefulset_name_test) {
+  auto function_ctx = std::make_unique<FunctionContext>(metadata_state_, nullptr);
+  auto udf_tester = px::carnot::udf::UDFTester<UPIDToStatefulSetNameUDF>(std::move(function_ctx));
+  auto upid1 = types::UInt128Value(528280977975, 89101);
+  udf_tester.ForInput(upid1).Expect("pl/running_statefulset");
+  auto upid2 = types::UInt128Value(528280977975, 468);
+  udf_tester.ForInput(upid2).Expect("");
+  auto upid3 = types::UInt128Value(528280977975, 123);
+  udf_tester.ForInput(upid3).Expect("");
+}
+
+TEST_F(WorkloadMetadataOpsTest, upid_to_daemonset_name_test) {
+  auto function_ctx = std::make_unique<FunctionContext>(metadata_state_, nullptr);
+  auto udf_tester = px::carnot::udf::UDFTester<UPIDToDaemonSetNameUDF>(std::move(function_ctx));
+  auto upid1 = types::UInt128Value(528280977975, 89101);
+  udf_tester.ForInput(upid1).Expect("");
+  auto upid2 = types::UInt128Value(528280977975, 468);
+  udf_tester.ForInput(upid2).Expect("");
+}
+
+TEST_F(WorkloadMetadataOpsTest, upid_to_job_id_test) {
+  auto function_ctx = std::make_unique<FunctionContext>(metadata_state_, nullptr);
+  auto udf_tester = px::carnot::udf::UDFTester<UPIDToJobIDUDF>(std::move(function_ctx));
+  auto upid1 = types::UInt128Value(528280977975, 89101);
+  udf_tester.ForInput(upid1).Expect("");
+  auto upid2 = types::UInt128Value(528280977975, 468);
+  udf_tester.ForInput(upid2).Expect("9_uid");
+}
+
+TEST_F(WorkloadMetadataOpsTest, upid_to_job_name_test) {
+  auto function_ctx = std::make_unique<FunctionContext>(metadata_state_, nullptr);
+  auto udf_tester = px::carnot::udf::UDFTester<UPIDToJobNameUDF>(std::move(function_ctx));
+  auto upid1 = types::UInt128Value(528280977975, 89101);
+  udf_tester.ForInput(upid1).Expect("");
+  auto upid2 = types::UInt128Value(528280977975, 468);
+  udf_tester.ForInput(upid2).Expect("pl/running_job");
+}
+
+TEST_F(WorkloadMetadataOpsTest, deployment_id_to_deployment_name_test) {
+  auto function_ctx = std::make_unique<FunctionContext>(metadata_state_, nullptr);
+  auto udf_tester =
+      px::carnot::udf::UDFTester<DeploymentIDToDeploymentNameUDF>(std::move(function_ctx));
+  udf_tester.ForInput("6_uid").Expect("pl/running_deployment");
+  // The ID of a workload of another kind.
+  udf_tester.ForInput("7_uid").Expect("");
+  // The ID of an object that isn't a workload.
+  udf_tester.ForInput("1_uid").Expect("");
+  udf_tester.ForInput("nonexistent").Expect("");
+}
+
+TEST_F(WorkloadMetadataOpsTest, deployment_name_to_deployment_id_test) {
+  auto function_ctx = std::make_unique<FunctionContext>(metadata_state_, nullptr);
+  auto udf_tester =
+      px::carnot::udf::UDFTester<DeploymentNameToDeploymentIDUDF>(std::move(function_ctx));
+  udf_tester.ForInput("pl/running_deployment").Expect("6_uid");
+  udf_tester.ForInput("pl/running_statefulset").Expect("");
+  udf_tester.ForInput("pl/nonexistent").Expect("");
+  udf_tester.ForInput("bad_format").Expect("");
+}
+
+TEST_F(WorkloadMetadataOpsTest, statefulset_id_to_statefulset_name_test) {
+  auto function_ctx = std::make_unique<FunctionContext>(metadata_state_, nullptr);
+  auto udf_tester =
+      px::carnot::udf::UDFTester<StatefulSetIDToStatefulSetNameUDF>(std::move(function_ctx));
+  udf_tester.ForInput("7_uid").Expect("pl/running_statefulset");
+  udf_tester.ForInput("6_uid").Expect("");
+  udf_tester.ForInput("nonexistent").Expect("");
+}
+
+TEST_F(WorkloadMetadataOpsTest, statefulset_name_to_statefulset_id_test) {
+  auto function_ctx = std::make_unique<FunctionContext>(metadata_state_, nullptr);
+  auto udf_tester =
+      px::carnot::udf::UDFTester<StatefulSetNameToStatefulSetIDUDF>(std::move(function_ctx));
+  udf_tester.ForInput("pl/running_statefulset").Expect("7_uid");
+  udf_tester.ForInput("pl/running_deployment").Expect("");
+  udf_tester.ForInput("bad_format").Expect("");
+}
+
+TEST_F(WorkloadMetadataOpsTest, daemonset_id_to_daemonset_name_test) {
+  auto function_ctx = std::make_unique<FunctionContext>(metadata_state_, nullptr);
+  auto udf_tester =
+      px::carnot::udf::UDFTester<DaemonSetIDToDaemonSetNameUDF>(std::move(function_ctx));
+  udf_tester.ForInput("8_uid").Expect("pl/running_daemonset");
+  udf_tester.ForInput("9_uid").Expect("");
+  udf_tester.ForInput("nonexistent").Expect("");
+}
+
+TEST_F(WorkloadMetadataOpsTest, daemonset_name_to_daemonset_id_test) {
+  auto function_ctx = std::make_unique<FunctionContext>(metadata_state_, nullptr);
+  auto udf_tester =
+      px::carnot::udf::UDFTester<DaemonSetNameToDaemonSetIDUDF>(std::move(function_ctx));
+  udf_tester.ForInput("pl/running_daemonset").Expect("8_uid");
+  udf_tester.ForInput("pl/nonexistent").Expect("");
+  udf_tester.ForInput("bad_format").Expect("");
+}
+
+TEST_F(WorkloadMetadataOpsTest, job_id_to_job_name_test) {
+  auto function_ctx = std::make_unique<FunctionContext>(metadata_state_, nullptr);
+  auto udf_tester = px::carnot::udf::UDFTester<JobIDToJobNameUDF>(std::move(function_ctx));
+  udf_tester.ForInput("9_uid").Expect("pl/running_job");
+  udf_tester.ForInput("8_uid").Expect("");
+  udf_tester.ForInput("nonexistent").Expect("");
+}
+
+TEST_F(WorkloadMetadataOpsTest, job_name_to_job_id_test) {
+  auto function_ctx = std::make_unique<FunctionContext>(metadata_state_, nullptr);
+  auto udf_tester = px::carnot::udf::UDFTester<JobNameToJobIDUDF>(std::move(function_ctx));
+  udf_tester.ForInput("pl/running_job").Expect("9_uid");
+  udf_tester.ForInput("pl/nonexistent").Expect("");
+  udf_tester.ForInput("bad_format").Expect("");
+}
+
 }  // namespace metadata
 }  // namespace funcs
 }  // namespace carnot
@@ -46,6 +46,12 @@ std::unique_ptr<MetadataHandler> MetadataHandler::Create() {
                                          {MetadataType::UPID, MetadataType::POD_NAME});
   handler->AddObject<IdMetadataProperty>(MetadataType::DEPLOYMENT_ID, {},
                                          {MetadataType::UPID, MetadataType::DEPLOYMENT_NAME});
+  handler->AddObject<IdMetadataProperty>(MetadataType::STATEFULSET_ID, {},
+                                         {MetadataType::UPID, MetadataType::STATEFULSET_NAME});
+  handler->AddObject<IdMetadataProperty>(MetadataType::DAEMONSET_ID, {},
+                                         {MetadataType::UPID, MetadataType::DAEMONSET_NAME});
+  handler->AddObject<IdMetadataProperty>(MetadataType::JOB_ID, {},
+                                         {MetadataType::UPID, MetadataType::JOB_NAME});
   handler->AddObject<NameMetadataProperty>(
       MetadataType::SERVICE_NAME, {"service"},
       {MetadataType::UPID, MetadataType::SERVICE_ID, MetadataType::POD_ID, MetadataType::POD_NAME});
@@ -53,6 +59,12 @@ std::unique_ptr<MetadataHandler> MetadataHandler::Create() {
                                            {MetadataType::UPID, MetadataType::POD_ID});
   handler->AddObject<NameMetadataProperty>(MetadataType::DEPLOYMENT_NAME, {"deployment"},
                                            {MetadataType::UPID, MetadataType::DEPLOYMENT_ID});
+  handler->AddObject<NameMetadataProperty>(MetadataType::STATEFULSET_NAME, {"statefulset"},
+                                           {MetadataType::UPID, MetadataType::STATEFULSET_ID});
+  handler->AddObject<NameMetadataProperty>(MetadataType::DAEMONSET_NAME, {"daemonset"},
+                                           {MetadataType::UPID, MetadataType::DAEMONSET_ID});
+  handler->AddObject<NameMetadataProperty>(MetadataType::JOB_NAME, {"job"},
+                                           {MetadataType::UPID, MetadataType::JOB_ID});
   handler->AddObject<NameMetadataProperty>(MetadataType::NAMESPACE, {},
                                            {MetadataType::UPID, MetadataType::POD_ID,
                                             MetadataType::POD_NAME, MetadataType::SERVICE_NAME});
@@ -68,9 +68,11 @@ TEST_P(MetadataGetPropertyTests, has_property) {
   EXPECT_OK(property_status);
 }
 
-std::vector<std::string> metadata_strs = {"service_name",  "service_id",   "pod_name",
-                                          "pod_id",        "container_id", "deployment_id",
-                                          "container_name"};
+std::vector<std::string> metadata_strs = {
+    "service_name",     "service_id",     "pod_name",       "pod_id",
+    "container_id",     "deployment_id",  "container_name", "statefulset_id",
+    "statefulset_name", "daemonset_id",   "daemonset_name", "job_id",
+    "job_name"};
 
 INSTANTIATE_TEST_SUITE_P(GetPropertyTestSuites, MetadataGetPropertyTests,
                          ::testing::ValuesIn(metadata_strs));
@@ -95,6 +97,9 @@ std::vector<std::tuple<std::string, std::string>> alias_to_original = {
     {"service", "service_name"},
     {"pod", "pod_name"},
     {"deployment", "deployment_name"},
+    {"statefulset", "statefulset_name"},
+    {"daemonset", "daemonset_name"},
+    {"job", "job_name"},
     {"container", "container_name"}};
 
 INSTANTIATE_TEST_SUITE_P(AliasPropertyTestSuites, MetadataAliasPropertyTests,
@@ -49,9 +49,15 @@ class MetadataObject : public QLObject {
   * service_id: Sources: "upid","service_name"
   * pod_id: Sources: "upid","pod_name"
   * deployment_id: Sources: "upid","deployment_name"
+  * statefulset_id: Sources: "upid","statefulset_name"
+  * daemonset_id: Sources: "upid","daemonset_name"
+  * job_id: Sources: "upid","job_name"
   * service_name ("service"): Sources: "upid","service_id"
   * pod_name ("pod"): Sources: "upid","pod_id"
   * deployment_name ("deployment"): Sources: "upid","deployment_id"
+  * statefulset_name ("statefulset"): Sources: "upid","statefulset_id"
+  * daemonset_name ("daemonset"): Sources: "upid","daemonset_id"
+  * job_name ("job"): Sources: "upid","job_id"
   * namespace: Sources: "upid"
   * node_name ("node"): Sources: "upid"
   * hostname ("host"): Sources: "upid"
@@ -201,6 +201,8 @@ func main() {
 	vpt := ptproxy.NewVizierPassThroughProxy(nc, vc)
 	vizierpb.RegisterVizierServiceServer(s.GRPCServer(), vpt)
 	vizierpb.RegisterVizierDebugServiceServer(s.GRPCServer(), vpt)
+	vizierpb.RegisterVizierScheduledScriptServiceServer(s.GRPCServer(), vpt)
+	vizierpb.RegisterVizierTracepointServiceServer(s.GRPCServer(), vpt)
 
 	sm, err := apienv.NewScriptMgrServiceClient()
 	if err != nil {
@@ -32,19 +32,21 @@ var actionToProtoMap = map[string]cloudpb.AutocompleteActionType{
 }
 
 var protoToKindMap = map[cloudpb.AutocompleteEntityKind]string{
-	cloudpb.AEK_UNKNOWN:   "AEK_UNKNOWN",
-	cloudpb.AEK_POD:       "AEK_POD",
-	cloudpb.AEK_SVC:       "AEK_SVC",
-	cloudpb.AEK_SCRIPT:    "AEK_SCRIPT",
-	cloudpb.AEK_NAMESPACE: "AEK_NAMESPACE",
+	cloudpb.AEK_UNKNOWN:    "AEK_UNKNOWN",
+	cloudpb.AEK_POD:        "AEK_POD",
+	cloudpb.AEK_SVC:        "AEK_SVC",
+	cloudpb.AEK_SCRIPT:     "AEK_SCRIPT",
+	cloudpb.AEK_NAMESPACE:  "AEK_NAMESPACE",
+	cloudpb.AEK_DEPLOYMENT: "AEK_DEPLOYMENT",
 }
 
 var kindToProtoMap = map[string]cloudpb.AutocompleteEntityKind{
-	"AEK_UNKNOWN":   cloudpb.AEK_UNKNOWN,
-	"AEK_POD":       cloudpb.AEK_POD,
-	"AEK_SVC":       cloudpb.AEK_SVC,
-	"AEK_SCRIPT":    cloudpb.AEK_SCRIPT,
-	"AEK_NAMESPACE": cloudpb.AEK_NAMESPACE,
+	"AEK_UNKNOWN":    cloudpb.AEK_UNKNOWN,
+	"AEK_POD":        cloudpb.AEK_POD,
+	"AEK_SVC":        cloudpb.AEK_SVC,
+	"AEK_SCRIPT":     cloudpb.AEK_SCRIPT,
+	"AEK_NAMESPACE":  cloudpb.AEK_NAMESPACE,
+	"AEK_DEPLOYMENT": cloudpb.AEK_DEPLOYMENT,
 }
 
 var protoToStateMap = map[cloudpb.AutocompleteEntityState]string{
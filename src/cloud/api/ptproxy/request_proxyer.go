@@ -293,6 +293,60 @@ func (p *requestProxyer) processNatsMsg(msg *nats.Msg) error {
 			log.WithError(err).Error("Failed to send message")
 			return err
 		}
+	case *cvmsgspb.V2CAPIStreamResponse_DebugCreateSnapshotResp:
+		err = p.srv.SendMsg(parsed.DebugCreateSnapshotResp)
+		if err != nil {
+			log.WithError(err).Error("Failed to send message")
+			return err
+		}
+	case *cvmsgspb.V2CAPIStreamResponse_DebugListSnapshotsResp:
+		err = p.srv.SendMsg(parsed.DebugListSnapshotsResp)
+		if err != nil {
+			log.WithError(err).Error("Failed to send message")
+			return err
+		}
+	case *cvmsgspb.V2CAPIStreamResponse_UpsertScheduledScriptResp:
+		err = p.srv.SendMsg(parsed.UpsertScheduledScriptResp)
+		if err != nil {
+			log.WithError(err).Error("Failed to send message")
+			return err
+		}
+	case *cvmsgspb.V2CAPIStreamResponse_ListScheduledScriptsResp:
+		err = p.srv.SendMsg(parsed.ListScheduledScriptsResp)
+		if err != nil {
+			log.WithError(err).Error("Failed to send message")
+			return err
+		}
+	case *cvmsgspb.V2CAPIStreamResponse_DeleteScheduledScriptResp:
+		err = p.srv.SendMsg(parsed.DeleteScheduledScriptResp)
+		if err != nil {
+			log.WithError(err).Error("Failed to send message")
+			return err
+		}
+	case *cvmsgspb.V2CAPIStreamResponse_ListTracepointsResp:
+		err = p.srv.SendMsg(parsed.ListTracepointsResp)
+		if err != nil {
+			log.WithError(err).Error("Failed to send message")
+			return err
+		}
+	case *cvmsgspb.V2CAPIStreamResponse_GetTracepointResp:
+		err = p.srv.SendMsg(parsed.GetTracepointResp)
+		if err != nil {
+			log.WithError(err).Error("Failed to send message")
+			return err
+		}
+	case *cvmsgspb.V2CAPIStreamResponse_DeleteTracepointsResp:
+		err = p.srv.SendMsg(parsed.DeleteTracepointsResp)
+		if err != nil {
+			log.WithError(err).Error("Failed to send message")
+			return err
+		}
+	case *cvmsgspb.V2CAPIStreamResponse_ExtendTracepointsResp:
+		err = p.srv.SendMsg(parsed.ExtendTracepointsResp)
+		if err != nil {
+			log.WithError(err).Error("Failed to send message")
+			return err
+		}
 	case *cvmsgspb.V2CAPIStreamResponse_Status:
 		// Status message come when the stream is closed.
 		if codes.Code(parsed.Status.Code) == codes.OK {
@@ -112,6 +112,156 @@ func (v *VizierPassThroughProxy) DebugPods(req *vizierpb.DebugPodsRequest, srv v
 	return rp.Run()
 }
 
+// DebugCreateSnapshot is the GRPC method to snapshot the metadata datastore of a cluster.
+func (v *VizierPassThroughProxy) DebugCreateSnapshot(req *vizierpb.DebugCreateSnapshotRequest, srv vizierpb.VizierDebugService_DebugCreateSnapshotServer) error {
+	rp, err := newRequestProxyer(v.vc, v.nc, true, req, srv)
+	if err != nil {
+		return err
+	}
+	defer rp.Finish()
+	vizReq := rp.prepareVizierRequest()
+	vizReq.Msg = &cvmsgspb.C2VAPIStreamRequest_DebugCreateSnapshotReq{DebugCreateSnapshotReq: req}
+	if err := rp.sendMessageToVizier(vizReq); err != nil {
+		return err
+	}
+	return rp.Run()
+}
+
+// DebugListSnapshots is the GRPC method to list the snapshots of the metadata datastore of a cluster.
+func (v *VizierPassThroughProxy) DebugListSnapshots(req *vizierpb.DebugListSnapshotsRequest, srv vizierpb.VizierDebugService_DebugListSnapshotsServer) error {
+	rp, err := newRequestProxyer(v.vc, v.nc, true, req, srv)
+	if err != nil {
+		return err
+	}
+	defer rp.Finish()
+	vizReq := rp.prepareVizierRequest()
+	vizReq.Msg = &cvmsgspb.C2VAPIStreamRequest_DebugListSnapshotsReq{DebugListSnapshotsReq: req}
+	if err := rp.sendMessageToVizier(vizReq); err != nil {
+		return err
+	}
+	return rp.Run()
+}
+
+// UpsertScheduledScript is the GRPC stream method to create or update a scheduled script.
+func (v *VizierPassThroughProxy) UpsertScheduledScript(req *vizierpb.UpsertScheduledScriptRequest, srv vizierpb.VizierScheduledScriptService_UpsertScheduledScriptServer) error {
+	rp, err := newRequestProxyer(v.vc, v.nc, false, req, srv)
+	if err != nil {
+		return err
+	}
+	defer rp.Finish()
+	vizReq := rp.prepareVizierRequest()
+	vizReq.Msg = &cvmsgspb.C2VAPIStreamRequest_UpsertScheduledScriptReq{UpsertScheduledScriptReq: req}
+	if err := rp.sendMessageToVizier(vizReq); err != nil {
+		return err
+	}
+	return rp.Run()
+}
+
+// ListScheduledScripts is the GRPC stream method to list the scheduled scripts of a cluster.
+func (v *VizierPassThroughProxy) ListScheduledScripts(req *vizierpb.ListScheduledScriptsRequest, srv vizierpb.VizierScheduledScriptService_ListScheduledScriptsServer) error {
+	rp, err := newRequestProxyer(v.vc, v.nc, false, req, srv)
+	if err != nil {
+		return err
+	}
+	defer rp.Finish()
+	vizReq := rp.prepareVizierRequest()
+	vizReq.Msg = &cvmsgspb.C2VAPIStreamRequest_ListScheduledScriptsReq{ListScheduledScriptsReq: req}
+	if err := rp.sendMessageToVizier(vizReq); err != nil {
+		return err
+	}
+	return rp.Run()
+}
+
+// DeleteScheduledScript is the GRPC stream method to delete a scheduled script.
+func (v *VizierPassThroughProxy) DeleteScheduledScript(req *vizierpb.DeleteScheduledScriptRequest, srv vizierpb.VizierScheduledScriptService_DeleteScheduledScriptServer) error {
+	rp, err := newRequestProxyer(v.vc, v.nc, false, req, srv)
+	if err != nil {
+		return err
+	}
+	defer rp.Finish()
+	vizReq := rp.prepareVizierRequest()
+	vizReq.Msg = &cvmsgspb.C2VAPIStreamRequest_DeleteScheduledScriptReq{DeleteScheduledScriptReq: req}
+	if err := rp.sendMessageToVizier(vizReq); err != nil {
+		return err
+	}
+	return rp.Run()
+}
+
+// GetScheduledScriptResults is the GRPC stream method to fetch the persisted results of a scheduled script.
+func (v *VizierPassThroughProxy) GetScheduledScriptResults(req *vizierpb.GetScheduledScriptResultsRequest, srv vizierpb.VizierScheduledScriptService_GetScheduledScriptResultsServer) error {
+	rp, err := newRequestProxyer(v.vc, v.nc, false, req, srv)
+	if err != nil {
+		return err
+	}
+	defer rp.Finish()
+	vizReq := rp.prepareVizierRequest()
+	vizReq.Msg = &cvmsgspb.C2VAPIStreamRequest_GetScheduledScriptResultsReq{GetScheduledScriptResultsReq: req}
+	if err := rp.sendMessageToVizier(vizReq); err != nil {
+		return err
+	}
+	return rp.Run()
+}
+
+// ListTracepoints is the GRPC stream method to list the tracepoints of a cluster.
+func (v *VizierPassThroughProxy) ListTracepoints(req *vizierpb.ListTracepointsRequest, srv vizierpb.VizierTracepointService_ListTracepointsServer) error {
+	rp, err := newRequestProxyer(v.vc, v.nc, false, req, srv)
+	if err != nil {
+		return err
+	}
+	defer rp.Finish()
+	vizReq := rp.prepareVizierRequest()
+	vizReq.Msg = &cvmsgspb.C2VAPIStreamRequest_ListTracepointsReq{ListTracepointsReq: req}
+	if err := rp.sendMessageToVizier(vizReq); err != nil {
+		return err
+	}
+	return rp.Run()
+}
+
+// GetTracepoint is the GRPC stream method to get a tracepoint and its state on each agent.
+func (v *VizierPassThroughProxy) GetTracepoint(req *vizierpb.GetTracepointRequest, srv vizierpb.VizierTracepointService_GetTracepointServer) error {
+	rp, err := newRequestProxyer(v.vc, v.nc, false, req, srv)
+	if err != nil {
+		return err
+	}
+	defer rp.Finish()
+	vizReq := rp.prepareVizierRequest()
+	vizReq.Msg = &cvmsgspb.C2VAPIStreamRequest_GetTracepointReq{GetTracepointReq: req}
+	if err := rp.sendMessageToVizier(vizReq); err != nil {
+		return err
+	}
+	return rp.Run()
+}
+
+// DeleteTracepoints is the GRPC stream method to delete tracepoints.
+func (v *VizierPassThroughProxy) DeleteTracepoints(req *vizierpb.DeleteTracepointsRequest, srv vizierpb.VizierTracepointService_DeleteTracepointsServer) error {
+	rp, err := newRequestProxyer(v.vc, v.nc, false, req, srv)
+	if err != nil {
+		return err
+	}
+	defer rp.Finish()
+	vizReq := rp.prepareVizierRequest()
+	vizReq.Msg = &cvmsgspb.C2VAPIStreamRequest_DeleteTracepointsReq{DeleteTracepointsReq: req}
+	if err := rp.sendMessageToVizier(vizReq); err != nil {
+		return err
+	}
+	return rp.Run()
+}
+
+// ExtendTracepoints is the GRPC stream method to extend the TTL of tracepoints.
+func (v *VizierPassThroughProxy) ExtendTracepoints(req *vizierpb.ExtendTracepointsRequest, srv vizierpb.VizierTracepointService_ExtendTracepointsServer) error {
+	rp, err := newRequestProxyer(v.vc, v.nc, false, req, srv)
+	if err != nil {
+		return err
+	}
+	defer rp.Finish()
+	vizReq := rp.prepareVizierRequest()
+	vizReq.Msg = &cvmsgspb.C2VAPIStreamRequest_ExtendTracepointsReq{ExtendTracepointsReq: req}
+	if err := rp.sendMessageToVizier(vizReq); err != nil {
+		return err
+	}
+	return rp.Run()
+}
+
 func getCredsFromCtx(ctx context.Context) (string, *jwtpb.JWTClaims, error) {
 	aCtx, err := authcontext.FromContext(ctx)
 	if err != nil {
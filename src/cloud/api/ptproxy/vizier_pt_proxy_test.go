@@ -73,6 +73,8 @@ func createTestState(t *testing.T) (*testState, func(t *testing.T)) {
 
 	vizierpb.RegisterVizierServiceServer(s, ptproxy.NewVizierPassThroughProxy(nc, &fakeVzMgr{}))
 	vizierpb.RegisterVizierDebugServiceServer(s, ptproxy.NewVizierPassThroughProxy(nc, &fakeVzMgr{}))
+	vizierpb.RegisterVizierScheduledScriptServiceServer(s, ptproxy.NewVizierPassThroughProxy(nc, &fakeVzMgr{}))
+	vizierpb.RegisterVizierTracepointServiceServer(s, ptproxy.NewVizierPassThroughProxy(nc, &fakeVzMgr{}))
 
 	eg := errgroup.Group{}
 	eg.Go(func() error { return s.Serve(lis) })
@@ -786,6 +788,112 @@ func (v *fakeVzMgr) GetVizierConnectionInfo(ctx context.Context, in *uuidpb.UUID
 	return results.info, results.err
 }
 
+func TestVizierPassThroughProxy_ListScheduledScripts(t *testing.T) {
+	viper.Set("jwt_signing_key", "the-key")
+
+	ts, cleanup := createTestState(t)
+	defer cleanup(t)
+
+	client := vizierpb.NewVizierScheduledScriptServiceClient(ts.conn)
+	validTestToken := testingutils.GenerateTestJWTToken(t, viper.GetString("jwt_signing_key"))
+	clusterID := "00000000-1111-2222-2222-333333333333"
+
+	scripts := &vizierpb.ListScheduledScriptsResponse{
+		Scripts: []*vizierpb.ScheduledScript{{ID: "script1", Name: "errors", CronExpression: "@hourly"}},
+	}
+	fv := newFakeVizier(t, uuid.FromStringOrNil(clusterID), ts.nc)
+	fv.Run(t, []*cvmsgspb.V2CAPIStreamResponse{
+		{Msg: &cvmsgspb.V2CAPIStreamResponse_ListScheduledScriptsResp{ListScheduledScriptsResp: scripts}},
+	})
+	defer fv.Stop()
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization",
+		fmt.Sprintf("bearer %s", validTestToken))
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+	resp, err := client.ListScheduledScripts(ctx, &vizierpb.ListScheduledScriptsRequest{ClusterID: clusterID})
+	require.NoError(t, err)
+
+	msg, err := resp.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, scripts, msg)
+	_, err = resp.Recv()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestVizierPassThroughProxy_DebugListSnapshots(t *testing.T) {
+	viper.Set("jwt_signing_key", "the-key")
+
+	ts, cleanup := createTestState(t)
+	defer cleanup(t)
+
+	client := vizierpb.NewVizierDebugServiceClient(ts.conn)
+	validTestToken := testingutils.GenerateTestJWTToken(t, viper.GetString("jwt_signing_key"))
+	clusterID := "00000000-1111-2222-2222-333333333333"
+
+	snapshots := &vizierpb.DebugListSnapshotsResponse{
+		Snapshots: []*vizierpb.DatastoreSnapshot{{Name: "metadata-20210304T101500.000000000Z.snap", CreatedAtNS: 1614852900000000000}},
+	}
+	fv := newFakeVizier(t, uuid.FromStringOrNil(clusterID), ts.nc)
+	fv.Run(t, []*cvmsgspb.V2CAPIStreamResponse{
+		{Msg: &cvmsgspb.V2CAPIStreamResponse_DebugListSnapshotsResp{DebugListSnapshotsResp: snapshots}},
+	})
+	defer fv.Stop()
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization",
+		fmt.Sprintf("bearer %s", validTestToken))
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+	resp, err := client.DebugListSnapshots(ctx, &vizierpb.DebugListSnapshotsRequest{ClusterID: clusterID})
+	require.NoError(t, err)
+
+	msg, err := resp.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, snapshots, msg)
+	_, err = resp.Recv()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestVizierPassThroughProxy_GetTracepoint(t *testing.T) {
+	viper.Set("jwt_signing_key", "the-key")
+
+	ts, cleanup := createTestState(t)
+	defer cleanup(t)
+
+	client := vizierpb.NewVizierTracepointServiceClient(ts.conn)
+	validTestToken := testingutils.GenerateTestJWTToken(t, viper.GetString("jwt_signing_key"))
+	clusterID := "00000000-1111-2222-2222-333333333333"
+
+	tracepoint := &vizierpb.GetTracepointResponse{
+		Tracepoint: &vizierpb.Tracepoint{
+			ID:    "tp1",
+			Name:  "http_probe",
+			State: vizierpb.FAILED_STATE,
+			AgentStatuses: []*vizierpb.AgentTracepointStatus{
+				{AgentID: "agent1", State: vizierpb.FAILED_STATE, Status: &vizierpb.Status{Code: int32(codes.Internal), Message: "could not attach probe"}},
+			},
+		},
+	}
+	fv := newFakeVizier(t, uuid.FromStringOrNil(clusterID), ts.nc)
+	fv.Run(t, []*cvmsgspb.V2CAPIStreamResponse{
+		{Msg: &cvmsgspb.V2CAPIStreamResponse_GetTracepointResp{GetTracepointResp: tracepoint}},
+	})
+	defer fv.Stop()
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization",
+		fmt.Sprintf("bearer %s", validTestToken))
+	ctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+	resp, err := client.GetTracepoint(ctx, &vizierpb.GetTracepointRequest{ClusterID: clusterID, Name: "http_probe"})
+	require.NoError(t, err)
+
+	msg, err := resp.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, tracepoint, msg)
+	_, err = resp.Recv()
+	assert.Equal(t, io.EOF, err)
+}
+
 type fakeVizier struct {
 	t    *testing.T
 	id   uuid.UUID
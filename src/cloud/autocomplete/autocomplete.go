@@ -72,13 +72,15 @@ var kindLabelToProtoMap = map[string]cloudpb.AutocompleteEntityKind{
 	"pod":    cloudpb.AEK_POD,
 	"script": cloudpb.AEK_SCRIPT,
 	"ns":     cloudpb.AEK_NAMESPACE,
+	"deploy": cloudpb.AEK_DEPLOYMENT,
 }
 
 var protoToKindLabelMap = map[cloudpb.AutocompleteEntityKind]string{
-	cloudpb.AEK_SVC:       "svc",
-	cloudpb.AEK_POD:       "pod",
-	cloudpb.AEK_SCRIPT:    "script",
-	cloudpb.AEK_NAMESPACE: "ns",
+	cloudpb.AEK_SVC:        "svc",
+	cloudpb.AEK_POD:        "pod",
+	cloudpb.AEK_SCRIPT:     "script",
+	cloudpb.AEK_NAMESPACE:  "ns",
+	cloudpb.AEK_DEPLOYMENT: "deploy",
 }
 
 // Autocomplete returns a formatted string and suggestions for the given input.
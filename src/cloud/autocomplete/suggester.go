@@ -44,17 +44,19 @@ type ElasticSuggester struct {
 }
 
 var protoToElasticLabelMap = map[cloudpb.AutocompleteEntityKind]string{
-	cloudpb.AEK_SVC:       "service",
-	cloudpb.AEK_POD:       "pod",
-	cloudpb.AEK_SCRIPT:    "script",
-	cloudpb.AEK_NAMESPACE: "script",
+	cloudpb.AEK_SVC:        "service",
+	cloudpb.AEK_POD:        "pod",
+	cloudpb.AEK_SCRIPT:     "script",
+	cloudpb.AEK_NAMESPACE:  "script",
+	cloudpb.AEK_DEPLOYMENT: "deployment",
 }
 
 var elasticLabelToProtoMap = map[string]cloudpb.AutocompleteEntityKind{
 	"service":   cloudpb.AEK_SVC,
 	"pod":       cloudpb.AEK_POD,
 	"script":    cloudpb.AEK_SCRIPT,
-	"namespace": cloudpb.AEK_NAMESPACE,
+	"namespace":  cloudpb.AEK_NAMESPACE,
+	"deployment": cloudpb.AEK_DEPLOYMENT,
 }
 
 var elasticStateToProtoMap = map[md.ESMDEntityState]cloudpb.AutocompleteEntityState{
@@ -21,6 +21,7 @@ package md
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/gofrs/uuid"
 	"github.com/nats-io/stan.go"
@@ -172,6 +173,23 @@ func (v *VizierIndexer) serviceUpdateToEMD(u *metadatapb.ResourceUpdate, service
 	}
 }
 
+func (v *VizierIndexer) workloadUpdateToEMD(u *metadatapb.ResourceUpdate, workloadUpdate *metadatapb.WorkloadUpdate) *EsMDEntity {
+	return &EsMDEntity{
+		OrgID:              v.orgID.String(),
+		VizierID:           v.vizierID.String(),
+		ClusterUID:         v.k8sUID,
+		UID:                workloadUpdate.UID,
+		Name:               workloadUpdate.Name,
+		NS:                 workloadUpdate.Namespace,
+		Kind:               strings.ToLower(workloadUpdate.Kind),
+		TimeStartedNS:      workloadUpdate.StartTimestampNS,
+		TimeStoppedNS:      workloadUpdate.StopTimestampNS,
+		RelatedEntityNames: []string{},
+		UpdateVersion:      u.UpdateVersion,
+		State:              getStateFromTimestamps(workloadUpdate.StopTimestampNS),
+	}
+}
+
 func (v *VizierIndexer) resourceUpdateToEMD(update *metadatapb.ResourceUpdate) *EsMDEntity {
 	switch update.Update.(type) {
 	case *metadatapb.ResourceUpdate_NamespaceUpdate:
@@ -180,6 +198,8 @@ func (v *VizierIndexer) resourceUpdateToEMD(update *metadatapb.ResourceUpdate) *
 		return v.podUpdateToEMD(update, update.GetPodUpdate())
 	case *metadatapb.ResourceUpdate_ServiceUpdate:
 		return v.serviceUpdateToEMD(update, update.GetServiceUpdate())
+	case *metadatapb.ResourceUpdate_WorkloadUpdate:
+		return v.workloadUpdateToEMD(update, update.GetWorkloadUpdate())
 	default:
 		// We don't care about any other update types.
 		// Notably containerUpdates and nodeUpdates.
@@ -137,6 +137,42 @@ func TestVizierIndexer_ResourceUpdate(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "workload update",
+			updates: []*metadatapb.ResourceUpdate{
+				{
+					Update: &metadatapb.ResourceUpdate_WorkloadUpdate{
+						WorkloadUpdate: &metadatapb.WorkloadUpdate{
+							UID:              "400",
+							Name:             "frontend",
+							Namespace:        "testns",
+							Kind:             "Deployment",
+							StartTimestampNS: 1000,
+							StopTimestampNS:  0,
+						},
+					},
+					UpdateVersion:     3,
+					PrevUpdateVersion: 2,
+				},
+			},
+			updateKind: "deployment",
+			expectedResults: []*md.EsMDEntity{
+				{
+					OrgID:              orgID.String(),
+					VizierID:           vzID.String(),
+					ClusterUID:         "test",
+					UID:                "400",
+					NS:                 "testns",
+					Name:               "frontend",
+					Kind:               "deployment",
+					TimeStartedNS:      int64(1000),
+					TimeStoppedNS:      int64(0),
+					RelatedEntityNames: []string{},
+					UpdateVersion:      3,
+					State:              md.ESMDEntityStateRunning,
+				},
+			},
+		},
 		{
 			name: "svc update",
 			updates: []*metadatapb.ResourceUpdate{
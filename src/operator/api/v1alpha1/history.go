@@ -0,0 +1,56 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MaxVersionHistory is the maximum number of records kept in the Vizier's version history.
+const MaxVersionHistory = 10
+
+// RecordVersion records the outcome of deploying the given version in the version history. Redeploying the most
+// recently recorded version updates its record, rather than adding a new one.
+func (s *VizierStatus) RecordVersion(version string, phase VizierPhase, deployedAt metav1.Time) {
+	record := VizierVersionRecord{
+		Version:     version,
+		VizierPhase: phase,
+		DeployedAt:  deployedAt,
+	}
+	if n := len(s.VersionHistory); n > 0 && s.VersionHistory[n-1].Version == version {
+		s.VersionHistory[n-1] = record
+		return
+	}
+	s.VersionHistory = append(s.VersionHistory, record)
+	if len(s.VersionHistory) > MaxVersionHistory {
+		s.VersionHistory = s.VersionHistory[len(s.VersionHistory)-MaxVersionHistory:]
+	}
+}
+
+// PreviousVersion returns the most recently deployed version, other than the current version, which reached the
+// running phase. Returns an empty string if there is no such version.
+func (s *VizierStatus) PreviousVersion() string {
+	for i := len(s.VersionHistory) - 1; i >= 0; i-- {
+		r := s.VersionHistory[i]
+		if r.Version != s.Version && r.VizierPhase == VizierPhaseRunning {
+			return r.Version
+		}
+	}
+	return ""
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package v1alpha1_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"px.dev/pixie/src/operator/api/v1alpha1"
+)
+
+func TestVizierStatus_RecordVersion(t *testing.T) {
+	s := &v1alpha1.VizierStatus{}
+	t1 := metav1.NewTime(time.Unix(100, 0))
+	t2 := metav1.NewTime(time.Unix(200, 0))
+
+	s.RecordVersion("0.7.0", v1alpha1.VizierPhaseRunning, t1)
+	s.RecordVersion("0.7.1", v1alpha1.VizierPhaseFailed, t1)
+	// Redeploying the same version replaces the last record.
+	s.RecordVersion("0.7.1", v1alpha1.VizierPhaseRunning, t2)
+
+	assert.Equal(t, []v1alpha1.VizierVersionRecord{
+		{Version: "0.7.0", VizierPhase: v1alpha1.VizierPhaseRunning, DeployedAt: t1},
+		{Version: "0.7.1", VizierPhase: v1alpha1.VizierPhaseRunning, DeployedAt: t2},
+	}, s.VersionHistory)
+}
+
+func TestVizierStatus_RecordVersionTruncates(t *testing.T) {
+	s := &v1alpha1.VizierStatus{}
+	for i := 0; i < v1alpha1.MaxVersionHistory+5; i++ {
+		s.RecordVersion(fmt.Sprintf("0.7.%d", i), v1alpha1.VizierPhaseRunning, metav1.Now())
+	}
+	assert.Equal(t, v1alpha1.MaxVersionHistory, len(s.VersionHistory))
+	assert.Equal(t, "0.7.5", s.VersionHistory[0].Version)
+	assert.Equal(t, "0.7.14", s.VersionHistory[len(s.VersionHistory)-1].Version)
+}
+
+func TestVizierStatus_PreviousVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   v1alpha1.VizierStatus
+		expected string
+	}{
+		{
+			name:     "no history",
+			status:   v1alpha1.VizierStatus{Version: "0.7.1"},
+			expected: "",
+		},
+		{
+			name: "previous running version",
+			status: v1alpha1.VizierStatus{
+				Version: "0.7.2",
+				VersionHistory: []v1alpha1.VizierVersionRecord{
+					{Version: "0.7.0", VizierPhase: v1alpha1.VizierPhaseRunning},
+					{Version: "0.7.1", VizierPhase: v1alpha1.VizierPhaseRunning},
+					{Version: "0.7.2", VizierPhase: v1alpha1.VizierPhaseFailed},
+				},
+			},
+			expected: "0.7.1",
+		},
+		{
+			name: "skips failed versions",
+			status: v1alpha1.VizierStatus{
+				Version: "0.7.2",
+				VersionHistory: []v1alpha1.VizierVersionRecord{
+					{Version: "0.7.0", VizierPhase: v1alpha1.VizierPhaseRunning},
+					{Version: "0.7.1", VizierPhase: v1alpha1.VizierPhaseFailed},
+					{Version: "0.7.2", VizierPhase: v1alpha1.VizierPhaseRunning},
+				},
+			},
+			expected: "0.7.0",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, test.status.PreviousVersion())
+		})
+	}
+}
@@ -62,6 +62,18 @@ type VizierStatus struct {
 	VizierPhase VizierPhase `json:"vizierPhase,omitempty"`
 	// Message is a human-readable message with details about why the Vizier is in this condition.
 	Message string `json:"message,omitempty"`
+	// VersionHistory records the versions of Vizier deployed by the operator, oldest first.
+	VersionHistory []VizierVersionRecord `json:"versionHistory,omitempty"`
+}
+
+// VizierVersionRecord records the outcome of deploying a version of Vizier.
+type VizierVersionRecord struct {
+	// Version is the version of Vizier that was deployed.
+	Version string `json:"version"`
+	// VizierPhase is the phase the Vizier reached after the version was deployed.
+	VizierPhase VizierPhase `json:"vizierPhase,omitempty"`
+	// DeployedAt is the time at which the deployment of the version completed.
+	DeployedAt metav1.Time `json:"deployedAt,omitempty"`
 }
 
 // VizierPhase is a high-level summary of where the Vizier is in its lifecycle.
@@ -44,7 +44,7 @@ func (in *Vizier) DeepCopyInto(out *Vizier) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Vizier.
@@ -120,6 +120,13 @@ func (in *VizierSpec) DeepCopy() *VizierSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VizierStatus) DeepCopyInto(out *VizierStatus) {
 	*out = *in
+	if in.VersionHistory != nil {
+		in, out := &in.VersionHistory, &out.VersionHistory
+		*out = make([]VizierVersionRecord, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VizierStatus.
@@ -131,3 +138,19 @@ func (in *VizierStatus) DeepCopy() *VizierStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VizierVersionRecord) DeepCopyInto(out *VizierVersionRecord) {
+	*out = *in
+	in.DeployedAt.DeepCopyInto(&out.DeployedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VizierVersionRecord.
+func (in *VizierVersionRecord) DeepCopy() *VizierVersionRecord {
+	if in == nil {
+		return nil
+	}
+	out := new(VizierVersionRecord)
+	in.DeepCopyInto(out)
+	return out
+}
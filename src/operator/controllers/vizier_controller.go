@@ -281,6 +281,8 @@ func (r *VizierReconciler) deployVizier(ctx context.Context, req ctrl.Request, v
 	} else {
 		vz.Status.VizierPhase = pixiev1alpha1.VizierPhaseRunning
 	}
+	// Keep a record of the deployed versions, so that a failed update can be rolled back.
+	vz.Status.RecordVersion(vz.Spec.Version, vz.Status.VizierPhase, metav1.Now())
 
 	err = r.Status().Update(ctx, vz)
 	if err != nil {
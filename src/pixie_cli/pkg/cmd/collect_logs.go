@@ -19,13 +19,19 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"time"
 
+	"github.com/gofrs/uuid"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"px.dev/pixie/src/pixie_cli/pkg/auth"
+	"px.dev/pixie/src/pixie_cli/pkg/diagnose"
+	"px.dev/pixie/src/pixie_cli/pkg/script"
 	"px.dev/pixie/src/pixie_cli/pkg/utils"
 	"px.dev/pixie/src/pixie_cli/pkg/vizier"
 	"px.dev/pixie/src/utils/shared/k8s"
@@ -34,9 +40,66 @@ import (
 func init() {
 	CollectLogsCmd.Flags().StringP("namespace", "n", "", "The namespace vizier is deployed in")
 	viper.BindPFlag("namespace", CollectLogsCmd.Flags().Lookup("namespace"))
+	CollectLogsCmd.Flags().StringP("cluster", "c", "", "ID of the cluster to collect Vizier state from")
+	registerClusterCompletion(CollectLogsCmd)
 }
 
-// CollectLogsCmd is the "deploy" command.
+// vizierStateScripts are run against the Vizier to capture its state in the log bundle.
+var vizierStateScripts = map[string]string{
+	diagnose.AgentStatusFile: `import px
+px.display(px.GetAgentStatus(), 'agents')
+`,
+	diagnose.TableSchemasFile: `import px
+px.display(px.GetSchemas(), 'schemas')
+`,
+	diagnose.TableInfoFile: `import px
+px.display(px.GetDebugTableInfo(), 'table_info')
+`,
+}
+
+func vizierStateCollector(conn *vizier.Connector, name string, pxl string) k8s.CollectorFunc {
+	return func(w io.Writer) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		s := &script.ExecutableScript{
+			ScriptName:   name,
+			ScriptString: pxl,
+		}
+		return vizier.RunScriptAndWriteJSON(ctx, []*vizier.Connector{conn}, s, w)
+	}
+}
+
+// addVizierStateCollectors adds collectors for the agent and table state known by the Vizier.
+// This is best effort, since collect-logs is often run against an unhealthy Vizier.
+func addVizierStateCollectors(c *k8s.LogCollector, selectedCluster string) {
+	if _, err := auth.LoadDefaultCredentials(); err != nil {
+		utils.Info("Not logged in, skipping collection of Vizier state")
+		return
+	}
+
+	cloudAddr := viper.GetString("cloud_addr")
+	clusterID := uuid.FromStringOrNil(selectedCluster)
+	if clusterID == uuid.Nil {
+		var err error
+		clusterID, err = getVizier(cloudAddr)
+		if err != nil {
+			utils.WithError(err).Error("Could not find Vizier, skipping collection of Vizier state")
+			return
+		}
+	}
+
+	conn, err := vizier.ConnectionToVizierByID(cloudAddr, clusterID)
+	if err != nil {
+		utils.WithError(err).Error("Could not connect to Vizier, skipping collection of Vizier state")
+		return
+	}
+
+	for fName, pxl := range vizierStateScripts {
+		c.AddCollector(fName, vizierStateCollector(conn, fName, pxl))
+	}
+}
+
+// CollectLogsCmd is the "collect-logs" command.
 var CollectLogsCmd = &cobra.Command{
 	Use:   "collect-logs",
 	Short: "Collect pixie logs on the cluster",
@@ -47,6 +110,9 @@ var CollectLogsCmd = &cobra.Command{
 		}
 
 		c := k8s.NewLogCollector(ns)
+		selectedCluster, _ := cmd.Flags().GetString("cluster")
+		addVizierStateCollectors(c, selectedCluster)
+
 		fName := fmt.Sprintf("pixie_logs_%s.zip", time.Now().Format("20060102150405"))
 		err := c.CollectPixieLogs(fName)
 		if err != nil {
@@ -0,0 +1,458 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"px.dev/pixie/src/api/proto/cloudpb"
+	"px.dev/pixie/src/api/proto/vispb"
+	"px.dev/pixie/src/pixie_cli/pkg/auth"
+	"px.dev/pixie/src/pixie_cli/pkg/completion"
+	"px.dev/pixie/src/pixie_cli/pkg/script"
+	"px.dev/pixie/src/pixie_cli/pkg/utils"
+	"px.dev/pixie/src/pixie_cli/pkg/vizier"
+	utils2 "px.dev/pixie/src/utils"
+	"px.dev/pixie/src/utils/shared/k8s"
+)
+
+const (
+	scriptCompletionTTL  = time.Hour
+	clusterCompletionTTL = 5 * time.Minute
+	entityCompletionTTL  = time.Minute
+	// entityCompletionTimeout bounds the call to the cloud autocomplete service, so a slow
+	// cloud doesn't hang the shell.
+	entityCompletionTimeout = 2 * time.Second
+)
+
+// pxTypeToEntityKind maps the script argument types to the entity kinds known by the autocomplete service.
+var pxTypeToEntityKind = map[vispb.PXType]cloudpb.AutocompleteEntityKind{
+	vispb.PX_POD:       cloudpb.AEK_POD,
+	vispb.PX_SERVICE:   cloudpb.AEK_SVC,
+	vispb.PX_NAMESPACE: cloudpb.AEK_NAMESPACE,
+}
+
+func init() {
+	CompletionCmd.AddCommand(completionBashCmd)
+	CompletionCmd.AddCommand(completionZshCmd)
+	CompletionCmd.AddCommand(completionFishCmd)
+
+	completionFishCmd.Flags().Bool("no-descriptions", false, "Disable completion descriptions")
+}
+
+// CompletionCmd is the "completion" command.
+var CompletionCmd = &cobra.Command{
+	Use:   "completion",
+	Short: "Generate shell completion scripts",
+	Long: `Generate shell completion scripts for px.
+
+Completes commands, flags, script names, script arguments and cluster IDs.`,
+}
+
+var completionBashCmd = &cobra.Command{
+	Use:   "bash",
+	Short: "Generate the bash completion script",
+	Long: `Generate the bash completion script. Requires the bash-completion package.
+
+To load completions in the current shell:
+
+  source <(px completion bash)
+
+To load completions for every new session, execute once:
+
+  px completion bash > /etc/bash_completion.d/px`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := RootCmd.GenBashCompletionV2(os.Stdout, true); err != nil {
+			utils.WithError(err).Fatal("Failed to generate completion script")
+		}
+	},
+}
+
+var completionZshCmd = &cobra.Command{
+	Use:   "zsh",
+	Short: "Generate the zsh completion script",
+	Long: `Generate the zsh completion script.
+
+To load completions for every new session, execute once:
+
+  px completion zsh > "${fpath[1]}/_px"`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := RootCmd.GenZshCompletion(os.Stdout); err != nil {
+			utils.WithError(err).Fatal("Failed to generate completion script")
+		}
+	},
+}
+
+var completionFishCmd = &cobra.Command{
+	Use:   "fish",
+	Short: "Generate the fish completion script",
+	Long: `Generate the fish completion script.
+
+To load completions for every new session, execute once:
+
+  px completion fish > ~/.config/fish/completions/px.fish`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		noDesc, _ := cmd.Flags().GetBool("no-descriptions")
+		if err := RootCmd.GenFishCompletion(os.Stdout, !noDesc); err != nil {
+			utils.WithError(err).Fatal("Failed to generate completion script")
+		}
+	},
+}
+
+// isCompletionRequest returns whether the command is cobra's hidden command which serves completion requests.
+func isCompletionRequest(cmd *cobra.Command) bool {
+	return cmd.Name() == cobra.ShellCompRequestCmd || cmd.Name() == cobra.ShellCompNoDescRequestCmd
+}
+
+// completionScriptArg is an argument of a bundle script, as stored in the completion cache.
+type completionScriptArg struct {
+	Name  string `json:"name"`
+	Usage string `json:"usage"`
+	Type  string `json:"type"`
+}
+
+// completionScript is a bundle script, as stored in the completion cache.
+type completionScript struct {
+	Name     string                `json:"name"`
+	ShortDoc string                `json:"shortDoc"`
+	Args     []completionScriptArg `json:"args"`
+}
+
+func completionCache() (*completion.Cache, error) {
+	dir, err := completion.DefaultCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return completion.NewCache(dir), nil
+}
+
+// hasCredentials returns whether the user is logged in. Completions which require the cloud are skipped
+// otherwise, since the auth helpers exit the process when credentials are missing.
+func hasCredentials() bool {
+	_, err := auth.LoadDefaultCredentials()
+	return err == nil
+}
+
+func getCompletionScripts(cmd *cobra.Command) ([]completionScript, error) {
+	c, err := completionCache()
+	if err != nil {
+		return nil, err
+	}
+	bundleFile := viper.GetString("bundle")
+	if f := cmd.Flags().Lookup("bundle"); f != nil && f.Changed {
+		bundleFile = f.Value.String()
+	}
+	if bundleFile == "" {
+		bundleFile = defaultBundleFile
+	}
+	// Unlike createBundleReader, don't require the user to be logged in to complete the public scripts.
+	var orgID, orgName string
+	if creds, err := auth.LoadDefaultCredentials(); err == nil {
+		orgID, orgName = creds.OrgID, creds.OrgName
+	}
+
+	var scripts []completionScript
+	key := fmt.Sprintf("scripts:%s:%s", orgID, bundleFile)
+	err = c.Get(key, scriptCompletionTTL, &scripts, func() (interface{}, error) {
+		br, err := script.NewBundleManagerWithOrg([]string{bundleFile, ossBundleFile}, orgID, orgName)
+		if err != nil {
+			return nil, err
+		}
+		var res []completionScript
+		for _, s := range br.GetScripts() {
+			if s.Hidden {
+				continue
+			}
+			cs := completionScript{Name: s.ScriptName, ShortDoc: s.ShortDoc}
+			fs := s.GetFlagSet()
+			if fs != nil {
+				argTypes := make(map[string]vispb.PXType)
+				for _, v := range s.Vis.Variables {
+					argTypes[v.Name] = v.Type
+				}
+				fs.VisitAll(func(f *flag.Flag) {
+					cs.Args = append(cs.Args, completionScriptArg{
+						Name:  f.Name,
+						Usage: f.Usage,
+						Type:  argTypes[f.Name].String(),
+					})
+				})
+			}
+			res = append(res, cs)
+		}
+		// Bundles which fail to load are skipped by the bundle manager, so don't cache an empty result.
+		if len(res) == 0 {
+			return nil, errors.New("no scripts found in bundle")
+		}
+		return res, nil
+	})
+	return scripts, err
+}
+
+func getCompletionClusters() ([]*cloudpb.ClusterInfo, error) {
+	if !hasCredentials() {
+		return nil, nil
+	}
+	c, err := completionCache()
+	if err != nil {
+		return nil, err
+	}
+	cloudAddr := viper.GetString("cloud_addr")
+
+	var clusters []*cloudpb.ClusterInfo
+	err = c.Get("clusters:"+cloudAddr, clusterCompletionTTL, &clusters, func() (interface{}, error) {
+		l, err := vizier.NewLister(cloudAddr)
+		if err != nil {
+			return nil, err
+		}
+		return l.GetViziersInfo()
+	})
+	return clusters, err
+}
+
+// completeCluster completes the values of the --cluster flag.
+func completeCluster(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	clusters, err := getCompletionClusters()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	var completions []string
+	for _, c := range clusters {
+		id := utils2.ProtoToUUIDStr(c.ID)
+		if !strings.HasPrefix(id, toComplete) {
+			continue
+		}
+		completions = append(completions, fmt.Sprintf("%s\t%s (%s)", id, c.PrettyClusterName,
+			strings.TrimPrefix(c.Status.String(), "CS_")))
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completionClusterID returns the cluster that entity completions are fetched from: the --cluster flag if set,
+// followed by the Vizier in the current kubeconfig and finally the first healthy Vizier.
+func completionClusterID(cmd *cobra.Command, clusters []*cloudpb.ClusterInfo) uuid.UUID {
+	if f := cmd.Flags().Lookup("cluster"); f != nil {
+		if id := uuid.FromStringOrNil(f.Value.String()); id != uuid.Nil {
+			return id
+		}
+	}
+	if config, err := k8s.TryGetConfig(); err == nil {
+		if id := vizier.GetClusterIDFromKubeConfig(config); id != uuid.Nil {
+			return id
+		}
+	}
+	for _, c := range clusters {
+		if c.Status == cloudpb.CS_HEALTHY {
+			return utils2.UUIDFromProtoOrNil(c.ID)
+		}
+	}
+	return uuid.Nil
+}
+
+// completeEntity completes the value of a script argument using the cloud autocomplete service.
+func completeEntity(cmd *cobra.Command, kind cloudpb.AutocompleteEntityKind, toComplete string) []string {
+	clusters, err := getCompletionClusters()
+	if err != nil || len(clusters) == 0 {
+		return nil
+	}
+	clusterID := completionClusterID(cmd, clusters)
+	if clusterID == uuid.Nil {
+		return nil
+	}
+	c, err := completionCache()
+	if err != nil {
+		return nil
+	}
+	cloudAddr := viper.GetString("cloud_addr")
+
+	var suggestions []*cloudpb.AutocompleteSuggestion
+	key := fmt.Sprintf("entities:%s:%s:%s:%s", cloudAddr, clusterID, kind, toComplete)
+	err = c.Get(key, entityCompletionTTL, &suggestions, func() (interface{}, error) {
+		cloudConn, err := utils.GetCloudClientConnection(cloudAddr)
+		if err != nil {
+			return nil, err
+		}
+		defer cloudConn.Close()
+		ctx, cancel := context.WithTimeout(auth.CtxWithCreds(context.Background()), entityCompletionTimeout)
+		defer cancel()
+
+		resp, err := cloudpb.NewAutocompleteServiceClient(cloudConn).AutocompleteField(ctx, &cloudpb.AutocompleteFieldRequest{
+			Input:      toComplete,
+			FieldType:  kind,
+			ClusterUID: clusterID.String(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return resp.Suggestions, nil
+	})
+	if err != nil {
+		return nil
+	}
+
+	var completions []string
+	for _, s := range suggestions {
+		if s.Description != "" {
+			completions = append(completions, fmt.Sprintf("%s\t%s", s.Name, s.Description))
+		} else {
+			completions = append(completions, s.Name)
+		}
+	}
+	return completions
+}
+
+// completeScriptArgs completes the script arguments which follow "--".
+func completeScriptArgs(cmd *cobra.Command, s *completionScript, scriptArgs []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	findArg := func(name string) *completionScriptArg {
+		for i := range s.Args {
+			if s.Args[i].Name == name {
+				return &s.Args[i]
+			}
+		}
+		return nil
+	}
+	completeValue := func(arg *completionScriptArg, prefix, value string) ([]string, cobra.ShellCompDirective) {
+		kind, ok := pxTypeToEntityKind[vispb.PXType(vispb.PXType_value[arg.Type])]
+		if !ok {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		var completions []string
+		for _, c := range completeEntity(cmd, kind, value) {
+			completions = append(completions, prefix+c)
+		}
+		return completions, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	// The value of a flag passed as "--name value".
+	if len(scriptArgs) > 0 {
+		prev := scriptArgs[len(scriptArgs)-1]
+		if strings.HasPrefix(prev, "-") && !strings.Contains(prev, "=") {
+			if arg := findArg(strings.TrimLeft(prev, "-")); arg != nil {
+				return completeValue(arg, "", toComplete)
+			}
+		}
+	}
+
+	if toComplete != "" && !strings.HasPrefix(toComplete, "-") {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	name := strings.TrimLeft(toComplete, "-")
+	// The value of a flag passed as "--name=value".
+	if splits := strings.SplitN(name, "=", 2); len(splits) == 2 {
+		if arg := findArg(splits[0]); arg != nil {
+			return completeValue(arg, "--"+splits[0]+"=", splits[1])
+		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var completions []string
+	for _, arg := range s.Args {
+		if strings.HasPrefix(arg.Name, name) {
+			completions = append(completions, fmt.Sprintf("--%s\t%s", arg.Name, arg.Usage))
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completionArgsLenAtDash returns the number of args before "--", or -1 if there is none. Cobra parses
+// the flags of a completion request with an extra "--" appended, so cmd.ArgsLenAtDash() returns len(args)
+// rather than -1 when no "--" was typed.
+func completionArgsLenAtDash(cmd *cobra.Command, args []string) int {
+	dash := cmd.ArgsLenAtDash()
+	if dash != len(args) || len(os.Args) < 2 {
+		return dash
+	}
+	// The last arg is the word being completed.
+	for _, arg := range os.Args[1 : len(os.Args)-1] {
+		if arg == "--" {
+			return dash
+		}
+	}
+	return -1
+}
+
+// completeScript completes the script name and the script arguments of the run and live commands.
+func completeScript(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if f, _ := cmd.Flags().GetString("file"); f != "" {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+
+	scripts, err := getCompletionScripts(cmd)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	dash := completionArgsLenAtDash(cmd, args)
+	if len(args) == 0 {
+		if dash == 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		var completions []string
+		for _, s := range scripts {
+			if strings.HasPrefix(s.Name, toComplete) {
+				completions = append(completions, fmt.Sprintf("%s\t%s", s.Name, s.ShortDoc))
+			}
+		}
+		return completions, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	// Script arguments must follow "--", otherwise they are parsed as px flags.
+	if dash < 1 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	for i := range scripts {
+		if scripts[i].Name == args[0] {
+			return completeScriptArgs(cmd, &scripts[i], args[dash:], toComplete)
+		}
+	}
+	return nil, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completePlugin completes the names of the installed plugins.
+func completePlugin(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var completions []string
+	for _, p := range FindPlugins() {
+		if strings.HasPrefix(p.Name, toComplete) && !isBuiltinCommand(p.Name) {
+			completions = append(completions, fmt.Sprintf("%s\tPlugin %s", p.Name, p.Path))
+		}
+	}
+	return completions, cobra.ShellCompDirectiveNoFileComp
+}
+
+func registerClusterCompletion(cmd *cobra.Command) {
+	if err := cmd.RegisterFlagCompletionFunc("cluster", completeCluster); err != nil {
+		panic(err)
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/dustin/go-humanize"
 	"github.com/fatih/color"
 	"github.com/gofrs/uuid"
 	"github.com/spf13/cobra"
@@ -40,7 +41,11 @@ func init() {
 	DebugCmd.AddCommand(DebugLogCmd)
 	DebugCmd.AddCommand(DebugPodsCmd)
 	DebugCmd.AddCommand(DebugContainersCmd)
+	DebugCmd.AddCommand(DebugSnapshotCmd)
+	DebugSnapshotCmd.AddCommand(DebugSnapshotCreateCmd)
+	DebugSnapshotCmd.AddCommand(DebugSnapshotListCmd)
 	DebugCmd.PersistentFlags().StringP("cluster", "c", "", "Run only on selected cluster")
+	registerClusterCompletion(DebugCmd)
 
 	DebugLogCmd.Flags().BoolP("previous", "p", false, "Show log from previous pod instead.")
 	DebugLogCmd.Flags().StringP("container", "n", "", "The container to get logs from.")
@@ -209,3 +214,71 @@ var DebugContainersCmd = &cobra.Command{
 		}
 	},
 }
+
+func connectToDebugVizier(cmd *cobra.Command) *vizier.Connector {
+	cloudAddr := viper.GetString("cloud_addr")
+	selectedCluster, _ := cmd.Flags().GetString("cluster")
+	clusterID := uuid.FromStringOrNil(selectedCluster)
+	if clusterID == uuid.Nil {
+		var err error
+		clusterID, err = getVizier(cloudAddr)
+		if err != nil {
+			utils.WithError(err).Fatal("Could not fetch healthy vizier")
+		}
+	}
+
+	fmt.Printf("Cluster ID : %s\n", clusterID.String())
+
+	conn, err := vizier.ConnectionToVizierByID(cloudAddr, clusterID)
+	if err != nil {
+		utils.WithError(err).Fatal("Could not connect to vizier")
+	}
+	return conn
+}
+
+// DebugSnapshotCmd is the command to manage snapshots of the metadata datastore.
+var DebugSnapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "Manage snapshots of the vizier metadata datastore",
+}
+
+// DebugSnapshotCreateCmd snapshots the metadata datastore.
+var DebugSnapshotCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Snapshot the vizier metadata datastore",
+	Run: func(cmd *cobra.Command, args []string) {
+		conn := connectToDebugVizier(cmd)
+		ctx, cleanup := utils.WithSignalCancellable(context.Background())
+		defer cleanup()
+		snapshot, err := conn.CreateSnapshot(ctx)
+		if err != nil {
+			utils.WithError(err).Fatal("Could not create snapshot")
+		}
+		w := components.CreateStreamWriter("table", os.Stdout)
+		defer w.Finish()
+		w.SetHeader("snapshots", []string{"Name", "Created", "Keys", "Size"})
+		_ = w.Write([]interface{}{snapshot.Name, time.Unix(0, snapshot.CreatedAtNS), snapshot.NumKeys,
+			humanize.Bytes(uint64(snapshot.SizeBytes))})
+	},
+}
+
+// DebugSnapshotListCmd lists the snapshots of the metadata datastore.
+var DebugSnapshotListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List snapshots of the vizier metadata datastore",
+	Run: func(cmd *cobra.Command, args []string) {
+		conn := connectToDebugVizier(cmd)
+		ctx, cleanup := utils.WithSignalCancellable(context.Background())
+		defer cleanup()
+		snapshots, err := conn.ListSnapshots(ctx)
+		if err != nil {
+			utils.WithError(err).Fatal("Could not list snapshots")
+		}
+		w := components.CreateStreamWriter("table", os.Stdout)
+		defer w.Finish()
+		w.SetHeader("snapshots", []string{"Name", "Created"})
+		for _, snapshot := range snapshots {
+			_ = w.Write([]interface{}{snapshot.Name, time.Unix(0, snapshot.CreatedAtNS)})
+		}
+	},
+}
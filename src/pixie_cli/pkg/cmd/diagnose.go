@@ -0,0 +1,63 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"px.dev/pixie/src/pixie_cli/pkg/components"
+	"px.dev/pixie/src/pixie_cli/pkg/diagnose"
+	"px.dev/pixie/src/pixie_cli/pkg/utils"
+)
+
+func init() {
+	DiagnoseCmd.Flags().StringP("output", "o", "", "Output format: one of: json|table|csv")
+}
+
+// DiagnoseCmd is the "diagnose" command.
+var DiagnoseCmd = &cobra.Command{
+	Use:   "diagnose <bundle.zip>",
+	Short: "Analyze a log bundle created by collect-logs for likely root causes",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		format, _ := cmd.Flags().GetString("output")
+		format = strings.ToLower(format)
+
+		b, err := diagnose.OpenBundle(args[0])
+		if err != nil {
+			utils.WithError(err).Fatal("Failed to read log bundle")
+		}
+
+		findings := diagnose.Diagnose(b, diagnose.DefaultRules)
+		if len(findings) == 0 {
+			utils.Info("No issues found in the log bundle")
+			return
+		}
+
+		w := components.CreateStreamWriter(format, os.Stdout)
+		defer w.Finish()
+		w.SetHeader("findings", []string{"Severity", "Rule", "Summary", "Details"})
+		for _, f := range findings {
+			_ = w.Write([]interface{}{f.Severity.String(), f.Rule, f.Summary, strings.Join(f.Details, "\n")})
+		}
+	},
+}
@@ -46,6 +46,7 @@ func init() {
 	GetPEMsCmd.Flags().BoolP("all-clusters", "d", false, "Run script across all clusters")
 	GetPEMsCmd.Flags().StringP("cluster", "c", "", "Run only on selected cluster")
 	GetPEMsCmd.Flags().MarkHidden("all-clusters")
+	registerClusterCompletion(GetPEMsCmd)
 
 	GetCmd.AddCommand(GetPEMsCmd)
 	GetCmd.AddCommand(GetViziersCmd)
@@ -42,12 +42,14 @@ func init() {
 	LiveCmd.Flags().BoolP("all-clusters", "d", false, "Run script across all clusters")
 	LiveCmd.Flags().StringP("cluster", "c", "", "Run only on selected cluster")
 	LiveCmd.Flags().MarkHidden("all-clusters")
+	registerClusterCompletion(LiveCmd)
 }
 
 // LiveCmd is the "query" command.
 var LiveCmd = &cobra.Command{
-	Use:   "live",
-	Short: "Interactive Pixie Views",
+	Use:               "live",
+	Short:             "Interactive Pixie Views",
+	ValidArgsFunction: completeScript,
 	Run: func(cmd *cobra.Command, args []string) {
 		cloudAddr := viper.GetString("cloud_addr")
 
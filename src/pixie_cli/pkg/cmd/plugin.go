@@ -0,0 +1,225 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/gofrs/uuid"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"gopkg.in/segmentio/analytics-go.v3"
+
+	"px.dev/pixie/src/pixie_cli/pkg/auth"
+	"px.dev/pixie/src/pixie_cli/pkg/pxanalytics"
+	"px.dev/pixie/src/pixie_cli/pkg/pxconfig"
+	"px.dev/pixie/src/pixie_cli/pkg/vizier"
+	"px.dev/pixie/src/utils/shared/k8s"
+)
+
+// pluginPrefix is the prefix of executables on the PATH that are treated as px plugins.
+const pluginPrefix = "px-"
+
+// Environment variables that are passed to plugins.
+const (
+	pluginEnvCloudAddr = "PX_CLOUD_ADDR"
+	pluginEnvAuthToken = "PX_AUTH_TOKEN"
+	pluginEnvClusterID = "PX_CLUSTER_ID"
+	pluginEnvOutput    = "PX_OUTPUT_FORMAT"
+)
+
+// errPluginNotFound is returned when no plugin exists for a command.
+var errPluginNotFound = errors.New("plugin not found")
+
+// Plugin is an executable on the PATH which can be invoked as a px subcommand.
+type Plugin struct {
+	// Name is the name of the subcommand, ie. "foo" for "px-foo".
+	Name string
+	// Path is the path to the plugin executable.
+	Path string
+}
+
+func isExecutable(info os.FileInfo) bool {
+	if info.IsDir() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return strings.HasSuffix(strings.ToLower(info.Name()), ".exe")
+	}
+	return info.Mode()&0111 != 0
+}
+
+func pluginName(fName string) string {
+	name := strings.TrimPrefix(fName, pluginPrefix)
+	if runtime.GOOS == "windows" {
+		name = strings.TrimSuffix(strings.ToLower(name), ".exe")
+	}
+	return name
+}
+
+// FindPlugins returns the plugins on the PATH, sorted by name. If a plugin exists in multiple
+// directories, the one that appears first on the PATH is used.
+func FindPlugins() []*Plugin {
+	seen := make(map[string]bool)
+	var plugins []*Plugin
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			continue
+		}
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			if !strings.HasPrefix(f.Name(), pluginPrefix) || !isExecutable(f) {
+				continue
+			}
+			name := pluginName(f.Name())
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			plugins = append(plugins, &Plugin{Name: name, Path: filepath.Join(dir, f.Name())})
+		}
+	}
+	sort.Slice(plugins, func(i, j int) bool {
+		return plugins[i].Name < plugins[j].Name
+	})
+	return plugins
+}
+
+// isBuiltinCommand returns whether the name is a builtin px command, which plugins cannot override.
+func isBuiltinCommand(name string) bool {
+	for _, c := range RootCmd.Commands() {
+		if c.Name() == name || c.HasAlias(name) {
+			return true
+		}
+	}
+	return name == "help" || name == cobra.ShellCompRequestCmd || name == cobra.ShellCompNoDescRequestCmd
+}
+
+// parsePluginFlags extracts the well-known px flags from the plugin args, without consuming them.
+func parsePluginFlags(args []string) (cluster string, output string) {
+	fs := pflag.NewFlagSet("plugin", pflag.ContinueOnError)
+	fs.ParseErrorsWhitelist.UnknownFlags = true
+	fs.SetOutput(ioutil.Discard)
+	fs.StringVarP(&cluster, "cluster", "c", "", "")
+	fs.StringVarP(&output, "output", "o", "", "")
+	_ = fs.Parse(args)
+	return cluster, output
+}
+
+// pluginEnv returns the environment for the plugin with the px state added to it.
+func pluginEnv(args []string) []string {
+	env := os.Environ()
+
+	env = append(env, fmt.Sprintf("%s=%s", pluginEnvCloudAddr, cloudAddrWithPort(viper.GetString("cloud_addr"))))
+
+	if creds, err := auth.LoadDefaultCredentials(); err == nil {
+		env = append(env, fmt.Sprintf("%s=%s", pluginEnvAuthToken, creds.Token))
+	}
+
+	cluster, output := parsePluginFlags(args)
+	clusterID := uuid.FromStringOrNil(cluster)
+	if clusterID == uuid.Nil {
+		// Default to the Vizier in the current kube context, if there is one.
+		if config, err := k8s.TryGetConfig(); err == nil {
+			clusterID = vizier.GetClusterIDFromKubeConfig(config)
+		}
+	}
+	if clusterID != uuid.Nil {
+		env = append(env, fmt.Sprintf("%s=%s", pluginEnvClusterID, clusterID.String()))
+	}
+
+	if output == "" {
+		output = "table"
+	}
+	env = append(env, fmt.Sprintf("%s=%s", pluginEnvOutput, strings.ToLower(output)))
+	return env
+}
+
+// runPlugin runs the plugin for the given args, if one exists. The exit code of the plugin is returned.
+func runPlugin(args []string) (int, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") || isBuiltinCommand(args[0]) {
+		return 0, errPluginNotFound
+	}
+
+	path, err := exec.LookPath(pluginPrefix + args[0])
+	if err != nil {
+		return 0, errPluginNotFound
+	}
+
+	_ = pxanalytics.Client().Enqueue(&analytics.Track{
+		UserId: pxconfig.Cfg().UniqueClientID,
+		Event:  "Exec Plugin",
+		Properties: analytics.NewProperties().
+			Set("plugin", args[0]),
+	})
+
+	c := exec.Command(path, args[1:]...)
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	c.Env = pluginEnv(args[1:])
+
+	err = c.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+func printPlugins(w io.Writer) {
+	plugins := FindPlugins()
+	if len(plugins) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nInstalled Plugins:\n")
+	for _, p := range plugins {
+		if isBuiltinCommand(p.Name) {
+			fmt.Fprintf(w, "  %-20s %s (overridden by builtin command)\n", p.Name, p.Path)
+			continue
+		}
+		fmt.Fprintf(w, "  %-20s %s\n", p.Name, p.Path)
+	}
+}
+
+func init() {
+	defaultHelpFunc := RootCmd.HelpFunc()
+	RootCmd.SetHelpFunc(func(cmd *cobra.Command, args []string) {
+		defaultHelpFunc(cmd, args)
+		if cmd == RootCmd {
+			printPlugins(cmd.OutOrStdout())
+		}
+	})
+}
@@ -19,13 +19,20 @@
 package cmd
 
 import (
+	"net"
 	"os"
 	"os/signal"
 
+	"github.com/gofrs/uuid"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/pixie_cli/pkg/auth"
+	"px.dev/pixie/src/pixie_cli/pkg/gateway"
 	"px.dev/pixie/src/pixie_cli/pkg/utils"
 	"px.dev/pixie/src/pixie_cli/pkg/vizier"
 	"px.dev/pixie/src/utils/shared/k8s"
@@ -34,6 +41,18 @@ import (
 func init() {
 	ProxyCmd.Flags().StringP("namespace", "n", "", "The namespace to install K8s secrets to")
 	viper.BindPFlag("namespace", ProxyCmd.Flags().Lookup("namespace"))
+
+	ProxyGatewayCmd.Flags().String("listen", "127.0.0.1:50300", "The address the gateway listens on")
+	ProxyGatewayCmd.Flags().String("api_keys_file", "", "File of 'name:key' lines with the API keys clients may use")
+	ProxyGatewayCmd.Flags().StringSlice("cluster", nil, "IDs of the clusters to expose. Exposes all clusters if unset")
+	ProxyGatewayCmd.Flags().String("service_api_key", "", "Pixie API key used to forward requests. Defaults to the logged in user's credentials")
+	ProxyGatewayCmd.Flags().String("tls_cert", "", "TLS certificate to serve the gateway with")
+	ProxyGatewayCmd.Flags().String("tls_key", "", "TLS key to serve the gateway with")
+	ProxyGatewayCmd.Flags().Bool("insecure_skip_verify_internal", false, "Skip TLS verification when connecting to in-cluster (*.cluster.local) addresses")
+	ProxyGatewayCmd.MarkFlagRequired("api_keys_file")
+	registerClusterCompletion(ProxyGatewayCmd)
+
+	ProxyCmd.AddCommand(ProxyGatewayCmd)
 }
 
 // ProxyCmd is the "proxy" command.
@@ -61,3 +80,93 @@ var ProxyCmd = &cobra.Command{
 		_ = p.Stop()
 	},
 }
+
+// ProxyGatewayCmd is the "proxy gateway" command.
+var ProxyGatewayCmd = &cobra.Command{
+	Use:   "gateway",
+	Short: "Serve the Vizier API for multiple clusters on a single, API key authenticated endpoint",
+	Long: `Serve the Vizier API for multiple clusters on a single endpoint.
+
+Clients authenticate with one of the keys in --api_keys_file, sent in the 'pixie-api-key'
+header, and select the cluster using the cluster ID in each request. Requests are forwarded
+to Pixie using the service API key, or the logged in user's credentials.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cloudAddr := viper.GetString("cloud_addr")
+		listenAddr, _ := cmd.Flags().GetString("listen")
+		keysFile, _ := cmd.Flags().GetString("api_keys_file")
+		clusterIDStrs, _ := cmd.Flags().GetStringSlice("cluster")
+		serviceAPIKey, _ := cmd.Flags().GetString("service_api_key")
+		tlsCert, _ := cmd.Flags().GetString("tls_cert")
+		tlsKey, _ := cmd.Flags().GetString("tls_key")
+		skipVerifyInternal, _ := cmd.Flags().GetBool("insecure_skip_verify_internal")
+
+		if serviceAPIKey == "" {
+			serviceAPIKey = os.Getenv("PX_SERVICE_API_KEY")
+		}
+
+		keys, err := gateway.LoadAPIKeysFile(keysFile)
+		if err != nil {
+			utils.WithError(err).Fatal("Failed to load API keys")
+		}
+		if len(keys) == 0 {
+			utils.Fatal("No API keys found, refusing to start an open gateway")
+		}
+
+		var clusterIDs []uuid.UUID
+		for _, s := range clusterIDStrs {
+			id, err := uuid.FromString(s)
+			if err != nil {
+				utils.WithError(err).Fatalf("Invalid cluster ID '%s'", s)
+			}
+			clusterIDs = append(clusterIDs, id)
+		}
+
+		creds := gateway.CredentialsFunc(auth.CtxWithCreds)
+		if serviceAPIKey != "" {
+			creds = gateway.APIKeyCredentials(serviceAPIKey)
+		} else {
+			// Fail early if the user isn't logged in.
+			auth.MustLoadDefaultCredentials()
+		}
+
+		pool, err := gateway.NewVizierPool(cloudAddr, creds, skipVerifyInternal)
+		if err != nil {
+			utils.WithError(err).Fatal("Failed to connect to Pixie Cloud")
+		}
+		defer pool.Close()
+
+		var serverOpts []grpc.ServerOption
+		if tlsCert != "" || tlsKey != "" {
+			tc, err := credentials.NewServerTLSFromFile(tlsCert, tlsKey)
+			if err != nil {
+				utils.WithError(err).Fatal("Failed to load TLS certificate")
+			}
+			serverOpts = append(serverOpts, grpc.Creds(tc))
+		} else if host, _, err := net.SplitHostPort(listenAddr); err == nil {
+			if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+				utils.Error("Serving without TLS on a non-loopback address, API keys will be sent in plaintext")
+			}
+		}
+
+		lis, err := net.Listen("tcp", listenAddr)
+		if err != nil {
+			utils.WithError(err).Fatal("Failed to listen")
+		}
+
+		s := grpc.NewServer(serverOpts...)
+		vizierpb.RegisterVizierServiceServer(s, gateway.NewServer(gateway.NewAPIKeyAuthenticator(keys), pool, clusterIDs))
+
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt)
+		go func() {
+			<-stop
+			utils.Info("Stopping gateway")
+			s.GracefulStop()
+		}()
+
+		utils.Infof("Serving Vizier gateway on %s", lis.Addr())
+		if err := s.Serve(lis); err != nil {
+			utils.WithError(err).Fatal("Gateway failed")
+		}
+	},
+}
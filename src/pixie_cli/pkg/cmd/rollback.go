@@ -0,0 +1,194 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gofrs/uuid"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/segmentio/analytics-go.v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"px.dev/pixie/src/operator/api/v1alpha1"
+	"px.dev/pixie/src/pixie_cli/pkg/components"
+	"px.dev/pixie/src/pixie_cli/pkg/pxanalytics"
+	"px.dev/pixie/src/pixie_cli/pkg/pxconfig"
+	"px.dev/pixie/src/pixie_cli/pkg/utils"
+	"px.dev/pixie/src/pixie_cli/pkg/vizier"
+	"px.dev/pixie/src/utils/shared/k8s"
+)
+
+func init() {
+	RollbackCmd.AddCommand(VizierRollbackCmd)
+
+	VizierRollbackCmd.Flags().StringP("cluster", "c", "", "Run only on selected cluster")
+	registerClusterCompletion(VizierRollbackCmd)
+	VizierRollbackCmd.Flags().StringP("vizier_version", "v", "",
+		"Version to roll back to. Defaults to the previous version recorded in the Vizier's version history")
+	VizierRollbackCmd.Flags().Duration("health_timeout", 10*time.Minute, "Time to wait for the PEMs to become healthy after the rollback")
+}
+
+// RollbackCmd is the "rollback" sub-command of the CLI.
+var RollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Roll back Pixie to a previous version",
+	Run: func(cmd *cobra.Command, args []string) {
+		utils.Info("Nothing here... Please execute one of the subcommands")
+		cmd.Help()
+	},
+}
+
+// getVizierResource returns the Vizier resource deployed by the operator in the cluster of the current kubeconfig,
+// which must match the given cluster.
+func getVizierResource(clusterID uuid.UUID) (*v1alpha1.Vizier, error) {
+	config, err := k8s.TryGetConfig()
+	if err != nil {
+		return nil, err
+	}
+	if id := vizier.GetClusterIDFromKubeConfig(config); id != clusterID {
+		return nil, fmt.Errorf("the current kubeconfig does not point at cluster %s", clusterID)
+	}
+	clientset := k8s.GetClientset(config)
+	ns, err := vizier.FindVizierNamespace(clientset)
+	if err != nil {
+		return nil, err
+	}
+	vzClient, err := v1alpha1.NewVizierClient(config)
+	if err != nil {
+		return nil, err
+	}
+	viziers, err := vzClient.List(context.Background(), ns, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if len(viziers.Items) == 0 {
+		return nil, errors.New("no Vizier resource found, the Vizier was not deployed by the operator")
+	}
+	return &viziers.Items[0], nil
+}
+
+func printVersionHistory(vz *v1alpha1.Vizier) {
+	w := components.CreateStreamWriter("table", os.Stderr)
+	defer w.Finish()
+	w.SetHeader("vizier_version_history", []string{"Version", "Phase", "Deployed At"})
+	for _, r := range vz.Status.VersionHistory {
+		_ = w.Write([]interface{}{r.Version, r.VizierPhase, r.DeployedAt.Format(time.RFC3339)})
+	}
+}
+
+// VizierRollbackCmd is the command used to roll back Vizier.
+var VizierRollbackCmd = &cobra.Command{
+	Use:     "vizier",
+	Aliases: []string{"platform", "pixie"},
+	Short:   "Roll back the Pixie Platform to a previous version",
+	Run: func(cmd *cobra.Command, args []string) {
+		cloudAddr := viper.GetString("cloud_addr")
+		versionString, _ := cmd.Flags().GetString("vizier_version")
+		healthTimeout, _ := cmd.Flags().GetDuration("health_timeout")
+
+		clusterID := uuid.Nil
+		clusterStr, _ := cmd.Flags().GetString("cluster")
+		if len(clusterStr) > 0 {
+			u, err := uuid.FromString(clusterStr)
+			if err != nil {
+				utils.WithError(err).Fatal("Failed to parse cluster argument")
+			}
+			clusterID = u
+		}
+
+		cloudConn, err := utils.GetCloudClientConnection(cloudAddr)
+		if err != nil {
+			// Keep this as a log.Fatal() as opposed to using the CLI logger, because it
+			// is an unexpected error that Sentry should catch.
+			log.Fatalln(err)
+		}
+
+		// The Vizier being rolled back is likely unhealthy, so don't require a healthy Vizier.
+		if clusterID == uuid.Nil {
+			clusterID, err = getVizier(cloudAddr)
+			if err != nil {
+				utils.WithError(err).Fatal("Failed to select cluster")
+			}
+		}
+		clusterInfo, err := vizier.GetVizierInfo(cloudAddr, clusterID)
+		if err != nil {
+			utils.WithError(err).Fatalf("Failed to get info for cluster: %s", clusterID.String())
+		}
+
+		if versionString == "" {
+			vz, err := getVizierResource(clusterID)
+			if err != nil {
+				utils.WithError(err).Fatal("Failed to read the Vizier's version history, use --vizier_version to select the version")
+			}
+			printVersionHistory(vz)
+			versionString = vz.Status.PreviousVersion()
+			if versionString == "" {
+				utils.Fatal("No previous version found in the Vizier's version history")
+			}
+			if !vz.Spec.DisableAutoUpdate {
+				utils.Info("Auto update is enabled for this Vizier, so it may be updated again later")
+			}
+		}
+
+		utils.Infof("Rolling back Pixie on cluster %s from version %s to %s",
+			clusterInfo.ClusterName, clusterInfo.VizierVersion, versionString)
+		if !components.YNPrompt("Continue with the rollback?", true) {
+			utils.Error("Aborting rollback.")
+			return
+		}
+
+		_ = pxanalytics.Client().Enqueue(&analytics.Track{
+			UserId: pxconfig.Cfg().UniqueClientID,
+			Event:  "Vizier Rollback Initiated",
+			Properties: analytics.NewProperties().
+				Set("cloud_addr", cloudAddr).
+				Set("cluster_id", clusterID).
+				Set("cluster_status", clusterInfo.Status.String()),
+		})
+
+		_, err = runVizierUpdate(cloudConn, cloudAddr, clusterID, versionString, false,
+			clusterInfo.NumInstrumentedNodes, healthTimeout)
+		if err != nil {
+			_ = pxanalytics.Client().Enqueue(&analytics.Track{
+				UserId: pxconfig.Cfg().UniqueClientID,
+				Event:  "Vizier Rollback Failed",
+				Properties: analytics.NewProperties().
+					Set("cloud_addr", cloudAddr).
+					Set("cluster_id", clusterID),
+			})
+			// Keep as log.Fatal which produces a Sentry error for this unexpected behavior.
+			log.WithError(err).Fatal("Rollback failed")
+		}
+
+		_ = pxanalytics.Client().Enqueue(&analytics.Track{
+			UserId: pxconfig.Cfg().UniqueClientID,
+			Event:  "Vizier Rollback Complete",
+			Properties: analytics.NewProperties().
+				Set("cloud_addr", cloudAddr).
+				Set("cluster_id", clusterID),
+		})
+	},
+}
@@ -55,11 +55,13 @@ func init() {
 	RootCmd.AddCommand(VersionCmd)
 	RootCmd.AddCommand(AuthCmd)
 	RootCmd.AddCommand(CollectLogsCmd)
+	RootCmd.AddCommand(DiagnoseCmd)
 	RootCmd.AddCommand(CreateCloudCertsCmd)
 	RootCmd.AddCommand(DemoCmd)
 	RootCmd.AddCommand(DeployCmd)
 	RootCmd.AddCommand(DeleteCmd)
 	RootCmd.AddCommand(UpdateCmd)
+	RootCmd.AddCommand(RollbackCmd)
 	RootCmd.AddCommand(ProxyCmd)
 	RootCmd.AddCommand(RunCmd)
 	RootCmd.AddCommand(LiveCmd)
@@ -70,6 +72,11 @@ func init() {
 	RootCmd.AddCommand(DeployKeyCmd)
 	RootCmd.AddCommand(APIKeyCmd)
 	RootCmd.AddCommand(DebugCmd)
+	RootCmd.AddCommand(ScheduleCmd)
+	RootCmd.AddCommand(TracepointCmd)
+	RootCmd.AddCommand(CompletionCmd)
+
+	RootCmd.ValidArgsFunction = completePlugin
 
 	RootCmd.PersistentFlags().MarkHidden("cloud_addr")
 	RootCmd.PersistentFlags().MarkHidden("dev_cloud_namespace")
@@ -93,6 +100,14 @@ func init() {
 	_ = RootCmd.ParseFlags(os.Args[1:])
 }
 
+// cloudAddrWithPort adds the default port to the cloud address, if it doesn't specify one.
+func cloudAddrWithPort(cloudAddr string) string {
+	if matched, err := regexp.MatchString(".+:[0-9]+$", cloudAddr); !matched && err == nil {
+		return cloudAddr + ":443"
+	}
+	return cloudAddr
+}
+
 func printTestingBanner() {
 	envs := os.Environ()
 	var pxEnvs []string
@@ -120,18 +135,22 @@ var RootCmd = &cobra.Command{
 	// TODO(zasgar): Add description and update this.
 	Long: `The Pixie command line interface.`,
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
-		printTestingBanner()
-
-		cloudAddr := viper.GetString("cloud_addr")
-		if matched, err := regexp.MatchString(".+:[0-9]+$", cloudAddr); !matched && err == nil {
-			viper.Set("cloud_addr", cloudAddr+":443")
+		if !isCompletionRequest(cmd) {
+			printTestingBanner()
 		}
 
+		viper.Set("cloud_addr", cloudAddrWithPort(viper.GetString("cloud_addr")))
+
 		if viper.IsSet("testing_env") && !viper.IsSet("dev_cloud_namespace") {
 			// Setting this to the most likely default if not already set.
 			viper.Set("dev_cloud_namespace", "plc-dev")
 		}
 
+		// Completion output is read by the shell, so skip the banner and update checks.
+		if isCompletionRequest(cmd) {
+			return
+		}
+
 		p := cmd
 
 		if p != nil {
@@ -171,6 +190,20 @@ var RootCmd = &cobra.Command{
 
 // Execute is the main function for the Cobra CLI.
 func Execute() {
+	// Commands which are not builtin are dispatched to plugins on the PATH.
+	if _, _, err := RootCmd.Find(os.Args[1:]); err != nil {
+		exitCode, err := runPlugin(os.Args[1:])
+		if err == nil {
+			if exitCode != 0 {
+				os.Exit(exitCode)
+			}
+			return
+		}
+		if err != errPluginNotFound {
+			utils.WithError(err).Fatal("Error executing plugin")
+		}
+	}
+
 	if err := RootCmd.Execute(); err != nil {
 		_ = pxanalytics.Client().Enqueue(&analytics.Track{
 			UserId: pxconfig.Cfg().UniqueClientID,
@@ -33,6 +33,7 @@ import (
 	"github.com/spf13/viper"
 	"gopkg.in/segmentio/analytics-go.v3"
 
+	"px.dev/pixie/src/api/proto/vizierpb"
 	"px.dev/pixie/src/cloud/api/ptproxy"
 	"px.dev/pixie/src/pixie_cli/pkg/pxanalytics"
 	"px.dev/pixie/src/pixie_cli/pkg/pxconfig"
@@ -49,6 +50,23 @@ func init() {
 	RunCmd.Flags().StringP("cluster", "c", "", "ID of the cluster to run on. "+
 		"Use 'px get viziers', or visit Admin console: work.withpixie.ai/admin, to find the ID")
 	RunCmd.Flags().MarkHidden("all-clusters")
+	registerClusterCompletion(RunCmd)
+
+	RunCmd.Flags().StringSlice("tracepoint-nodes", nil, "Only deploy the script's tracepoints to the agents on these nodes")
+	RunCmd.Flags().String("tracepoint-node-labels", "", "Only deploy the script's tracepoints to the agents on nodes "+
+		"with these labels, e.g. pool=a,disk=ssd")
+	RunCmd.Flags().StringSlice("tracepoint-namespaces", nil, "Only deploy the script's tracepoints to the agents on nodes "+
+		"running a pod in one of these namespaces")
+	RunCmd.Flags().String("tracepoint-pod-labels", "", "Only deploy the script's tracepoints to the agents on nodes "+
+		"running a pod with these labels, e.g. app=frontend")
+	RunCmd.Flags().Int32("tracepoint-canary-agents", 0, "Roll the script's tracepoints out in stages, "+
+		"starting with this many agents")
+	RunCmd.Flags().Int32("tracepoint-wave-percent", 0, "The percentage of agents that each stage of a tracepoint "+
+		"rollout deploys to")
+	RunCmd.Flags().Int32("tracepoint-max-failure-percent", 0, "The percentage of agents that may fail to run a "+
+		"tracepoint before its rollout is rolled back")
+	RunCmd.Flags().Duration("tracepoint-wave-timeout", 0, "How long to wait for the agents in each stage of a "+
+		"tracepoint rollout to start running it")
 
 	RunCmd.Flags().StringP("bundle", "b", "", "Path/URL to bundle file")
 	viper.BindPFlag("bundle", RunCmd.Flags().Lookup("bundle"))
@@ -110,8 +128,9 @@ Script Usage:
 
 func createNewCobraCommand() *cobra.Command {
 	return &cobra.Command{
-		Use:   "run",
-		Short: "Execute a script",
+		Use:               "run",
+		Short:             "Execute a script",
+		ValidArgsFunction: completeScript,
 		Run: func(cmd *cobra.Command, args []string) {
 			cloudAddr := viper.GetString("cloud_addr")
 			format, _ := cmd.Flags().GetString("output")
@@ -173,6 +192,9 @@ func createNewCobraCommand() *cobra.Command {
 				}
 			}
 
+			execScript.TracepointSelector = tracepointSelectorFromFlags(cmd)
+			execScript.TracepointRollout = tracepointRolloutFromFlags(cmd)
+
 			allClusters, _ := cmd.Flags().GetBool("all-clusters")
 			selectedCluster, _ := cmd.Flags().GetString("cluster")
 			clusterID := uuid.FromStringOrNil(selectedCluster)
@@ -241,6 +263,51 @@ func createNewCobraCommand() *cobra.Command {
 	}
 }
 
+// tracepointSelectorFromFlags returns the selector for the script's tracepoints, or nil if the
+// tracepoints should be deployed to all agents.
+func tracepointSelectorFromFlags(cmd *cobra.Command) *vizierpb.TracepointSelector {
+	nodeNames, _ := cmd.Flags().GetStringSlice("tracepoint-nodes")
+	nodeLabels, _ := cmd.Flags().GetString("tracepoint-node-labels")
+	namespaces, _ := cmd.Flags().GetStringSlice("tracepoint-namespaces")
+	podLabels, _ := cmd.Flags().GetString("tracepoint-pod-labels")
+	if len(nodeNames) == 0 && nodeLabels == "" && len(namespaces) == 0 && podLabels == "" {
+		return nil
+	}
+	return &vizierpb.TracepointSelector{
+		NodeNames:  nodeNames,
+		NodeLabels: nodeLabels,
+		Namespaces: namespaces,
+		PodLabels:  podLabels,
+	}
+}
+
+// tracepointRolloutFromFlags returns the policy for rolling the script's tracepoints out in stages,
+// or nil if none of the rollout flags are set.
+func tracepointRolloutFromFlags(cmd *cobra.Command) *vizierpb.TracepointRolloutPolicy {
+	flags := []string{"tracepoint-canary-agents", "tracepoint-wave-percent", "tracepoint-max-failure-percent",
+		"tracepoint-wave-timeout"}
+	set := false
+	for _, f := range flags {
+		if cmd.Flags().Changed(f) {
+			set = true
+			break
+		}
+	}
+	if !set {
+		return nil
+	}
+	canaryAgents, _ := cmd.Flags().GetInt32("tracepoint-canary-agents")
+	wavePercent, _ := cmd.Flags().GetInt32("tracepoint-wave-percent")
+	maxFailurePercent, _ := cmd.Flags().GetInt32("tracepoint-max-failure-percent")
+	waveTimeout, _ := cmd.Flags().GetDuration("tracepoint-wave-timeout")
+	return &vizierpb.TracepointRolloutPolicy{
+		CanaryAgents:      canaryAgents,
+		WavePercent:       wavePercent,
+		MaxFailurePercent: maxFailurePercent,
+		WaveTimeoutNS:     waveTimeout.Nanoseconds(),
+	}
+}
+
 // RunCmd is the "query" command.
 var RunCmd = createNewCobraCommand()
 
@@ -0,0 +1,252 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/gofrs/uuid"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/pixie_cli/pkg/components"
+	"px.dev/pixie/src/pixie_cli/pkg/script"
+	"px.dev/pixie/src/pixie_cli/pkg/utils"
+	"px.dev/pixie/src/pixie_cli/pkg/vizier"
+)
+
+func init() {
+	ScheduleCmd.AddCommand(ScheduleCreateCmd)
+	ScheduleCmd.AddCommand(ScheduleListCmd)
+	ScheduleCmd.AddCommand(ScheduleDeleteCmd)
+	ScheduleCmd.AddCommand(ScheduleResultsCmd)
+	ScheduleCmd.PersistentFlags().StringP("cluster", "c", "", "ID of the cluster the scripts are scheduled on")
+	registerClusterCompletion(ScheduleCmd)
+
+	ScheduleCreateCmd.Flags().StringP("file", "f", "", "Script file, specify - for STDIN")
+	ScheduleCreateCmd.Flags().StringP("name", "n", "", "The name of the scheduled script. Defaults to the script name")
+	ScheduleCreateCmd.Flags().String("cron", "", "When to run the script, as a cron expression (e.g. '0 * * * *' or '@daily')")
+	ScheduleCreateCmd.Flags().String("id", "", "The ID of an existing scheduled script to update")
+
+	ScheduleListCmd.Flags().StringP("output", "o", "", "Output format: one of: json|table|csv")
+
+	ScheduleResultsCmd.Flags().StringP("output", "o", "", "Output format: one of: json|table|csv")
+	ScheduleResultsCmd.Flags().String("run", "", "The ID of the run to fetch. Defaults to the latest successful run")
+}
+
+// ScheduleCmd is the "schedule" command.
+var ScheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Manage scripts that run on a schedule and keep their results",
+}
+
+// mustConnectClusterVizier connects to the cluster selected with --cluster, or to the current
+// cluster if none is.
+func mustConnectClusterVizier(cmd *cobra.Command) *vizier.Connector {
+	cloudAddr := viper.GetString("cloud_addr")
+	selectedCluster, _ := cmd.Flags().GetString("cluster")
+	clusterID := uuid.FromStringOrNil(selectedCluster)
+
+	var err error
+	if clusterID == uuid.Nil {
+		clusterID, err = vizier.GetCurrentOrFirstHealthyVizier(cloudAddr)
+		if err != nil {
+			utils.WithError(err).Fatal("Could not fetch healthy vizier")
+		}
+	}
+
+	conn, err := vizier.ConnectionToVizierByID(cloudAddr, clusterID)
+	if err != nil {
+		utils.WithError(err).Fatal("Could not connect to vizier")
+	}
+	return conn
+}
+
+// ScheduleCreateCmd is the "schedule create" command.
+var ScheduleCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Run a script on a schedule",
+	Example: `  px schedule create px/http_data --cron '@hourly'
+  px schedule create px/namespace --cron '0 9 * * *' --name daily-default -- --namespace default
+  px schedule create -f script.pxl --cron '*/30 * * * *'`,
+	Run: func(cmd *cobra.Command, args []string) {
+		cron, _ := cmd.Flags().GetString("cron")
+		if cron == "" {
+			utils.Fatal("Expected a schedule, set with --cron")
+		}
+
+		var execScript *script.ExecutableScript
+		var scriptArgs []string
+		scriptFile, _ := cmd.Flags().GetString("file")
+		if scriptFile == "" {
+			if len(args) == 0 {
+				utils.Fatal("Expected script_name with script args.")
+			}
+			br, err := createBundleReader()
+			if err != nil {
+				utils.WithError(err).Fatal("Failed to read script bundle")
+			}
+			execScript = br.MustGetScript(args[0])
+			scriptArgs = args[1:]
+		} else {
+			var err error
+			execScript, err = loadScriptFromFile(scriptFile)
+			if err != nil {
+				utils.WithError(err).Fatal("Failed to get query string")
+			}
+			scriptArgs = args
+		}
+
+		if fs := execScript.GetFlagSet(); fs != nil {
+			if err := fs.Parse(scriptArgs); err != nil {
+				if err == flag.ErrHelp {
+					os.Exit(0)
+				}
+				utils.WithError(err).Fatal("Failed to parse script flags")
+			}
+			if err := execScript.UpdateFlags(fs); err != nil {
+				if errors.Is(err, script.ErrMissingRequiredArgument) {
+					utils.Fatal("Missing required argument, use `px run <script_name> -- --help` to see the script's arguments")
+				}
+				utils.WithError(err).Fatal("Error parsing script flags")
+			}
+		}
+		execFuncs, err := vizier.GetFuncsToExecute(execScript)
+		if err != nil {
+			utils.WithError(err).Fatal("Failed to get the functions to execute")
+		}
+
+		name, _ := cmd.Flags().GetString("name")
+		if name == "" {
+			name = execScript.ScriptName
+		}
+		if name == "" {
+			utils.Fatal("Expected a name for the scheduled script, set with --name")
+		}
+		id, _ := cmd.Flags().GetString("id")
+
+		conn := mustConnectClusterVizier(cmd)
+		ctx, cleanup := utils.WithSignalCancellable(context.Background())
+		defer cleanup()
+		id, err = conn.UpsertScheduledScript(ctx, &vizierpb.ScheduledScript{
+			ID:             id,
+			Name:           name,
+			QueryStr:       strings.TrimSpace(execScript.ScriptString),
+			ExecFuncs:      execFuncs,
+			CronExpression: cron,
+		})
+		if err != nil {
+			utils.WithError(err).Fatal("Failed to schedule script")
+		}
+		utils.Infof("Scheduled script %s with ID %s", name, id)
+	},
+}
+
+// ScheduleListCmd is the "schedule list" command.
+var ScheduleListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the scheduled scripts and their latest run",
+	Run: func(cmd *cobra.Command, args []string) {
+		format, _ := cmd.Flags().GetString("output")
+		format = strings.ToLower(format)
+
+		conn := mustConnectClusterVizier(cmd)
+		ctx, cleanup := utils.WithSignalCancellable(context.Background())
+		defer cleanup()
+		scripts, err := conn.ListScheduledScripts(ctx)
+		if err != nil {
+			utils.WithError(err).Fatal("Failed to list scheduled scripts")
+		}
+
+		w := components.CreateStreamWriter(format, os.Stdout)
+		defer w.Finish()
+		w.SetHeader("scheduled_scripts", []string{"ID", "Name", "Schedule", "LastRun", "LastRunID", "LastRunStatus"})
+
+		for _, s := range scripts {
+			var lastRun interface{}
+			var lastRunID, lastRunStatus string
+			// Runs are newest first.
+			if len(s.Runs) > 0 {
+				run := s.Runs[0]
+				lastRun = run.StartTimestampNS
+				if format == "" || format == "table" {
+					lastRun = humanize.Time(time.Unix(0, run.StartTimestampNS))
+				}
+				lastRunID = run.ID
+				lastRunStatus = "OK"
+				if run.Status.GetCode() != 0 {
+					lastRunStatus = run.Status.GetMessage()
+				}
+			}
+			_ = w.Write([]interface{}{s.ID, s.Name, s.CronExpression, lastRun, lastRunID, lastRunStatus})
+		}
+	},
+}
+
+// ScheduleDeleteCmd is the "schedule delete" command.
+var ScheduleDeleteCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Delete a scheduled script and its results",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			utils.Fatal("Must supply a single argument scheduled script ID")
+		}
+
+		conn := mustConnectClusterVizier(cmd)
+		ctx, cleanup := utils.WithSignalCancellable(context.Background())
+		defer cleanup()
+		if err := conn.DeleteScheduledScript(ctx, args[0]); err != nil {
+			utils.WithError(err).Fatal("Failed to delete scheduled script")
+		}
+		utils.Infof("Deleted scheduled script %s", args[0])
+	},
+}
+
+// ScheduleResultsCmd is the "schedule results" command.
+var ScheduleResultsCmd = &cobra.Command{
+	Use:   "results",
+	Short: "Show the results of a run of a scheduled script",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			utils.Fatal("Must supply a single argument scheduled script ID")
+		}
+		format, _ := cmd.Flags().GetString("output")
+		format = strings.ToLower(format)
+		runID, _ := cmd.Flags().GetString("run")
+
+		conn := mustConnectClusterVizier(cmd)
+		ctx, cleanup := utils.WithSignalCancellable(context.Background())
+		defer cleanup()
+		resp, err := conn.GetScheduledScriptResults(ctx, args[0], runID)
+		if err != nil {
+			utils.WithError(err).Fatal("Failed to fetch scheduled script results")
+		}
+		tw := vizier.NewStreamOutputAdapter(ctx, resp, format)
+		if err := tw.Finish(); err != nil {
+			utils.WithError(err).Fatal("Failed to fetch scheduled script results")
+		}
+	},
+}
@@ -60,10 +60,11 @@ var ScriptListCmd = &cobra.Command{
 
 // ScriptShowCmd is the "script show" command.
 var ScriptShowCmd = &cobra.Command{
-	Use:     "show",
-	Short:   "Dumps out the string for a particular pxl script",
-	Args:    cobra.ExactArgs(1),
-	Aliases: []string{"scripts"},
+	Use:               "show",
+	Short:             "Dumps out the string for a particular pxl script",
+	Args:              cobra.ExactArgs(1),
+	Aliases:           []string{"scripts"},
+	ValidArgsFunction: completeScript,
 	Run: func(cmd *cobra.Command, args []string) {
 		br := mustCreateBundleReader()
 		scriptName := args[0]
@@ -0,0 +1,209 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/gogo/protobuf/jsonpb"
+	"github.com/spf13/cobra"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/pixie_cli/pkg/components"
+	"px.dev/pixie/src/pixie_cli/pkg/utils"
+)
+
+func init() {
+	TracepointCmd.AddCommand(TracepointListCmd)
+	TracepointCmd.AddCommand(TracepointDescribeCmd)
+	TracepointCmd.AddCommand(TracepointDeleteCmd)
+	TracepointCmd.AddCommand(TracepointExtendCmd)
+	TracepointCmd.PersistentFlags().StringP("cluster", "c", "", "ID of the cluster the tracepoints are deployed on")
+	registerClusterCompletion(TracepointCmd)
+
+	TracepointListCmd.Flags().StringP("output", "o", "", "Output format: one of: json|table|csv")
+
+	TracepointDescribeCmd.Flags().StringP("output", "o", "", "Output format: one of: json|table|csv")
+
+	TracepointExtendCmd.Flags().Duration("ttl", 0, "The new TTL of the tracepoints, starting now (e.g. 30m)")
+}
+
+// TracepointCmd is the "tracepoint" command.
+var TracepointCmd = &cobra.Command{
+	Use:     "tracepoint",
+	Aliases: []string{"tracepoints"},
+	Short:   "Inspect and manage the tracepoints deployed by PxL scripts",
+}
+
+// tracepointState is the state of the tracepoint as displayed, which also tells apart tracepoints
+// that are being terminated.
+func tracepointState(tp *vizierpb.Tracepoint) string {
+	if tp.ExpectedState == vizierpb.TERMINATED_STATE && tp.State != vizierpb.TERMINATED_STATE {
+		return "TERMINATING"
+	}
+	return strings.TrimSuffix(tp.State.String(), "_STATE")
+}
+
+func formatStatuses(statuses []*vizierpb.Status) string {
+	msgs := make([]string, len(statuses))
+	for i, s := range statuses {
+		msgs[i] = s.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func formatExpiry(expiresAtNS int64, format string) interface{} {
+	if format != "" && format != "table" {
+		return expiresAtNS
+	}
+	if expiresAtNS == 0 {
+		return ""
+	}
+	return humanize.Time(time.Unix(0, expiresAtNS))
+}
+
+// TracepointListCmd is the "tracepoint list" command.
+var TracepointListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the tracepoints and their state",
+	Run: func(cmd *cobra.Command, args []string) {
+		format, _ := cmd.Flags().GetString("output")
+		format = strings.ToLower(format)
+
+		conn := mustConnectClusterVizier(cmd)
+		ctx, cleanup := utils.WithSignalCancellable(context.Background())
+		defer cleanup()
+		tracepoints, err := conn.ListTracepoints(ctx)
+		if err != nil {
+			utils.WithError(err).Fatal("Failed to list tracepoints")
+		}
+
+		w := components.CreateStreamWriter(format, os.Stdout)
+		defer w.Finish()
+		w.SetHeader("tracepoints", []string{"Name", "ID", "Version", "State", "Tables", "Expires", "Errors"})
+		for _, tp := range tracepoints {
+			_ = w.Write([]interface{}{tp.Name, tp.ID, tp.Version, tracepointState(tp),
+				strings.Join(tp.TableNames, ","), formatExpiry(tp.ExpiresAtNS, format), formatStatuses(tp.Statuses)})
+		}
+	},
+}
+
+// TracepointDescribeCmd is the "tracepoint describe" command.
+var TracepointDescribeCmd = &cobra.Command{
+	Use:   "describe",
+	Short: "Show a tracepoint and its state on each agent",
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			utils.Fatal("Must supply a single argument tracepoint name")
+		}
+		format, _ := cmd.Flags().GetString("output")
+		format = strings.ToLower(format)
+
+		conn := mustConnectClusterVizier(cmd)
+		ctx, cleanup := utils.WithSignalCancellable(context.Background())
+		defer cleanup()
+		tp, err := conn.GetTracepoint(ctx, args[0])
+		if err != nil {
+			utils.WithError(err).Fatal("Failed to get tracepoint")
+		}
+
+		if format == "json" {
+			m := jsonpb.Marshaler{Indent: "  "}
+			if err := m.Marshal(os.Stdout, tp); err != nil {
+				utils.WithError(err).Fatal("Failed to print tracepoint")
+			}
+			fmt.Println()
+			return
+		}
+
+		if format == "" || format == "table" {
+			fmt.Printf("Name:     %s\n", tp.Name)
+			fmt.Printf("ID:       %s\n", tp.ID)
+			fmt.Printf("Version:  %d\n", tp.Version)
+			fmt.Printf("State:    %s\n", tracepointState(tp))
+			fmt.Printf("Tables:   %s\n", strings.Join(tp.TableNames, ", "))
+			fmt.Printf("Expires:  %s\n", formatExpiry(tp.ExpiresAtNS, format))
+			if len(tp.Statuses) > 0 {
+				fmt.Printf("Errors:   %s\n", formatStatuses(tp.Statuses))
+			}
+			fmt.Println()
+		}
+
+		w := components.CreateStreamWriter(format, os.Stdout)
+		defer w.Finish()
+		w.SetHeader("agent_tracepoints", []string{"AgentID", "State", "Error"})
+		for _, s := range tp.AgentStatuses {
+			var msg string
+			if s.Status.GetCode() != 0 {
+				msg = s.Status.GetMessage()
+			}
+			_ = w.Write([]interface{}{s.AgentID, strings.TrimSuffix(s.State.String(), "_STATE"), msg})
+		}
+	},
+}
+
+// TracepointDeleteCmd is the "tracepoint delete" command.
+var TracepointDeleteCmd = &cobra.Command{
+	Use:   "delete",
+	Short: "Remove tracepoints from all agents",
+	Example: `  px tracepoint delete http_probe
+  px tracepoint delete http_probe dns_probe`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			utils.Fatal("Must supply the names of the tracepoints to delete")
+		}
+
+		conn := mustConnectClusterVizier(cmd)
+		ctx, cleanup := utils.WithSignalCancellable(context.Background())
+		defer cleanup()
+		if err := conn.DeleteTracepoints(ctx, args); err != nil {
+			utils.WithError(err).Fatal("Failed to delete tracepoints")
+		}
+		utils.Infof("Deleting tracepoints %s", strings.Join(args, ", "))
+	},
+}
+
+// TracepointExtendCmd is the "tracepoint extend" command.
+var TracepointExtendCmd = &cobra.Command{
+	Use:     "extend",
+	Short:   "Extend the TTL of tracepoints",
+	Example: `  px tracepoint extend http_probe --ttl 1h`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			utils.Fatal("Must supply the names of the tracepoints to extend")
+		}
+		ttl, _ := cmd.Flags().GetDuration("ttl")
+		if ttl <= 0 {
+			utils.Fatal("Expected a positive TTL, set with --ttl")
+		}
+
+		conn := mustConnectClusterVizier(cmd)
+		ctx, cleanup := utils.WithSignalCancellable(context.Background())
+		defer cleanup()
+		if err := conn.ExtendTracepoints(ctx, args, ttl); err != nil {
+			utils.WithError(err).Fatal("Failed to extend tracepoints")
+		}
+		utils.Infof("Tracepoints %s now expire in %s", strings.Join(args, ", "), ttl)
+	},
+}
@@ -21,6 +21,9 @@ package cmd
 import (
 	"context"
 	"errors"
+	"fmt"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/blang/semver"
@@ -28,9 +31,13 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"google.golang.org/grpc"
 	"gopkg.in/segmentio/analytics-go.v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 
 	"px.dev/pixie/src/api/proto/cloudpb"
+	"px.dev/pixie/src/pixie_cli/pkg/auth"
 	"px.dev/pixie/src/pixie_cli/pkg/components"
 	"px.dev/pixie/src/pixie_cli/pkg/pxanalytics"
 	"px.dev/pixie/src/pixie_cli/pkg/pxconfig"
@@ -39,8 +46,15 @@ import (
 	"px.dev/pixie/src/pixie_cli/pkg/vizier"
 	version "px.dev/pixie/src/shared/goversion"
 	utils2 "px.dev/pixie/src/utils"
+	"px.dev/pixie/src/utils/shared/artifacts"
+	"px.dev/pixie/src/utils/shared/k8s"
+	yamlsutils "px.dev/pixie/src/utils/shared/yamls"
+	vizieryamls "px.dev/pixie/src/utils/template_generator/vizier_yamls"
 )
 
+// operatorDeploymentName is the name of the Deployment running the Vizier operator.
+const operatorDeploymentName = "vizier-operator"
+
 func init() {
 	UpdateCmd.AddCommand(CLIUpdateCmd)
 	UpdateCmd.AddCommand(VizierUpdateCmd)
@@ -57,6 +71,10 @@ func init() {
 	viper.BindPFlag("redeploy_etcd", VizierUpdateCmd.Flags().Lookup("redeploy_etcd"))
 
 	VizierUpdateCmd.Flags().StringP("cluster", "c", "", "Run only on selected cluster")
+	VizierUpdateCmd.Flags().Bool("plan", false, "Show the changes the update would make, without updating")
+	VizierUpdateCmd.Flags().Bool("auto_rollback", false, "Roll back to the current version if the update fails")
+	VizierUpdateCmd.Flags().Duration("health_timeout", 10*time.Minute, "Time to wait for the PEMs to become healthy after the update")
+	registerClusterCompletion(VizierUpdateCmd)
 }
 
 // UpdateCmd is the "update" sub-command of the CLI.
@@ -78,6 +96,9 @@ var VizierUpdateCmd = &cobra.Command{
 		versionString := viper.GetString("vizier_version")
 		cloudAddr := viper.GetString("cloud_addr")
 		redeployEtcd := viper.GetBool("redeploy_etcd")
+		showPlan, _ := cmd.Flags().GetBool("plan")
+		autoRollback, _ := cmd.Flags().GetBool("auto_rollback")
+		healthTimeout, _ := cmd.Flags().GetDuration("health_timeout")
 
 		clusterID := uuid.Nil
 		clusterStr, _ := cmd.Flags().GetString("cluster")
@@ -108,13 +129,6 @@ var VizierUpdateCmd = &cobra.Command{
 			utils.WithError(err).Fatalf("Failed to get info for cluster: %s", clusterID.String())
 		}
 
-		utils.Infof("Updating Pixie on the following cluster: %s", clusterInfo.ClusterName)
-		clusterOk := components.YNPrompt("Is the cluster correct?", true)
-		if !clusterOk {
-			utils.Error("Cluster is not correct. Aborting.")
-			return
-		}
-
 		if len(versionString) == 0 {
 			// Fetch latest version.
 			versionString, err = getLatestVizierVersion(cloudConn)
@@ -125,6 +139,22 @@ var VizierUpdateCmd = &cobra.Command{
 			}
 		}
 
+		if showPlan {
+			plan, err := getVizierPlan(cloudConn, cloudAddr, clusterInfo, versionString)
+			if err != nil {
+				utils.WithError(err).Fatal("Failed to plan update")
+			}
+			printVizierPlan(clusterInfo, plan)
+			return
+		}
+
+		utils.Infof("Updating Pixie on the following cluster: %s", clusterInfo.ClusterName)
+		clusterOk := components.YNPrompt("Is the cluster correct?", true)
+		if !clusterOk {
+			utils.Error("Cluster is not correct. Aborting.")
+			return
+		}
+
 		if sv, err := semver.Parse(clusterInfo.VizierVersion); err == nil {
 			svNew := semver.MustParse(versionString)
 			if svNew.Compare(sv) < 0 {
@@ -144,47 +174,8 @@ var VizierUpdateCmd = &cobra.Command{
 
 		utils.Infof("Updating to version: %s", versionString)
 
-		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
-		defer cancel()
-		updateJobs := []utils.Task{
-			newTaskWrapper("Initiating update", func() error {
-				return initiateUpdate(ctx, cloudConn, clusterID, versionString, redeployEtcd)
-			}),
-			newTaskWrapper("Wait for update to complete (this may take a few minutes)", func() error {
-				timer := time.NewTicker(5 * time.Second)
-				timeout := time.NewTimer(5 * time.Minute)
-				defer timer.Stop()
-				defer timeout.Stop()
-				for {
-					select {
-					case <-timer.C:
-						clusterInfo, err := vizier.GetVizierInfo(cloudAddr, clusterID)
-						if err != nil {
-							return err
-						}
-						if clusterInfo.Status == cloudpb.CS_HEALTHY {
-							updateVersion, err := semver.Parse(versionString)
-							if err != nil {
-								return err
-							}
-							currentVersion, err := semver.Parse(clusterInfo.VizierVersion)
-							if err != nil {
-								return err
-							}
-
-							if currentVersion.Compare(updateVersion) == 0 {
-								return nil
-							}
-						}
-					case <-timeout.C:
-						return errors.New("timeout waiting for update")
-					}
-				}
-			}),
-			newTaskWrapper("Wait for healthcheck", waitForHealthCheckTaskGenerator(cloudAddr, clusterID)),
-		}
-		uj := utils.NewSerialTaskRunner(updateJobs)
-		err = uj.RunAndMonitor()
+		started, err := runVizierUpdate(cloudConn, cloudAddr, clusterID, versionString, redeployEtcd,
+			clusterInfo.NumInstrumentedNodes, healthTimeout)
 
 		if err != nil {
 			_ = pxanalytics.Client().Enqueue(&analytics.Track{
@@ -195,6 +186,24 @@ var VizierUpdateCmd = &cobra.Command{
 					Set("cluster_id", clusterID),
 			})
 
+			if autoRollback && started && clusterInfo.VizierVersion != "" {
+				utils.WithError(err).Errorf("Update failed, rolling back to version %s", clusterInfo.VizierVersion)
+				_, rbErr := runVizierUpdate(cloudConn, cloudAddr, clusterID, clusterInfo.VizierVersion, false,
+					clusterInfo.NumInstrumentedNodes, healthTimeout)
+				_ = pxanalytics.Client().Enqueue(&analytics.Track{
+					UserId: pxconfig.Cfg().UniqueClientID,
+					Event:  "Vizier Auto Rollback",
+					Properties: analytics.NewProperties().
+						Set("cloud_addr", cloudAddr).
+						Set("cluster_id", clusterID).
+						Set("succeeded", rbErr == nil),
+				})
+				if rbErr != nil {
+					log.WithError(rbErr).Fatal("Rollback failed")
+				}
+				utils.Fatalf("Update failed, rolled back to version %s", clusterInfo.VizierVersion)
+			}
+
 			// Keep as log.Fatal which produces a Sentry error for this unexpected behavior
 			// (as opposed to user error which shouldn't be tracked in Sentry)
 			log.WithError(err).Fatal("Update failed")
@@ -210,6 +219,205 @@ var VizierUpdateCmd = &cobra.Command{
 	},
 }
 
+// runVizierUpdate updates the Vizier to the given version, and waits for it to become healthy. Returns whether the
+// update was started, so that callers can tell whether a failed update needs to be rolled back.
+func runVizierUpdate(cloudConn *grpc.ClientConn, cloudAddr string, clusterID uuid.UUID, versionString string,
+	redeployEtcd bool, expectedPEMs int32, healthTimeout time.Duration) (bool, error) {
+	started := false
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	updateJobs := []utils.Task{
+		newTaskWrapper("Initiating update", func() error {
+			if err := initiateUpdate(ctx, cloudConn, clusterID, versionString, redeployEtcd); err != nil {
+				return err
+			}
+			started = true
+			return nil
+		}),
+		newTaskWrapper("Wait for update to complete (this may take a few minutes)",
+			waitForVizierVersionTaskGenerator(cloudAddr, clusterID, versionString)),
+		newTaskWrapper("Wait for PEMs to become healthy",
+			waitForHealthyPEMsTaskGenerator(cloudAddr, clusterID, expectedPEMs, healthTimeout)),
+		newTaskWrapper("Wait for healthcheck", waitForHealthCheckTaskGenerator(cloudAddr, clusterID)),
+	}
+	uj := utils.NewSerialTaskRunner(updateJobs)
+	err := uj.RunAndMonitor()
+	return started, err
+}
+
+// waitForVizierVersionTaskGenerator waits for the Vizier to report the given version.
+func waitForVizierVersionTaskGenerator(cloudAddr string, clusterID uuid.UUID, versionString string) func() error {
+	return func() error {
+		updateVersion, err := semver.Parse(versionString)
+		if err != nil {
+			return err
+		}
+		timer := time.NewTicker(5 * time.Second)
+		timeout := time.NewTimer(5 * time.Minute)
+		defer timer.Stop()
+		defer timeout.Stop()
+		for {
+			select {
+			case <-timer.C:
+				clusterInfo, err := vizier.GetVizierInfo(cloudAddr, clusterID)
+				if err != nil {
+					return err
+				}
+				if clusterInfo.Status == cloudpb.CS_HEALTHY {
+					currentVersion, err := semver.Parse(clusterInfo.VizierVersion)
+					if err != nil {
+						return err
+					}
+
+					if currentVersion.Compare(updateVersion) == 0 {
+						return nil
+					}
+				}
+			case <-timeout.C:
+				return errors.New("timeout waiting for update")
+			}
+		}
+	}
+}
+
+// waitForHealthyPEMsTaskGenerator waits for at least the expected number of PEMs to be running, which is the number
+// of PEMs that were running before the update.
+func waitForHealthyPEMsTaskGenerator(cloudAddr string, clusterID uuid.UUID, expectedPEMs int32, healthTimeout time.Duration) func() error {
+	return func() error {
+		timer := time.NewTicker(5 * time.Second)
+		timeout := time.NewTimer(healthTimeout)
+		defer timer.Stop()
+		defer timeout.Stop()
+		var healthyPEMs int32
+		for {
+			select {
+			case <-timer.C:
+				clusterInfo, err := vizier.GetVizierInfo(cloudAddr, clusterID)
+				if err != nil {
+					return err
+				}
+				healthyPEMs = clusterInfo.NumInstrumentedNodes
+				if clusterInfo.Status == cloudpb.CS_HEALTHY && healthyPEMs >= expectedPEMs {
+					return nil
+				}
+			case <-timeout.C:
+				return fmt.Errorf("timeout waiting for PEMs to become healthy: %d of %d healthy", healthyPEMs, expectedPEMs)
+			}
+		}
+	}
+}
+
+// getOperatorVersion returns the version of the Vizier operator running in the cluster, or an empty string if the
+// operator is not deployed.
+func getOperatorVersion(clientset *kubernetes.Clientset) (string, error) {
+	deployments, err := clientset.AppsV1().Deployments("").List(context.Background(), metav1.ListOptions{
+		FieldSelector: "metadata.name=" + operatorDeploymentName,
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, d := range deployments.Items {
+		for _, c := range d.Spec.Template.Spec.Containers {
+			if idx := strings.LastIndex(c.Image, ":"); idx != -1 {
+				return c.Image[idx+1:], nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// fetchVizierYAMLs fetches the Vizier YAMLs for the given version, filled in with the cluster's values.
+func fetchVizierYAMLs(cloudConn *grpc.ClientConn, cloudAddr string, clusterInfo *cloudpb.ClusterInfo, versionString string) ([]*yamlsutils.YAMLFile, error) {
+	creds := auth.MustLoadDefaultCredentials()
+	templatedYAMLs, err := artifacts.FetchVizierTemplates(cloudConn, creds.Token, versionString)
+	if err != nil {
+		return nil, err
+	}
+	// Values which don't depend on the version are only placeholders, since they are the same for both versions
+	// being compared.
+	tmplValues := &vizieryamls.VizierTmplValues{
+		DeployKey:       "<deploy-key>",
+		CloudAddr:       cloudAddr,
+		CloudUpdateAddr: cloudAddr,
+		ClusterName:     clusterInfo.ClusterName,
+		Namespace:       "pl",
+	}
+	return yamlsutils.ExecuteTemplatedYAMLs(templatedYAMLs, vizieryamls.VizierTmplValuesToArgs(tmplValues))
+}
+
+// getVizierPlan computes the changes made by updating the Vizier to the given version.
+func getVizierPlan(cloudConn *grpc.ClientConn, cloudAddr string, clusterInfo *cloudpb.ClusterInfo, versionString string) (*update.VizierPlan, error) {
+	plan := &update.VizierPlan{
+		CurrentVersion:   clusterInfo.VizierVersion,
+		TargetVersion:    versionString,
+		CLIVersion:       version.GetVersion().Semver().String(),
+		LatestCLIVersion: update.UpdatesAvailable(cloudAddr),
+	}
+
+	latestOperatorVersion, err := getLatestOperatorVersion(cloudConn)
+	if err != nil {
+		utils.WithError(err).Error("Failed to fetch the latest operator version")
+	}
+	plan.LatestOperatorVersion = latestOperatorVersion
+
+	// The operator can only be found if the kubeconfig points at the cluster being updated.
+	clusterID := utils2.UUIDFromProtoOrNil(clusterInfo.ID)
+	if config, err := k8s.TryGetConfig(); err == nil && vizier.GetClusterIDFromKubeConfig(config) == clusterID {
+		plan.OperatorVersion, err = getOperatorVersion(k8s.GetClientset(config))
+		if err != nil {
+			utils.WithError(err).Error("Failed to get the operator version")
+		}
+	} else {
+		utils.Info("The current kubeconfig does not point at the cluster, skipping the operator check")
+	}
+
+	if _, err := semver.Parse(plan.CurrentVersion); err != nil {
+		utils.Infof("Cannot compare resources with unreleased version %s", plan.CurrentVersion)
+		return plan, nil
+	}
+	currentYAMLs, err := fetchVizierYAMLs(cloudConn, cloudAddr, clusterInfo, plan.CurrentVersion)
+	if err != nil {
+		return nil, err
+	}
+	targetYAMLs, err := fetchVizierYAMLs(cloudConn, cloudAddr, clusterInfo, plan.TargetVersion)
+	if err != nil {
+		return nil, err
+	}
+	plan.Changes, err = update.DiffVizierResources(currentYAMLs, targetYAMLs)
+	if err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+func printVizierPlan(clusterInfo *cloudpb.ClusterInfo, plan *update.VizierPlan) {
+	operatorVersion := plan.OperatorVersion
+	if operatorVersion == "" {
+		operatorVersion = "<none>"
+	}
+	fmt.Fprintf(os.Stderr, "Cluster:          %s\n", clusterInfo.PrettyClusterName)
+	fmt.Fprintf(os.Stderr, "Current version:  %s\n", plan.CurrentVersion)
+	fmt.Fprintf(os.Stderr, "Target version:   %s\n", plan.TargetVersion)
+	fmt.Fprintf(os.Stderr, "CLI version:      %s\n", plan.CLIVersion)
+	fmt.Fprintf(os.Stderr, "Operator version: %s\n\n", operatorVersion)
+
+	w := components.CreateStreamWriter("table", os.Stdout)
+	w.SetHeader("vizier_update_plan", []string{"Change", "Kind", "Name", "Images"})
+	for _, c := range plan.Changes {
+		_ = w.Write([]interface{}{c.Change, c.Kind, c.Name, strings.Join(c.ImageChanges, "\n")})
+	}
+	w.Finish()
+
+	issues := plan.CompatibilityIssues()
+	if len(issues) == 0 {
+		utils.Info("No compatibility issues found")
+		return
+	}
+	for _, issue := range issues {
+		utils.Error(issue)
+	}
+}
+
 // CLIUpdateCmd is the cli subcommand of the "update" command.
 var CLIUpdateCmd = &cobra.Command{
 	Use:   "cli",
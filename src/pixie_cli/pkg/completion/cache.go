@@ -0,0 +1,125 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package completion
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type cacheEntry struct {
+	UpdatedAt time.Time       `json:"updatedAt"`
+	Value     json.RawMessage `json:"value"`
+}
+
+// Cache is a file backed cache of completion results. Shell completion runs a new px process on every
+// keypress, so results which require a call to the cloud or a Vizier are cached across invocations.
+type Cache struct {
+	dir string
+	now func() time.Time
+}
+
+// NewCache creates a cache which stores its entries in the given directory.
+func NewCache(dir string) *Cache {
+	return &Cache{
+		dir: dir,
+		now: time.Now,
+	}
+}
+
+// DefaultCacheDir returns the default directory for the completion cache.
+func DefaultCacheDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".pixie", "completion_cache"), nil
+}
+
+func (c *Cache) path(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(h[:])+".json")
+}
+
+func (c *Cache) read(key string) (*cacheEntry, error) {
+	b, err := ioutil.ReadFile(c.path(key))
+	if err != nil {
+		return nil, err
+	}
+	entry := &cacheEntry{}
+	if err := json.Unmarshal(b, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (c *Cache) write(key string, entry *cacheEntry) error {
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return err
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first, so that concurrent completions never read a partial entry.
+	f, err := ioutil.TempFile(c.dir, "tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), c.path(key))
+}
+
+// Get loads the cached value for key into v. If there is no cached value, or it is older than ttl, fetch is
+// called to refresh it. If fetch fails, a stale cached value is used if one exists.
+func (c *Cache) Get(key string, ttl time.Duration, v interface{}, fetch func() (interface{}, error)) error {
+	entry, readErr := c.read(key)
+	if readErr == nil && c.now().Sub(entry.UpdatedAt) < ttl {
+		return json.Unmarshal(entry.Value, v)
+	}
+
+	val, err := fetch()
+	if err != nil {
+		if readErr == nil {
+			return json.Unmarshal(entry.Value, v)
+		}
+		return err
+	}
+
+	b, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+	// Failing to write the cache only makes the next completion slower.
+	_ = c.write(key, &cacheEntry{UpdatedAt: c.now(), Value: b})
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,93 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package completion
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCache_Get(t *testing.T) {
+	dir, err := ioutil.TempDir("", "completion_cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Unix(1000, 0)
+	c := NewCache(dir)
+	c.now = func() time.Time { return now }
+
+	fetches := 0
+	fetch := func() (interface{}, error) {
+		fetches++
+		return []string{"a", "b"}, nil
+	}
+
+	var vals []string
+	require.NoError(t, c.Get("key", time.Minute, &vals, fetch))
+	assert.Equal(t, []string{"a", "b"}, vals)
+	assert.Equal(t, 1, fetches)
+
+	// A fresh entry is read from the cache.
+	now = now.Add(30 * time.Second)
+	vals = nil
+	require.NoError(t, c.Get("key", time.Minute, &vals, fetch))
+	assert.Equal(t, []string{"a", "b"}, vals)
+	assert.Equal(t, 1, fetches)
+
+	// A stale entry is refreshed.
+	now = now.Add(time.Minute)
+	require.NoError(t, c.Get("key", time.Minute, &vals, func() (interface{}, error) {
+		fetches++
+		return []string{"c"}, nil
+	}))
+	assert.Equal(t, []string{"c"}, vals)
+	assert.Equal(t, 2, fetches)
+}
+
+func TestCache_GetStaleOnError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "completion_cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	now := time.Unix(1000, 0)
+	c := NewCache(dir)
+	c.now = func() time.Time { return now }
+
+	var vals []string
+	require.NoError(t, c.Get("key", time.Minute, &vals, func() (interface{}, error) {
+		return []string{"a"}, nil
+	}))
+
+	now = now.Add(time.Hour)
+	failingFetch := func() (interface{}, error) {
+		return nil, errors.New("cloud unavailable")
+	}
+	vals = nil
+	require.NoError(t, c.Get("key", time.Minute, &vals, failingFetch))
+	assert.Equal(t, []string{"a"}, vals)
+
+	// Without a cached value the error is returned.
+	assert.Error(t, c.Get("other", time.Minute, &vals, failingFetch))
+}
@@ -0,0 +1,243 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package diagnose
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+
+	"px.dev/pixie/src/utils/shared/k8s"
+)
+
+const (
+	// AgentStatusFile is the name of the file in the log bundle containing the agent registration state.
+	AgentStatusFile = "agent_status.json"
+	// TableSchemasFile is the name of the file in the log bundle containing the table schemas.
+	TableSchemasFile = "table_schemas.json"
+	// TableInfoFile is the name of the file in the log bundle containing the table info for each agent.
+	TableInfoFile = "table_info.json"
+)
+
+// AgentStatus is the registration state of an agent, as reported by the metadata service.
+type AgentStatus struct {
+	AgentID         string `json:"agent_id"`
+	ASID            int64  `json:"asid"`
+	Hostname        string `json:"hostname"`
+	IPAddress       string `json:"ip_address"`
+	AgentState      string `json:"agent_state"`
+	LastHeartbeatNS int64  `json:"last_heartbeat_ns"`
+}
+
+// VizierStatus is the subset of the Vizier CRD status that is used for diagnosis.
+type VizierStatus struct {
+	Name        string
+	Version     string
+	VizierPhase string
+	Message     string
+}
+
+// Bundle is a log bundle created by `px collect-logs`.
+type Bundle struct {
+	// CollectedAt is the time at which the bundle was created.
+	CollectedAt time.Time
+
+	Pods    []v1.Pod
+	Nodes   []k8s.NodeInfo
+	Certs   []k8s.CertInfo
+	Agents  []AgentStatus
+	Viziers []VizierStatus
+
+	files map[string][]byte
+}
+
+// OpenBundle reads the log bundle at the given path.
+func OpenBundle(fName string) (*Bundle, error) {
+	f, err := os.Open(fName)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return NewBundle(f, stat.Size(), stat.ModTime())
+}
+
+// NewBundle reads a log bundle from the given zip reader.
+func NewBundle(r io.ReaderAt, size int64, collectedAt time.Time) (*Bundle, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bundle{
+		CollectedAt: collectedAt,
+		files:       make(map[string][]byte),
+	}
+	for _, zf := range zr.File {
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, err
+		}
+		contents, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		b.files[zf.Name] = contents
+	}
+
+	if err := b.parse(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (b *Bundle) parse() error {
+	for _, name := range b.FileNames() {
+		if !strings.HasPrefix(name, "pod_") || !strings.HasSuffix(name, "_describe.json") {
+			continue
+		}
+		pod := v1.Pod{}
+		if err := json.Unmarshal(b.files[name], &pod); err != nil {
+			return err
+		}
+		b.Pods = append(b.Pods, pod)
+	}
+
+	if contents, ok := b.files[k8s.NodeInfoFile]; ok {
+		if err := json.Unmarshal(contents, &b.Nodes); err != nil {
+			return err
+		}
+	}
+
+	if contents, ok := b.files[k8s.CertInfoFile]; ok {
+		if err := json.Unmarshal(contents, &b.Certs); err != nil {
+			return err
+		}
+	}
+
+	// The agent status is written as one JSON record per row. It is collected on a best effort basis, so
+	// a truncated file is not an error.
+	dec := json.NewDecoder(bytes.NewReader(b.files[AgentStatusFile]))
+	for dec.More() {
+		agent := AgentStatus{}
+		if err := dec.Decode(&agent); err != nil {
+			log.WithError(err).Warnf("Failed to parse %s", AgentStatusFile)
+			break
+		}
+		b.Agents = append(b.Agents, agent)
+	}
+
+	if contents, ok := b.files[k8s.VizierCRDFile]; ok && len(contents) > 0 {
+		vzList := struct {
+			Items []struct {
+				Metadata struct {
+					Name string `json:"name"`
+				} `json:"metadata"`
+				Status struct {
+					Version     string `json:"version"`
+					VizierPhase string `json:"vizierPhase"`
+					Message     string `json:"message"`
+				} `json:"status"`
+			} `json:"items"`
+		}{}
+		if err := json.Unmarshal(contents, &vzList); err != nil {
+			return err
+		}
+		for _, vz := range vzList.Items {
+			b.Viziers = append(b.Viziers, VizierStatus{
+				Name:        vz.Metadata.Name,
+				Version:     vz.Status.Version,
+				VizierPhase: vz.Status.VizierPhase,
+				Message:     vz.Status.Message,
+			})
+		}
+	}
+	return nil
+}
+
+// FileNames returns the sorted names of all files in the bundle.
+func (b *Bundle) FileNames() []string {
+	names := make([]string, 0, len(b.files))
+	for name := range b.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// File returns the contents of the named file in the bundle.
+func (b *Bundle) File(name string) ([]byte, bool) {
+	contents, ok := b.files[name]
+	return contents, ok
+}
+
+// PodLogs returns the names of the log files for the given pod.
+func (b *Bundle) PodLogs(podName string) []string {
+	var logs []string
+	for _, name := range b.FileNames() {
+		if strings.HasPrefix(name, podName+"__") && strings.HasSuffix(name, ".log") {
+			logs = append(logs, name)
+		}
+	}
+	return logs
+}
+
+// GrepPodLogs returns the lines in the logs of the given pod which contain any of the patterns.
+func (b *Bundle) GrepPodLogs(podName string, patterns ...string) []string {
+	var matches []string
+	for _, name := range b.PodLogs(podName) {
+		scanner := bufio.NewScanner(bytes.NewReader(b.files[name]))
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			for _, p := range patterns {
+				if strings.Contains(line, p) {
+					matches = append(matches, line)
+					break
+				}
+			}
+		}
+	}
+	return matches
+}
+
+// Node returns the info for the named node, if it exists in the bundle.
+func (b *Bundle) Node(name string) *k8s.NodeInfo {
+	for i := range b.Nodes {
+		if b.Nodes[i].Name == name {
+			return &b.Nodes[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,386 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package diagnose
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+
+	"px.dev/pixie/src/pixie_cli/pkg/utils"
+)
+
+const (
+	pemPodPrefix = "vizier-pem"
+	// A container that has restarted at least this many times is considered to be crash looping.
+	crashLoopRestartThreshold = 5
+	// Certificates expiring within this duration of the bundle collection are flagged.
+	certExpiryWarningWindow = 30 * 24 * time.Hour
+	kernelMinVersion        = "4.14.0"
+	agentStateHealthy       = "AGENT_STATE_HEALTHY"
+)
+
+// Log lines emitted by the PEM when it cannot find or install linux headers.
+var missingKernelHeadersPatterns = []string{
+	"Could not find any linux headers to use",
+	"Could not find packaged headers to install",
+}
+
+// Pods for the dependencies of the Vizier control plane.
+var controlPlaneDepPrefixes = map[string]string{
+	"pl-nats": "NATS",
+	"pl-etcd": "etcd",
+}
+
+// Severity is the severity of a finding.
+type Severity int
+
+const (
+	// SeverityInfo is used for findings which are unlikely to cause problems.
+	SeverityInfo Severity = iota
+	// SeverityWarning is used for findings which may cause problems.
+	SeverityWarning
+	// SeverityError is used for findings which are likely to be a root cause.
+	SeverityError
+)
+
+// String returns the string representation of the severity.
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "ERROR"
+	case SeverityWarning:
+		return "WARNING"
+	default:
+		return "INFO"
+	}
+}
+
+// Finding is a likely cause of a problem found in a log bundle.
+type Finding struct {
+	Rule     string
+	Severity Severity
+	Summary  string
+	Details  []string
+}
+
+// Rule checks a log bundle for a class of problems.
+type Rule interface {
+	// Name returns the name of the rule.
+	Name() string
+	// Check returns the findings for the given bundle.
+	Check(b *Bundle) []*Finding
+}
+
+type ruleFunc struct {
+	name  string
+	check func(b *Bundle) []*Finding
+}
+
+func (r *ruleFunc) Name() string {
+	return r.name
+}
+
+func (r *ruleFunc) Check(b *Bundle) []*Finding {
+	findings := r.check(b)
+	for _, f := range findings {
+		f.Rule = r.name
+	}
+	return findings
+}
+
+// NewRule creates a rule from the given check function.
+func NewRule(name string, check func(b *Bundle) []*Finding) Rule {
+	return &ruleFunc{name: name, check: check}
+}
+
+// DefaultRules are the rules run by `px diagnose`.
+var DefaultRules = []Rule{
+	NewRule("crash-loop", checkCrashLoops),
+	NewRule("oom-killed", checkOOMKills),
+	NewRule("kernel-headers", checkKernelHeaders),
+	NewRule("kernel-version", checkKernelVersions),
+	NewRule("cert-expiry", checkCertExpiry),
+	NewRule("control-plane-deps", checkControlPlaneDeps),
+	NewRule("agent-state", checkAgentState),
+	NewRule("vizier-status", checkVizierStatus),
+}
+
+// Diagnose runs the rules over the bundle and returns the findings, most severe first.
+func Diagnose(b *Bundle, rules []Rule) []*Finding {
+	var findings []*Finding
+	for _, r := range rules {
+		findings = append(findings, r.Check(b)...)
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Severity > findings[j].Severity
+	})
+	return findings
+}
+
+func isPEM(pod *v1.Pod) bool {
+	return strings.HasPrefix(pod.Name, pemPodPrefix)
+}
+
+func checkCrashLoops(b *Bundle) []*Finding {
+	var findings []*Finding
+	for i := range b.Pods {
+		pod := &b.Pods[i]
+		for _, cs := range pod.Status.ContainerStatuses {
+			crashLooping := cs.State.Waiting != nil && cs.State.Waiting.Reason == "CrashLoopBackOff"
+			if !crashLooping && cs.RestartCount < crashLoopRestartThreshold {
+				continue
+			}
+
+			details := []string{fmt.Sprintf("restart count: %d", cs.RestartCount)}
+			if t := cs.LastTerminationState.Terminated; t != nil {
+				details = append(details, fmt.Sprintf("last termination: reason=%s exit_code=%d", t.Reason, t.ExitCode))
+			}
+			severity := SeverityWarning
+			if crashLooping || isPEM(pod) {
+				severity = SeverityError
+			}
+			findings = append(findings, &Finding{
+				Severity: severity,
+				Summary:  fmt.Sprintf("Container %s in pod %s is crash looping", cs.Name, pod.Name),
+				Details:  details,
+			})
+		}
+	}
+	return findings
+}
+
+func checkOOMKills(b *Bundle) []*Finding {
+	var findings []*Finding
+	for i := range b.Pods {
+		pod := &b.Pods[i]
+		for _, cs := range pod.Status.ContainerStatuses {
+			oomKilled := false
+			for _, t := range []*v1.ContainerStateTerminated{cs.State.Terminated, cs.LastTerminationState.Terminated} {
+				if t != nil && t.Reason == "OOMKilled" {
+					oomKilled = true
+				}
+			}
+			if !oomKilled {
+				continue
+			}
+
+			var details []string
+			for _, c := range pod.Spec.Containers {
+				if c.Name == cs.Name {
+					if limit, ok := c.Resources.Limits[v1.ResourceMemory]; ok {
+						details = append(details, fmt.Sprintf("memory limit: %s", limit.String()))
+					}
+				}
+			}
+			if isPEM(pod) {
+				details = append(details, "consider raising the PEM memory limit (--pem_memory_limit)")
+			}
+			findings = append(findings, &Finding{
+				Severity: SeverityError,
+				Summary:  fmt.Sprintf("Container %s in pod %s was OOM killed", cs.Name, pod.Name),
+				Details:  details,
+			})
+		}
+	}
+	return findings
+}
+
+func checkKernelHeaders(b *Bundle) []*Finding {
+	var findings []*Finding
+	for i := range b.Pods {
+		pod := &b.Pods[i]
+		if !isPEM(pod) {
+			continue
+		}
+		matches := b.GrepPodLogs(pod.Name, missingKernelHeadersPatterns...)
+		if len(matches) == 0 {
+			continue
+		}
+
+		details := []string{fmt.Sprintf("node: %s", pod.Spec.NodeName)}
+		if node := b.Node(pod.Spec.NodeName); node != nil {
+			details = append(details,
+				fmt.Sprintf("kernel: %s", node.SystemInfo.KernelVersion),
+				fmt.Sprintf("os image: %s", node.SystemInfo.OSImage))
+		}
+		details = append(details, "install the linux headers for the running kernel on the node")
+		findings = append(findings, &Finding{
+			Severity: SeverityError,
+			Summary:  fmt.Sprintf("PEM %s could not find linux headers", pod.Name),
+			Details:  details,
+		})
+	}
+	return findings
+}
+
+func checkKernelVersions(b *Bundle) []*Finding {
+	var findings []*Finding
+	for _, node := range b.Nodes {
+		compatible, err := utils.VersionCompatible(node.SystemInfo.KernelVersion, kernelMinVersion)
+		if err != nil {
+			findings = append(findings, &Finding{
+				Severity: SeverityInfo,
+				Summary:  fmt.Sprintf("Could not parse kernel version of node %s", node.Name),
+				Details:  []string{fmt.Sprintf("kernel: %s", node.SystemInfo.KernelVersion)},
+			})
+			continue
+		}
+		if !compatible {
+			findings = append(findings, &Finding{
+				Severity: SeverityError,
+				Summary:  fmt.Sprintf("Kernel version of node %s is not supported", node.Name),
+				Details: []string{
+					fmt.Sprintf("kernel: %s", node.SystemInfo.KernelVersion),
+					fmt.Sprintf("minimum supported kernel: %s", kernelMinVersion),
+				},
+			})
+		}
+	}
+	return findings
+}
+
+func checkCertExpiry(b *Bundle) []*Finding {
+	var findings []*Finding
+	for _, cert := range b.Certs {
+		name := fmt.Sprintf("%s/%s", cert.Secret, cert.Key)
+		details := []string{
+			fmt.Sprintf("subject: %s", cert.Subject),
+			fmt.Sprintf("valid: %s - %s", cert.NotBefore.Format(time.RFC3339), cert.NotAfter.Format(time.RFC3339)),
+		}
+		switch {
+		case b.CollectedAt.After(cert.NotAfter):
+			findings = append(findings, &Finding{
+				Severity: SeverityError,
+				Summary:  fmt.Sprintf("Certificate %s has expired", name),
+				Details:  details,
+			})
+		case b.CollectedAt.Before(cert.NotBefore):
+			findings = append(findings, &Finding{
+				Severity: SeverityError,
+				Summary:  fmt.Sprintf("Certificate %s is not yet valid, check for clock skew", name),
+				Details:  details,
+			})
+		case b.CollectedAt.Add(certExpiryWarningWindow).After(cert.NotAfter):
+			findings = append(findings, &Finding{
+				Severity: SeverityWarning,
+				Summary:  fmt.Sprintf("Certificate %s expires soon", name),
+				Details:  details,
+			})
+		}
+	}
+	return findings
+}
+
+func podReady(pod *v1.Pod) bool {
+	if pod.Status.Phase != v1.PodRunning {
+		return false
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type == v1.PodReady {
+			return c.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+func checkControlPlaneDeps(b *Bundle) []*Finding {
+	var findings []*Finding
+	for i := range b.Pods {
+		pod := &b.Pods[i]
+		for prefix, dep := range controlPlaneDepPrefixes {
+			if !strings.HasPrefix(pod.Name, prefix) || podReady(pod) {
+				continue
+			}
+			details := []string{fmt.Sprintf("phase: %s", pod.Status.Phase)}
+			if pod.Status.Message != "" {
+				details = append(details, fmt.Sprintf("message: %s", pod.Status.Message))
+			}
+			findings = append(findings, &Finding{
+				Severity: SeverityError,
+				Summary:  fmt.Sprintf("%s pod %s is not ready", dep, pod.Name),
+				Details:  details,
+			})
+		}
+	}
+	return findings
+}
+
+func checkAgentState(b *Bundle) []*Finding {
+	var findings []*Finding
+	for _, agent := range b.Agents {
+		if agent.AgentState == agentStateHealthy {
+			continue
+		}
+		lastHeartbeat := time.Duration(agent.LastHeartbeatNS)
+		findings = append(findings, &Finding{
+			Severity: SeverityWarning,
+			Summary:  fmt.Sprintf("Agent on %s is %s", agent.Hostname, agent.AgentState),
+			Details: []string{
+				fmt.Sprintf("agent: %s", agent.AgentID),
+				fmt.Sprintf("last heartbeat: %s ago", lastHeartbeat.Round(time.Second)),
+			},
+		})
+	}
+
+	// The agent status is collected on a best effort basis, skip the registration check if it is missing.
+	if len(b.Agents) == 0 {
+		return findings
+	}
+	// Every running PEM should have registered an agent.
+	registered := make(map[string]bool)
+	for _, agent := range b.Agents {
+		registered[agent.Hostname] = true
+	}
+	for i := range b.Pods {
+		pod := &b.Pods[i]
+		if !isPEM(pod) || pod.Status.Phase != v1.PodRunning {
+			continue
+		}
+		if !registered[pod.Spec.NodeName] && !registered[pod.Name] {
+			findings = append(findings, &Finding{
+				Severity: SeverityError,
+				Summary:  fmt.Sprintf("PEM %s is running but has not registered with the metadata service", pod.Name),
+				Details:  []string{fmt.Sprintf("node: %s", pod.Spec.NodeName)},
+			})
+		}
+	}
+	return findings
+}
+
+func checkVizierStatus(b *Bundle) []*Finding {
+	var findings []*Finding
+	for _, vz := range b.Viziers {
+		if vz.VizierPhase != "Failed" {
+			continue
+		}
+		findings = append(findings, &Finding{
+			Severity: SeverityError,
+			Summary:  fmt.Sprintf("Vizier %s failed to deploy", vz.Name),
+			Details: []string{
+				fmt.Sprintf("version: %s", vz.Version),
+				fmt.Sprintf("message: %s", vz.Message),
+			},
+		})
+	}
+	return findings
+}
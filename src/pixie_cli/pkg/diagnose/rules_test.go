@@ -0,0 +1,174 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package diagnose_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"px.dev/pixie/src/pixie_cli/pkg/diagnose"
+	"px.dev/pixie/src/utils/shared/k8s"
+)
+
+var collectedAt = time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func makeBundle(t *testing.T, files map[string]interface{}) *diagnose.Bundle {
+	buf := &bytes.Buffer{}
+	zf := zip.NewWriter(buf)
+	for name, contents := range files {
+		w, err := zf.Create(name)
+		require.NoError(t, err)
+		switch c := contents.(type) {
+		case string:
+			_, err = w.Write([]byte(c))
+		default:
+			err = json.NewEncoder(w).Encode(c)
+		}
+		require.NoError(t, err)
+	}
+	require.NoError(t, zf.Close())
+
+	b, err := diagnose.NewBundle(bytes.NewReader(buf.Bytes()), int64(buf.Len()), collectedAt)
+	require.NoError(t, err)
+	return b
+}
+
+func makePod(name string, node string, statuses ...v1.ContainerStatus) v1.Pod {
+	return v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       v1.PodSpec{NodeName: node},
+		Status: v1.PodStatus{
+			Phase:             v1.PodRunning,
+			Conditions:        []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+			ContainerStatuses: statuses,
+		},
+	}
+}
+
+func podFile(name string) string {
+	return fmt.Sprintf("pod_%s_describe.json", name)
+}
+
+func runRule(b *diagnose.Bundle, name string) []*diagnose.Finding {
+	for _, r := range diagnose.DefaultRules {
+		if r.Name() == name {
+			return r.Check(b)
+		}
+	}
+	return nil
+}
+
+func TestHealthyBundle(t *testing.T) {
+	b := makeBundle(t, map[string]interface{}{
+		podFile("vizier-pem-abcd"):      makePod("vizier-pem-abcd", "node-1", v1.ContainerStatus{Name: "pem"}),
+		"vizier-pem-abcd__pem.log":      "Using linux headers found at /usr/src/linux-headers-5.4.0 for BCC runtime.\n",
+		podFile("pl-nats-1"):            makePod("pl-nats-1", "node-1", v1.ContainerStatus{Name: "pl-nats"}),
+		k8s.NodeInfoFile:                []k8s.NodeInfo{{Name: "node-1", SystemInfo: v1.NodeSystemInfo{KernelVersion: "5.4.0-1036-gke"}}},
+		diagnose.AgentStatusFile:        `{"_tableName_":"agents","hostname":"node-1","agent_state":"AGENT_STATE_HEALTHY"}`,
+		k8s.CertInfoFile:                []k8s.CertInfo{{Secret: "service-tls-certs", Key: "server.crt", NotBefore: collectedAt.Add(-time.Hour), NotAfter: collectedAt.Add(365 * 24 * time.Hour)}},
+		"vizier-pem-abcd__pem.prev.log": "",
+	})
+
+	assert.Equal(t, 1, len(b.Agents))
+	assert.Equal(t, 2, len(b.Pods))
+	assert.Empty(t, diagnose.Diagnose(b, diagnose.DefaultRules))
+}
+
+func TestCrashLoopingPEM(t *testing.T) {
+	b := makeBundle(t, map[string]interface{}{
+		podFile("vizier-pem-abcd"): makePod("vizier-pem-abcd", "node-1", v1.ContainerStatus{
+			Name:         "pem",
+			RestartCount: 12,
+			State: v1.ContainerState{
+				Waiting: &v1.ContainerStateWaiting{Reason: "CrashLoopBackOff"},
+			},
+			LastTerminationState: v1.ContainerState{
+				Terminated: &v1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137},
+			},
+		}),
+	})
+
+	findings := runRule(b, "crash-loop")
+	require.Equal(t, 1, len(findings))
+	assert.Equal(t, diagnose.SeverityError, findings[0].Severity)
+	assert.Equal(t, "crash-loop", findings[0].Rule)
+	assert.Contains(t, findings[0].Details, "last termination: reason=OOMKilled exit_code=137")
+
+	findings = runRule(b, "oom-killed")
+	require.Equal(t, 1, len(findings))
+	assert.Equal(t, "Container pem in pod vizier-pem-abcd was OOM killed", findings[0].Summary)
+}
+
+func TestMissingKernelHeaders(t *testing.T) {
+	b := makeBundle(t, map[string]interface{}{
+		podFile("vizier-pem-abcd"): makePod("vizier-pem-abcd", "node-1", v1.ContainerStatus{Name: "pem"}),
+		"vizier-pem-abcd__pem.log": "I0601 Attempting to install packaged headers.\nE0601 Could not find any linux headers to use.\n",
+		k8s.NodeInfoFile:           []k8s.NodeInfo{{Name: "node-1", SystemInfo: v1.NodeSystemInfo{KernelVersion: "4.9.0"}}},
+	})
+
+	findings := runRule(b, "kernel-headers")
+	require.Equal(t, 1, len(findings))
+	assert.Contains(t, findings[0].Details, "kernel: 4.9.0")
+
+	findings = runRule(b, "kernel-version")
+	require.Equal(t, 1, len(findings))
+	assert.Equal(t, diagnose.SeverityError, findings[0].Severity)
+}
+
+func TestCertExpiry(t *testing.T) {
+	b := makeBundle(t, map[string]interface{}{
+		k8s.CertInfoFile: []k8s.CertInfo{
+			{Secret: "a", Key: "server.crt", NotBefore: collectedAt.Add(-time.Hour), NotAfter: collectedAt.Add(-time.Minute)},
+			{Secret: "b", Key: "server.crt", NotBefore: collectedAt.Add(-time.Hour), NotAfter: collectedAt.Add(24 * time.Hour)},
+			{Secret: "c", Key: "server.crt", NotBefore: collectedAt.Add(time.Hour), NotAfter: collectedAt.Add(365 * 24 * time.Hour)},
+		},
+	})
+
+	findings := diagnose.Diagnose(b, diagnose.DefaultRules)
+	require.Equal(t, 3, len(findings))
+	assert.Equal(t, "Certificate a/server.crt has expired", findings[0].Summary)
+	assert.Equal(t, "Certificate c/server.crt is not yet valid, check for clock skew", findings[1].Summary)
+	assert.Equal(t, "Certificate b/server.crt expires soon", findings[2].Summary)
+	assert.Equal(t, diagnose.SeverityWarning, findings[2].Severity)
+}
+
+func TestUnregisteredPEM(t *testing.T) {
+	b := makeBundle(t, map[string]interface{}{
+		podFile("vizier-pem-abcd"): makePod("vizier-pem-abcd", "node-1", v1.ContainerStatus{Name: "pem"}),
+		podFile("vizier-pem-efgh"): makePod("vizier-pem-efgh", "node-2", v1.ContainerStatus{Name: "pem"}),
+		diagnose.AgentStatusFile: `{"_tableName_":"agents","hostname":"node-1","agent_state":"AGENT_STATE_HEALTHY"}
+{"_tableName_":"agents","hostname":"kelvin","agent_state":"AGENT_STATE_UNRESPONSIVE","last_heartbeat_ns":60000000000}
+`,
+	})
+
+	findings := diagnose.Diagnose(b, diagnose.DefaultRules)
+	require.Equal(t, 2, len(findings))
+	assert.Equal(t, "PEM vizier-pem-efgh is running but has not registered with the metadata service", findings[0].Summary)
+	assert.Equal(t, "Agent on kelvin is AGENT_STATE_UNRESPONSIVE", findings[1].Summary)
+	assert.Contains(t, findings[1].Details, "last heartbeat: 1m0s ago")
+}
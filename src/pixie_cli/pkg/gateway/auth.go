@@ -0,0 +1,112 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// APIKeyHeader is the metadata key clients use to send their gateway API key.
+const APIKeyHeader = "pixie-api-key"
+
+// APIKeyAuthenticator authenticates gateway clients using a static set of API keys.
+type APIKeyAuthenticator struct {
+	// Keys are stored hashed, mapping to the name of the key's owner.
+	keys map[[sha256.Size]byte]string
+}
+
+// NewAPIKeyAuthenticator creates an authenticator from a map of API key to key name.
+func NewAPIKeyAuthenticator(keys map[string]string) *APIKeyAuthenticator {
+	a := &APIKeyAuthenticator{
+		keys: make(map[[sha256.Size]byte]string, len(keys)),
+	}
+	for key, name := range keys {
+		a.keys[sha256.Sum256([]byte(key))] = name
+	}
+	return a
+}
+
+// LoadAPIKeys parses API keys from a reader. Each non-empty line is of the form
+// "name:key". Lines starting with '#' are ignored.
+func LoadAPIKeys(r io.Reader) (map[string]string, error) {
+	keys := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: expected 'name:key'", lineNum)
+		}
+		name := strings.TrimSpace(parts[0])
+		key := strings.TrimSpace(parts[1])
+		if name == "" || key == "" {
+			return nil, fmt.Errorf("line %d: name and key must be non-empty", lineNum)
+		}
+		if _, ok := keys[key]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key for '%s'", lineNum, name)
+		}
+		keys[key] = name
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// LoadAPIKeysFile parses API keys from the given file. See LoadAPIKeys for the format.
+func LoadAPIKeysFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadAPIKeys(f)
+}
+
+// Authenticate checks the API key in the incoming context and returns the name of the matching key.
+func (a *APIKeyAuthenticator) Authenticate(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "missing API key")
+	}
+	vals := md.Get(APIKeyHeader)
+	if len(vals) == 0 || vals[0] == "" {
+		return "", status.Error(codes.Unauthenticated, "missing API key")
+	}
+	name, ok := a.keys[sha256.Sum256([]byte(vals[0]))]
+	if !ok {
+		return "", status.Error(codes.Unauthenticated, "invalid API key")
+	}
+	return name, nil
+}
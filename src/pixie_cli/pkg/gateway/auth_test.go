@@ -0,0 +1,77 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package gateway_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/pixie_cli/pkg/gateway"
+)
+
+func TestLoadAPIKeys(t *testing.T) {
+	keys, err := gateway.LoadAPIKeys(strings.NewReader(`
+# Team keys.
+alice:key-a
+bob: key-b
+`))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"key-a": "alice", "key-b": "bob"}, keys)
+}
+
+func TestLoadAPIKeys_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"missing separator", "alice"},
+		{"empty key", "alice:"},
+		{"empty name", ":key"},
+		{"duplicate key", "alice:key\nbob:key"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := gateway.LoadAPIKeys(strings.NewReader(test.input))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestAPIKeyAuthenticator(t *testing.T) {
+	a := gateway.NewAPIKeyAuthenticator(map[string]string{"key-a": "alice"})
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(gateway.APIKeyHeader, "key-a"))
+	name, err := a.Authenticate(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", name)
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs(gateway.APIKeyHeader, "key-b"))
+	_, err = a.Authenticate(ctx)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = a.Authenticate(context.Background())
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
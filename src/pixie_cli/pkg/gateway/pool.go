@@ -0,0 +1,242 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"golang.org/x/sync/singleflight"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/api/proto/cloudpb"
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/shared/services"
+	"px.dev/pixie/src/utils"
+)
+
+// upstreamTTL is how long a Vizier connection is reused. Vizier tokens issued by the cloud
+// are valid for an hour, so connections are refreshed well before they expire.
+const upstreamTTL = 30 * time.Minute
+
+// CredentialsFunc attaches the gateway's own credentials to an outgoing request.
+type CredentialsFunc func(ctx context.Context) context.Context
+
+// APIKeyCredentials returns credentials which authenticate using a Pixie API key.
+func APIKeyCredentials(apiKey string) CredentialsFunc {
+	return func(ctx context.Context) context.Context {
+		return metadata.AppendToOutgoingContext(ctx, APIKeyHeader, apiKey)
+	}
+}
+
+// BearerCredentials returns credentials which authenticate using a bearer token.
+func BearerCredentials(token string) CredentialsFunc {
+	return func(ctx context.Context) context.Context {
+		return metadata.AppendToOutgoingContext(ctx, "authorization", fmt.Sprintf("bearer %s", token))
+	}
+}
+
+type vizierUpstream struct {
+	// conn is only set when the upstream owns its connection (ie. direct mode).
+	conn      *grpc.ClientConn
+	vz        vizierpb.VizierServiceClient
+	creds     CredentialsFunc
+	expiresAt time.Time
+}
+
+func (u *vizierUpstream) ExecuteScript(ctx context.Context, req *vizierpb.ExecuteScriptRequest) (vizierpb.VizierService_ExecuteScriptClient, error) {
+	return u.vz.ExecuteScript(u.creds(ctx), req)
+}
+
+func (u *vizierUpstream) HealthCheck(ctx context.Context, req *vizierpb.HealthCheckRequest) (vizierpb.VizierService_HealthCheckClient, error) {
+	return u.vz.HealthCheck(u.creds(ctx), req)
+}
+
+func (u *vizierUpstream) close() {
+	if u.conn != nil {
+		_ = u.conn.Close()
+	}
+}
+
+// VizierPool is an UpstreamProvider which connects to Viziers through Pixie Cloud using a
+// single service credential. Connections are cached per cluster.
+type VizierPool struct {
+	cloudConn *grpc.ClientConn
+	vc        cloudpb.VizierClusterInfoClient
+	creds     CredentialsFunc
+	// skipVerifyInternal disables TLS verification for in-cluster (*.cluster.local) addresses,
+	// which are usually served with self-signed certificates.
+	skipVerifyInternal bool
+
+	// connecting deduplicates concurrent connection attempts to the same cluster, so that
+	// slow dials don't block requests for other clusters.
+	connecting singleflight.Group
+
+	mu        sync.Mutex
+	upstreams map[uuid.UUID]*vizierUpstream
+}
+
+// isInternalAddr returns whether the address points to a service inside a K8s cluster.
+func isInternalAddr(addr string) bool {
+	host := addr
+	if h, _, err := net.SplitHostPort(addr); err == nil {
+		host = h
+	}
+	return strings.HasSuffix(strings.TrimSuffix(host, "."), ".cluster.local")
+}
+
+func (p *VizierPool) dial(addr string) (*grpc.ClientConn, error) {
+	dialOpts, err := services.GetGRPCClientDialOptsServerSideTLS(p.skipVerifyInternal && isInternalAddr(addr))
+	if err != nil {
+		return nil, err
+	}
+	return grpc.Dial(addr, dialOpts...)
+}
+
+// NewVizierPool creates a VizierPool which talks to the cloud at cloudAddr. TLS certificates are
+// always verified, unless skipVerifyInternal is set and the address is inside the cluster.
+func NewVizierPool(cloudAddr string, creds CredentialsFunc, skipVerifyInternal bool) (*VizierPool, error) {
+	p := &VizierPool{
+		creds:              creds,
+		skipVerifyInternal: skipVerifyInternal,
+		upstreams:          make(map[uuid.UUID]*vizierUpstream),
+	}
+	conn, err := p.dial(cloudAddr)
+	if err != nil {
+		return nil, err
+	}
+	p.cloudConn = conn
+	p.vc = cloudpb.NewVizierClusterInfoClient(conn)
+	return p, nil
+}
+
+// cached returns the unexpired upstream for the cluster, if any.
+func (p *VizierPool) cached(clusterID uuid.UUID) (*vizierUpstream, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	u, ok := p.upstreams[clusterID]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().Before(u.expiresAt) {
+		return u, true
+	}
+	// Give in-flight streams on the expired connection time to finish before closing it.
+	time.AfterFunc(upstreamTTL, u.close)
+	delete(p.upstreams, clusterID)
+	return nil, false
+}
+
+// Get returns the upstream for the given cluster, connecting to it if necessary.
+func (p *VizierPool) Get(ctx context.Context, clusterID uuid.UUID) (Upstream, error) {
+	if u, ok := p.cached(clusterID); ok {
+		return u, nil
+	}
+
+	// The pool lock isn't held while connecting, concurrent requests for the same cluster share
+	// a single connection attempt instead.
+	v, err, _ := p.connecting.Do(clusterID.String(), func() (interface{}, error) {
+		if u, ok := p.cached(clusterID); ok {
+			return u, nil
+		}
+		u, err := p.connect(ctx, clusterID)
+		if err != nil {
+			return nil, err
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.upstreams[clusterID] = u
+		return u, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*vizierUpstream), nil
+}
+
+func (p *VizierPool) connect(ctx context.Context, clusterID uuid.UUID) (*vizierUpstream, error) {
+	ctx = p.creds(ctx)
+	resp, err := p.vc.GetClusterInfo(ctx, &cloudpb.GetClusterInfoRequest{
+		ID: utils.ProtoFromUUID(clusterID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Clusters) == 0 {
+		return nil, status.Errorf(codes.NotFound, "cluster '%s' not found", clusterID)
+	}
+	info := resp.Clusters[0]
+
+	if info.Config != nil && info.Config.PassthroughEnabled {
+		return &vizierUpstream{
+			vz:        vizierpb.NewVizierServiceClient(p.cloudConn),
+			creds:     p.creds,
+			expiresAt: time.Now().Add(upstreamTTL),
+		}, nil
+	}
+
+	ci, err := p.vc.GetClusterConnectionInfo(ctx, &cloudpb.GetClusterConnectionInfoRequest{
+		ID: utils.ProtoFromUUID(clusterID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(ci.Token) == 0 {
+		return nil, errors.New("invalid token received")
+	}
+	if len(ci.IPAddress) == 0 {
+		return nil, errors.New("missing Vizier URL, likely still initializing")
+	}
+	u, err := url.Parse(ci.IPAddress)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := p.dial(u.Host)
+	if err != nil {
+		return nil, err
+	}
+	return &vizierUpstream{
+		conn:      conn,
+		vz:        vizierpb.NewVizierServiceClient(conn),
+		creds:     BearerCredentials(ci.Token),
+		expiresAt: time.Now().Add(upstreamTTL),
+	}, nil
+}
+
+// Close closes all connections held by the pool.
+func (p *VizierPool) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id, u := range p.upstreams {
+		u.close()
+		delete(p.upstreams, id)
+	}
+	return p.cloudConn.Close()
+}
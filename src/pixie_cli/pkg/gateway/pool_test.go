@@ -0,0 +1,47 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package gateway
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsInternalAddr(t *testing.T) {
+	tests := []struct {
+		addr     string
+		internal bool
+	}{
+		{"vizier-proxy-service.pl.svc.cluster.local:51200", true},
+		{"vizier-proxy-service.pl.svc.cluster.local", true},
+		{"vizier-proxy-service.pl.svc.cluster.local.:51200", true},
+		{"withpixie.ai:443", false},
+		// Contains every character of "cluster.local", but isn't in the cluster.
+		{"work.withpixie.ai:443", false},
+		{"cluster.local.example.com:443", false},
+		{"10.0.0.1:51200", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.addr, func(t *testing.T) {
+			assert.Equal(t, test.internal, isInternalAddr(test.addr))
+		})
+	}
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package gateway
+
+import (
+	"context"
+	"io"
+
+	"github.com/gofrs/uuid"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+)
+
+// Upstream is a connection to a single Vizier that requests can be forwarded to.
+type Upstream interface {
+	ExecuteScript(ctx context.Context, req *vizierpb.ExecuteScriptRequest) (vizierpb.VizierService_ExecuteScriptClient, error)
+	HealthCheck(ctx context.Context, req *vizierpb.HealthCheckRequest) (vizierpb.VizierService_HealthCheckClient, error)
+}
+
+// UpstreamProvider returns the upstream for a given cluster.
+type UpstreamProvider interface {
+	Get(ctx context.Context, clusterID uuid.UUID) (Upstream, error)
+}
+
+// Server is a VizierService that authenticates clients and routes their requests to the
+// Vizier specified by the cluster ID in each request.
+type Server struct {
+	auth      *APIKeyAuthenticator
+	upstreams UpstreamProvider
+	// If non-empty, only these clusters are exposed by the gateway.
+	clusters map[uuid.UUID]bool
+}
+
+// NewServer creates a new gateway server. If clusterIDs is empty, all clusters available to the
+// upstream provider are exposed.
+func NewServer(auth *APIKeyAuthenticator, upstreams UpstreamProvider, clusterIDs []uuid.UUID) *Server {
+	clusters := make(map[uuid.UUID]bool)
+	for _, id := range clusterIDs {
+		clusters[id] = true
+	}
+	return &Server{
+		auth:      auth,
+		upstreams: upstreams,
+		clusters:  clusters,
+	}
+}
+
+func (s *Server) route(ctx context.Context, clusterIDStr string) (Upstream, *log.Entry, error) {
+	user, err := s.auth.Authenticate(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	clusterID, err := uuid.FromString(clusterIDStr)
+	if err != nil || clusterID == uuid.Nil {
+		return nil, nil, status.Error(codes.InvalidArgument, "invalid cluster ID")
+	}
+	l := log.WithField("user", user).WithField("cluster_id", clusterID)
+	if len(s.clusters) > 0 && !s.clusters[clusterID] {
+		l.Info("Rejected request for cluster not exposed by the gateway")
+		return nil, nil, status.Errorf(codes.PermissionDenied, "cluster '%s' is not exposed by this gateway", clusterID)
+	}
+	u, err := s.upstreams.Get(ctx, clusterID)
+	if err != nil {
+		l.WithError(err).Error("Failed to connect to Vizier")
+		if _, ok := status.FromError(err); ok {
+			return nil, nil, err
+		}
+		return nil, nil, status.Errorf(codes.Unavailable, "failed to connect to cluster '%s'", clusterID)
+	}
+	return u, l, nil
+}
+
+// ExecuteScript forwards a script execution request to the requested Vizier.
+func (s *Server) ExecuteScript(req *vizierpb.ExecuteScriptRequest, srv vizierpb.VizierService_ExecuteScriptServer) error {
+	u, l, err := s.route(srv.Context(), req.ClusterID)
+	if err != nil {
+		return err
+	}
+	l.WithField("mutation", req.Mutation).Info("Forwarding ExecuteScript request")
+
+	stream, err := u.ExecuteScript(srv.Context(), req)
+	if err != nil {
+		return err
+	}
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := srv.Send(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// HealthCheck forwards a health check request to the requested Vizier.
+func (s *Server) HealthCheck(req *vizierpb.HealthCheckRequest, srv vizierpb.VizierService_HealthCheckServer) error {
+	u, _, err := s.route(srv.Context(), req.ClusterID)
+	if err != nil {
+		return err
+	}
+
+	stream, err := u.HealthCheck(srv.Context(), req)
+	if err != nil {
+		return err
+	}
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := srv.Send(msg); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,204 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package gateway_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/pixie_cli/pkg/gateway"
+)
+
+const bufSize = 1024 * 1024
+
+var (
+	exposedCluster = uuid.Must(uuid.FromString("11285cdd-1de9-4ab1-ae6a-0ba08c8c676c"))
+	hiddenCluster  = uuid.Must(uuid.FromString("7ba7b810-9dad-11d1-80b4-00c04fd430c8"))
+)
+
+// fakeVizier is a VizierService that echoes back the query and the credentials it received.
+type fakeVizier struct{}
+
+func (f *fakeVizier) ExecuteScript(req *vizierpb.ExecuteScriptRequest, srv vizierpb.VizierService_ExecuteScriptServer) error {
+	md, _ := metadata.FromIncomingContext(srv.Context())
+	if len(md.Get(gateway.APIKeyHeader)) > 0 {
+		return status.Error(codes.Internal, "client API key was forwarded")
+	}
+	if len(md.Get("authorization")) == 0 || md.Get("authorization")[0] != "bearer service-token" {
+		return status.Error(codes.Unauthenticated, "missing service token")
+	}
+	if req.QueryStr == "fail" {
+		return status.Error(codes.InvalidArgument, "bad query")
+	}
+	for _, id := range []string{"1", "2"} {
+		if err := srv.Send(&vizierpb.ExecuteScriptResponse{QueryID: id}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeVizier) HealthCheck(req *vizierpb.HealthCheckRequest, srv vizierpb.VizierService_HealthCheckServer) error {
+	return srv.Send(&vizierpb.HealthCheckResponse{Status: &vizierpb.Status{Code: 0}})
+}
+
+type fakeUpstream struct {
+	vz vizierpb.VizierServiceClient
+}
+
+func (f *fakeUpstream) ExecuteScript(ctx context.Context, req *vizierpb.ExecuteScriptRequest) (vizierpb.VizierService_ExecuteScriptClient, error) {
+	return f.vz.ExecuteScript(gateway.BearerCredentials("service-token")(ctx), req)
+}
+
+func (f *fakeUpstream) HealthCheck(ctx context.Context, req *vizierpb.HealthCheckRequest) (vizierpb.VizierService_HealthCheckClient, error) {
+	return f.vz.HealthCheck(gateway.BearerCredentials("service-token")(ctx), req)
+}
+
+type fakeUpstreamProvider struct {
+	upstream *fakeUpstream
+	requests []uuid.UUID
+}
+
+func (f *fakeUpstreamProvider) Get(ctx context.Context, clusterID uuid.UUID) (gateway.Upstream, error) {
+	f.requests = append(f.requests, clusterID)
+	return f.upstream, nil
+}
+
+func startServer(t *testing.T, register func(s *grpc.Server)) *grpc.ClientConn {
+	lis := bufconn.Listen(bufSize)
+	s := grpc.NewServer()
+	register(s)
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithInsecure())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func setupGateway(t *testing.T) (vizierpb.VizierServiceClient, *fakeUpstreamProvider) {
+	vzConn := startServer(t, func(s *grpc.Server) {
+		vizierpb.RegisterVizierServiceServer(s, &fakeVizier{})
+	})
+	provider := &fakeUpstreamProvider{
+		upstream: &fakeUpstream{vz: vizierpb.NewVizierServiceClient(vzConn)},
+	}
+	auth := gateway.NewAPIKeyAuthenticator(map[string]string{"key-a": "alice"})
+	gwConn := startServer(t, func(s *grpc.Server) {
+		vizierpb.RegisterVizierServiceServer(s, gateway.NewServer(auth, provider, []uuid.UUID{exposedCluster}))
+	})
+	return vizierpb.NewVizierServiceClient(gwConn), provider
+}
+
+func withKey(key string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), gateway.APIKeyHeader, key)
+}
+
+func recvAll(stream vizierpb.VizierService_ExecuteScriptClient) ([]string, error) {
+	var ids []string
+	for {
+		msg, err := stream.Recv()
+		if err == io.EOF {
+			return ids, nil
+		}
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, msg.QueryID)
+	}
+}
+
+func TestServer_ExecuteScript(t *testing.T) {
+	client, provider := setupGateway(t)
+
+	stream, err := client.ExecuteScript(withKey("key-a"), &vizierpb.ExecuteScriptRequest{
+		QueryStr:  "px.display(df)",
+		ClusterID: exposedCluster.String(),
+	})
+	require.NoError(t, err)
+	ids, err := recvAll(stream)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2"}, ids)
+	assert.Equal(t, []uuid.UUID{exposedCluster}, provider.requests)
+}
+
+func TestServer_ExecuteScriptUpstreamError(t *testing.T) {
+	client, _ := setupGateway(t)
+
+	stream, err := client.ExecuteScript(withKey("key-a"), &vizierpb.ExecuteScriptRequest{
+		QueryStr:  "fail",
+		ClusterID: exposedCluster.String(),
+	})
+	require.NoError(t, err)
+	_, err = recvAll(stream)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_Rejected(t *testing.T) {
+	tests := []struct {
+		name      string
+		key       string
+		clusterID string
+		code      codes.Code
+	}{
+		{"invalid key", "key-b", exposedCluster.String(), codes.Unauthenticated},
+		{"invalid cluster ID", "key-a", "abcd", codes.InvalidArgument},
+		{"cluster not exposed", "key-a", hiddenCluster.String(), codes.PermissionDenied},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, provider := setupGateway(t)
+			stream, err := client.ExecuteScript(withKey(test.key), &vizierpb.ExecuteScriptRequest{
+				QueryStr:  "px.display(df)",
+				ClusterID: test.clusterID,
+			})
+			require.NoError(t, err)
+			_, err = recvAll(stream)
+			assert.Equal(t, test.code, status.Code(err))
+			assert.Empty(t, provider.requests)
+		})
+	}
+}
+
+func TestServer_HealthCheck(t *testing.T) {
+	client, _ := setupGateway(t)
+
+	stream, err := client.HealthCheck(withKey("key-a"), &vizierpb.HealthCheckRequest{
+		ClusterID: exposedCluster.String(),
+	})
+	require.NoError(t, err)
+	msg, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), msg.Status.Code)
+}
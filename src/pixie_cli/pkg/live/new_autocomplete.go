@@ -39,10 +39,11 @@ type AutocompleteModal interface {
 }
 
 var protoToKindLabelMap = map[cloudpb.AutocompleteEntityKind]string{
-	cloudpb.AEK_SVC:       "svc",
-	cloudpb.AEK_POD:       "pod",
-	cloudpb.AEK_SCRIPT:    "script",
-	cloudpb.AEK_NAMESPACE: "ns",
+	cloudpb.AEK_SVC:        "svc",
+	cloudpb.AEK_POD:        "pod",
+	cloudpb.AEK_SCRIPT:     "script",
+	cloudpb.AEK_NAMESPACE:  "ns",
+	cloudpb.AEK_DEPLOYMENT: "deploy",
 }
 
 type suggestion struct {
@@ -99,3 +99,8 @@ func (f *FlagSet) SetOutput(output io.Writer) {
 func (f *FlagSet) Usage() {
 	f.baseFlagSet.Usage()
 }
+
+// VisitAll wraps flag.FlagSet's VisitAll function, calling fn for each declared flag in lexicographical order.
+func (f *FlagSet) VisitAll(fn func(*flag.Flag)) {
+	f.baseFlagSet.VisitAll(fn)
+}
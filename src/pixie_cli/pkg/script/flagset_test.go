@@ -20,6 +20,7 @@ package script_test
 
 import (
 	"errors"
+	"flag"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -118,3 +119,16 @@ func TestMissingRequiredFlags(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, f4, "6")
 }
+
+func TestVisitAll(t *testing.T) {
+	flags := setupTest()
+
+	var names []string
+	var usages []string
+	flags.VisitAll(func(f *flag.Flag) {
+		names = append(names, f.Name)
+		usages = append(usages, f.Usage)
+	})
+	assert.Equal(t, []string{"f3", "f4", "no_default1", "no_default2"}, names)
+	assert.Equal(t, "(required) a flag with no default", usages[2])
+}
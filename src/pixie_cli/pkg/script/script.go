@@ -28,6 +28,7 @@ import (
 	"github.com/spf13/viper"
 
 	"px.dev/pixie/src/api/proto/vispb"
+	"px.dev/pixie/src/api/proto/vizierpb"
 )
 
 var jsonUnmashaler = &jsonpb.Unmarshaler{
@@ -53,6 +54,10 @@ type ExecutableScript struct {
 	IsLocal bool
 	// Args contains a map from name to argument info.
 	Args map[string]Arg
+	// TracepointSelector restricts the agents that the tracepoints deployed by the script run on.
+	TracepointSelector *vizierpb.TracepointSelector
+	// TracepointRollout is the policy for rolling the script's tracepoints out in stages.
+	TracepointRollout *vizierpb.TracepointRolloutPolicy
 }
 
 // LiveViewLink returns the fully qualified URL for the live view.
@@ -0,0 +1,221 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package update
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver"
+	k8syaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	"px.dev/pixie/src/utils/shared/yamls"
+)
+
+// ResourceChangeType is the kind of change made to a Vizier resource by an update.
+type ResourceChangeType string
+
+const (
+	// ResourceAdded indicates that the resource only exists in the target version.
+	ResourceAdded ResourceChangeType = "Added"
+	// ResourceRemoved indicates that the resource only exists in the current version.
+	ResourceRemoved ResourceChangeType = "Removed"
+	// ResourceModified indicates that the resource exists in both versions, with a different spec.
+	ResourceModified ResourceChangeType = "Modified"
+)
+
+// ResourceChange is a change made to a Vizier resource by an update.
+type ResourceChange struct {
+	Kind   string
+	Name   string
+	Change ResourceChangeType
+	// ImageChanges lists the container images which change, formatted as "<old> -> <new>".
+	ImageChanges []string
+}
+
+// VizierPlan describes the effects of updating a Vizier to a new version.
+type VizierPlan struct {
+	CurrentVersion string
+	TargetVersion  string
+	CLIVersion     string
+	// LatestCLIVersion is the newest available CLI version, or empty if the CLI is up to date.
+	LatestCLIVersion string
+	// OperatorVersion is the version of the operator managing the Vizier, or empty if the
+	// Vizier was not deployed by the operator.
+	OperatorVersion       string
+	LatestOperatorVersion string
+	Changes               []*ResourceChange
+}
+
+// CompatibilityIssues returns the problems which may prevent the update from succeeding, or from being rolled back.
+func (p *VizierPlan) CompatibilityIssues() []string {
+	var issues []string
+
+	current, currentErr := semver.Parse(p.CurrentVersion)
+	target, targetErr := semver.Parse(p.TargetVersion)
+	if targetErr != nil {
+		issues = append(issues, fmt.Sprintf("Target version %s is not a valid version", p.TargetVersion))
+	} else if currentErr == nil {
+		switch target.Compare(current) {
+		case 0:
+			issues = append(issues, fmt.Sprintf("Vizier is already running version %s", p.CurrentVersion))
+		case -1:
+			issues = append(issues, fmt.Sprintf("Target version %s is older than the current version %s, use `px rollback vizier` instead",
+				p.TargetVersion, p.CurrentVersion))
+		}
+	}
+
+	if p.LatestCLIVersion != "" {
+		issues = append(issues, fmt.Sprintf("CLI version %s is out of date, run `px update cli` to update to %s",
+			p.CLIVersion, p.LatestCLIVersion))
+	}
+
+	if p.OperatorVersion == "" {
+		issues = append(issues, "Vizier is not managed by the operator, so its version history is not recorded for `px rollback vizier`")
+	} else if operator, err := semver.Parse(p.OperatorVersion); err == nil {
+		if latest, err := semver.Parse(p.LatestOperatorVersion); err == nil && operator.LT(latest) {
+			issues = append(issues, fmt.Sprintf("Operator version %s is older than the latest operator version %s",
+				p.OperatorVersion, p.LatestOperatorVersion))
+		}
+	}
+	return issues
+}
+
+func resourceKey(kind, name string) string {
+	return kind + "/" + name
+}
+
+// parseResources decodes the K8s resources in the YAMLs, keyed by kind and name.
+func parseResources(files []*yamls.YAMLFile) (map[string]map[string]interface{}, error) {
+	resources := make(map[string]map[string]interface{})
+	for _, f := range files {
+		decoder := k8syaml.NewYAMLOrJSONDecoder(strings.NewReader(f.YAML), 4096)
+		for {
+			var obj map[string]interface{}
+			err := decoder.Decode(&obj)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode %s: %w", f.Name, err)
+			}
+			if len(obj) == 0 {
+				continue
+			}
+			kind, _ := obj["kind"].(string)
+			metadata, _ := obj["metadata"].(map[string]interface{})
+			name, _ := metadata["name"].(string)
+			resources[resourceKey(kind, name)] = obj
+		}
+	}
+	return resources, nil
+}
+
+// containerImages returns the images of the containers in a resource, keyed by container name.
+func containerImages(obj map[string]interface{}) map[string]string {
+	images := make(map[string]string)
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			for k, child := range t {
+				if k != "containers" && k != "initContainers" {
+					walk(child)
+					continue
+				}
+				containers, _ := child.([]interface{})
+				for _, c := range containers {
+					container, _ := c.(map[string]interface{})
+					name, _ := container["name"].(string)
+					image, _ := container["image"].(string)
+					if image != "" {
+						images[name] = image
+					}
+				}
+			}
+		case []interface{}:
+			for _, child := range t {
+				walk(child)
+			}
+		}
+	}
+	walk(obj)
+	return images
+}
+
+func imageChanges(current, target map[string]interface{}) []string {
+	currentImages := containerImages(current)
+	targetImages := containerImages(target)
+
+	var changes []string
+	for name, image := range targetImages {
+		if old, ok := currentImages[name]; ok && old != image {
+			changes = append(changes, fmt.Sprintf("%s -> %s", old, image))
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+// DiffVizierResources compares the YAMLs of two Vizier versions, and returns the resources which are added, removed
+// or modified, sorted by kind and name.
+func DiffVizierResources(current, target []*yamls.YAMLFile) ([]*ResourceChange, error) {
+	currentResources, err := parseResources(current)
+	if err != nil {
+		return nil, err
+	}
+	targetResources, err := parseResources(target)
+	if err != nil {
+		return nil, err
+	}
+
+	newChange := func(obj map[string]interface{}, change ResourceChangeType) *ResourceChange {
+		kind, _ := obj["kind"].(string)
+		metadata, _ := obj["metadata"].(map[string]interface{})
+		name, _ := metadata["name"].(string)
+		return &ResourceChange{Kind: kind, Name: name, Change: change}
+	}
+
+	var changes []*ResourceChange
+	for key, obj := range targetResources {
+		currentObj, ok := currentResources[key]
+		if !ok {
+			changes = append(changes, newChange(obj, ResourceAdded))
+			continue
+		}
+		if !reflect.DeepEqual(currentObj, obj) {
+			c := newChange(obj, ResourceModified)
+			c.ImageChanges = imageChanges(currentObj, obj)
+			changes = append(changes, c)
+		}
+	}
+	for key, obj := range currentResources {
+		if _, ok := targetResources[key]; !ok {
+			changes = append(changes, newChange(obj, ResourceRemoved))
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return resourceKey(changes[i].Kind, changes[i].Name) < resourceKey(changes[j].Kind, changes[j].Name)
+	})
+	return changes, nil
+}
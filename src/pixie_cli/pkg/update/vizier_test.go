@@ -0,0 +1,154 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package update_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/pixie_cli/pkg/update"
+	"px.dev/pixie/src/utils/shared/yamls"
+)
+
+const currentVizierYAML = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: pl-cloud-config
+data:
+  PL_CLOUD_ADDR: withpixie.ai:443
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: vizier-pem
+spec:
+  template:
+    spec:
+      containers:
+      - name: pem
+        image: gcr.io/pixie-oss/pixie-prod/vizier/pem_image:0.7.1
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: kelvin-service
+`
+
+const targetVizierYAML = `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: pl-cloud-config
+data:
+  PL_CLOUD_ADDR: withpixie.ai:443
+---
+apiVersion: apps/v1
+kind: DaemonSet
+metadata:
+  name: vizier-pem
+spec:
+  template:
+    spec:
+      containers:
+      - name: pem
+        image: gcr.io/pixie-oss/pixie-prod/vizier/pem_image:0.7.2
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: vizier-query-broker
+`
+
+func TestDiffVizierResources(t *testing.T) {
+	changes, err := update.DiffVizierResources(
+		[]*yamls.YAMLFile{{Name: "vizier", YAML: currentVizierYAML}},
+		[]*yamls.YAMLFile{{Name: "vizier", YAML: targetVizierYAML}},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []*update.ResourceChange{
+		{
+			Kind:   "DaemonSet",
+			Name:   "vizier-pem",
+			Change: update.ResourceModified,
+			ImageChanges: []string{
+				"gcr.io/pixie-oss/pixie-prod/vizier/pem_image:0.7.1 -> gcr.io/pixie-oss/pixie-prod/vizier/pem_image:0.7.2",
+			},
+		},
+		{Kind: "Deployment", Name: "vizier-query-broker", Change: update.ResourceAdded},
+		{Kind: "Service", Name: "kelvin-service", Change: update.ResourceRemoved},
+	}, changes)
+}
+
+func TestVizierPlan_CompatibilityIssues(t *testing.T) {
+	tests := []struct {
+		name           string
+		plan           *update.VizierPlan
+		expectedIssues int
+	}{
+		{
+			name: "compatible",
+			plan: &update.VizierPlan{
+				CurrentVersion:        "0.7.1",
+				TargetVersion:         "0.7.2",
+				CLIVersion:            "0.5.8",
+				OperatorVersion:       "0.0.10",
+				LatestOperatorVersion: "0.0.10",
+			},
+			expectedIssues: 0,
+		},
+		{
+			name: "downgrade",
+			plan: &update.VizierPlan{
+				CurrentVersion:  "0.7.2",
+				TargetVersion:   "0.7.1",
+				OperatorVersion: "0.0.10",
+			},
+			expectedIssues: 1,
+		},
+		{
+			name: "outdated CLI and operator",
+			plan: &update.VizierPlan{
+				CurrentVersion:        "0.7.1",
+				TargetVersion:         "0.7.2",
+				CLIVersion:            "0.5.7",
+				LatestCLIVersion:      "0.5.8",
+				OperatorVersion:       "0.0.9",
+				LatestOperatorVersion: "0.0.10",
+			},
+			expectedIssues: 2,
+		},
+		{
+			name: "no operator",
+			plan: &update.VizierPlan{
+				CurrentVersion: "0.7.1",
+				TargetVersion:  "0.7.2",
+			},
+			expectedIssues: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Len(t, test.plan.CompatibilityIssues(), test.expectedIssues)
+		})
+	}
+}
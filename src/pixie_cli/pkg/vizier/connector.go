@@ -52,6 +52,8 @@ type Connector struct {
 	conn               *grpc.ClientConn
 	vz                 vizierpb.VizierServiceClient
 	vzDebug            vizierpb.VizierDebugServiceClient
+	vzSched            vizierpb.VizierScheduledScriptServiceClient
+	vzTracepoint       vizierpb.VizierTracepointServiceClient
 	vzToken            string
 	passthroughEnabled bool
 }
@@ -84,6 +86,8 @@ func NewConnector(cloudAddr string, vzInfo *cloudpb.ClusterInfo, conn *Connectio
 
 	c.vz = vizierpb.NewVizierServiceClient(c.conn)
 	c.vzDebug = vizierpb.NewVizierDebugServiceClient(c.conn)
+	c.vzSched = vizierpb.NewVizierScheduledScriptServiceClient(c.conn)
+	c.vzTracepoint = vizierpb.NewVizierTracepointServiceClient(c.conn)
 
 	return c, nil
 }
@@ -226,10 +230,12 @@ func (c *Connector) ExecuteScriptStream(ctx context.Context, script *script.Exec
 	}
 
 	reqPB := &vizierpb.ExecuteScriptRequest{
-		QueryStr:  scriptStr,
-		ClusterID: c.id.String(),
-		ExecFuncs: execFuncs,
-		Mutation:  containsMutation(script),
+		QueryStr:           scriptStr,
+		ClusterID:          c.id.String(),
+		ExecFuncs:          execFuncs,
+		Mutation:           containsMutation(script),
+		TracepointSelector: script.TracepointSelector,
+		TracepointRollout:  script.TracepointRollout,
 	}
 
 	if c.passthroughEnabled {
@@ -380,3 +386,239 @@ func (c *Connector) DebugPodsRequest(ctx context.Context) (chan *DebugPodsRespon
 	}()
 	return results, nil
 }
+
+// CreateSnapshot snapshots the Vizier metadata datastore.
+func (c *Connector) CreateSnapshot(ctx context.Context) (*vizierpb.DatastoreSnapshot, error) {
+	reqPB := &vizierpb.DebugCreateSnapshotRequest{
+		ClusterID: c.id.String(),
+	}
+	if c.passthroughEnabled {
+		ctx = auth.CtxWithCreds(ctx)
+	} else {
+		ctx = ctxWithTokenCreds(ctx, c.vzToken)
+	}
+
+	resp, err := c.vzDebug.DebugCreateSnapshot(ctx, reqPB)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := resp.Recv()
+	if err != nil {
+		return nil, err
+	}
+	return msg.Snapshot, nil
+}
+
+// ListSnapshots lists the snapshots of the Vizier metadata datastore, newest first.
+func (c *Connector) ListSnapshots(ctx context.Context) ([]*vizierpb.DatastoreSnapshot, error) {
+	reqPB := &vizierpb.DebugListSnapshotsRequest{
+		ClusterID: c.id.String(),
+	}
+	if c.passthroughEnabled {
+		ctx = auth.CtxWithCreds(ctx)
+	} else {
+		ctx = ctxWithTokenCreds(ctx, c.vzToken)
+	}
+
+	resp, err := c.vzDebug.DebugListSnapshots(ctx, reqPB)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := resp.Recv()
+	if err != nil {
+		return nil, err
+	}
+	return msg.Snapshots, nil
+}
+
+// UpsertScheduledScript creates or updates a scheduled script and returns its ID.
+func (c *Connector) UpsertScheduledScript(ctx context.Context, s *vizierpb.ScheduledScript) (string, error) {
+	reqPB := &vizierpb.UpsertScheduledScriptRequest{
+		ClusterID: c.id.String(),
+		Script:    s,
+	}
+	if c.passthroughEnabled {
+		ctx = auth.CtxWithCreds(ctx)
+	} else {
+		ctx = ctxWithTokenCreds(ctx, c.vzToken)
+	}
+
+	resp, err := c.vzSched.UpsertScheduledScript(ctx, reqPB)
+	if err != nil {
+		return "", err
+	}
+	msg, err := resp.Recv()
+	if err != nil {
+		return "", err
+	}
+	return msg.ID, nil
+}
+
+// ListScheduledScripts lists the scheduled scripts and their recent runs.
+func (c *Connector) ListScheduledScripts(ctx context.Context) ([]*vizierpb.ScheduledScript, error) {
+	reqPB := &vizierpb.ListScheduledScriptsRequest{
+		ClusterID: c.id.String(),
+	}
+	if c.passthroughEnabled {
+		ctx = auth.CtxWithCreds(ctx)
+	} else {
+		ctx = ctxWithTokenCreds(ctx, c.vzToken)
+	}
+
+	resp, err := c.vzSched.ListScheduledScripts(ctx, reqPB)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := resp.Recv()
+	if err != nil {
+		return nil, err
+	}
+	return msg.Scripts, nil
+}
+
+// DeleteScheduledScript deletes a scheduled script and its persisted results.
+func (c *Connector) DeleteScheduledScript(ctx context.Context, id string) error {
+	reqPB := &vizierpb.DeleteScheduledScriptRequest{
+		ClusterID: c.id.String(),
+		ID:        id,
+	}
+	if c.passthroughEnabled {
+		ctx = auth.CtxWithCreds(ctx)
+	} else {
+		ctx = ctxWithTokenCreds(ctx, c.vzToken)
+	}
+
+	resp, err := c.vzSched.DeleteScheduledScript(ctx, reqPB)
+	if err != nil {
+		return err
+	}
+	_, err = resp.Recv()
+	return err
+}
+
+// ListTracepoints lists the tracepoints deployed on Vizier and their overall state.
+func (c *Connector) ListTracepoints(ctx context.Context) ([]*vizierpb.Tracepoint, error) {
+	reqPB := &vizierpb.ListTracepointsRequest{
+		ClusterID: c.id.String(),
+	}
+	if c.passthroughEnabled {
+		ctx = auth.CtxWithCreds(ctx)
+	} else {
+		ctx = ctxWithTokenCreds(ctx, c.vzToken)
+	}
+
+	resp, err := c.vzTracepoint.ListTracepoints(ctx, reqPB)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := resp.Recv()
+	if err != nil {
+		return nil, err
+	}
+	return msg.Tracepoints, nil
+}
+
+// GetTracepoint gets the tracepoint with the given name, along with its state on each agent.
+func (c *Connector) GetTracepoint(ctx context.Context, name string) (*vizierpb.Tracepoint, error) {
+	reqPB := &vizierpb.GetTracepointRequest{
+		ClusterID: c.id.String(),
+		Name:      name,
+	}
+	if c.passthroughEnabled {
+		ctx = auth.CtxWithCreds(ctx)
+	} else {
+		ctx = ctxWithTokenCreds(ctx, c.vzToken)
+	}
+
+	resp, err := c.vzTracepoint.GetTracepoint(ctx, reqPB)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := resp.Recv()
+	if err != nil {
+		return nil, err
+	}
+	return msg.Tracepoint, nil
+}
+
+// DeleteTracepoints starts terminating the tracepoints with the given names.
+func (c *Connector) DeleteTracepoints(ctx context.Context, names []string) error {
+	reqPB := &vizierpb.DeleteTracepointsRequest{
+		ClusterID: c.id.String(),
+		Names:     names,
+	}
+	if c.passthroughEnabled {
+		ctx = auth.CtxWithCreds(ctx)
+	} else {
+		ctx = ctxWithTokenCreds(ctx, c.vzToken)
+	}
+
+	resp, err := c.vzTracepoint.DeleteTracepoints(ctx, reqPB)
+	if err != nil {
+		return err
+	}
+	_, err = resp.Recv()
+	return err
+}
+
+// ExtendTracepoints resets the TTL of the tracepoints with the given names.
+func (c *Connector) ExtendTracepoints(ctx context.Context, names []string, ttl time.Duration) error {
+	reqPB := &vizierpb.ExtendTracepointsRequest{
+		ClusterID: c.id.String(),
+		Names:     names,
+		TTLNS:     int64(ttl),
+	}
+	if c.passthroughEnabled {
+		ctx = auth.CtxWithCreds(ctx)
+	} else {
+		ctx = ctxWithTokenCreds(ctx, c.vzToken)
+	}
+
+	resp, err := c.vzTracepoint.ExtendTracepoints(ctx, reqPB)
+	if err != nil {
+		return err
+	}
+	_, err = resp.Recv()
+	return err
+}
+
+// GetScheduledScriptResults fetches the persisted results of a run of a scheduled script. The
+// results are returned in the same form as ExecuteScriptStream's. An empty runID fetches the
+// latest successful run.
+func (c *Connector) GetScheduledScriptResults(ctx context.Context, scriptID, runID string) (chan *ExecData, error) {
+	reqPB := &vizierpb.GetScheduledScriptResultsRequest{
+		ClusterID: c.id.String(),
+		ScriptID:  scriptID,
+		RunID:     runID,
+	}
+	if c.passthroughEnabled {
+		ctx = auth.CtxWithCreds(ctx)
+	} else {
+		ctx = ctxWithTokenCreds(ctx, c.vzToken)
+	}
+
+	resp, err := c.vzSched.GetScheduledScriptResults(ctx, reqPB)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(chan *ExecData)
+	go func() {
+		for {
+			select {
+			case <-resp.Context().Done():
+				return
+			case <-ctx.Done():
+				return
+			default:
+				msg, err := resp.Recv()
+				results <- &ExecData{ClusterID: c.id, Resp: msg, Err: err}
+				if err != nil || msg == nil {
+					close(results)
+					return
+				}
+			}
+		}
+	}()
+	return results, nil
+}
@@ -31,6 +31,7 @@ import (
 	"gopkg.in/segmentio/analytics-go.v3"
 
 	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/pixie_cli/pkg/components"
 	"px.dev/pixie/src/pixie_cli/pkg/pxanalytics"
 	"px.dev/pixie/src/pixie_cli/pkg/pxconfig"
 	"px.dev/pixie/src/pixie_cli/pkg/script"
@@ -174,6 +175,20 @@ func RunScriptAndOutputResults(ctx context.Context, conns []*Connector, execScri
 	return err
 }
 
+// RunScriptAndWriteJSON runs the specified script on vizier and writes the results to w as JSON records.
+func RunScriptAndWriteJSON(ctx context.Context, conns []*Connector, execScript *script.ExecutableScript, w io.Writer) error {
+	resp, err := RunScript(ctx, conns, execScript)
+	if err != nil {
+		return err
+	}
+
+	factoryFunc := func(md *vizierpb.ExecuteScriptResponse_MetaData) components.OutputStreamWriter {
+		return components.CreateStreamWriter("json", w)
+	}
+	tw := NewStreamOutputAdapterWithFactory(ctx, resp, "json", factoryFunc)
+	return tw.Finish()
+}
+
 func runScript(ctx context.Context, conns []*Connector, execScript *script.ExecutableScript, format string) (*StreamOutputAdapter, error) {
 	resp, err := RunScript(ctx, conns, execScript)
 	if err != nil {
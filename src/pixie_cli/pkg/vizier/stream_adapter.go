@@ -347,6 +347,12 @@ func (v *StreamOutputAdapter) parseError(ctx context.Context, s *vizierpb.Status
 
 func (v *StreamOutputAdapter) handleExecutionStats(ctx context.Context, es *vizierpb.QueryExecutionStats) error {
 	v.execStats = es
+	if es.TruncationReason != "" {
+		utils.Infof("Results are truncated: the script exceeded its %s budget", es.TruncationReason)
+	}
+	for _, m := range es.MissingAgents {
+		utils.Infof("Results are partial: missing data from agent %s for tables: %s", m.AgentID, strings.Join(m.Tables, ", "))
+	}
 	return nil
 }
 
@@ -163,6 +163,41 @@ start_timestamp_ns: 7
 pod_ids: "1_uid"
 )";
 
+/*
+ *  Templates for workload updates.
+ */
+const char* kRunningDeploymentUpdatePbTxt = R"(
+uid: "6_uid"
+name: "running_deployment"
+namespace: "pl"
+kind: "Deployment"
+start_timestamp_ns: 3
+)";
+
+const char* kRunningStatefulSetUpdatePbTxt = R"(
+uid: "7_uid"
+name: "running_statefulset"
+namespace: "pl"
+kind: "StatefulSet"
+start_timestamp_ns: 3
+)";
+
+const char* kRunningDaemonSetUpdatePbTxt = R"(
+uid: "8_uid"
+name: "running_daemonset"
+namespace: "pl"
+kind: "DaemonSet"
+start_timestamp_ns: 3
+)";
+
+const char* kRunningJobUpdatePbTxt = R"(
+uid: "9_uid"
+name: "running_job"
+namespace: "pl"
+kind: "Job"
+start_timestamp_ns: 3
+)";
+
 std::unique_ptr<px::shared::k8s::metadatapb::ResourceUpdate> CreateRunningPodUpdatePB() {
   auto update = std::make_unique<px::shared::k8s::metadatapb::ResourceUpdate>();
   auto update_proto = absl::Substitute(kResourceUpdateTmpl, "pod_update", kRunningPodUpdatePbTxt);
@@ -252,6 +287,42 @@ std::unique_ptr<px::shared::k8s::metadatapb::ResourceUpdate> CreateServiceWithSa
   return update;
 }
 
+std::unique_ptr<px::shared::k8s::metadatapb::ResourceUpdate> CreateRunningDeploymentUpdatePB() {
+  auto update = std::make_unique<px::shared::k8s::metadatapb::ResourceUpdate>();
+  auto update_proto =
+      absl::Substitute(kResourceUpdateTmpl, "workload_update", kRunningDeploymentUpdatePbTxt);
+  CHECK(google::protobuf::TextFormat::MergeFromString(update_proto, update.get()))
+      << "Failed to parse proto";
+  return update;
+}
+
+std::unique_ptr<px::shared::k8s::metadatapb::ResourceUpdate> CreateRunningStatefulSetUpdatePB() {
+  auto update = std::make_unique<px::shared::k8s::metadatapb::ResourceUpdate>();
+  auto update_proto =
+      absl::Substitute(kResourceUpdateTmpl, "workload_update", kRunningStatefulSetUpdatePbTxt);
+  CHECK(google::protobuf::TextFormat::MergeFromString(update_proto, update.get()))
+      << "Failed to parse proto";
+  return update;
+}
+
+std::unique_ptr<px::shared::k8s::metadatapb::ResourceUpdate> CreateRunningDaemonSetUpdatePB() {
+  auto update = std::make_unique<px::shared::k8s::metadatapb::ResourceUpdate>();
+  auto update_proto =
+      absl::Substitute(kResourceUpdateTmpl, "workload_update", kRunningDaemonSetUpdatePbTxt);
+  CHECK(google::protobuf::TextFormat::MergeFromString(update_proto, update.get()))
+      << "Failed to parse proto";
+  return update;
+}
+
+std::unique_ptr<px::shared::k8s::metadatapb::ResourceUpdate> CreateRunningJobUpdatePB() {
+  auto update = std::make_unique<px::shared::k8s::metadatapb::ResourceUpdate>();
+  auto update_proto =
+      absl::Substitute(kResourceUpdateTmpl, "workload_update", kRunningJobUpdatePbTxt);
+  CHECK(google::protobuf::TextFormat::MergeFromString(update_proto, update.get()))
+      << "Failed to parse proto";
+  return update;
+}
+
 }  // namespace testutils
 }  // namespace metadatapb
 }  // namespace px
@@ -19,6 +19,8 @@
 package k8s
 
 import (
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -762,3 +764,49 @@ func NodeSpecToProto(n *v1.NodeSpec) *metadatapb.NodeSpec {
 		PodCIDR:  n.PodCIDR,
 	}
 }
+
+// The kinds of the workloads that control pods.
+const (
+	DeploymentKind  = "Deployment"
+	ReplicaSetKind  = "ReplicaSet"
+	StatefulSetKind = "StatefulSet"
+	DaemonSetKind   = "DaemonSet"
+	JobKind         = "Job"
+)
+
+func workloadToProto(o *metav1.ObjectMeta, kind string) (*metadatapb.Workload, error) {
+	metadata, err := ObjectMetadataToProto(o)
+	if err != nil {
+		return nil, err
+	}
+
+	return &metadatapb.Workload{
+		Metadata: metadata,
+		Kind:     kind,
+	}, nil
+}
+
+// DeploymentToProto converts a k8s Deployment into a workload proto.
+func DeploymentToProto(d *appsv1.Deployment) (*metadatapb.Workload, error) {
+	return workloadToProto(&d.ObjectMeta, DeploymentKind)
+}
+
+// ReplicaSetToProto converts a k8s ReplicaSet into a workload proto.
+func ReplicaSetToProto(r *appsv1.ReplicaSet) (*metadatapb.Workload, error) {
+	return workloadToProto(&r.ObjectMeta, ReplicaSetKind)
+}
+
+// StatefulSetToProto converts a k8s StatefulSet into a workload proto.
+func StatefulSetToProto(s *appsv1.StatefulSet) (*metadatapb.Workload, error) {
+	return workloadToProto(&s.ObjectMeta, StatefulSetKind)
+}
+
+// DaemonSetToProto converts a k8s DaemonSet into a workload proto.
+func DaemonSetToProto(d *appsv1.DaemonSet) (*metadatapb.Workload, error) {
+	return workloadToProto(&d.ObjectMeta, DaemonSetKind)
+}
+
+// JobToProto converts a k8s Job into a workload proto.
+func JobToProto(j *batchv1.Job) (*metadatapb.Workload, error) {
+	return workloadToProto(&j.ObjectMeta, JobKind)
+}
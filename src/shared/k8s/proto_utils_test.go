@@ -24,6 +24,7 @@ import (
 	"github.com/gogo/protobuf/proto"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -1334,3 +1335,33 @@ func TestNodeToProto(t *testing.T) {
 	}
 	assert.Equal(t, expectedPb, oPb)
 }
+
+func TestDeploymentToProto(t *testing.T) {
+	creationTime := metav1.Unix(0, 4)
+	o := appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "frontend",
+			Namespace:         "pl",
+			UID:               "abcd",
+			ResourceVersion:   "1",
+			CreationTimestamp: creationTime,
+			Labels:            map[string]string{"app": "frontend"},
+		},
+	}
+
+	oPb, err := k8s.DeploymentToProto(&o)
+	require.NoError(t, err)
+
+	assert.Equal(t, &metadatapb.Workload{
+		Metadata: &metadatapb.ObjectMetadata{
+			Name:                "frontend",
+			Namespace:           "pl",
+			UID:                 "abcd",
+			ResourceVersion:     "1",
+			CreationTimestampNS: 4,
+			Labels:              map[string]string{"app": "frontend"},
+			OwnerReferences:     []*metadatapb.OwnerReference{},
+		},
+		Kind: "Deployment",
+	}, oPb)
+}
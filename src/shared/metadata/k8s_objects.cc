@@ -48,5 +48,11 @@ std::string NamespaceInfo::DebugString(int indent) const {
                           name(), uid(), state);
 }
 
+std::string WorkloadInfo::DebugString(int indent) const {
+  std::string state = stop_time_ns() != 0 ? "S" : "R";
+  return absl::Substitute("$0<Workload:kind=$1:ns=$2:name=$3:uid=$4:state=$5>", Indent(indent),
+                          kind(), ns(), name(), uid(), state);
+}
+
 }  // namespace md
 }  // namespace px
@@ -34,7 +34,7 @@ namespace md {
 /**
  * Enum with all the different metadata types.
  */
-enum class K8sObjectType { kUnknown, kPod, kService, kNamespace };
+enum class K8sObjectType { kUnknown, kPod, kService, kNamespace, kWorkload };
 
 /**
  * Base class for all K8s metadata objects.
@@ -66,6 +66,22 @@ class K8sMetadataObject {
   int64_t stop_time_ns() const { return stop_time_ns_; }
   void set_stop_time_ns(int64_t stop_time_ns) { stop_time_ns_ = stop_time_ns; }
 
+  // Labels and annotations are limited to the keys allowed by the metadata service.
+  const absl::flat_hash_map<std::string, std::string>& labels() const { return labels_; }
+  template <typename TMap>
+  void set_labels(const TMap& labels) {
+    labels_ = absl::flat_hash_map<std::string, std::string>(labels.begin(), labels.end());
+  }
+
+  const absl::flat_hash_map<std::string, std::string>& annotations() const {
+    return annotations_;
+  }
+  template <typename TMap>
+  void set_annotations(const TMap& annotations) {
+    annotations_ =
+        absl::flat_hash_map<std::string, std::string>(annotations.begin(), annotations.end());
+  }
+
   virtual std::unique_ptr<K8sMetadataObject> Clone() const = 0;
   virtual std::string DebugString(int indent = 0) const = 0;
 
@@ -105,6 +121,12 @@ class K8sMetadataObject {
    * A value of 0 implies that the object is still active.
    */
   int64_t stop_time_ns_ = 0;
+
+  /**
+   * Allow-listed labels and annotations of this K8s object.
+   */
+  absl::flat_hash_map<std::string, std::string> labels_;
+  absl::flat_hash_map<std::string, std::string> annotations_;
 };
 
 enum class PodQOSClass : uint8_t { kUnknown = 0, kGuaranteed, kBestEffort, kBurstable };
@@ -256,7 +278,12 @@ class PodInfo : public K8sMetadataObject {
                 ConvertToPodConditions(pod_update_info.conditions()), pod_update_info.message(),
                 pod_update_info.reason(), pod_update_info.node_name(), pod_update_info.hostname(),
                 pod_update_info.pod_ip(), pod_update_info.start_timestamp_ns(),
-                pod_update_info.stop_timestamp_ns()) {}
+                pod_update_info.stop_timestamp_ns()) {
+    set_workload(pod_update_info.workload().uid(), pod_update_info.workload().name(),
+                 pod_update_info.workload().kind());
+    set_labels(pod_update_info.labels());
+    set_annotations(pod_update_info.annotations());
+  }
 
   virtual ~PodInfo() = default;
 
@@ -285,6 +312,16 @@ class PodInfo : public K8sMetadataObject {
   const std::string& hostname() const { return hostname_; }
   const std::string& pod_ip() const { return pod_ip_; }
 
+  // The top-level workload (Deployment, StatefulSet, ...) that owns this pod, if any.
+  void set_workload(std::string_view uid, std::string_view name, std::string_view kind) {
+    workload_uid_ = uid;
+    workload_name_ = name;
+    workload_kind_ = kind;
+  }
+  const std::string& workload_uid() const { return workload_uid_; }
+  const std::string& workload_name() const { return workload_name_; }
+  const std::string& workload_kind() const { return workload_kind_; }
+
   const absl::flat_hash_set<std::string>& containers() const { return containers_; }
   const absl::flat_hash_set<std::string>& services() const { return services_; }
 
@@ -323,6 +360,10 @@ class PodInfo : public K8sMetadataObject {
   std::string node_name_;
   std::string hostname_;
   std::string pod_ip_;
+
+  std::string workload_uid_;
+  std::string workload_name_;
+  std::string workload_kind_;
 };
 
 /**
@@ -465,5 +506,34 @@ class NamespaceInfo : public K8sMetadataObject {
   NamespaceInfo& operator=(const NamespaceInfo& other) = delete;
 };
 
+/**
+ * WorkloadInfo contains information about top-level K8s workloads, such as Deployments,
+ * StatefulSets, DaemonSets and Jobs.
+ */
+class WorkloadInfo : public K8sMetadataObject {
+ public:
+  WorkloadInfo(UID uid, std::string_view ns, std::string_view name, std::string_view kind)
+      : K8sMetadataObject(K8sObjectType::kWorkload, std::move(uid), std::move(ns),
+                          std::move(name)),
+        kind_(kind) {}
+  virtual ~WorkloadInfo() = default;
+
+  // The kind of the workload, such as "Deployment".
+  const std::string& kind() const { return kind_; }
+
+  std::unique_ptr<K8sMetadataObject> Clone() const override {
+    return std::unique_ptr<WorkloadInfo>(new WorkloadInfo(*this));
+  }
+
+  std::string DebugString(int indent = 0) const override;
+
+ protected:
+  WorkloadInfo(const WorkloadInfo& other) = default;
+  WorkloadInfo& operator=(const WorkloadInfo& other) = delete;
+
+ private:
+  std::string kind_;
+};
+
 }  // namespace md
 }  // namespace px
@@ -193,5 +193,37 @@ TEST(ServiceInfo, clone) {
   EXPECT_EQ(cloned->type(), service_info.type());
 }
 
+TEST(WorkloadInfo, debug_string) {
+  WorkloadInfo workload_info("123", "pl", "statefulset1", "StatefulSet");
+  for (int i = 0; i < 5; ++i) {
+    EXPECT_EQ(
+        absl::Substitute("$0<Workload:kind=StatefulSet:ns=pl:name=statefulset1:uid=123:state=R>",
+                         Indent(i)),
+        workload_info.DebugString(i));
+  }
+
+  workload_info.set_stop_time_ns(1000);
+  EXPECT_EQ("<Workload:kind=StatefulSet:ns=pl:name=statefulset1:uid=123:state=S>",
+            workload_info.DebugString());
+}
+
+TEST(WorkloadInfo, clone) {
+  WorkloadInfo workload_info("123", "pl", "statefulset1", "StatefulSet");
+  workload_info.set_start_time_ns(123);
+  workload_info.set_stop_time_ns(256);
+
+  std::unique_ptr<WorkloadInfo> cloned(
+      static_cast<WorkloadInfo*>(workload_info.Clone().release()));
+  EXPECT_EQ(cloned->uid(), workload_info.uid());
+  EXPECT_EQ(cloned->name(), workload_info.name());
+  EXPECT_EQ(cloned->ns(), workload_info.ns());
+  EXPECT_EQ(cloned->kind(), workload_info.kind());
+
+  EXPECT_EQ(cloned->start_time_ns(), workload_info.start_time_ns());
+  EXPECT_EQ(cloned->stop_time_ns(), workload_info.stop_time_ns());
+
+  EXPECT_EQ(K8sObjectType::kWorkload, cloned->type());
+}
+
 }  // namespace md
 }  // namespace px
@@ -57,6 +57,11 @@ const NamespaceInfo* K8sMetadataState::NamespaceInfoByID(UIDView ns_id) const {
   return static_cast<const NamespaceInfo*>(K8sMetadataObjectByID(ns_id, type));
 }
 
+const WorkloadInfo* K8sMetadataState::WorkloadInfoByID(UIDView workload_id) const {
+  auto type = K8sObjectType::kWorkload;
+  return static_cast<const WorkloadInfo*>(K8sMetadataObjectByID(workload_id, type));
+}
+
 const ContainerInfo* K8sMetadataState::ContainerInfoByID(CIDView id) const {
   auto it = containers_by_id_.find(id);
 
@@ -92,6 +97,16 @@ UID K8sMetadataState::NamespaceIDByName(K8sNameIdentView namespace_name) const {
   return (it == namespaces_by_name_.end()) ? "" : it->second;
 }
 
+UID K8sMetadataState::WorkloadIDByName(std::string_view kind,
+                                       K8sNameIdentView workload_name) const {
+  auto kind_it = workloads_by_kind_and_name_.find(kind);
+  if (kind_it == workloads_by_kind_and_name_.end()) {
+    return "";
+  }
+  auto it = kind_it->second.find(workload_name);
+  return (it == kind_it->second.end()) ? "" : it->second;
+}
+
 std::unique_ptr<K8sMetadataState> K8sMetadataState::Clone() const {
   auto other = std::make_unique<K8sMetadataState>();
 
@@ -111,6 +126,7 @@ std::unique_ptr<K8sMetadataState> K8sMetadataState::Clone() const {
   other->pods_by_name_ = pods_by_name_;
   other->services_by_name_ = services_by_name_;
   other->namespaces_by_name_ = namespaces_by_name_;
+  other->workloads_by_kind_and_name_ = workloads_by_kind_and_name_;
   other->containers_by_name_ = containers_by_name_;
   other->pods_by_ip_ = pods_by_ip_;
 
@@ -186,6 +202,10 @@ Status K8sMetadataState::HandlePodUpdate(const PodUpdate& update) {
   pod_info->set_conditions(ConvertToPodConditions(update.conditions()));
   pod_info->set_phase_message(update.message());
   pod_info->set_phase_reason(update.reason());
+  pod_info->set_workload(update.workload().uid(), update.workload().name(),
+                         update.workload().kind());
+  pod_info->set_labels(update.labels());
+  pod_info->set_annotations(update.annotations());
 
   pods_by_name_[{ns, name}] = object_uid;
   if (update.host_ip() !=
@@ -246,6 +266,8 @@ Status K8sMetadataState::HandleServiceUpdate(const ServiceUpdate& update) {
   }
   service_info->set_start_time_ns(update.start_timestamp_ns());
   service_info->set_stop_time_ns(update.stop_timestamp_ns());
+  service_info->set_labels(update.labels());
+  service_info->set_annotations(update.annotations());
 
   VLOG(1) << "service update: " << update.name();
 
@@ -275,6 +297,29 @@ Status K8sMetadataState::HandleNamespaceUpdate(const NamespaceUpdate& update) {
   return Status::OK();
 }
 
+Status K8sMetadataState::HandleWorkloadUpdate(const WorkloadUpdate& update) {
+  const UID& workload_uid = update.uid();
+  const std::string& name = update.name();
+  const std::string& ns = update.namespace_();
+  const std::string& kind = update.kind();
+
+  auto it = k8s_objects_by_id_.find(workload_uid);
+  if (it == k8s_objects_by_id_.end()) {
+    auto workload_obj = std::make_unique<WorkloadInfo>(workload_uid, ns, name, kind);
+    VLOG(1) << "Adding Workload: " << workload_obj->DebugString();
+    it = k8s_objects_by_id_.try_emplace(workload_uid, std::move(workload_obj)).first;
+  }
+  auto workload_info = static_cast<WorkloadInfo*>(it->second.get());
+
+  workload_info->set_start_time_ns(update.start_timestamp_ns());
+  workload_info->set_stop_time_ns(update.stop_timestamp_ns());
+
+  VLOG(1) << "workload update: " << update.name();
+
+  workloads_by_kind_and_name_[kind][{ns, name}] = workload_uid;
+  return Status::OK();
+}
+
 template <typename T>
 bool IsExpired(const T& obj, int64_t retention_time, int64_t now) {
   if (obj.stop_time_ns() == 0) {
@@ -308,6 +353,14 @@ Status K8sMetadataState::CleanupExpiredMetadata(int64_t retention_time_ns) {
       case K8sObjectType::kService:
         services_by_name_.erase({k8s_object->ns(), k8s_object->name()});
         break;
+      case K8sObjectType::kWorkload: {
+        auto kind_it = workloads_by_kind_and_name_.find(
+            static_cast<WorkloadInfo*>(k8s_object.get())->kind());
+        if (kind_it != workloads_by_kind_and_name_.end()) {
+          kind_it->second.erase({k8s_object->ns(), k8s_object->name()});
+        }
+        break;
+      }
       default:
         LOG(DFATAL) << absl::Substitute("Unexpected object type: $0",
                                         static_cast<int>(k8s_object->type()));
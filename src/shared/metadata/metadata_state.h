@@ -49,6 +49,7 @@ class K8sMetadataState : NotCopyable {
   using ContainerUpdate = px::shared::k8s::metadatapb::ContainerUpdate;
   using ServiceUpdate = px::shared::k8s::metadatapb::ServiceUpdate;
   using NamespaceUpdate = px::shared::k8s::metadatapb::NamespaceUpdate;
+  using WorkloadUpdate = px::shared::k8s::metadatapb::WorkloadUpdate;
 
   // K8s names consist of both a namespace and name : <ns, name>.
   using K8sNameIdent = std::pair<std::string, std::string>;
@@ -96,6 +97,8 @@ class K8sMetadataState : NotCopyable {
   using PodsByNameMap = K8sEntityByNameMap;
   using ServicesByNameMap = K8sEntityByNameMap;
   using NamespacesByNameMap = K8sEntityByNameMap;
+  // Workloads of different kinds can share a name, so they are mapped by name per kind.
+  using WorkloadsByKindAndNameMap = absl::flat_hash_map<std::string, K8sEntityByNameMap>;
   using ContainersByNameMap = absl::flat_hash_map<std::string, CID>;
   using PodsByPodIpMap = absl::flat_hash_map<std::string, UID>;
 
@@ -179,12 +182,29 @@ class K8sMetadataState : NotCopyable {
    */
   UID NamespaceIDByName(K8sNameIdentView namespace_name) const;
 
+  /**
+   * WorkloadInfoByID gets an unowned pointer to the Workload. This pointer will remain active
+   * for the lifetime of this metadata state instance.
+   * @param workload_id the id of the Workload.
+   * @return Pointer to the WorkloadInfo.
+   */
+  const WorkloadInfo* WorkloadInfoByID(UIDView workload_id) const;
+
+  /**
+   * WorkloadIDByName returns the WorkloadID for the workload of the given kind and name.
+   * @param kind the kind of the workload, such as "Deployment".
+   * @param workload_name the workload name
+   * @return the workload id or empty string if the workload does not exist.
+   */
+  UID WorkloadIDByName(std::string_view kind, K8sNameIdentView workload_name) const;
+
   std::unique_ptr<K8sMetadataState> Clone() const;
 
   Status HandlePodUpdate(const PodUpdate& update);
   Status HandleContainerUpdate(const ContainerUpdate& update);
   Status HandleServiceUpdate(const ServiceUpdate& update);
   Status HandleNamespaceUpdate(const NamespaceUpdate& update);
+  Status HandleWorkloadUpdate(const WorkloadUpdate& update);
 
   Status CleanupExpiredMetadata(int64_t retention_time_ns);
 
@@ -221,6 +241,11 @@ class K8sMetadataState : NotCopyable {
    */
   NamespacesByNameMap namespaces_by_name_;
 
+  /**
+   * Mapping of workloads by kind, and then by name.
+   */
+  WorkloadsByKindAndNameMap workloads_by_kind_and_name_;
+
   /**
    * Mapping of containers by name.
    */
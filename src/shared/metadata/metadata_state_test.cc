@@ -85,6 +85,15 @@ constexpr char kRunningNamespaceUpdatePbTxt[] = R"(
   stop_timestamp_ns: 8
 )";
 
+constexpr char kRunningStatefulSetUpdatePbTxt[] = R"(
+  uid: "sts0_uid"
+  name: "running_sts"
+  namespace: "ns0"
+  kind: "StatefulSet"
+  start_timestamp_ns: 7
+  stop_timestamp_ns: 8
+)";
+
 TEST(K8sMetadataStateTest, CloneCopiedCIDR) {
   K8sMetadataState state;
 
@@ -228,6 +237,29 @@ TEST(K8sMetadataStateTest, HandleNamespaceUpdate) {
   EXPECT_EQ(8, info->stop_time_ns());
 }
 
+TEST(K8sMetadataStateTest, HandleWorkloadUpdate) {
+  K8sMetadataState state;
+
+  K8sMetadataState::WorkloadUpdate update;
+  ASSERT_TRUE(TextFormat::MergeFromString(kRunningStatefulSetUpdatePbTxt, &update))
+      << "Failed to parse proto";
+
+  EXPECT_OK(state.HandleWorkloadUpdate(update));
+  auto info = state.WorkloadInfoByID("sts0_uid");
+  ASSERT_NE(nullptr, info);
+  EXPECT_EQ("sts0_uid", info->uid());
+  EXPECT_EQ("running_sts", info->name());
+  EXPECT_EQ("ns0", info->ns());
+  EXPECT_EQ("StatefulSet", info->kind());
+  EXPECT_EQ(7, info->start_time_ns());
+  EXPECT_EQ(8, info->stop_time_ns());
+
+  EXPECT_EQ("sts0_uid", state.WorkloadIDByName("StatefulSet", {"ns0", "running_sts"}));
+  // Workloads of other kinds can share the name.
+  EXPECT_EQ("", state.WorkloadIDByName("Deployment", {"ns0", "running_sts"}));
+  EXPECT_EQ(nullptr, state.NamespaceInfoByID("sts0_uid"));
+}
+
 TEST(K8sMetadataStateTest, CleanupExpiredMetadata) {
   K8sMetadataState state;
 
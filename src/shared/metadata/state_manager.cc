@@ -151,6 +151,10 @@ Status ApplyK8sUpdates(
         PL_RETURN_IF_ERROR(
             HandleNamespaceUpdate(update->namespace_update(), state, metadata_filter));
         break;
+      case ResourceUpdate::kWorkloadUpdate:
+        PL_RETURN_IF_ERROR(
+            HandleWorkloadUpdate(update->workload_update(), state, metadata_filter));
+        break;
       default:
         LOG(ERROR) << "Unhandled Update Type: " << update->update_case() << " (ignoring)";
     }
@@ -327,5 +331,12 @@ Status HandleNamespaceUpdate(const NamespaceUpdate& update, AgentMetadataState*
   return state->k8s_metadata_state()->HandleNamespaceUpdate(update);
 }
 
+Status HandleWorkloadUpdate(const WorkloadUpdate& update, AgentMetadataState* state,
+                            AgentMetadataFilter*) {
+  VLOG(2) << "Workload Update: " << update.DebugString();
+
+  return state->k8s_metadata_state()->HandleWorkloadUpdate(update);
+}
+
 }  // namespace md
 }  // namespace px
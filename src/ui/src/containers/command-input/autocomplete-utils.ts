@@ -19,7 +19,7 @@
 import { StatusGroup } from 'app/components';
 import { GQLAutocompleteEntityKind } from 'app/types/schema';
 
-export type EntityType = 'AEK_UNKNOWN' | 'AEK_POD' | 'AEK_SVC' | 'AEK_SCRIPT' | 'AEK_NAMESPACE';
+export type EntityType = 'AEK_UNKNOWN' | 'AEK_POD' | 'AEK_SVC' | 'AEK_SCRIPT' | 'AEK_NAMESPACE' | 'AEK_DEPLOYMENT';
 
 // Converts a vixpb.PXType to an entityType that is accepted by autocomplete.
 export function pxTypeToEntityType(pxType: string): GQLAutocompleteEntityKind {
@@ -45,6 +45,8 @@ export function entityTypeToString(entityType: GQLAutocompleteEntityKind): strin
       return 'pod';
     case GQLAutocompleteEntityKind.AEK_NAMESPACE:
       return 'ns';
+    case GQLAutocompleteEntityKind.AEK_DEPLOYMENT:
+      return 'deploy';
     default:
       return '';
   }
@@ -132,5 +132,237 @@ export class VizierDebugServiceClient {
       this.methodInfoDebugPods);
   }
 
+  methodInfoDebugCreateSnapshot = new grpcWeb.AbstractClientBase.MethodInfo(
+    src_api_proto_vizierpb_vizierapi_pb.DebugCreateSnapshotResponse,
+    (request: src_api_proto_vizierpb_vizierapi_pb.DebugCreateSnapshotRequest) => {
+      return request.serializeBinary();
+    },
+    src_api_proto_vizierpb_vizierapi_pb.DebugCreateSnapshotResponse.deserializeBinary
+  );
+
+  debugCreateSnapshot(
+    request: src_api_proto_vizierpb_vizierapi_pb.DebugCreateSnapshotRequest,
+    metadata?: grpcWeb.Metadata) {
+    return this.client_.serverStreaming(
+      this.hostname_ +
+        '/px.api.vizierpb.VizierDebugService/DebugCreateSnapshot',
+      request,
+      metadata || {},
+      this.methodInfoDebugCreateSnapshot);
+  }
+
+  methodInfoDebugListSnapshots = new grpcWeb.AbstractClientBase.MethodInfo(
+    src_api_proto_vizierpb_vizierapi_pb.DebugListSnapshotsResponse,
+    (request: src_api_proto_vizierpb_vizierapi_pb.DebugListSnapshotsRequest) => {
+      return request.serializeBinary();
+    },
+    src_api_proto_vizierpb_vizierapi_pb.DebugListSnapshotsResponse.deserializeBinary
+  );
+
+  debugListSnapshots(
+    request: src_api_proto_vizierpb_vizierapi_pb.DebugListSnapshotsRequest,
+    metadata?: grpcWeb.Metadata) {
+    return this.client_.serverStreaming(
+      this.hostname_ +
+        '/px.api.vizierpb.VizierDebugService/DebugListSnapshots',
+      request,
+      metadata || {},
+      this.methodInfoDebugListSnapshots);
+  }
+
+}
+
+export class VizierScheduledScriptServiceClient {
+  client_: grpcWeb.AbstractClientBase;
+  hostname_: string;
+  credentials_: null | { [index: string]: string; };
+  options_: null | { [index: string]: any; };
+
+  constructor (hostname: string,
+               credentials?: null | { [index: string]: string; },
+               options?: null | { [index: string]: any; }) {
+    if (!options) options = {};
+    if (!credentials) credentials = {};
+    options['format'] = 'text';
+
+    this.client_ = new grpcWeb.GrpcWebClientBase(options);
+    this.hostname_ = hostname;
+    this.credentials_ = credentials;
+    this.options_ = options;
+  }
+
+  methodInfoUpsertScheduledScript = new grpcWeb.AbstractClientBase.MethodInfo(
+    src_api_proto_vizierpb_vizierapi_pb.UpsertScheduledScriptResponse,
+    (request: src_api_proto_vizierpb_vizierapi_pb.UpsertScheduledScriptRequest) => {
+      return request.serializeBinary();
+    },
+    src_api_proto_vizierpb_vizierapi_pb.UpsertScheduledScriptResponse.deserializeBinary
+  );
+
+  upsertScheduledScript(
+    request: src_api_proto_vizierpb_vizierapi_pb.UpsertScheduledScriptRequest,
+    metadata?: grpcWeb.Metadata) {
+    return this.client_.serverStreaming(
+      this.hostname_ +
+        '/px.api.vizierpb.VizierScheduledScriptService/UpsertScheduledScript',
+      request,
+      metadata || {},
+      this.methodInfoUpsertScheduledScript);
+  }
+
+  methodInfoListScheduledScripts = new grpcWeb.AbstractClientBase.MethodInfo(
+    src_api_proto_vizierpb_vizierapi_pb.ListScheduledScriptsResponse,
+    (request: src_api_proto_vizierpb_vizierapi_pb.ListScheduledScriptsRequest) => {
+      return request.serializeBinary();
+    },
+    src_api_proto_vizierpb_vizierapi_pb.ListScheduledScriptsResponse.deserializeBinary
+  );
+
+  listScheduledScripts(
+    request: src_api_proto_vizierpb_vizierapi_pb.ListScheduledScriptsRequest,
+    metadata?: grpcWeb.Metadata) {
+    return this.client_.serverStreaming(
+      this.hostname_ +
+        '/px.api.vizierpb.VizierScheduledScriptService/ListScheduledScripts',
+      request,
+      metadata || {},
+      this.methodInfoListScheduledScripts);
+  }
+
+  methodInfoDeleteScheduledScript = new grpcWeb.AbstractClientBase.MethodInfo(
+    src_api_proto_vizierpb_vizierapi_pb.DeleteScheduledScriptResponse,
+    (request: src_api_proto_vizierpb_vizierapi_pb.DeleteScheduledScriptRequest) => {
+      return request.serializeBinary();
+    },
+    src_api_proto_vizierpb_vizierapi_pb.DeleteScheduledScriptResponse.deserializeBinary
+  );
+
+  deleteScheduledScript(
+    request: src_api_proto_vizierpb_vizierapi_pb.DeleteScheduledScriptRequest,
+    metadata?: grpcWeb.Metadata) {
+    return this.client_.serverStreaming(
+      this.hostname_ +
+        '/px.api.vizierpb.VizierScheduledScriptService/DeleteScheduledScript',
+      request,
+      metadata || {},
+      this.methodInfoDeleteScheduledScript);
+  }
+
+  methodInfoGetScheduledScriptResults = new grpcWeb.AbstractClientBase.MethodInfo(
+    src_api_proto_vizierpb_vizierapi_pb.ExecuteScriptResponse,
+    (request: src_api_proto_vizierpb_vizierapi_pb.GetScheduledScriptResultsRequest) => {
+      return request.serializeBinary();
+    },
+    src_api_proto_vizierpb_vizierapi_pb.ExecuteScriptResponse.deserializeBinary
+  );
+
+  getScheduledScriptResults(
+    request: src_api_proto_vizierpb_vizierapi_pb.GetScheduledScriptResultsRequest,
+    metadata?: grpcWeb.Metadata) {
+    return this.client_.serverStreaming(
+      this.hostname_ +
+        '/px.api.vizierpb.VizierScheduledScriptService/GetScheduledScriptResults',
+      request,
+      metadata || {},
+      this.methodInfoGetScheduledScriptResults);
+  }
+
+}
+
+export class VizierTracepointServiceClient {
+  client_: grpcWeb.AbstractClientBase;
+  hostname_: string;
+  credentials_: null | { [index: string]: string; };
+  options_: null | { [index: string]: any; };
+
+  constructor (hostname: string,
+               credentials?: null | { [index: string]: string; },
+               options?: null | { [index: string]: any; }) {
+    if (!options) options = {};
+    if (!credentials) credentials = {};
+    options['format'] = 'text';
+
+    this.client_ = new grpcWeb.GrpcWebClientBase(options);
+    this.hostname_ = hostname;
+    this.credentials_ = credentials;
+    this.options_ = options;
+  }
+
+  methodInfoListTracepoints = new grpcWeb.AbstractClientBase.MethodInfo(
+    src_api_proto_vizierpb_vizierapi_pb.ListTracepointsResponse,
+    (request: src_api_proto_vizierpb_vizierapi_pb.ListTracepointsRequest) => {
+      return request.serializeBinary();
+    },
+    src_api_proto_vizierpb_vizierapi_pb.ListTracepointsResponse.deserializeBinary
+  );
+
+  listTracepoints(
+    request: src_api_proto_vizierpb_vizierapi_pb.ListTracepointsRequest,
+    metadata?: grpcWeb.Metadata) {
+    return this.client_.serverStreaming(
+      this.hostname_ +
+        '/px.api.vizierpb.VizierTracepointService/ListTracepoints',
+      request,
+      metadata || {},
+      this.methodInfoListTracepoints);
+  }
+
+  methodInfoGetTracepoint = new grpcWeb.AbstractClientBase.MethodInfo(
+    src_api_proto_vizierpb_vizierapi_pb.GetTracepointResponse,
+    (request: src_api_proto_vizierpb_vizierapi_pb.GetTracepointRequest) => {
+      return request.serializeBinary();
+    },
+    src_api_proto_vizierpb_vizierapi_pb.GetTracepointResponse.deserializeBinary
+  );
+
+  getTracepoint(
+    request: src_api_proto_vizierpb_vizierapi_pb.GetTracepointRequest,
+    metadata?: grpcWeb.Metadata) {
+    return this.client_.serverStreaming(
+      this.hostname_ +
+        '/px.api.vizierpb.VizierTracepointService/GetTracepoint',
+      request,
+      metadata || {},
+      this.methodInfoGetTracepoint);
+  }
+
+  methodInfoDeleteTracepoints = new grpcWeb.AbstractClientBase.MethodInfo(
+    src_api_proto_vizierpb_vizierapi_pb.DeleteTracepointsResponse,
+    (request: src_api_proto_vizierpb_vizierapi_pb.DeleteTracepointsRequest) => {
+      return request.serializeBinary();
+    },
+    src_api_proto_vizierpb_vizierapi_pb.DeleteTracepointsResponse.deserializeBinary
+  );
+
+  deleteTracepoints(
+    request: src_api_proto_vizierpb_vizierapi_pb.DeleteTracepointsRequest,
+    metadata?: grpcWeb.Metadata) {
+    return this.client_.serverStreaming(
+      this.hostname_ +
+        '/px.api.vizierpb.VizierTracepointService/DeleteTracepoints',
+      request,
+      metadata || {},
+      this.methodInfoDeleteTracepoints);
+  }
+
+  methodInfoExtendTracepoints = new grpcWeb.AbstractClientBase.MethodInfo(
+    src_api_proto_vizierpb_vizierapi_pb.ExtendTracepointsResponse,
+    (request: src_api_proto_vizierpb_vizierapi_pb.ExtendTracepointsRequest) => {
+      return request.serializeBinary();
+    },
+    src_api_proto_vizierpb_vizierapi_pb.ExtendTracepointsResponse.deserializeBinary
+  );
+
+  extendTracepoints(
+    request: src_api_proto_vizierpb_vizierapi_pb.ExtendTracepointsRequest,
+    metadata?: grpcWeb.Metadata) {
+    return this.client_.serverStreaming(
+      this.hostname_ +
+        '/px.api.vizierpb.VizierTracepointService/ExtendTracepoints',
+      request,
+      metadata || {},
+      this.methodInfoExtendTracepoints);
+  }
+
 }
 
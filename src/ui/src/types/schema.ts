@@ -134,7 +134,8 @@ export enum GQLAutocompleteEntityKind {
   AEK_POD = 'AEK_POD',
   AEK_SVC = 'AEK_SVC',
   AEK_SCRIPT = 'AEK_SCRIPT',
-  AEK_NAMESPACE = 'AEK_NAMESPACE'
+  AEK_NAMESPACE = 'AEK_NAMESPACE',
+  AEK_DEPLOYMENT = 'AEK_DEPLOYMENT'
 }
 
 export interface GQLAutocompleteSuggestion {
@@ -97,10 +97,15 @@ func GetDiscoveryClient(config *rest.Config) *discovery.DiscoveryClient {
 	return discoveryClient
 }
 
+// TryGetConfig gets the kubernetes rest config, returning an error if it cannot be built.
+func TryGetConfig() (*rest.Config, error) {
+	// use the current context in kubeconfig
+	return clientcmd.BuildConfigFromFlags("", *kubeconfig)
+}
+
 // GetConfig gets the kubernetes rest config.
 func GetConfig() *rest.Config {
-	// use the current context in kubeconfig
-	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
+	config, err := TryGetConfig()
 	if err != nil {
 		// Don't use log.Fatal, because it will send an error to Sentry when invoked from the CLI.
 		fmt.Printf("Could not build kubeconfig: %s\n", err.Error())
@@ -21,13 +21,16 @@ package k8s
 import (
 	"archive/zip"
 	"context"
+	"crypto/x509"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	v12 "k8s.io/api/core/v1"
@@ -36,11 +39,51 @@ import (
 	"k8s.io/client-go/rest"
 )
 
+const (
+	// NodeInfoFile is the name of the file in the log bundle containing node system info.
+	NodeInfoFile = "node_info.json"
+	// CertInfoFile is the name of the file in the log bundle containing certificate validity info.
+	CertInfoFile = "cert_info.json"
+	// VizierCRDFile is the name of the file in the log bundle containing the Vizier CRD.
+	VizierCRDFile = "vizier_crd.json"
+	// NATSClusterFile is the name of the file in the log bundle containing the NATS cluster status.
+	NATSClusterFile = "nats_cluster.json"
+	// EtcdClusterFile is the name of the file in the log bundle containing the etcd cluster status.
+	EtcdClusterFile = "etcd_cluster.json"
+)
+
+// NodeInfo is the information about a node that is stored in the log bundle.
+type NodeInfo struct {
+	Name        string              `json:"name"`
+	SystemInfo  v12.NodeSystemInfo  `json:"systemInfo"`
+	Conditions  []v12.NodeCondition `json:"conditions"`
+	Allocatable v12.ResourceList    `json:"allocatable"`
+}
+
+// CertInfo is the validity information for a certificate stored in a secret. The certificate
+// itself is not stored in the log bundle.
+type CertInfo struct {
+	Secret    string    `json:"secret"`
+	Key       string    `json:"key"`
+	Subject   string    `json:"subject"`
+	NotBefore time.Time `json:"notBefore"`
+	NotAfter  time.Time `json:"notAfter"`
+}
+
+// CollectorFunc writes additional diagnostic information to the log bundle.
+type CollectorFunc func(w io.Writer) error
+
+type extraCollector struct {
+	fName string
+	f     CollectorFunc
+}
+
 // LogCollector collect logs for Pixie and cluster setup information.
 type LogCollector struct {
 	k8sConfig    *rest.Config
 	k8sClientSet *kubernetes.Clientset
 	ns           string
+	extras       []extraCollector
 }
 
 // NewLogCollector creates a new log collector.
@@ -126,6 +169,84 @@ func (c *LogCollector) writePodDescription(zf *zip.Writer, pod v12.Pod) error {
 	return enc.Encode(pod)
 }
 
+// AddCollector registers an additional collector whose output is written to fName in the log bundle.
+// Collectors are best effort, a failing collector does not fail the log collection.
+func (c *LogCollector) AddCollector(fName string, f CollectorFunc) {
+	c.extras = append(c.extras, extraCollector{fName: fName, f: f})
+}
+
+func (c *LogCollector) writeNodeInfo(zf *zip.Writer) error {
+	nodes, err := c.k8sClientSet.CoreV1().Nodes().List(context.Background(), v1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	info := make([]NodeInfo, len(nodes.Items))
+	for i, n := range nodes.Items {
+		info[i] = NodeInfo{
+			Name:        n.Name,
+			SystemInfo:  n.Status.NodeInfo,
+			Conditions:  n.Status.Conditions,
+			Allocatable: n.Status.Allocatable,
+		}
+	}
+
+	w, err := zf.Create(NodeInfoFile)
+	defer zf.Flush()
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(info)
+}
+
+func (c *LogCollector) writeCertInfo(zf *zip.Writer) error {
+	secrets, err := c.k8sClientSet.CoreV1().Secrets(c.ns).List(context.Background(), v1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	info := make([]CertInfo, 0)
+	for _, s := range secrets.Items {
+		for k, v := range s.Data {
+			if !strings.HasSuffix(k, ".crt") {
+				continue
+			}
+			block, _ := pem.Decode(v)
+			if block == nil {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				log.WithError(err).Warnf("Failed to parse certificate %s/%s", s.Name, k)
+				continue
+			}
+			info = append(info, CertInfo{
+				Secret:    s.Name,
+				Key:       k,
+				Subject:   cert.Subject.String(),
+				NotBefore: cert.NotBefore,
+				NotAfter:  cert.NotAfter,
+			})
+		}
+	}
+
+	w, err := zf.Create(CertInfoFile)
+	defer zf.Flush()
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(info)
+}
+
+func (c *LogCollector) runExtraCollector(zf *zip.Writer, e extraCollector) error {
+	w, err := zf.Create(e.fName)
+	defer zf.Flush()
+	if err != nil {
+		return err
+	}
+	return e.f(w)
+}
+
 // CollectPixieLogs collects logs for all Pixie pods and write them to the zip file fName.
 func (c *LogCollector) CollectPixieLogs(fName string) error {
 	if !strings.HasSuffix(fName, ".zip") {
@@ -172,5 +293,37 @@ func (c *LogCollector) CollectPixieLogs(fName string) error {
 		log.WithError(err).Warn("failed to log services")
 	}
 
+	err = c.writeNodeInfo(zf)
+	if err != nil {
+		log.WithError(err).Warn("failed to write node info")
+	}
+
+	err = c.writeCertInfo(zf)
+	if err != nil {
+		log.WithError(err).Warn("failed to write cert info")
+	}
+
+	err = c.logKubeCmd(zf, VizierCRDFile, "-n", c.ns, "get", "viziers.px.dev", "-o", "json")
+	if err != nil {
+		log.WithError(err).Warn("failed to log vizier CRD")
+	}
+
+	err = c.logKubeCmd(zf, NATSClusterFile, "-n", c.ns, "get", "natsclusters", "-o", "json")
+	if err != nil {
+		log.WithError(err).Warn("failed to log NATS cluster")
+	}
+
+	err = c.logKubeCmd(zf, EtcdClusterFile, "-n", c.ns, "get", "etcdclusters", "-o", "json")
+	if err != nil {
+		log.WithError(err).Warn("failed to log etcd cluster")
+	}
+
+	for _, e := range c.extras {
+		err = c.runExtraCollector(zf, e)
+		if err != nil {
+			log.WithError(err).Warnf("failed to collect %s", e.fName)
+		}
+	}
+
 	return nil
 }
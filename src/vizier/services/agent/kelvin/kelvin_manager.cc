@@ -32,6 +32,8 @@ Status KelvinManager::PostRegisterHookImpl() {
       dispatcher(), info(), agent_nats_connector(), carnot());
   PL_RETURN_IF_ERROR(RegisterMessageHandler(messages::VizierMessage::MsgCase::kExecuteQueryRequest,
                                             execute_query_handler));
+  PL_RETURN_IF_ERROR(RegisterMessageHandler(messages::VizierMessage::MsgCase::kCancelQueryRequest,
+                                            execute_query_handler));
 
   return Status::OK();
 }
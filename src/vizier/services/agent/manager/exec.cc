@@ -55,6 +55,8 @@ class ExecuteQueryMessageHandler::ExecuteQueryTask : public AsyncTask {
     if (!s.ok()) {
       if (s.code() == px::statuspb::Code::CANCELLED) {
         LOG(WARNING) << absl::Substitute("Cancelled query: $0", query_id_.str());
+      } else if (s.code() == px::statuspb::Code::DEADLINE_EXCEEDED) {
+        LOG(WARNING) << absl::Substitute("Query $0 timed out", query_id_.str());
       } else {
         LOG(ERROR) << absl::Substitute("Query $0 failed, reason: $1, plan: $2", query_id_.str(),
                                        s.ToString(), req_.plan().DebugString());
@@ -82,6 +84,18 @@ ExecuteQueryMessageHandler::ExecuteQueryMessageHandler(px::event::Dispatcher* di
     : MessageHandler(dispatcher, agent_info, nats_conn), carnot_(carnot) {}
 
 Status ExecuteQueryMessageHandler::HandleMessage(std::unique_ptr<messages::VizierMessage> msg) {
+  switch (msg->msg_case()) {
+    case messages::VizierMessage::kExecuteQueryRequest:
+      return HandleExecuteQuery(std::move(msg));
+    case messages::VizierMessage::kCancelQueryRequest:
+      return HandleCancelQuery(msg->cancel_query_request());
+    default:
+      return error::InvalidArgument("Unexpected message type: $0", msg->msg_case());
+  }
+}
+
+Status ExecuteQueryMessageHandler::HandleExecuteQuery(
+    std::unique_ptr<messages::VizierMessage> msg) {
   // Create a task and run it on the threadpool.
   auto task = std::make_unique<ExecuteQueryTask>(this, carnot_, std::move(msg));
 
@@ -95,6 +109,23 @@ Status ExecuteQueryMessageHandler::HandleMessage(std::unique_ptr<messages::Vizie
   return Status::OK();
 }
 
+Status ExecuteQueryMessageHandler::HandleCancelQuery(const messages::CancelQueryRequest& req) {
+  PL_ASSIGN_OR_RETURN(auto query_id, ParseUUID(req.query_id()));
+  if (!running_queries_.contains(query_id)) {
+    // The query already finished on this agent, or never ran on it.
+    VLOG(1) << absl::Substitute("Ignoring cancel request for query that isn't running: $0",
+                                query_id.str());
+    return Status::OK();
+  }
+  LOG(INFO) << absl::Substitute("Cancelling query: id=$0", query_id.str());
+  auto s = carnot_->CancelQuery(query_id);
+  if (error::IsNotFound(s)) {
+    // The query finished executing before it could be cancelled.
+    return Status::OK();
+  }
+  return s;
+}
+
 void ExecuteQueryMessageHandler::HandleQueryExecutionComplete(sole::uuid query_id) {
   // Upon completion of the query, we makr the runnable task for deletion.
   auto node = running_queries_.extract(query_id);
@@ -31,7 +31,8 @@ namespace agent {
 /**
  * ExecuteQueryMessageHandler takes execute query results and performs them.
  * If a qb_stub is specified the results will also be RPCd to the query broker,
- * otherwise only query execution is performed.
+ * otherwise only query execution is performed. It also handles cancel query requests, which stop
+ * queries that are executing.
  *
  * This class runs all of it's work on a thread pool and tracks pending queries internally.
  */
@@ -55,6 +56,9 @@ class ExecuteQueryMessageHandler : public Manager::MessageHandler {
   // Forward declare private task class.
   class ExecuteQueryTask;
 
+  Status HandleExecuteQuery(std::unique_ptr<messages::VizierMessage> msg);
+  Status HandleCancelQuery(const messages::CancelQueryRequest& req);
+
   carnot::Carnot* carnot_;
 
   // Map from query_id -> Running query task.
@@ -0,0 +1,149 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+#include <gtest/gtest.h>
+
+#include <memory>
+#include <string>
+#include <utility>
+#include <vector>
+
+#include <absl/synchronization/mutex.h>
+#include <absl/synchronization/notification.h>
+
+#include "src/carnot/carnot.h"
+#include "src/common/event/api_impl.h"
+#include "src/common/event/libuv.h"
+#include "src/common/testing/event/simulated_time_system.h"
+#include "src/common/testing/testing.h"
+#include "src/common/uuid/uuid_utils.h"
+#include "src/vizier/messages/messagespb/messages.pb.h"
+#include "src/vizier/services/agent/manager/exec.h"
+#include "src/vizier/services/agent/manager/manager.h"
+#include "src/vizier/services/agent/manager/test_utils.h"
+
+namespace px {
+namespace vizier {
+namespace agent {
+
+// FakeCarnot blocks in ExecutePlan until the query is cancelled.
+class FakeCarnot : public carnot::Carnot {
+ public:
+  Status ExecuteQuery(const std::string&, const sole::uuid&, types::Time64NSValue, bool) override {
+    return error::Unimplemented("ExecuteQuery is not supported by FakeCarnot");
+  }
+
+  Status ExecutePlan(const carnot::planpb::Plan&, const sole::uuid& query_id, bool) override {
+    executing_.Notify();
+    if (!cancelled_.WaitForNotificationWithTimeout(absl::Seconds(10))) {
+      return error::DeadlineExceeded("Query $0 was never cancelled", query_id.str());
+    }
+    return error::Cancelled("Query $0 was cancelled", query_id.str());
+  }
+
+  Status CancelQuery(const sole::uuid& query_id) override {
+    absl::MutexLock lock(&lock_);
+    cancelled_queries_.push_back(query_id);
+    if (!cancelled_.HasBeenNotified()) {
+      cancelled_.Notify();
+    }
+    return Status::OK();
+  }
+
+  void RegisterAgentMetadataCallback(AgentMetadataCallbackFunc) override {}
+
+  const carnot::udf::Registry* FuncRegistry() const override { return nullptr; }
+
+  bool WaitForExecuting() { return executing_.WaitForNotificationWithTimeout(absl::Seconds(10)); }
+
+  std::vector<sole::uuid> cancelled_queries() {
+    absl::MutexLock lock(&lock_);
+    return cancelled_queries_;
+  }
+
+ private:
+  absl::Notification executing_;
+  absl::Notification cancelled_;
+  absl::Mutex lock_;
+  std::vector<sole::uuid> cancelled_queries_ ABSL_GUARDED_BY(lock_);
+};
+
+class ExecuteQueryMessageHandlerTest : public ::testing::Test {
+ protected:
+  void TearDown() override { dispatcher_->Exit(); }
+
+  ExecuteQueryMessageHandlerTest() {
+    start_monotonic_time_ = std::chrono::steady_clock::now();
+    start_system_time_ = std::chrono::system_clock::now();
+    time_system_ =
+        std::make_unique<event::SimulatedTimeSystem>(start_monotonic_time_, start_system_time_);
+    api_ = std::make_unique<px::event::APIImpl>(time_system_.get());
+    dispatcher_ = api_->AllocateDispatcher("manager");
+    nats_conn_ = std::make_unique<FakeNATSConnector<px::vizier::messages::VizierMessage>>();
+    carnot_ = std::make_unique<FakeCarnot>();
+    exec_handler_ = std::make_unique<ExecuteQueryMessageHandler>(dispatcher_.get(), &agent_info_,
+                                                                 nats_conn_.get(), carnot_.get());
+  }
+
+  std::unique_ptr<messages::VizierMessage> ExecuteQueryMessage(const sole::uuid& query_id) {
+    auto msg = std::make_unique<messages::VizierMessage>();
+    ToProto(query_id, msg->mutable_execute_query_request()->mutable_query_id());
+    return msg;
+  }
+
+  std::unique_ptr<messages::VizierMessage> CancelQueryMessage(const sole::uuid& query_id) {
+    auto msg = std::make_unique<messages::VizierMessage>();
+    ToProto(query_id, msg->mutable_cancel_query_request()->mutable_query_id());
+    return msg;
+  }
+
+  event::MonotonicTimePoint start_monotonic_time_;
+  event::SystemTimePoint start_system_time_;
+  std::unique_ptr<event::SimulatedTimeSystem> time_system_;
+  std::unique_ptr<event::APIImpl> api_;
+  std::unique_ptr<event::Dispatcher> dispatcher_;
+  std::unique_ptr<FakeNATSConnector<px::vizier::messages::VizierMessage>> nats_conn_;
+  std::unique_ptr<FakeCarnot> carnot_;
+  std::unique_ptr<ExecuteQueryMessageHandler> exec_handler_;
+  agent::Info agent_info_;
+};
+
+TEST_F(ExecuteQueryMessageHandlerTest, CancelRunningQuery) {
+  auto query_id = sole::uuid4();
+  ASSERT_OK(exec_handler_->HandleMessage(ExecuteQueryMessage(query_id)));
+  ASSERT_TRUE(carnot_->WaitForExecuting());
+
+  ASSERT_OK(exec_handler_->HandleMessage(CancelQueryMessage(query_id)));
+  // Wait for the cancelled query to finish and be cleaned up.
+  dispatcher_->Run(event::Dispatcher::RunType::RunUntilExit);
+
+  EXPECT_EQ(std::vector<sole::uuid>{query_id}, carnot_->cancelled_queries());
+
+  // The query has finished, so a second cancel shouldn't reach carnot.
+  ASSERT_OK(exec_handler_->HandleMessage(CancelQueryMessage(query_id)));
+  EXPECT_EQ(1, carnot_->cancelled_queries().size());
+}
+
+TEST_F(ExecuteQueryMessageHandlerTest, CancelUnknownQuery) {
+  ASSERT_OK(exec_handler_->HandleMessage(CancelQueryMessage(sole::uuid4())));
+  EXPECT_TRUE(carnot_->cancelled_queries().empty());
+}
+
+}  // namespace agent
+}  // namespace vizier
+}  // namespace px
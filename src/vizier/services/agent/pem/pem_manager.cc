@@ -46,6 +46,8 @@ Status PEMManager::PostRegisterHookImpl() {
       dispatcher(), info(), agent_nats_connector(), carnot());
   PL_RETURN_IF_ERROR(RegisterMessageHandler(messages::VizierMessage::MsgCase::kExecuteQueryRequest,
                                             execute_query_handler));
+  PL_RETURN_IF_ERROR(RegisterMessageHandler(messages::VizierMessage::MsgCase::kCancelQueryRequest,
+                                            execute_query_handler));
 
   tracepoint_manager_ =
       std::make_shared<TracepointManager>(dispatcher(), info(), agent_nats_connector(),
@@ -45,7 +45,9 @@ import (
 	"px.dev/pixie/src/api/proto/vizierpb"
 	"px.dev/pixie/src/cloud/vzconn/vzconnpb"
 	"px.dev/pixie/src/shared/cvmsgspb"
+	svcutils "px.dev/pixie/src/shared/services/utils"
 	"px.dev/pixie/src/utils"
+	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
 	"px.dev/pixie/src/vizier/utils/messagebus"
 )
 
@@ -128,6 +130,8 @@ const (
 	registrationTimeout           = 30 * time.Second
 	passthroughReplySubjectPrefix = "v2c.reply-"
 	vizStatusCheckFailInterval    = 10 * time.Second
+	// snapshotTimeout bounds how long a passthrough snapshot request can take.
+	snapshotTimeout = 5 * time.Minute
 )
 
 // ErrRegistrationTimeout is the registration timeout error.
@@ -174,6 +178,8 @@ type Bridge struct {
 	vzInfo       VizierInfo
 	vzUpdater    VizierUpdater
 	vizChecker   VizierHealthChecker
+	// The client used to snapshot the metadata datastore. May be nil.
+	snapshotClient metadatapb.MetadataSnapshotServiceClient
 
 	hbSeqNum int64
 
@@ -208,18 +214,19 @@ type Bridge struct {
 }
 
 // New creates a cloud connector to cloud bridge.
-func New(vizierID uuid.UUID, jwtSigningKey string, deployKey string, sessionID int64, vzClient vzconnpb.VZConnServiceClient, vzInfo VizierInfo, vzUpdater VizierUpdater, nc *nats.Conn, checker VizierHealthChecker) *Bridge {
+func New(vizierID uuid.UUID, jwtSigningKey string, deployKey string, sessionID int64, vzClient vzconnpb.VZConnServiceClient, vzInfo VizierInfo, vzUpdater VizierUpdater, nc *nats.Conn, checker VizierHealthChecker, snapshotClient metadatapb.MetadataSnapshotServiceClient) *Bridge {
 	return &Bridge{
-		vizierID:      vizierID,
-		jwtSigningKey: jwtSigningKey,
-		deployKey:     deployKey,
-		sessionID:     sessionID,
-		vzConnClient:  vzClient,
-		vizChecker:    checker,
-		vzInfo:        vzInfo,
-		vzUpdater:     vzUpdater,
-		hbSeqNum:      0,
-		nc:            nc,
+		vizierID:       vizierID,
+		jwtSigningKey:  jwtSigningKey,
+		deployKey:      deployKey,
+		sessionID:      sessionID,
+		vzConnClient:   vzClient,
+		vizChecker:     checker,
+		vzInfo:         vzInfo,
+		vzUpdater:      vzUpdater,
+		hbSeqNum:       0,
+		snapshotClient: snapshotClient,
+		nc:             nc,
 		// Buffer NATS channels to make sure we don't back-pressure NATS
 		natsCh:            make(chan *nats.Msg, 5000),
 		registered:        false,
@@ -598,6 +605,99 @@ func (s *Bridge) handleDebugPodsRequest(reqID string, req *vizierpb.DebugPodsReq
 	return s.sendDebugStreamResponse(reqID, resps)
 }
 
+// snapshotContext returns a context that is authorized to call the metadata service.
+func (s *Bridge) snapshotContext(ctx context.Context) (context.Context, error) {
+	if s.snapshotClient == nil {
+		return nil, status.Error(codes.Unavailable, "the metadata snapshot service is not configured")
+	}
+	claims := svcutils.GenerateJWTForService("cloud_conn", "vizier")
+	token, err := svcutils.SignJWTClaims(claims, s.jwtSigningKey)
+	if err != nil {
+		return nil, err
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", fmt.Sprintf("bearer %s", token)), nil
+}
+
+func (s *Bridge) createSnapshot(ctx context.Context) (*vizierpb.DatastoreSnapshot, error) {
+	ctx, err := s.snapshotContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.snapshotClient.CreateSnapshot(ctx, &metadatapb.CreateSnapshotRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Snapshot, nil
+}
+
+func (s *Bridge) listSnapshots(ctx context.Context) ([]*vizierpb.DatastoreSnapshot, error) {
+	ctx, err := s.snapshotContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.snapshotClient.ListSnapshots(ctx, &metadatapb.ListSnapshotsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Snapshots, nil
+}
+
+func (s *Bridge) handleDebugCreateSnapshotRequest(reqID string, req *vizierpb.DebugCreateSnapshotRequest) error {
+	if req == nil {
+		err := status.Errorf(codes.Internal, "DebugCreateSnapshotRequest is unexpectedly nil")
+		s.sendPTStatusMessage(reqID, codes.Internal, err.Error())
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), snapshotTimeout)
+	defer cancel()
+	snapshot, err := s.createSnapshot(ctx)
+	if err != nil {
+		s.sendPTStatusMessage(reqID, status.Code(err), err.Error())
+		return err
+	}
+
+	resps := []*cvmsgspb.V2CAPIStreamResponse{
+		{
+			RequestID: reqID,
+			Msg: &cvmsgspb.V2CAPIStreamResponse_DebugCreateSnapshotResp{
+				DebugCreateSnapshotResp: &vizierpb.DebugCreateSnapshotResponse{
+					Snapshot: snapshot,
+				},
+			},
+		},
+	}
+	return s.sendDebugStreamResponse(reqID, resps)
+}
+
+func (s *Bridge) handleDebugListSnapshotsRequest(reqID string, req *vizierpb.DebugListSnapshotsRequest) error {
+	if req == nil {
+		err := status.Errorf(codes.Internal, "DebugListSnapshotsRequest is unexpectedly nil")
+		s.sendPTStatusMessage(reqID, codes.Internal, err.Error())
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), snapshotTimeout)
+	defer cancel()
+	snapshots, err := s.listSnapshots(ctx)
+	if err != nil {
+		s.sendPTStatusMessage(reqID, status.Code(err), err.Error())
+		return err
+	}
+
+	resps := []*cvmsgspb.V2CAPIStreamResponse{
+		{
+			RequestID: reqID,
+			Msg: &cvmsgspb.V2CAPIStreamResponse_DebugListSnapshotsResp{
+				DebugListSnapshotsResp: &vizierpb.DebugListSnapshotsResponse{
+					Snapshots: snapshots,
+				},
+			},
+		},
+	}
+	return s.sendDebugStreamResponse(reqID, resps)
+}
+
 func (s *Bridge) doRegistrationHandshake(stream vzconnpb.VZConnService_NATSBridgeClient) error {
 	addr, _, err := s.vzInfo.GetAddress()
 	if err != nil {
@@ -915,6 +1015,21 @@ func (s *Bridge) HandleNATSBridging(stream vzconnpb.VZConnService_NATSBridgeClie
 						log.WithError(err).Error("Could not handle debug pods request")
 					}
 					continue
+				case *cvmsgspb.C2VAPIStreamRequest_DebugCreateSnapshotReq:
+					// Snapshots can take a while, so don't block the bridge on them.
+					go func() {
+						err := s.handleDebugCreateSnapshotRequest(pb.RequestID, pb.GetDebugCreateSnapshotReq())
+						if err != nil {
+							log.WithError(err).Error("Could not handle debug create snapshot request")
+						}
+					}()
+					continue
+				case *cvmsgspb.C2VAPIStreamRequest_DebugListSnapshotsReq:
+					err := s.handleDebugListSnapshotsRequest(pb.RequestID, pb.GetDebugListSnapshotsReq())
+					if err != nil {
+						log.WithError(err).Error("Could not handle debug list snapshots request")
+					}
+					continue
 				default:
 				}
 			}
@@ -1134,3 +1249,21 @@ func (s *Bridge) DebugPods(req *vizierpb.DebugPodsRequest, srv vizierpb.VizierDe
 	}
 	return nil
 }
+
+// DebugCreateSnapshot is the GRPC method to snapshot the metadata datastore.
+func (s *Bridge) DebugCreateSnapshot(req *vizierpb.DebugCreateSnapshotRequest, srv vizierpb.VizierDebugService_DebugCreateSnapshotServer) error {
+	snapshot, err := s.createSnapshot(srv.Context())
+	if err != nil {
+		return err
+	}
+	return srv.Send(&vizierpb.DebugCreateSnapshotResponse{Snapshot: snapshot})
+}
+
+// DebugListSnapshots is the GRPC method to list the snapshots of the metadata datastore.
+func (s *Bridge) DebugListSnapshots(req *vizierpb.DebugListSnapshotsRequest, srv vizierpb.VizierDebugService_DebugListSnapshotsServer) error {
+	snapshots, err := s.listSnapshots(srv.Context())
+	if err != nil {
+		return err
+	}
+	return srv.Send(&vizierpb.DebugListSnapshotsResponse{Snapshots: snapshots})
+}
@@ -305,7 +305,7 @@ func TestNATSGRPCBridgeTest_CorrectRegistrationFlow(t *testing.T) {
 	ts.wg.Add(1)
 
 	sessionID := time.Now().UnixNano()
-	b := bridge.New(ts.vzID, ts.jwt, "", sessionID, ts.vzClient, makeFakeVZInfo("foobar", 123), &FakeVZUpdater{}, ts.nats, &FakeVZChecker{})
+	b := bridge.New(ts.vzID, ts.jwt, "", sessionID, ts.vzClient, makeFakeVZInfo("foobar", 123), &FakeVZUpdater{}, ts.nats, &FakeVZChecker{}, nil)
 	defer b.Stop()
 	go b.RunStream()
 
@@ -341,7 +341,7 @@ func TestNATSGRPCBridgeTest_TestOutboundNATSMessage(t *testing.T) {
 	ts.wg.Add(1)
 
 	sessionID := time.Now().UnixNano()
-	b := bridge.New(ts.vzID, ts.jwt, "", sessionID, ts.vzClient, makeFakeVZInfo("foobar", 123), &FakeVZUpdater{}, ts.nats, &FakeVZChecker{})
+	b := bridge.New(ts.vzID, ts.jwt, "", sessionID, ts.vzClient, makeFakeVZInfo("foobar", 123), &FakeVZUpdater{}, ts.nats, &FakeVZChecker{}, nil)
 	defer func() {
 		b.Stop()
 	}()
@@ -399,7 +399,7 @@ func TestNATSGRPCBridgeTest_TestInboundNATSMessage(t *testing.T) {
 	ts.wg.Add(1)
 
 	sessionID := time.Now().UnixNano()
-	b := bridge.New(ts.vzID, ts.jwt, "", sessionID, ts.vzClient, makeFakeVZInfo("foobar", 123), &FakeVZUpdater{}, ts.nats, &FakeVZChecker{})
+	b := bridge.New(ts.vzID, ts.jwt, "", sessionID, ts.vzClient, makeFakeVZInfo("foobar", 123), &FakeVZUpdater{}, ts.nats, &FakeVZChecker{}, nil)
 	defer b.Stop()
 
 	go b.RunStream()
@@ -472,7 +472,7 @@ func TestNATSGRPCBridgeTest_TestRegisterDeployment(t *testing.T) {
 	vzID := uuid.FromStringOrNil("")
 
 	sessionID := time.Now().UnixNano()
-	b := bridge.New(vzID, ts.jwt, "", sessionID, ts.vzClient, makeFakeVZInfo("foobar", 123), &FakeVZUpdater{}, ts.nats, &FakeVZChecker{})
+	b := bridge.New(vzID, ts.jwt, "", sessionID, ts.vzClient, makeFakeVZInfo("foobar", 123), &FakeVZUpdater{}, ts.nats, &FakeVZChecker{}, nil)
 	defer b.Stop()
 
 	go b.RunStream()
@@ -40,6 +40,7 @@ import (
 	"px.dev/pixie/src/shared/services/server"
 	controllers "px.dev/pixie/src/vizier/services/cloud_connector/bridge"
 	"px.dev/pixie/src/vizier/services/cloud_connector/vizhealth"
+	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
 )
 
 func init() {
@@ -50,6 +51,8 @@ func init() {
 	pflag.String("pod_namespace", "pl", "The namespace this pod runs in.")
 	pflag.String("qb_service", "vizier-query-broker", "The querybroker service url (load balancer/list is ok)")
 	pflag.String("qb_port", "50300", "The querybroker service port")
+	pflag.String("mds_service", "vizier-metadata", "The metadata service name")
+	pflag.String("mds_port", "50400", "The metadata service port")
 	pflag.String("cluster_name", "", "The name of the user's K8s cluster")
 	pflag.String("deploy_key", "", "The deploy key for the cluster")
 	pflag.Bool("disable_auto_update", false, "Whether auto-update should be disabled")
@@ -70,6 +73,22 @@ func newVzServiceClient() (vizierpb.VizierServiceClient, error) {
 	return vizierpb.NewVizierServiceClient(qbChannel), nil
 }
 
+func newSnapshotServiceClient() (metadatapb.MetadataSnapshotServiceClient, error) {
+	dialOpts, err := services.GetGRPCClientDialOpts()
+	if err != nil {
+		return nil, err
+	}
+
+	mdsAddr := fmt.Sprintf("%s.%s.svc:%s", viper.GetString("mds_service"), viper.GetString("pod_namespace"), viper.GetString("mds_port"))
+
+	mdsChannel, err := grpc.Dial(mdsAddr, dialOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return metadatapb.NewMetadataSnapshotServiceClient(mdsChannel), nil
+}
+
 // Checks to see if the cloud connector has successfully assigned a cluster ID.
 type readinessCheck struct {
 	vzInfo controllers.VizierInfo
@@ -145,6 +164,11 @@ func main() {
 	checker := vizhealth.NewChecker(viper.GetString("jwt_signing_key"), qbVzClient)
 	defer checker.Stop()
 
+	snapshotClient, err := newSnapshotServiceClient()
+	if err != nil {
+		log.WithError(err).Fatal("Failed to init metadata snapshot stub")
+	}
+
 	// Periodically clean up any completed jobs.
 	quitCh := make(chan bool)
 	go vzInfo.CleanupCronJob("etcd-defrag-job", 2*time.Hour, quitCh)
@@ -153,7 +177,7 @@ func main() {
 	// We just use the current time in nanoseconds to mark the session ID. This will let the cloud side know that
 	// the cloud connector restarted. Clock skew might make this incorrect, but we mostly want this for debugging.
 	sessionID := time.Now().UnixNano()
-	svr := controllers.New(vizierID, viper.GetString("jwt_signing_key"), deployKey, sessionID, nil, vzInfo, vzInfo, nil, checker)
+	svr := controllers.New(vizierID, viper.GetString("jwt_signing_key"), deployKey, sessionID, nil, vzInfo, vzInfo, nil, checker, snapshotClient)
 	go svr.RunStream()
 	defer svr.Stop()
 
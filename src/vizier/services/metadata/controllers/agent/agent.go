@@ -39,6 +39,13 @@ import (
 	"px.dev/pixie/src/vizier/utils/messagebus"
 )
 
+var (
+	// ErrAgentNotFound is returned when an agent does not exist.
+	ErrAgentNotFound = errors.New("Agent does not exist")
+	// ErrAgentNotQuarantinable is returned when quarantining an agent that doesn't collect data.
+	ErrAgentNotQuarantinable = errors.New("only agents that collect data can be quarantined")
+)
+
 // Store is the interface that a persistent datastore needs to implement for tracking
 // agent data.
 type Store interface {
@@ -122,6 +129,17 @@ type Manager interface {
 	GetServiceCIDR() string
 	// GetPodCIDRs returns the PodCIDRs for the cluster.
 	GetPodCIDRs() []string
+
+	// ReportQueryFailures records that the given agents failed to return results for a query,
+	// quarantining them if that makes them unhealthy.
+	ReportQueryFailures(agentIDs []uuid.UUID, reason string) error
+	// ReportTracepointFailure records that a tracepoint failed on the given agent.
+	ReportTracepointFailure(agentID uuid.UUID) error
+	// GetAgentHealth gets the health of the given agents, or of all agents if none are given.
+	GetAgentHealth(agentIDs []uuid.UUID) ([]*metadata_servicepb.AgentHealth, error)
+	// SetQuarantineOverride overrides the automatic quarantine of the given agent, returning its new
+	// quarantine state.
+	SetQuarantineOverride(agentID uuid.UUID, override agentpb.QuarantineOverride) (*agentpb.AgentQuarantine, error)
 }
 
 // agentUpdateTracker stores the updates (in order) for agents for GetAgentUpdates.
@@ -157,6 +175,13 @@ type ManagerImpl struct {
 	agentUpdateTrackers map[uuid.UUID]*agentUpdateTracker
 	// Protects agentUpdateTrackers.
 	agentUpdateTrackersMutex sync.Mutex
+
+	healthConfig HealthConfig
+	// The signals that the health score of each agent is computed from. These are only kept in memory,
+	// so agents start out healthy when the metadata service restarts.
+	agentHealth map[uuid.UUID]*agentHealth
+	// Protects healthConfig and agentHealth.
+	agentHealthMutex sync.Mutex
 }
 
 // NewManager creates a new agent manager.
@@ -168,6 +193,8 @@ func NewManager(agtStore Store, cidr CIDRInfoProvider, conn *nats.Conn) *Manager
 		cidr:                cidr,
 		conn:                conn,
 		agentUpdateTrackers: make(map[uuid.UUID]*agentUpdateTracker),
+		healthConfig:        DefaultHealthConfig(),
+		agentHealth:         make(map[uuid.UUID]*agentHealth),
 	}
 
 	return Manager
@@ -230,6 +257,10 @@ func (m *ManagerImpl) deleteAgentWrapper(agentID uuid.UUID) error {
 		return err
 	}
 
+	m.agentHealthMutex.Lock()
+	delete(m.agentHealth, agentID)
+	m.agentHealthMutex.Unlock()
+
 	m.agentUpdateTrackersMutex.Lock()
 	defer m.agentUpdateTrackersMutex.Unlock()
 
@@ -372,6 +403,10 @@ func (m *ManagerImpl) ApplyAgentUpdate(update *Update) error {
 		log.WithError(err).Error("Error when updating terminated processes")
 	}
 	if update.UpdateInfo.Data != nil {
+		m.agentHealthMutex.Lock()
+		m.healthForLocked(update.AgentID, resp).recordDataInfo(time.Now())
+		m.agentHealthMutex.Unlock()
+
 		err = m.updateAgentDataInfoWrapper(update.AgentID, update.UpdateInfo.Data)
 		if err != nil {
 			return err
@@ -455,6 +490,11 @@ func (m *ManagerImpl) RegisterAgent(agent *agentpb.Agent) (uint32, error) {
 		agent.LastHeartbeatNS = time.Now().UnixNano()
 	}
 
+	// The agent may have been purged and be registering again, so start tracking its health from scratch.
+	m.agentHealthMutex.Lock()
+	m.agentHealth[aUUID] = newAgentHealth(agent.Quarantine.GetOverride())
+	m.agentHealthMutex.Unlock()
+
 	// Add this agent to the updated agents list.
 	err = m.createAgentWrapper(aUUID, agent)
 	if err != nil {
@@ -482,11 +522,19 @@ func (m *ManagerImpl) UpdateHeartbeat(agentID uuid.UUID) error {
 		return err
 	}
 	if agent == nil {
-		return errors.New("Agent does not exist")
+		return ErrAgentNotFound
 	}
 
 	// Update LastHeartbeatNS in AgentData.
-	agent.LastHeartbeatNS = time.Now().UnixNano()
+	now := time.Now()
+	agent.LastHeartbeatNS = now.UnixNano()
+
+	// Heartbeats are regular, so this is also where agents are reinstated once their failures
+	// fall out of the failure window.
+	m.agentHealthMutex.Lock()
+	m.healthForLocked(agentID, agent).recordHeartbeat(now, m.healthConfig.HeartbeatInterval)
+	m.updateQuarantineLocked(agentID, agent, now)
+	m.agentHealthMutex.Unlock()
 
 	err = m.updateAgentWrapper(agentID, agent)
 	if err != nil {
@@ -0,0 +1,403 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package agent
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/gogo/protobuf/proto"
+	log "github.com/sirupsen/logrus"
+
+	"px.dev/pixie/src/utils"
+	metadata_servicepb "px.dev/pixie/src/vizier/services/metadata/metadatapb"
+	"px.dev/pixie/src/vizier/services/shared/agentpb"
+)
+
+const (
+	// The weights of each signal in the health score. They add up to 1. The age of an agent's data
+	// info isn't a signal, since agents only send it when the data they collect changes, so it says
+	// nothing about whether a quiet agent is healthy.
+	heartbeatJitterWeight   = 0.3
+	queryFailureWeight      = 0.6
+	tracepointFailureWeight = 0.1
+	// The smoothing factor of the moving average of the heartbeat jitter.
+	heartbeatJitterAlpha = 0.2
+)
+
+// HealthConfig configures how the agent manager scores the health of agents, and when it quarantines
+// them from query plans.
+type HealthConfig struct {
+	// HeartbeatInterval is the interval agents are expected to send heartbeats at.
+	HeartbeatInterval time.Duration
+	// FailureWindow is how long a query or tracepoint failure counts against an agent.
+	FailureWindow time.Duration
+	// MaxQueryFailures is the number of query failures within the failure window at which the
+	// query failure signal is completely unhealthy.
+	MaxQueryFailures int
+	// MaxTracepointFailures is the number of tracepoint failures within the failure window at which
+	// the tracepoint failure signal is completely unhealthy.
+	MaxTracepointFailures int
+	// QuarantineThreshold is the score below which agents are quarantined. Automatic quarantine is
+	// disabled if it is 0.
+	QuarantineThreshold float64
+	// ReinstateThreshold is the score at which quarantined agents are reinstated. It should be higher
+	// than the QuarantineThreshold, so that agents don't flap in and out of quarantine.
+	ReinstateThreshold float64
+}
+
+// DefaultHealthConfig returns the default agent health config.
+func DefaultHealthConfig() HealthConfig {
+	return HealthConfig{
+		HeartbeatInterval:     5 * time.Second,
+		FailureWindow:         10 * time.Minute,
+		MaxQueryFailures:      5,
+		MaxTracepointFailures: 3,
+		QuarantineThreshold:   0.5,
+		ReinstateThreshold:    0.8,
+	}
+}
+
+// agentHealth tracks the signals that the health score of an agent is computed from.
+type agentHealth struct {
+	lastHeartbeat   time.Time
+	heartbeatJitter time.Duration
+	// The failures within the failure window, oldest first.
+	queryFailures          []time.Time
+	lastQueryFailureReason string
+	tracepointFailures     []time.Time
+	// When the agent last sent its data info. It is only reported, and doesn't affect the score.
+	lastDataInfo time.Time
+	// The operator override of the agent's quarantine. This is persisted in the agent, but is also kept
+	// here so that concurrent updates of the agent with a stale override don't lose it.
+	override agentpb.QuarantineOverride
+}
+
+func newAgentHealth(override agentpb.QuarantineOverride) *agentHealth {
+	return &agentHealth{
+		override: override,
+	}
+}
+
+func (h *agentHealth) recordHeartbeat(now time.Time, interval time.Duration) {
+	if !h.lastHeartbeat.IsZero() {
+		deviation := now.Sub(h.lastHeartbeat) - interval
+		if deviation < 0 {
+			deviation = -deviation
+		}
+		h.heartbeatJitter = time.Duration(heartbeatJitterAlpha*float64(deviation) +
+			(1-heartbeatJitterAlpha)*float64(h.heartbeatJitter))
+	}
+	h.lastHeartbeat = now
+}
+
+func (h *agentHealth) recordQueryFailure(now time.Time, reason string) {
+	h.queryFailures = append(h.queryFailures, now)
+	h.lastQueryFailureReason = reason
+}
+
+func (h *agentHealth) recordTracepointFailure(now time.Time) {
+	h.tracepointFailures = append(h.tracepointFailures, now)
+}
+
+func (h *agentHealth) recordDataInfo(now time.Time) {
+	h.lastDataInfo = now
+}
+
+// pruneFailures drops the failures that happened before the cutoff.
+func pruneFailures(failures []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(failures) && failures[i].Before(cutoff) {
+		i++
+	}
+	return failures[i:]
+}
+
+// prune drops the failures that are outside of the failure window.
+func (h *agentHealth) prune(now time.Time, window time.Duration) {
+	cutoff := now.Add(-window)
+	h.queryFailures = pruneFailures(h.queryFailures, cutoff)
+	h.tracepointFailures = pruneFailures(h.tracepointFailures, cutoff)
+}
+
+// ratio returns n/max, capped at 1.
+func ratio(n float64, max float64) float64 {
+	if max <= 0 {
+		return 0
+	}
+	return math.Min(n/max, 1)
+}
+
+// score computes the health score of the agent, between 0 (unhealthy) and 1 (healthy), along with
+// descriptions of the signals that most lowered it. The failures must be pruned first.
+func (h *agentHealth) score(cfg HealthConfig) (float64, []string) {
+	var signals []string
+	penalty := 0.0
+	addPenalty := func(weight float64, p float64, desc string) {
+		penalty += weight * p
+		// Only describe the signals that are at least half unhealthy.
+		if p >= 0.5 {
+			signals = append(signals, desc)
+		}
+	}
+
+	addPenalty(heartbeatJitterWeight, ratio(float64(h.heartbeatJitter), float64(cfg.HeartbeatInterval)),
+		fmt.Sprintf("heartbeat jitter of %s", h.heartbeatJitter.Round(time.Millisecond)))
+
+	queryDesc := fmt.Sprintf("%d query failures", len(h.queryFailures))
+	if h.lastQueryFailureReason != "" {
+		queryDesc += fmt.Sprintf(" (last: %s)", h.lastQueryFailureReason)
+	}
+	addPenalty(queryFailureWeight, ratio(float64(len(h.queryFailures)), float64(cfg.MaxQueryFailures)), queryDesc)
+
+	addPenalty(tracepointFailureWeight, ratio(float64(len(h.tracepointFailures)), float64(cfg.MaxTracepointFailures)),
+		fmt.Sprintf("%d tracepoint failures", len(h.tracepointFailures)))
+
+	return math.Max(1-penalty, 0), signals
+}
+
+// collectsData returns whether the agent is a PEM. Only PEMs can be quarantined, since queries can't
+// be planned without Kelvins.
+func collectsData(agent *agentpb.Agent) bool {
+	return agent.Info == nil || agent.Info.Capabilities == nil || agent.Info.Capabilities.CollectsData
+}
+
+// SetHealthConfig sets how the agent manager scores the health of agents.
+func (m *ManagerImpl) SetHealthConfig(cfg HealthConfig) {
+	m.agentHealthMutex.Lock()
+	defer m.agentHealthMutex.Unlock()
+	m.healthConfig = cfg
+}
+
+// healthForLocked returns the health of the given agent, starting to track it if it isn't yet.
+// The caller must hold agentHealthMutex.
+func (m *ManagerImpl) healthForLocked(agentID uuid.UUID, agent *agentpb.Agent) *agentHealth {
+	h, ok := m.agentHealth[agentID]
+	if !ok {
+		h = newAgentHealth(agent.Quarantine.GetOverride())
+		m.agentHealth[agentID] = h
+	}
+	return h
+}
+
+// updateQuarantineLocked updates the quarantine state of the agent from its health score, returning
+// whether it changed. The caller must hold agentHealthMutex.
+func (m *ManagerImpl) updateQuarantineLocked(agentID uuid.UUID, agent *agentpb.Agent, now time.Time) bool {
+	h := m.healthForLocked(agentID, agent)
+	cfg := m.healthConfig
+	h.prune(now, cfg.FailureWindow)
+
+	prev := agent.Quarantine
+	if prev == nil {
+		prev = &agentpb.AgentQuarantine{}
+	}
+	quarantined := prev.Quarantined
+	reason := prev.Reason
+
+	switch h.override {
+	case agentpb.QUARANTINE_OVERRIDE_QUARANTINE:
+		quarantined = true
+		reason = "quarantined by an operator"
+	case agentpb.QUARANTINE_OVERRIDE_REINSTATE:
+		quarantined = false
+	default:
+		score, signals := h.score(cfg)
+		switch {
+		case !collectsData(agent) || cfg.QuarantineThreshold <= 0:
+			quarantined = false
+		case !quarantined && score < cfg.QuarantineThreshold:
+			quarantined = true
+			reason = fmt.Sprintf("health score %.2f is below %.2f", score, cfg.QuarantineThreshold)
+			if len(signals) > 0 {
+				reason = fmt.Sprintf("%s: %s", reason, strings.Join(signals, ", "))
+			}
+		case quarantined && prev.Override == agentpb.QUARANTINE_OVERRIDE_QUARANTINE:
+			// The operator cleared their override, so the agent stays quarantined only if it is unhealthy.
+			quarantined = score < cfg.ReinstateThreshold
+			reason = fmt.Sprintf("health score %.2f is below %.2f", score, cfg.ReinstateThreshold)
+		case quarantined && score >= cfg.ReinstateThreshold:
+			quarantined = false
+		}
+	}
+	if !quarantined {
+		reason = ""
+	}
+
+	if quarantined == prev.Quarantined && reason == prev.Reason && h.override == prev.Override {
+		return false
+	}
+
+	sinceNS := prev.SinceNS
+	switch {
+	case quarantined && !prev.Quarantined:
+		sinceNS = now.UnixNano()
+		log.WithField("agentID", agentID.String()).WithField("reason", reason).Info("Quarantining agent")
+	case !quarantined && prev.Quarantined:
+		sinceNS = 0
+		log.WithField("agentID", agentID.String()).Info("Reinstating agent")
+	}
+	agent.Quarantine = &agentpb.AgentQuarantine{
+		Quarantined: quarantined,
+		Override:    h.override,
+		Reason:      reason,
+		SinceNS:     sinceNS,
+	}
+	return true
+}
+
+// reevaluateQuarantine updates the quarantine state of the agent with the given ID in the store,
+// if it has changed.
+func (m *ManagerImpl) reevaluateQuarantine(agentID uuid.UUID) error {
+	agent, err := m.agtStore.GetAgent(agentID)
+	if err != nil {
+		return err
+	}
+	if agent == nil {
+		// The agent has been deleted since the failure.
+		return nil
+	}
+
+	m.agentHealthMutex.Lock()
+	changed := m.updateQuarantineLocked(agentID, agent, time.Now())
+	m.agentHealthMutex.Unlock()
+
+	if !changed {
+		return nil
+	}
+	return m.updateAgentWrapper(agentID, agent)
+}
+
+// ReportQueryFailures records that the given agents failed to return results for a query.
+func (m *ManagerImpl) ReportQueryFailures(agentIDs []uuid.UUID, reason string) error {
+	now := time.Now()
+	m.agentHealthMutex.Lock()
+	for _, agentID := range agentIDs {
+		// Only track the agents we know about, so that reports for deleted agents don't leak.
+		if h, ok := m.agentHealth[agentID]; ok {
+			h.recordQueryFailure(now, reason)
+		}
+	}
+	m.agentHealthMutex.Unlock()
+
+	var errs []error
+	for _, agentID := range agentIDs {
+		if err := m.reevaluateQuarantine(agentID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	// Pick the first err if any to return.
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// ReportTracepointFailure records that a tracepoint failed on the given agent.
+func (m *ManagerImpl) ReportTracepointFailure(agentID uuid.UUID) error {
+	m.agentHealthMutex.Lock()
+	h, ok := m.agentHealth[agentID]
+	if ok {
+		h.recordTracepointFailure(time.Now())
+	}
+	m.agentHealthMutex.Unlock()
+
+	if !ok {
+		return nil
+	}
+	return m.reevaluateQuarantine(agentID)
+}
+
+// GetAgentHealth gets the health of the given agents, or of all agents if none are given.
+func (m *ManagerImpl) GetAgentHealth(agentIDs []uuid.UUID) ([]*metadata_servicepb.AgentHealth, error) {
+	agents, err := m.agtStore.GetAgents()
+	if err != nil {
+		return nil, err
+	}
+
+	requested := make(map[uuid.UUID]bool)
+	for _, agentID := range agentIDs {
+		requested[agentID] = true
+	}
+
+	now := time.Now()
+	m.agentHealthMutex.Lock()
+	defer m.agentHealthMutex.Unlock()
+
+	var health []*metadata_servicepb.AgentHealth
+	for _, agent := range agents {
+		agentID := utils.UUIDFromProtoOrNil(agent.Info.AgentID)
+		if len(requested) > 0 && !requested[agentID] {
+			continue
+		}
+		h := m.healthForLocked(agentID, agent)
+		h.prune(now, m.healthConfig.FailureWindow)
+		score, _ := h.score(m.healthConfig)
+
+		agentHealth := &metadata_servicepb.AgentHealth{
+			AgentID:                  agent.Info.AgentID,
+			Hostname:                 agent.Info.HostInfo.GetHostname(),
+			Score:                    score,
+			HeartbeatJitterNS:        h.heartbeatJitter.Nanoseconds(),
+			RecentQueryFailures:      int32(len(h.queryFailures)),
+			RecentTracepointFailures: int32(len(h.tracepointFailures)),
+			Quarantine:               agent.Quarantine,
+		}
+		if !h.lastDataInfo.IsZero() {
+			agentHealth.NSSinceDataInfo = now.Sub(h.lastDataInfo).Nanoseconds()
+		}
+		if agentHealth.Quarantine == nil {
+			agentHealth.Quarantine = &agentpb.AgentQuarantine{}
+		}
+		health = append(health, agentHealth)
+	}
+	return health, nil
+}
+
+// SetQuarantineOverride overrides the automatic quarantine of the given agent.
+func (m *ManagerImpl) SetQuarantineOverride(agentID uuid.UUID, override agentpb.QuarantineOverride) (*agentpb.AgentQuarantine, error) {
+	agent, err := m.agtStore.GetAgent(agentID)
+	if err != nil {
+		return nil, err
+	}
+	if agent == nil {
+		return nil, ErrAgentNotFound
+	}
+	if override == agentpb.QUARANTINE_OVERRIDE_QUARANTINE && !collectsData(agent) {
+		return nil, ErrAgentNotQuarantinable
+	}
+
+	m.agentHealthMutex.Lock()
+	m.healthForLocked(agentID, agent).override = override
+	changed := m.updateQuarantineLocked(agentID, agent, time.Now())
+	m.agentHealthMutex.Unlock()
+
+	if changed {
+		err = m.updateAgentWrapper(agentID, agent)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if agent.Quarantine == nil {
+		return &agentpb.AgentQuarantine{}, nil
+	}
+	return proto.Clone(agent.Quarantine).(*agentpb.AgentQuarantine), nil
+}
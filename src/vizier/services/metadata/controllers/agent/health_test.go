@@ -0,0 +1,226 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package agent_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/vizier/services/metadata/controllers/agent"
+	"px.dev/pixie/src/vizier/services/metadata/controllers/testutils"
+	"px.dev/pixie/src/vizier/services/shared/agentpb"
+)
+
+func TestManager_QuarantineAndReinstate(t *testing.T) {
+	ads, agtMgr, _, cleanup := setupManager(t)
+	defer cleanup()
+
+	cfg := agent.DefaultHealthConfig()
+	cfg.FailureWindow = 100 * time.Millisecond
+	agtMgr.(*agent.ManagerImpl).SetHealthConfig(cfg)
+
+	u := uuid.FromStringOrNil(testutils.ExistingAgentUUID)
+	require.NoError(t, agtMgr.UpdateHeartbeat(u))
+
+	// A single failure isn't enough to quarantine the agent.
+	require.NoError(t, agtMgr.ReportQueryFailures([]uuid.UUID{u}, "timed out"))
+	agt, err := ads.GetAgent(u)
+	require.NoError(t, err)
+	assert.False(t, agt.Quarantine.GetQuarantined())
+
+	cursor := agtMgr.NewAgentUpdateCursor()
+	_, _, err = agtMgr.GetAgentUpdates(cursor)
+	require.NoError(t, err)
+
+	for i := 0; i < cfg.MaxQueryFailures; i++ {
+		require.NoError(t, agtMgr.ReportQueryFailures([]uuid.UUID{u}, "timed out"))
+	}
+	agt, err = ads.GetAgent(u)
+	require.NoError(t, err)
+	require.NotNil(t, agt.Quarantine)
+	assert.True(t, agt.Quarantine.Quarantined)
+	assert.Contains(t, agt.Quarantine.Reason, "query failures (last: timed out)")
+	assert.NotZero(t, agt.Quarantine.SinceNS)
+
+	// The quarantine is sent to agent update consumers.
+	updates, _, err := agtMgr.GetAgentUpdates(cursor)
+	require.NoError(t, err)
+	require.Len(t, updates, 1)
+	assert.True(t, updates[0].GetAgent().Quarantine.GetQuarantined())
+
+	// Heartbeats keep the agent quarantined while the failures are within the window.
+	require.NoError(t, agtMgr.UpdateHeartbeat(u))
+	agt, err = ads.GetAgent(u)
+	require.NoError(t, err)
+	assert.True(t, agt.Quarantine.GetQuarantined())
+
+	// Once the failures fall out of the window, the agent is reinstated at its next heartbeat.
+	time.Sleep(cfg.FailureWindow)
+	require.NoError(t, agtMgr.UpdateHeartbeat(u))
+	agt, err = ads.GetAgent(u)
+	require.NoError(t, err)
+	assert.False(t, agt.Quarantine.GetQuarantined())
+	assert.Empty(t, agt.Quarantine.GetReason())
+	assert.Zero(t, agt.Quarantine.GetSinceNS())
+}
+
+func TestManager_QuarantineDisabled(t *testing.T) {
+	ads, agtMgr, _, cleanup := setupManager(t)
+	defer cleanup()
+
+	cfg := agent.DefaultHealthConfig()
+	cfg.QuarantineThreshold = 0
+	agtMgr.(*agent.ManagerImpl).SetHealthConfig(cfg)
+
+	u := uuid.FromStringOrNil(testutils.ExistingAgentUUID)
+	require.NoError(t, agtMgr.UpdateHeartbeat(u))
+	for i := 0; i < cfg.MaxQueryFailures; i++ {
+		require.NoError(t, agtMgr.ReportQueryFailures([]uuid.UUID{u}, "timed out"))
+	}
+	agt, err := ads.GetAgent(u)
+	require.NoError(t, err)
+	assert.False(t, agt.Quarantine.GetQuarantined())
+}
+
+func TestManager_KelvinNotQuarantined(t *testing.T) {
+	ads, agtMgr, _, cleanup := setupManager(t)
+	defer cleanup()
+
+	u := uuid.FromStringOrNil(testutils.UnhealthyKelvinAgentUUID)
+	require.NoError(t, agtMgr.UpdateHeartbeat(u))
+	for i := 0; i < agent.DefaultHealthConfig().MaxQueryFailures; i++ {
+		require.NoError(t, agtMgr.ReportQueryFailures([]uuid.UUID{u}, "timed out"))
+	}
+	agt, err := ads.GetAgent(u)
+	require.NoError(t, err)
+	assert.False(t, agt.Quarantine.GetQuarantined())
+
+	_, err = agtMgr.SetQuarantineOverride(u, agentpb.QUARANTINE_OVERRIDE_QUARANTINE)
+	assert.ErrorIs(t, err, agent.ErrAgentNotQuarantinable)
+}
+
+func TestManager_ReportTracepointFailure(t *testing.T) {
+	_, agtMgr, _, cleanup := setupManager(t)
+	defer cleanup()
+
+	u := uuid.FromStringOrNil(testutils.ExistingAgentUUID)
+	require.NoError(t, agtMgr.UpdateHeartbeat(u))
+	require.NoError(t, agtMgr.ReportTracepointFailure(u))
+	require.NoError(t, agtMgr.ReportTracepointFailure(u))
+
+	health, err := agtMgr.GetAgentHealth([]uuid.UUID{u})
+	require.NoError(t, err)
+	require.Len(t, health, 1)
+	assert.Equal(t, int32(2), health[0].RecentTracepointFailures)
+	assert.Less(t, health[0].Score, 1.0)
+	assert.False(t, health[0].Quarantine.Quarantined)
+}
+
+func TestManager_GetAgentHealth(t *testing.T) {
+	_, agtMgr, _, cleanup := setupManager(t)
+	defer cleanup()
+
+	u := uuid.FromStringOrNil(testutils.ExistingAgentUUID)
+	require.NoError(t, agtMgr.UpdateHeartbeat(u))
+	require.NoError(t, agtMgr.ReportQueryFailures([]uuid.UUID{u}, "timed out"))
+
+	health, err := agtMgr.GetAgentHealth(nil)
+	require.NoError(t, err)
+	assert.Len(t, health, 3)
+
+	health, err = agtMgr.GetAgentHealth([]uuid.UUID{u})
+	require.NoError(t, err)
+	require.Len(t, health, 1)
+	assert.Equal(t, "testhost", health[0].Hostname)
+	assert.Equal(t, int32(1), health[0].RecentQueryFailures)
+	assert.InDelta(t, 0.88, health[0].Score, 0.01)
+	assert.False(t, health[0].Quarantine.Quarantined)
+}
+
+func TestManager_QuietAgentIsHealthy(t *testing.T) {
+	ads, agtMgr, _, cleanup := setupManager(t)
+	defer cleanup()
+
+	cfg := agent.DefaultHealthConfig()
+	cfg.HeartbeatInterval = 10 * time.Millisecond
+	agtMgr.(*agent.ManagerImpl).SetHealthConfig(cfg)
+
+	// PEMs only send their data info when their data changes, so a PEM that keeps heartbeating
+	// without sending it stays healthy.
+	u := uuid.FromStringOrNil(testutils.ExistingAgentUUID)
+	for i := 0; i < 3; i++ {
+		require.NoError(t, agtMgr.UpdateHeartbeat(u))
+		time.Sleep(cfg.HeartbeatInterval)
+	}
+
+	health, err := agtMgr.GetAgentHealth([]uuid.UUID{u})
+	require.NoError(t, err)
+	require.Len(t, health, 1)
+	assert.Zero(t, health[0].NSSinceDataInfo)
+	assert.Greater(t, health[0].Score, cfg.ReinstateThreshold)
+	agt, err := ads.GetAgent(u)
+	require.NoError(t, err)
+	assert.False(t, agt.Quarantine.GetQuarantined())
+}
+
+func TestManager_SetQuarantineOverride(t *testing.T) {
+	ads, agtMgr, _, cleanup := setupManager(t)
+	defer cleanup()
+
+	u := uuid.FromStringOrNil(testutils.ExistingAgentUUID)
+
+	q, err := agtMgr.SetQuarantineOverride(u, agentpb.QUARANTINE_OVERRIDE_QUARANTINE)
+	require.NoError(t, err)
+	assert.True(t, q.Quarantined)
+	assert.Equal(t, agentpb.QUARANTINE_OVERRIDE_QUARANTINE, q.Override)
+
+	// The override outlasts heartbeats of the healthy agent.
+	require.NoError(t, agtMgr.UpdateHeartbeat(u))
+	agt, err := ads.GetAgent(u)
+	require.NoError(t, err)
+	assert.True(t, agt.Quarantine.GetQuarantined())
+
+	// Clearing the override reinstates the healthy agent.
+	q, err = agtMgr.SetQuarantineOverride(u, agentpb.QUARANTINE_OVERRIDE_NONE)
+	require.NoError(t, err)
+	assert.False(t, q.Quarantined)
+
+	// Reinstated agents aren't quarantined however unhealthy they are.
+	_, err = agtMgr.SetQuarantineOverride(u, agentpb.QUARANTINE_OVERRIDE_REINSTATE)
+	require.NoError(t, err)
+	for i := 0; i < agent.DefaultHealthConfig().MaxQueryFailures; i++ {
+		require.NoError(t, agtMgr.ReportQueryFailures([]uuid.UUID{u}, "timed out"))
+	}
+	agt, err = ads.GetAgent(u)
+	require.NoError(t, err)
+	assert.False(t, agt.Quarantine.GetQuarantined())
+	assert.Equal(t, agentpb.QUARANTINE_OVERRIDE_REINSTATE, agt.Quarantine.GetOverride())
+
+	// Once the override is cleared, the unhealthy agent is quarantined.
+	q, err = agtMgr.SetQuarantineOverride(u, agentpb.QUARANTINE_OVERRIDE_NONE)
+	require.NoError(t, err)
+	assert.True(t, q.Quarantined)
+
+	_, err = agtMgr.SetQuarantineOverride(uuid.FromStringOrNil(testutils.NewAgentUUID), agentpb.QUARANTINE_OVERRIDE_QUARANTINE)
+	assert.ErrorIs(t, err, agent.ErrAgentNotFound)
+}
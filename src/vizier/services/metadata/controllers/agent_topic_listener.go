@@ -289,6 +289,13 @@ func (a *AgentTopicListener) onAgentTracepointInfoUpdate(m *messagespb.Tracepoin
 	if err != nil {
 		log.WithError(err).Error("Could not update agent tracepoint status")
 	}
+
+	if m.State == statuspb.FAILED_STATE {
+		err = a.agtMgr.ReportTracepointFailure(utils.UUIDFromProtoOrNil(m.AgentID))
+		if err != nil {
+			log.WithError(err).Error("Could not report agent tracepoint failure")
+		}
+	}
 }
 
 // Stop stops processing any agent messagespb.
@@ -424,12 +431,10 @@ func (ah *AgentHandler) onAgentRegisterRequest(m *messagespb.RegisterAgentReques
 
 		agentIDs := []uuid.UUID{agentID}
 
-		for _, tp := range tracepoints {
-			if tp.ExpectedState != statuspb.TERMINATED_STATE {
-				err = ah.tpMgr.RegisterTracepoint(agentIDs, utils.UUIDFromProtoOrNil(tp.ID), tp.Tracepoint)
-				if err != nil {
-					log.WithError(err).Error("Failed to send RegisterTracepoint request")
-				}
+		for _, tp := range tracepoint.TracepointsForAgent(tracepoints, agentID) {
+			err = ah.tpMgr.RegisterTracepoint(agentIDs, utils.UUIDFromProtoOrNil(tp.ID), tp.Tracepoint)
+			if err != nil {
+				log.WithError(err).Error("Failed to send RegisterTracepoint request")
 			}
 		}
 	}()
@@ -567,6 +567,57 @@ func TestAgentTracepointInfoUpdate(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestAgentTracepointInfoUpdate_Failed(t *testing.T) {
+	// Set up mock.
+	atl, mockAgtMgr, mockTracepointStore, cleanup := setup(t, assertSendMessageUncalled(t))
+	defer cleanup()
+
+	agentID := uuid.Must(uuid.NewV4())
+	tpID := uuid.Must(uuid.NewV4())
+	failure := &statuspb.Status{
+		ErrCode: statuspb.INTERNAL,
+		Msg:     "failed to attach probe",
+	}
+
+	mockTracepointStore.
+		EXPECT().
+		UpdateTracepointState(&storepb.AgentTracepointStatus{
+			ID:      utils.ProtoFromUUID(tpID),
+			AgentID: utils.ProtoFromUUID(agentID),
+			State:   statuspb.FAILED_STATE,
+			Status:  failure,
+		}).
+		Return(nil)
+
+	// The failure counts against the agent's health.
+	mockAgtMgr.
+		EXPECT().
+		ReportTracepointFailure(agentID).
+		Return(nil)
+
+	req := &messagespb.VizierMessage{
+		Msg: &messagespb.VizierMessage_TracepointMessage{
+			TracepointMessage: &messagespb.TracepointMessage{
+				Msg: &messagespb.TracepointMessage_TracepointInfoUpdate{
+					TracepointInfoUpdate: &messagespb.TracepointInfoUpdate{
+						ID:      utils.ProtoFromUUID(tpID),
+						AgentID: utils.ProtoFromUUID(agentID),
+						State:   statuspb.FAILED_STATE,
+						Status:  failure,
+					},
+				},
+			},
+		},
+	}
+	reqPb, err := req.Marshal()
+	require.NoError(t, err)
+
+	msg := nats.Msg{}
+	msg.Data = reqPb
+	err = atl.HandleMessage(&msg)
+	require.NoError(t, err)
+}
+
 func TestAgentStop(t *testing.T) {
 	u, err := uuid.FromString(testutils.NewAgentUUID)
 	require.NoError(t, err)
@@ -0,0 +1,86 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package backup
+
+import (
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
+	"px.dev/pixie/src/vizier/utils/datastore/snapshot"
+)
+
+// Server implements the MetadataSnapshotService.
+type Server struct {
+	// The snapshot manager, or nil if no snapshot store is configured.
+	m *snapshot.Manager
+}
+
+// NewServer creates a server that takes snapshots with the given manager. The manager may be nil,
+// in which case all requests fail.
+func NewServer(m *snapshot.Manager) *Server {
+	return &Server{m: m}
+}
+
+func snapshotToProto(s *snapshot.StoredSnapshot) *vizierpb.DatastoreSnapshot {
+	return &vizierpb.DatastoreSnapshot{
+		Name:        s.Name,
+		CreatedAtNS: s.CreatedAt.UnixNano(),
+		NumKeys:     int64(s.Keys),
+		SizeBytes:   int64(s.Size),
+	}
+}
+
+func (s *Server) checkConfigured() error {
+	if s.m == nil {
+		return status.Error(codes.FailedPrecondition, "no metadata snapshot store is configured")
+	}
+	return nil
+}
+
+// CreateSnapshot snapshots the metadata datastore.
+func (s *Server) CreateSnapshot(ctx context.Context, req *metadatapb.CreateSnapshotRequest) (*metadatapb.CreateSnapshotResponse, error) {
+	if err := s.checkConfigured(); err != nil {
+		return nil, err
+	}
+	snap, err := s.m.Snapshot(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to create snapshot: %v", err)
+	}
+	return &metadatapb.CreateSnapshotResponse{Snapshot: snapshotToProto(snap)}, nil
+}
+
+// ListSnapshots lists the saved snapshots of the metadata datastore, newest first.
+func (s *Server) ListSnapshots(ctx context.Context, req *metadatapb.ListSnapshotsRequest) (*metadatapb.ListSnapshotsResponse, error) {
+	if err := s.checkConfigured(); err != nil {
+		return nil, err
+	}
+	snapshots, err := s.m.List(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to list snapshots: %v", err)
+	}
+	resp := &metadatapb.ListSnapshotsResponse{}
+	for _, snap := range snapshots {
+		resp.Snapshots = append(resp.Snapshots, snapshotToProto(snap))
+	}
+	return resp, nil
+}
@@ -0,0 +1,75 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package backup_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/vizier/services/metadata/controllers/backup"
+	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
+	"px.dev/pixie/src/vizier/utils/datastore/pebbledb"
+	"px.dev/pixie/src/vizier/utils/datastore/snapshot"
+	"px.dev/pixie/src/vizier/utils/objectstore"
+)
+
+func TestServer_CreateListSnapshots(t *testing.T) {
+	c, err := pebble.Open("test", &pebble.Options{
+		FS: vfs.NewMem(),
+	})
+	require.NoError(t, err)
+	db := pebbledb.New(c, 3*time.Second)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	require.NoError(t, db.Set("/agent/1", "a1"))
+	require.NoError(t, db.Set("/agent/2", "a2"))
+
+	store, err := objectstore.NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	s := backup.NewServer(snapshot.NewManager(db, store))
+	ctx := context.Background()
+
+	createResp, err := s.CreateSnapshot(ctx, &metadatapb.CreateSnapshotRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), createResp.Snapshot.NumKeys)
+	assert.NotZero(t, createResp.Snapshot.SizeBytes)
+
+	listResp, err := s.ListSnapshots(ctx, &metadatapb.ListSnapshotsRequest{})
+	require.NoError(t, err)
+	require.Len(t, listResp.Snapshots, 1)
+	assert.Equal(t, createResp.Snapshot.Name, listResp.Snapshots[0].Name)
+	assert.Equal(t, createResp.Snapshot.CreatedAtNS, listResp.Snapshots[0].CreatedAtNS)
+}
+
+func TestServer_NotConfigured(t *testing.T) {
+	s := backup.NewServer(nil)
+	_, err := s.CreateSnapshot(context.Background(), &metadatapb.CreateSnapshotRequest{})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = s.ListSnapshots(context.Background(), &metadatapb.ListSnapshotsRequest{})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+}
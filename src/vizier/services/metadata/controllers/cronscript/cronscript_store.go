@@ -0,0 +1,211 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cronscript
+
+import (
+	"path"
+	"sort"
+
+	"github.com/gofrs/uuid"
+	"github.com/gogo/protobuf/proto"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/shared/services/jwtpb"
+	"px.dev/pixie/src/vizier/utils/datastore"
+)
+
+const (
+	cronScriptsPrefix     = "/cronScript/"
+	cronScriptRunsPrefix  = "/cronScriptRuns/"
+	cronScriptOwnerPrefix = "/cronScriptOwner/"
+)
+
+// Datastore stores cron scripts and their run history in a given Datastore.
+type Datastore struct {
+	ds datastore.MultiGetterSetterDeleterCloser
+}
+
+// NewDatastore wraps the datastore in a cron script store.
+func NewDatastore(ds datastore.MultiGetterSetterDeleterCloser) *Datastore {
+	return &Datastore{ds: ds}
+}
+
+func getCronScriptKey(scriptID uuid.UUID) string {
+	return path.Join(cronScriptsPrefix, scriptID.String())
+}
+
+func getCronScriptOwnerKey(scriptID uuid.UUID) string {
+	return path.Join(cronScriptOwnerPrefix, scriptID.String())
+}
+
+func getCronScriptRunsKey(scriptID uuid.UUID) string {
+	// The trailing slash keeps the prefix from matching the runs of other scripts.
+	return path.Join(cronScriptRunsPrefix, scriptID.String()) + "/"
+}
+
+func getCronScriptRunKey(scriptID uuid.UUID, runID string) string {
+	return path.Join(cronScriptRunsPrefix, scriptID.String(), runID)
+}
+
+// UpsertScript creates or updates a cron script. Its runs are stored separately and are not
+// saved with it.
+func (d *Datastore) UpsertScript(scriptID uuid.UUID, script *vizierpb.ScheduledScript) error {
+	s := *script
+	s.Runs = nil
+	val, err := s.Marshal()
+	if err != nil {
+		return err
+	}
+	return d.ds.Set(getCronScriptKey(scriptID), string(val))
+}
+
+// GetScript gets the cron script with the given ID, if it exists.
+func (d *Datastore) GetScript(scriptID uuid.UUID) (*vizierpb.ScheduledScript, error) {
+	val, err := d.ds.Get(getCronScriptKey(scriptID))
+	if err != nil {
+		return nil, err
+	}
+	if val == nil {
+		return nil, nil
+	}
+	script := &vizierpb.ScheduledScript{}
+	if err := proto.Unmarshal(val, script); err != nil {
+		return nil, err
+	}
+	return script, nil
+}
+
+// GetScripts gets all of the cron scripts in the store.
+func (d *Datastore) GetScripts() ([]*vizierpb.ScheduledScript, error) {
+	_, vals, err := d.ds.GetWithPrefix(cronScriptsPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	scripts := make([]*vizierpb.ScheduledScript, 0, len(vals))
+	for _, val := range vals {
+		script := &vizierpb.ScheduledScript{}
+		if err := proto.Unmarshal(val, script); err != nil {
+			continue
+		}
+		scripts = append(scripts, script)
+	}
+	return scripts, nil
+}
+
+// SetOwner stores the claims of the user who owns the cron script. Runs of the script are
+// executed under these claims.
+func (d *Datastore) SetOwner(scriptID uuid.UUID, owner *jwtpb.JWTClaims) error {
+	val, err := owner.Marshal()
+	if err != nil {
+		return err
+	}
+	return d.ds.Set(getCronScriptOwnerKey(scriptID), string(val))
+}
+
+// GetOwners gets the owners of all of the cron scripts in the store, keyed by script ID.
+func (d *Datastore) GetOwners() (map[string]*jwtpb.JWTClaims, error) {
+	keys, vals, err := d.ds.GetWithPrefix(cronScriptOwnerPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	owners := make(map[string]*jwtpb.JWTClaims, len(vals))
+	for i, val := range vals {
+		owner := &jwtpb.JWTClaims{}
+		if err := proto.Unmarshal(val, owner); err != nil {
+			continue
+		}
+		owners[path.Base(keys[i])] = owner
+	}
+	return owners, nil
+}
+
+// DeleteScript deletes the cron script and its run history. It returns the IDs of the deleted
+// runs.
+func (d *Datastore) DeleteScript(scriptID uuid.UUID) ([]string, error) {
+	runs, err := d.GetRuns(scriptID)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.ds.DeleteAll([]string{getCronScriptKey(scriptID), getCronScriptOwnerKey(scriptID)}); err != nil {
+		return nil, err
+	}
+	if err := d.ds.DeleteWithPrefix(getCronScriptRunsKey(scriptID)); err != nil {
+		return nil, err
+	}
+
+	runIDs := make([]string, len(runs))
+	for i, run := range runs {
+		runIDs[i] = run.ID
+	}
+	return runIDs, nil
+}
+
+// GetRuns gets the run history of the cron script, newest first.
+func (d *Datastore) GetRuns(scriptID uuid.UUID) ([]*vizierpb.ScheduledScriptRun, error) {
+	_, vals, err := d.ds.GetWithPrefix(getCronScriptRunsKey(scriptID))
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make([]*vizierpb.ScheduledScriptRun, 0, len(vals))
+	for _, val := range vals {
+		run := &vizierpb.ScheduledScriptRun{}
+		if err := proto.Unmarshal(val, run); err != nil {
+			continue
+		}
+		runs = append(runs, run)
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].StartTimestampNS > runs[j].StartTimestampNS
+	})
+	return runs, nil
+}
+
+// AddRun adds a run to the history of the cron script, keeping only the newest maxRuns runs. It
+// returns the IDs of the runs that were dropped.
+func (d *Datastore) AddRun(scriptID uuid.UUID, run *vizierpb.ScheduledScriptRun, maxRuns int) ([]string, error) {
+	val, err := run.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	if err := d.ds.Set(getCronScriptRunKey(scriptID, run.ID), string(val)); err != nil {
+		return nil, err
+	}
+
+	runs, err := d.GetRuns(scriptID)
+	if err != nil {
+		return nil, err
+	}
+	if len(runs) <= maxRuns {
+		return nil, nil
+	}
+
+	var evicted []string
+	var keys []string
+	for _, r := range runs[maxRuns:] {
+		evicted = append(evicted, r.ID)
+		keys = append(keys, getCronScriptRunKey(scriptID, r.ID))
+	}
+	if err := d.ds.DeleteAll(keys); err != nil {
+		return nil, err
+	}
+	return evicted, nil
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cronscript
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/vizier/utils/datastore/pebbledb"
+)
+
+func setupTest(t *testing.T) (*Datastore, func()) {
+	memFS := vfs.NewMem()
+	c, err := pebble.Open("test", &pebble.Options{
+		FS: memFS,
+	})
+	if err != nil {
+		t.Fatal("failed to initialize a pebbledb")
+		os.Exit(1)
+	}
+
+	db := pebbledb.New(c, 3*time.Second)
+	ds := NewDatastore(db)
+	cleanup := func() {
+		err := db.Close()
+		if err != nil {
+			t.Fatal("Failed to close db")
+		}
+	}
+
+	return ds, cleanup
+}
+
+func TestCronScriptStore_UpsertAndGetScripts(t *testing.T) {
+	ds, cleanup := setupTest(t)
+	defer cleanup()
+
+	id := uuid.Must(uuid.NewV4())
+	script := &vizierpb.ScheduledScript{
+		ID:             id.String(),
+		Name:           "errors",
+		QueryStr:       "import px",
+		CronExpression: "@hourly",
+		// Runs are stored separately.
+		Runs: []*vizierpb.ScheduledScriptRun{{ID: "run"}},
+	}
+	require.NoError(t, ds.UpsertScript(id, script))
+
+	scripts, err := ds.GetScripts()
+	require.NoError(t, err)
+	require.Len(t, scripts, 1)
+	assert.Equal(t, "errors", scripts[0].Name)
+	assert.Nil(t, scripts[0].Runs)
+
+	script.CronExpression = "@daily"
+	require.NoError(t, ds.UpsertScript(id, script))
+	got, err := ds.GetScript(id)
+	require.NoError(t, err)
+	assert.Equal(t, "@daily", got.CronExpression)
+
+	missing, err := ds.GetScript(uuid.Must(uuid.NewV4()))
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+}
+
+func TestCronScriptStore_AddRunEvictsOldest(t *testing.T) {
+	ds, cleanup := setupTest(t)
+	defer cleanup()
+
+	id := uuid.Must(uuid.NewV4())
+	other := uuid.Must(uuid.NewV4())
+	require.NoError(t, ds.UpsertScript(id, &vizierpb.ScheduledScript{ID: id.String(), Name: "a"}))
+	require.NoError(t, ds.UpsertScript(other, &vizierpb.ScheduledScript{ID: other.String(), Name: "b"}))
+	_, err := ds.AddRun(other, &vizierpb.ScheduledScriptRun{ID: "other", StartTimestampNS: 1}, 2)
+	require.NoError(t, err)
+
+	for i, runID := range []string{"r1", "r2"} {
+		evicted, err := ds.AddRun(id, &vizierpb.ScheduledScriptRun{ID: runID, StartTimestampNS: int64(i + 1)}, 2)
+		require.NoError(t, err)
+		assert.Empty(t, evicted)
+	}
+	evicted, err := ds.AddRun(id, &vizierpb.ScheduledScriptRun{ID: "r3", StartTimestampNS: 3}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"r1"}, evicted)
+
+	runs, err := ds.GetRuns(id)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, "r3", runs[0].ID)
+	assert.Equal(t, "r2", runs[1].ID)
+
+	runIDs, err := ds.DeleteScript(id)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"r2", "r3"}, runIDs)
+	runs, err = ds.GetRuns(id)
+	require.NoError(t, err)
+	assert.Empty(t, runs)
+
+	// The other script's runs are untouched.
+	runs, err = ds.GetRuns(other)
+	require.NoError(t, err)
+	assert.Len(t, runs, 1)
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cronscript
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gofrs/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
+)
+
+// The number of runs kept in the history of each cron script.
+const maxRunsPerScript = 10
+
+// Server implements the MetadataCronScriptService.
+type Server struct {
+	ds *Datastore
+	// Guards upserts, so that two scripts can't be given the same name.
+	mu sync.Mutex
+}
+
+// NewServer creates a server backed by the given store.
+func NewServer(ds *Datastore) *Server {
+	return &Server{ds: ds}
+}
+
+func parseID(id string) (uuid.UUID, error) {
+	u, err := uuid.FromString(id)
+	if err != nil {
+		return uuid.Nil, status.Errorf(codes.InvalidArgument, "invalid cron script ID %q", id)
+	}
+	return u, nil
+}
+
+// GetCronScripts returns all of the cron scripts, along with their recent runs and owners.
+func (s *Server) GetCronScripts(ctx context.Context, req *metadatapb.GetCronScriptsRequest) (*metadatapb.GetCronScriptsResponse, error) {
+	scripts, err := s.ds.GetScripts()
+	if err != nil {
+		return nil, err
+	}
+	for _, script := range scripts {
+		id, err := uuid.FromString(script.ID)
+		if err != nil {
+			continue
+		}
+		script.Runs, err = s.ds.GetRuns(id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	owners, err := s.ds.GetOwners()
+	if err != nil {
+		return nil, err
+	}
+	return &metadatapb.GetCronScriptsResponse{Scripts: scripts, Owners: owners}, nil
+}
+
+// UpsertCronScript creates or updates a cron script.
+func (s *Server) UpsertCronScript(ctx context.Context, req *metadatapb.UpsertCronScriptRequest) (*metadatapb.UpsertCronScriptResponse, error) {
+	script := req.Script
+	if script == nil {
+		return nil, status.Error(codes.InvalidArgument, "missing cron script")
+	}
+	id, err := parseID(script.ID)
+	if err != nil {
+		return nil, err
+	}
+	if script.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "cron script must have a name")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scripts, err := s.ds.GetScripts()
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range scripts {
+		if other.Name == script.Name && other.ID != script.ID {
+			return nil, status.Errorf(codes.AlreadyExists, "a cron script named %q already exists", script.Name)
+		}
+	}
+
+	if err := s.ds.UpsertScript(id, script); err != nil {
+		return nil, err
+	}
+	if req.Owner != nil {
+		if err := s.ds.SetOwner(id, req.Owner); err != nil {
+			return nil, err
+		}
+	}
+	return &metadatapb.UpsertCronScriptResponse{}, nil
+}
+
+// DeleteCronScript deletes a cron script and its run history.
+func (s *Server) DeleteCronScript(ctx context.Context, req *metadatapb.DeleteCronScriptRequest) (*metadatapb.DeleteCronScriptResponse, error) {
+	id, err := parseID(req.ID)
+	if err != nil {
+		return nil, err
+	}
+	script, err := s.ds.GetScript(id)
+	if err != nil {
+		return nil, err
+	}
+	if script == nil {
+		return nil, status.Errorf(codes.NotFound, "cron script %s not found", req.ID)
+	}
+
+	runIDs, err := s.ds.DeleteScript(id)
+	if err != nil {
+		return nil, err
+	}
+	return &metadatapb.DeleteCronScriptResponse{RunIDs: runIDs}, nil
+}
+
+// RecordCronScriptRun adds a run to the history of a cron script.
+func (s *Server) RecordCronScriptRun(ctx context.Context, req *metadatapb.RecordCronScriptRunRequest) (*metadatapb.RecordCronScriptRunResponse, error) {
+	id, err := parseID(req.ScriptID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Run == nil || req.Run.ID == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing cron script run")
+	}
+	script, err := s.ds.GetScript(id)
+	if err != nil {
+		return nil, err
+	}
+	if script == nil {
+		return nil, status.Errorf(codes.NotFound, "cron script %s not found", req.ScriptID)
+	}
+
+	evicted, err := s.ds.AddRun(id, req.Run, maxRunsPerScript)
+	if err != nil {
+		return nil, err
+	}
+	return &metadatapb.RecordCronScriptRunResponse{EvictedRunIDs: evicted}, nil
+}
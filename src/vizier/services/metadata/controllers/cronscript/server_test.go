@@ -0,0 +1,126 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package cronscript_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/shared/services/jwtpb"
+	"px.dev/pixie/src/vizier/services/metadata/controllers/cronscript"
+	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
+	"px.dev/pixie/src/vizier/utils/datastore/pebbledb"
+)
+
+func newTestServer(t *testing.T) *cronscript.Server {
+	c, err := pebble.Open("test", &pebble.Options{
+		FS: vfs.NewMem(),
+	})
+	if err != nil {
+		t.Fatal("failed to initialize a pebbledb")
+		os.Exit(1)
+	}
+	db := pebbledb.New(c, 3*time.Second)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	return cronscript.NewServer(cronscript.NewDatastore(db))
+}
+
+func TestServer_UpsertCronScript(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	id := uuid.Must(uuid.NewV4()).String()
+	_, err := s.UpsertCronScript(ctx, &metadatapb.UpsertCronScriptRequest{
+		Script: &vizierpb.ScheduledScript{ID: id, Name: "errors", CronExpression: "@hourly"},
+		Owner:  &jwtpb.JWTClaims{Subject: "user1"},
+	})
+	require.NoError(t, err)
+
+	// Names are unique.
+	_, err = s.UpsertCronScript(ctx, &metadatapb.UpsertCronScriptRequest{
+		Script: &vizierpb.ScheduledScript{ID: uuid.Must(uuid.NewV4()).String(), Name: "errors"},
+	})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	_, err = s.UpsertCronScript(ctx, &metadatapb.UpsertCronScriptRequest{
+		Script: &vizierpb.ScheduledScript{ID: "not-a-uuid", Name: "other"},
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = s.RecordCronScriptRun(ctx, &metadatapb.RecordCronScriptRunRequest{
+		ScriptID: id,
+		Run:      &vizierpb.ScheduledScriptRun{ID: "run1", StartTimestampNS: 1},
+	})
+	require.NoError(t, err)
+
+	resp, err := s.GetCronScripts(ctx, &metadatapb.GetCronScriptsRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.Scripts, 1)
+	assert.Equal(t, "errors", resp.Scripts[0].Name)
+	require.Len(t, resp.Scripts[0].Runs, 1)
+	assert.Equal(t, "run1", resp.Scripts[0].Runs[0].ID)
+	require.Contains(t, resp.Owners, id)
+	assert.Equal(t, "user1", resp.Owners[id].Subject)
+}
+
+func TestServer_DeleteCronScript(t *testing.T) {
+	s := newTestServer(t)
+	ctx := context.Background()
+
+	id := uuid.Must(uuid.NewV4()).String()
+	_, err := s.UpsertCronScript(ctx, &metadatapb.UpsertCronScriptRequest{
+		Script: &vizierpb.ScheduledScript{ID: id, Name: "errors"},
+		Owner:  &jwtpb.JWTClaims{Subject: "user1"},
+	})
+	require.NoError(t, err)
+	_, err = s.RecordCronScriptRun(ctx, &metadatapb.RecordCronScriptRunRequest{
+		ScriptID: id,
+		Run:      &vizierpb.ScheduledScriptRun{ID: "run1"},
+	})
+	require.NoError(t, err)
+
+	resp, err := s.DeleteCronScript(ctx, &metadatapb.DeleteCronScriptRequest{ID: id})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"run1"}, resp.RunIDs)
+
+	scripts, err := s.GetCronScripts(ctx, &metadatapb.GetCronScriptsRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, scripts.Owners)
+
+	_, err = s.DeleteCronScript(ctx, &metadatapb.DeleteCronScriptRequest{ID: id})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = s.RecordCronScriptRun(ctx, &metadatapb.RecordCronScriptRunRequest{
+		ScriptID: id,
+		Run:      &vizierpb.ScheduledScriptRun{ID: "run2"},
+	})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package k8smeta
+
+import (
+	"strings"
+)
+
+// MetadataAllowList decides which label or annotation keys are propagated to agents. Labels and
+// annotations can be high-cardinality, so nothing is propagated unless it is explicitly allowed.
+type MetadataAllowList struct {
+	keys     map[string]bool
+	prefixes []string
+}
+
+// NewMetadataAllowList creates an allow-list from the given keys. A key ending in "*" matches
+// any key with that prefix, so "app.kubernetes.io/*" allows all of the recommended app labels
+// and "*" allows every key.
+func NewMetadataAllowList(keys []string) *MetadataAllowList {
+	a := &MetadataAllowList{keys: make(map[string]bool)}
+	for _, k := range keys {
+		k = strings.TrimSpace(k)
+		if k == "" {
+			continue
+		}
+		if strings.HasSuffix(k, "*") {
+			a.prefixes = append(a.prefixes, strings.TrimSuffix(k, "*"))
+			continue
+		}
+		a.keys[k] = true
+	}
+	return a
+}
+
+// Allowed returns whether the given key should be propagated.
+func (a *MetadataAllowList) Allowed(key string) bool {
+	if a == nil {
+		return false
+	}
+	if a.keys[key] {
+		return true
+	}
+	for _, p := range a.prefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns the entries of m whose keys are allowed, or nil if there are none.
+func (a *MetadataAllowList) Filter(m map[string]string) map[string]string {
+	var filtered map[string]string
+	for k, v := range m {
+		if !a.Allowed(k) {
+			continue
+		}
+		if filtered == nil {
+			filtered = make(map[string]string)
+		}
+		filtered[k] = v
+	}
+	return filtered
+}
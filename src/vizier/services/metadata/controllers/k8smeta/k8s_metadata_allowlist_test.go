@@ -0,0 +1,81 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package k8smeta_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"px.dev/pixie/src/vizier/services/metadata/controllers/k8smeta"
+)
+
+func TestMetadataAllowList_Filter(t *testing.T) {
+	tests := []struct {
+		name     string
+		keys     []string
+		input    map[string]string
+		expected map[string]string
+	}{
+		{
+			name:     "nothing allowed",
+			keys:     nil,
+			input:    map[string]string{"app": "foo"},
+			expected: nil,
+		},
+		{
+			name:     "exact keys",
+			keys:     []string{"app", " team "},
+			input:    map[string]string{"app": "foo", "team": "bar", "pod-template-hash": "abcd"},
+			expected: map[string]string{"app": "foo", "team": "bar"},
+		},
+		{
+			name: "prefix",
+			keys: []string{"app.kubernetes.io/*"},
+			input: map[string]string{
+				"app.kubernetes.io/name":    "foo",
+				"app.kubernetes.io/version": "v1",
+				"version":                   "v1",
+			},
+			expected: map[string]string{
+				"app.kubernetes.io/name":    "foo",
+				"app.kubernetes.io/version": "v1",
+			},
+		},
+		{
+			name:     "wildcard",
+			keys:     []string{"*"},
+			input:    map[string]string{"app": "foo", "version": "v1"},
+			expected: map[string]string{"app": "foo", "version": "v1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := k8smeta.NewMetadataAllowList(test.keys)
+			assert.Equal(t, test.expected, a.Filter(test.input))
+		})
+	}
+}
+
+func TestMetadataAllowList_Nil(t *testing.T) {
+	var a *k8smeta.MetadataAllowList
+	assert.False(t, a.Allowed("app"))
+	assert.Nil(t, a.Filter(map[string]string{"app": "foo"}))
+}
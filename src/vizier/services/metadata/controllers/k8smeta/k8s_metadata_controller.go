@@ -34,6 +34,7 @@ import (
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
 
+	protoutils "px.dev/pixie/src/shared/k8s"
 	"px.dev/pixie/src/vizier/services/metadata/storepb"
 )
 
@@ -85,10 +86,16 @@ func NewController(mds Store, updateCh chan *K8sResourceMessage) (*Controller, e
 	// Create a watcher for each resource.
 	// The resource types we watch the K8s API for. These types are in a specific order:
 	// for example, nodes and namespaces must be synced before pods, since nodes/namespaces
-	// contain pods.
+	// contain pods. Workloads are synced before pods, so that pods can be resolved to the
+	// workloads that own them.
 	watchers := []watcher{
 		NewNodeWatcher("nodes", updateCh, clientset),
 		NewNamespaceWatcher("namespaces", updateCh, clientset),
+		NewWorkloadWatcher("deployments", protoutils.DeploymentKind, updateCh, clientset.AppsV1().RESTClient()),
+		NewWorkloadWatcher("replicasets", protoutils.ReplicaSetKind, updateCh, clientset.AppsV1().RESTClient()),
+		NewWorkloadWatcher("statefulsets", protoutils.StatefulSetKind, updateCh, clientset.AppsV1().RESTClient()),
+		NewWorkloadWatcher("daemonsets", protoutils.DaemonSetKind, updateCh, clientset.AppsV1().RESTClient()),
+		NewWorkloadWatcher("jobs", protoutils.JobKind, updateCh, clientset.BatchV1().RESTClient()),
 		NewPodWatcher("pods", updateCh, clientset),
 		NewEndpointsWatcher("endpoints", updateCh, clientset),
 		NewServiceWatcher("services", updateCh, clientset),
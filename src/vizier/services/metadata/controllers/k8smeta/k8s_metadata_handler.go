@@ -35,6 +35,7 @@ import (
 	"k8s.io/apimachinery/pkg/watch"
 
 	"px.dev/pixie/src/shared/cvmsgspb"
+	protoutils "px.dev/pixie/src/shared/k8s"
 	"px.dev/pixie/src/shared/k8s/metadatapb"
 	"px.dev/pixie/src/vizier/messages/messagespb"
 	"px.dev/pixie/src/vizier/services/metadata/storepb"
@@ -132,6 +133,16 @@ type ProcessorState struct {
 	NodeToIP map[string]string
 	// A map from pod name to its IP.
 	PodToIP map[string]string
+	// A map from workload UID to the workload that controls it, such as the Deployment that owns
+	// a ReplicaSet. Used to resolve pods to their top-level workloads.
+	WorkloadOwners map[string]*metadatapb.OwnerReference
+	// A map from service name to its annotations, since service updates are sent from endpoints.
+	ServiceAnnotations map[string]map[string]string
+	// The label and annotation keys that are propagated to agents.
+	LabelAllowList      *MetadataAllowList
+	AnnotationAllowList *MetadataAllowList
+	// The nodes in the cluster and the pods on each of them.
+	Topology *Topology
 }
 
 // Handler handles any incoming k8s updates. It saves the update to the store for persistence, and
@@ -153,12 +164,23 @@ type Handler struct {
 	once  sync.Once
 }
 
-// NewHandler creates a new Handler.
-func NewHandler(updateCh <-chan *K8sResourceMessage, mds Store, conn *nats.Conn) *Handler {
+// NewHandler creates a new Handler. Only the labels and annotations allowed by labelAllowList and
+// annotationAllowList are sent to agents.
+func NewHandler(updateCh <-chan *K8sResourceMessage, mds Store, conn *nats.Conn, labelAllowList, annotationAllowList *MetadataAllowList) *Handler {
 	done := make(chan struct{})
 	leaderMsgs := make(map[string]*metadatapb.Endpoints)
 	handlerMap := make(map[string]UpdateProcessor)
-	state := ProcessorState{LeaderMsgs: leaderMsgs, PodCIDRs: make([]string, 0), NodeToIP: make(map[string]string), PodToIP: make(map[string]string)}
+	state := ProcessorState{
+		LeaderMsgs:          leaderMsgs,
+		PodCIDRs:            make([]string, 0),
+		NodeToIP:            make(map[string]string),
+		PodToIP:             make(map[string]string),
+		WorkloadOwners:      make(map[string]*metadatapb.OwnerReference),
+		ServiceAnnotations:  make(map[string]map[string]string),
+		LabelAllowList:      labelAllowList,
+		AnnotationAllowList: annotationAllowList,
+		Topology:            NewTopology(),
+	}
 	mh := &Handler{updateCh: updateCh, mds: mds, conn: conn, done: done, processHandlerMap: handlerMap, state: state}
 
 	// Register update processors.
@@ -167,6 +189,9 @@ func NewHandler(updateCh <-chan *K8sResourceMessage, mds Store, conn *nats.Conn)
 	mh.processHandlerMap["pods"] = &PodUpdateProcessor{}
 	mh.processHandlerMap["nodes"] = &NodeUpdateProcessor{}
 	mh.processHandlerMap["namespaces"] = &NamespaceUpdateProcessor{}
+	for _, resource := range []string{"deployments", "replicasets", "statefulsets", "daemonsets", "jobs"} {
+		mh.processHandlerMap[resource] = &WorkloadUpdateProcessor{}
+	}
 
 	go mh.processUpdates()
 	return mh
@@ -384,6 +409,11 @@ func (m *Handler) GetPodCIDRs() []string {
 	return m.state.PodCIDRs
 }
 
+// GetTopology returns the nodes in the cluster and the pods on each of them.
+func (m *Handler) GetTopology() *Topology {
+	return m.state.Topology
+}
+
 func setDeleted(objMeta *metadatapb.ObjectMetadata) {
 	if objMeta.DeletionTimestampNS != 0 {
 		// Deletion timestamp already set.
@@ -496,13 +526,13 @@ func (p *EndpointsUpdateProcessor) GetUpdatesToSend(storedUpdates []*StoredUpdat
 
 	for ip := range ipToPodNames {
 		updates = append(updates, &OutgoingUpdate{
-			Update: getServiceResourceUpdateFromEndpoint(pb, rv, ipToPodUIDs[ip], ipToPodNames[ip]),
+			Update: getServiceResourceUpdateFromEndpoint(pb, rv, ipToPodUIDs[ip], ipToPodNames[ip], state),
 			Topics: []string{ip},
 		})
 	}
 	// Also send update to Kelvin.
 	updates = append(updates, &OutgoingUpdate{
-		Update: getServiceResourceUpdateFromEndpoint(pb, rv, allPodUIDs, allPodNames),
+		Update: getServiceResourceUpdateFromEndpoint(pb, rv, allPodUIDs, allPodNames, state),
 		Topics: []string{KelvinUpdateTopic},
 	})
 
@@ -535,6 +565,15 @@ func (p *ServiceUpdateProcessor) ValidateUpdate(obj *storepb.K8SResource, state
 	}
 
 	p.updateServiceCIDR(e, state)
+
+	// Cache the service's annotations, which aren't mirrored onto its endpoints.
+	svcName := fmt.Sprintf("%s/%s", e.Metadata.Namespace, e.Metadata.Name)
+	annotations := state.AnnotationAllowList.Filter(e.Metadata.Annotations)
+	if e.Metadata.DeletionTimestampNS != 0 || annotations == nil {
+		delete(state.ServiceAnnotations, svcName)
+	} else {
+		state.ServiceAnnotations[svcName] = annotations
+	}
 	return true
 }
 
@@ -608,6 +647,7 @@ func (p *PodUpdateProcessor) ValidateUpdate(obj *storepb.K8SResource, state *Pro
 	} else {
 		state.PodToIP[podName] = e.Status.HostIP
 	}
+	state.Topology.UpdatePod(e)
 
 	return true
 }
@@ -679,8 +719,12 @@ func (p *PodUpdateProcessor) GetUpdatesToSend(storedUpdates []*StoredUpdate, sta
 		}
 		podUpdate := u.Update.GetPod()
 		if podUpdate != nil {
+			ru := getResourceUpdateFromPod(podUpdate, u.UpdateVersion)
+			ru.GetPodUpdate().Workload = resolveWorkload(podUpdate.Metadata, state)
+			ru.GetPodUpdate().Labels = state.LabelAllowList.Filter(podUpdate.Metadata.Labels)
+			ru.GetPodUpdate().Annotations = state.AnnotationAllowList.Filter(podUpdate.Metadata.Annotations)
 			updates = append(updates, &OutgoingUpdate{
-				Update: getResourceUpdateFromPod(podUpdate, u.UpdateVersion),
+				Update: ru,
 				Topics: topics,
 			})
 		}
@@ -716,6 +760,7 @@ func (p *NodeUpdateProcessor) ValidateUpdate(obj *storepb.K8SResource, state *Pr
 
 	// Create a mapping from node -> IP. This gives us a list of all IPs in the cluster that we need to
 	// send updates to.
+	state.Topology.UpdateNode(n)
 	if n.Metadata.DeletionTimestampNS != 0 {
 		delete(state.NodeToIP, n.Metadata.Name)
 		return true
@@ -796,6 +841,114 @@ func (p *NamespaceUpdateProcessor) GetUpdatesToSend(storedUpdates []*StoredUpdat
 	}
 }
 
+// WorkloadUpdateProcessor is a processor for workload controllers, such as Deployments and Jobs.
+type WorkloadUpdateProcessor struct{}
+
+// IsNodeScoped returns whether this update is scoped to specific nodes, or should be sent to all nodes.
+func (p *WorkloadUpdateProcessor) IsNodeScoped() bool {
+	return false
+}
+
+// SetDeleted sets the deletion timestamp for the object, if there is none already set.
+func (p *WorkloadUpdateProcessor) SetDeleted(obj *storepb.K8SResource) {
+	e := obj.GetWorkload()
+	if e == nil {
+		return
+	}
+	setDeleted(e.Metadata)
+}
+
+// ValidateUpdate checks that the provided workload object is valid, and tracks the workload that controls it.
+func (p *WorkloadUpdateProcessor) ValidateUpdate(obj *storepb.K8SResource, state *ProcessorState) bool {
+	e := obj.GetWorkload()
+	if e == nil {
+		log.WithField("object", obj).Trace("Received non-workload object when handling workload metadata.")
+		return false
+	}
+
+	owner := controllingWorkload(e.Metadata)
+	if e.Metadata.DeletionTimestampNS != 0 || owner == nil {
+		delete(state.WorkloadOwners, e.Metadata.UID)
+	} else {
+		state.WorkloadOwners[e.Metadata.UID] = owner
+	}
+	return true
+}
+
+// GetStoredProtos gets the update protos that should be persisted.
+func (p *WorkloadUpdateProcessor) GetStoredProtos(obj *storepb.K8SResource) []*storepb.K8SResource {
+	return []*storepb.K8SResource{obj}
+}
+
+// GetUpdatesToSend gets the resource updates that should be sent out to the agents, along with the agent IPs that the update should be sent to.
+func (p *WorkloadUpdateProcessor) GetUpdatesToSend(storedUpdates []*StoredUpdate, state *ProcessorState) []*OutgoingUpdate {
+	if len(storedUpdates) == 0 {
+		return nil
+	}
+
+	pb := storedUpdates[0].Update.GetWorkload()
+	rv := storedUpdates[0].UpdateVersion
+
+	// Only top-level workloads are sent out. Pods are resolved to them, so the agents
+	// never need to know about workloads that are controlled by another workload, such as
+	// the ReplicaSets of a Deployment.
+	if controllingWorkload(pb.Metadata) != nil {
+		return nil
+	}
+
+	// Send the update to all agents.
+	agents := []string{KelvinUpdateTopic}
+	for _, ip := range state.NodeToIP {
+		agents = append(agents, ip)
+	}
+	return []*OutgoingUpdate{
+		{
+			Update: getResourceUpdateFromWorkload(pb, rv),
+			Topics: agents,
+		},
+	}
+}
+
+// The kinds of workloads that are tracked, and can control pods or other workloads.
+var workloadKinds = map[string]bool{
+	protoutils.DeploymentKind:  true,
+	protoutils.ReplicaSetKind:  true,
+	protoutils.StatefulSetKind: true,
+	protoutils.DaemonSetKind:   true,
+	protoutils.JobKind:         true,
+}
+
+// The maximum length of the chain of workloads between a pod and its top-level workload. This
+// guards against cycles in the owner references.
+const maxWorkloadDepth = 8
+
+// controllingWorkload returns the tracked workload that owns the object, if any.
+func controllingWorkload(objMeta *metadatapb.ObjectMetadata) *metadatapb.OwnerReference {
+	for _, ref := range objMeta.OwnerReferences {
+		if workloadKinds[ref.Kind] {
+			return ref
+		}
+	}
+	return nil
+}
+
+// resolveWorkload follows the owner references of the object up to the top-level workload
+// that controls it, for example from a pod to the Deployment that owns its ReplicaSet.
+func resolveWorkload(objMeta *metadatapb.ObjectMetadata, state *ProcessorState) *metadatapb.OwnerReference {
+	ref := controllingWorkload(objMeta)
+	if ref == nil {
+		return nil
+	}
+	for i := 0; i < maxWorkloadDepth; i++ {
+		owner, ok := state.WorkloadOwners[ref.UID]
+		if !ok {
+			break
+		}
+		ref = owner
+	}
+	return ref
+}
+
 func formatContainerID(cid string) (metadatapb.ContainerType, string) {
 	// Strip prefixes like docker:// or containerd://
 	tokens := strings.SplitN(cid, "://", 2)
@@ -858,7 +1011,23 @@ func getResourceUpdateFromNamespace(ns *metadatapb.Namespace, uv int64) *metadat
 	}
 }
 
-func getServiceResourceUpdateFromEndpoint(ep *metadatapb.Endpoints, uv int64, podIDs []string, podNames []string) *metadatapb.ResourceUpdate {
+func getResourceUpdateFromWorkload(w *metadatapb.Workload, uv int64) *metadatapb.ResourceUpdate {
+	return &metadatapb.ResourceUpdate{
+		UpdateVersion: uv,
+		Update: &metadatapb.ResourceUpdate_WorkloadUpdate{
+			WorkloadUpdate: &metadatapb.WorkloadUpdate{
+				UID:              w.Metadata.UID,
+				Name:             w.Metadata.Name,
+				Namespace:        w.Metadata.Namespace,
+				Kind:             w.Kind,
+				StartTimestampNS: w.Metadata.CreationTimestampNS,
+				StopTimestampNS:  w.Metadata.DeletionTimestampNS,
+			},
+		},
+	}
+}
+
+func getServiceResourceUpdateFromEndpoint(ep *metadatapb.Endpoints, uv int64, podIDs []string, podNames []string, state *ProcessorState) *metadatapb.ResourceUpdate {
 	update := &metadatapb.ResourceUpdate{
 		UpdateVersion: uv,
 		Update: &metadatapb.ResourceUpdate_ServiceUpdate{
@@ -870,6 +1039,9 @@ func getServiceResourceUpdateFromEndpoint(ep *metadatapb.Endpoints, uv int64, po
 				StopTimestampNS:  ep.Metadata.DeletionTimestampNS,
 				PodIDs:           podIDs,
 				PodNames:         podNames,
+				// Kubernetes mirrors a service's labels onto its endpoints.
+				Labels:      state.LabelAllowList.Filter(ep.Metadata.Labels),
+				Annotations: state.ServiceAnnotations[fmt.Sprintf("%s/%s", ep.Metadata.Namespace, ep.Metadata.Name)],
 			},
 		},
 	}
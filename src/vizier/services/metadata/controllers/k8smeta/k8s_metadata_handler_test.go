@@ -356,7 +356,7 @@ func TestHandler_GetUpdatesForIP(t *testing.T) {
 	require.NoError(t, err)
 
 	updateCh := make(chan *k8smeta.K8sResourceMessage)
-	mdh := k8smeta.NewHandler(updateCh, mds, nil)
+	mdh := k8smeta.NewHandler(updateCh, mds, nil, nil, nil)
 	defer mdh.Stop()
 	updates, err := mdh.GetUpdatesForIP("", 0, 0)
 	require.NoError(t, err)
@@ -400,7 +400,7 @@ func TestHandler_ProcessUpdates(t *testing.T) {
 	nc, natsCleanup := testingutils.MustStartTestNATS(t)
 	defer natsCleanup()
 
-	mdh := k8smeta.NewHandler(updateCh, mds, nc)
+	mdh := k8smeta.NewHandler(updateCh, mds, nc, nil, nil)
 	defer mdh.Stop()
 
 	expectedMsg := &messagespb.VizierMessage{
@@ -1187,3 +1187,201 @@ func TestNamespaceUpdateProcessor_GetUpdatesToSend(t *testing.T) {
 	assert.Contains(t, updates[0].Topics, "127.0.0.1")
 	assert.Contains(t, updates[0].Topics, "127.0.0.2")
 }
+
+func createWorkloadObject(uid string, kind string, name string, owner *metadatapb.OwnerReference) *storepb.K8SResource {
+	md := &metadatapb.ObjectMetadata{
+		Name:                name,
+		Namespace:           "pl",
+		UID:                 uid,
+		ResourceVersion:     "1",
+		CreationTimestampNS: 4,
+	}
+	if owner != nil {
+		md.OwnerReferences = []*metadatapb.OwnerReference{owner}
+	}
+	return &storepb.K8SResource{
+		Resource: &storepb.K8SResource_Workload{
+			Workload: &metadatapb.Workload{
+				Metadata: md,
+				Kind:     kind,
+			},
+		},
+	}
+}
+
+func TestWorkloadUpdateProcessor_ValidateUpdate(t *testing.T) {
+	deployment := &metadatapb.OwnerReference{Kind: "Deployment", Name: "frontend", UID: "d1"}
+	o := createWorkloadObject("rs1", "ReplicaSet", "frontend-abc", deployment)
+
+	state := &k8smeta.ProcessorState{WorkloadOwners: make(map[string]*metadatapb.OwnerReference)}
+	p := k8smeta.WorkloadUpdateProcessor{}
+	assert.True(t, p.ValidateUpdate(o, state))
+	assert.Equal(t, deployment, state.WorkloadOwners["rs1"])
+
+	// Owners that aren't workloads aren't tracked.
+	other := createWorkloadObject("job1", "Job", "backup", &metadatapb.OwnerReference{Kind: "CronJob", Name: "backup", UID: "c1"})
+	assert.True(t, p.ValidateUpdate(other, state))
+	assert.NotContains(t, state.WorkloadOwners, "job1")
+
+	p.SetDeleted(o)
+	assert.True(t, p.ValidateUpdate(o, state))
+	assert.NotContains(t, state.WorkloadOwners, "rs1")
+
+	assert.False(t, p.ValidateUpdate(createNodeObject(), state))
+}
+
+func TestWorkloadUpdateProcessor_GetUpdatesToSend(t *testing.T) {
+	state := &k8smeta.ProcessorState{NodeToIP: map[string]string{"node1": "127.0.0.1"}}
+	p := k8smeta.WorkloadUpdateProcessor{}
+
+	updates := p.GetUpdatesToSend([]*k8smeta.StoredUpdate{
+		{
+			Update:        createWorkloadObject("d1", "Deployment", "frontend", nil),
+			UpdateVersion: 2,
+		},
+	}, state)
+	require.Equal(t, 1, len(updates))
+	assert.Equal(t, &metadatapb.ResourceUpdate{
+		UpdateVersion: 2,
+		Update: &metadatapb.ResourceUpdate_WorkloadUpdate{
+			WorkloadUpdate: &metadatapb.WorkloadUpdate{
+				UID:              "d1",
+				Name:             "frontend",
+				Namespace:        "pl",
+				Kind:             "Deployment",
+				StartTimestampNS: 4,
+			},
+		},
+	}, updates[0].Update)
+	assert.ElementsMatch(t, []string{k8smeta.KelvinUpdateTopic, "127.0.0.1"}, updates[0].Topics)
+
+	// Workloads controlled by another workload aren't sent.
+	updates = p.GetUpdatesToSend([]*k8smeta.StoredUpdate{
+		{
+			Update: createWorkloadObject("rs1", "ReplicaSet", "frontend-abc",
+				&metadatapb.OwnerReference{Kind: "Deployment", Name: "frontend", UID: "d1"}),
+			UpdateVersion: 3,
+		},
+	}, state)
+	assert.Equal(t, 0, len(updates))
+}
+
+func TestPodUpdateProcessor_GetUpdatesToSendResolvesWorkload(t *testing.T) {
+	state := &k8smeta.ProcessorState{WorkloadOwners: make(map[string]*metadatapb.OwnerReference)}
+	wp := k8smeta.WorkloadUpdateProcessor{}
+	deployment := &metadatapb.OwnerReference{Kind: "Deployment", Name: "frontend", UID: "d1"}
+	assert.True(t, wp.ValidateUpdate(createWorkloadObject("rs1", "ReplicaSet", "frontend-abc", deployment), state))
+
+	pod := &metadatapb.Pod{
+		Metadata: &metadatapb.ObjectMetadata{
+			Name:      "frontend-abc-xyz",
+			Namespace: "pl",
+			UID:       "p1",
+			OwnerReferences: []*metadatapb.OwnerReference{
+				{Kind: "ReplicaSet", Name: "frontend-abc", UID: "rs1"},
+			},
+		},
+		Status: &metadatapb.PodStatus{HostIP: "127.0.0.1"},
+	}
+	p := k8smeta.PodUpdateProcessor{}
+	updates := p.GetUpdatesToSend([]*k8smeta.StoredUpdate{
+		{
+			Update: &storepb.K8SResource{
+				Resource: &storepb.K8SResource_Pod{Pod: pod},
+			},
+			UpdateVersion: 5,
+		},
+	}, state)
+	require.Equal(t, 1, len(updates))
+	assert.Equal(t, deployment, updates[0].Update.GetPodUpdate().Workload)
+
+	// Pods whose owner is unknown are resolved as far as possible.
+	pod.Metadata.OwnerReferences[0].UID = "rs2"
+	updates = p.GetUpdatesToSend([]*k8smeta.StoredUpdate{
+		{
+			Update: &storepb.K8SResource{
+				Resource: &storepb.K8SResource_Pod{Pod: pod},
+			},
+			UpdateVersion: 6,
+		},
+	}, state)
+	require.Equal(t, 1, len(updates))
+	assert.Equal(t, "ReplicaSet", updates[0].Update.GetPodUpdate().Workload.Kind)
+}
+
+func TestPodUpdateProcessor_GetUpdatesToSendFiltersMetadata(t *testing.T) {
+	state := &k8smeta.ProcessorState{
+		LabelAllowList:      k8smeta.NewMetadataAllowList([]string{"app", "team"}),
+		AnnotationAllowList: k8smeta.NewMetadataAllowList([]string{"example.com/*"}),
+	}
+
+	pod := &metadatapb.Pod{
+		Metadata: &metadatapb.ObjectMetadata{
+			Name:      "frontend-abc-xyz",
+			Namespace: "pl",
+			UID:       "p1",
+			Labels: map[string]string{
+				"app":               "frontend",
+				"team":              "web",
+				"pod-template-hash": "abc",
+			},
+			Annotations: map[string]string{
+				"example.com/owner":                 "alice",
+				"kubectl.kubernetes.io/restartedAt": "now",
+			},
+		},
+		Status: &metadatapb.PodStatus{HostIP: "127.0.0.1"},
+	}
+	p := k8smeta.PodUpdateProcessor{}
+	updates := p.GetUpdatesToSend([]*k8smeta.StoredUpdate{
+		{
+			Update: &storepb.K8SResource{
+				Resource: &storepb.K8SResource_Pod{Pod: pod},
+			},
+			UpdateVersion: 5,
+		},
+	}, state)
+	require.Equal(t, 1, len(updates))
+	assert.Equal(t, map[string]string{"app": "frontend", "team": "web"}, updates[0].Update.GetPodUpdate().Labels)
+	assert.Equal(t, map[string]string{"example.com/owner": "alice"}, updates[0].Update.GetPodUpdate().Annotations)
+}
+
+func TestEndpointsUpdateProcessor_GetUpdatesToSendWithMetadata(t *testing.T) {
+	state := &k8smeta.ProcessorState{
+		PodToIP: map[string]string{
+			"pl/another-pod": "127.0.0.2",
+			"pl/pod-name":    "127.0.0.1",
+		},
+		ServiceAnnotations:  make(map[string]map[string]string),
+		LabelAllowList:      k8smeta.NewMetadataAllowList([]string{"app"}),
+		AnnotationAllowList: k8smeta.NewMetadataAllowList([]string{"team"}),
+	}
+
+	svc := createServiceObject()
+	svc.GetService().Metadata.Name = "object_md"
+	svc.GetService().Metadata.Namespace = "a_namespace"
+	svc.GetService().Metadata.DeletionTimestampNS = 0
+	svc.GetService().Metadata.Annotations = map[string]string{"team": "web", "other": "value"}
+	sp := k8smeta.ServiceUpdateProcessor{}
+	assert.True(t, sp.ValidateUpdate(svc, state))
+
+	ep := createEndpointsObject()
+	ep.GetEndpoints().Metadata.Labels = map[string]string{"app": "frontend", "version": "v1"}
+	p := k8smeta.EndpointsUpdateProcessor{}
+	updates := p.GetUpdatesToSend([]*k8smeta.StoredUpdate{
+		{
+			Update:        ep,
+			UpdateVersion: 2,
+		},
+	}, state)
+	require.NotEqual(t, 0, len(updates))
+	for _, u := range updates {
+		assert.Equal(t, map[string]string{"app": "frontend"}, u.Update.GetServiceUpdate().Labels)
+		assert.Equal(t, map[string]string{"team": "web"}, u.Update.GetServiceUpdate().Annotations)
+	}
+
+	// Deleting the service drops its cached annotations.
+	sp.SetDeleted(svc)
+	assert.True(t, sp.ValidateUpdate(svc, state))
+	assert.NotContains(t, state.ServiceAnnotations, "a_namespace/object_md")
+}
@@ -0,0 +1,166 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package k8smeta
+
+import (
+	"fmt"
+	"sync"
+
+	"px.dev/pixie/src/shared/k8s/metadatapb"
+)
+
+// Topology tracks the nodes in the cluster and the pods scheduled on each of them, so that agents
+// can be selected by the node they run on and the workloads running alongside them. Only the
+// names, labels and placement of the resources are kept. The returned protos are shared and must
+// not be modified.
+type Topology struct {
+	mu sync.RWMutex
+	// A map from node name to the node.
+	nodes map[string]*metadatapb.Node
+	// A map from node internal IP to the node name.
+	nodeIPs map[string]string
+	// A map from node name to the pods on that node, keyed by <namespace>/<name>.
+	nodePods map[string]map[string]*metadatapb.Pod
+	// A map from <namespace>/<name> to the node the pod is scheduled on.
+	podNodes map[string]string
+}
+
+// NewTopology creates an empty Topology.
+func NewTopology() *Topology {
+	return &Topology{
+		nodes:    make(map[string]*metadatapb.Node),
+		nodeIPs:  make(map[string]string),
+		nodePods: make(map[string]map[string]*metadatapb.Pod),
+		podNodes: make(map[string]string),
+	}
+}
+
+// UpdateNode adds, updates or, if it has been deleted, removes the given node.
+func (t *Topology) UpdateNode(n *metadatapb.Node) {
+	if t == nil || n == nil || n.Metadata == nil {
+		return
+	}
+	name := n.Metadata.Name
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if prev, ok := t.nodes[name]; ok {
+		delete(t.nodeIPs, nodeInternalIP(prev))
+		delete(t.nodes, name)
+	}
+	if n.Metadata.DeletionTimestampNS != 0 {
+		return
+	}
+
+	node := &metadatapb.Node{
+		Metadata: &metadatapb.ObjectMetadata{
+			Name:   name,
+			Labels: n.Metadata.Labels,
+		},
+	}
+	if ip := nodeInternalIP(n); ip != "" {
+		node.Status = &metadatapb.NodeStatus{
+			Addresses: []*metadatapb.NodeAddress{
+				{Type: metadatapb.NODE_ADDR_TYPE_INTERNAL_IP, Address: ip},
+			},
+		}
+		t.nodeIPs[ip] = name
+	}
+	t.nodes[name] = node
+}
+
+// UpdatePod adds, updates or, if it has been deleted or has finished, removes the given pod.
+func (t *Topology) UpdatePod(p *metadatapb.Pod) {
+	if t == nil || p == nil || p.Metadata == nil {
+		return
+	}
+	key := fmt.Sprintf("%s/%s", p.Metadata.Namespace, p.Metadata.Name)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if nodeName, ok := t.podNodes[key]; ok {
+		delete(t.nodePods[nodeName], key)
+		if len(t.nodePods[nodeName]) == 0 {
+			delete(t.nodePods, nodeName)
+		}
+		delete(t.podNodes, key)
+	}
+
+	nodeName := ""
+	if p.Spec != nil {
+		nodeName = p.Spec.NodeName
+	}
+	if nodeName == "" || p.Metadata.DeletionTimestampNS != 0 {
+		return
+	}
+	if p.Status != nil && (p.Status.Phase == metadatapb.SUCCEEDED || p.Status.Phase == metadatapb.FAILED) {
+		return
+	}
+
+	pods, ok := t.nodePods[nodeName]
+	if !ok {
+		pods = make(map[string]*metadatapb.Pod)
+		t.nodePods[nodeName] = pods
+	}
+	pods[key] = &metadatapb.Pod{
+		Metadata: &metadatapb.ObjectMetadata{
+			Name:      p.Metadata.Name,
+			Namespace: p.Metadata.Namespace,
+			Labels:    p.Metadata.Labels,
+		},
+		Spec: &metadatapb.PodSpec{
+			NodeName: nodeName,
+		},
+	}
+	t.podNodes[key] = nodeName
+}
+
+// NodeForHost returns the node with the given internal IP or, failing that, the given name. It
+// returns nil if there is no such node.
+func (t *Topology) NodeForHost(hostIP string, hostname string) *metadatapb.Node {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if name, ok := t.nodeIPs[hostIP]; ok && hostIP != "" {
+		return t.nodes[name]
+	}
+	return t.nodes[hostname]
+}
+
+// PodsOnNode returns the running pods that are scheduled on the node with the given name.
+func (t *Topology) PodsOnNode(nodeName string) []*metadatapb.Pod {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	pods := make([]*metadatapb.Pod, 0, len(t.nodePods[nodeName]))
+	for _, p := range t.nodePods[nodeName] {
+		pods = append(pods, p)
+	}
+	return pods
+}
+
+func nodeInternalIP(n *metadatapb.Node) string {
+	if n.Status == nil {
+		return ""
+	}
+	for _, addr := range n.Status.Addresses {
+		if addr.Type == metadatapb.NODE_ADDR_TYPE_INTERNAL_IP {
+			return addr.Address
+		}
+	}
+	return ""
+}
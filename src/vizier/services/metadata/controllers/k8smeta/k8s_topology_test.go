@@ -0,0 +1,124 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package k8smeta_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/shared/k8s/metadatapb"
+	"px.dev/pixie/src/vizier/services/metadata/controllers/k8smeta"
+)
+
+func topologyNode(name string, ip string, labels map[string]string) *metadatapb.Node {
+	return &metadatapb.Node{
+		Metadata: &metadatapb.ObjectMetadata{
+			Name:   name,
+			Labels: labels,
+		},
+		Status: &metadatapb.NodeStatus{
+			Addresses: []*metadatapb.NodeAddress{
+				{Type: metadatapb.NODE_ADDR_TYPE_EXTERNAL_IP, Address: "1.1.1.1"},
+				{Type: metadatapb.NODE_ADDR_TYPE_INTERNAL_IP, Address: ip},
+			},
+		},
+	}
+}
+
+func topologyPod(namespace string, name string, nodeName string, labels map[string]string) *metadatapb.Pod {
+	return &metadatapb.Pod{
+		Metadata: &metadatapb.ObjectMetadata{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: &metadatapb.PodSpec{
+			NodeName: nodeName,
+		},
+		Status: &metadatapb.PodStatus{
+			Phase: metadatapb.RUNNING,
+		},
+	}
+}
+
+func podNames(pods []*metadatapb.Pod) []string {
+	var names []string
+	for _, p := range pods {
+		names = append(names, p.Metadata.Namespace+"/"+p.Metadata.Name)
+	}
+	return names
+}
+
+func TestTopology_Nodes(t *testing.T) {
+	topo := k8smeta.NewTopology()
+	topo.UpdateNode(topologyNode("node-1", "10.0.0.1", map[string]string{"pool": "a"}))
+	topo.UpdateNode(topologyNode("node-2", "10.0.0.2", map[string]string{"pool": "b"}))
+
+	n := topo.NodeForHost("10.0.0.1", "ignored")
+	require.NotNil(t, n)
+	assert.Equal(t, "node-1", n.Metadata.Name)
+	assert.Equal(t, map[string]string{"pool": "a"}, n.Metadata.Labels)
+
+	// Falls back to the hostname if the IP is unknown.
+	n = topo.NodeForHost("", "node-2")
+	require.NotNil(t, n)
+	assert.Equal(t, "node-2", n.Metadata.Name)
+	assert.Nil(t, topo.NodeForHost("10.0.0.3", "node-3"))
+
+	// The node's IP changes.
+	topo.UpdateNode(topologyNode("node-1", "10.0.0.4", map[string]string{"pool": "c"}))
+	assert.Nil(t, topo.NodeForHost("10.0.0.1", ""))
+	n = topo.NodeForHost("10.0.0.4", "")
+	require.NotNil(t, n)
+	assert.Equal(t, map[string]string{"pool": "c"}, n.Metadata.Labels)
+
+	deleted := topologyNode("node-1", "10.0.0.4", nil)
+	deleted.Metadata.DeletionTimestampNS = 10
+	topo.UpdateNode(deleted)
+	assert.Nil(t, topo.NodeForHost("10.0.0.4", "node-1"))
+}
+
+func TestTopology_Pods(t *testing.T) {
+	topo := k8smeta.NewTopology()
+	topo.UpdatePod(topologyPod("ns1", "pod-1", "node-1", map[string]string{"app": "a"}))
+	topo.UpdatePod(topologyPod("ns2", "pod-2", "node-1", nil))
+	topo.UpdatePod(topologyPod("ns1", "pod-3", "node-2", nil))
+	// Pods that haven't been scheduled yet aren't on any node.
+	topo.UpdatePod(topologyPod("ns1", "pod-4", "", nil))
+
+	assert.ElementsMatch(t, []string{"ns1/pod-1", "ns2/pod-2"}, podNames(topo.PodsOnNode("node-1")))
+	assert.ElementsMatch(t, []string{"ns1/pod-3"}, podNames(topo.PodsOnNode("node-2")))
+
+	// The pod moves to another node.
+	topo.UpdatePod(topologyPod("ns1", "pod-1", "node-2", map[string]string{"app": "a"}))
+	assert.ElementsMatch(t, []string{"ns2/pod-2"}, podNames(topo.PodsOnNode("node-1")))
+	assert.ElementsMatch(t, []string{"ns1/pod-1", "ns1/pod-3"}, podNames(topo.PodsOnNode("node-2")))
+
+	// Finished and deleted pods are removed.
+	finished := topologyPod("ns1", "pod-3", "node-2", nil)
+	finished.Status.Phase = metadatapb.SUCCEEDED
+	topo.UpdatePod(finished)
+	deleted := topologyPod("ns2", "pod-2", "node-1", nil)
+	deleted.Metadata.DeletionTimestampNS = 10
+	topo.UpdatePod(deleted)
+	assert.Equal(t, 0, len(topo.PodsOnNode("node-1")))
+	assert.ElementsMatch(t, []string{"ns1/pod-1"}, podNames(topo.PodsOnNode("node-2")))
+}
@@ -0,0 +1,218 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package k8smeta
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	watchClient "k8s.io/client-go/tools/watch"
+
+	protoutils "px.dev/pixie/src/shared/k8s"
+	"px.dev/pixie/src/shared/k8s/metadatapb"
+	"px.dev/pixie/src/vizier/services/metadata/storepb"
+)
+
+// workloadToProto converts a k8s workload controller, such as a Deployment, into a proto.
+func workloadToProto(o runtime.Object) (*metadatapb.Workload, error) {
+	switch w := o.(type) {
+	case *appsv1.Deployment:
+		return protoutils.DeploymentToProto(w)
+	case *appsv1.ReplicaSet:
+		return protoutils.ReplicaSetToProto(w)
+	case *appsv1.StatefulSet:
+		return protoutils.StatefulSetToProto(w)
+	case *appsv1.DaemonSet:
+		return protoutils.DaemonSetToProto(w)
+	case *batchv1.Job:
+		return protoutils.JobToProto(w)
+	default:
+		return nil, fmt.Errorf("unexpected workload type %T", o)
+	}
+}
+
+// WorkloadWatcher is a resource watcher for workload controllers, such as Deployments or Jobs.
+// Unlike the core resource watchers, a single implementation handles all of the workload kinds.
+type WorkloadWatcher struct {
+	resourceStr string
+	kind        string
+	lastRV      string
+	updateCh    chan *K8sResourceMessage
+	restClient  rest.Interface
+}
+
+// NewWorkloadWatcher creates a resource watcher for the workloads of the given kind. The rest client
+// must be for the API group the resource belongs to, such as apps/v1 for Deployments.
+func NewWorkloadWatcher(resource string, kind string, updateCh chan *K8sResourceMessage, restClient rest.Interface) *WorkloadWatcher {
+	return &WorkloadWatcher{resourceStr: resource, kind: kind, updateCh: updateCh, restClient: restClient}
+}
+
+// Sync syncs the watcher state with the stored updates for the watcher's workloads.
+func (mc *WorkloadWatcher) Sync(storedUpdates []*storepb.K8SResource) error {
+	watcher := cache.NewListWatchFromClient(mc.restClient, mc.resourceStr, v1.NamespaceAll, fields.Everything())
+	list, err := watcher.List(metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	listMeta, err := meta.ListAccessor(list)
+	if err != nil {
+		return err
+	}
+	objs, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+
+	workloads := make([]*metadatapb.Workload, 0, len(objs))
+	for _, o := range objs {
+		pb, err := workloadToProto(o)
+		if err != nil {
+			continue
+		}
+		workloads = append(workloads, pb)
+	}
+	mc.syncWorkloadImpl(storedUpdates, workloads, listMeta.GetResourceVersion())
+	return nil
+}
+
+func (mc *WorkloadWatcher) syncWorkloadImpl(storedUpdates []*storepb.K8SResource, currentState []*metadatapb.Workload, resourceVersion string) {
+	activeResources := make(map[string]bool)
+
+	// Send update for currently active workloads.
+	for _, pb := range currentState {
+		activeResources[pb.Metadata.UID] = true
+
+		msg := &K8sResourceMessage{
+			Object: &storepb.K8SResource{
+				Resource: &storepb.K8SResource_Workload{
+					Workload: pb,
+				},
+			},
+			ObjectType: mc.resourceStr,
+			EventType:  watch.Modified,
+		}
+		mc.updateCh <- msg
+	}
+	mc.lastRV = resourceVersion
+
+	// Make a map of workloads of this kind that we have stored.
+	storedResources := make(map[string]*storepb.K8SResource)
+	for i := range storedUpdates {
+		update := storedUpdates[i].GetWorkload()
+		if update == nil || update.Kind != mc.kind {
+			continue
+		}
+
+		if update.Metadata.DeletionTimestampNS != 0 {
+			// This workload is already terminated, so we don't
+			// need to send another termination event.
+			delete(storedResources, update.Metadata.UID)
+			continue
+		}
+
+		storedResources[update.Metadata.UID] = storedUpdates[i]
+	}
+
+	// For each workload in our store, determine if it is still running. If not, send a termination update.
+	for uid, r := range storedResources {
+		if _, ok := activeResources[uid]; ok {
+			continue
+		}
+
+		msg := &K8sResourceMessage{
+			Object:     r,
+			ObjectType: mc.resourceStr,
+			EventType:  watch.Deleted,
+		}
+		mc.updateCh <- msg
+	}
+}
+
+// StartWatcher starts a watcher for the watcher's workloads.
+func (mc *WorkloadWatcher) StartWatcher(quitCh chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for {
+		watcher := cache.NewListWatchFromClient(mc.restClient, mc.resourceStr, v1.NamespaceAll, fields.Everything())
+		retryWatcher, err := watchClient.NewRetryWatcher(mc.lastRV, watcher)
+		if err != nil {
+			log.WithError(err).Fatal("Could not start watcher for k8s resource: " + mc.resourceStr)
+		}
+
+		resCh := retryWatcher.ResultChan()
+		runWatcher := true
+		for runWatcher {
+			select {
+			case <-quitCh:
+				return
+			case c := <-resCh:
+				s, ok := c.Object.(*metav1.Status)
+				if ok && s.Status == metav1.StatusFailure {
+					if s.Reason == metav1.StatusReasonGone {
+						log.WithField("resource", mc.resourceStr).Info("Requested resource version too old, no longer stored in K8S API")
+						runWatcher = false
+						break
+					}
+					// Ignore and let the retry watcher retry.
+					log.WithField("resource", mc.resourceStr).WithField("object", c.Object).Info("Failed to read from k8s watcher")
+					continue
+				}
+
+				pb, err := workloadToProto(c.Object)
+				if err != nil {
+					continue
+				}
+				// Update the lastRV, so that if the watcher restarts, it starts at the correct resource version.
+				mc.lastRV = pb.Metadata.ResourceVersion
+
+				msg := &K8sResourceMessage{
+					Object: &storepb.K8SResource{
+						Resource: &storepb.K8SResource_Workload{
+							Workload: pb,
+						},
+					},
+					ObjectType: mc.resourceStr,
+					EventType:  c.Type,
+				}
+				mc.updateCh <- msg
+			}
+		}
+
+		log.WithField("resource", mc.resourceStr).Info("K8s watcher channel closed. Retrying")
+
+		// Wait 5 minutes before retrying, however if stop is called, just return.
+		select {
+		case <-quitCh:
+			return
+		case <-time.After(5 * time.Minute):
+			continue
+		}
+	}
+}
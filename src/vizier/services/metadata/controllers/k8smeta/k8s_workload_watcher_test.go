@@ -0,0 +1,74 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package k8smeta
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/watch"
+
+	"px.dev/pixie/src/shared/k8s/metadatapb"
+	"px.dev/pixie/src/vizier/services/metadata/storepb"
+)
+
+func makeWorkload(uid string, kind string, deletionTimestampNS int64) *metadatapb.Workload {
+	return &metadatapb.Workload{
+		Metadata: &metadatapb.ObjectMetadata{
+			Name:                "workload_" + uid,
+			UID:                 uid,
+			CreationTimestampNS: 4,
+			DeletionTimestampNS: deletionTimestampNS,
+		},
+		Kind: kind,
+	}
+}
+
+func TestWorkloadWatcher_SyncWorkloadImpl(t *testing.T) {
+	storedUpdates := []*storepb.K8SResource{
+		// Still running in k8s.
+		{Resource: &storepb.K8SResource_Workload{Workload: makeWorkload("1", "Deployment", 0)}},
+		// Already terminated, so no other termination event should be sent.
+		{Resource: &storepb.K8SResource_Workload{Workload: makeWorkload("2", "Deployment", 5)}},
+		// No longer running in k8s, a termination event should be sent.
+		{Resource: &storepb.K8SResource_Workload{Workload: makeWorkload("3", "Deployment", 0)}},
+		// Belongs to a different watcher.
+		{Resource: &storepb.K8SResource_Workload{Workload: makeWorkload("4", "StatefulSet", 0)}},
+	}
+	currentState := []*metadatapb.Workload{
+		makeWorkload("1", "Deployment", 0),
+		makeWorkload("5", "Deployment", 0),
+	}
+
+	updateCh := make(chan *K8sResourceMessage, 10)
+	watcher := NewWorkloadWatcher("deployments", "Deployment", updateCh, nil)
+	watcher.syncWorkloadImpl(storedUpdates, currentState, "10")
+
+	expectedUIDs := []string{"1", "5", "3"}
+	expectedEventTypes := []watch.EventType{watch.Modified, watch.Modified, watch.Deleted}
+
+	assert.Equal(t, 3, len(updateCh))
+	for i := range expectedUIDs {
+		update := <-updateCh
+		assert.Equal(t, expectedUIDs[i], update.Object.GetWorkload().Metadata.UID)
+		assert.Equal(t, expectedEventTypes[i], update.EventType)
+		assert.Equal(t, "deployments", update.ObjectType)
+	}
+	assert.Equal(t, "10", watcher.lastRV)
+}
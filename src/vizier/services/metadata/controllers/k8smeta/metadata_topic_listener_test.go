@@ -114,7 +114,7 @@ func TestMetadataTopicListener_GetUpdatesInBatches(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			mds := &FakeStore{}
 			updateCh := make(chan *K8sResourceMessage)
-			mdh := NewHandler(updateCh, mds, nil)
+			mdh := NewHandler(updateCh, mds, nil, nil, nil)
 			mdTL, err := NewMetadataTopicListener(mdh, func(topic string, b []byte) error {
 				return nil
 			})
@@ -154,7 +154,7 @@ func TestMetadataTopicListener_GetUpdatesInBatches(t *testing.T) {
 func TestMetadataTopicListener_ProcessAgentMessage(t *testing.T) {
 	mds := &FakeStore{}
 	updateCh := make(chan *K8sResourceMessage)
-	mdh := NewHandler(updateCh, mds, nil)
+	mdh := NewHandler(updateCh, mds, nil, nil, nil)
 
 	sentUpdates := make([]*messagespb.VizierMessage, 0)
 	mdTL, err := NewMetadataTopicListener(mdh, func(topic string, b []byte) error {
@@ -296,6 +296,66 @@ func (s *Server) GetAgentUpdates(req *metadatapb.AgentUpdatesRequest, srv metada
 	}
 }
 
+// ReportAgentQueryFailures records that agents failed to return results for a query, so that they
+// can be quarantined if they keep failing.
+func (s *Server) ReportAgentQueryFailures(ctx context.Context, req *metadatapb.ReportAgentQueryFailuresRequest) (*metadatapb.ReportAgentQueryFailuresResponse, error) {
+	agentIDs := make([]uuid.UUID, len(req.AgentIDs))
+	for i, id := range req.AgentIDs {
+		agentID, err := utils.UUIDFromProto(id)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Invalid agent ID: %+v", err))
+		}
+		agentIDs[i] = agentID
+	}
+
+	err := s.agtMgr.ReportQueryFailures(agentIDs, req.Reason)
+	if err != nil {
+		return nil, err
+	}
+	return &metadatapb.ReportAgentQueryFailuresResponse{}, nil
+}
+
+// GetAgentHealth gets the health scores and quarantine states of agents.
+func (s *Server) GetAgentHealth(ctx context.Context, req *metadatapb.GetAgentHealthRequest) (*metadatapb.GetAgentHealthResponse, error) {
+	agentIDs := make([]uuid.UUID, len(req.AgentIDs))
+	for i, id := range req.AgentIDs {
+		agentID, err := utils.UUIDFromProto(id)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Invalid agent ID: %+v", err))
+		}
+		agentIDs[i] = agentID
+	}
+
+	health, err := s.agtMgr.GetAgentHealth(agentIDs)
+	if err != nil {
+		return nil, err
+	}
+	return &metadatapb.GetAgentHealthResponse{
+		Agents: health,
+	}, nil
+}
+
+// SetAgentQuarantine overrides the automatic quarantine of an agent.
+func (s *Server) SetAgentQuarantine(ctx context.Context, req *metadatapb.SetAgentQuarantineRequest) (*metadatapb.SetAgentQuarantineResponse, error) {
+	agentID, err := utils.UUIDFromProto(req.AgentID)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Invalid agent ID: %+v", err))
+	}
+
+	quarantine, err := s.agtMgr.SetQuarantineOverride(agentID, req.Override)
+	switch {
+	case errors.Is(err, agent.ErrAgentNotFound):
+		return nil, status.Error(codes.NotFound, fmt.Sprintf("Could not find agent %s", agentID.String()))
+	case errors.Is(err, agent.ErrAgentNotQuarantinable):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case err != nil:
+		return nil, err
+	}
+	return &metadatapb.SetAgentQuarantineResponse{
+		Quarantine: quarantine,
+	}, nil
+}
+
 // RegisterTracepoint is a request to register the tracepoints specified in the TracepointDeployment on all agents.
 func (s *Server) RegisterTracepoint(ctx context.Context, req *metadatapb.RegisterTracepointRequest) (*metadatapb.RegisterTracepointResponse, error) {
 	responses := make([]*metadatapb.RegisterTracepointResponse_TracepointStatus, len(req.Requests))
@@ -306,7 +366,26 @@ func (s *Server) RegisterTracepoint(ctx context.Context, req *metadatapb.Registe
 		if err != nil {
 			return nil, err
 		}
-		tracepointID, err := s.tpMgr.CreateTracepoint(tp.Name, tp.TracepointDeployment, ttl)
+		rollout := s.tpMgr.RolloutPolicy(tp.Rollout)
+		var tracepointID *uuid.UUID
+		switch {
+		case rollout != nil:
+			tracepointID, err = s.tpMgr.CreateTracepointWithRollout(tp.Name, tp.TracepointDeployment, ttl, rollout, tp.Selector)
+		case tp.Selector != nil:
+			tracepointID, err = s.tpMgr.CreateTracepointWithSelector(tp.Name, tp.TracepointDeployment, ttl, tp.Selector)
+		default:
+			tracepointID, err = s.tpMgr.CreateTracepoint(tp.Name, tp.TracepointDeployment, ttl)
+		}
+		if err == tracepoint.ErrRolloutInProgress {
+			responses[i] = &metadatapb.RegisterTracepointResponse_TracepointStatus{
+				Status: &statuspb.Status{
+					ErrCode: statuspb.FAILED_PRECONDITION,
+					Msg:     err.Error(),
+				},
+				Name: tp.Name,
+			}
+			continue
+		}
 		if err != nil && err != tracepoint.ErrTracepointAlreadyExists {
 			return nil, err
 		}
@@ -329,6 +408,12 @@ func (s *Server) RegisterTracepoint(ctx context.Context, req *metadatapb.Registe
 			Name: tp.Name,
 		}
 
+		if rollout != nil || tp.Selector != nil {
+			// The tracepoint manager deploys the tracepoint to the agents in waves, or to the agents
+			// that match the selector.
+			continue
+		}
+
 		// Get all agents currently running.
 		agents, err := s.agtMgr.GetActiveAgents()
 		if err != nil {
@@ -403,12 +488,16 @@ func (s *Server) GetTracepointInfo(ctx context.Context, req *metadatapb.GetTrace
 		}
 
 		tracepointState[i] = &metadatapb.GetTracepointInfoResponse_TracepointState{
-			ID:            tp.ID,
-			State:         state,
-			Statuses:      statuses,
-			Name:          tp.Name,
-			ExpectedState: tp.ExpectedState,
-			SchemaNames:   schemas,
+			ID:             tp.ID,
+			State:          state,
+			Statuses:       statuses,
+			Name:           tp.Name,
+			ExpectedState:  tp.ExpectedState,
+			SchemaNames:    schemas,
+			Version:        tp.Version,
+			Rollout:        tp.Rollout,
+			Selector:       tp.Selector,
+			SelectedAgents: int32(len(tp.SelectedAgentIDs)),
 		}
 	}
 
@@ -417,6 +506,18 @@ func (s *Server) GetTracepointInfo(ctx context.Context, req *metadatapb.GetTrace
 	}, nil
 }
 
+// GetTracepointVersions is a request to get the deployment history of a tracepoint.
+func (s *Server) GetTracepointVersions(ctx context.Context, req *metadatapb.GetTracepointVersionsRequest) (*metadatapb.GetTracepointVersionsResponse, error) {
+	versions, current, err := s.tpMgr.GetTracepointVersions(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &metadatapb.GetTracepointVersionsResponse{
+		Versions:       versions,
+		CurrentVersion: current,
+	}, nil
+}
+
 func getTracepointStateFromAgentTracepointStates(agentStates []*storepb.AgentTracepointStatus) (statuspb.LifeCycleState, []*statuspb.Status) {
 	if len(agentStates) == 0 {
 		return statuspb.PENDING_STATE, nil
@@ -480,6 +581,96 @@ func (s *Server) RemoveTracepoint(ctx context.Context, req *metadatapb.RemoveTra
 	}, nil
 }
 
+// GetTracepointDetails is a request to get the stored info, per-agent states and expiry of the
+// given tracepoints.
+func (s *Server) GetTracepointDetails(ctx context.Context, req *metadatapb.GetTracepointDetailsRequest) (*metadatapb.GetTracepointDetailsResponse, error) {
+	var tracepointInfos []*storepb.TracepointInfo
+	var err error
+	if len(req.Names) > 0 {
+		var ids []*uuid.UUID
+		ids, err = s.tpMgr.GetTracepointsWithNames(req.Names)
+		if err != nil {
+			return nil, err
+		}
+		tpIDs := make([]uuid.UUID, len(ids))
+		for i, id := range ids {
+			if id == nil {
+				return nil, status.Error(codes.NotFound, fmt.Sprintf("Could not find tracepoint for given name: %s", req.Names[i]))
+			}
+			tpIDs[i] = *id
+		}
+		tracepointInfos, err = s.tpMgr.GetTracepointsForIDs(tpIDs)
+	} else {
+		tracepointInfos, err = s.tpMgr.GetAllTracepoints()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	expiries, err := s.tpMgr.GetTracepointExpiries()
+	if err != nil {
+		return nil, err
+	}
+
+	details := make([]*metadatapb.GetTracepointDetailsResponse_TracepointDetails, 0, len(tracepointInfos))
+	for _, tp := range tracepointInfos {
+		if tp == nil {
+			continue
+		}
+		tUUID := utils.UUIDFromProtoOrNil(tp.ID)
+		agentStates, err := s.tpMgr.GetTracepointStates(tUUID)
+		if err != nil {
+			return nil, err
+		}
+		state, statuses := getTracepointStateFromAgentTracepointStates(agentStates)
+		var expiresAt int64
+		if expiry, ok := expiries[tUUID]; ok {
+			expiresAt = expiry.UnixNano()
+		}
+		details = append(details, &metadatapb.GetTracepointDetailsResponse_TracepointDetails{
+			Info:          tp,
+			AgentStatuses: agentStates,
+			ExpiresAtNs:   expiresAt,
+			State:         state,
+			Statuses:      statuses,
+		})
+	}
+
+	return &metadatapb.GetTracepointDetailsResponse{
+		Tracepoints: details,
+	}, nil
+}
+
+// ExtendTracepointTTL is a request to reset the TTL of the given tracepoints.
+func (s *Server) ExtendTracepointTTL(ctx context.Context, req *metadatapb.ExtendTracepointTTLRequest) (*metadatapb.ExtendTracepointTTLResponse, error) {
+	ttl, err := types.DurationFromProto(req.TTL)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("Invalid TTL: %+v", err))
+	}
+	if ttl <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "TTL must be positive")
+	}
+
+	err = s.tpMgr.ExtendTracepointTTLs(req.Names, ttl)
+	if errors.Is(err, tracepoint.ErrTracepointNotFound) {
+		return &metadatapb.ExtendTracepointTTLResponse{
+			Status: &statuspb.Status{
+				ErrCode: statuspb.NOT_FOUND,
+				Msg:     err.Error(),
+			},
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &metadatapb.ExtendTracepointTTLResponse{
+		Status: &statuspb.Status{
+			ErrCode: statuspb.OK,
+		},
+	}, nil
+}
+
 // UpdateConfig updates the config for the specified agent.
 func (s *Server) UpdateConfig(ctx context.Context, req *metadatapb.UpdateConfigRequest) (*metadatapb.UpdateConfigResponse, error) {
 	splitName := strings.Split(req.AgentPodName, "/")
@@ -27,6 +27,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
 	"github.com/gofrs/uuid"
 	"github.com/gogo/protobuf/proto"
 	"github.com/gogo/protobuf/types"
@@ -36,7 +38,9 @@ import (
 	"github.com/stretchr/testify/require"
 	"golang.org/x/sync/errgroup"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	grpc_metadata "google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
 
 	"px.dev/pixie/src/api/proto/uuidpb"
@@ -44,6 +48,7 @@ import (
 	"px.dev/pixie/src/carnot/planner/dynamic_tracing/ir/logicalpb"
 	"px.dev/pixie/src/common/base/statuspb"
 	"px.dev/pixie/src/shared/bloomfilterpb"
+	k8s_metadatapb "px.dev/pixie/src/shared/k8s/metadatapb"
 	sharedmetadatapb "px.dev/pixie/src/shared/metadatapb"
 	"px.dev/pixie/src/shared/services/env"
 	"px.dev/pixie/src/shared/services/server"
@@ -52,7 +57,9 @@ import (
 	"px.dev/pixie/src/utils/testingutils"
 	"px.dev/pixie/src/vizier/messages/messagespb"
 	"px.dev/pixie/src/vizier/services/metadata/controllers"
+	"px.dev/pixie/src/vizier/services/metadata/controllers/agent"
 	mock_agent "px.dev/pixie/src/vizier/services/metadata/controllers/agent/mock"
+	"px.dev/pixie/src/vizier/services/metadata/controllers/k8smeta"
 	"px.dev/pixie/src/vizier/services/metadata/controllers/testutils"
 	"px.dev/pixie/src/vizier/services/metadata/controllers/tracepoint"
 	mock_tracepoint "px.dev/pixie/src/vizier/services/metadata/controllers/tracepoint/mock"
@@ -60,6 +67,7 @@ import (
 	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
 	"px.dev/pixie/src/vizier/services/metadata/storepb"
 	"px.dev/pixie/src/vizier/services/shared/agentpb"
+	"px.dev/pixie/src/vizier/utils/datastore/pebbledb"
 )
 
 func testTableInfos() []*storepb.TableInfo {
@@ -316,6 +324,11 @@ func Test_Server_RegisterTracepoint(t *testing.T) {
 		GetTracepointsWithNames([]string{"test_tracepoint"}).
 		Return([]*uuid.UUID{nil}, nil)
 
+	mockTracepointStore.
+		EXPECT().
+		GetTracepointVersions("test_tracepoint").
+		Return(nil, nil)
+
 	var tpID uuid.UUID
 	mockTracepointStore.
 		EXPECT().
@@ -340,6 +353,10 @@ func Test_Server_RegisterTracepoint(t *testing.T) {
 			assert.Equal(t, tpID, id)
 			return nil
 		})
+	mockTracepointStore.
+		EXPECT().
+		SetTracepointVersion("test_tracepoint", gomock.Any()).
+		Return(nil)
 	// Set up server.
 	env, err := metadataenv.New("vizier")
 	if err != nil {
@@ -435,6 +452,11 @@ func Test_Server_RegisterTracepoint_Exists(t *testing.T) {
 		DeleteTracepointTTLs([]uuid.UUID{oldTPID}).
 		Return(nil)
 
+	mockTracepointStore.
+		EXPECT().
+		GetTracepointVersions("test_tracepoint").
+		Return([]*storepb.TracepointVersion{{Version: 1, ID: utils.ProtoFromUUID(oldTPID)}}, nil)
+
 	mockAgtMgr.
 		EXPECT().
 		GetActiveAgents().
@@ -464,6 +486,10 @@ func Test_Server_RegisterTracepoint_Exists(t *testing.T) {
 			assert.Equal(t, tpID, id)
 			return nil
 		})
+	mockTracepointStore.
+		EXPECT().
+		SetTracepointVersion("test_tracepoint", gomock.Any()).
+		Return(nil)
 
 	// Set up server.
 	env, err := metadataenv.New("vizier")
@@ -496,6 +522,98 @@ func Test_Server_RegisterTracepoint_Exists(t *testing.T) {
 	assert.Equal(t, statuspb.OK, resp.Tracepoints[0].Status.ErrCode)
 }
 
+func Test_Server_RegisterTracepoint_Selector(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockAgtMgr := mock_agent.NewMockManager(ctrl)
+
+	c, err := pebble.Open("test", &pebble.Options{
+		FS: vfs.NewMem(),
+	})
+	require.NoError(t, err)
+	db := pebbledb.New(c, 3*time.Second)
+	defer db.Close()
+
+	tracepointMgr := tracepoint.NewManager(tracepoint.NewDatastore(db), mockAgtMgr, 5*time.Second)
+	defer tracepointMgr.Close()
+
+	topology := k8smeta.NewTopology()
+	for i, name := range []string{"node-1", "node-2", "node-3"} {
+		topology.UpdateNode(&k8s_metadatapb.Node{
+			Metadata: &k8s_metadatapb.ObjectMetadata{
+				Name:   name,
+				Labels: map[string]string{"pool": []string{"a", "b", "a"}[i]},
+			},
+			Status: &k8s_metadatapb.NodeStatus{
+				Addresses: []*k8s_metadatapb.NodeAddress{
+					{Type: k8s_metadatapb.NODE_ADDR_TYPE_INTERNAL_IP, Address: fmt.Sprintf("10.0.0.%d", i+1)},
+				},
+			},
+		})
+	}
+	tracepointMgr.SetTopology(topology)
+
+	agentIDs := make([]uuid.UUID, 3)
+	agents := make([]*agentpb.Agent, 3)
+	for i := range agents {
+		agentIDs[i] = uuid.Must(uuid.NewV4())
+		agents[i] = &agentpb.Agent{
+			Info: &agentpb.AgentInfo{
+				AgentID:  utils.ProtoFromUUID(agentIDs[i]),
+				HostInfo: &agentpb.HostInfo{HostIP: fmt.Sprintf("10.0.0.%d", i+1)},
+			},
+		}
+	}
+	mockAgtMgr.
+		EXPECT().
+		GetActiveAgents().
+		Return(agents, nil).
+		AnyTimes()
+
+	var messaged []uuid.UUID
+	mockAgtMgr.
+		EXPECT().
+		MessageAgents(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ids []uuid.UUID, msg []byte) error {
+			messaged = append(messaged, ids...)
+			return nil
+		}).
+		AnyTimes()
+
+	env, err := metadataenv.New("vizier")
+	require.NoError(t, err)
+	s := controllers.NewServer(env, mockAgtMgr, tracepointMgr)
+
+	resp, err := s.RegisterTracepoint(context.Background(), &metadatapb.RegisterTracepointRequest{
+		Requests: []*metadatapb.RegisterTracepointRequest_TracepointRequest{
+			{
+				TracepointDeployment: &logicalpb.TracepointDeployment{
+					Programs: []*logicalpb.TracepointDeployment_TracepointProgram{
+						{TableName: "test"},
+					},
+				},
+				Name:     "test_tracepoint",
+				TTL:      &types.Duration{Seconds: 5},
+				Selector: &storepb.TracepointSelector{NodeLabels: map[string]string{"pool": "a"}},
+			},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(resp.Tracepoints))
+	assert.Equal(t, statuspb.OK, resp.Tracepoints[0].Status.ErrCode)
+
+	// Only the agents on the nodes in pool a get the tracepoint.
+	assert.ElementsMatch(t, []uuid.UUID{agentIDs[0], agentIDs[2]}, messaged)
+
+	details, err := s.GetTracepointDetails(context.Background(), &metadatapb.GetTracepointDetailsRequest{
+		Names: []string{"test_tracepoint"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(details.Tracepoints))
+	assert.ElementsMatch(t, []*uuidpb.UUID{utils.ProtoFromUUID(agentIDs[0]), utils.ProtoFromUUID(agentIDs[2])},
+		details.Tracepoints[0].Info.SelectedAgentIDs)
+}
+
 func Test_Server_GetTracepointInfo(t *testing.T) {
 	tests := []struct {
 		name             string
@@ -728,6 +846,296 @@ func Test_Server_RemoveTracepoint(t *testing.T) {
 	assert.Equal(t, statuspb.OK, resp.Status.ErrCode)
 }
 
+func Test_Server_GetTracepointDetails(t *testing.T) {
+	// Set up mock.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockAgtMgr := mock_agent.NewMockManager(ctrl)
+	mockTracepointStore := mock_tracepoint.NewMockStore(ctrl)
+
+	tracepointMgr := tracepoint.NewManager(mockTracepointStore, mockAgtMgr, 5*time.Second)
+
+	tpID := uuid.Must(uuid.NewV4())
+	agentID := uuid.Must(uuid.NewV4())
+	expiry := time.Now().Add(10 * time.Minute)
+
+	tpInfo := &storepb.TracepointInfo{
+		ID:            utils.ProtoFromUUID(tpID),
+		Name:          "test1",
+		ExpectedState: statuspb.RUNNING_STATE,
+		Version:       2,
+	}
+	agentStates := []*storepb.AgentTracepointStatus{
+		{
+			ID:      utils.ProtoFromUUID(tpID),
+			AgentID: utils.ProtoFromUUID(agentID),
+			State:   statuspb.FAILED_STATE,
+			Status: &statuspb.Status{
+				ErrCode: statuspb.INTERNAL,
+				Msg:     "could not attach probe",
+			},
+		},
+	}
+
+	mockTracepointStore.
+		EXPECT().
+		GetTracepointsWithNames([]string{"test1"}).
+		Return([]*uuid.UUID{&tpID}, nil)
+	mockTracepointStore.
+		EXPECT().
+		GetTracepointsForIDs([]uuid.UUID{tpID}).
+		Return([]*storepb.TracepointInfo{tpInfo}, nil)
+	mockTracepointStore.
+		EXPECT().
+		GetTracepointTTLs().
+		Return([]uuid.UUID{tpID}, []time.Time{expiry}, nil)
+	mockTracepointStore.
+		EXPECT().
+		GetTracepointStates(tpID).
+		Return(agentStates, nil)
+
+	// Set up server.
+	env, err := metadataenv.New("vizier")
+	if err != nil {
+		t.Fatal("Failed to create api environment.")
+	}
+
+	s := controllers.NewServer(env, mockAgtMgr, tracepointMgr)
+
+	resp, err := s.GetTracepointDetails(context.Background(), &metadatapb.GetTracepointDetailsRequest{
+		Names: []string{"test1"},
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Tracepoints, 1)
+	assert.Equal(t, tpInfo, resp.Tracepoints[0].Info)
+	assert.Equal(t, agentStates, resp.Tracepoints[0].AgentStatuses)
+	assert.Equal(t, expiry.UnixNano(), resp.Tracepoints[0].ExpiresAtNs)
+	assert.Equal(t, statuspb.FAILED_STATE, resp.Tracepoints[0].State)
+	assert.Equal(t, []*statuspb.Status{agentStates[0].Status}, resp.Tracepoints[0].Statuses)
+}
+
+func Test_Server_GetTracepointDetails_NotFound(t *testing.T) {
+	// Set up mock.
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockAgtMgr := mock_agent.NewMockManager(ctrl)
+	mockTracepointStore := mock_tracepoint.NewMockStore(ctrl)
+
+	tracepointMgr := tracepoint.NewManager(mockTracepointStore, mockAgtMgr, 5*time.Second)
+
+	mockTracepointStore.
+		EXPECT().
+		GetTracepointsWithNames([]string{"missing"}).
+		Return([]*uuid.UUID{nil}, nil)
+
+	// Set up server.
+	env, err := metadataenv.New("vizier")
+	if err != nil {
+		t.Fatal("Failed to create api environment.")
+	}
+
+	s := controllers.NewServer(env, mockAgtMgr, tracepointMgr)
+
+	_, err = s.GetTracepointDetails(context.Background(), &metadatapb.GetTracepointDetailsRequest{
+		Names: []string{"missing"},
+	})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func Test_Server_ExtendTracepointTTL(t *testing.T) {
+	tests := []struct {
+		name          string
+		expectedState statuspb.LifeCycleState
+		exists        bool
+		expectedCode  statuspb.Code
+	}{
+		{
+			name:          "running tracepoint",
+			expectedState: statuspb.RUNNING_STATE,
+			exists:        true,
+			expectedCode:  statuspb.OK,
+		},
+		{
+			name:          "terminated tracepoint",
+			expectedState: statuspb.TERMINATED_STATE,
+			exists:        true,
+			expectedCode:  statuspb.NOT_FOUND,
+		},
+		{
+			name:         "nonexistent tracepoint",
+			exists:       false,
+			expectedCode: statuspb.NOT_FOUND,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Set up mock.
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockAgtMgr := mock_agent.NewMockManager(ctrl)
+			mockTracepointStore := mock_tracepoint.NewMockStore(ctrl)
+
+			tracepointMgr := tracepoint.NewManager(mockTracepointStore, mockAgtMgr, 5*time.Second)
+
+			tpID := uuid.Must(uuid.NewV4())
+			if !test.exists {
+				mockTracepointStore.
+					EXPECT().
+					GetTracepointsWithNames([]string{"test1"}).
+					Return([]*uuid.UUID{nil}, nil)
+			} else {
+				mockTracepointStore.
+					EXPECT().
+					GetTracepointsWithNames([]string{"test1"}).
+					Return([]*uuid.UUID{&tpID}, nil)
+				mockTracepointStore.
+					EXPECT().
+					GetTracepoint(tpID).
+					Return(&storepb.TracepointInfo{
+						ID:            utils.ProtoFromUUID(tpID),
+						Name:          "test1",
+						ExpectedState: test.expectedState,
+					}, nil)
+			}
+			if test.expectedCode == statuspb.OK {
+				mockTracepointStore.
+					EXPECT().
+					SetTracepointTTL(tpID, 10*time.Minute).
+					Return(nil)
+			}
+
+			// Set up server.
+			env, err := metadataenv.New("vizier")
+			if err != nil {
+				t.Fatal("Failed to create api environment.")
+			}
+
+			s := controllers.NewServer(env, mockAgtMgr, tracepointMgr)
+
+			resp, err := s.ExtendTracepointTTL(context.Background(), &metadatapb.ExtendTracepointTTLRequest{
+				Names: []string{"test1"},
+				TTL:   types.DurationProto(10 * time.Minute),
+			})
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedCode, resp.Status.ErrCode)
+		})
+	}
+}
+
+func Test_Server_ReportAgentQueryFailures(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockAgtMgr := mock_agent.NewMockManager(ctrl)
+
+	agentID := uuid.Must(uuid.NewV4())
+	mockAgtMgr.
+		EXPECT().
+		ReportQueryFailures([]uuid.UUID{agentID}, "timed out").
+		Return(nil)
+
+	env, err := metadataenv.New("vizier")
+	require.NoError(t, err)
+	s := controllers.NewServer(env, mockAgtMgr, nil)
+
+	_, err = s.ReportAgentQueryFailures(context.Background(), &metadatapb.ReportAgentQueryFailuresRequest{
+		AgentIDs: []*uuidpb.UUID{utils.ProtoFromUUID(agentID)},
+		Reason:   "timed out",
+	})
+	require.NoError(t, err)
+}
+
+func Test_Server_GetAgentHealth(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockAgtMgr := mock_agent.NewMockManager(ctrl)
+
+	agentID := uuid.Must(uuid.NewV4())
+	health := []*metadatapb.AgentHealth{
+		{
+			AgentID:             utils.ProtoFromUUID(agentID),
+			Score:               0.4,
+			RecentQueryFailures: 5,
+			Quarantine: &agentpb.AgentQuarantine{
+				Quarantined: true,
+				Reason:      "health score 0.40 is below 0.50",
+			},
+		},
+	}
+	mockAgtMgr.
+		EXPECT().
+		GetAgentHealth([]uuid.UUID{agentID}).
+		Return(health, nil)
+
+	env, err := metadataenv.New("vizier")
+	require.NoError(t, err)
+	s := controllers.NewServer(env, mockAgtMgr, nil)
+
+	resp, err := s.GetAgentHealth(context.Background(), &metadatapb.GetAgentHealthRequest{
+		AgentIDs: []*uuidpb.UUID{utils.ProtoFromUUID(agentID)},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, health, resp.Agents)
+}
+
+func Test_Server_SetAgentQuarantine(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		expectedCode codes.Code
+	}{
+		{
+			name:         "quarantined",
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "nonexistent agent",
+			err:          agent.ErrAgentNotFound,
+			expectedCode: codes.NotFound,
+		},
+		{
+			name:         "kelvin",
+			err:          agent.ErrAgentNotQuarantinable,
+			expectedCode: codes.InvalidArgument,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mockAgtMgr := mock_agent.NewMockManager(ctrl)
+
+			agentID := uuid.Must(uuid.NewV4())
+			quarantine := &agentpb.AgentQuarantine{
+				Quarantined: true,
+				Override:    agentpb.QUARANTINE_OVERRIDE_QUARANTINE,
+				Reason:      "quarantined by an operator",
+			}
+			if test.err != nil {
+				quarantine = nil
+			}
+			mockAgtMgr.
+				EXPECT().
+				SetQuarantineOverride(agentID, agentpb.QUARANTINE_OVERRIDE_QUARANTINE).
+				Return(quarantine, test.err)
+
+			env, err := metadataenv.New("vizier")
+			require.NoError(t, err)
+			s := controllers.NewServer(env, mockAgtMgr, nil)
+
+			resp, err := s.SetAgentQuarantine(context.Background(), &metadatapb.SetAgentQuarantineRequest{
+				AgentID:  utils.ProtoFromUUID(agentID),
+				Override: agentpb.QUARANTINE_OVERRIDE_QUARANTINE,
+			})
+			assert.Equal(t, test.expectedCode, status.Code(err))
+			if test.err == nil {
+				assert.Equal(t, quarantine, resp.Quarantine)
+			}
+		})
+	}
+}
+
 func createDialer(lis *bufconn.Listener) func(ctx context.Context, url string) (net.Conn, error) {
 	return func(ctx context.Context, url string) (conn net.Conn, e error) {
 		return lis.Dial()
@@ -0,0 +1,375 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package tracepoint
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	log "github.com/sirupsen/logrus"
+
+	"px.dev/pixie/src/carnot/planner/dynamic_tracing/ir/logicalpb"
+	"px.dev/pixie/src/common/base/statuspb"
+	"px.dev/pixie/src/utils"
+	"px.dev/pixie/src/vizier/services/metadata/storepb"
+)
+
+const (
+	// rolloutCheckPeriod is how often in-progress rollouts are checked for agents that have
+	// finished registering the tracepoint.
+	rolloutCheckPeriod = 5 * time.Second
+	// defaultWaveTimeout is how long agents have to register a tracepoint, if the rollout policy
+	// doesn't say.
+	defaultWaveTimeout = 2 * time.Minute
+)
+
+// RolloutInProgress returns whether the tracepoint is being rolled out in stages.
+func RolloutInProgress(tp *storepb.TracepointInfo) bool {
+	return tp.Rollout != nil && tp.Rollout.Phase == storepb.ROLLOUT_IN_PROGRESS &&
+		tp.ExpectedState != statuspb.TERMINATED_STATE
+}
+
+// TracepointsForAgent returns which of the given tracepoints should be registered on the given
+// agent, such as when it starts up. Tracepoints that are being rolled out are only registered on
+// the agents the rollout has reached. The other agents keep running the previous version.
+func TracepointsForAgent(tps []*storepb.TracepointInfo, agentID uuid.UUID) []*storepb.TracepointInfo {
+	replaced := make(map[uuid.UUID]bool)
+	skip := make(map[uuid.UUID]bool)
+	for _, tp := range tps {
+		if !RolloutInProgress(tp) {
+			continue
+		}
+		if rolloutTargets(tp.Rollout, agentID) {
+			if tp.Rollout.PreviousID != nil {
+				replaced[utils.UUIDFromProtoOrNil(tp.Rollout.PreviousID)] = true
+			}
+		} else {
+			skip[utils.UUIDFromProtoOrNil(tp.ID)] = true
+		}
+	}
+
+	var out []*storepb.TracepointInfo
+	for _, tp := range tps {
+		id := utils.UUIDFromProtoOrNil(tp.ID)
+		if tp.ExpectedState == statuspb.TERMINATED_STATE || replaced[id] || skip[id] {
+			continue
+		}
+		// New agents are deployed to once they are found to match the selector.
+		if tp.Selector != nil && !RolloutInProgress(tp) && !containsAgent(tp.SelectedAgentIDs, agentID) {
+			continue
+		}
+		out = append(out, tp)
+	}
+	return out
+}
+
+func rolloutTargets(r *storepb.TracepointRollout, agentID uuid.UUID) bool {
+	return containsAgent(r.AgentIDs, agentID)
+}
+
+// normalizeRolloutPolicy fills in the defaults for, and clamps, the fields of a rollout policy.
+func normalizeRolloutPolicy(policy *storepb.TracepointRolloutPolicy) *storepb.TracepointRolloutPolicy {
+	p := proto.Clone(policy).(*storepb.TracepointRolloutPolicy)
+	if p.CanaryAgents < 1 {
+		p.CanaryAgents = 1
+	}
+	if p.WavePercent <= 0 || p.WavePercent > 100 {
+		p.WavePercent = 100
+	}
+	if p.MaxFailurePercent < 0 {
+		p.MaxFailurePercent = 0
+	}
+	if p.MaxFailurePercent > 100 {
+		p.MaxFailurePercent = 100
+	}
+	if timeout, err := types.DurationFromProto(p.WaveTimeout); err != nil || timeout <= 0 {
+		p.WaveTimeout = types.DurationProto(defaultWaveTimeout)
+	}
+	return p
+}
+
+// SetDefaultRolloutPolicy sets the rollout policy used for tracepoints that are registered
+// without one. If nil, such tracepoints are deployed to all agents at once.
+func (m *Manager) SetDefaultRolloutPolicy(policy *storepb.TracepointRolloutPolicy) {
+	m.rolloutMu.Lock()
+	defer m.rolloutMu.Unlock()
+	m.defaultRollout = policy
+}
+
+// RolloutPolicy returns the rollout policy to use for a tracepoint registered with the given
+// policy, which may be nil. It returns nil if the tracepoint should be deployed to all agents at once.
+func (m *Manager) RolloutPolicy(requested *storepb.TracepointRolloutPolicy) *storepb.TracepointRolloutPolicy {
+	if requested != nil {
+		return requested
+	}
+	m.rolloutMu.Lock()
+	defer m.rolloutMu.Unlock()
+	return m.defaultRollout
+}
+
+// CreateTracepointWithRollout creates a tracepoint and starts rolling it out in stages: first to
+// a canary subset of agents, and then in waves once the agents deployed to so far report that the
+// tracepoint is running. If too many agents fail, the tracepoint is removed and the version it
+// replaced is restored. If the selector is set, only the agents that match it are rolled out to.
+func (m *Manager) CreateTracepointWithRollout(tracepointName string, tracepointDeployment *logicalpb.TracepointDeployment, ttl time.Duration, policy *storepb.TracepointRolloutPolicy, selector *storepb.TracepointSelector) (*uuid.UUID, error) {
+	id, err := m.createTracepoint(tracepointName, tracepointDeployment, ttl, normalizeRolloutPolicy(policy), normalizeSelector(selector))
+	if err != nil {
+		return id, err
+	}
+	m.ResumeRollouts()
+
+	m.rolloutMu.Lock()
+	defer m.rolloutMu.Unlock()
+	tp, err := m.ts.GetTracepoint(*id)
+	if err != nil {
+		return nil, err
+	}
+	if tp == nil || !RolloutInProgress(tp) {
+		// Terminated before it could be deployed.
+		return id, nil
+	}
+	// Deploy to the canary agents.
+	return id, m.advanceRollout(tp)
+}
+
+// ResumeRollouts starts checking on the progress of staged rollouts, including ones that were in
+// progress when the metadata service restarted.
+func (m *Manager) ResumeRollouts() {
+	m.rolloutOnce.Do(func() {
+		go m.runRollouts()
+	})
+}
+
+func (m *Manager) runRollouts() {
+	ticker := time.NewTicker(rolloutCheckPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+			m.advanceRollouts()
+		}
+	}
+}
+
+func (m *Manager) advanceRollouts() {
+	// The tracepoints are read under the lock, so that they aren't terminated or changed by
+	// another rollout while they are being advanced.
+	m.rolloutMu.Lock()
+	defer m.rolloutMu.Unlock()
+	tps, err := m.ts.GetTracepoints()
+	if err != nil {
+		log.WithError(err).Warn("Failed to get tracepoints to check rollouts")
+		return
+	}
+	for _, tp := range tps {
+		if tp == nil || !RolloutInProgress(tp) {
+			continue
+		}
+		if err := m.advanceRollout(tp); err != nil {
+			log.WithError(err).WithField("tracepoint", tp.Name).Warn("Failed to advance tracepoint rollout")
+		}
+	}
+}
+
+// advanceRollout checks on the agents the tracepoint has been deployed to so far, and deploys the
+// next wave, rolls back, or completes the rollout. rolloutMu must be held.
+func (m *Manager) advanceRollout(tp *storepb.TracepointInfo) error {
+	r := tp.Rollout
+	id := utils.UUIDFromProtoOrNil(tp.ID)
+
+	agents, err := m.agtMgr.GetActiveAgents()
+	if err != nil {
+		return err
+	}
+	active := make(map[uuid.UUID]bool, len(agents))
+	for _, agt := range agents {
+		active[utils.UUIDFromProtoOrNil(agt.Info.AgentID)] = true
+	}
+
+	if r.Waves > 0 {
+		states, err := m.ts.GetTracepointStates(id)
+		if err != nil {
+			return err
+		}
+		stateByAgent := make(map[uuid.UUID]statuspb.LifeCycleState, len(states))
+		for _, s := range states {
+			stateByAgent[utils.UUIDFromProtoOrNil(s.AgentID)] = s.State
+		}
+
+		var targeted, failed, pending int
+		for _, agentID := range r.AgentIDs {
+			aID := utils.UUIDFromProtoOrNil(agentID)
+			// Agents that have gone away don't count either way.
+			if !active[aID] {
+				continue
+			}
+			targeted++
+			switch stateByAgent[aID] {
+			case statuspb.RUNNING_STATE:
+			case statuspb.FAILED_STATE:
+				failed++
+			default:
+				pending++
+			}
+		}
+
+		timeout, _ := types.DurationFromProto(r.Policy.WaveTimeout)
+		if pending > 0 && time.Since(time.Unix(0, r.WaveStartedAtNS)) > timeout {
+			failed += pending
+			pending = 0
+		}
+		if targeted > 0 && failed*100 > int(r.Policy.MaxFailurePercent)*targeted {
+			return m.rollBack(tp, fmt.Sprintf("%d of %d agents failed to run the tracepoint", failed, targeted))
+		}
+		if pending > 0 {
+			return nil
+		}
+	}
+
+	eligible := m.selectAgents(tp.Selector, agents)
+	var remaining []uuid.UUID
+	for _, agt := range eligible {
+		aID := utils.UUIDFromProtoOrNil(agt.Info.AgentID)
+		if !rolloutTargets(r, aID) {
+			remaining = append(remaining, aID)
+		}
+	}
+	if len(remaining) == 0 {
+		return m.completeRollout(tp)
+	}
+
+	size := int(r.Policy.CanaryAgents)
+	if r.Waves > 0 {
+		// Round up, so that every wave deploys to at least one agent.
+		size = (len(eligible)*int(r.Policy.WavePercent) + 99) / 100
+	}
+	if size > len(remaining) {
+		size = len(remaining)
+	}
+	wave := remaining[:size]
+
+	// Record the wave before deploying it, so that agents that register the tracepoint in the
+	// meantime are counted.
+	for _, aID := range wave {
+		r.AgentIDs = append(r.AgentIDs, utils.ProtoFromUUID(aID))
+	}
+	r.Waves++
+	r.WaveStartedAtNS = time.Now().UnixNano()
+	if err := m.ts.UpsertTracepoint(id, tp); err != nil {
+		return err
+	}
+
+	log.WithField("tracepoint", tp.Name).WithField("version", tp.Version).WithField("wave", r.Waves).
+		Infof("Deploying tracepoint to %d agents", len(wave))
+	if r.PreviousID != nil {
+		msg, err := removeTracepointMsg(utils.UUIDFromProtoOrNil(r.PreviousID))
+		if err != nil {
+			return err
+		}
+		if err := m.agtMgr.MessageAgents(wave, msg); err != nil {
+			return err
+		}
+	}
+	return m.RegisterTracepoint(wave, id, tp.Tracepoint)
+}
+
+// completeRollout marks the rollout as complete, and terminates the version it replaced, which by
+// now has been removed from every agent.
+func (m *Manager) completeRollout(tp *storepb.TracepointInfo) error {
+	tp.Rollout.Phase = storepb.ROLLOUT_COMPLETE
+	if tp.Selector != nil {
+		// From now on, the selector decides which agents the tracepoint is deployed to.
+		tp.SelectedAgentIDs = tp.Rollout.AgentIDs
+	}
+	if err := m.ts.UpsertTracepoint(utils.UUIDFromProtoOrNil(tp.ID), tp); err != nil {
+		return err
+	}
+	log.WithField("tracepoint", tp.Name).WithField("version", tp.Version).Info("Tracepoint rollout complete")
+	if tp.Rollout.PreviousID == nil {
+		return nil
+	}
+	return m.ts.DeleteTracepointTTLs([]uuid.UUID{utils.UUIDFromProtoOrNil(tp.Rollout.PreviousID)})
+}
+
+// rollBack removes the tracepoint from the agents, and restores the version it replaced on the
+// agents that the rollout had reached.
+func (m *Manager) rollBack(tp *storepb.TracepointInfo, reason string) error {
+	id := utils.UUIDFromProtoOrNil(tp.ID)
+	log.WithField("tracepoint", tp.Name).WithField("version", tp.Version).WithField("reason", reason).
+		Warn("Rolling back tracepoint rollout")
+
+	// Mark the rollout as rolled back before terminating the tracepoint, so that the previous
+	// version isn't terminated along with it.
+	tp.Rollout.Phase = storepb.ROLLOUT_ROLLED_BACK
+	if err := m.ts.UpsertTracepoint(id, tp); err != nil {
+		return err
+	}
+	if err := m.ts.DeleteTracepointTTLs([]uuid.UUID{id}); err != nil {
+		return err
+	}
+	if err := m.terminateTracepointLocked(id); err != nil {
+		return err
+	}
+
+	versions, err := m.ts.GetTracepointVersions(tp.Name)
+	if err != nil {
+		return err
+	}
+	for _, v := range versions {
+		if v.Version == tp.Version {
+			v.RolledBack = true
+			if err := m.ts.SetTracepointVersion(tp.Name, v); err != nil {
+				return err
+			}
+		}
+	}
+
+	if tp.Rollout.PreviousID == nil {
+		return nil
+	}
+	prevID := utils.UUIDFromProtoOrNil(tp.Rollout.PreviousID)
+	prev, err := m.ts.GetTracepoint(prevID)
+	if err != nil {
+		return err
+	}
+	if prev == nil || prev.ExpectedState == statuspb.TERMINATED_STATE {
+		return nil
+	}
+	if err := m.ts.SetTracepointWithName(tp.Name, prevID); err != nil {
+		return err
+	}
+	var agentIDs []uuid.UUID
+	for _, agentIDPb := range tp.Rollout.AgentIDs {
+		agentID := utils.UUIDFromProtoOrNil(agentIDPb)
+		// The previous version may only have been deployed to the agents its selector matched.
+		if prev.Selector != nil && !containsAgent(prev.SelectedAgentIDs, agentID) {
+			continue
+		}
+		agentIDs = append(agentIDs, agentID)
+	}
+	if len(agentIDs) == 0 {
+		return nil
+	}
+	return m.RegisterTracepoint(agentIDs, prevID, prev.Tracepoint)
+}
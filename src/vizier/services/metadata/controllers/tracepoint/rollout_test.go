@@ -0,0 +1,370 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package tracepoint
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/carnot/planner/dynamic_tracing/ir/logicalpb"
+	"px.dev/pixie/src/common/base/statuspb"
+	"px.dev/pixie/src/utils"
+	"px.dev/pixie/src/vizier/messages/messagespb"
+	"px.dev/pixie/src/vizier/services/metadata/storepb"
+	"px.dev/pixie/src/vizier/services/shared/agentpb"
+)
+
+type sentMsg struct {
+	agentIDs []uuid.UUID
+	remove   bool
+	id       uuid.UUID
+}
+
+// fakeMessenger records the tracepoint messages sent to agents.
+type fakeMessenger struct {
+	t      *testing.T
+	agents []uuid.UUID
+
+	mu   sync.Mutex
+	sent []sentMsg
+}
+
+func (f *fakeMessenger) GetActiveAgents() ([]*agentpb.Agent, error) {
+	agents := make([]*agentpb.Agent, len(f.agents))
+	for i, id := range f.agents {
+		agents[i] = &agentpb.Agent{
+			Info: &agentpb.AgentInfo{
+				AgentID: utils.ProtoFromUUID(id),
+				HostInfo: &agentpb.HostInfo{
+					HostIP: fmt.Sprintf("10.0.0.%d", i+1),
+				},
+			},
+		}
+	}
+	return agents, nil
+}
+
+func (f *fakeMessenger) MessageAgents(agentIDs []uuid.UUID, msg []byte) error {
+	vzMsg := &messagespb.VizierMessage{}
+	require.NoError(f.t, proto.Unmarshal(msg, vzMsg))
+	tpMsg := vzMsg.GetTracepointMessage()
+	s := sentMsg{agentIDs: agentIDs}
+	if req := tpMsg.GetRemoveTracepointRequest(); req != nil {
+		s.remove = true
+		s.id = utils.UUIDFromProtoOrNil(req.ID)
+	} else {
+		s.id = utils.UUIDFromProtoOrNil(tpMsg.GetRegisterTracepointRequest().ID)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, s)
+	return nil
+}
+
+func (f *fakeMessenger) MessageActiveAgents(msg []byte) error {
+	return f.MessageAgents(f.agents, msg)
+}
+
+// takeSent returns the messages sent to specific agents since the last call.
+func (f *fakeMessenger) takeSent() []sentMsg {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var sent []sentMsg
+	for _, s := range f.sent {
+		// Removals sent to every agent happen in the background when TTLs are deleted.
+		if s.remove && len(s.agentIDs) == len(f.agents) {
+			continue
+		}
+		sent = append(sent, s)
+	}
+	f.sent = nil
+	return sent
+}
+
+func testDeployment(field string) *logicalpb.TracepointDeployment {
+	return &logicalpb.TracepointDeployment{
+		Programs: []*logicalpb.TracepointDeployment_TracepointProgram{
+			{
+				TableName: "table1",
+				Spec: &logicalpb.TracepointSpec{
+					Outputs: []*logicalpb.Output{
+						{
+							Name:   "table1",
+							Fields: []string{field},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func setupRolloutTest(t *testing.T, numAgents int) (*Datastore, *fakeMessenger, *Manager) {
+	_, ts, cleanup := setupTest(t)
+	agtMgr := &fakeMessenger{t: t}
+	for i := 0; i < numAgents; i++ {
+		agtMgr.agents = append(agtMgr.agents, uuid.Must(uuid.NewV4()))
+	}
+	m := NewManager(ts, agtMgr, time.Hour)
+	t.Cleanup(func() {
+		m.Close()
+		cleanup()
+	})
+	return ts, agtMgr, m
+}
+
+func setAgentStates(t *testing.T, m *Manager, tpID uuid.UUID, agentIDs []uuid.UUID, state statuspb.LifeCycleState) {
+	for _, agentID := range agentIDs {
+		require.NoError(t, m.UpdateAgentTracepointStatus(utils.ProtoFromUUID(tpID), utils.ProtoFromUUID(agentID), state, nil))
+	}
+}
+
+func TestRollout_Waves(t *testing.T) {
+	ts, agtMgr, m := setupRolloutTest(t, 4)
+	agents := agtMgr.agents
+
+	id1, err := m.CreateTracepoint("test_tracepoint", testDeployment("abc"), time.Hour)
+	require.NoError(t, err)
+
+	policy := &storepb.TracepointRolloutPolicy{
+		CanaryAgents:      1,
+		WavePercent:       50,
+		MaxFailurePercent: 0,
+		WaveTimeout:       types.DurationProto(time.Hour),
+	}
+	id2, err := m.CreateTracepointWithRollout("test_tracepoint", testDeployment("def"), time.Hour, policy, nil)
+	require.NoError(t, err)
+
+	// The canary replaces the old version.
+	assert.Equal(t, []sentMsg{
+		{agentIDs: agents[:1], remove: true, id: *id1},
+		{agentIDs: agents[:1], id: *id2},
+	}, agtMgr.takeSent())
+
+	// Only the canary runs the new version.
+	tps, err := ts.GetTracepoints()
+	require.NoError(t, err)
+	for i, agentID := range agents {
+		forAgent := TracepointsForAgent(tps, agentID)
+		require.Equal(t, 1, len(forAgent))
+		if i == 0 {
+			assert.Equal(t, utils.ProtoFromUUID(*id2), forAgent[0].ID)
+		} else {
+			assert.Equal(t, utils.ProtoFromUUID(*id1), forAgent[0].ID)
+		}
+	}
+
+	// Nothing happens until the canary reports back.
+	m.advanceRollouts()
+	assert.Equal(t, 0, len(agtMgr.takeSent()))
+
+	setAgentStates(t, m, *id2, agents[:1], statuspb.RUNNING_STATE)
+	m.advanceRollouts()
+	assert.Equal(t, []sentMsg{
+		{agentIDs: agents[1:3], remove: true, id: *id1},
+		{agentIDs: agents[1:3], id: *id2},
+	}, agtMgr.takeSent())
+
+	setAgentStates(t, m, *id2, agents[1:3], statuspb.RUNNING_STATE)
+	m.advanceRollouts()
+	assert.Equal(t, []sentMsg{
+		{agentIDs: agents[3:], remove: true, id: *id1},
+		{agentIDs: agents[3:], id: *id2},
+	}, agtMgr.takeSent())
+
+	setAgentStates(t, m, *id2, agents[3:], statuspb.RUNNING_STATE)
+	m.advanceRollouts()
+
+	tp, err := ts.GetTracepoint(*id2)
+	require.NoError(t, err)
+	assert.Equal(t, storepb.ROLLOUT_COMPLETE, tp.Rollout.Phase)
+	assert.Equal(t, int32(3), tp.Rollout.Waves)
+	assert.Equal(t, int64(2), tp.Version)
+
+	// The old version is no longer kept alive.
+	ttls, _, err := ts.GetTracepointTTLs()
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{*id2}, ttls)
+
+	versions, current, err := m.GetTracepointVersions("test_tracepoint")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), current)
+	require.Equal(t, 2, len(versions))
+	assert.Equal(t, utils.ProtoFromUUID(*id1), versions[0].ID)
+	assert.Equal(t, utils.ProtoFromUUID(*id2), versions[1].ID)
+	assert.False(t, versions[1].RolledBack)
+}
+
+func TestRollout_RollBack(t *testing.T) {
+	tests := []struct {
+		name        string
+		canaryState statuspb.LifeCycleState
+		waveTimeout time.Duration
+	}{
+		{
+			name:        "canary failed",
+			canaryState: statuspb.FAILED_STATE,
+			waveTimeout: time.Hour,
+		},
+		{
+			name:        "canary timed out",
+			canaryState: statuspb.PENDING_STATE,
+			waveTimeout: time.Nanosecond,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts, agtMgr, m := setupRolloutTest(t, 3)
+			agents := agtMgr.agents
+
+			id1, err := m.CreateTracepoint("test_tracepoint", testDeployment("abc"), time.Hour)
+			require.NoError(t, err)
+
+			policy := &storepb.TracepointRolloutPolicy{
+				CanaryAgents:      2,
+				WavePercent:       50,
+				MaxFailurePercent: 50,
+				WaveTimeout:       types.DurationProto(test.waveTimeout),
+			}
+			id2, err := m.CreateTracepointWithRollout("test_tracepoint", testDeployment("def"), time.Hour, policy, nil)
+			require.NoError(t, err)
+			agtMgr.takeSent()
+
+			// Another version can't be rolled out until this one is done.
+			_, err = m.CreateTracepointWithRollout("test_tracepoint", testDeployment("ghi"), time.Hour, policy, nil)
+			assert.Equal(t, ErrRolloutInProgress, err)
+
+			// Half of the canaries failing is within the threshold, but both failing isn't.
+			setAgentStates(t, m, *id2, agents[:1], statuspb.FAILED_STATE)
+			setAgentStates(t, m, *id2, agents[1:2], test.canaryState)
+			m.advanceRollouts()
+
+			assert.Equal(t, []sentMsg{
+				{agentIDs: agents[:2], id: *id1},
+			}, agtMgr.takeSent())
+
+			tp, err := ts.GetTracepoint(*id2)
+			require.NoError(t, err)
+			assert.Equal(t, storepb.ROLLOUT_ROLLED_BACK, tp.Rollout.Phase)
+			assert.Equal(t, statuspb.TERMINATED_STATE, tp.ExpectedState)
+
+			prev, err := ts.GetTracepoint(*id1)
+			require.NoError(t, err)
+			assert.Equal(t, statuspb.RUNNING_STATE, prev.ExpectedState)
+
+			ids, err := ts.GetTracepointsWithNames([]string{"test_tracepoint"})
+			require.NoError(t, err)
+			assert.Equal(t, id1, ids[0])
+
+			versions, current, err := m.GetTracepointVersions("test_tracepoint")
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), current)
+			require.Equal(t, 2, len(versions))
+			assert.True(t, versions[1].RolledBack)
+
+			// Every agent runs the old version again.
+			tps, err := ts.GetTracepoints()
+			require.NoError(t, err)
+			for _, agentID := range agents {
+				forAgent := TracepointsForAgent(tps, agentID)
+				require.Equal(t, 1, len(forAgent))
+				assert.Equal(t, utils.ProtoFromUUID(*id1), forAgent[0].ID)
+			}
+		})
+	}
+}
+
+func TestRollout_ExtendTTL(t *testing.T) {
+	_, _, m := setupRolloutTest(t, 4)
+
+	id1, err := m.CreateTracepoint("test_tracepoint", testDeployment("abc"), time.Minute)
+	require.NoError(t, err)
+	policy := &storepb.TracepointRolloutPolicy{
+		CanaryAgents: 1,
+		WavePercent:  50,
+		WaveTimeout:  types.DurationProto(time.Hour),
+	}
+	id2, err := m.CreateTracepointWithRollout("test_tracepoint", testDeployment("def"), time.Minute, policy, nil)
+	require.NoError(t, err)
+
+	err = m.ExtendTracepointTTLs([]string{"test_tracepoint"}, 2*time.Hour)
+	require.NoError(t, err)
+
+	// The previous version keeps running on the agents the rollout hasn't reached, so it is
+	// extended too.
+	expiries, err := m.GetTracepointExpiries()
+	require.NoError(t, err)
+	require.Contains(t, expiries, *id1)
+	require.Contains(t, expiries, *id2)
+	assert.True(t, expiries[*id1].After(time.Now().Add(time.Hour)))
+	assert.True(t, expiries[*id2].After(time.Now().Add(time.Hour)))
+
+	err = m.ExtendTracepointTTLs([]string{"missing"}, time.Hour)
+	assert.True(t, errors.Is(err, ErrTracepointNotFound))
+
+	// Tracepoints that are terminating can't be extended.
+	require.NoError(t, m.terminateTracepoint(*id2))
+	err = m.ExtendTracepointTTLs([]string{"test_tracepoint"}, time.Hour)
+	assert.True(t, errors.Is(err, ErrTracepointNotFound))
+}
+
+func TestRollout_TerminateDuringRollout(t *testing.T) {
+	ts, agtMgr, m := setupRolloutTest(t, 4)
+
+	policy := &storepb.TracepointRolloutPolicy{
+		CanaryAgents: 1,
+		WavePercent:  50,
+		WaveTimeout:  types.DurationProto(time.Hour),
+	}
+	id, err := m.CreateTracepointWithRollout("test_tracepoint", testDeployment("abc"), time.Hour, policy, nil)
+	require.NoError(t, err)
+	setAgentStates(t, m, *id, agtMgr.agents[:1], statuspb.RUNNING_STATE)
+
+	// Termination waits for the rollouts that are being advanced.
+	m.rolloutMu.Lock()
+	terminated := make(chan error)
+	go func() {
+		terminated <- m.terminateTracepoint(*id)
+	}()
+	select {
+	case <-terminated:
+		t.Fatal("Tracepoint was terminated while its rollout was being advanced")
+	case <-time.After(50 * time.Millisecond):
+	}
+	m.rolloutMu.Unlock()
+	require.NoError(t, <-terminated)
+
+	// Advancing the rollouts doesn't bring back the terminated tracepoint.
+	m.advanceRollouts()
+	tp, err := ts.GetTracepoint(*id)
+	require.NoError(t, err)
+	assert.Equal(t, statuspb.TERMINATED_STATE, tp.ExpectedState)
+	assert.Equal(t, 1, len(tp.Rollout.AgentIDs))
+}
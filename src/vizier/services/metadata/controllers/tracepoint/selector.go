@@ -0,0 +1,280 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package tracepoint
+
+import (
+	"time"
+
+	"github.com/gofrs/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"px.dev/pixie/src/api/proto/uuidpb"
+	"px.dev/pixie/src/carnot/planner/dynamic_tracing/ir/logicalpb"
+	"px.dev/pixie/src/common/base/statuspb"
+	"px.dev/pixie/src/shared/k8s/metadatapb"
+	"px.dev/pixie/src/utils"
+	"px.dev/pixie/src/vizier/services/metadata/storepb"
+	"px.dev/pixie/src/vizier/services/shared/agentpb"
+)
+
+// selectorResyncPeriod is how often the agents that match tracepoint selectors are re-evaluated,
+// so that tracepoints follow the pods they select as the pods move between nodes.
+const selectorResyncPeriod = 15 * time.Second
+
+// Topology is the Kubernetes state that tracepoint selectors are resolved against.
+type Topology interface {
+	// NodeForHost returns the node with the given internal IP or name, or nil if there is none.
+	NodeForHost(hostIP string, hostname string) *metadatapb.Node
+	// PodsOnNode returns the running pods on the node with the given name.
+	PodsOnNode(nodeName string) []*metadatapb.Pod
+}
+
+// normalizeSelector returns nil if the selector doesn't restrict the agents at all.
+func normalizeSelector(sel *storepb.TracepointSelector) *storepb.TracepointSelector {
+	if sel == nil || (len(sel.NodeNames) == 0 && len(sel.NodeLabels) == 0 && len(sel.Namespaces) == 0 &&
+		len(sel.PodLabels) == 0) {
+		return nil
+	}
+	return sel
+}
+
+func labelsMatch(selector map[string]string, labels map[string]string) bool {
+	for k, v := range selector {
+		if l, ok := labels[k]; !ok || l != v {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(strs []string, s string) bool {
+	for _, str := range strs {
+		if str == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAgent(ids []*uuidpb.UUID, agentID uuid.UUID) bool {
+	for _, id := range ids {
+		if utils.UUIDFromProtoOrNil(id) == agentID {
+			return true
+		}
+	}
+	return false
+}
+
+// selectorMatches returns whether the given node, which has the given pods running on it, matches
+// the selector. The node may be nil if it isn't known.
+func selectorMatches(sel *storepb.TracepointSelector, node *metadatapb.Node, pods []*metadatapb.Pod) bool {
+	if node == nil || node.Metadata == nil {
+		return false
+	}
+	if len(sel.NodeNames) > 0 && !containsString(sel.NodeNames, node.Metadata.Name) {
+		return false
+	}
+	if !labelsMatch(sel.NodeLabels, node.Metadata.Labels) {
+		return false
+	}
+	if len(sel.Namespaces) == 0 && len(sel.PodLabels) == 0 {
+		return true
+	}
+	for _, p := range pods {
+		if len(sel.Namespaces) > 0 && !containsString(sel.Namespaces, p.Metadata.Namespace) {
+			continue
+		}
+		if labelsMatch(sel.PodLabels, p.Metadata.Labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetTopology sets the Kubernetes state that tracepoint selectors are resolved against, and starts
+// re-evaluating the selectors of deployed tracepoints as the state changes.
+func (m *Manager) SetTopology(topology Topology) {
+	m.rolloutMu.Lock()
+	m.topology = topology
+	m.rolloutMu.Unlock()
+
+	m.selectorOnce.Do(func() {
+		go m.runSelectors()
+	})
+}
+
+// selectAgents returns the agents that run on the nodes matching the selector. rolloutMu must be held.
+func (m *Manager) selectAgents(sel *storepb.TracepointSelector, agents []*agentpb.Agent) []*agentpb.Agent {
+	if sel == nil {
+		return agents
+	}
+	if m.topology == nil {
+		log.Warn("Tracepoint selectors can't be resolved without the Kubernetes topology")
+		return nil
+	}
+
+	var selected []*agentpb.Agent
+	for _, agt := range agents {
+		hostInfo := agt.Info.HostInfo
+		if hostInfo == nil {
+			continue
+		}
+		node := m.topology.NodeForHost(hostInfo.HostIP, hostInfo.Hostname)
+		var pods []*metadatapb.Pod
+		if node != nil {
+			pods = m.topology.PodsOnNode(node.Metadata.Name)
+		}
+		if selectorMatches(sel, node, pods) {
+			selected = append(selected, agt)
+		}
+	}
+	return selected
+}
+
+// CreateTracepointWithSelector creates a tracepoint and deploys it to the agents on the nodes that
+// match the selector. The tracepoint is deployed to, or removed from, agents as the nodes they run
+// on start or stop matching.
+func (m *Manager) CreateTracepointWithSelector(tracepointName string, tracepointDeployment *logicalpb.TracepointDeployment, ttl time.Duration, selector *storepb.TracepointSelector) (*uuid.UUID, error) {
+	id, err := m.createTracepoint(tracepointName, tracepointDeployment, ttl, nil, normalizeSelector(selector))
+	if err != nil {
+		return id, err
+	}
+
+	agents, err := m.agtMgr.GetActiveAgents()
+	if err != nil {
+		return nil, err
+	}
+	m.rolloutMu.Lock()
+	defer m.rolloutMu.Unlock()
+	tp, err := m.ts.GetTracepoint(*id)
+	if err != nil {
+		return nil, err
+	}
+	if tp == nil || tp.ExpectedState == statuspb.TERMINATED_STATE {
+		// Terminated before it could be deployed.
+		return id, nil
+	}
+	return id, m.reconcileSelector(tp, agents)
+}
+
+func (m *Manager) runSelectors() {
+	ticker := time.NewTicker(selectorResyncPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return
+		case <-ticker.C:
+			m.reconcileSelectors()
+		}
+	}
+}
+
+func (m *Manager) reconcileSelectors() {
+	agents, err := m.agtMgr.GetActiveAgents()
+	if err != nil {
+		log.WithError(err).Warn("Failed to get agents to check tracepoint selectors")
+		return
+	}
+
+	// The tracepoints are read under the lock, so that they aren't terminated or changed by a
+	// rollout while their agents are being updated.
+	m.rolloutMu.Lock()
+	defer m.rolloutMu.Unlock()
+	tps, err := m.ts.GetTracepoints()
+	if err != nil {
+		log.WithError(err).Warn("Failed to get tracepoints to check selectors")
+		return
+	}
+
+	// Tracepoints that are being rolled out, or replaced by a rollout, are deployed by the rollout.
+	replaced := make(map[uuid.UUID]bool)
+	for _, tp := range tps {
+		if tp != nil && RolloutInProgress(tp) && tp.Rollout.PreviousID != nil {
+			replaced[utils.UUIDFromProtoOrNil(tp.Rollout.PreviousID)] = true
+		}
+	}
+
+	for _, tp := range tps {
+		if tp == nil || tp.Selector == nil || tp.ExpectedState == statuspb.TERMINATED_STATE ||
+			RolloutInProgress(tp) || replaced[utils.UUIDFromProtoOrNil(tp.ID)] {
+			continue
+		}
+		if err := m.reconcileSelector(tp, agents); err != nil {
+			log.WithError(err).WithField("tracepoint", tp.Name).Warn("Failed to update the agents selected for tracepoint")
+		}
+	}
+}
+
+// reconcileSelector deploys the tracepoint to the agents that newly match its selector, and
+// removes it from the agents that no longer match. rolloutMu must be held.
+func (m *Manager) reconcileSelector(tp *storepb.TracepointInfo, agents []*agentpb.Agent) error {
+	id := utils.UUIDFromProtoOrNil(tp.ID)
+	active := make(map[uuid.UUID]bool, len(agents))
+	for _, agt := range agents {
+		active[utils.UUIDFromProtoOrNil(agt.Info.AgentID)] = true
+	}
+
+	var selectedIDs []*uuidpb.UUID
+	var added []uuid.UUID
+	for _, agt := range m.selectAgents(tp.Selector, agents) {
+		agentID := utils.UUIDFromProtoOrNil(agt.Info.AgentID)
+		selectedIDs = append(selectedIDs, agt.Info.AgentID)
+		if !containsAgent(tp.SelectedAgentIDs, agentID) {
+			added = append(added, agentID)
+		}
+	}
+	var removed []uuid.UUID
+	stale := false
+	for _, agentIDPb := range tp.SelectedAgentIDs {
+		agentID := utils.UUIDFromProtoOrNil(agentIDPb)
+		if containsAgent(selectedIDs, agentID) {
+			continue
+		}
+		// Agents that have gone away don't need the tracepoint removed.
+		stale = true
+		if active[agentID] {
+			removed = append(removed, agentID)
+		}
+	}
+	if len(added) == 0 && !stale {
+		return nil
+	}
+
+	tp.SelectedAgentIDs = selectedIDs
+	if err := m.ts.UpsertTracepoint(id, tp); err != nil {
+		return err
+	}
+
+	log.WithField("tracepoint", tp.Name).WithField("added", len(added)).WithField("removed", len(removed)).
+		Info("Updating the agents selected for tracepoint")
+	if len(removed) > 0 {
+		msg, err := removeTracepointMsg(id)
+		if err != nil {
+			return err
+		}
+		if err := m.agtMgr.MessageAgents(removed, msg); err != nil {
+			return err
+		}
+	}
+	if len(added) > 0 {
+		return m.RegisterTracepoint(added, id, tp.Tracepoint)
+	}
+	return nil
+}
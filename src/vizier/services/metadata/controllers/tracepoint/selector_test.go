@@ -0,0 +1,245 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package tracepoint
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/common/base/statuspb"
+	"px.dev/pixie/src/shared/k8s/metadatapb"
+	"px.dev/pixie/src/utils"
+	"px.dev/pixie/src/vizier/services/metadata/controllers/k8smeta"
+	"px.dev/pixie/src/vizier/services/metadata/storepb"
+)
+
+func selectorNode(name string, ip string, labels map[string]string) *metadatapb.Node {
+	return &metadatapb.Node{
+		Metadata: &metadatapb.ObjectMetadata{
+			Name:   name,
+			Labels: labels,
+		},
+		Status: &metadatapb.NodeStatus{
+			Addresses: []*metadatapb.NodeAddress{
+				{Type: metadatapb.NODE_ADDR_TYPE_INTERNAL_IP, Address: ip},
+			},
+		},
+	}
+}
+
+func selectorPod(namespace string, name string, nodeName string, labels map[string]string) *metadatapb.Pod {
+	return &metadatapb.Pod{
+		Metadata: &metadatapb.ObjectMetadata{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: &metadatapb.PodSpec{
+			NodeName: nodeName,
+		},
+	}
+}
+
+func TestSelectorMatches(t *testing.T) {
+	node := selectorNode("node-1", "10.0.0.1", map[string]string{"pool": "a", "zone": "us-west1"})
+	pods := []*metadatapb.Pod{
+		selectorPod("ns1", "pod-1", "node-1", map[string]string{"app": "frontend"}),
+		selectorPod("ns2", "pod-2", "node-1", map[string]string{"app": "backend"}),
+	}
+
+	tests := []struct {
+		name     string
+		selector *storepb.TracepointSelector
+		node     *metadatapb.Node
+		expected bool
+	}{
+		{
+			name:     "node name",
+			selector: &storepb.TracepointSelector{NodeNames: []string{"node-2", "node-1"}},
+			node:     node,
+			expected: true,
+		},
+		{
+			name:     "other node name",
+			selector: &storepb.TracepointSelector{NodeNames: []string{"node-2"}},
+			node:     node,
+			expected: false,
+		},
+		{
+			name:     "node labels",
+			selector: &storepb.TracepointSelector{NodeLabels: map[string]string{"pool": "a", "zone": "us-west1"}},
+			node:     node,
+			expected: true,
+		},
+		{
+			name:     "other node labels",
+			selector: &storepb.TracepointSelector{NodeLabels: map[string]string{"pool": "a", "zone": "us-east1"}},
+			node:     node,
+			expected: false,
+		},
+		{
+			name:     "namespace",
+			selector: &storepb.TracepointSelector{Namespaces: []string{"ns2"}},
+			node:     node,
+			expected: true,
+		},
+		{
+			name:     "other namespace",
+			selector: &storepb.TracepointSelector{Namespaces: []string{"ns3"}},
+			node:     node,
+			expected: false,
+		},
+		{
+			name:     "pod labels",
+			selector: &storepb.TracepointSelector{PodLabels: map[string]string{"app": "backend"}},
+			node:     node,
+			expected: true,
+		},
+		{
+			name: "pod labels in another namespace",
+			selector: &storepb.TracepointSelector{
+				Namespaces: []string{"ns1"},
+				PodLabels:  map[string]string{"app": "backend"},
+			},
+			node:     node,
+			expected: false,
+		},
+		{
+			name: "all criteria",
+			selector: &storepb.TracepointSelector{
+				NodeNames:  []string{"node-1"},
+				NodeLabels: map[string]string{"pool": "a"},
+				Namespaces: []string{"ns1"},
+				PodLabels:  map[string]string{"app": "frontend"},
+			},
+			node:     node,
+			expected: true,
+		},
+		{
+			name:     "unknown node",
+			selector: &storepb.TracepointSelector{NodeLabels: map[string]string{"pool": "a"}},
+			node:     nil,
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, selectorMatches(test.selector, test.node, pods))
+		})
+	}
+}
+
+func TestNormalizeSelector(t *testing.T) {
+	assert.Nil(t, normalizeSelector(nil))
+	assert.Nil(t, normalizeSelector(&storepb.TracepointSelector{}))
+	sel := &storepb.TracepointSelector{Namespaces: []string{"ns1"}}
+	assert.Equal(t, sel, normalizeSelector(sel))
+}
+
+func setupSelectorTest(t *testing.T) (*Datastore, *fakeMessenger, *Manager, *k8smeta.Topology) {
+	ts, agtMgr, m := setupRolloutTest(t, 3)
+	topo := k8smeta.NewTopology()
+	topo.UpdateNode(selectorNode("node-1", "10.0.0.1", map[string]string{"pool": "a"}))
+	topo.UpdateNode(selectorNode("node-2", "10.0.0.2", map[string]string{"pool": "b"}))
+	topo.UpdateNode(selectorNode("node-3", "10.0.0.3", map[string]string{"pool": "a"}))
+	m.SetTopology(topo)
+	return ts, agtMgr, m, topo
+}
+
+func TestSelector_FollowsPods(t *testing.T) {
+	ts, agtMgr, m, topo := setupSelectorTest(t)
+	agents := agtMgr.agents
+
+	topo.UpdatePod(selectorPod("ns1", "pod-1", "node-1", nil))
+	selector := &storepb.TracepointSelector{Namespaces: []string{"ns1"}}
+	id, err := m.CreateTracepointWithSelector("test_tracepoint", testDeployment("abc"), time.Hour, selector)
+	require.NoError(t, err)
+	assert.Equal(t, []sentMsg{
+		{agentIDs: agents[:1], id: *id},
+	}, agtMgr.takeSent())
+
+	tps, err := ts.GetTracepoints()
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(TracepointsForAgent(tps, agents[0])))
+	assert.Equal(t, 0, len(TracepointsForAgent(tps, agents[1])))
+
+	// Nothing changes until the pod moves.
+	m.reconcileSelectors()
+	assert.Equal(t, 0, len(agtMgr.takeSent()))
+
+	topo.UpdatePod(selectorPod("ns1", "pod-1", "node-2", nil))
+	m.reconcileSelectors()
+	assert.Equal(t, []sentMsg{
+		{agentIDs: agents[:1], remove: true, id: *id},
+		{agentIDs: agents[1:2], id: *id},
+	}, agtMgr.takeSent())
+
+	tps, err = ts.GetTracepoints()
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(TracepointsForAgent(tps, agents[0])))
+	assert.Equal(t, 1, len(TracepointsForAgent(tps, agents[1])))
+
+	// The tracepoint is kept once the agent that no longer matches has removed it.
+	setAgentStates(t, m, *id, agents[:1], statuspb.TERMINATED_STATE)
+	tp, err := ts.GetTracepoint(*id)
+	require.NoError(t, err)
+	require.NotNil(t, tp)
+	require.Equal(t, 1, len(tp.SelectedAgentIDs))
+	assert.Equal(t, utils.ProtoFromUUID(agents[1]), tp.SelectedAgentIDs[0])
+}
+
+func TestSelector_Rollout(t *testing.T) {
+	ts, agtMgr, m, _ := setupSelectorTest(t)
+	agents := agtMgr.agents
+
+	policy := &storepb.TracepointRolloutPolicy{
+		CanaryAgents: 1,
+		WavePercent:  100,
+		WaveTimeout:  types.DurationProto(time.Hour),
+	}
+	selector := &storepb.TracepointSelector{NodeLabels: map[string]string{"pool": "a"}}
+	id, err := m.CreateTracepointWithRollout("test_tracepoint", testDeployment("abc"), time.Hour, policy, selector)
+	require.NoError(t, err)
+	assert.Equal(t, []sentMsg{
+		{agentIDs: agents[:1], id: *id},
+	}, agtMgr.takeSent())
+
+	setAgentStates(t, m, *id, agents[:1], statuspb.RUNNING_STATE)
+	m.advanceRollouts()
+	// The agent on the node in the other pool is skipped.
+	assert.Equal(t, []sentMsg{
+		{agentIDs: agents[2:], id: *id},
+	}, agtMgr.takeSent())
+
+	setAgentStates(t, m, *id, agents[2:], statuspb.RUNNING_STATE)
+	m.advanceRollouts()
+	tp, err := ts.GetTracepoint(*id)
+	require.NoError(t, err)
+	assert.Equal(t, storepb.ROLLOUT_COMPLETE, tp.Rollout.Phase)
+	assert.Equal(t, 2, len(tp.SelectedAgentIDs))
+
+	// Once the rollout is complete, the selector keeps the agents up to date.
+	m.reconcileSelectors()
+	assert.Equal(t, 0, len(agtMgr.takeSent()))
+}
@@ -34,16 +34,24 @@ import (
 	"px.dev/pixie/src/utils"
 	"px.dev/pixie/src/vizier/messages/messagespb"
 	"px.dev/pixie/src/vizier/services/metadata/storepb"
+	"px.dev/pixie/src/vizier/services/shared/agentpb"
+	"px.dev/pixie/src/vizier/utils/datastore/watch"
 )
 
 var (
 	// ErrTracepointAlreadyExists is produced if a tracepoint already exists with the given name
 	// and does not have a matching schema.
 	ErrTracepointAlreadyExists = errors.New("TracepointDeployment already exists")
+	// ErrRolloutInProgress is produced if a staged rollout is started for a tracepoint whose
+	// previous version is still being rolled out.
+	ErrRolloutInProgress = errors.New("a rollout of this tracepoint is already in progress")
+	// ErrTracepointNotFound is produced if there is no running tracepoint with the given name.
+	ErrTracepointNotFound = errors.New("tracepoint not found")
 )
 
 // agentMessenger is a controller that lets us message all agents and all active agents.
 type agentMessenger interface {
+	GetActiveAgents() ([]*agentpb.Agent, error)
 	MessageAgents(agentIDs []uuid.UUID, msg []byte) error
 	MessageActiveAgents(msg []byte) error
 }
@@ -63,13 +71,33 @@ type Store interface {
 	DeleteTracepoint(uuid.UUID) error
 	DeleteTracepointsForAgent(uuid.UUID) error
 	GetTracepointTTLs() ([]uuid.UUID, []time.Time, error)
+	SetTracepointVersion(string, *storepb.TracepointVersion) error
+	GetTracepointVersions(string) ([]*storepb.TracepointVersion, error)
 }
 
+// expiryWatcher is a Store that can notify the manager as tracepoint TTLs expire, rather than
+// the manager having to poll for expired tracepoints.
+type expiryWatcher interface {
+	WatchTracepointExpiry() (<-chan uuid.UUID, func(), error)
+}
+
+// expiryResyncPeriod is how often expired tracepoints are polled for when the store notifies the
+// manager of expirations. Polling catches any expirations that weren't notified.
+const expiryResyncPeriod = 5 * time.Minute
+
 // Manager manages the tracepoints deployed in the cluster.
 type Manager struct {
 	ts     Store
 	agtMgr agentMessenger
 
+	// rolloutMu serializes changes to the agents that tracepoints are deployed to, by staged
+	// rollouts, by selectors and by termination.
+	rolloutMu      sync.Mutex
+	rolloutOnce    sync.Once
+	defaultRollout *storepb.TracepointRolloutPolicy
+	topology       Topology
+	selectorOnce   sync.Once
+
 	done chan struct{}
 	once sync.Once
 }
@@ -82,21 +110,86 @@ func NewManager(ts Store, agtMgr agentMessenger, ttlReaperDuration time.Duration
 		done:   make(chan struct{}),
 	}
 
-	go tm.watchForTracepointExpiry(ttlReaperDuration)
+	go tm.watchForTracepointExpiry(ts, ttlReaperDuration)
 	return tm
 }
 
-func (m *Manager) watchForTracepointExpiry(ttlReaperDuration time.Duration) {
-	ticker := time.NewTicker(ttlReaperDuration)
-	defer ticker.Stop()
+// watchForTracepointExpiry is passed the store, since Close clears it from the manager.
+func (m *Manager) watchForTracepointExpiry(ts Store, ttlReaperDuration time.Duration) {
+	watcher, ok := ts.(expiryWatcher)
+	if !ok {
+		m.runExpiryLoop(nil, ttlReaperDuration)
+		return
+	}
 	for {
+		expired, cancel, err := watcher.WatchTracepointExpiry()
+		if err != nil {
+			if err != watch.ErrNotSupported {
+				log.WithError(err).Warn("Failed to watch for tracepoint expiry, polling instead")
+			}
+			m.runExpiryLoop(nil, ttlReaperDuration)
+			return
+		}
+		closed := m.runExpiryLoop(expired, expiryResyncPeriod)
+		cancel()
+		if closed {
+			return
+		}
+		// The watch was dropped, so catch up on any expirations that were missed before watching again.
 		select {
 		case <-m.done:
 			return
+		case <-time.After(time.Second):
+		}
+		m.terminateExpiredTracepoints()
+	}
+}
+
+// runExpiryLoop terminates expired tracepoints every period, and as soon as their IDs are sent on
+// expired, which may be nil. It returns true once the manager is closed, and false if expired is
+// closed.
+func (m *Manager) runExpiryLoop(expired <-chan uuid.UUID, period time.Duration) bool {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.done:
+			return true
 		case <-ticker.C:
 			m.terminateExpiredTracepoints()
+		case id, ok := <-expired:
+			if !ok {
+				return false
+			}
+			m.terminateExpiredTracepoint(id)
+		}
+	}
+}
+
+func (m *Manager) terminateExpiredTracepoint(id uuid.UUID) {
+	tp, err := m.ts.GetTracepoint(id)
+	if err != nil {
+		log.WithError(err).Warn("error encountered when trying to terminating expired tracepoints")
+		return
+	}
+	if tp == nil || tp.ExpectedState == statuspb.TERMINATED_STATE {
+		return
+	}
+	// The TTL may have been set again since it expired.
+	ttlKeys, ttlVals, err := m.ts.GetTracepointTTLs()
+	if err != nil {
+		log.WithError(err).Warn("error encountered when trying to terminating expired tracepoints")
+		return
+	}
+	for i, ttlID := range ttlKeys {
+		if ttlID == id && ttlVals[i].After(time.Now()) {
+			return
 		}
 	}
+	err = m.terminateTracepoint(id)
+	if err != nil {
+		log.WithError(err).Warn("error encountered when trying to terminating expired tracepoints")
+	}
 }
 
 func (m *Manager) terminateExpiredTracepoints() {
@@ -137,7 +230,17 @@ func (m *Manager) terminateExpiredTracepoints() {
 	}
 }
 
+// terminateTracepoint terminates the tracepoint, and removes it from the agents.
 func (m *Manager) terminateTracepoint(id uuid.UUID) error {
+	// Rollouts and selectors update tracepoints under the lock, so they never see, or write back,
+	// a tracepoint that is being terminated.
+	m.rolloutMu.Lock()
+	defer m.rolloutMu.Unlock()
+	return m.terminateTracepointLocked(id)
+}
+
+// terminateTracepointLocked is terminateTracepoint for callers that hold rolloutMu.
+func (m *Manager) terminateTracepointLocked(id uuid.UUID) error {
 	// Update state in datastore to terminated.
 	tp, err := m.ts.GetTracepoint(id)
 	if err != nil {
@@ -148,6 +251,15 @@ func (m *Manager) terminateTracepoint(id uuid.UUID) error {
 		return nil
 	}
 
+	// The previous version is still running on the agents that the rollout hasn't reached, so it
+	// has to go too.
+	if RolloutInProgress(tp) && tp.Rollout.PreviousID != nil {
+		err = m.ts.DeleteTracepointTTLs([]uuid.UUID{utils.UUIDFromProtoOrNil(tp.Rollout.PreviousID)})
+		if err != nil {
+			return err
+		}
+	}
+
 	tp.ExpectedState = statuspb.TERMINATED_STATE
 	err = m.ts.UpsertTracepoint(id, tp)
 	if err != nil {
@@ -155,6 +267,15 @@ func (m *Manager) terminateTracepoint(id uuid.UUID) error {
 	}
 
 	// Send termination messages to PEMs.
+	msg, err := removeTracepointMsg(id)
+	if err != nil {
+		return err
+	}
+
+	return m.agtMgr.MessageActiveAgents(msg)
+}
+
+func removeTracepointMsg(id uuid.UUID) ([]byte, error) {
 	tracepointReq := messagespb.VizierMessage{
 		Msg: &messagespb.VizierMessage_TracepointMessage{
 			TracepointMessage: &messagespb.TracepointMessage{
@@ -166,20 +287,20 @@ func (m *Manager) terminateTracepoint(id uuid.UUID) error {
 			},
 		},
 	}
-	msg, err := tracepointReq.Marshal()
-	if err != nil {
-		return err
-	}
-
-	return m.agtMgr.MessageActiveAgents(msg)
+	return tracepointReq.Marshal()
 }
 
 func (m *Manager) deleteTracepoint(id uuid.UUID) error {
 	return m.ts.DeleteTracepoint(id)
 }
 
-// CreateTracepoint creates and stores info about the given tracepoint.
+// CreateTracepoint creates and stores info about the given tracepoint. The caller is responsible
+// for registering the tracepoint on the agents.
 func (m *Manager) CreateTracepoint(tracepointName string, tracepointDeployment *logicalpb.TracepointDeployment, ttl time.Duration) (*uuid.UUID, error) {
+	return m.createTracepoint(tracepointName, tracepointDeployment, ttl, nil, nil)
+}
+
+func (m *Manager) createTracepoint(tracepointName string, tracepointDeployment *logicalpb.TracepointDeployment, ttl time.Duration, rollout *storepb.TracepointRolloutPolicy, selector *storepb.TracepointSelector) (*uuid.UUID, error) {
 	// Check to see if a tracepoint with the matching name already exists.
 	resp, err := m.ts.GetTracepointsWithNames([]string{tracepointName})
 	if err != nil {
@@ -191,6 +312,9 @@ func (m *Manager) CreateTracepoint(tracepointName string, tracepointDeployment *
 	}
 	prevTracepointID := resp[0]
 
+	// The running tracepoint that a staged rollout replaces.
+	var replacedID *uuid.UUID
+
 	if prevTracepointID != nil { // Existing tracepoint already exists.
 		prevTracepoint, err := m.ts.GetTracepoint(*prevTracepointID)
 		if err != nil {
@@ -226,14 +350,36 @@ func (m *Manager) CreateTracepoint(tracepointName string, tracepointDeployment *
 				return prevTracepointID, ErrTracepointAlreadyExists
 			}
 
-			// Something has changed, so trigger termination of the old tracepoint.
-			err = m.ts.DeleteTracepointTTLs([]uuid.UUID{*prevTracepointID})
-			if err != nil {
-				return nil, err
+			if rollout != nil {
+				// The old tracepoint keeps running until the rollout replaces it on every agent.
+				if RolloutInProgress(prevTracepoint) {
+					return nil, ErrRolloutInProgress
+				}
+				replacedID = prevTracepointID
+			} else {
+				// Something has changed, so trigger termination of the old tracepoint, along with the
+				// version it was replacing if it was still being rolled out.
+				ids := []uuid.UUID{*prevTracepointID}
+				if RolloutInProgress(prevTracepoint) && prevTracepoint.Rollout.PreviousID != nil {
+					ids = append(ids, utils.UUIDFromProtoOrNil(prevTracepoint.Rollout.PreviousID))
+				}
+				err = m.ts.DeleteTracepointTTLs(ids)
+				if err != nil {
+					return nil, err
+				}
 			}
 		}
 	}
 
+	versions, err := m.ts.GetTracepointVersions(tracepointName)
+	if err != nil {
+		return nil, err
+	}
+	version := int64(1)
+	if len(versions) > 0 {
+		version = versions[len(versions)-1].Version + 1
+	}
+
 	tpID, err := uuid.NewV4()
 	if err != nil {
 		return nil, err
@@ -243,6 +389,17 @@ func (m *Manager) CreateTracepoint(tracepointName string, tracepointDeployment *
 		Tracepoint:    tracepointDeployment,
 		Name:          tracepointName,
 		ExpectedState: statuspb.RUNNING_STATE,
+		Version:       version,
+		Selector:      selector,
+	}
+	if rollout != nil {
+		newTracepoint.Rollout = &storepb.TracepointRollout{
+			Policy: rollout,
+			Phase:  storepb.ROLLOUT_IN_PROGRESS,
+		}
+		if replacedID != nil {
+			newTracepoint.Rollout.PreviousID = utils.ProtoFromUUID(*replacedID)
+		}
 	}
 	err = m.ts.UpsertTracepoint(tpID, newTracepoint)
 	if err != nil {
@@ -256,6 +413,15 @@ func (m *Manager) CreateTracepoint(tracepointName string, tracepointDeployment *
 	if err != nil {
 		return nil, err
 	}
+	err = m.ts.SetTracepointVersion(tracepointName, &storepb.TracepointVersion{
+		Version:     version,
+		ID:          utils.ProtoFromUUID(tpID),
+		Tracepoint:  tracepointDeployment,
+		CreatedAtNS: time.Now().UnixNano(),
+	})
+	if err != nil {
+		return nil, err
+	}
 	return &tpID, nil
 }
 
@@ -281,7 +447,15 @@ func (m *Manager) UpdateAgentTracepointStatus(tracepointID *uuidpb.UUID, agentID
 		}
 
 		if allTerminated {
-			return m.deleteTracepoint(tID)
+			tp, err := m.ts.GetTracepoint(tID)
+			if err != nil {
+				return err
+			}
+			// Agents that no longer match the tracepoint's selector remove it, but other agents may
+			// start matching later.
+			if tp == nil || tp.Selector == nil || tp.ExpectedState == statuspb.TERMINATED_STATE {
+				return m.deleteTracepoint(tID)
+			}
 		}
 	}
 
@@ -327,11 +501,41 @@ func (m *Manager) GetTracepointStates(tracepointID uuid.UUID) ([]*storepb.AgentT
 	return m.ts.GetTracepointStates(tracepointID)
 }
 
+// GetTracepointVersions gets the history of the tracepoint with the given name, oldest first, along
+// with the version that is currently deployed or being rolled out, or 0 if there isn't one.
+func (m *Manager) GetTracepointVersions(name string) ([]*storepb.TracepointVersion, int64, error) {
+	versions, err := m.ts.GetTracepointVersions(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	ids, err := m.ts.GetTracepointsWithNames([]string{name})
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(ids) != 1 || ids[0] == nil {
+		return versions, 0, nil
+	}
+	tp, err := m.ts.GetTracepoint(*ids[0])
+	if err != nil {
+		return nil, 0, err
+	}
+	if tp == nil || tp.ExpectedState == statuspb.TERMINATED_STATE {
+		return versions, 0, nil
+	}
+	return versions, tp.Version, nil
+}
+
 // GetTracepointsForIDs gets all the tracepoint infos for the given ids.
 func (m *Manager) GetTracepointsForIDs(ids []uuid.UUID) ([]*storepb.TracepointInfo, error) {
 	return m.ts.GetTracepointsForIDs(ids)
 }
 
+// GetTracepointsWithNames gets the IDs of the current tracepoints with the given names. The ID is
+// nil for names that don't have a tracepoint.
+func (m *Manager) GetTracepointsWithNames(names []string) ([]*uuid.UUID, error) {
+	return m.ts.GetTracepointsWithNames(names)
+}
+
 // RemoveTracepoints starts the termination process for the tracepoints with the given names.
 func (m *Manager) RemoveTracepoints(names []string) error {
 	tpIDs, err := m.ts.GetTracepointsWithNames(names)
@@ -351,6 +555,55 @@ func (m *Manager) RemoveTracepoints(names []string) error {
 	return m.ts.DeleteTracepointTTLs(ids)
 }
 
+// GetTracepointExpiries gets when the TTL of each tracepoint that has one expires.
+func (m *Manager) GetTracepointExpiries() (map[uuid.UUID]time.Time, error) {
+	ttlKeys, ttlVals, err := m.ts.GetTracepointTTLs()
+	if err != nil {
+		return nil, err
+	}
+	expiries := make(map[uuid.UUID]time.Time, len(ttlKeys))
+	for i, id := range ttlKeys {
+		expiries[id] = ttlVals[i]
+	}
+	return expiries, nil
+}
+
+// ExtendTracepointTTLs resets the TTL of the tracepoints with the given names, so that they expire
+// after the given duration from now. Tracepoints that are already terminating can't be extended.
+func (m *Manager) ExtendTracepointTTLs(names []string, ttl time.Duration) error {
+	tpIDs, err := m.ts.GetTracepointsWithNames(names)
+	if err != nil {
+		return err
+	}
+
+	var ids []uuid.UUID
+	for i, id := range tpIDs {
+		if id == nil {
+			return fmt.Errorf("%w: %s", ErrTracepointNotFound, names[i])
+		}
+		tp, err := m.ts.GetTracepoint(*id)
+		if err != nil {
+			return err
+		}
+		if tp == nil || tp.ExpectedState == statuspb.TERMINATED_STATE {
+			return fmt.Errorf("%w: %s", ErrTracepointNotFound, names[i])
+		}
+		ids = append(ids, *id)
+		// The previous version keeps running on the agents the rollout hasn't reached yet.
+		if RolloutInProgress(tp) && tp.Rollout.PreviousID != nil {
+			ids = append(ids, utils.UUIDFromProtoOrNil(tp.Rollout.PreviousID))
+		}
+	}
+
+	for _, id := range ids {
+		err = m.ts.SetTracepointTTL(id, ttl)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // DeleteAgent deletes tracepoints on the given agent.
 func (m *Manager) DeleteAgent(agentID uuid.UUID) error {
 	return m.ts.DeleteTracepointsForAgent(agentID)
@@ -19,8 +19,10 @@
 package tracepoint
 
 import (
+	"fmt"
 	"path"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gofrs/uuid"
@@ -31,15 +33,21 @@ import (
 	"px.dev/pixie/src/utils"
 	"px.dev/pixie/src/vizier/services/metadata/storepb"
 	"px.dev/pixie/src/vizier/utils/datastore"
+	"px.dev/pixie/src/vizier/utils/datastore/watch"
 )
 
 const (
-	tracepointsPrefix      = "/tracepoint/"
-	tracepointStatesPrefix = "/tracepointStates/"
-	tracepointTTLsPrefix   = "/tracepointTTL/"
-	tracepointNamesPrefix  = "/tracepointName/"
+	tracepointsPrefix        = "/tracepoint/"
+	tracepointStatesPrefix   = "/tracepointStates/"
+	tracepointTTLsPrefix     = "/tracepointTTL/"
+	tracepointNamesPrefix    = "/tracepointName/"
+	tracepointVersionsPrefix = "/tracepointVersion/"
 )
 
+// maxTracepointVersions is the number of versions kept in the history of each tracepoint. Older
+// versions are pruned as new ones are added.
+const maxTracepointVersions = 20
+
 // Datastore implements the TracepointStore interface on a given Datastore.
 type Datastore struct {
 	ds datastore.MultiGetterSetterDeleterCloser
@@ -70,6 +78,15 @@ func getTracepointTTLKey(tracepointID uuid.UUID) string {
 	return path.Join(tracepointTTLsPrefix, tracepointID.String())
 }
 
+func getTracepointVersionsKey(tracepointName string) string {
+	return path.Join(tracepointVersionsPrefix, tracepointName) + "/"
+}
+
+func getTracepointVersionKey(tracepointName string, version int64) string {
+	// Versions are zero-padded so that they sort in order.
+	return fmt.Sprintf("%s%020d", getTracepointVersionsKey(tracepointName), version)
+}
+
 // GetTracepointsWithNames gets which tracepoint is associated with the given name.
 func (t *Datastore) GetTracepointsWithNames(tracepointNames []string) ([]*uuid.UUID, error) {
 	eg := errgroup.Group{}
@@ -133,6 +150,70 @@ func (t *Datastore) DeleteTracepoint(tracepointID uuid.UUID) error {
 	return t.ds.DeleteWithPrefix(getTracepointStatesKey(tracepointID))
 }
 
+// SetTracepointVersion creates or updates a version in the history of the tracepoint with the given
+// name. Only the latest maxTracepointVersions versions are kept.
+func (t *Datastore) SetTracepointVersion(tracepointName string, version *storepb.TracepointVersion) error {
+	val, err := version.Marshal()
+	if err != nil {
+		return err
+	}
+
+	err = t.ds.Set(getTracepointVersionKey(tracepointName, version.Version), string(val))
+	if err != nil {
+		return err
+	}
+
+	keys, _, err := t.getTracepointVersions(tracepointName)
+	if err != nil {
+		return err
+	}
+	if len(keys) <= maxTracepointVersions {
+		return nil
+	}
+	return t.ds.DeleteAll(keys[:len(keys)-maxTracepointVersions])
+}
+
+// getTracepointVersions gets the keys and values of the history of the tracepoint with the given
+// name, oldest first.
+func (t *Datastore) getTracepointVersions(tracepointName string) ([]string, [][]byte, error) {
+	prefix := getTracepointVersionsKey(tracepointName)
+	keys, vals, err := t.ds.GetWithPrefix(prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var versionKeys []string
+	var versionVals [][]byte
+	for i, key := range keys {
+		// Skip the versions of tracepoints whose names have this tracepoint's name as a prefix.
+		if strings.Contains(strings.TrimPrefix(key, prefix), "/") {
+			continue
+		}
+		versionKeys = append(versionKeys, key)
+		versionVals = append(versionVals, vals[i])
+	}
+	return versionKeys, versionVals, nil
+}
+
+// GetTracepointVersions gets the history of the tracepoint with the given name, oldest first.
+func (t *Datastore) GetTracepointVersions(tracepointName string) ([]*storepb.TracepointVersion, error) {
+	_, vals, err := t.getTracepointVersions(tracepointName)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []*storepb.TracepointVersion
+	for _, val := range vals {
+		pb := &storepb.TracepointVersion{}
+		err := proto.Unmarshal(val, pb)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, pb)
+	}
+	return versions, nil
+}
+
 // GetTracepoint gets the tracepoint info from the store, if it exists.
 func (t *Datastore) GetTracepoint(tracepointID uuid.UUID) (*storepb.TracepointInfo, error) {
 	resp, err := t.ds.Get(getTracepointKey(tracepointID))
@@ -307,3 +388,44 @@ func (t *Datastore) GetTracepointTTLs() ([]uuid.UUID, []time.Time, error) {
 
 	return ids, expirations, nil
 }
+
+// WatchTracepointExpiry sends the IDs of tracepoints as their TTLs expire. The channel is closed
+// when cancel is called, or if the underlying watch is dropped.
+func (t *Datastore) WatchTracepointExpiry() (<-chan uuid.UUID, func(), error) {
+	w, ok := t.ds.(datastore.Watcher)
+	if !ok {
+		return nil, nil, watch.ErrNotSupported
+	}
+	events, cancelWatch, err := w.Watch(tracepointTTLsPrefix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	expired := make(chan uuid.UUID)
+	done := make(chan struct{})
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			close(done)
+			cancelWatch()
+		})
+	}
+	go func() {
+		defer close(expired)
+		for ev := range events {
+			if ev.Type != watch.Expire {
+				continue
+			}
+			id, err := uuid.FromString(strings.TrimPrefix(ev.Key, tracepointTTLsPrefix))
+			if err != nil {
+				continue
+			}
+			select {
+			case expired <- id:
+			case <-done:
+				return
+			}
+		}
+	}()
+	return expired, cancel, nil
+}
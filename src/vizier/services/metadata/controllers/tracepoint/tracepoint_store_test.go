@@ -362,3 +362,62 @@ func TestTracepointStore_GetTracepointTTLs(t *testing.T) {
 	assert.Contains(t, tracepoints, s1ID)
 	assert.Contains(t, tracepoints, s2ID)
 }
+
+func TestTracepointStore_TracepointVersions(t *testing.T) {
+	_, ts, cleanup := setupTest(t)
+	defer cleanup()
+
+	tpID1 := uuid.Must(uuid.NewV4())
+	tpID2 := uuid.Must(uuid.NewV4())
+	tpID3 := uuid.Must(uuid.NewV4())
+
+	v1 := &storepb.TracepointVersion{Version: 1, ID: utils.ProtoFromUUID(tpID1)}
+	v2 := &storepb.TracepointVersion{Version: 2, ID: utils.ProtoFromUUID(tpID2)}
+	v10 := &storepb.TracepointVersion{Version: 10, ID: utils.ProtoFromUUID(tpID3)}
+
+	require.NoError(t, ts.SetTracepointVersion("test", v10))
+	require.NoError(t, ts.SetTracepointVersion("test", v1))
+	require.NoError(t, ts.SetTracepointVersion("test", v2))
+	// Tracepoints whose names share a prefix have separate histories.
+	require.NoError(t, ts.SetTracepointVersion("test2", v1))
+	require.NoError(t, ts.SetTracepointVersion("test/nested", v1))
+
+	versions, err := ts.GetTracepointVersions("test")
+	require.NoError(t, err)
+	assert.Equal(t, []*storepb.TracepointVersion{v1, v2, v10}, versions)
+
+	// Versions can be updated in place.
+	v2.RolledBack = true
+	require.NoError(t, ts.SetTracepointVersion("test", v2))
+	versions, err = ts.GetTracepointVersions("test")
+	require.NoError(t, err)
+	require.Equal(t, 3, len(versions))
+	assert.True(t, versions[1].RolledBack)
+
+	versions, err = ts.GetTracepointVersions("missing")
+	require.NoError(t, err)
+	assert.Equal(t, 0, len(versions))
+}
+
+func TestTracepointStore_TracepointVersionsPruned(t *testing.T) {
+	_, ts, cleanup := setupTest(t)
+	defer cleanup()
+
+	for i := 1; i <= maxTracepointVersions+5; i++ {
+		v := &storepb.TracepointVersion{Version: int64(i), ID: utils.ProtoFromUUID(uuid.Must(uuid.NewV4()))}
+		require.NoError(t, ts.SetTracepointVersion("test", v))
+	}
+	require.NoError(t, ts.SetTracepointVersion("test2", &storepb.TracepointVersion{Version: 1}))
+
+	// Only the latest versions are kept.
+	versions, err := ts.GetTracepointVersions("test")
+	require.NoError(t, err)
+	require.Equal(t, maxTracepointVersions, len(versions))
+	assert.Equal(t, int64(6), versions[0].Version)
+	assert.Equal(t, int64(maxTracepointVersions+5), versions[len(versions)-1].Version)
+
+	// The history of other tracepoints isn't affected.
+	versions, err = ts.GetTracepointVersions("test2")
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(versions))
+}
@@ -23,6 +23,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
 	"github.com/gofrs/uuid"
 	"github.com/gogo/protobuf/proto"
 	"github.com/golang/mock/gomock"
@@ -37,6 +39,7 @@ import (
 	"px.dev/pixie/src/vizier/services/metadata/controllers/tracepoint"
 	mock_tracepoint "px.dev/pixie/src/vizier/services/metadata/controllers/tracepoint/mock"
 	"px.dev/pixie/src/vizier/services/metadata/storepb"
+	"px.dev/pixie/src/vizier/utils/datastore/pebbledb"
 )
 
 func TestCreateTracepoint(t *testing.T) {
@@ -261,6 +264,24 @@ func TestCreateTracepoint(t *testing.T) {
 			var newID uuid.UUID
 
 			if !test.expectError && !test.expectTTLUpdateOnly {
+				// Each redeploy of the tracepoint gets the next version.
+				var versions []*storepb.TracepointVersion
+				expectedVersion := int64(1)
+				if test.originalTracepoint != nil {
+					versions = []*storepb.TracepointVersion{
+						{
+							Version:    1,
+							ID:         utils.ProtoFromUUID(origID),
+							Tracepoint: test.originalTracepoint,
+						},
+					}
+					expectedVersion = 2
+				}
+				mockTracepointStore.
+					EXPECT().
+					GetTracepointVersions("test_tracepoint").
+					Return(versions, nil)
+
 				mockTracepointStore.
 					EXPECT().
 					UpsertTracepoint(gomock.Any(), gomock.Any()).
@@ -271,10 +292,21 @@ func TestCreateTracepoint(t *testing.T) {
 							Name:          "test_tracepoint",
 							ID:            utils.ProtoFromUUID(id),
 							ExpectedState: statuspb.RUNNING_STATE,
+							Version:       expectedVersion,
 						}, tpInfo)
 						return nil
 					})
 
+				mockTracepointStore.
+					EXPECT().
+					SetTracepointVersion("test_tracepoint", gomock.Any()).
+					DoAndReturn(func(name string, v *storepb.TracepointVersion) error {
+						assert.Equal(t, expectedVersion, v.Version)
+						assert.Equal(t, utils.ProtoFromUUID(newID), v.ID)
+						assert.Equal(t, test.newTracepoint, v.Tracepoint)
+						return nil
+					})
+
 				mockTracepointStore.
 					EXPECT().
 					SetTracepointWithName("test_tracepoint", gomock.Any()).
@@ -498,6 +530,14 @@ func TestUpdateAgentTracepointStatus_Terminated(t *testing.T) {
 			{AgentID: utils.ProtoFromUUID(agentUUID2), State: statuspb.RUNNING_STATE},
 		}, nil)
 
+	mockTracepointStore.
+		EXPECT().
+		GetTracepoint(tpID).
+		Return(&storepb.TracepointInfo{
+			ID:            utils.ProtoFromUUID(tpID),
+			ExpectedState: statuspb.TERMINATED_STATE,
+		}, nil)
+
 	mockTracepointStore.
 		EXPECT().
 		DeleteTracepoint(tpID).
@@ -605,6 +645,50 @@ func TestTTLExpiration(t *testing.T) {
 	assert.Contains(t, seenDeletions, tpID3.String())
 }
 
+func TestTTLExpiration_Watch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mockAgtMgr := mock_agent.NewMockManager(ctrl)
+
+	db, err := pebble.Open("test", &pebble.Options{
+		FS: vfs.NewMem(),
+	})
+	require.NoError(t, err)
+	ds := pebbledb.New(db, 100*time.Millisecond)
+	defer ds.Close()
+	ts := tracepoint.NewDatastore(ds)
+
+	tpID := uuid.Must(uuid.NewV4())
+	require.NoError(t, ts.UpsertTracepoint(tpID, &storepb.TracepointInfo{ID: utils.ProtoFromUUID(tpID)}))
+	require.NoError(t, ts.SetTracepointTTL(tpID, time.Second))
+
+	removed := make(chan string, 1)
+	mockAgtMgr.
+		EXPECT().
+		MessageActiveAgents(gomock.Any()).
+		DoAndReturn(func(msg []byte) error {
+			vzMsg := &messagespb.VizierMessage{}
+			require.NoError(t, proto.Unmarshal(msg, vzMsg))
+			removed <- utils.ProtoToUUIDStr(vzMsg.GetTracepointMessage().GetRemoveTracepointRequest().ID)
+			return nil
+		})
+
+	// The manager would only poll after an hour, so the tracepoint is terminated because of the
+	// TTL expiring.
+	tracepointMgr := tracepoint.NewManager(ts, mockAgtMgr, time.Hour)
+	defer tracepointMgr.Close()
+
+	select {
+	case id := <-removed:
+		assert.Equal(t, tpID.String(), id)
+	case <-time.After(30 * time.Second):
+		t.Fatal("timed out waiting for tracepoint to be terminated")
+	}
+	tp, err := ts.GetTracepoint(tpID)
+	require.NoError(t, err)
+	assert.Equal(t, statuspb.TERMINATED_STATE, tp.ExpectedState)
+}
+
 func TestUpdateAgentTracepointStatus_RemoveTracepoints(t *testing.T) {
 	// Set up mock.
 	ctrl := gomock.NewController(t)
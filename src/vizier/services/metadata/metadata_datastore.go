@@ -0,0 +1,226 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v3"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	bunt "github.com/tidwall/buntdb"
+
+	"px.dev/pixie/src/vizier/utils/datastore/badgerdb"
+	"px.dev/pixie/src/vizier/utils/datastore/buntdb"
+	"px.dev/pixie/src/vizier/utils/datastore/encrypted"
+	"px.dev/pixie/src/vizier/utils/datastore/instrumented"
+	"px.dev/pixie/src/vizier/utils/datastore/migrate"
+)
+
+const (
+	etcdBackend     = "etcd"
+	pebbledbBackend = "pebbledb"
+	badgerdbBackend = "badgerdb"
+	buntdbBackend   = "buntdb"
+
+	// badgerOpenDir is where the badgerdb files live.
+	badgerOpenDir = "/metadata/badger"
+	// buntOpenPath is the file backing the buntdb datastore.
+	buntOpenPath = "/metadata/bunt.db"
+
+	// migrationMarkerPrefix is the prefix of the keys recording that a migration to a backend has
+	// started but not yet been verified. The backend is appended to the prefix.
+	migrationMarkerPrefix = "/datastoreMigration/to/"
+	// migrationRetryInterval is how long to wait before retrying a migration that failed to backfill
+	// or verify.
+	migrationRetryInterval = time.Minute
+)
+
+func init() {
+	pflag.String("datastore_backend", "", "The datastore backend to use: etcd, pebbledb, badgerdb or buntdb. "+
+		"Defaults to etcd if use_etcd_operator is set, and pebbledb otherwise.")
+	pflag.String("datastore_migrate_from", "", "The datastore backend to migrate from. If set, reads are served "+
+		"from this backend while writes go to both it and datastore_backend, and its existing keys are backfilled "+
+		"into datastore_backend. It can be removed once the migration is logged as verified.")
+	pflag.String("datastore_encryption_key_dir", "", "The directory, usually a mounted secret, holding the keys used to "+
+		"encrypt datastore values at rest. Each file is a 32 byte key named by its ID, and the file named primary holds "+
+		"the ID of the key to encrypt with. Values are not encrypted if unset.")
+	pflag.Duration("datastore_encryption_key_reload_interval", time.Minute, "How often to check the encryption keys "+
+		"for a rotation of the primary key")
+	pflag.Duration("datastore_stats_interval", time.Minute, "How often to count the keys in the datastore, and "+
+		"check its size, for the datastore metrics")
+}
+
+func datastoreBackend() string {
+	if backend := viper.GetString("datastore_backend"); backend != "" {
+		return backend
+	}
+	if viper.GetBool("use_etcd_operator") {
+		return etcdBackend
+	}
+	return pebbledbBackend
+}
+
+func mustInitBadgerDatastore() *badgerdb.DataStore {
+	db, err := badger.Open(badger.DefaultOptions(badgerOpenDir).WithLogger(nil))
+	if err != nil {
+		log.WithError(err).Fatal("Failed to open badger database.")
+	}
+	return badgerdb.New(db)
+}
+
+func mustInitBuntDatastore() *buntdb.DataStore {
+	db, err := bunt.Open(buntOpenPath)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to open bunt database.")
+	}
+	return buntdb.New(db)
+}
+
+func mustInitBackend(backend string) (migrate.Datastore, func()) {
+	noop := func() {}
+	switch backend {
+	case etcdBackend:
+		return mustInitEtcdDatastore()
+	case pebbledbBackend:
+		return mustInitPebbleDatastore(), noop
+	case badgerdbBackend:
+		return mustInitBadgerDatastore(), noop
+	case buntdbBackend:
+		return mustInitBuntDatastore(), noop
+	default:
+		log.Fatalf("Unknown datastore backend: %s", backend)
+	}
+	return nil, noop
+}
+
+func migrationMarkerKey(backend string) string {
+	return migrationMarkerPrefix + backend
+}
+
+// mustInitDatastore opens the configured datastore backend. If a migration is configured, the old
+// backend stays the primary while its keys are backfilled into the new backend. Once the backfill
+// is verified, the migration flag can be removed to cut over to the new backend. Cutting over
+// before then is refused, since the new backend may be missing keys.
+func mustInitDatastore() (migrate.Datastore, func()) {
+	backend := datastoreBackend()
+	ds, cleanupFunc := mustInitBackend(backend)
+
+	from := viper.GetString("datastore_migrate_from")
+	if from == "" || from == backend {
+		marker, err := ds.Get(migrationMarkerKey(backend))
+		if err != nil {
+			log.WithError(err).Fatal("Failed to check for an unverified datastore migration.")
+		}
+		if marker != nil {
+			log.Fatalf("The migration to the %s datastore has not been verified. Keep datastore_migrate_from set "+
+				"until it is.", backend)
+		}
+		// Markers for other backends are left behind by migrations that were rolled back.
+		if err := ds.DeleteWithPrefix(migrationMarkerPrefix); err != nil {
+			log.WithError(err).Error("Failed to delete stale datastore migration markers.")
+		}
+		return ds, cleanupFunc
+	}
+
+	oldDs, oldCleanupFunc := mustInitBackend(from)
+	dualDs := migrate.NewDualWriteDatastore(oldDs, ds)
+	if err := dualDs.Set(migrationMarkerKey(backend), from); err != nil {
+		log.WithError(err).Fatal("Failed to mark the start of the datastore migration.")
+	}
+	quitCh := make(chan struct{})
+	go migrateDatastore(dualDs, from, backend, quitCh)
+
+	return dualDs, func() {
+		close(quitCh)
+		cleanupFunc()
+		oldCleanupFunc()
+	}
+}
+
+// migrateDatastore backfills the new backend until it is verified to match the old one, and then
+// clears the migration marker so that the new backend can be cut over to. It gives up when quitCh
+// is closed.
+func migrateDatastore(dualDs *migrate.DualWriteDatastore, from, backend string, quitCh <-chan struct{}) {
+	contextLogger := log.WithField("from", from).WithField("to", backend)
+	for {
+		err := backfillAndVerify(dualDs, backend, contextLogger)
+		if err == nil {
+			return
+		}
+		contextLogger.WithError(err).Error("Failed to migrate datastore, retrying")
+		select {
+		case <-quitCh:
+			return
+		case <-time.After(migrationRetryInterval):
+		}
+	}
+}
+
+func backfillAndVerify(dualDs *migrate.DualWriteDatastore, backend string, contextLogger *log.Entry) error {
+	start := time.Now()
+	stats, err := dualDs.Backfill()
+	if err != nil {
+		return fmt.Errorf("failed to backfill datastore: %w", err)
+	}
+	contextLogger.WithField("stats", stats.String()).WithField("duration", time.Since(start)).
+		Info("Backfilled datastore")
+
+	stats, err = dualDs.Verify()
+	if err != nil {
+		return fmt.Errorf("failed to verify datastore migration: %w", err)
+	}
+	if err := dualDs.Delete(migrationMarkerKey(backend)); err != nil {
+		return fmt.Errorf("failed to clear datastore migration marker: %w", err)
+	}
+	contextLogger.WithField("stats", stats.String()).
+		Info("Verified datastore migration, it is safe to remove datastore_migrate_from")
+	return nil
+}
+
+// initInstrumentation wraps the datastore so that metrics about it are exported, and periodically
+// updates the key counts and on-disk size.
+func initInstrumentation(ds migrate.Datastore) (migrate.Datastore, func()) {
+	instDs := instrumented.New(ds)
+	quitCh := make(chan struct{})
+	go instDs.RunStatsUpdater(viper.GetDuration("datastore_stats_interval"), quitCh)
+	return instDs, func() { close(quitCh) }
+}
+
+// mustInitEncryption wraps the datastore so that values are encrypted at rest, if encryption keys
+// are configured. Any values that aren't encrypted with the primary key, such as values written
+// before encryption was enabled, are re-encrypted in the background.
+func mustInitEncryption(ds migrate.Datastore) (migrate.Datastore, func()) {
+	dir := viper.GetString("datastore_encryption_key_dir")
+	if dir == "" {
+		return ds, func() {}
+	}
+	keyring, err := encrypted.LoadKeyring(dir)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to load datastore encryption keys.")
+	}
+	encDs := encrypted.New(ds, keyring)
+	encDs.ReEncryptInBackground()
+
+	quitCh := make(chan struct{})
+	go encDs.WatchKeyring(dir, viper.GetDuration("datastore_encryption_key_reload_interval"), quitCh)
+	return encDs, func() { close(quitCh) }
+}
@@ -25,7 +25,9 @@ import (
 	"time"
 
 	"github.com/cockroachdb/pebble"
+	"github.com/gogo/protobuf/types"
 	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
@@ -41,11 +43,13 @@ import (
 	"px.dev/pixie/src/shared/services/server"
 	"px.dev/pixie/src/vizier/services/metadata/controllers"
 	"px.dev/pixie/src/vizier/services/metadata/controllers/agent"
+	"px.dev/pixie/src/vizier/services/metadata/controllers/backup"
+	"px.dev/pixie/src/vizier/services/metadata/controllers/cronscript"
 	"px.dev/pixie/src/vizier/services/metadata/controllers/k8smeta"
 	"px.dev/pixie/src/vizier/services/metadata/controllers/tracepoint"
 	"px.dev/pixie/src/vizier/services/metadata/metadataenv"
 	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
-	"px.dev/pixie/src/vizier/utils/datastore"
+	"px.dev/pixie/src/vizier/services/metadata/storepb"
 	"px.dev/pixie/src/vizier/utils/datastore/etcd"
 	"px.dev/pixie/src/vizier/utils/datastore/pebbledb"
 )
@@ -65,6 +69,46 @@ func init() {
 	pflag.String("pod_namespace", "pl", "The namespace this pod runs in. Used for leader elections")
 	pflag.String("nats_url", "pl-nats", "The URL of NATS")
 	pflag.Bool("use_etcd_operator", false, "Whether the etcd operator should be used instead of the persistent version.")
+	pflag.StringSlice("k8s_label_allowlist", []string{"app", "app.kubernetes.io/name", "version"},
+		"The K8s label keys to send to agents. Keys ending in '*' match by prefix.")
+	pflag.StringSlice("k8s_annotation_allowlist", []string{},
+		"The K8s annotation keys to send to agents. Keys ending in '*' match by prefix.")
+	pflag.Int32("tracepoint_rollout_canary_agents", 0, "If set, tracepoints are rolled out in stages by default, "+
+		"starting with this many agents. Otherwise, they are deployed to all agents at once unless the request says otherwise.")
+	pflag.Int32("tracepoint_rollout_wave_percent", 25, "The percentage of agents that each wave of a staged tracepoint rollout deploys to")
+	pflag.Int32("tracepoint_rollout_max_failure_percent", 10, "The percentage of agents that may fail to run a tracepoint "+
+		"before its staged rollout is rolled back")
+	pflag.Duration("tracepoint_rollout_wave_timeout", 2*time.Minute, "How long agents have to start running a tracepoint "+
+		"in a staged rollout before they count as failed")
+	pflag.Float64("agent_quarantine_threshold", 0.5, "Agents whose health score drops below this are quarantined "+
+		"from query plans. Set to 0 to disable automatic quarantine")
+	pflag.Float64("agent_reinstate_threshold", 0.8, "Quarantined agents are reinstated once their health score reaches this")
+	pflag.Duration("agent_health_failure_window", 10*time.Minute, "How long query and tracepoint failures count "+
+		"against the health score of an agent")
+}
+
+// agentHealthConfig returns the config for scoring the health of agents.
+func agentHealthConfig() agent.HealthConfig {
+	cfg := agent.DefaultHealthConfig()
+	cfg.QuarantineThreshold = viper.GetFloat64("agent_quarantine_threshold")
+	cfg.ReinstateThreshold = viper.GetFloat64("agent_reinstate_threshold")
+	cfg.FailureWindow = viper.GetDuration("agent_health_failure_window")
+	return cfg
+}
+
+// defaultTracepointRolloutPolicy returns the rollout policy for tracepoints that are registered
+// without one, or nil if they should be deployed to all agents at once.
+func defaultTracepointRolloutPolicy() *storepb.TracepointRolloutPolicy {
+	canaryAgents := viper.GetInt32("tracepoint_rollout_canary_agents")
+	if canaryAgents <= 0 {
+		return nil
+	}
+	return &storepb.TracepointRolloutPolicy{
+		CanaryAgents:      canaryAgents,
+		WavePercent:       viper.GetInt32("tracepoint_rollout_wave_percent"),
+		MaxFailurePercent: viper.GetInt32("tracepoint_rollout_max_failure_percent"),
+		WaveTimeout:       types.DurationProto(viper.GetDuration("tracepoint_rollout_wave_timeout")),
+	}
 }
 
 func mustInitEtcdDatastore() (*etcd.DataStore, func()) {
@@ -180,26 +224,36 @@ func main() {
 		cancel()
 	}()
 
-	var dataStore datastore.MultiGetterSetterDeleterCloser
-	var cleanupFunc func()
-	if viper.GetBool("use_etcd_operator") {
-		dataStore, cleanupFunc = mustInitEtcdDatastore()
-		defer cleanupFunc()
-	} else {
-		dataStore = mustInitPebbleDatastore()
-	}
-	defer dataStore.Close()
+	rawDataStore, cleanupFunc := mustInitDatastore()
+	defer cleanupFunc()
+	defer rawDataStore.Close()
+
+	// Instrument the datastore directly, so that the metrics measure the backend rather than the
+	// encryption layer.
+	rawDataStore, instrumentationCleanupFunc := initInstrumentation(rawDataStore)
+	defer instrumentationCleanupFunc()
+
+	// Restore the datastore, if needed, before anything reads from it. Snapshots are taken below the
+	// encryption layer, so that they stay encrypted.
+	snapshotMgr, snapshotCleanupFunc := mustInitSnapshots(rawDataStore)
+	defer snapshotCleanupFunc()
+
+	dataStore, encryptionCleanupFunc := mustInitEncryption(rawDataStore)
+	defer encryptionCleanupFunc()
 
 	k8sMds := k8smeta.NewDatastore(dataStore)
 	// Listen for K8s metadata updates.
 	updateCh := make(chan *k8smeta.K8sResourceMessage)
-	mdh := k8smeta.NewHandler(updateCh, k8sMds, nc)
+	mdh := k8smeta.NewHandler(updateCh, k8sMds, nc,
+		k8smeta.NewMetadataAllowList(viper.GetStringSlice("k8s_label_allowlist")),
+		k8smeta.NewMetadataAllowList(viper.GetStringSlice("k8s_annotation_allowlist")))
 
 	k8sMc, err := k8smeta.NewController(k8sMds, updateCh)
 	defer k8sMc.Stop()
 
 	ads := agent.NewDatastore(dataStore, 24*time.Hour)
 	agtMgr := agent.NewManager(ads, mdh, nc)
+	agtMgr.SetHealthConfig(agentHealthConfig())
 
 	schemaQuitCh := make(chan struct{})
 	defer close(schemaQuitCh)
@@ -223,6 +277,9 @@ func main() {
 	// Initialize tracepoint handler.
 	tracepointMgr := tracepoint.NewManager(tds, agtMgr, 30*time.Second)
 	defer tracepointMgr.Close()
+	tracepointMgr.SetDefaultRolloutPolicy(defaultTracepointRolloutPolicy())
+	tracepointMgr.ResumeRollouts()
+	tracepointMgr.SetTopology(mdh.GetTopology())
 
 	mc, err := controllers.NewMessageBusController(nc, agtMgr, tracepointMgr,
 		mdh, &isLeader)
@@ -239,6 +296,8 @@ func main() {
 	}
 	mux := http.NewServeMux()
 	healthz.RegisterDefaultChecks(mux)
+	// Like the rest of the mux, the metrics require a bearer token.
+	mux.Handle("/metrics", promhttp.Handler())
 
 	svr := controllers.NewServer(env, agtMgr, tracepointMgr)
 	log.Info("Metadata Server: " + version.GetVersion().ToString())
@@ -252,6 +311,8 @@ func main() {
 	metadatapb.RegisterMetadataServiceServer(s.GRPCServer(), svr)
 	metadatapb.RegisterMetadataTracepointServiceServer(s.GRPCServer(), svr)
 	metadatapb.RegisterMetadataConfigServiceServer(s.GRPCServer(), svr)
+	metadatapb.RegisterMetadataCronScriptServiceServer(s.GRPCServer(), cronscript.NewServer(cronscript.NewDatastore(dataStore)))
+	metadatapb.RegisterMetadataSnapshotServiceServer(s.GRPCServer(), backup.NewServer(snapshotMgr))
 
 	s.Start()
 	s.StopOnInterrupt()
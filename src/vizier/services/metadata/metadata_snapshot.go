@@ -0,0 +1,125 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+
+	"px.dev/pixie/src/vizier/utils/datastore"
+	"px.dev/pixie/src/vizier/utils/datastore/migrate"
+	"px.dev/pixie/src/vizier/utils/datastore/snapshot"
+	"px.dev/pixie/src/vizier/utils/objectstore"
+)
+
+func init() {
+	pflag.String("snapshot_store", "", "Where snapshots of the metadata datastore are saved: file or s3. Snapshots are disabled if unset")
+	pflag.String("snapshot_dir", "", "The directory snapshots are written to, required for the file store. This should be "+
+		"a mounted persistent volume, and not the same volume as the datastore, or the snapshots are lost with it")
+	pflag.String("snapshot_s3_endpoint", "", "The S3-compatible endpoint snapshots are written to, for the s3 store")
+	pflag.String("snapshot_s3_bucket", "", "The bucket snapshots are written to, for the s3 store")
+	pflag.String("snapshot_s3_region", "us-east-1", "The region of the bucket, for the s3 store")
+	pflag.String("snapshot_s3_prefix", "", "The prefix of the snapshot objects, for the s3 store")
+	pflag.String("snapshot_s3_access_key_id", "", "The access key ID used to write to the bucket, for the s3 store")
+	pflag.String("snapshot_s3_secret_access_key", "", "The secret access key used to write to the bucket, for the s3 store")
+	pflag.Duration("snapshot_interval", time.Hour, "How often to snapshot the metadata datastore. Scheduled snapshots are disabled if 0")
+	pflag.Int("snapshot_retain", 24, "The number of scheduled snapshots to keep")
+	pflag.String("snapshot_restore", "", "The name of the snapshot to restore, or \"latest\", if the datastore is empty on startup")
+}
+
+var errNotEmpty = errors.New("datastore is not empty")
+
+func isEmpty(ds datastore.Walker) (bool, error) {
+	err := ds.Walk(func(string, []byte, time.Duration) error {
+		return errNotEmpty
+	})
+	if err == errNotEmpty {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// mustInitSnapshots sets up snapshots of the datastore, if a snapshot store is configured. If a
+// restore is configured and the datastore is empty, for example because its volume was lost, the
+// snapshot is restored before returning. Scheduled snapshots are taken until the cleanup func runs.
+func mustInitSnapshots(ds migrate.Datastore) (*snapshot.Manager, func()) {
+	noop := func() {}
+	kind := viper.GetString("snapshot_store")
+	if kind == "" {
+		if viper.GetString("snapshot_restore") != "" {
+			log.Fatal("snapshot_restore requires a snapshot_store")
+		}
+		return nil, noop
+	}
+
+	store, err := objectstore.New(objectstore.Config{
+		Kind: kind,
+		Dir:  viper.GetString("snapshot_dir"),
+		S3: objectstore.S3Config{
+			Endpoint:        viper.GetString("snapshot_s3_endpoint"),
+			Bucket:          viper.GetString("snapshot_s3_bucket"),
+			Region:          viper.GetString("snapshot_s3_region"),
+			Prefix:          viper.GetString("snapshot_s3_prefix"),
+			AccessKeyID:     viper.GetString("snapshot_s3_access_key_id"),
+			SecretAccessKey: viper.GetString("snapshot_s3_secret_access_key"),
+		},
+	})
+	if err != nil {
+		log.WithError(err).Fatal("Failed to create snapshot store.")
+	}
+	m := snapshot.NewManager(ds, store)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if name := viper.GetString("snapshot_restore"); name != "" {
+		mustRestoreSnapshot(ctx, m, ds, name)
+	}
+
+	if interval := viper.GetDuration("snapshot_interval"); interval > 0 {
+		go m.Run(ctx, interval, viper.GetInt("snapshot_retain"))
+	}
+	return m, cancel
+}
+
+func mustRestoreSnapshot(ctx context.Context, m *snapshot.Manager, ds migrate.Datastore, name string) {
+	empty, err := isEmpty(ds)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to read datastore.")
+	}
+	if !empty {
+		log.Info("Datastore is not empty, skipping snapshot restore")
+		return
+	}
+
+	start := time.Now()
+	s, err := m.Restore(ctx, name, ds)
+	if errors.Is(err, snapshot.ErrNoSnapshots) {
+		log.Warn("No snapshots to restore, starting with an empty datastore")
+		return
+	}
+	if err != nil {
+		log.WithError(err).WithField("name", name).Fatal("Failed to restore snapshot.")
+	}
+	log.WithField("name", s.Name).WithField("keys", s.Keys).WithField("duration", time.Since(start)).
+		Info("Restored datastore from snapshot")
+}
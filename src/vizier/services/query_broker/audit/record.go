@@ -0,0 +1,104 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package audit
+
+import (
+	"time"
+
+	"px.dev/pixie/src/shared/services/jwtpb"
+)
+
+// Record is an audit log entry for a single script execution.
+type Record struct {
+	Time    time.Time `json:"time"`
+	QueryID string    `json:"query_id"`
+	Caller  Caller    `json:"caller"`
+	// The sha256 hash of the script text.
+	ScriptHash string     `json:"script_hash"`
+	FuncArgs   []FuncCall `json:"func_args,omitempty"`
+	Mutation   bool       `json:"mutation"`
+	Mutations  []Mutation `json:"mutations,omitempty"`
+	// The access policies that applied to the caller.
+	Policies []string `json:"policies,omitempty"`
+	// Whether the results were shared from an identical query.
+	CacheHit      bool  `json:"cache_hit"`
+	DurationNs    int64 `json:"duration_ns"`
+	BytesReturned int64 `json:"bytes_returned"`
+	// The final status code of the script, eg. OK or InvalidArgument.
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// Caller identifies who executed a script, from the claims of their JWT.
+type Caller struct {
+	Subject   string `json:"subject,omitempty"`
+	UserID    string `json:"user_id,omitempty"`
+	OrgID     string `json:"org_id,omitempty"`
+	Email     string `json:"email,omitempty"`
+	IsAPIUser bool   `json:"is_api_user,omitempty"`
+	ServiceID string `json:"service_id,omitempty"`
+	ClusterID string `json:"cluster_id,omitempty"`
+}
+
+// CallerFromClaims returns the caller identified by the claims.
+func CallerFromClaims(claims *jwtpb.JWTClaims) Caller {
+	if claims == nil {
+		return Caller{}
+	}
+	c := Caller{Subject: claims.Subject}
+	if uc := claims.GetUserClaims(); uc != nil {
+		c.UserID = uc.UserID
+		c.OrgID = uc.OrgID
+		c.Email = uc.Email
+		c.IsAPIUser = uc.IsAPIUser
+	}
+	if sc := claims.GetServiceClaims(); sc != nil {
+		c.ServiceID = sc.ServiceID
+	}
+	if cc := claims.GetClusterClaims(); cc != nil {
+		c.ClusterID = cc.ClusterID
+	}
+	return c
+}
+
+// FuncCall is a function executed by a script, with its arguments.
+type FuncCall struct {
+	Name              string            `json:"name"`
+	OutputTablePrefix string            `json:"output_table_prefix,omitempty"`
+	Args              map[string]string `json:"args,omitempty"`
+}
+
+// The types of mutations.
+const (
+	MutationDeployTracepoint = "deploy_tracepoint"
+	MutationDeleteTracepoint = "delete_tracepoint"
+	MutationUpdateConfig     = "update_config"
+)
+
+// Mutation is a change to the cluster state made by a script.
+type Mutation struct {
+	Type string `json:"type"`
+	// The name of the tracepoint, for tracepoint mutations.
+	Name string `json:"name,omitempty"`
+	TTL  string `json:"ttl,omitempty"`
+	// The config key and value, for config updates.
+	Key          string `json:"key,omitempty"`
+	Value        string `json:"value,omitempty"`
+	AgentPodName string `json:"agent_pod_name,omitempty"`
+}
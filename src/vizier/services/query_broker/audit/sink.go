@@ -0,0 +1,136 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Sink writes audit records.
+type Sink interface {
+	Write(r *Record) error
+	Close() error
+}
+
+// The supported sink types.
+const (
+	SinkNone   = "none"
+	SinkStdout = "stdout"
+	SinkFile   = "file"
+	SinkNATS   = "nats"
+)
+
+// NewSink creates the sink of the given type. The target is the path of the file for file sinks,
+// and the subject for NATS sinks. A nil sink is returned for SinkNone.
+func NewSink(sinkType string, target string, nc *nats.Conn) (Sink, error) {
+	switch sinkType {
+	case SinkNone, "":
+		return nil, nil
+	case SinkStdout:
+		return NewJSONSink(os.Stdout), nil
+	case SinkFile:
+		if target == "" {
+			return nil, fmt.Errorf("a path is required for the file audit sink")
+		}
+		s, err := NewFileSink(target)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case SinkNATS:
+		if target == "" {
+			return nil, fmt.Errorf("a subject is required for the NATS audit sink")
+		}
+		if nc == nil {
+			return nil, fmt.Errorf("a NATS connection is required for the NATS audit sink")
+		}
+		return NewNATSSink(nc, target), nil
+	default:
+		return nil, fmt.Errorf("unknown audit sink type '%s'", sinkType)
+	}
+}
+
+// JSONSink writes records as newline delimited JSON.
+type JSONSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	c   io.Closer
+}
+
+// NewJSONSink creates a sink writing JSON records to w.
+func NewJSONSink(w io.Writer) *JSONSink {
+	return &JSONSink{enc: json.NewEncoder(w)}
+}
+
+// NewFileSink creates a sink appending JSON records to the file at path.
+func NewFileSink(path string) (*JSONSink, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	s := NewJSONSink(f)
+	s.c = f
+	return s, nil
+}
+
+// Write writes the record.
+func (s *JSONSink) Write(r *Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(r)
+}
+
+// Close closes the underlying file, if any.
+func (s *JSONSink) Close() error {
+	if s.c == nil {
+		return nil
+	}
+	return s.c.Close()
+}
+
+// NATSSink publishes JSON records to a NATS subject.
+type NATSSink struct {
+	nc      *nats.Conn
+	subject string
+}
+
+// NewNATSSink creates a sink publishing records to the subject.
+func NewNATSSink(nc *nats.Conn, subject string) *NATSSink {
+	return &NATSSink{nc: nc, subject: subject}
+}
+
+// Write publishes the record.
+func (s *NATSSink) Write(r *Record) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return s.nc.Publish(s.subject, b)
+}
+
+// Close flushes pending records. The NATS connection is owned by the caller.
+func (s *NATSSink) Close() error {
+	return s.nc.Flush()
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package audit_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/shared/services/jwtpb"
+	"px.dev/pixie/src/utils/testingutils"
+	"px.dev/pixie/src/vizier/services/query_broker/audit"
+)
+
+func testRecord() *audit.Record {
+	return &audit.Record{
+		Time:       time.Unix(100, 0).UTC(),
+		QueryID:    "11285cdd-1de9-4ab1-ae6a-0ba08c8c676c",
+		Caller:     audit.Caller{UserID: "user", Email: "user@pixielabs.ai"},
+		ScriptHash: "abcd",
+		Mutation:   true,
+		Mutations: []audit.Mutation{
+			{Type: audit.MutationDeployTracepoint, Name: "tp", TTL: "5m0s"},
+		},
+		Status: "OK",
+	}
+}
+
+func TestCallerFromClaims(t *testing.T) {
+	claims := &jwtpb.JWTClaims{
+		Subject: "user",
+		CustomClaims: &jwtpb.JWTClaims_UserClaims{
+			UserClaims: &jwtpb.UserJWTClaims{
+				UserID: "user",
+				OrgID:  "org",
+				Email:  "user@pixielabs.ai",
+			},
+		},
+	}
+	assert.Equal(t, audit.Caller{
+		Subject: "user",
+		UserID:  "user",
+		OrgID:   "org",
+		Email:   "user@pixielabs.ai",
+	}, audit.CallerFromClaims(claims))
+
+	claims = &jwtpb.JWTClaims{
+		Subject: "service",
+		CustomClaims: &jwtpb.JWTClaims_ServiceClaims{
+			ServiceClaims: &jwtpb.ServiceJWTClaims{ServiceID: "cron"},
+		},
+	}
+	assert.Equal(t, audit.Caller{Subject: "service", ServiceID: "cron"}, audit.CallerFromClaims(claims))
+	assert.Equal(t, audit.Caller{}, audit.CallerFromClaims(nil))
+}
+
+func TestJSONSink(t *testing.T) {
+	var buf bytes.Buffer
+	s := audit.NewJSONSink(&buf)
+	require.NoError(t, s.Write(testRecord()))
+	require.NoError(t, s.Write(testRecord()))
+	require.NoError(t, s.Close())
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	var r audit.Record
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &r))
+	assert.Equal(t, testRecord(), &r)
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	s, err := audit.NewSink(audit.SinkFile, path, nil)
+	require.NoError(t, err)
+	require.NoError(t, s.Write(testRecord()))
+	require.NoError(t, s.Close())
+
+	// Records are appended to an existing log.
+	s, err = audit.NewSink(audit.SinkFile, path, nil)
+	require.NoError(t, err)
+	require.NoError(t, s.Write(testRecord()))
+	require.NoError(t, s.Close())
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(b)), "\n"), 2)
+}
+
+func TestNATSSink(t *testing.T) {
+	nc, cleanup := testingutils.MustStartTestNATS(t)
+	defer cleanup()
+
+	msgCh := make(chan *nats.Msg, 1)
+	sub, err := nc.ChanSubscribe("audit", msgCh)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, sub.Unsubscribe())
+	}()
+
+	s, err := audit.NewSink(audit.SinkNATS, "audit", nc)
+	require.NoError(t, err)
+	require.NoError(t, s.Write(testRecord()))
+	require.NoError(t, s.Close())
+
+	select {
+	case msg := <-msgCh:
+		var r audit.Record
+		require.NoError(t, json.Unmarshal(msg.Data, &r))
+		assert.Equal(t, testRecord(), &r)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for audit record")
+	}
+}
+
+func TestNewSink(t *testing.T) {
+	s, err := audit.NewSink(audit.SinkNone, "", nil)
+	require.NoError(t, err)
+	assert.Nil(t, s)
+
+	_, err = audit.NewSink(audit.SinkFile, "", nil)
+	assert.Error(t, err)
+	_, err = audit.NewSink(audit.SinkNATS, "audit", nil)
+	assert.Error(t, err)
+	_, err = audit.NewSink("syslog", "", nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,289 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"px.dev/pixie/src/vizier/services/query_broker/querybrokerpb"
+)
+
+// The delay clients are asked to wait before retrying a rejected query.
+const admissionRetryDelay = 5 * time.Second
+
+var (
+	admissionRunningQueries = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "query_broker_admission_running_queries",
+		Help: "The number of queries admitted and currently running.",
+	})
+	admissionQueuedQueries = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "query_broker_admission_queued_queries",
+		Help: "The number of queries waiting to be admitted.",
+	})
+	admissionLimit = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "query_broker_admission_limit",
+		Help: "The configured admission control limits. A value of 0 means unlimited.",
+	}, []string{"limit"})
+	admissionRejectedQueries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "query_broker_admission_rejected_queries_total",
+		Help: "The number of queries rejected by admission control, by reason.",
+	}, []string{"reason"})
+	admissionQueueWaitSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "query_broker_admission_queue_wait_seconds",
+		Help:    "The time queries spent waiting to be admitted.",
+		Buckets: prometheus.ExponentialBuckets(0.01, 4, 8),
+	})
+)
+
+func init() {
+	prometheus.MustRegister(admissionRunningQueries, admissionQueuedQueries, admissionLimit,
+		admissionRejectedQueries, admissionQueueWaitSeconds)
+}
+
+// AdmissionLimits configures how many queries may run at once.
+type AdmissionLimits struct {
+	// The maximum number of queries running across all users. 0 means unlimited.
+	MaxConcurrentQueries int
+	// The maximum number of queries running for a single user or API key. 0 means unlimited.
+	MaxConcurrentQueriesPerUser int
+	// The maximum number of queries waiting to be admitted. 0 disables queueing, so queries
+	// over the limits are rejected immediately.
+	MaxQueuedQueries int
+	// How long a query waits in the queue before being rejected. 0 means it waits until
+	// the client cancels the request.
+	QueueTimeout time.Duration
+}
+
+// AdmissionLimitsToProto converts AdmissionLimits to its proto representation.
+func AdmissionLimitsToProto(limits AdmissionLimits) *querybrokerpb.AdmissionLimits {
+	pb := &querybrokerpb.AdmissionLimits{
+		MaxConcurrentQueries:        int32(limits.MaxConcurrentQueries),
+		MaxConcurrentQueriesPerUser: int32(limits.MaxConcurrentQueriesPerUser),
+		MaxQueuedQueries:            int32(limits.MaxQueuedQueries),
+	}
+	if limits.QueueTimeout > 0 {
+		pb.QueueTimeout = types.DurationProto(limits.QueueTimeout)
+	}
+	return pb
+}
+
+// AdmissionLimitsFromProto converts and validates the proto representation of AdmissionLimits.
+func AdmissionLimitsFromProto(pb *querybrokerpb.AdmissionLimits) (AdmissionLimits, error) {
+	limits := AdmissionLimits{
+		MaxConcurrentQueries:        int(pb.MaxConcurrentQueries),
+		MaxConcurrentQueriesPerUser: int(pb.MaxConcurrentQueriesPerUser),
+		MaxQueuedQueries:            int(pb.MaxQueuedQueries),
+	}
+	if pb.QueueTimeout != nil {
+		timeout, err := types.DurationFromProto(pb.QueueTimeout)
+		if err != nil {
+			return AdmissionLimits{}, status.Errorf(codes.InvalidArgument, "invalid queue timeout: %v", err)
+		}
+		limits.QueueTimeout = timeout
+	}
+	if limits.MaxConcurrentQueries < 0 || limits.MaxConcurrentQueriesPerUser < 0 ||
+		limits.MaxQueuedQueries < 0 || limits.QueueTimeout < 0 {
+		return AdmissionLimits{}, status.Error(codes.InvalidArgument, "admission limits must not be negative")
+	}
+	return limits, nil
+}
+
+type admissionWaiter struct {
+	user string
+	// Closed when the waiter has been admitted.
+	admitted chan struct{}
+}
+
+// AdmissionController limits the number of queries that run concurrently, globally and per user.
+// Queries over the limits wait in a bounded FIFO queue.
+type AdmissionController struct {
+	mu             sync.Mutex
+	limits         AdmissionLimits
+	running        int
+	runningPerUser map[string]int
+	queue          *list.List
+}
+
+// NewAdmissionController creates an AdmissionController with the given limits.
+func NewAdmissionController(limits AdmissionLimits) *AdmissionController {
+	a := &AdmissionController{
+		runningPerUser: make(map[string]int),
+		queue:          list.New(),
+	}
+	a.SetLimits(limits)
+	return a
+}
+
+// Limits returns the current limits.
+func (a *AdmissionController) Limits() AdmissionLimits {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.limits
+}
+
+// Stats returns the number of running and queued queries.
+func (a *AdmissionController) Stats() (running int, queued int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.running, a.queue.Len()
+}
+
+// SetLimits updates the limits. Queued queries which fit within the new limits are admitted.
+func (a *AdmissionController) SetLimits(limits AdmissionLimits) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.limits = limits
+
+	admissionLimit.WithLabelValues("max_concurrent_queries").Set(float64(limits.MaxConcurrentQueries))
+	admissionLimit.WithLabelValues("max_concurrent_queries_per_user").Set(float64(limits.MaxConcurrentQueriesPerUser))
+	admissionLimit.WithLabelValues("max_queued_queries").Set(float64(limits.MaxQueuedQueries))
+	admissionLimit.WithLabelValues("queue_timeout_seconds").Set(limits.QueueTimeout.Seconds())
+
+	a.dispatchLocked()
+}
+
+func (a *AdmissionController) canRunLocked(user string) bool {
+	if a.limits.MaxConcurrentQueries > 0 && a.running >= a.limits.MaxConcurrentQueries {
+		return false
+	}
+	if a.limits.MaxConcurrentQueriesPerUser > 0 && a.runningPerUser[user] >= a.limits.MaxConcurrentQueriesPerUser {
+		return false
+	}
+	return true
+}
+
+func (a *AdmissionController) startLocked(user string) {
+	a.running++
+	a.runningPerUser[user]++
+	admissionRunningQueries.Set(float64(a.running))
+}
+
+// dispatchLocked admits queued queries, in order, while they fit within the limits. Queries which
+// are only blocked by their user's limit don't hold up queries from other users.
+func (a *AdmissionController) dispatchLocked() {
+	for e := a.queue.Front(); e != nil; {
+		next := e.Next()
+		w := e.Value.(*admissionWaiter)
+		if a.canRunLocked(w.user) {
+			a.queue.Remove(e)
+			a.startLocked(w.user)
+			close(w.admitted)
+		}
+		e = next
+	}
+	admissionQueuedQueries.Set(float64(a.queue.Len()))
+}
+
+func (a *AdmissionController) releaseFunc(user string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+			a.running--
+			a.runningPerUser[user]--
+			if a.runningPerUser[user] <= 0 {
+				delete(a.runningPerUser, user)
+			}
+			admissionRunningQueries.Set(float64(a.running))
+			a.dispatchLocked()
+		})
+	}
+}
+
+func rejectQuery(reason string, format string, args ...interface{}) error {
+	admissionRejectedQueries.WithLabelValues(reason).Inc()
+	st := status.New(codes.ResourceExhausted, fmt.Sprintf(format, args...))
+	if withDetails, err := st.WithDetails(&errdetails.RetryInfo{
+		RetryDelay: durationpb.New(admissionRetryDelay),
+	}); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// Admit blocks until the user is allowed to run a query. The returned func must be called once
+// the query completes. Returns a ResourceExhausted error if the query can't be admitted.
+func (a *AdmissionController) Admit(ctx context.Context, user string) (func(), error) {
+	a.mu.Lock()
+	// Queued queries never fit within the limits, since they are admitted as soon as they do, so
+	// a query that fits can skip the queue.
+	if a.canRunLocked(user) {
+		a.startLocked(user)
+		a.mu.Unlock()
+		return a.releaseFunc(user), nil
+	}
+	if a.queue.Len() >= a.limits.MaxQueuedQueries {
+		a.mu.Unlock()
+		return nil, rejectQuery("queue_full",
+			"too many queries are running, retry in %s", admissionRetryDelay)
+	}
+	w := &admissionWaiter{
+		user:     user,
+		admitted: make(chan struct{}),
+	}
+	e := a.queue.PushBack(w)
+	admissionQueuedQueries.Set(float64(a.queue.Len()))
+	timeout := a.limits.QueueTimeout
+	a.mu.Unlock()
+
+	start := time.Now()
+	var timeoutCh <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		timeoutCh = t.C
+	}
+
+	var err error
+	select {
+	case <-w.admitted:
+		admissionQueueWaitSeconds.Observe(time.Since(start).Seconds())
+		return a.releaseFunc(user), nil
+	case <-ctx.Done():
+		err = status.Error(codes.Canceled, "query was cancelled while waiting to be admitted")
+	case <-timeoutCh:
+		err = rejectQuery("queue_timeout",
+			"query was not admitted within %s, retry in %s", timeout, admissionRetryDelay)
+	}
+
+	a.mu.Lock()
+	select {
+	case <-w.admitted:
+		a.mu.Unlock()
+		// The query was admitted while it was giving up, so release its slot.
+		a.releaseFunc(user)()
+	default:
+		a.queue.Remove(e)
+		admissionQueuedQueries.Set(float64(a.queue.Len()))
+		a.mu.Unlock()
+	}
+	return nil, err
+}
@@ -0,0 +1,194 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/vizier/services/query_broker/controllers"
+	"px.dev/pixie/src/vizier/services/query_broker/querybrokerpb"
+)
+
+func TestAdmissionController_Unlimited(t *testing.T) {
+	a := controllers.NewAdmissionController(controllers.AdmissionLimits{})
+	for i := 0; i < 10; i++ {
+		_, err := a.Admit(context.Background(), "user1")
+		require.NoError(t, err)
+	}
+	running, queued := a.Stats()
+	assert.Equal(t, 10, running)
+	assert.Equal(t, 0, queued)
+}
+
+func TestAdmissionController_RejectWithoutQueue(t *testing.T) {
+	a := controllers.NewAdmissionController(controllers.AdmissionLimits{
+		MaxConcurrentQueries: 1,
+	})
+	release, err := a.Admit(context.Background(), "user1")
+	require.NoError(t, err)
+
+	_, err = a.Admit(context.Background(), "user2")
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	st, _ := status.FromError(err)
+	require.Len(t, st.Details(), 1)
+	_, ok := st.Details()[0].(*errdetails.RetryInfo)
+	assert.True(t, ok)
+
+	release()
+	// Releasing twice should have no effect.
+	release()
+	release, err = a.Admit(context.Background(), "user2")
+	require.NoError(t, err)
+	release()
+
+	running, _ := a.Stats()
+	assert.Equal(t, 0, running)
+}
+
+func TestAdmissionController_PerUserLimit(t *testing.T) {
+	a := controllers.NewAdmissionController(controllers.AdmissionLimits{
+		MaxConcurrentQueriesPerUser: 1,
+	})
+	_, err := a.Admit(context.Background(), "user1")
+	require.NoError(t, err)
+
+	_, err = a.Admit(context.Background(), "user1")
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	// Other users are unaffected.
+	_, err = a.Admit(context.Background(), "user2")
+	require.NoError(t, err)
+}
+
+func TestAdmissionController_QueueAdmitsOnRelease(t *testing.T) {
+	a := controllers.NewAdmissionController(controllers.AdmissionLimits{
+		MaxConcurrentQueries: 1,
+		MaxQueuedQueries:     1,
+	})
+	release, err := a.Admit(context.Background(), "user1")
+	require.NoError(t, err)
+
+	admitted := make(chan error)
+	go func() {
+		_, err := a.Admit(context.Background(), "user2")
+		admitted <- err
+	}()
+
+	require.Eventually(t, func() bool {
+		_, queued := a.Stats()
+		return queued == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// The queue is full.
+	_, err = a.Admit(context.Background(), "user3")
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+
+	release()
+	require.NoError(t, <-admitted)
+	running, queued := a.Stats()
+	assert.Equal(t, 1, running)
+	assert.Equal(t, 0, queued)
+}
+
+func TestAdmissionController_QueueTimeout(t *testing.T) {
+	a := controllers.NewAdmissionController(controllers.AdmissionLimits{
+		MaxConcurrentQueries: 1,
+		MaxQueuedQueries:     1,
+		QueueTimeout:         10 * time.Millisecond,
+	})
+	_, err := a.Admit(context.Background(), "user1")
+	require.NoError(t, err)
+
+	_, err = a.Admit(context.Background(), "user2")
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	_, queued := a.Stats()
+	assert.Equal(t, 0, queued)
+}
+
+func TestAdmissionController_QueueCancelled(t *testing.T) {
+	a := controllers.NewAdmissionController(controllers.AdmissionLimits{
+		MaxConcurrentQueries: 1,
+		MaxQueuedQueries:     1,
+	})
+	_, err := a.Admit(context.Background(), "user1")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = a.Admit(ctx, "user2")
+	assert.Equal(t, codes.Canceled, status.Code(err))
+	_, queued := a.Stats()
+	assert.Equal(t, 0, queued)
+}
+
+func TestAdmissionController_SetLimitsAdmitsQueued(t *testing.T) {
+	a := controllers.NewAdmissionController(controllers.AdmissionLimits{
+		MaxConcurrentQueries: 1,
+		MaxQueuedQueries:     1,
+	})
+	_, err := a.Admit(context.Background(), "user1")
+	require.NoError(t, err)
+
+	admitted := make(chan error)
+	go func() {
+		_, err := a.Admit(context.Background(), "user2")
+		admitted <- err
+	}()
+	require.Eventually(t, func() bool {
+		_, queued := a.Stats()
+		return queued == 1
+	}, time.Second, 10*time.Millisecond)
+
+	a.SetLimits(controllers.AdmissionLimits{
+		MaxConcurrentQueries: 2,
+		MaxQueuedQueries:     1,
+	})
+	require.NoError(t, <-admitted)
+}
+
+func TestAdmissionLimitsFromProto(t *testing.T) {
+	limits, err := controllers.AdmissionLimitsFromProto(&querybrokerpb.AdmissionLimits{
+		MaxConcurrentQueries:        10,
+		MaxConcurrentQueriesPerUser: 2,
+		MaxQueuedQueries:            5,
+		QueueTimeout:                types.DurationProto(time.Minute),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, controllers.AdmissionLimits{
+		MaxConcurrentQueries:        10,
+		MaxConcurrentQueriesPerUser: 2,
+		MaxQueuedQueries:            5,
+		QueueTimeout:                time.Minute,
+	}, limits)
+	assert.Equal(t, int32(10), controllers.AdmissionLimitsToProto(limits).MaxConcurrentQueries)
+
+	_, err = controllers.AdmissionLimitsFromProto(&querybrokerpb.AdmissionLimits{
+		MaxConcurrentQueries: -1,
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
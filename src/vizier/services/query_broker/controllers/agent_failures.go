@@ -0,0 +1,112 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/metadata"
+
+	"px.dev/pixie/src/api/proto/uuidpb"
+	jwtutils "px.dev/pixie/src/shared/services/utils"
+	"px.dev/pixie/src/utils"
+	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
+)
+
+// agentFailureReportTimeout is how long reporting agent failures to the metadata service may take.
+const agentFailureReportTimeout = 10 * time.Second
+
+// AgentFailureReporter reports the agents that failed to return results for a query, so that agents
+// that keep failing can be quarantined from query plans.
+type AgentFailureReporter interface {
+	ReportAgentFailures(queryID uuid.UUID, agentIDs []string, reason string) error
+}
+
+// MetadataAgentFailureReporter reports agent failures to the metadata service.
+type MetadataAgentFailureReporter struct {
+	mdsClient  metadatapb.MetadataServiceClient
+	signingKey string
+}
+
+// NewMetadataAgentFailureReporter creates a reporter that reports agent failures to the metadata service.
+func NewMetadataAgentFailureReporter(mdsClient metadatapb.MetadataServiceClient, signingKey string) *MetadataAgentFailureReporter {
+	return &MetadataAgentFailureReporter{
+		mdsClient:  mdsClient,
+		signingKey: signingKey,
+	}
+}
+
+// ReportAgentFailures reports that the given agents failed to return results for the query.
+func (r *MetadataAgentFailureReporter) ReportAgentFailures(queryID uuid.UUID, agentIDs []string, reason string) error {
+	if len(agentIDs) == 0 {
+		return nil
+	}
+	ids := make([]*uuidpb.UUID, len(agentIDs))
+	for i, agentID := range agentIDs {
+		id, err := uuid.FromString(agentID)
+		if err != nil {
+			return err
+		}
+		ids[i] = utils.ProtoFromUUID(id)
+	}
+
+	claims := jwtutils.GenerateJWTForService("query_broker", "vizier")
+	token, err := jwtutils.SignJWTClaims(claims, r.signingKey)
+	if err != nil {
+		return err
+	}
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization",
+		fmt.Sprintf("bearer %s", token))
+	ctx, cancel := context.WithTimeout(ctx, agentFailureReportTimeout)
+	defer cancel()
+
+	_, err = r.mdsClient.ReportAgentQueryFailures(ctx, &metadatapb.ReportAgentQueryFailuresRequest{
+		AgentIDs: ids,
+		Reason:   fmt.Sprintf("query %s: %s", queryID.String(), reason),
+	})
+	return err
+}
+
+// attributableFailures returns the failed agents of a query that are at fault for not returning
+// their results. When more than half of a query's agents failed, the agents receiving their data or
+// the network are likely at fault, so none of them are.
+func attributableFailures(failed []string, numAgents int) []string {
+	if len(failed)*2 > numAgents {
+		return nil
+	}
+	return failed
+}
+
+// reportAgentFailures reports the failed agents of a query in the background, so that the query
+// isn't held up by the metadata service.
+func (s *Server) reportAgentFailures(queryID uuid.UUID, agentIDs []string, reason string) {
+	if s.agentFailures == nil || len(agentIDs) == 0 {
+		return
+	}
+	go func() {
+		err := s.agentFailures.ReportAgentFailures(queryID, agentIDs, reason)
+		if err != nil {
+			log.WithError(err).WithField("query_id", queryID).Error("Failed to report agent failures")
+		}
+	}()
+}
@@ -0,0 +1,71 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"px.dev/pixie/src/api/proto/uuidpb"
+	"px.dev/pixie/src/utils"
+	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
+	mock_metadatapb "px.dev/pixie/src/vizier/services/metadata/metadatapb/mock"
+	"px.dev/pixie/src/vizier/services/query_broker/controllers"
+)
+
+func TestMetadataAgentFailureReporter(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	mds := mock_metadatapb.NewMockMetadataServiceClient(ctrl)
+
+	queryID := uuid.Must(uuid.NewV4())
+	agentID := uuid.Must(uuid.NewV4())
+
+	mds.
+		EXPECT().
+		ReportAgentQueryFailures(gomock.Any(), &metadatapb.ReportAgentQueryFailuresRequest{
+			AgentIDs: []*uuidpb.UUID{utils.ProtoFromUUID(agentID)},
+			Reason:   "query " + queryID.String() + ": disconnected before sending all of its results",
+		}).
+		DoAndReturn(func(ctx context.Context, req *metadatapb.ReportAgentQueryFailuresRequest, opts ...grpc.CallOption) (*metadatapb.ReportAgentQueryFailuresResponse, error) {
+			// The report is authorized as the query broker service.
+			md, ok := metadata.FromOutgoingContext(ctx)
+			require.True(t, ok)
+			assert.Len(t, md.Get("authorization"), 1)
+			return &metadatapb.ReportAgentQueryFailuresResponse{}, nil
+		})
+
+	r := controllers.NewMetadataAgentFailureReporter(mds, "signing_key")
+	err := r.ReportAgentFailures(queryID, []string{agentID.String()}, "disconnected before sending all of its results")
+	require.NoError(t, err)
+
+	// Nothing is reported when no agents failed.
+	err = r.ReportAgentFailures(queryID, nil, "disconnected before sending all of its results")
+	require.NoError(t, err)
+
+	err = r.ReportAgentFailures(queryID, []string{"not-a-uuid"}, "disconnected before sending all of its results")
+	assert.Error(t, err)
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/carnot/planner/plannerpb"
+	"px.dev/pixie/src/vizier/services/query_broker/audit"
+)
+
+// auditedStream records what is sent to the client of ExecuteScript for the audit log.
+type auditedStream struct {
+	vizierpb.VizierService_ExecuteScriptServer
+
+	bytesSent int64
+	cacheHit  bool
+	// The last error status sent to the client, if any.
+	errStatus *vizierpb.Status
+}
+
+func (a *auditedStream) Send(resp *vizierpb.ExecuteScriptResponse) error {
+	err := a.VizierService_ExecuteScriptServer.Send(resp)
+	if err != nil {
+		return err
+	}
+	a.bytesSent += int64(resp.Size())
+	if resp.GetData().GetExecutionStats().GetCacheHit() {
+		a.cacheHit = true
+	}
+	if s := resp.Status; s != nil && s.Code != int32(codes.OK) {
+		a.errStatus = s
+	}
+	if s := resp.GetMutationInfo().GetStatus(); s != nil && s.Code != int32(codes.OK) {
+		a.errStatus = s
+	}
+	return nil
+}
+
+func newAuditRecord(ctx context.Context, req *vizierpb.ExecuteScriptRequest) *audit.Record {
+	r := &audit.Record{
+		Time:       time.Now(),
+		QueryID:    req.QueryID,
+		ScriptHash: ScriptHash(req.QueryStr),
+		Mutation:   req.Mutation,
+	}
+	r.Caller = audit.CallerFromClaims(claimsFromContext(ctx))
+	for _, f := range req.ExecFuncs {
+		call := audit.FuncCall{
+			Name:              f.FuncName,
+			OutputTablePrefix: f.OutputTablePrefix,
+		}
+		if len(f.ArgValues) > 0 {
+			call.Args = make(map[string]string, len(f.ArgValues))
+			for _, arg := range f.ArgValues {
+				call.Args[arg.Name] = arg.Value
+			}
+		}
+		r.FuncArgs = append(r.FuncArgs, call)
+	}
+	return r
+}
+
+func auditMutations(mutations []*plannerpb.CompileMutation) []audit.Mutation {
+	var muts []audit.Mutation
+	for _, mut := range mutations {
+		switch mut := mut.Mutation.(type) {
+		case *plannerpb.CompileMutation_Trace:
+			m := audit.Mutation{
+				Type: audit.MutationDeployTracepoint,
+				Name: mut.Trace.Name,
+			}
+			if ttl, err := types.DurationFromProto(mut.Trace.TTL); err == nil {
+				m.TTL = ttl.String()
+			}
+			muts = append(muts, m)
+		case *plannerpb.CompileMutation_DeleteTracepoint:
+			muts = append(muts, audit.Mutation{
+				Type: audit.MutationDeleteTracepoint,
+				Name: mut.DeleteTracepoint.Name,
+			})
+		case *plannerpb.CompileMutation_ConfigUpdate:
+			muts = append(muts, audit.Mutation{
+				Type:         audit.MutationUpdateConfig,
+				Key:          mut.ConfigUpdate.Key,
+				Value:        mut.ConfigUpdate.Value,
+				AgentPodName: mut.ConfigUpdate.AgentPodName,
+			})
+		}
+	}
+	return muts
+}
+
+// finishAuditRecord fills in the outcome of the script from the stream and the error returned by ExecuteScript.
+func finishAuditRecord(r *audit.Record, stream *auditedStream, err error) {
+	r.DurationNs = time.Since(r.Time).Nanoseconds()
+	r.BytesReturned = stream.bytesSent
+	r.CacheHit = stream.cacheHit
+	switch {
+	case err != nil:
+		r.Status = status.Code(err).String()
+		r.Message = status.Convert(err).Message()
+	case stream.errStatus != nil:
+		r.Status = codes.Code(stream.errStatus.Code).String()
+		r.Message = stream.errStatus.Message
+	default:
+		r.Status = codes.OK.String()
+	}
+}
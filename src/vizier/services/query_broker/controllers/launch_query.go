@@ -80,3 +80,27 @@ func LaunchQuery(queryID uuid.UUID, natsConn *nats.Conn, planMap map[uuid.UUID]*
 
 	return nil
 }
+
+// CancelQuery tells the given agents to stop executing a query.
+func CancelQuery(queryID uuid.UUID, natsConn *nats.Conn, agentIDs []uuid.UUID) error {
+	msg := messagespb.VizierMessage{
+		Msg: &messagespb.VizierMessage_CancelQueryRequest{
+			CancelQueryRequest: &messagespb.CancelQueryRequest{
+				QueryID: utils.ProtoFromUUID(queryID),
+			},
+		},
+	}
+	msgAsBytes, err := msg.Marshal()
+	if err != nil {
+		return err
+	}
+
+	var eg errgroup.Group
+	for _, agentID := range agentIDs {
+		agentTopic := messagebus.AgentUUIDTopic(agentID)
+		eg.Go(func() error {
+			return natsConn.Publish(agentTopic, msgAsBytes)
+		})
+	}
+	return eg.Wait()
+}
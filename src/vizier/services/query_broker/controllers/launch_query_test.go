@@ -25,6 +25,7 @@ import (
 
 	"github.com/gofrs/uuid"
 	"github.com/gogo/protobuf/proto"
+	"github.com/nats-io/nats.go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -176,3 +177,33 @@ func TestLaunchQueryNATSFailure(t *testing.T) {
 	err = controllers.LaunchQuery(queryUUID, nc, planMap, false)
 	require.NotNil(t, err)
 }
+
+func TestCancelQuery(t *testing.T) {
+	nc, cleanup := testingutils.MustStartTestNATS(t)
+	defer cleanup()
+
+	queryUUID := uuid.Must(uuid.FromString(queryIDStr))
+	agentUUIDs := []uuid.UUID{
+		uuid.Must(uuid.FromString(agent1ID)),
+		uuid.Must(uuid.FromString(agent2ID)),
+	}
+
+	var subs []*nats.Subscription
+	for _, agentID := range agentUUIDs {
+		sub, err := nc.SubscribeSync(fmt.Sprintf("Agent/%s", agentID.String()))
+		require.NoError(t, err)
+		subs = append(subs, sub)
+	}
+
+	require.NoError(t, controllers.CancelQuery(queryUUID, nc, agentUUIDs))
+
+	for _, sub := range subs {
+		m, err := sub.NextMsg(time.Second)
+		require.NoError(t, err)
+		pb := &messagespb.VizierMessage{}
+		require.NoError(t, proto.Unmarshal(m.Data, pb))
+		req := pb.GetCancelQueryRequest()
+		require.NotNil(t, req)
+		assert.Equal(t, queryUUID, utils.UUIDFromProtoOrNil(req.QueryID))
+	}
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/status"
+)
+
+// The statuses queries are counted by in query_broker_queries_total.
+const (
+	queryStatusOK               = "ok"
+	queryStatusCompilationError = "compilation_error"
+	queryStatusPolicyDenied     = "policy_denied"
+	queryStatusTruncated        = "truncated"
+	queryStatusPartial          = "partial"
+	queryStatusError            = "error"
+)
+
+var (
+	queriesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "query_broker_queries_total",
+		Help: "The number of queries run, by status.",
+	}, []string{"status"})
+	queryErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "query_broker_query_errors_total",
+		Help: "The number of queries that failed, by gRPC error code.",
+	}, []string{"code"})
+	activeQueries = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "query_broker_active_queries",
+		Help: "The number of queries currently being compiled or executed.",
+	})
+	queryCompileSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "query_broker_query_compile_seconds",
+		Help:    "The time spent compiling queries.",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 12),
+	})
+	queryExecutionSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "query_broker_query_execution_seconds",
+		Help:    "The time from when a query is launched on the agents until its results are streamed.",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2, 14),
+	})
+
+	forwarderActiveQueries = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "query_broker_forwarder_active_queries",
+		Help: "The number of queries registered in the result forwarder.",
+	})
+	forwarderPendingChunks = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "query_broker_forwarder_pending_chunks",
+		Help: "The number of result chunks from agents waiting to be forwarded to the client stream.",
+	})
+	forwardedRowsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "query_broker_forwarded_rows_total",
+		Help: "The number of result rows forwarded to clients.",
+	})
+	forwardedBytesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "query_broker_forwarded_bytes_total",
+		Help: "The number of result bytes forwarded to clients.",
+	})
+
+	agentResultChunksTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "query_broker_agent_result_chunks_total",
+		Help: "The number of result chunks received from agents.",
+	})
+	agentResponseSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "query_broker_agent_response_seconds",
+		Help:    "The time from when a query is registered until an agent finishes sending the results for a table.",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2, 14),
+	})
+	agentStreamFailuresTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "query_broker_agent_stream_failures_total",
+		Help: "The number of agent result streams that failed, by gRPC error code.",
+	}, []string{"code"})
+)
+
+func init() {
+	prometheus.MustRegister(queriesTotal, queryErrorsTotal, activeQueries, queryCompileSeconds,
+		queryExecutionSeconds, forwarderActiveQueries, forwarderPendingChunks, forwardedRowsTotal,
+		forwardedBytesTotal, agentResultChunksTotal, agentResponseSeconds, agentStreamFailuresTotal)
+}
+
+// recordQueryResult counts a finished query. outcome is the query's status if it didn't fail.
+func recordQueryResult(outcome string, err error) {
+	if err != nil {
+		outcome = queryStatusError
+		queryErrorsTotal.WithLabelValues(status.Code(err).String()).Inc()
+	}
+	queriesTotal.WithLabelValues(outcome).Inc()
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/vizier/services/query_broker/controllers"
+)
+
+// metricValue returns the value of the unlabelled counter or gauge with the given name.
+func metricValue(t *testing.T, name string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		require.Len(t, family.GetMetric(), 1)
+		m := family.GetMetric()[0]
+		if m.GetCounter() != nil {
+			return m.GetCounter().GetValue()
+		}
+		return m.GetGauge().GetValue()
+	}
+	t.Fatalf("metric %s not registered", name)
+	return 0
+}
+
+func TestStreamResultsMetrics(t *testing.T) {
+	queryID := uuid.Must(uuid.NewV4())
+	f := controllers.NewQueryResultForwarderWithTimeout(1 * time.Second)
+
+	rowsBefore := metricValue(t, "query_broker_forwarded_rows_total")
+	bytesBefore := metricValue(t, "query_broker_forwarded_bytes_total")
+	activeBefore := metricValue(t, "query_broker_forwarder_active_queries")
+
+	require.NoError(t, f.RegisterQuery(queryID, map[string]string{"foo": "123"}, nil, nil))
+	assert.Equal(t, activeBefore+1, metricValue(t, "query_broker_forwarder_active_queries"))
+
+	resultCh := make(chan *vizierpb.ExecuteScriptResponse)
+	errCh := make(chan error)
+	go func() {
+		errCh <- f.StreamResults(context.Background(), queryID, resultCh, 0, nil, controllers.QueryBudget{})
+	}()
+
+	expected, in := makeRowBatchResult(t, queryID, "foo", "123" /*eos*/, true)
+	_, stats := makeExecStatsResult(t, queryID)
+
+	go func() {
+		for range resultCh {
+		}
+	}()
+
+	require.NoError(t, f.ForwardQueryResult(makeInitiateTableRequest(queryID, "foo")))
+	require.NoError(t, f.ForwardQueryResult(in))
+	require.NoError(t, f.ForwardQueryResult(stats))
+	require.NoError(t, <-errCh)
+	close(resultCh)
+
+	assert.Equal(t, rowsBefore+float64(expected.NumRows), metricValue(t, "query_broker_forwarded_rows_total"))
+	assert.Less(t, bytesBefore, metricValue(t, "query_broker_forwarded_bytes_total"))
+	assert.Equal(t, activeBefore, metricValue(t, "query_broker_forwarder_active_queries"))
+	assert.Equal(t, 0.0, metricValue(t, "query_broker_forwarder_pending_chunks"))
+}
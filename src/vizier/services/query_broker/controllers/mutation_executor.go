@@ -36,6 +36,7 @@ import (
 	"px.dev/pixie/src/shared/services/authcontext"
 	"px.dev/pixie/src/utils"
 	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
+	"px.dev/pixie/src/vizier/services/query_broker/policy"
 )
 
 // TracepointMap stores a map from the name to tracepoint info.
@@ -49,6 +50,10 @@ type MutationExecutor struct {
 	activeTracepoints TracepointMap
 	outputTables      []string
 	distributedState  *distributedpb.DistributedState
+	// The access restrictions of the caller. Nil if there are none.
+	restrictions *policy.Effective
+	// The mutations compiled from the script.
+	mutations []*plannerpb.CompileMutation
 }
 
 // TracepointInfo stores information of a particular tracepoint.
@@ -73,6 +78,49 @@ func NewMutationExecutor(
 	}
 }
 
+// SetRestrictions sets the access restrictions of the caller, which are checked before any
+// mutation is applied.
+func (m *MutationExecutor) SetRestrictions(restrictions *policy.Effective) {
+	m.restrictions = restrictions
+}
+
+// checkRestrictions returns a status describing the violation if the caller's access restrictions
+// don't allow one of the mutations. Deleted tracepoints are looked up, so that callers can only
+// delete tracepoints they could have deployed.
+func (m *MutationExecutor) checkRestrictions(ctx context.Context, mutations []*plannerpb.CompileMutation) (*statuspb.Status, error) {
+	if m.restrictions.Empty() {
+		return nil, nil
+	}
+	var deleted []string
+	for _, mut := range mutations {
+		switch mut := mut.Mutation.(type) {
+		case *plannerpb.CompileMutation_Trace:
+			if st := m.restrictions.CheckTracepoint(mut.Trace); st != nil {
+				return st, nil
+			}
+		case *plannerpb.CompileMutation_DeleteTracepoint:
+			deleted = append(deleted, mut.DeleteTracepoint.Name)
+		case *plannerpb.CompileMutation_ConfigUpdate:
+			if st := m.restrictions.CheckConfigUpdate(); st != nil {
+				return st, nil
+			}
+		}
+	}
+	if len(deleted) == 0 {
+		return nil, nil
+	}
+	resp, err := m.mdtp.GetTracepointDetails(ctx, &metadatapb.GetTracepointDetailsRequest{Names: deleted})
+	if err != nil {
+		return nil, err
+	}
+	for _, tp := range resp.Tracepoints {
+		if st := m.restrictions.CheckTracepoint(tp.Info.GetTracepoint()); st != nil {
+			return st, nil
+		}
+	}
+	return nil, nil
+}
+
 // Execute runs the mutation. On unknown errors it will return an error, otherwise we return a status message
 // that has more context about the error message.
 func (m *MutationExecutor) Execute(ctx context.Context, req *vizierpb.ExecuteScriptRequest, planOpts *planpb.PlanOptions) (*statuspb.Status, error) {
@@ -93,6 +141,7 @@ func (m *MutationExecutor) Execute(ctx context.Context, req *vizierpb.ExecuteScr
 	if mutations.Status != nil && mutations.Status.ErrCode != statuspb.OK {
 		return mutations.Status, nil
 	}
+	m.mutations = mutations.Mutations
 	aCtx, err := authcontext.FromContext(ctx)
 	if err != nil {
 		return nil, err
@@ -104,6 +153,16 @@ func (m *MutationExecutor) Execute(ctx context.Context, req *vizierpb.ExecuteScr
 		return nil, nil
 	}
 
+	if st, err := m.checkRestrictions(ctx, mutations.Mutations); st != nil || err != nil {
+		return st, err
+	}
+
+	selector, err := VizierTracepointSelectorToStoreSelector(req.TracepointSelector)
+	if err != nil {
+		return &statuspb.Status{ErrCode: statuspb.INVALID_ARGUMENT, Msg: err.Error()}, nil
+	}
+	rollout := VizierTracepointRolloutPolicyToStorePolicy(req.TracepointRollout)
+
 	registerTracepointsReq := &metadatapb.RegisterTracepointRequest{
 		Requests: make([]*metadatapb.RegisterTracepointRequest_TracepointRequest, 0),
 	}
@@ -125,6 +184,8 @@ func (m *MutationExecutor) Execute(ctx context.Context, req *vizierpb.ExecuteScr
 						TracepointDeployment: mut.Trace,
 						Name:                 mut.Trace.Name,
 						TTL:                  mut.Trace.TTL,
+						Selector:             selector,
+						Rollout:              rollout,
 					})
 
 				if _, ok := m.activeTracepoints[name]; ok {
@@ -211,6 +272,11 @@ func (m *MutationExecutor) Execute(ctx context.Context, req *vizierpb.ExecuteScr
 	return nil, nil
 }
 
+// Mutations returns the mutations compiled from the script by Execute.
+func (m *MutationExecutor) Mutations() []*plannerpb.CompileMutation {
+	return m.mutations
+}
+
 // MutationInfo returns the summarized mutation information.
 func (m *MutationExecutor) MutationInfo(ctx context.Context) (*vizierpb.MutationInfo, error) {
 	req := &metadatapb.GetTracepointInfoRequest{
@@ -19,12 +19,29 @@
 package controllers_test
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/gogo/protobuf/proto"
+	"github.com/gogo/protobuf/types"
 	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"px.dev/pixie/src/api/proto/vizierpb"
 	"px.dev/pixie/src/carnot/planner/distributedpb"
+	"px.dev/pixie/src/carnot/planner/dynamic_tracing/ir/logicalpb"
+	"px.dev/pixie/src/carnot/planner/plannerpb"
+	"px.dev/pixie/src/carnot/planpb"
+	"px.dev/pixie/src/common/base/statuspb"
+	"px.dev/pixie/src/shared/services/authcontext"
+	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
+	mock_metadatapb "px.dev/pixie/src/vizier/services/metadata/metadatapb/mock"
+	"px.dev/pixie/src/vizier/services/metadata/storepb"
+	"px.dev/pixie/src/vizier/services/query_broker/controllers"
+	mock_controllers "px.dev/pixie/src/vizier/services/query_broker/controllers/mock"
+	"px.dev/pixie/src/vizier/services/query_broker/policy"
 )
 
 func TestMutationExecutor_Execute(t *testing.T) {
@@ -39,3 +56,229 @@ func TestMutationExecutor_Execute(t *testing.T) {
 
 	t.Skip("These tests are incomplete and need to be done")
 }
+
+func podTracepointDeployment(name string, pod string) *logicalpb.TracepointDeployment {
+	return &logicalpb.TracepointDeployment{
+		Name: name,
+		DeploymentSpec: &logicalpb.DeploymentSpec{
+			TargetOneof: &logicalpb.DeploymentSpec_PodProcess_{
+				PodProcess: &logicalpb.DeploymentSpec_PodProcess{Pod: pod},
+			},
+		},
+		Programs: []*logicalpb.TracepointDeployment_TracepointProgram{
+			{TableName: name + "_table"},
+		},
+	}
+}
+
+func TestMutationExecutor_Restrictions(t *testing.T) {
+	restrictions := policy.Resolve([]policy.Policy{
+		{Name: "team-a-only", Namespaces: []string{"team-a"}},
+	}, nil)
+	deployed := map[string]*logicalpb.TracepointDeployment{
+		"team_a_probe": podTracepointDeployment("team_a_probe", "team-a/server"),
+		"team_b_probe": podTracepointDeployment("team_b_probe", "team-b/server"),
+	}
+
+	tests := []struct {
+		name     string
+		mutation *plannerpb.CompileMutation
+		allowed  bool
+	}{
+		{
+			name: "deploy to allowed namespace",
+			mutation: &plannerpb.CompileMutation{
+				Mutation: &plannerpb.CompileMutation_Trace{Trace: podTracepointDeployment("probe", "team-a/server")},
+			},
+			allowed: true,
+		},
+		{
+			name: "deploy to other namespace",
+			mutation: &plannerpb.CompileMutation{
+				Mutation: &plannerpb.CompileMutation_Trace{Trace: podTracepointDeployment("probe", "team-b/server")},
+			},
+		},
+		{
+			name: "delete in allowed namespace",
+			mutation: &plannerpb.CompileMutation{
+				Mutation: &plannerpb.CompileMutation_DeleteTracepoint{
+					DeleteTracepoint: &plannerpb.DeleteTracepoint{Name: "team_a_probe"},
+				},
+			},
+			allowed: true,
+		},
+		{
+			name: "delete in other namespace",
+			mutation: &plannerpb.CompileMutation{
+				Mutation: &plannerpb.CompileMutation_DeleteTracepoint{
+					DeleteTracepoint: &plannerpb.DeleteTracepoint{Name: "team_b_probe"},
+				},
+			},
+		},
+		{
+			name: "config update",
+			mutation: &plannerpb.CompileMutation{
+				Mutation: &plannerpb.CompileMutation_ConfigUpdate{
+					ConfigUpdate: &plannerpb.ConfigUpdate{Key: "gprof", Value: "true", AgentPodName: "pem-1"},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			planner := mock_controllers.NewMockPlanner(ctrl)
+			planner.EXPECT().CompileMutations(gomock.Any(), gomock.Any()).Return(&plannerpb.CompileMutationsResponse{
+				Status:    &statuspb.Status{ErrCode: statuspb.OK},
+				Mutations: []*plannerpb.CompileMutation{test.mutation},
+			}, nil)
+			mdtp := mock_metadatapb.NewMockMetadataTracepointServiceClient(ctrl)
+			mdconf := mock_metadatapb.NewMockMetadataConfigServiceClient(ctrl)
+			mdtp.EXPECT().GetTracepointDetails(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, req *metadatapb.GetTracepointDetailsRequest, opts ...interface{}) (*metadatapb.GetTracepointDetailsResponse, error) {
+					resp := &metadatapb.GetTracepointDetailsResponse{}
+					for _, name := range req.Names {
+						resp.Tracepoints = append(resp.Tracepoints, &metadatapb.GetTracepointDetailsResponse_TracepointDetails{
+							Info: &storepb.TracepointInfo{Name: name, Tracepoint: deployed[name]},
+						})
+					}
+					return resp, nil
+				}).AnyTimes()
+
+			// Mutations are only applied if they are allowed.
+			if test.allowed {
+				mdtp.EXPECT().RegisterTracepoint(gomock.Any(), gomock.Any()).
+					Return(&metadatapb.RegisterTracepointResponse{
+						Tracepoints: []*metadatapb.RegisterTracepointResponse_TracepointStatus{{Name: "probe"}},
+					}, nil).AnyTimes()
+				mdtp.EXPECT().RemoveTracepoint(gomock.Any(), gomock.Any()).
+					Return(&metadatapb.RemoveTracepointResponse{}, nil).AnyTimes()
+			}
+
+			exec := controllers.NewMutationExecutor(planner, mdtp, mdconf, &distributedpb.DistributedState{})
+			exec.SetRestrictions(restrictions)
+			ctx := authcontext.NewContext(context.Background(), authcontext.New())
+			st, err := exec.Execute(ctx, &vizierpb.ExecuteScriptRequest{
+				QueryStr: "import pxtrace",
+				Mutation: true,
+			}, &planpb.PlanOptions{})
+			require.NoError(t, err)
+			if test.allowed {
+				assert.Nil(t, st)
+				return
+			}
+			require.NotNil(t, st)
+			assert.Equal(t, statuspb.PERMISSION_DENIED, st.ErrCode)
+		})
+	}
+}
+
+func TestMutationExecutor_TracepointOptions(t *testing.T) {
+	tests := []struct {
+		name         string
+		selector     *vizierpb.TracepointSelector
+		wantSelector *storepb.TracepointSelector
+		rollout      *vizierpb.TracepointRolloutPolicy
+		wantRollout  *storepb.TracepointRolloutPolicy
+		wantErr      bool
+	}{
+		{
+			name: "no options",
+		},
+		{
+			name: "selector",
+			selector: &vizierpb.TracepointSelector{
+				NodeNames:  []string{"node-1"},
+				NodeLabels: "pool=a, disk=ssd",
+				Namespaces: []string{"ns1"},
+				PodLabels:  "app=frontend",
+			},
+			wantSelector: &storepb.TracepointSelector{
+				NodeNames:  []string{"node-1"},
+				NodeLabels: map[string]string{"pool": "a", "disk": "ssd"},
+				Namespaces: []string{"ns1"},
+				PodLabels:  map[string]string{"app": "frontend"},
+			},
+		},
+		{
+			name: "rollout",
+			rollout: &vizierpb.TracepointRolloutPolicy{
+				CanaryAgents:      2,
+				WavePercent:       25,
+				MaxFailurePercent: 10,
+				WaveTimeoutNS:     int64(time.Minute),
+			},
+			wantRollout: &storepb.TracepointRolloutPolicy{
+				CanaryAgents:      2,
+				WavePercent:       25,
+				MaxFailurePercent: 10,
+				WaveTimeout:       types.DurationProto(time.Minute),
+			},
+		},
+		{
+			name: "rollout without wave timeout",
+			rollout: &vizierpb.TracepointRolloutPolicy{
+				CanaryAgents: 1,
+			},
+			wantRollout: &storepb.TracepointRolloutPolicy{
+				CanaryAgents: 1,
+			},
+		},
+		{
+			name: "invalid label selector",
+			selector: &vizierpb.TracepointSelector{
+				NodeLabels: "pool",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			planner := mock_controllers.NewMockPlanner(ctrl)
+			planner.EXPECT().CompileMutations(gomock.Any(), gomock.Any()).Return(&plannerpb.CompileMutationsResponse{
+				Status: &statuspb.Status{ErrCode: statuspb.OK},
+				Mutations: []*plannerpb.CompileMutation{
+					{
+						Mutation: &plannerpb.CompileMutation_Trace{Trace: podTracepointDeployment("probe", "ns1/server")},
+					},
+				},
+			}, nil)
+			mdtp := mock_metadatapb.NewMockMetadataTracepointServiceClient(ctrl)
+			mdconf := mock_metadatapb.NewMockMetadataConfigServiceClient(ctrl)
+			if !test.wantErr {
+				mdtp.EXPECT().RegisterTracepoint(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(ctx context.Context, req *metadatapb.RegisterTracepointRequest, opts ...interface{}) (*metadatapb.RegisterTracepointResponse, error) {
+						require.Equal(t, 1, len(req.Requests))
+						assert.Equal(t, test.wantSelector, req.Requests[0].Selector)
+						assert.Equal(t, test.wantRollout, req.Requests[0].Rollout)
+						return &metadatapb.RegisterTracepointResponse{
+							Tracepoints: []*metadatapb.RegisterTracepointResponse_TracepointStatus{{Name: "probe"}},
+						}, nil
+					})
+			}
+
+			exec := controllers.NewMutationExecutor(planner, mdtp, mdconf, &distributedpb.DistributedState{})
+			ctx := authcontext.NewContext(context.Background(), authcontext.New())
+			st, err := exec.Execute(ctx, &vizierpb.ExecuteScriptRequest{
+				QueryStr:           "import pxtrace",
+				Mutation:           true,
+				TracepointSelector: test.selector,
+				TracepointRollout:  test.rollout,
+			}, &planpb.PlanOptions{})
+			require.NoError(t, err)
+			if !test.wantErr {
+				assert.Nil(t, st)
+				return
+			}
+			require.NotNil(t, st)
+			assert.Equal(t, statuspb.INVALID_ARGUMENT, st.ErrCode)
+		})
+	}
+}
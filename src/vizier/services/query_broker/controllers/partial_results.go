@@ -0,0 +1,229 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/carnot/planpb"
+	"px.dev/pixie/src/carnot/queryresultspb"
+	"px.dev/pixie/src/utils"
+)
+
+// How long the query broker waits past the partial results deadline for the agents to send
+// what they have, before it returns the results without them.
+const partialResultsGracePeriod = 5 * time.Second
+
+// PartialResultsOpts configures how a query completes when some of its agents fail or are slow.
+type PartialResultsOpts struct {
+	// The fraction of agents that must report for the partial results to be returned.
+	Quorum float64
+	// How long an agent waits for the agents that send it data before continuing without them.
+	// 0 waits until they finish or fail.
+	Deadline time.Duration
+}
+
+// PartialResultsError is returned by the result forwarder when it stops waiting for slow agents
+// and returns the results it has. The client has already been sent the results and the list of
+// missing agents.
+type PartialResultsError struct {
+	QueryID       string
+	MissingAgents []string
+}
+
+func (e *PartialResultsError) Error() string {
+	return fmt.Sprintf("query %s returned partial results, missing agents: %s",
+		e.QueryID, strings.Join(e.MissingAgents, ", "))
+}
+
+// QueryAgents describes the agents a query runs on and the result tables that depend on each
+// of them.
+type QueryAgents struct {
+	numAgents int
+	// The result tables each agent sends to the query broker.
+	producerTables map[uuid.UUID][]string
+	// The agents sending data to each GRPC source, keyed by the agent running the source.
+	sourceAgents map[uuid.UUID]map[uint64][]uuid.UUID
+	// The result tables that depend on data from each agent.
+	affectedTables map[uuid.UUID][]string
+	// The agents whose result streams broke before they sent all of their data.
+	disconnected map[uuid.UUID]bool
+}
+
+func forEachPlanNode(plan *planpb.Plan, f func(node *planpb.PlanNode)) {
+	for _, fragment := range plan.Nodes {
+		for _, node := range fragment.Nodes {
+			f(node)
+		}
+	}
+}
+
+// NewQueryAgents builds the agent graph of a query from its per-agent plans.
+func NewQueryAgents(planMap map[uuid.UUID]*planpb.Plan) *QueryAgents {
+	a := &QueryAgents{
+		numAgents:      len(planMap),
+		producerTables: make(map[uuid.UUID][]string),
+		sourceAgents:   make(map[uuid.UUID]map[uint64][]uuid.UUID),
+		affectedTables: make(map[uuid.UUID][]string),
+	}
+
+	sourceIDs := make(map[uuid.UUID]map[uint64]bool)
+	for agentID, plan := range planMap {
+		agentID := agentID
+		sourceIDs[agentID] = make(map[uint64]bool)
+		forEachPlanNode(plan, func(node *planpb.PlanNode) {
+			switch node.Op.OpType {
+			case planpb.GRPC_SOURCE_OPERATOR:
+				sourceIDs[agentID][node.Id] = true
+			case planpb.GRPC_SINK_OPERATOR:
+				if output := node.Op.GetGRPCSinkOp().GetOutputTable(); output != nil {
+					a.producerTables[agentID] = append(a.producerTables[agentID], output.TableName)
+				}
+			}
+		})
+	}
+
+	// Match the sinks of each agent's upstream agents to its GRPC sources.
+	downstream := make(map[uuid.UUID][]uuid.UUID)
+	for agentID, plan := range planMap {
+		agentID := agentID
+		for _, upstreamPB := range plan.IncomingAgentIDs {
+			upstreamID := utils.UUIDFromProtoOrNil(upstreamPB)
+			upstreamPlan, ok := planMap[upstreamID]
+			if !ok {
+				continue
+			}
+			downstream[upstreamID] = append(downstream[upstreamID], agentID)
+			forEachPlanNode(upstreamPlan, func(node *planpb.PlanNode) {
+				if node.Op.OpType != planpb.GRPC_SINK_OPERATOR {
+					return
+				}
+				dest, ok := node.Op.GetGRPCSinkOp().GetDestination().(*planpb.GRPCSinkOperator_GRPCSourceID)
+				if !ok || !sourceIDs[agentID][dest.GRPCSourceID] {
+					return
+				}
+				if a.sourceAgents[agentID] == nil {
+					a.sourceAgents[agentID] = make(map[uint64][]uuid.UUID)
+				}
+				a.sourceAgents[agentID][dest.GRPCSourceID] = append(a.sourceAgents[agentID][dest.GRPCSourceID], upstreamID)
+			})
+		}
+	}
+
+	var collect func(agentID uuid.UUID, tables map[string]bool, visited map[uuid.UUID]bool)
+	collect = func(agentID uuid.UUID, tables map[string]bool, visited map[uuid.UUID]bool) {
+		if visited[agentID] {
+			return
+		}
+		visited[agentID] = true
+		for _, table := range a.producerTables[agentID] {
+			tables[table] = true
+		}
+		for _, next := range downstream[agentID] {
+			collect(next, tables, visited)
+		}
+	}
+	for agentID := range planMap {
+		tables := make(map[string]bool)
+		collect(agentID, tables, make(map[uuid.UUID]bool))
+		for table := range tables {
+			a.affectedTables[agentID] = append(a.affectedTables[agentID], table)
+		}
+		sort.Strings(a.affectedTables[agentID])
+	}
+	return a
+}
+
+// incompleteAgents returns the agents whose data didn't fully reach the agents reporting the
+// given stats.
+func (a *QueryAgents) incompleteAgents(stats []*queryresultspb.AgentExecutionStats) []uuid.UUID {
+	var agents []uuid.UUID
+	for _, s := range stats {
+		agentID := utils.UUIDFromProtoOrNil(s.AgentID)
+		for _, sourceID := range s.IncompleteGRPCSourceIDs {
+			agents = append(agents, a.sourceAgents[agentID][uint64(sourceID)]...)
+		}
+	}
+	return agents
+}
+
+// markDisconnected records that the given agents' result streams broke.
+func (a *QueryAgents) markDisconnected(agentIDs []uuid.UUID) {
+	if a.disconnected == nil {
+		a.disconnected = make(map[uuid.UUID]bool)
+	}
+	for _, agentID := range agentIDs {
+		a.disconnected[agentID] = true
+	}
+}
+
+// DisconnectedAgents returns the agents whose result streams broke before they sent all of their
+// data, because of a transport or agent error. Agents that were only slow aren't included. It
+// must not be called while the query's results are being streamed.
+func (a *QueryAgents) DisconnectedAgents() []string {
+	var agentIDs []string
+	for agentID := range a.disconnected {
+		agentIDs = append(agentIDs, agentID.String())
+	}
+	sort.Strings(agentIDs)
+	return agentIDs
+}
+
+// pendingAgents returns the agents that still have result tables to send.
+func (a *QueryAgents) pendingAgents(remainingTables *concurrentSet) []uuid.UUID {
+	var agents []uuid.UUID
+	for agentID, tables := range a.producerTables {
+		for _, table := range tables {
+			if remainingTables.exists(table) {
+				agents = append(agents, agentID)
+				break
+			}
+		}
+	}
+	return agents
+}
+
+// missingAgents describes the given agents and the result tables that lack their data.
+func (a *QueryAgents) missingAgents(agentIDs map[uuid.UUID]bool) []*vizierpb.MissingAgent {
+	var missing []*vizierpb.MissingAgent
+	for agentID := range agentIDs {
+		missing = append(missing, &vizierpb.MissingAgent{
+			AgentID: agentID.String(),
+			Tables:  a.affectedTables[agentID],
+		})
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		return missing[i].AgentID < missing[j].AgentID
+	})
+	return missing
+}
+
+// hasQuorum returns whether enough agents reported for the results to be returned.
+func (a *QueryAgents) hasQuorum(numMissing int, quorum float64) bool {
+	if a.numAgents == 0 {
+		return true
+	}
+	return float64(a.numAgents-numMissing) >= quorum*float64(a.numAgents)
+}
@@ -0,0 +1,189 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"px.dev/pixie/src/carnot/planner/distributedpb"
+	"px.dev/pixie/src/carnot/planner/plannerpb"
+	"px.dev/pixie/src/carnot/planpb"
+	"px.dev/pixie/src/utils"
+)
+
+var planCacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "query_broker_plan_cache_requests_total",
+	Help: "The number of plan cache lookups, by result.",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(planCacheRequests)
+}
+
+type planCacheEntry struct {
+	key       string
+	result    *distributedpb.LogicalPlannerResult
+	expiresAt time.Time
+}
+
+// PlanCache is an LRU cache of compiled query plans.
+//
+// The compiler resolves relative times (eg. start_time='-5m') against the time of compilation,
+// so a cached plan reuses the time window of the original compilation. The TTL bounds how stale
+// those windows can get.
+//
+// Plans are cached per distributed state, since callers with different policies see different
+// schemas. Plans for distributed states that are no longer used age out of the LRU.
+type PlanCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	// Keyed by the distributed state hash and the plan cache key.
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+// NewPlanCache creates a plan cache which holds up to maxEntries plans for at most ttl.
+func NewPlanCache(maxEntries int, ttl time.Duration) *PlanCache {
+	return &PlanCache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// HashDistributedState returns a hash of the agents and schemas in the distributed state.
+// The agents are hashed in order of their IDs, since the tracker builds them from a map. The
+// per-agent metadata info changes whenever pods come and go, so it is left out of the hash; the
+// TTL bounds how stale the agent filtering of a cached plan can get.
+func HashDistributedState(ds *distributedpb.DistributedState) (string, error) {
+	canonical := &distributedpb.DistributedState{
+		CarnotInfo: make([]*distributedpb.CarnotInfo, len(ds.CarnotInfo)),
+		SchemaInfo: ds.SchemaInfo,
+	}
+	for i, info := range ds.CarnotInfo {
+		c := *info
+		c.MetadataInfo = nil
+		canonical.CarnotInfo[i] = &c
+	}
+	sort.SliceStable(canonical.CarnotInfo, func(i, j int) bool {
+		a := utils.UUIDFromProtoOrNil(canonical.CarnotInfo[i].AgentID).String()
+		b := utils.UUIDFromProtoOrNil(canonical.CarnotInfo[j].AgentID).String()
+		if a != b {
+			return a < b
+		}
+		return canonical.CarnotInfo[i].ASID < canonical.CarnotInfo[j].ASID
+	})
+
+	b, err := canonical.Marshal()
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:]), nil
+}
+
+// PlanCacheKey returns the cache key for a query, its arguments and plan options.
+func PlanCacheKey(req *plannerpb.QueryRequest, planOpts *planpb.PlanOptions) (string, error) {
+	h := sha256.New()
+	h.Write([]byte(strings.TrimSpace(req.QueryStr)))
+	for _, f := range req.ExecFuncs {
+		b, err := f.Marshal()
+		if err != nil {
+			return "", err
+		}
+		h.Write(b)
+	}
+	if planOpts != nil {
+		b, err := planOpts.Marshal()
+		if err != nil {
+			return "", err
+		}
+		h.Write(b)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func planCacheEntryKey(dsHash string, key string) string {
+	return dsHash + "/" + key
+}
+
+// Get returns the cached plan for the key, if it was compiled against the given distributed state.
+// The returned plan must not be modified.
+func (c *PlanCache) Get(dsHash string, key string) (*distributedpb.LogicalPlannerResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key = planCacheEntryKey(dsHash, key)
+	e, ok := c.entries[key]
+	if !ok {
+		planCacheRequests.WithLabelValues("miss").Inc()
+		return nil, false
+	}
+	entry := e.Value.(*planCacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.lru.Remove(e)
+		delete(c.entries, key)
+		planCacheRequests.WithLabelValues("miss").Inc()
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	planCacheRequests.WithLabelValues("hit").Inc()
+	return entry.result, true
+}
+
+// Put adds a plan compiled against the given distributed state to the cache.
+func (c *PlanCache) Put(dsHash string, key string, result *distributedpb.LogicalPlannerResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key = planCacheEntryKey(dsHash, key)
+	entry := &planCacheEntry{
+		key:       key,
+		result:    result,
+		expiresAt: time.Now().Add(c.ttl),
+	}
+	if e, ok := c.entries[key]; ok {
+		e.Value = entry
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*planCacheEntry).key)
+	}
+}
+
+// Len returns the number of cached plans.
+func (c *PlanCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers_test
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/carnot/planner/distributedpb"
+	"px.dev/pixie/src/carnot/planner/plannerpb"
+	"px.dev/pixie/src/carnot/planpb"
+	"px.dev/pixie/src/shared/metadatapb"
+	"px.dev/pixie/src/utils"
+	"px.dev/pixie/src/vizier/services/query_broker/controllers"
+)
+
+func mustPlanCacheKey(t *testing.T, req *plannerpb.QueryRequest, planOpts *planpb.PlanOptions) string {
+	key, err := controllers.PlanCacheKey(req, planOpts)
+	require.NoError(t, err)
+	return key
+}
+
+func mustHashDistributedState(t *testing.T, numAgents int) string {
+	ds := &distributedpb.DistributedState{}
+	for i := 0; i < numAgents; i++ {
+		ds.CarnotInfo = append(ds.CarnotInfo, &distributedpb.CarnotInfo{
+			AgentID: utils.ProtoFromUUIDStrOrNil(agent1ID),
+			ASID:    uint32(i),
+		})
+	}
+	h, err := controllers.HashDistributedState(ds)
+	require.NoError(t, err)
+	return h
+}
+
+func TestPlanCacheKey(t *testing.T) {
+	req := &plannerpb.QueryRequest{QueryStr: "px.display(df)"}
+	key := mustPlanCacheKey(t, req, &planpb.PlanOptions{})
+
+	// Surrounding whitespace doesn't change the key.
+	assert.Equal(t, key, mustPlanCacheKey(t, &plannerpb.QueryRequest{QueryStr: "\n px.display(df)\n"}, &planpb.PlanOptions{}))
+	// Plan options, function args and the query itself do.
+	assert.NotEqual(t, key, mustPlanCacheKey(t, req, &planpb.PlanOptions{Analyze: true}))
+	assert.NotEqual(t, key, mustPlanCacheKey(t, &plannerpb.QueryRequest{QueryStr: "px.display(df2)"}, &planpb.PlanOptions{}))
+	withArgs := &plannerpb.QueryRequest{
+		QueryStr: "px.display(df)",
+		ExecFuncs: []*plannerpb.FuncToExecute{
+			{
+				FuncName: "f",
+				ArgValues: []*plannerpb.FuncToExecute_ArgValue{
+					{Name: "start_time", Value: "-5m"},
+				},
+			},
+		},
+	}
+	assert.NotEqual(t, key, mustPlanCacheKey(t, withArgs, &planpb.PlanOptions{}))
+}
+
+func TestHashDistributedState_Canonical(t *testing.T) {
+	var infos []*distributedpb.CarnotInfo
+	for i := 0; i < 5; i++ {
+		infos = append(infos, &distributedpb.CarnotInfo{
+			AgentID: utils.ProtoFromUUID(uuid.Must(uuid.NewV4())),
+			ASID:    uint32(i),
+			MetadataInfo: &distributedpb.MetadataInfo{
+				MetadataFields: []metadatapb.MetadataType{metadatapb.POD_NAME},
+			},
+		})
+	}
+	h, err := controllers.HashDistributedState(&distributedpb.DistributedState{CarnotInfo: infos})
+	require.NoError(t, err)
+
+	// The order of the agents doesn't matter.
+	for i := 0; i < 10; i++ {
+		shuffled := make([]*distributedpb.CarnotInfo, len(infos))
+		copy(shuffled, infos)
+		rand.Shuffle(len(shuffled), func(i, j int) {
+			shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+		})
+		shuffledHash, err := controllers.HashDistributedState(&distributedpb.DistributedState{CarnotInfo: shuffled})
+		require.NoError(t, err)
+		assert.Equal(t, h, shuffledHash)
+	}
+
+	// Neither does the metadata the agents have.
+	infos[0].MetadataInfo = nil
+	noMetadataHash, err := controllers.HashDistributedState(&distributedpb.DistributedState{CarnotInfo: infos})
+	require.NoError(t, err)
+	assert.Equal(t, h, noMetadataHash)
+
+	// But the agents do.
+	fewerAgentsHash, err := controllers.HashDistributedState(&distributedpb.DistributedState{CarnotInfo: infos[1:]})
+	require.NoError(t, err)
+	assert.NotEqual(t, h, fewerAgentsHash)
+}
+
+func TestPlanCache_GetPut(t *testing.T) {
+	c := controllers.NewPlanCache(10, time.Minute)
+	ds := mustHashDistributedState(t, 1)
+	result := &distributedpb.LogicalPlannerResult{}
+
+	_, ok := c.Get(ds, "a")
+	assert.False(t, ok)
+
+	c.Put(ds, "a", result)
+	cached, ok := c.Get(ds, "a")
+	require.True(t, ok)
+	assert.Same(t, result, cached)
+}
+
+func TestPlanCache_DistributedStateChange(t *testing.T) {
+	c := controllers.NewPlanCache(10, time.Minute)
+	ds1 := mustHashDistributedState(t, 1)
+	ds2 := mustHashDistributedState(t, 2)
+
+	c.Put(ds1, "a", &distributedpb.LogicalPlannerResult{})
+	c.Put(ds1, "b", &distributedpb.LogicalPlannerResult{})
+	assert.Equal(t, 2, c.Len())
+
+	// Plans compiled against other agents or schemas aren't used.
+	_, ok := c.Get(ds2, "a")
+	assert.False(t, ok)
+
+	// Caching a plan for one distributed state doesn't drop the plans for another, so callers
+	// that see different schemas don't evict each other's plans.
+	c.Put(ds2, "a", &distributedpb.LogicalPlannerResult{})
+	assert.Equal(t, 3, c.Len())
+	_, ok = c.Get(ds1, "a")
+	assert.True(t, ok)
+	_, ok = c.Get(ds2, "a")
+	assert.True(t, ok)
+}
+
+func TestPlanCache_TTL(t *testing.T) {
+	c := controllers.NewPlanCache(10, time.Millisecond)
+	ds := mustHashDistributedState(t, 1)
+
+	c.Put(ds, "a", &distributedpb.LogicalPlannerResult{})
+	time.Sleep(5 * time.Millisecond)
+	_, ok := c.Get(ds, "a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestPlanCache_Eviction(t *testing.T) {
+	c := controllers.NewPlanCache(2, time.Minute)
+	ds := mustHashDistributedState(t, 1)
+
+	c.Put(ds, "a", &distributedpb.LogicalPlannerResult{})
+	c.Put(ds, "b", &distributedpb.LogicalPlannerResult{})
+	// Touch a so that b is the least recently used.
+	_, ok := c.Get(ds, "a")
+	require.True(t, ok)
+	c.Put(ds, "c", &distributedpb.LogicalPlannerResult{})
+
+	assert.Equal(t, 2, c.Len())
+	_, ok = c.Get(ds, "b")
+	assert.False(t, ok)
+	_, ok = c.Get(ds, "a")
+	assert.True(t, ok)
+	_, ok = c.Get(ds, "c")
+	assert.True(t, ok)
+}
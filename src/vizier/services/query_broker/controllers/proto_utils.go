@@ -21,12 +21,14 @@ package controllers
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/gofrs/uuid"
 	"github.com/gogo/protobuf/types"
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"k8s.io/apimachinery/pkg/labels"
 
 	"px.dev/pixie/src/api/proto/vizierpb"
 	"px.dev/pixie/src/carnot/carnotpb"
@@ -39,6 +41,7 @@ import (
 	"px.dev/pixie/src/shared/types/typespb"
 	"px.dev/pixie/src/table_store/schemapb"
 	"px.dev/pixie/src/utils"
+	"px.dev/pixie/src/vizier/services/metadata/storepb"
 )
 
 var dataTypeToVizierDataType = map[typespb.DataType]vizierpb.DataType{
@@ -148,6 +151,45 @@ func VizierQueryRequestToPlannerQueryRequest(vpb *vizierpb.ExecuteScriptRequest)
 	}, nil
 }
 
+// VizierTracepointSelectorToStoreSelector converts the externally-facing tracepoint selector, whose
+// labels are given as label selectors, to the one stored by the metadata service.
+func VizierTracepointSelectorToStoreSelector(sel *vizierpb.TracepointSelector) (*storepb.TracepointSelector, error) {
+	if sel == nil {
+		return nil, nil
+	}
+	nodeLabels, err := labels.ConvertSelectorToLabelsMap(sel.NodeLabels)
+	if err != nil {
+		return nil, fmt.Errorf("invalid node label selector %q: %v", sel.NodeLabels, err)
+	}
+	podLabels, err := labels.ConvertSelectorToLabelsMap(sel.PodLabels)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pod label selector %q: %v", sel.PodLabels, err)
+	}
+	return &storepb.TracepointSelector{
+		NodeNames:  sel.NodeNames,
+		NodeLabels: nodeLabels,
+		Namespaces: sel.Namespaces,
+		PodLabels:  podLabels,
+	}, nil
+}
+
+// VizierTracepointRolloutPolicyToStorePolicy converts the externally-facing tracepoint rollout policy
+// to the one stored by the metadata service.
+func VizierTracepointRolloutPolicyToStorePolicy(policy *vizierpb.TracepointRolloutPolicy) *storepb.TracepointRolloutPolicy {
+	if policy == nil {
+		return nil
+	}
+	storePolicy := &storepb.TracepointRolloutPolicy{
+		CanaryAgents:      policy.CanaryAgents,
+		WavePercent:       policy.WavePercent,
+		MaxFailurePercent: policy.MaxFailurePercent,
+	}
+	if policy.WaveTimeoutNS > 0 {
+		storePolicy.WaveTimeout = types.DurationProto(time.Duration(policy.WaveTimeoutNS))
+	}
+	return storePolicy
+}
+
 // ErrToVizierResponse converts an error to an externally-facing Vizier response message
 func ErrToVizierResponse(id uuid.UUID, err error) *vizierpb.ExecuteScriptResponse {
 	return &vizierpb.ExecuteScriptResponse{
@@ -248,6 +290,27 @@ func QueryResultStatsToVizierStats(e *queryresultspb.QueryExecutionStats, compil
 	}
 }
 
+// TruncatedResponse is the last response sent for a query that was stopped before all of its
+// agents finished, either because it exceeded its budget or because it returned partial results.
+func TruncatedResponse(queryID uuid.UUID, reason string, missing []*vizierpb.MissingAgent,
+	compilationTimeNs int64, executionTimeNs int64) *vizierpb.ExecuteScriptResponse {
+	return &vizierpb.ExecuteScriptResponse{
+		QueryID: queryID.String(),
+		Result: &vizierpb.ExecuteScriptResponse_Data{
+			Data: &vizierpb.QueryData{
+				ExecutionStats: &vizierpb.QueryExecutionStats{
+					Timing: &vizierpb.QueryTimingInfo{
+						ExecutionTimeNs:   executionTimeNs,
+						CompilationTimeNs: compilationTimeNs,
+					},
+					TruncationReason: reason,
+					MissingAgents:    missing,
+				},
+			},
+		},
+	}
+}
+
 // UInt128ToVizierUInt128 converts our internal representation of UInt128 to Vizier's representation of UInt128.
 func UInt128ToVizierUInt128(i *typespb.UInt128) *vizierpb.UInt128 {
 	return &vizierpb.UInt128{
@@ -351,6 +414,12 @@ func BuildExecuteScriptResponse(r *carnotpb.TransferResultChunkRequest,
 
 	if execStats := r.GetExecutionAndTimingInfo(); execStats != nil {
 		stats := QueryResultStatsToVizierStats(execStats.ExecutionStats, compilationTimeNs)
+		for _, agentStats := range execStats.AgentExecutionStats {
+			if agentStats.ScanTruncated {
+				stats.TruncationReason = TruncatedByMaxRowsScannedPerAgent
+				break
+			}
+		}
 		res.Result = &vizierpb.ExecuteScriptResponse_Data{
 			Data: &vizierpb.QueryData{
 				ExecutionStats: stats,
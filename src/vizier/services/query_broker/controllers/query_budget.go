@@ -0,0 +1,84 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	"px.dev/pixie/src/carnot/planpb"
+)
+
+// The budgets a query's results can be truncated by. These are reported to the client in
+// QueryExecutionStats.truncation_reason.
+const (
+	TruncatedByTimeout                = "timeout"
+	TruncatedByMaxBytesForwarded      = "max_bytes_forwarded"
+	TruncatedByMaxRowsScannedPerAgent = "max_rows_scanned_per_agent"
+)
+
+// QueryBudget bounds the resources a single query can use. A zero value means unlimited.
+type QueryBudget struct {
+	// Wall-clock time after which the query is cancelled on all agents.
+	Timeout time.Duration
+	// Max number of result bytes forwarded to the client.
+	MaxBytesForwarded int64
+	// Max number of rows each agent scans from its tables.
+	MaxRowsScannedPerAgent int64
+}
+
+// QueryBudgetFromPlanOptions returns the budget set in the plan options.
+func QueryBudgetFromPlanOptions(planOpts *planpb.PlanOptions) QueryBudget {
+	return QueryBudget{
+		Timeout:                time.Duration(planOpts.TimeoutNs),
+		MaxBytesForwarded:      planOpts.MaxBytesForwarded,
+		MaxRowsScannedPerAgent: planOpts.MaxRowsScannedPerAgent,
+	}
+}
+
+// Limit lowers the budget in the plan options to the limits in b. Budgets the query doesn't
+// set default to the limit.
+func (b QueryBudget) Limit(planOpts *planpb.PlanOptions) {
+	planOpts.TimeoutNs = minBudget(planOpts.TimeoutNs, int64(b.Timeout))
+	planOpts.MaxBytesForwarded = minBudget(planOpts.MaxBytesForwarded, b.MaxBytesForwarded)
+	planOpts.MaxRowsScannedPerAgent = minBudget(planOpts.MaxRowsScannedPerAgent, b.MaxRowsScannedPerAgent)
+}
+
+// minBudget returns the smaller of two budgets, where 0 is unlimited.
+func minBudget(a, b int64) int64 {
+	if a <= 0 {
+		return b
+	}
+	if b <= 0 || a < b {
+		return a
+	}
+	return b
+}
+
+// BudgetExceededError is returned by the result forwarder when it stops streaming a query's
+// results because the query exceeded its budget. The client has already been sent the partial
+// results and the truncation status.
+type BudgetExceededError struct {
+	QueryID string
+	Reason  string
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("query %s exceeded its %s budget", e.QueryID, e.Reason)
+}
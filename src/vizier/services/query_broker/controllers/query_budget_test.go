@@ -0,0 +1,60 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"px.dev/pixie/src/carnot/planpb"
+	"px.dev/pixie/src/vizier/services/query_broker/controllers"
+)
+
+func TestQueryBudget_Limit(t *testing.T) {
+	limits := controllers.QueryBudget{
+		Timeout:           time.Minute,
+		MaxBytesForwarded: 1000,
+	}
+
+	// Budgets the query doesn't set default to the limits.
+	planOpts := &planpb.PlanOptions{}
+	limits.Limit(planOpts)
+	assert.Equal(t, int64(time.Minute), planOpts.TimeoutNs)
+	assert.Equal(t, int64(1000), planOpts.MaxBytesForwarded)
+	assert.Equal(t, int64(0), planOpts.MaxRowsScannedPerAgent)
+
+	// Queries can lower their budgets, but not raise them above the limits.
+	planOpts = &planpb.PlanOptions{
+		TimeoutNs:              int64(time.Second),
+		MaxBytesForwarded:      5000,
+		MaxRowsScannedPerAgent: 10,
+	}
+	limits.Limit(planOpts)
+	assert.Equal(t, int64(time.Second), planOpts.TimeoutNs)
+	assert.Equal(t, int64(1000), planOpts.MaxBytesForwarded)
+	assert.Equal(t, int64(10), planOpts.MaxRowsScannedPerAgent)
+
+	assert.Equal(t, controllers.QueryBudget{
+		Timeout:                time.Second,
+		MaxBytesForwarded:      1000,
+		MaxRowsScannedPerAgent: 10,
+	}, controllers.QueryBudgetFromPlanOptions(planOpts))
+}
@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/cast"
 
@@ -38,6 +39,17 @@ var defaultQueryFlags = map[string]interface{}{
 	"explain":                   false,
 	"analyze":                   false,
 	"max_output_rows_per_table": 10000,
+	// Budgets for the query. 0 means the query broker's limit applies.
+	"timeout_ms":                 0,
+	"max_bytes_forwarded":        0,
+	"max_rows_scanned_per_agent": 0,
+	// Return the results of the agents that finished when other agents fail or are slow.
+	"partial_results": false,
+	// The fraction of agents that must report for partial results to be returned.
+	"partial_results_quorum": 0.5,
+	// How long to wait for slow agents before returning partial results. 0 waits for every
+	// agent to finish or fail.
+	"partial_results_deadline_ms": 0,
 }
 
 // QueryFlags represents a set of Pixie configuration flags.
@@ -118,10 +130,29 @@ func (f *QueryFlags) set(key string, value string) error {
 
 // GetPlanOptions creates the plan option proto from the specified query flags.
 func (f *QueryFlags) GetPlanOptions() *planpb.PlanOptions {
-	return &planpb.PlanOptions{
-		Explain:               f.GetBool("explain"),
-		Analyze:               f.GetBool("analyze"),
-		MaxOutputRowsPerTable: f.GetInt64("max_output_rows_per_table"),
+	planOpts := &planpb.PlanOptions{
+		Explain:                f.GetBool("explain"),
+		Analyze:                f.GetBool("analyze"),
+		MaxOutputRowsPerTable:  f.GetInt64("max_output_rows_per_table"),
+		TimeoutNs:              f.GetInt64("timeout_ms") * int64(time.Millisecond),
+		MaxBytesForwarded:      f.GetInt64("max_bytes_forwarded"),
+		MaxRowsScannedPerAgent: f.GetInt64("max_rows_scanned_per_agent"),
+	}
+	if partial := f.GetPartialResultsOpts(); partial != nil {
+		planOpts.PartialResultsDeadlineNs = int64(partial.Deadline)
+	}
+	return planOpts
+}
+
+// GetPartialResultsOpts returns the partial results options, or nil if the query doesn't
+// accept partial results.
+func (f *QueryFlags) GetPartialResultsOpts() *PartialResultsOpts {
+	if !f.GetBool("partial_results") {
+		return nil
+	}
+	return &PartialResultsOpts{
+		Quorum:   f.GetFloat64("partial_results_quorum"),
+		Deadline: time.Duration(f.GetInt64("partial_results_deadline_ms")) * time.Millisecond,
 	}
 }
 
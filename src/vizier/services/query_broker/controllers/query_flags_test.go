@@ -20,6 +20,7 @@ package controllers_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -110,3 +111,39 @@ func TestParseQueryFlags_PlanOptions(t *testing.T) {
 	assert.Equal(t, options.Explain, false)
 	assert.Equal(t, options.Analyze, true)
 }
+
+func TestParseQueryFlags_Budgets(t *testing.T) {
+	qf, err := controllers.ParseQueryFlags(`
+#px:set timeout_ms=1500
+#px:set max_bytes_forwarded=1000000
+#px:set max_rows_scanned_per_agent=5000
+`)
+	require.NoError(t, err)
+
+	options := qf.GetPlanOptions()
+	assert.Equal(t, int64(1500*time.Millisecond), options.TimeoutNs)
+	assert.Equal(t, int64(1000000), options.MaxBytesForwarded)
+	assert.Equal(t, int64(5000), options.MaxRowsScannedPerAgent)
+}
+
+func TestParseQueryFlags_PartialResults(t *testing.T) {
+	qf, err := controllers.ParseQueryFlags(`
+#px:set partial_results_deadline_ms=2000
+`)
+	require.NoError(t, err)
+	// The deadline only applies when partial results are enabled.
+	assert.Nil(t, qf.GetPartialResultsOpts())
+	assert.Equal(t, int64(0), qf.GetPlanOptions().PartialResultsDeadlineNs)
+
+	qf, err = controllers.ParseQueryFlags(`
+#px:set partial_results=true
+#px:set partial_results_quorum=0.75
+#px:set partial_results_deadline_ms=2000
+`)
+	require.NoError(t, err)
+	assert.Equal(t, &controllers.PartialResultsOpts{
+		Quorum:   0.75,
+		Deadline: 2 * time.Second,
+	}, qf.GetPartialResultsOpts())
+	assert.Equal(t, int64(2*time.Second), qf.GetPlanOptions().PartialResultsDeadlineNs)
+}
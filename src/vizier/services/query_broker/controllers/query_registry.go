@@ -0,0 +1,183 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/gogo/protobuf/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/shared/services/authcontext"
+	"px.dev/pixie/src/shared/services/jwtpb"
+	"px.dev/pixie/src/utils"
+	"px.dev/pixie/src/vizier/services/query_broker/querybrokerpb"
+)
+
+type registeredQuery struct {
+	scriptHash string
+	user       string
+	startTime  time.Time
+	// Accessed atomically.
+	bytesForwarded int64
+
+	cancel   context.CancelFunc
+	agentIDs []uuid.UUID
+}
+
+// QueryRegistry tracks the queries that are currently executing in the query broker.
+type QueryRegistry struct {
+	mu      sync.Mutex
+	queries map[uuid.UUID]*registeredQuery
+}
+
+// NewQueryRegistry creates a new QueryRegistry.
+func NewQueryRegistry() *QueryRegistry {
+	return &QueryRegistry{
+		queries: make(map[uuid.UUID]*registeredQuery),
+	}
+}
+
+// ScriptHash returns the hex encoded SHA256 hash of the query string.
+func ScriptHash(queryStr string) string {
+	h := sha256.Sum256([]byte(queryStr))
+	return hex.EncodeToString(h[:])
+}
+
+// claimsFromContext returns the JWT claims of the caller, or nil if there are none.
+func claimsFromContext(ctx context.Context) *jwtpb.JWTClaims {
+	aCtx, err := authcontext.FromContext(ctx)
+	if err != nil {
+		return nil
+	}
+	return aCtx.Claims
+}
+
+// userFromContext returns a human readable identifier for the user making the request.
+func userFromContext(ctx context.Context) string {
+	claims := claimsFromContext(ctx)
+	if claims == nil {
+		return ""
+	}
+	if uc := claims.GetUserClaims(); uc != nil {
+		if uc.Email != "" {
+			return uc.Email
+		}
+		if uc.UserID != "" {
+			return uc.UserID
+		}
+	}
+	return claims.Subject
+}
+
+// Register adds a query to the registry. The cancel func is called if the query is cancelled.
+func (r *QueryRegistry) Register(queryID uuid.UUID, queryStr string, user string, cancel context.CancelFunc) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.queries[queryID]; ok {
+		return status.Errorf(codes.AlreadyExists, "query %s is already running", queryID.String())
+	}
+	r.queries[queryID] = &registeredQuery{
+		scriptHash: ScriptHash(queryStr),
+		user:       user,
+		startTime:  time.Now(),
+		cancel:     cancel,
+	}
+	return nil
+}
+
+// SetAgents records the agents that a query was launched on.
+func (r *QueryRegistry) SetAgents(queryID uuid.UUID, agentIDs []uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if q, ok := r.queries[queryID]; ok {
+		q.agentIDs = agentIDs
+	}
+}
+
+// AddBytesForwarded records that n more bytes were forwarded to the client for the query.
+func (r *QueryRegistry) AddBytesForwarded(queryID uuid.UUID, n int64) {
+	r.mu.Lock()
+	q, ok := r.queries[queryID]
+	r.mu.Unlock()
+	if ok {
+		atomic.AddInt64(&q.bytesForwarded, n)
+	}
+}
+
+// Unregister removes a query from the registry.
+func (r *QueryRegistry) Unregister(queryID uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.queries, queryID)
+}
+
+// User returns the user that is running the query. Returns false if the query isn't running.
+func (r *QueryRegistry) User(queryID uuid.UUID) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	q, ok := r.queries[queryID]
+	if !ok {
+		return "", false
+	}
+	return q.user, true
+}
+
+// Cancel cancels the query and returns the agents the query was launched on.
+// Returns false if the query isn't running.
+func (r *QueryRegistry) Cancel(queryID uuid.UUID) ([]uuid.UUID, bool) {
+	r.mu.Lock()
+	q, ok := r.queries[queryID]
+	r.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	q.cancel()
+	return q.agentIDs, true
+}
+
+// List returns information about all of the queries in the registry, ordered by start time.
+func (r *QueryRegistry) List() []*querybrokerpb.QueryInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	infos := make([]*querybrokerpb.QueryInfo, 0, len(r.queries))
+	for id, q := range r.queries {
+		startTime, _ := types.TimestampProto(q.startTime)
+		infos = append(infos, &querybrokerpb.QueryInfo{
+			QueryID:        utils.ProtoFromUUID(id),
+			ScriptHash:     q.scriptHash,
+			User:           q.user,
+			StartTime:      startTime,
+			BytesForwarded: atomic.LoadInt64(&q.bytesForwarded),
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].StartTime.Compare(infos[j].StartTime) < 0
+	})
+	return infos
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gofrs/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/utils"
+	"px.dev/pixie/src/vizier/services/query_broker/controllers"
+)
+
+func TestQueryRegistry(t *testing.T) {
+	r := controllers.NewQueryRegistry()
+	queryID := uuid.Must(uuid.FromString(queryIDStr))
+	agentID := uuid.Must(uuid.FromString(agent1ID))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	require.NoError(t, r.Register(queryID, "px.display(df)", "user@pixielabs.ai", cancel))
+
+	// Registering the same ID twice should fail.
+	err := r.Register(queryID, "px.display(df)", "user@pixielabs.ai", cancel)
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	r.SetAgents(queryID, []uuid.UUID{agentID})
+	r.AddBytesForwarded(queryID, 10)
+	r.AddBytesForwarded(queryID, 5)
+
+	queries := r.List()
+	require.Len(t, queries, 1)
+	assert.Equal(t, queryID, utils.UUIDFromProtoOrNil(queries[0].QueryID))
+	assert.Equal(t, controllers.ScriptHash("px.display(df)"), queries[0].ScriptHash)
+	assert.Equal(t, "user@pixielabs.ai", queries[0].User)
+	assert.Equal(t, int64(15), queries[0].BytesForwarded)
+	assert.NotNil(t, queries[0].StartTime)
+
+	user, ok := r.User(queryID)
+	require.True(t, ok)
+	assert.Equal(t, "user@pixielabs.ai", user)
+
+	agentIDs, ok := r.Cancel(queryID)
+	require.True(t, ok)
+	assert.Equal(t, []uuid.UUID{agentID}, agentIDs)
+	assert.Error(t, ctx.Err())
+
+	r.Unregister(queryID)
+	assert.Empty(t, r.List())
+	_, ok = r.Cancel(queryID)
+	assert.False(t, ok)
+	_, ok = r.User(queryID)
+	assert.False(t, ok)
+}
@@ -118,9 +118,22 @@ type activeQuery struct {
 
 	gotFinalExecStats bool
 	agentExecStats    *[]*queryresultspb.AgentExecutionStats
+
+	// The agents the query runs on, used to report the agents whose results are missing.
+	agents *QueryAgents
+	// Set if the query accepts partial results.
+	partial *PartialResultsOpts
+	// The agents whose data was cut off before it reached the result tables.
+	failedAgents map[uuid.UUID]bool
+
+	// When the query was registered, used to measure how long agents take to respond.
+	registeredAt time.Time
 }
 
-func newActiveQuery(tableIDMap map[string]string) *activeQuery {
+func newActiveQuery(tableIDMap map[string]string, agents *QueryAgents, partial *PartialResultsOpts) *activeQuery {
+	if agents == nil {
+		agents = &QueryAgents{}
+	}
 	aq := &activeQuery{
 		cancelClientStreamCh: make(chan struct{}),
 
@@ -133,6 +146,11 @@ func newActiveQuery(tableIDMap map[string]string) *activeQuery {
 		allTablesConnectedCh: make(chan struct{}),
 
 		gotFinalExecStats: false,
+
+		agents:       agents,
+		partial:      partial,
+		failedAgents: make(map[uuid.UUID]bool),
+		registeredAt: time.Now(),
 	}
 
 	for tableName := range tableIDMap {
@@ -160,6 +178,15 @@ func (a *activeQuery) updateQueryState(msg *carnotpb.TransferResultChunkRequest)
 			return fmt.Errorf("already received exec stats for query %s", queryIDStr)
 		}
 		a.gotFinalExecStats = true
+		incomplete := a.agents.incompleteAgents(execStats.AgentExecutionStats)
+		for _, agentID := range incomplete {
+			a.failedAgents[agentID] = true
+		}
+		// Without a partial results deadline, agents only stop receiving from an agent early when
+		// its stream breaks.
+		if a.partial == nil || a.partial.Deadline == 0 {
+			a.agents.markDisconnected(incomplete)
+		}
 		return nil
 	}
 
@@ -181,6 +208,7 @@ func (a *activeQuery) updateQueryState(msg *carnotpb.TransferResultChunkRequest)
 				return fmt.Errorf("received multiple EOS for table name '%s' for query ID %s", tableName, queryIDStr)
 			}
 			a.remainingTableEos.remove(tableName)
+			agentResponseSeconds.Observe(time.Since(a.registeredAt).Seconds())
 			return nil
 		}
 
@@ -206,21 +234,39 @@ func (a *activeQuery) queryComplete() bool {
 	return a.uninitializedTables.size() == 0 && a.remainingTableEos.size() == 0 && a.gotFinalExecStats
 }
 
+// missingAgents returns the agents whose results haven't been received, either because their
+// data was cut off or because they haven't finished yet.
+func (a *activeQuery) missingAgents() []*vizierpb.MissingAgent {
+	agentIDs := make(map[uuid.UUID]bool)
+	for agentID := range a.failedAgents {
+		agentIDs[agentID] = true
+	}
+	for _, agentID := range a.agents.pendingAgents(a.remainingTableEos) {
+		agentIDs[agentID] = true
+	}
+	return a.agents.missingAgents(agentIDs)
+}
+
 // QueryResultForwarder is responsible for receiving query results from the agent streams and forwarding
 // that data to the client stream.
 type QueryResultForwarder interface {
-	RegisterQuery(queryID uuid.UUID, tableIDMap map[string]string) error
+	// agents and partial are optional. If partial is set, the query's results are returned once
+	// the partial results deadline passes, as long as enough of its agents reported.
+	RegisterQuery(queryID uuid.UUID, tableIDMap map[string]string, agents *QueryAgents, partial *PartialResultsOpts) error
 	// To be used if a query needs to be deleted before StreamResults is invoked.
 	// Otherwise, StreamResults will delete the query for the caller.
 	DeleteQuery(queryID uuid.UUID)
 
 	// Streams results from the agent stream to the client stream.
 	// Blocks until the stream (& the agent stream) has completed, been cancelled, or experienced an error.
-	// Returns error for any error received.
+	// Returns error for any error received. If the query exceeds its budget, the client is sent a
+	// truncation status and a *BudgetExceededError is returned. If the query accepts partial
+	// results and stops waiting for slow agents, a *PartialResultsError is returned.
 	StreamResults(ctx context.Context, queryID uuid.UUID,
 		resultCh chan *vizierpb.ExecuteScriptResponse,
 		compilationTimeNs int64,
-		queryPlanOpts *QueryPlanOpts) error
+		queryPlanOpts *QueryPlanOpts,
+		budget QueryBudget) error
 
 	// Pass a message received from the agent stream to the client-side stream.
 	ForwardQueryResult(msg *carnotpb.TransferResultChunkRequest) error
@@ -251,14 +297,16 @@ func NewQueryResultForwarderWithTimeout(timeout time.Duration) QueryResultForwar
 }
 
 // RegisterQuery registers a query ID in the result forwarder.
-func (f *QueryResultForwarderImpl) RegisterQuery(queryID uuid.UUID, tableIDMap map[string]string) error {
+func (f *QueryResultForwarderImpl) RegisterQuery(queryID uuid.UUID, tableIDMap map[string]string,
+	agents *QueryAgents, partial *PartialResultsOpts) error {
 	f.activeQueriesMutex.Lock()
 	defer f.activeQueriesMutex.Unlock()
 
 	if _, present := f.activeQueries[queryID]; present {
 		return fmt.Errorf("Query %d already registered", queryID)
 	}
-	f.activeQueries[queryID] = newActiveQuery(tableIDMap)
+	f.activeQueries[queryID] = newActiveQuery(tableIDMap, agents, partial)
+	forwarderActiveQueries.Inc()
 	return nil
 }
 
@@ -266,7 +314,16 @@ func (f *QueryResultForwarderImpl) RegisterQuery(queryID uuid.UUID, tableIDMap m
 func (f *QueryResultForwarderImpl) DeleteQuery(queryID uuid.UUID) {
 	f.activeQueriesMutex.Lock()
 	defer f.activeQueriesMutex.Unlock()
+	f.deleteQueryLocked(queryID)
+}
+
+// deleteQueryLocked deletes a query ID, the caller must hold activeQueriesMutex.
+func (f *QueryResultForwarderImpl) deleteQueryLocked(queryID uuid.UUID) {
+	if _, present := f.activeQueries[queryID]; !present {
+		return
+	}
 	delete(f.activeQueries, queryID)
+	forwarderActiveQueries.Dec()
 }
 
 // The max size of the query plan string, including a buffer for the rest of the message.
@@ -276,7 +333,8 @@ const maxQueryPlanStringSize = 1024*1024 - maxQueryPlanBufferSize
 // StreamResults streams results from the agent streams to the client stream.
 func (f *QueryResultForwarderImpl) StreamResults(ctx context.Context, queryID uuid.UUID,
 	resultCh chan *vizierpb.ExecuteScriptResponse,
-	compilationTimeNs int64, queryPlanOpts *QueryPlanOpts) error {
+	compilationTimeNs int64, queryPlanOpts *QueryPlanOpts, budget QueryBudget) error {
+	start := time.Now()
 	f.activeQueriesMutex.Lock()
 	activeQuery, present := f.activeQueries[queryID]
 	f.activeQueriesMutex.Unlock()
@@ -287,7 +345,7 @@ func (f *QueryResultForwarderImpl) StreamResults(ctx context.Context, queryID uu
 
 	defer func() {
 		f.activeQueriesMutex.Lock()
-		delete(f.activeQueries, queryID)
+		f.deleteQueryLocked(queryID)
 		f.activeQueriesMutex.Unlock()
 	}()
 
@@ -298,6 +356,48 @@ func (f *QueryResultForwarderImpl) StreamResults(ctx context.Context, queryID uu
 		return err
 	}
 
+	// Stops the query, sending the client its partial results followed by the truncation status.
+	truncate := func(reason string) error {
+		log.WithField("query_id", queryID).WithField("budget", reason).Info("Query exceeded its budget, truncating results")
+		resultCh <- TruncatedResponse(queryID, reason, activeQuery.missingAgents(), compilationTimeNs,
+			time.Since(start).Nanoseconds())
+		return cancelStreamReturnErr(&BudgetExceededError{QueryID: queryID.String(), Reason: reason})
+	}
+
+	// Stops waiting for slow agents, sending the client the agents whose results are missing if
+	// enough agents reported.
+	stopWaiting := func() error {
+		missing := activeQuery.missingAgents()
+		missingIDs := make([]string, len(missing))
+		for i, m := range missing {
+			missingIDs[i] = m.AgentID
+		}
+		if !activeQuery.agents.hasQuorum(len(missing), activeQuery.partial.Quorum) {
+			return cancelStreamReturnErr(fmt.Errorf("Query %s exceeded its partial results deadline without a quorum of agents, missing: %s",
+				queryID.String(), strings.Join(missingIDs, ", ")))
+		}
+		log.WithField("query_id", queryID).WithField("missing_agents", missingIDs).Info("Query exceeded its partial results deadline, returning partial results")
+		resultCh <- TruncatedResponse(queryID, "", missing, compilationTimeNs, time.Since(start).Nanoseconds())
+		return cancelStreamReturnErr(&PartialResultsError{QueryID: queryID.String(), MissingAgents: missingIDs})
+	}
+
+	var timeoutCh <-chan time.Time
+	if budget.Timeout > 0 {
+		timer := time.NewTimer(budget.Timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+	var bytesForwarded int64
+
+	// Agents stop waiting for their upstream agents at the deadline, so give them time to send
+	// what they have before giving up on them.
+	var partialDeadlineCh <-chan time.Time
+	if activeQuery.partial != nil && activeQuery.partial.Deadline > 0 {
+		timer := time.NewTimer(activeQuery.partial.Deadline + partialResultsGracePeriod)
+		defer timer.Stop()
+		partialDeadlineCh = timer.C
+	}
+
 	// Waits for `resultSinkInitializationTimeout` time for all of the result sinks (tables)
 	// for this query to initialize a connection to the query broker.
 	go func() {
@@ -329,6 +429,12 @@ func (f *QueryResultForwarderImpl) StreamResults(ctx context.Context, queryID uu
 		case <-activeQuery.cancelClientStreamCh:
 			return activeQuery.cancelClientStreamError
 
+		case <-timeoutCh:
+			return truncate(TruncatedByTimeout)
+
+		case <-partialDeadlineCh:
+			return stopWaiting()
+
 		case msg := <-activeQuery.queryResultCh:
 			// Stream the agent stream result to the client stream.
 			// Check if stream is complete. If so, close client stream.
@@ -364,9 +470,29 @@ func (f *QueryResultForwarderImpl) StreamResults(ctx context.Context, queryID uu
 				return cancelStreamReturnErr(err)
 			}
 
+			if budget.MaxBytesForwarded > 0 && resp.GetData().GetBatch() != nil {
+				size := int64(resp.Size())
+				if bytesForwarded+size > budget.MaxBytesForwarded {
+					return truncate(TruncatedByMaxBytesForwarded)
+				}
+				bytesForwarded += size
+			}
+
+			if stats := resp.GetData().GetExecutionStats(); stats != nil {
+				stats.MissingAgents = activeQuery.missingAgents()
+				if activeQuery.partial != nil && !activeQuery.agents.hasQuorum(len(stats.MissingAgents), activeQuery.partial.Quorum) {
+					return cancelStreamReturnErr(fmt.Errorf("Query %s failed, %d agents did not report which is below the partial results quorum",
+						queryID.String(), len(stats.MissingAgents)))
+				}
+			}
+
 			// Some inbound messages don't translate into responses to the client stream.
 			if resp != nil {
 				resultCh <- resp
+				if batch := resp.GetData().GetBatch(); batch != nil {
+					forwardedRowsTotal.Add(float64(batch.NumRows))
+					forwardedBytesTotal.Add(float64(resp.Size()))
+				}
 			}
 
 			if activeQuery.queryComplete() {
@@ -389,6 +515,8 @@ func (f *QueryResultForwarderImpl) ForwardQueryResult(msg *carnotpb.TransferResu
 		return fmt.Errorf("error in ForwardQueryResult: Query %s is not registered in query forwarder", queryID.String())
 	}
 
+	forwarderPendingChunks.Inc()
+	defer forwarderPendingChunks.Dec()
 	select {
 	case activeQuery.queryResultCh <- msg:
 		return nil
@@ -30,6 +30,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"px.dev/pixie/src/api/proto/uuidpb"
 	"px.dev/pixie/src/api/proto/vizierpb"
 	"px.dev/pixie/src/carnot/carnotpb"
 	"px.dev/pixie/src/carnot/planner/distributedpb"
@@ -165,10 +166,10 @@ func TestStreamResultsSimple(t *testing.T) {
 	ctx := context.Background()
 	var err error
 
-	assert.Nil(t, f.RegisterQuery(queryID, expectedTables))
+	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, nil, nil))
 
 	go func() {
-		err = f.StreamResults(ctx, queryID, resultCh, 350, nil)
+		err = f.StreamResults(ctx, queryID, resultCh, 350, nil, controllers.QueryBudget{})
 		close(doneCh)
 	}()
 
@@ -226,10 +227,10 @@ func TestStreamResultsAgentCancel(t *testing.T) {
 	ctx := context.Background()
 	var err error
 
-	assert.Nil(t, f.RegisterQuery(queryID, expectedTables))
+	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, nil, nil))
 
 	go func() {
-		err = f.StreamResults(ctx, queryID, resultCh, 350, nil)
+		err = f.StreamResults(ctx, queryID, resultCh, 350, nil, controllers.QueryBudget{})
 
 		// Forwarding after stream is done should fail.
 		_, in1 := makeRowBatchResult(t, queryID, "bar", "456" /*eos*/, true)
@@ -287,10 +288,10 @@ func TestStreamResultsClientContextCancel(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	var err error
 
-	assert.Nil(t, f.RegisterQuery(queryID, expectedTables))
+	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, nil, nil))
 
 	go func() {
-		err = f.StreamResults(ctx, queryID, resultCh, 350, nil)
+		err = f.StreamResults(ctx, queryID, resultCh, 350, nil, controllers.QueryBudget{})
 		close(doneCh)
 	}()
 
@@ -344,7 +345,7 @@ func TestStreamResultsQueryPlan(t *testing.T) {
 	ctx := context.Background()
 	var err error
 
-	assert.Nil(t, f.RegisterQuery(queryID, expectedTables))
+	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, nil, nil))
 
 	plan, planMap := makePlan(t)
 
@@ -355,7 +356,7 @@ func TestStreamResultsQueryPlan(t *testing.T) {
 	}
 
 	go func() {
-		err = f.StreamResults(ctx, queryID, resultCh, 350, queryPlanOpts)
+		err = f.StreamResults(ctx, queryID, resultCh, 350, queryPlanOpts, controllers.QueryBudget{})
 		close(doneCh)
 	}()
 
@@ -428,10 +429,10 @@ func TestStreamResultsWrongQueryID(t *testing.T) {
 	ctx := context.Background()
 	var err error
 
-	assert.Nil(t, f.RegisterQuery(queryID, expectedTables))
+	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, nil, nil))
 
 	go func() {
-		err = f.StreamResults(ctx, queryID, resultCh, 350, nil)
+		err = f.StreamResults(ctx, queryID, resultCh, 350, nil, controllers.QueryBudget{})
 		close(doneCh)
 	}()
 
@@ -480,10 +481,10 @@ func TestStreamResultsResultsBeforeInitialization(t *testing.T) {
 	ctx := context.Background()
 	var err error
 
-	assert.Nil(t, f.RegisterQuery(queryID, expectedTables))
+	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, nil, nil))
 
 	go func() {
-		err = f.StreamResults(ctx, queryID, resultCh, 350, nil)
+		err = f.StreamResults(ctx, queryID, resultCh, 350, nil, controllers.QueryBudget{})
 		close(doneCh)
 	}()
 
@@ -526,10 +527,10 @@ func TestStreamResultsNeverInitializedTable(t *testing.T) {
 	ctx := context.Background()
 	var err error
 
-	assert.Nil(t, f.RegisterQuery(queryID, expectedTables))
+	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, nil, nil))
 
 	go func() {
-		err = f.StreamResults(ctx, queryID, resultCh, 350, nil)
+		err = f.StreamResults(ctx, queryID, resultCh, 350, nil, controllers.QueryBudget{})
 		close(doneCh)
 	}()
 
@@ -544,3 +545,332 @@ func TestStreamResultsNeverInitializedTable(t *testing.T) {
 	assert.Equal(t, queryID.String(), results[0].QueryID)
 	assert.Equal(t, expected0, results[0].GetData().Batch)
 }
+
+func TestStreamResultsTimeoutBudget(t *testing.T) {
+	queryID := uuid.Must(uuid.NewV4())
+
+	f := controllers.NewQueryResultForwarderWithTimeout(1 * time.Second)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	expectedTables := make(map[string]string)
+	expectedTables["foo"] = "123"
+
+	var results []*vizierpb.ExecuteScriptResponse
+	resultCh := make(chan *vizierpb.ExecuteScriptResponse)
+	doneCh := make(chan bool)
+
+	go func() {
+		for {
+			select {
+			case msg := <-resultCh:
+				results = append(results, msg)
+			case <-doneCh:
+				wg.Done()
+				return
+			}
+		}
+	}()
+	ctx := context.Background()
+	var err error
+
+	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, nil, nil))
+
+	go func() {
+		err = f.StreamResults(ctx, queryID, resultCh, 350, nil, controllers.QueryBudget{
+			Timeout: 100 * time.Millisecond,
+		})
+		close(doneCh)
+	}()
+
+	expected0, in0 := makeRowBatchResult(t, queryID, "foo", "123" /*eos*/, false)
+	assert.Nil(t, f.ForwardQueryResult(makeInitiateTableRequest(queryID, "foo")))
+	assert.Nil(t, f.ForwardQueryResult(in0))
+	// The query never finishes, so the timeout truncates it.
+	wg.Wait()
+
+	var budgetErr *controllers.BudgetExceededError
+	require.ErrorAs(t, err, &budgetErr)
+	assert.Equal(t, controllers.TruncatedByTimeout, budgetErr.Reason)
+
+	require.Equal(t, 2, len(results))
+	assert.Equal(t, expected0, results[0].GetData().Batch)
+	stats := results[1].GetData().ExecutionStats
+	require.NotNil(t, stats)
+	assert.Equal(t, controllers.TruncatedByTimeout, stats.TruncationReason)
+	assert.Equal(t, int64(350), stats.Timing.CompilationTimeNs)
+
+	// The agents can't forward any more results.
+	_, in1 := makeRowBatchResult(t, queryID, "foo", "123" /*eos*/, true)
+	assert.NotNil(t, f.ForwardQueryResult(in1))
+}
+
+func TestStreamResultsMaxBytesBudget(t *testing.T) {
+	queryID := uuid.Must(uuid.NewV4())
+
+	f := controllers.NewQueryResultForwarderWithTimeout(1 * time.Second)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	expectedTables := make(map[string]string)
+	expectedTables["foo"] = "123"
+
+	var results []*vizierpb.ExecuteScriptResponse
+	resultCh := make(chan *vizierpb.ExecuteScriptResponse)
+	doneCh := make(chan bool)
+
+	go func() {
+		for {
+			select {
+			case msg := <-resultCh:
+				results = append(results, msg)
+			case <-doneCh:
+				wg.Done()
+				return
+			}
+		}
+	}()
+	ctx := context.Background()
+	var err error
+
+	expected0, in0 := makeRowBatchResult(t, queryID, "foo", "123" /*eos*/, false)
+	_, in1 := makeRowBatchResult(t, queryID, "foo", "123" /*eos*/, true)
+	batchSize := (&vizierpb.ExecuteScriptResponse{
+		QueryID: queryID.String(),
+		Result: &vizierpb.ExecuteScriptResponse_Data{
+			Data: &vizierpb.QueryData{Batch: expected0},
+		},
+	}).Size()
+
+	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, nil, nil))
+
+	go func() {
+		// Only the first batch fits in the budget.
+		err = f.StreamResults(ctx, queryID, resultCh, 350, nil, controllers.QueryBudget{
+			MaxBytesForwarded: int64(batchSize) + 1,
+		})
+		close(doneCh)
+	}()
+
+	assert.Nil(t, f.ForwardQueryResult(makeInitiateTableRequest(queryID, "foo")))
+	assert.Nil(t, f.ForwardQueryResult(in0))
+	assert.Nil(t, f.ForwardQueryResult(in1))
+	wg.Wait()
+
+	var budgetErr *controllers.BudgetExceededError
+	require.ErrorAs(t, err, &budgetErr)
+	assert.Equal(t, controllers.TruncatedByMaxBytesForwarded, budgetErr.Reason)
+
+	require.Equal(t, 2, len(results))
+	assert.Equal(t, expected0, results[0].GetData().Batch)
+	assert.Equal(t, controllers.TruncatedByMaxBytesForwarded, results[1].GetData().ExecutionStats.TruncationReason)
+}
+
+func TestStreamResultsScanTruncated(t *testing.T) {
+	queryID := uuid.Must(uuid.NewV4())
+
+	f := controllers.NewQueryResultForwarderWithTimeout(1 * time.Second)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	expectedTables := make(map[string]string)
+	expectedTables["foo"] = "123"
+
+	var results []*vizierpb.ExecuteScriptResponse
+	resultCh := make(chan *vizierpb.ExecuteScriptResponse)
+	doneCh := make(chan bool)
+
+	go func() {
+		for {
+			select {
+			case msg := <-resultCh:
+				results = append(results, msg)
+			case <-doneCh:
+				wg.Done()
+				return
+			}
+		}
+	}()
+	ctx := context.Background()
+	var err error
+
+	assert.Nil(t, f.RegisterQuery(queryID, expectedTables, nil, nil))
+
+	go func() {
+		err = f.StreamResults(ctx, queryID, resultCh, 350, nil, controllers.QueryBudget{
+			MaxRowsScannedPerAgent: 10,
+		})
+		close(doneCh)
+	}()
+
+	_, in0 := makeRowBatchResult(t, queryID, "foo", "123" /*eos*/, true)
+	_, in1 := makeExecStatsResult(t, queryID)
+	in1.GetExecutionAndTimingInfo().AgentExecutionStats = []*queryresultspb.AgentExecutionStats{
+		{ScanTruncated: true},
+	}
+
+	assert.Nil(t, f.ForwardQueryResult(makeInitiateTableRequest(queryID, "foo")))
+	assert.Nil(t, f.ForwardQueryResult(in0))
+	assert.Nil(t, f.ForwardQueryResult(in1))
+	wg.Wait()
+
+	// The agents stop scanning themselves, so the query completes normally.
+	require.NoError(t, err)
+	require.Equal(t, 2, len(results))
+	assert.Equal(t, controllers.TruncatedByMaxRowsScannedPerAgent, results[1].GetData().ExecutionStats.TruncationReason)
+}
+
+// makePartialResultsPlanMap returns the plans of a query where two PEMs send their data to a
+// Kelvin, which produces the result table "foo".
+func makePartialResultsPlanMap() (uuid.UUID, uuid.UUID, uuid.UUID, map[uuid.UUID]*planpb.Plan) {
+	kelvinID := uuid.Must(uuid.NewV4())
+	pem1ID := uuid.Must(uuid.NewV4())
+	pem2ID := uuid.Must(uuid.NewV4())
+
+	sourceNode := func(id uint64) *planpb.PlanNode {
+		return &planpb.PlanNode{
+			Id: id,
+			Op: &planpb.Operator{
+				OpType: planpb.GRPC_SOURCE_OPERATOR,
+				Op:     &planpb.Operator_GRPCSourceOp{GRPCSourceOp: &planpb.GRPCSourceOperator{}},
+			},
+		}
+	}
+	sinkNode := func(sink *planpb.GRPCSinkOperator) *planpb.PlanNode {
+		return &planpb.PlanNode{
+			Id: 10,
+			Op: &planpb.Operator{
+				OpType: planpb.GRPC_SINK_OPERATOR,
+				Op:     &planpb.Operator_GRPCSinkOp{GRPCSinkOp: sink},
+			},
+		}
+	}
+	pemPlan := func(sourceID uint64) *planpb.Plan {
+		return &planpb.Plan{
+			Nodes: []*planpb.PlanFragment{{
+				Nodes: []*planpb.PlanNode{sinkNode(&planpb.GRPCSinkOperator{
+					Destination: &planpb.GRPCSinkOperator_GRPCSourceID{GRPCSourceID: sourceID},
+				})},
+			}},
+		}
+	}
+
+	planMap := map[uuid.UUID]*planpb.Plan{
+		kelvinID: {
+			Nodes: []*planpb.PlanFragment{{
+				Nodes: []*planpb.PlanNode{
+					sourceNode(1),
+					sourceNode(2),
+					sinkNode(&planpb.GRPCSinkOperator{
+						Destination: &planpb.GRPCSinkOperator_OutputTable{
+							OutputTable: &planpb.GRPCSinkOperator_ResultTable{TableName: "foo"},
+						},
+					}),
+				},
+			}},
+			IncomingAgentIDs: []*uuidpb.UUID{utils.ProtoFromUUID(pem1ID), utils.ProtoFromUUID(pem2ID)},
+		},
+		pem1ID: pemPlan(1),
+		pem2ID: pemPlan(2),
+	}
+	return kelvinID, pem1ID, pem2ID, planMap
+}
+
+func TestStreamResultsMissingAgents(t *testing.T) {
+	tests := []struct {
+		name    string
+		partial *controllers.PartialResultsOpts
+		wantErr bool
+		// Whether the missing agent is known to have disconnected, rather than missed the deadline.
+		wantDisconnected bool
+	}{
+		{
+			name:             "partial results disabled",
+			wantDisconnected: true,
+		},
+		{
+			name:             "quorum met",
+			partial:          &controllers.PartialResultsOpts{Quorum: 0.5},
+			wantDisconnected: true,
+		},
+		{
+			name:    "quorum met with a deadline",
+			partial: &controllers.PartialResultsOpts{Quorum: 0.5, Deadline: time.Hour},
+		},
+		{
+			name:    "quorum not met",
+			partial: &controllers.PartialResultsOpts{Quorum: 0.9},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			queryID := uuid.Must(uuid.NewV4())
+			kelvinID, pem1ID, _, planMap := makePartialResultsPlanMap()
+			expectedTables := map[string]string{"foo": "123"}
+
+			f := controllers.NewQueryResultForwarder()
+			resultCh := make(chan *vizierpb.ExecuteScriptResponse)
+			var results []*vizierpb.ExecuteScriptResponse
+			doneCh := make(chan bool)
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case msg := <-resultCh:
+						results = append(results, msg)
+					case <-doneCh:
+						return
+					}
+				}
+			}()
+
+			agents := controllers.NewQueryAgents(planMap)
+			require.NoError(t, f.RegisterQuery(queryID, expectedTables, agents, test.partial))
+
+			var err error
+			go func() {
+				err = f.StreamResults(context.Background(), queryID, resultCh, 350, nil, controllers.QueryBudget{})
+				close(doneCh)
+			}()
+
+			_, in0 := makeRowBatchResult(t, queryID, "foo", "123" /*eos*/, true)
+			_, in1 := makeExecStatsResult(t, queryID)
+			// The Kelvin gave up on the data from the first PEM.
+			in1.GetExecutionAndTimingInfo().AgentExecutionStats = []*queryresultspb.AgentExecutionStats{
+				{
+					AgentID:                 utils.ProtoFromUUID(kelvinID),
+					IncompleteGRPCSourceIDs: []int64{1},
+				},
+			}
+
+			assert.Nil(t, f.ForwardQueryResult(makeInitiateTableRequest(queryID, "foo")))
+			assert.Nil(t, f.ForwardQueryResult(in0))
+			if test.wantErr {
+				// The stream may be cancelled before the exec stats are forwarded.
+				_ = f.ForwardQueryResult(in1)
+			} else {
+				assert.Nil(t, f.ForwardQueryResult(in1))
+			}
+			wg.Wait()
+
+			if test.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, 2, len(results))
+			assert.Equal(t, []*vizierpb.MissingAgent{
+				{AgentID: pem1ID.String(), Tables: []string{"foo"}},
+			}, results[1].GetData().ExecutionStats.MissingAgents)
+			if test.wantDisconnected {
+				assert.Equal(t, []string{pem1ID.String()}, agents.DisconnectedAgents())
+			} else {
+				assert.Empty(t, agents.DisconnectedAgents())
+			}
+		})
+	}
+}
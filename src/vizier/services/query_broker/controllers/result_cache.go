@@ -0,0 +1,314 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/carnot/planner/plannerpb"
+	"px.dev/pixie/src/carnot/planpb"
+)
+
+// Results larger than this are still shared with queries that joined while they were running,
+// but are not kept around for later queries.
+const maxCachedResultBytes = 32 * 1024 * 1024
+
+// defaultMaxSharedBufferBytes bounds the responses buffered for the subscribers of a running query.
+const defaultMaxSharedBufferBytes = 64 * 1024 * 1024
+
+var resultCacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "query_broker_result_cache_requests_total",
+	Help: "The number of result cache lookups, by result (hit, coalesced or miss).",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(resultCacheRequests)
+}
+
+// ResultCache shares the results of identical queries. A query that arrives while an identical
+// query is running subscribes to the running query's results instead of being executed, and
+// successful results are kept for a short TTL.
+//
+// Queries are identical if they have the same script, arguments and plan options, and arrive
+// in the same time bucket. The bucket is as wide as the TTL, so that scripts with relative time
+// ranges don't share results that are too stale.
+type ResultCache struct {
+	mu             sync.Mutex
+	ttl            time.Duration
+	maxBufferBytes int
+	entries        map[string]*SharedResult
+}
+
+// NewResultCache creates a result cache which keeps results for at most ttl.
+func NewResultCache(ttl time.Duration) *ResultCache {
+	return &ResultCache{
+		ttl:            ttl,
+		maxBufferBytes: defaultMaxSharedBufferBytes,
+		entries:        make(map[string]*SharedResult),
+	}
+}
+
+// SetMaxBufferBytes bounds the size of the responses buffered for the subscribers of a running
+// query. Once a result outgrows it, no new queries can join, responses are only kept until
+// every subscriber has received them, and subscribers that fall too far behind are dropped.
+func (c *ResultCache) SetMaxBufferBytes(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.maxBufferBytes = n
+}
+
+// Key returns the cache key for a query executed at the given time. Only queries with the same
+// access restrictions share results.
+func (c *ResultCache) Key(req *plannerpb.QueryRequest, planOpts *planpb.PlanOptions, restrictions string, now time.Time) (string, error) {
+	planKey, err := PlanCacheKey(req, planOpts)
+	if err != nil {
+		return "", err
+	}
+	bucket := now.Truncate(c.ttl).UnixNano()
+	h := sha256.Sum256([]byte(fmt.Sprintf("%s/%s/%d", planKey, restrictions, bucket)))
+	return hex.EncodeToString(h[:]), nil
+}
+
+// Join returns the shared result for the key. If leader is true, no identical query is running or
+// cached, and the caller must execute the query, publishing its results to the shared result.
+// Otherwise the caller should subscribe to the shared result.
+func (c *ResultCache) Join(key string) (r *SharedResult, leader bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for k, e := range c.entries {
+		if e.expired(now) {
+			delete(c.entries, k)
+		}
+	}
+
+	if r, ok := c.entries[key]; ok {
+		if r.isDone() {
+			resultCacheRequests.WithLabelValues("hit").Inc()
+		} else {
+			resultCacheRequests.WithLabelValues("coalesced").Inc()
+		}
+		return r, false
+	}
+
+	resultCacheRequests.WithLabelValues("miss").Inc()
+	r = &SharedResult{
+		cache:          c,
+		key:            key,
+		maxBufferBytes: c.maxBufferBytes,
+		updated:        make(chan struct{}),
+		subscribers:    make(map[*subscriber]bool),
+	}
+	c.entries[key] = r
+	return r, true
+}
+
+func (c *ResultCache) remove(key string, r *SharedResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries[key] == r {
+		delete(c.entries, key)
+	}
+}
+
+// subscriber is the progress of a query subscribed to a shared result.
+type subscriber struct {
+	// The number of responses sent to the subscriber.
+	sent int
+	// Set if the subscriber was dropped for falling behind.
+	err error
+}
+
+// SharedResult is the response stream of a query, shared with identical queries.
+type SharedResult struct {
+	cache          *ResultCache
+	key            string
+	maxBufferBytes int
+
+	mu sync.Mutex
+	// Closed and replaced whenever a response is published or the query finishes.
+	updated chan struct{}
+	// The buffered responses. Responses before offset have been dropped from the buffer.
+	responses []*vizierpb.ExecuteScriptResponse
+	offset    int
+	// The size of the buffered responses.
+	size int
+	// Set once the result outgrew the buffer. It can't be joined or cached afterwards.
+	overflowed  bool
+	subscribers map[*subscriber]bool
+	done        bool
+	err         error
+	expiresAt   time.Time
+}
+
+func (r *SharedResult) notifyLocked() {
+	close(r.updated)
+	r.updated = make(chan struct{})
+}
+
+func (r *SharedResult) isDone() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.done
+}
+
+func (r *SharedResult) expired(now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.done && now.After(r.expiresAt)
+}
+
+// Publish adds a response from the executing query.
+func (r *SharedResult) Publish(resp *vizierpb.ExecuteScriptResponse) {
+	r.mu.Lock()
+	r.responses = append(r.responses, resp)
+	r.size += resp.Size()
+	overflowed := !r.overflowed && r.size > r.maxBufferBytes
+	if r.size > r.maxBufferBytes {
+		r.overflowed = true
+		r.trimLocked()
+	}
+	r.notifyLocked()
+	r.mu.Unlock()
+
+	if overflowed {
+		// Queries joining now would miss the dropped responses.
+		r.cache.remove(r.key, r)
+	}
+}
+
+// trimLocked drops the responses that every subscriber has received. While the buffer is
+// still too large, the subscriber furthest behind is dropped.
+func (r *SharedResult) trimLocked() {
+	for {
+		end := r.offset + len(r.responses)
+		minSent := end
+		var slowest *subscriber
+		for sub := range r.subscribers {
+			if sub.sent < minSent {
+				minSent, slowest = sub.sent, sub
+			}
+		}
+		for r.offset < minSent {
+			r.size -= r.responses[0].Size()
+			r.responses[0] = nil
+			r.responses = r.responses[1:]
+			r.offset++
+		}
+		if r.size <= r.maxBufferBytes || slowest == nil {
+			return
+		}
+		slowest.err = status.Error(codes.ResourceExhausted, "fell too far behind the shared query results")
+		delete(r.subscribers, slowest)
+	}
+}
+
+// Finish marks the executing query as done. Subscribers receive err once they have received
+// all responses. The result is kept for later queries only if it is cacheable.
+func (r *SharedResult) Finish(err error, cacheable bool) {
+	r.mu.Lock()
+	r.done = true
+	r.err = err
+	r.expiresAt = time.Now().Add(r.cache.ttl)
+	cacheable = cacheable && err == nil && !r.overflowed && r.size <= maxCachedResultBytes
+	r.notifyLocked()
+	r.mu.Unlock()
+
+	if !cacheable {
+		r.cache.remove(r.key, r)
+	}
+}
+
+// Subscribe sends the responses of the shared query to send, rewritten for the subscribing
+// query ID, until the shared query finishes or ctx is cancelled. Subscribers which receive
+// responses too slowly to keep the buffer bounded fail with ResourceExhausted.
+func (r *SharedResult) Subscribe(ctx context.Context, queryID string, send func(*vizierpb.ExecuteScriptResponse) error) error {
+	sub := &subscriber{}
+	r.mu.Lock()
+	if r.offset > 0 {
+		r.mu.Unlock()
+		return status.Errorf(codes.ResourceExhausted, "the results of the shared query are too large to share with query %s", queryID)
+	}
+	r.subscribers[sub] = true
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.subscribers, sub)
+	}()
+
+	for {
+		r.mu.Lock()
+		if sub.err != nil {
+			r.mu.Unlock()
+			return sub.err
+		}
+		// Copied, since the buffer can be trimmed while the responses are sent.
+		pending := append([]*vizierpb.ExecuteScriptResponse(nil), r.responses[sub.sent-r.offset:]...)
+		done, err, updated := r.done, r.err, r.updated
+		r.mu.Unlock()
+
+		for _, resp := range pending {
+			if err := send(sharedResponse(resp, queryID)); err != nil {
+				return err
+			}
+		}
+		if len(pending) > 0 {
+			r.mu.Lock()
+			sub.sent += len(pending)
+			r.mu.Unlock()
+			continue
+		}
+		if done {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return status.Errorf(codes.Canceled, "query %s was cancelled", queryID)
+		case <-updated:
+		}
+	}
+}
+
+// sharedResponse copies a response of the shared query for another query, flagging its
+// execution stats as a cache hit. The original response is shared, so it is not modified.
+func sharedResponse(resp *vizierpb.ExecuteScriptResponse, queryID string) *vizierpb.ExecuteScriptResponse {
+	copied := *resp
+	copied.QueryID = queryID
+	if stats := resp.GetData().GetExecutionStats(); stats != nil {
+		data := *resp.GetData()
+		copiedStats := *stats
+		copiedStats.CacheHit = true
+		data.ExecutionStats = &copiedStats
+		copied.Result = &vizierpb.ExecuteScriptResponse_Data{Data: &data}
+	}
+	return &copied
+}
@@ -0,0 +1,202 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/carnot/planner/plannerpb"
+	"px.dev/pixie/src/carnot/planpb"
+	"px.dev/pixie/src/vizier/services/query_broker/controllers"
+)
+
+func execStatsResponse(queryID string) *vizierpb.ExecuteScriptResponse {
+	return &vizierpb.ExecuteScriptResponse{
+		QueryID: queryID,
+		Result: &vizierpb.ExecuteScriptResponse_Data{
+			Data: &vizierpb.QueryData{
+				ExecutionStats: &vizierpb.QueryExecutionStats{RecordsProcessed: 5},
+			},
+		},
+	}
+}
+
+func collect(t *testing.T, r *controllers.SharedResult, queryID string) ([]*vizierpb.ExecuteScriptResponse, error) {
+	var resps []*vizierpb.ExecuteScriptResponse
+	err := r.Subscribe(context.Background(), queryID, func(resp *vizierpb.ExecuteScriptResponse) error {
+		resps = append(resps, resp)
+		return nil
+	})
+	return resps, err
+}
+
+func TestResultCache_Key(t *testing.T) {
+	c := controllers.NewResultCache(10 * time.Second)
+	req := &plannerpb.QueryRequest{QueryStr: "px.display(df)"}
+	now := time.Unix(1000, 0)
+
+	key, err := c.Key(req, &planpb.PlanOptions{}, "", now)
+	require.NoError(t, err)
+
+	// Queries in the same time bucket are identical.
+	sameBucket, err := c.Key(req, &planpb.PlanOptions{}, "", now.Add(9*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, key, sameBucket)
+
+	nextBucket, err := c.Key(req, &planpb.PlanOptions{}, "", now.Add(10*time.Second))
+	require.NoError(t, err)
+	assert.NotEqual(t, key, nextBucket)
+
+	otherQuery, err := c.Key(&plannerpb.QueryRequest{QueryStr: "px.display(df2)"}, &planpb.PlanOptions{}, "", now)
+	require.NoError(t, err)
+	assert.NotEqual(t, key, otherQuery)
+
+	// Callers with different access restrictions don't share results.
+	restricted, err := c.Key(req, &planpb.PlanOptions{}, "contractors", now)
+	require.NoError(t, err)
+	assert.NotEqual(t, key, restricted)
+}
+
+func TestResultCache_Coalesce(t *testing.T) {
+	c := controllers.NewResultCache(time.Minute)
+
+	leaderResult, leader := c.Join("key")
+	require.True(t, leader)
+	followerResult, leader := c.Join("key")
+	require.False(t, leader)
+
+	type subscription struct {
+		resps []*vizierpb.ExecuteScriptResponse
+		err   error
+	}
+	subCh := make(chan subscription)
+	go func() {
+		resps, err := collect(t, followerResult, "follower")
+		subCh <- subscription{resps, err}
+	}()
+
+	metadata := &vizierpb.ExecuteScriptResponse{QueryID: "leader"}
+	leaderResult.Publish(metadata)
+	leaderResult.Publish(execStatsResponse("leader"))
+	leaderResult.Finish(nil, true)
+
+	sub := <-subCh
+	require.NoError(t, sub.err)
+	require.Len(t, sub.resps, 2)
+	assert.Equal(t, "follower", sub.resps[0].QueryID)
+	assert.Equal(t, "follower", sub.resps[1].QueryID)
+	assert.True(t, sub.resps[1].GetData().GetExecutionStats().CacheHit)
+	assert.Equal(t, int64(5), sub.resps[1].GetData().GetExecutionStats().RecordsProcessed)
+	// The leader's responses are not modified.
+	assert.Equal(t, "leader", metadata.QueryID)
+
+	// The completed result is cached.
+	cached, leader := c.Join("key")
+	require.False(t, leader)
+	resps, err := collect(t, cached, "later")
+	require.NoError(t, err)
+	assert.Len(t, resps, 2)
+}
+
+func TestResultCache_FailedQueryNotCached(t *testing.T) {
+	c := controllers.NewResultCache(time.Minute)
+
+	r, leader := c.Join("key")
+	require.True(t, leader)
+	r.Publish(&vizierpb.ExecuteScriptResponse{QueryID: "leader"})
+	r.Finish(status.Error(codes.Internal, "agent failed"), true)
+
+	// Queries that had already joined receive the error.
+	resps, err := collect(t, r, "follower")
+	assert.Len(t, resps, 1)
+	assert.Equal(t, codes.Internal, status.Code(err))
+
+	_, leader = c.Join("key")
+	assert.True(t, leader)
+}
+
+func TestResultCache_Expiry(t *testing.T) {
+	c := controllers.NewResultCache(time.Millisecond)
+
+	r, leader := c.Join("key")
+	require.True(t, leader)
+	r.Publish(execStatsResponse("leader"))
+	r.Finish(nil, true)
+
+	time.Sleep(5 * time.Millisecond)
+	_, leader = c.Join("key")
+	assert.True(t, leader)
+}
+
+func TestResultCache_SubscriberCancelled(t *testing.T) {
+	c := controllers.NewResultCache(time.Minute)
+
+	c.Join("key")
+	r, leader := c.Join("key")
+	require.False(t, leader)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := r.Subscribe(ctx, "follower", func(*vizierpb.ExecuteScriptResponse) error { return nil })
+	assert.Equal(t, codes.Canceled, status.Code(err))
+}
+
+func TestResultCache_SlowSubscriberDropped(t *testing.T) {
+	resp := execStatsResponse("leader")
+	c := controllers.NewResultCache(time.Minute)
+	c.SetMaxBufferBytes(2 * resp.Size())
+
+	leaderResult, leader := c.Join("key")
+	require.True(t, leader)
+	followerResult, leader := c.Join("key")
+	require.False(t, leader)
+
+	received := make(chan struct{})
+	release := make(chan struct{})
+	errCh := make(chan error)
+	go func() {
+		errCh <- followerResult.Subscribe(context.Background(), "follower", func(*vizierpb.ExecuteScriptResponse) error {
+			received <- struct{}{}
+			<-release
+			return nil
+		})
+	}()
+
+	leaderResult.Publish(resp)
+	// The follower is stuck sending the first response.
+	<-received
+	leaderResult.Publish(resp)
+	leaderResult.Publish(resp)
+
+	// The result outgrew the buffer, so it can't be joined anymore.
+	_, leader = c.Join("key")
+	assert.True(t, leader)
+
+	close(release)
+	err := <-errCh
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
@@ -20,6 +20,7 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"sync"
@@ -43,7 +44,10 @@ import (
 	"px.dev/pixie/src/utils"
 	funcs "px.dev/pixie/src/vizier/funcs/go"
 	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
+	"px.dev/pixie/src/vizier/services/query_broker/audit"
+	"px.dev/pixie/src/vizier/services/query_broker/policy"
 	"px.dev/pixie/src/vizier/services/query_broker/querybrokerenv"
+	"px.dev/pixie/src/vizier/services/query_broker/querybrokerpb"
 	"px.dev/pixie/src/vizier/services/query_broker/tracker"
 )
 
@@ -80,8 +84,24 @@ type Server struct {
 	mdtp            metadatapb.MetadataTracepointServiceClient
 	mdconf          metadatapb.MetadataConfigServiceClient
 	resultForwarder QueryResultForwarder
+	queryRegistry   *QueryRegistry
+	admission       *AdmissionController
+	// budgetLimits caps the budgets of every query.
+	budgetLimits QueryBudget
 
 	planner Planner
+	// planCache is nil when plan caching is disabled.
+	planCache *PlanCache
+	// resultCache is nil when result sharing is disabled.
+	resultCache *ResultCache
+	// auditSink is nil when audit logging is disabled.
+	auditSink audit.Sink
+	// policies is nil when no access policies are enforced.
+	policies *policy.Store
+	// The users, besides Vizier services, that may call the admin RPCs.
+	admins policy.Subjects
+	// agentFailures is nil when agent failures aren't reported.
+	agentFailures AgentFailureReporter
 }
 
 // NewServer creates GRPC handlers.
@@ -113,6 +133,8 @@ func NewServerWithForwarderAndPlanner(env querybrokerenv.QueryBrokerEnv,
 		env:             env,
 		agentsTracker:   agentsTracker,
 		resultForwarder: resultForwarder,
+		queryRegistry:   NewQueryRegistry(),
+		admission:       NewAdmissionController(AdmissionLimits{}),
 		natsConn:        natsConn,
 		mdtp:            mds,
 		mdconf:          mdconf,
@@ -121,16 +143,98 @@ func NewServerWithForwarderAndPlanner(env querybrokerenv.QueryBrokerEnv,
 	return s, nil
 }
 
+// SetAdmissionLimits updates the limits on the number of queries that can run at once.
+func (s *Server) SetAdmissionLimits(limits AdmissionLimits) {
+	s.admission.SetLimits(limits)
+}
+
+// SetQueryBudgetLimits sets the largest budgets a query can use. They also apply to queries that don't
+// set their own budget.
+func (s *Server) SetQueryBudgetLimits(limits QueryBudget) {
+	s.budgetLimits = limits
+}
+
+// SetPlanCache sets the cache used to reuse compiled plans across queries. A nil cache disables plan caching.
+func (s *Server) SetPlanCache(c *PlanCache) {
+	s.planCache = c
+}
+
+// SetResultCache sets the cache used to share results between identical queries. A nil cache disables result sharing.
+func (s *Server) SetResultCache(c *ResultCache) {
+	s.resultCache = c
+}
+
+// SetAuditSink sets the sink that records every executed script. A nil sink disables audit logging.
+func (s *Server) SetAuditSink(sink audit.Sink) {
+	s.auditSink = sink
+}
+
+// SetPolicyStore sets the access policies enforced on scripts. A nil store disables access policies.
+func (s *Server) SetPolicyStore(store *policy.Store) {
+	s.policies = store
+}
+
+// SetAdmins sets the users that may call the admin RPCs, such as changing the admission limits.
+// Vizier services can always call them.
+func (s *Server) SetAdmins(admins policy.Subjects) {
+	s.admins = admins
+}
+
+// authorizeAdmin returns an error unless the caller is a Vizier service or an admin.
+func (s *Server) authorizeAdmin(ctx context.Context) error {
+	claims := claimsFromContext(ctx)
+	if claims.GetServiceClaims() != nil || s.admins.Match(claims) {
+		return nil
+	}
+	return status.Error(codes.PermissionDenied, "admin access is required")
+}
+
+// SetAgentFailureReporter sets where the agents that fail to return results for queries are reported.
+// A nil reporter disables reporting.
+func (s *Server) SetAgentFailureReporter(r AgentFailureReporter) {
+	s.agentFailures = r
+}
+
 // Close frees the planner memory in the server.
 func (s *Server) Close() {
 	s.planner.Free()
 }
 
+// plan compiles the query, reusing a cached plan when one was compiled for the same query against the
+// same distributed state.
+func (s *Server) plan(plannerState *distributedpb.LogicalPlannerState, req *plannerpb.QueryRequest) (*distributedpb.LogicalPlannerResult, error) {
+	if s.planCache == nil {
+		return s.planner.Plan(plannerState, req)
+	}
+	dsHash, err := HashDistributedState(plannerState.DistributedState)
+	if err != nil {
+		return nil, err
+	}
+	key, err := PlanCacheKey(req, plannerState.PlanOptions)
+	if err != nil {
+		return nil, err
+	}
+	if result, ok := s.planCache.Get(dsHash, key); ok {
+		return result, nil
+	}
+
+	result, err := s.planner.Plan(plannerState, req)
+	if err != nil {
+		return nil, err
+	}
+	// Compilation errors are cheap to reproduce, so only successful plans are cached.
+	if result.GetStatus().GetErrCode() == statuspb.OK {
+		s.planCache.Put(dsHash, key, result)
+	}
+	return result, nil
+}
+
 // runQuery executes a query and streams the results to the client.
 // returns a bool for whether the query timed out and an error.
 func (s *Server) runQuery(ctx context.Context, req *plannerpb.QueryRequest, queryID uuid.UUID,
 	planOpts *planpb.PlanOptions, distributedState *distributedpb.DistributedState,
-	resultStream chan *vizierpb.ExecuteScriptResponse, doneCh chan bool) error {
+	restrictions *policy.Effective, partial *PartialResultsOpts,
+	resultStream chan *vizierpb.ExecuteScriptResponse, doneCh chan bool) (err error) {
 	log.WithField("query_id", queryID).Infof("Running script")
 	start := time.Now()
 	defer func(t time.Time) {
@@ -138,6 +242,13 @@ func (s *Server) runQuery(ctx context.Context, req *plannerpb.QueryRequest, quer
 		log.WithField("query_id", queryID).WithField("duration", duration).Info("Executed query")
 	}(start)
 
+	activeQueries.Inc()
+	outcome := queryStatusOK
+	defer func() {
+		activeQueries.Dec()
+		recordQueryResult(outcome, err)
+	}()
+
 	defer func() {
 		close(doneCh)
 	}()
@@ -154,25 +265,48 @@ func (s *Server) runQuery(ctx context.Context, req *plannerpb.QueryRequest, quer
 		ResultAddress:       s.env.Address(),
 		ResultSSLTargetName: s.env.SSLTargetName(),
 	}
+	if !restrictions.Empty() {
+		// Denied tables are hidden from the planner, so they can't be read by any part of the plan.
+		restrictedState := *distributedState
+		restrictedState.SchemaInfo = restrictions.FilterSchemas(distributedState.SchemaInfo)
+		plannerState.DistributedState = &restrictedState
+	}
 
 	// Compile the query plan.
-	plannerResultPB, err := s.planner.Plan(plannerState, req)
+	plannerResultPB, err := s.plan(plannerState, req)
 
 	if err != nil {
 		// send the compilation error and return nil.
 		return err
 	}
 
-	compilationTimeNs := time.Since(start).Nanoseconds()
+	compilationTime := time.Since(start)
+	compilationTimeNs := compilationTime.Nanoseconds()
+	queryCompileSeconds.Observe(compilationTime.Seconds())
 
 	// When the status is not OK, this means it's a compilation error on the query passed in.
 	if plannerResultPB.Status.ErrCode != statuspb.OK {
+		outcome = queryStatusCompilationError
 		resultStream <- StatusToVizierResponse(queryID, plannerResultPB.Status)
 		return nil
 	}
 
 	// Plan describes the mapping of agents to the plan that should execute on them.
 	plan := plannerResultPB.Plan
+	if !restrictions.Empty() {
+		// The plan may be cached, so restrict a copy of it.
+		plan = proto.Clone(plan).(*distributedpb.DistributedPlan)
+		policyStatus, err := restrictions.Apply(plan, distributedState.SchemaInfo)
+		if err != nil {
+			return err
+		}
+		// Policy violations are reported like compilation errors.
+		if policyStatus != nil {
+			outcome = queryStatusPolicyDenied
+			resultStream <- StatusToVizierResponse(queryID, policyStatus)
+			return nil
+		}
+	}
 	planMap := make(map[uuid.UUID]*planpb.Plan)
 
 	for carnotID, agentPlan := range plan.QbAddressToPlan {
@@ -184,6 +318,11 @@ func (s *Server) runQuery(ctx context.Context, req *plannerpb.QueryRequest, quer
 		planMap[u] = agentPlan
 	}
 
+	// The query may have been cancelled while it was being compiled.
+	if ctx.Err() != nil {
+		return status.Errorf(codes.Canceled, "query %s was cancelled", queryID.String())
+	}
+
 	queryPlanTableID, err := uuid.NewV4()
 	if err != nil {
 		return err
@@ -215,7 +354,8 @@ func (s *Server) runQuery(ctx context.Context, req *plannerpb.QueryRequest, quer
 		resultStream <- resp
 	}
 
-	err = s.resultForwarder.RegisterQuery(queryID, tableNameToIDMap)
+	queryAgents := NewQueryAgents(planMap)
+	err = s.resultForwarder.RegisterQuery(queryID, tableNameToIDMap, queryAgents, partial)
 	if err != nil {
 		return err
 	}
@@ -224,6 +364,11 @@ func (s *Server) runQuery(ctx context.Context, req *plannerpb.QueryRequest, quer
 		s.resultForwarder.DeleteQuery(queryID)
 		return err
 	}
+	agentIDs := make([]uuid.UUID, 0, len(planMap))
+	for agentID := range planMap {
+		agentIDs = append(agentIDs, agentID)
+	}
+	s.queryRegistry.SetAgents(queryID, agentIDs)
 
 	// Send over the query plan responses, if applicable.
 	var queryPlanOpts *QueryPlanOpts
@@ -236,8 +381,28 @@ func (s *Server) runQuery(ctx context.Context, req *plannerpb.QueryRequest, quer
 		}
 	}
 
-	return s.resultForwarder.StreamResults(ctx, queryID, resultStream,
-		compilationTimeNs, queryPlanOpts)
+	execStart := time.Now()
+	err = s.resultForwarder.StreamResults(ctx, queryID, resultStream,
+		compilationTimeNs, queryPlanOpts, QueryBudgetFromPlanOptions(planOpts))
+	queryExecutionSeconds.Observe(time.Since(execStart).Seconds())
+	// Agents that were only slow, and were cut off by the query's timeout or partial results
+	// deadline, aren't at fault.
+	s.reportAgentFailures(queryID, attributableFailures(queryAgents.DisconnectedAgents(), len(planMap)),
+		"disconnected before sending all of its results")
+	var budgetErr *BudgetExceededError
+	var partialErr *PartialResultsError
+	if errors.As(err, &budgetErr) || errors.As(err, &partialErr) {
+		outcome = queryStatusPartial
+		if budgetErr != nil {
+			outcome = queryStatusTruncated
+		}
+		// The client has its partial results, stop the query on the agents.
+		if err := CancelQuery(queryID, s.natsConn, agentIDs); err != nil {
+			log.WithError(err).WithField("query_id", queryID).Error("Failed to cancel query on agents")
+		}
+		return nil
+	}
+	return err
 }
 
 func loadUDFInfo(udfInfoPb *udfspb.UDFInfo) error {
@@ -298,7 +463,7 @@ func (s *Server) CheckHealth(ctx context.Context) error {
 	}()
 
 	distributedState := s.agentsTracker.GetAgentInfo().DistributedState()
-	err = s.runQuery(ctx, req, queryID, planOpts, &distributedState, resultStream, doneCh)
+	err = s.runQuery(ctx, req, queryID, planOpts, &distributedState, nil, nil, resultStream, doneCh)
 	if err != nil {
 		return fmt.Errorf("error running healthcheck query ID %s: %v", queryID.String(), err)
 	}
@@ -368,30 +533,112 @@ func (s *Server) HealthCheck(req *vizierpb.HealthCheckRequest, srv vizierpb.Vizi
 }
 
 // ExecuteScript executes the script and sends results through the gRPC stream.
-func (s *Server) ExecuteScript(req *vizierpb.ExecuteScriptRequest, srv vizierpb.VizierService_ExecuteScriptServer) error {
+func (s *Server) ExecuteScript(req *vizierpb.ExecuteScriptRequest, srv vizierpb.VizierService_ExecuteScriptServer) (err error) {
 	ctx := context.WithValue(srv.Context(), execStartKey, time.Now())
-	// TODO(philkuz,PP-2262) we should move the query id into the api so we can track queries from the client that orignated them.
-	queryID, err := uuid.NewV4()
-	if err != nil {
-		return srv.Send(ErrToVizierResponse(queryID, err))
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var queryID uuid.UUID
+	var record *audit.Record
+	if s.auditSink != nil {
+		record = newAuditRecord(ctx, req)
+		stream := &auditedStream{VizierService_ExecuteScriptServer: srv}
+		srv = stream
+		defer func() {
+			if queryID != uuid.Nil {
+				record.QueryID = queryID.String()
+			}
+			finishAuditRecord(record, stream, err)
+			if err := s.auditSink.Write(record); err != nil {
+				log.WithError(err).WithField("query_id", record.QueryID).Error("Failed to write audit record")
+			}
+		}()
+	}
+
+	if req.QueryID != "" {
+		queryID, err = uuid.FromString(req.QueryID)
+		if err != nil || queryID == uuid.Nil {
+			return status.Errorf(codes.InvalidArgument, "invalid query ID '%s'", req.QueryID)
+		}
+	} else {
+		queryID, err = uuid.NewV4()
+		if err != nil {
+			return srv.Send(ErrToVizierResponse(queryID, err))
+		}
 	}
 
+	user := userFromContext(ctx)
+	if err := s.queryRegistry.Register(queryID, req.QueryStr, user, cancel); err != nil {
+		return err
+	}
+	defer s.queryRegistry.Unregister(queryID)
+
 	flags, err := ParseQueryFlags(req.QueryStr)
 	if err != nil {
 		return srv.Send(ErrToVizierResponse(queryID, err))
 	}
 
 	planOpts := flags.GetPlanOptions()
+	s.budgetLimits.Limit(planOpts)
+
+	// Convert request to a format expected by the planner.
+	convertedReq, err := VizierQueryRequestToPlannerQueryRequest(req)
+	if err != nil {
+		return err
+	}
+
+	var restrictions *policy.Effective
+	if s.policies != nil {
+		restrictions = s.policies.Resolve(claimsFromContext(ctx))
+		if record != nil {
+			record.Policies = restrictions.Policies
+		}
+	}
+
+	// Identical queries share results. Mutations have side effects, so they always run.
+	var shared *SharedResult
+	if s.resultCache != nil && !req.Mutation {
+		key, err := s.resultCache.Key(convertedReq, planOpts, restrictions.Key(), time.Now())
+		if err != nil {
+			return err
+		}
+		var leader bool
+		shared, leader = s.resultCache.Join(key)
+		if !leader {
+			log.WithField("query_id", queryID).Info("Sharing results of an identical query")
+			return shared.Subscribe(ctx, queryID.String(), func(resp *vizierpb.ExecuteScriptResponse) error {
+				if err := srv.Send(resp); err != nil {
+					return err
+				}
+				s.queryRegistry.AddBytesForwarded(queryID, int64(resp.Size()))
+				return nil
+			})
+		}
+	}
+
+	release, err := s.admission.Admit(ctx, user)
+	if err != nil {
+		log.WithField("query_id", queryID).WithField("user", user).WithError(err).Info("Query not admitted")
+		if shared != nil {
+			shared.Finish(err, false)
+		}
+		return err
+	}
+	defer release()
 
 	distributedState := s.agentsTracker.GetAgentInfo().DistributedState()
 
 	if req.Mutation {
 		mutationExec := NewMutationExecutor(s.planner, s.mdtp, s.mdconf, &distributedState)
+		mutationExec.SetRestrictions(restrictions)
 
 		status, err := mutationExec.Execute(ctx, req, planOpts)
 		if err != nil {
 			return srv.Send(ErrToVizierResponse(queryID, err))
 		}
+		if record != nil {
+			record.Mutations = auditMutations(mutationExec.Mutations())
+		}
 		if status != nil {
 			return srv.Send(StatusToVizierResponse(queryID, status))
 		}
@@ -409,12 +656,6 @@ func (s *Server) ExecuteScript(req *vizierpb.ExecuteScriptRequest, srv vizierpb.
 		}
 	}
 
-	// Convert request to a format expected by the planner.
-	convertedReq, err := VizierQueryRequestToPlannerQueryRequest(req)
-	if err != nil {
-		return err
-	}
-
 	resultStream := make(chan *vizierpb.ExecuteScriptResponse)
 	doneCh := make(chan bool)
 
@@ -422,6 +663,8 @@ func (s *Server) ExecuteScript(req *vizierpb.ExecuteScriptRequest, srv vizierpb.
 	wg.Add(1)
 
 	var sendErr error
+	// Whether the final execution stats were sent, which means the query completed.
+	gotExecStats := false
 	go func() {
 		var err error
 		for {
@@ -430,18 +673,40 @@ func (s *Server) ExecuteScript(req *vizierpb.ExecuteScriptRequest, srv vizierpb.
 				wg.Done()
 				return
 			case result := <-resultStream:
+				if result.GetData().GetExecutionStats() != nil {
+					gotExecStats = true
+				}
+				if shared != nil {
+					shared.Publish(result)
+				}
 				err = srv.Send(result)
 				if err != nil {
 					sendErr = err
+					continue
 				}
+				s.queryRegistry.AddBytesForwarded(queryID, int64(result.Size()))
 			}
 		}
 	}()
 
 	log.Infof("Launching query: %s", queryID)
-	err = s.runQuery(ctx, convertedReq, queryID, planOpts, &distributedState, resultStream, doneCh)
+	err = s.runQuery(ctx, convertedReq, queryID, planOpts, &distributedState, restrictions, flags.GetPartialResultsOpts(), resultStream, doneCh)
 	wg.Wait()
 
+	if shared != nil {
+		switch {
+		case err != nil:
+			shared.Finish(err, false)
+		case gotExecStats:
+			shared.Finish(nil, true)
+		case ctx.Err() != nil:
+			shared.Finish(status.Error(codes.Aborted, "the identical query sharing its results was cancelled"), false)
+		default:
+			// The query failed to compile, subscribers have received the same compilation error.
+			shared.Finish(nil, false)
+		}
+	}
+
 	if err != nil {
 		return err
 	}
@@ -451,6 +716,92 @@ func (s *Server) ExecuteScript(req *vizierpb.ExecuteScriptRequest, srv vizierpb.
 	return nil
 }
 
+// GetQueries lists the queries that are currently running.
+func (s *Server) GetQueries(ctx context.Context, req *querybrokerpb.GetQueriesRequest) (*querybrokerpb.GetQueriesResponse, error) {
+	queries := s.queryRegistry.List()
+	if s.authorizeAdmin(ctx) != nil {
+		// Users who aren't admins can only see their own queries.
+		user := userFromContext(ctx)
+		owned := make([]*querybrokerpb.QueryInfo, 0, len(queries))
+		for _, q := range queries {
+			if user != "" && q.User == user {
+				owned = append(owned, q)
+			}
+		}
+		queries = owned
+	}
+	return &querybrokerpb.GetQueriesResponse{
+		Queries: queries,
+	}, nil
+}
+
+// CancelQuery cancels a running query, closing its client stream and stopping its execution on the agents.
+func (s *Server) CancelQuery(ctx context.Context, req *querybrokerpb.CancelQueryRequest) (*querybrokerpb.CancelQueryResponse, error) {
+	queryID, err := utils.UUIDFromProto(req.QueryID)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "invalid query ID")
+	}
+	user := userFromContext(ctx)
+	owner, ok := s.queryRegistry.User(queryID)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "query %s is not running", queryID.String())
+	}
+	if user == "" || owner != user {
+		// Only the user running the query or an admin can cancel it.
+		if err := s.authorizeAdmin(ctx); err != nil {
+			return nil, status.Error(codes.PermissionDenied, "only the owner of the query or an admin can cancel it")
+		}
+	}
+	log.WithField("query_id", queryID).WithField("user", user).Info("Cancelling query")
+
+	// Cancel the client stream with an error before cancelling the context, so that the client
+	// is told why the stream was closed.
+	s.resultForwarder.OptionallyCancelClientStream(queryID,
+		status.Errorf(codes.Canceled, "query %s was cancelled", queryID.String()))
+	agentIDs, ok := s.queryRegistry.Cancel(queryID)
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "query %s is not running", queryID.String())
+	}
+
+	if len(agentIDs) > 0 {
+		if err := CancelQuery(queryID, s.natsConn, agentIDs); err != nil {
+			log.WithError(err).WithField("query_id", queryID).Error("Failed to send cancel request to agents")
+			return nil, status.Error(codes.Internal, "failed to notify agents of cancellation")
+		}
+	}
+	return &querybrokerpb.CancelQueryResponse{}, nil
+}
+
+// GetAdmissionStatus returns the admission control limits and the current number of running and queued queries.
+func (s *Server) GetAdmissionStatus(ctx context.Context, req *querybrokerpb.GetAdmissionStatusRequest) (*querybrokerpb.GetAdmissionStatusResponse, error) {
+	if err := s.authorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+	running, queued := s.admission.Stats()
+	return &querybrokerpb.GetAdmissionStatusResponse{
+		Limits:         AdmissionLimitsToProto(s.admission.Limits()),
+		RunningQueries: int32(running),
+		QueuedQueries:  int32(queued),
+	}, nil
+}
+
+// UpdateAdmissionLimits updates the admission control limits.
+func (s *Server) UpdateAdmissionLimits(ctx context.Context, req *querybrokerpb.UpdateAdmissionLimitsRequest) (*querybrokerpb.UpdateAdmissionLimitsResponse, error) {
+	if err := s.authorizeAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if req.Limits == nil {
+		return nil, status.Error(codes.InvalidArgument, "limits must be specified")
+	}
+	limits, err := AdmissionLimitsFromProto(req.Limits)
+	if err != nil {
+		return nil, err
+	}
+	log.WithField("user", userFromContext(ctx)).WithField("limits", limits).Info("Updating admission limits")
+	s.admission.SetLimits(limits)
+	return &querybrokerpb.UpdateAdmissionLimitsResponse{}, nil
+}
+
 // TransferResultChunk implements the API that allows the query broker receive streamed results
 // from Carnot instances.
 func (s *Server) TransferResultChunk(srv carnotpb.ResultSinkService_TransferResultChunkServer) error {
@@ -481,10 +832,16 @@ func (s *Server) TransferResultChunk(srv carnotpb.ResultSinkService_TransferResu
 		return err
 	}
 
+	// Fails the stream, counting the failure by its error code.
+	sendAndFail := func(code codes.Code, message string) error {
+		agentStreamFailuresTotal.WithLabelValues(code.String()).Inc()
+		return sendAndClose( /*success*/ false, message)
+	}
+
 	handleAgentStreamClosed := func() error {
 		if tableName != "" && !sentEos {
 			// Send an error and cancel the query if the stream is closed unexpectedly.
-			return sendAndClose( /*success*/ false, fmt.Sprintf(
+			return sendAndFail(codes.Unavailable, fmt.Sprintf(
 				"Agent stream was unexpectedly closed for table %s of query %s before the results completed",
 				tableName, queryID.String(),
 			))
@@ -505,24 +862,25 @@ func (s *Server) TransferResultChunk(srv carnotpb.ResultSinkService_TransferResu
 			if err != nil {
 				if s, ok := status.FromError(err); ok {
 					if s.Code() == codes.Unavailable {
-						return sendAndClose( /*success*/ false,
+						return sendAndFail(codes.Unavailable,
 							fmt.Sprintf("Agent stream disconnected for query %s", queryID.String()))
 					}
 				}
 
-				return sendAndClose( /*success*/ false, err.Error())
+				return sendAndFail(status.Code(err), err.Error())
 			}
+			agentResultChunksTotal.Inc()
 
 			qid, err := utils.UUIDFromProto(msg.QueryID)
 			if err != nil {
-				return sendAndClose( /*success*/ false, err.Error())
+				return sendAndFail(codes.InvalidArgument, err.Error())
 			}
 
 			if queryID == uuid.Nil {
 				queryID = qid
 			}
 			if queryID != qid {
-				return sendAndClose( /*success*/ false, fmt.Sprintf(
+				return sendAndFail(codes.InvalidArgument, fmt.Sprintf(
 					"Received results from multiple queries in the same TransferResultChunk stream: %s and %s",
 					queryID, qid,
 				))
@@ -534,7 +892,7 @@ func (s *Server) TransferResultChunk(srv carnotpb.ResultSinkService_TransferResu
 			// that the latest result chunk was not forwarded.
 			if err != nil {
 				log.WithError(err).Infof("Could not forward result message for query %s", queryID)
-				return sendAndClose( /*success*/ false, err.Error())
+				return sendAndFail(codes.Canceled, err.Error())
 			}
 
 			// Keep track of which table this stream is sending results for, and if it has sent EOS yet.
@@ -543,7 +901,7 @@ func (s *Server) TransferResultChunk(srv carnotpb.ResultSinkService_TransferResu
 					tableName = queryResult.GetTableName()
 				}
 				if tableName != queryResult.GetTableName() {
-					return sendAndClose( /*success*/ false, fmt.Sprintf(
+					return sendAndFail(codes.InvalidArgument, fmt.Sprintf(
 						"Received results from multiple tables for query %s in the same TransferResultChunk stream"+
 							": %s and %s", queryID.String(), tableName, queryResult.GetTableName(),
 					))
@@ -23,26 +23,36 @@ import (
 	"fmt"
 	"io"
 	"testing"
+	"time"
 
 	"github.com/gofrs/uuid"
 	"github.com/gogo/protobuf/proto"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"px.dev/pixie/src/api/proto/vizierpb"
 	mock_vizierpb "px.dev/pixie/src/api/proto/vizierpb/mock"
 	"px.dev/pixie/src/carnot/carnotpb"
 	mock_carnotpb "px.dev/pixie/src/carnot/carnotpb/mock"
 	"px.dev/pixie/src/carnot/planner/distributedpb"
+	"px.dev/pixie/src/carnot/planner/plannerpb"
+	"px.dev/pixie/src/carnot/planpb"
 	"px.dev/pixie/src/carnot/queryresultspb"
 	"px.dev/pixie/src/shared/services/authcontext"
+	srvutils "px.dev/pixie/src/shared/services/utils"
 	"px.dev/pixie/src/table_store/schemapb"
 	"px.dev/pixie/src/utils"
 	"px.dev/pixie/src/utils/testingutils"
+	"px.dev/pixie/src/vizier/messages/messagespb"
+	"px.dev/pixie/src/vizier/services/query_broker/audit"
 	"px.dev/pixie/src/vizier/services/query_broker/controllers"
 	mock_controllers "px.dev/pixie/src/vizier/services/query_broker/controllers/mock"
+	"px.dev/pixie/src/vizier/services/query_broker/policy"
 	"px.dev/pixie/src/vizier/services/query_broker/querybrokerenv"
+	"px.dev/pixie/src/vizier/services/query_broker/querybrokerpb"
 	"px.dev/pixie/src/vizier/services/query_broker/tracker"
 )
 
@@ -278,6 +288,8 @@ type fakeResultForwarder struct {
 	QueryDeleted          uuid.UUID
 	QueryStreamed         uuid.UUID
 	StreamedQueryPlanOpts *controllers.QueryPlanOpts
+	StreamedBudget        controllers.QueryBudget
+	RegisteredPartialOpts *controllers.PartialResultsOpts
 
 	// Variables to set/use for TransferResultChunk testing.
 	ClientStreamClosed   bool
@@ -286,9 +298,11 @@ type fakeResultForwarder struct {
 }
 
 // RegisterQuery registers a query.
-func (f *fakeResultForwarder) RegisterQuery(queryID uuid.UUID, tableIDMap map[string]string) error {
+func (f *fakeResultForwarder) RegisterQuery(queryID uuid.UUID, tableIDMap map[string]string,
+	agents *controllers.QueryAgents, partial *controllers.PartialResultsOpts) error {
 	f.QueryRegistered = queryID
 	f.TableIDMap = tableIDMap
+	f.RegisteredPartialOpts = partial
 	return nil
 }
 
@@ -300,8 +314,10 @@ func (f *fakeResultForwarder) DeleteQuery(queryID uuid.UUID) {
 func (f *fakeResultForwarder) StreamResults(ctx context.Context, queryID uuid.UUID,
 	resultCh chan *vizierpb.ExecuteScriptResponse,
 	compilationTimeNs int64,
-	queryPlanOpts *controllers.QueryPlanOpts) error {
+	queryPlanOpts *controllers.QueryPlanOpts,
+	budget controllers.QueryBudget) error {
 	f.StreamedQueryPlanOpts = queryPlanOpts
+	f.StreamedBudget = budget
 	f.QueryStreamed = queryID
 
 	for _, expectedResult := range f.ClientResultsToSend {
@@ -325,6 +341,15 @@ func (f *fakeResultForwarder) OptionallyCancelClientStream(queryID uuid.UUID, er
 	f.ClientStreamClosed = true
 }
 
+type fakeAgentFailureReporter struct {
+	reported chan []string
+}
+
+func (r *fakeAgentFailureReporter) ReportAgentFailures(queryID uuid.UUID, agentIDs []string, reason string) error {
+	r.reported <- agentIDs
+	return nil
+}
+
 func TestCheckHealth_Success(t *testing.T) {
 	// Start NATS.
 	nc, cleanup := testingutils.MustStartTestNATS(t)
@@ -1064,3 +1089,645 @@ func TestTransferResultChunk_ClientStreamCancelled(t *testing.T) {
 	assert.NotNil(t, rf.ClientStreamError)
 	assert.Equal(t, 0, len(rf.ReceivedAgentResults))
 }
+
+func TestExecuteScript_ClientQueryID(t *testing.T) {
+	nc, cleanup := testingutils.MustStartTestNATS(t)
+	defer cleanup()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	plannerStatePB := new(distributedpb.LogicalPlannerState)
+	if err := proto.UnmarshalText(singleAgentDistributedState, plannerStatePB); err != nil {
+		t.Fatal("Cannot Unmarshal protobuf.")
+	}
+	at := fakeAgentsTracker{
+		agentsInfo: tracker.NewTestAgentsInfo(plannerStatePB.DistributedState),
+	}
+
+	env, err := querybrokerenv.New("qb_address", "qb_hostname", "test")
+	require.NoError(t, err)
+
+	plannerResultPB := &distributedpb.LogicalPlannerResult{}
+	if err := proto.UnmarshalText(expectedPlannerResult, plannerResultPB); err != nil {
+		t.Fatal("Cannot Unmarshal protobuf.")
+	}
+	planner := mock_controllers.NewMockPlanner(ctrl)
+	planner.EXPECT().
+		Plan(plannerStatePB, gomock.Any()).
+		Return(plannerResultPB, nil)
+
+	rf := &fakeResultForwarder{}
+	s, err := controllers.NewServerWithForwarderAndPlanner(env, &at, rf, nil, nil, nc, planner)
+	require.NoError(t, err)
+
+	srv := mock_vizierpb.NewMockVizierService_ExecuteScriptServer(ctrl)
+	ctx := authcontext.NewContext(context.Background(), authcontext.New())
+	srv.EXPECT().Context().Return(ctx).AnyTimes()
+	srv.EXPECT().Send(gomock.Any()).Return(nil).AnyTimes()
+
+	queryID := uuid.Must(uuid.NewV4())
+	err = s.ExecuteScript(&vizierpb.ExecuteScriptRequest{
+		QueryStr: testQuery,
+		QueryID:  queryID.String(),
+	}, srv)
+	require.NoError(t, err)
+	assert.Equal(t, queryID, rf.QueryRegistered)
+	assert.Equal(t, queryID, rf.QueryStreamed)
+
+	// The query should no longer be tracked once it has completed.
+	resp, err := s.GetQueries(context.Background(), &querybrokerpb.GetQueriesRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, resp.Queries)
+}
+
+func TestExecuteScript_InvalidQueryID(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	env, err := querybrokerenv.New("qb_address", "qb_hostname", "test")
+	require.NoError(t, err)
+	s, err := controllers.NewServerWithForwarderAndPlanner(env, &fakeAgentsTracker{}, &fakeResultForwarder{}, nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	srv := mock_vizierpb.NewMockVizierService_ExecuteScriptServer(ctrl)
+	srv.EXPECT().Context().Return(context.Background()).AnyTimes()
+
+	err = s.ExecuteScript(&vizierpb.ExecuteScriptRequest{
+		QueryStr: testQuery,
+		QueryID:  "not-a-uuid",
+	}, srv)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestCancelQuery_NotRunning(t *testing.T) {
+	env, err := querybrokerenv.New("qb_address", "qb_hostname", "test")
+	require.NoError(t, err)
+	rf := &fakeResultForwarder{}
+	s, err := controllers.NewServerWithForwarderAndPlanner(env, &fakeAgentsTracker{}, rf, nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	_, err = s.CancelQuery(context.Background(), &querybrokerpb.CancelQueryRequest{
+		QueryID: utils.ProtoFromUUID(uuid.Must(uuid.NewV4())),
+	})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+// blockingResultForwarder streams results until the query is cancelled.
+type blockingResultForwarder struct {
+	fakeResultForwarder
+	streaming chan struct{}
+}
+
+func (f *blockingResultForwarder) StreamResults(ctx context.Context, queryID uuid.UUID,
+	resultCh chan *vizierpb.ExecuteScriptResponse,
+	compilationTimeNs int64,
+	queryPlanOpts *controllers.QueryPlanOpts,
+	budget controllers.QueryBudget) error {
+	close(f.streaming)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestCancelQuery_RequiresOwnerOrAdmin(t *testing.T) {
+	nc, cleanup := testingutils.MustStartTestNATS(t)
+	defer cleanup()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	plannerStatePB := new(distributedpb.LogicalPlannerState)
+	if err := proto.UnmarshalText(singleAgentDistributedState, plannerStatePB); err != nil {
+		t.Fatal("Cannot Unmarshal protobuf.")
+	}
+	at := fakeAgentsTracker{
+		agentsInfo: tracker.NewTestAgentsInfo(plannerStatePB.DistributedState),
+	}
+
+	env, err := querybrokerenv.New("qb_address", "qb_hostname", "test")
+	require.NoError(t, err)
+
+	plannerResultPB := &distributedpb.LogicalPlannerResult{}
+	if err := proto.UnmarshalText(expectedPlannerResult, plannerResultPB); err != nil {
+		t.Fatal("Cannot Unmarshal protobuf.")
+	}
+	planner := mock_controllers.NewMockPlanner(ctrl)
+	planner.EXPECT().
+		Plan(plannerStatePB, gomock.Any()).
+		Return(plannerResultPB, nil)
+
+	rf := &blockingResultForwarder{streaming: make(chan struct{})}
+	s, err := controllers.NewServerWithForwarderAndPlanner(env, &at, rf, nil, nil, nc, planner)
+	require.NoError(t, err)
+	s.SetAdmins(policy.Subjects{Emails: []string{"*@admins.pixielabs.ai"}})
+
+	userCtx := func(email string) context.Context {
+		aCtx := authcontext.New()
+		aCtx.Claims = srvutils.GenerateJWTForUser("user-id", "org-id", email, time.Now().Add(time.Hour), "withpixie.ai")
+		return authcontext.NewContext(context.Background(), aCtx)
+	}
+
+	srv := mock_vizierpb.NewMockVizierService_ExecuteScriptServer(ctrl)
+	srv.EXPECT().Context().Return(userCtx("owner@pixielabs.ai")).AnyTimes()
+	srv.EXPECT().Send(gomock.Any()).Return(nil).AnyTimes()
+
+	queryID := uuid.Must(uuid.NewV4())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = s.ExecuteScript(&vizierpb.ExecuteScriptRequest{
+			QueryStr: testQuery,
+			QueryID:  queryID.String(),
+		}, srv)
+	}()
+
+	select {
+	case <-rf.streaming:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Query never started streaming results")
+	}
+
+	// Only the owner and admins can see the query.
+	resp, err := s.GetQueries(userCtx("other@pixielabs.ai"), &querybrokerpb.GetQueriesRequest{})
+	require.NoError(t, err)
+	assert.Empty(t, resp.Queries)
+	resp, err = s.GetQueries(userCtx("owner@pixielabs.ai"), &querybrokerpb.GetQueriesRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.Queries, 1)
+	assert.Equal(t, queryID, utils.UUIDFromProtoOrNil(resp.Queries[0].QueryID))
+	resp, err = s.GetQueries(userCtx("admin@admins.pixielabs.ai"), &querybrokerpb.GetQueriesRequest{})
+	require.NoError(t, err)
+	assert.Len(t, resp.Queries, 1)
+
+	req := &querybrokerpb.CancelQueryRequest{QueryID: utils.ProtoFromUUID(queryID)}
+	_, err = s.CancelQuery(userCtx("other@pixielabs.ai"), req)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = s.CancelQuery(context.Background(), req)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = s.CancelQuery(userCtx("owner@pixielabs.ai"), req)
+	require.NoError(t, err)
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Cancelled query never finished")
+	}
+}
+
+func TestUpdateAdmissionLimits(t *testing.T) {
+	env, err := querybrokerenv.New("qb_address", "qb_hostname", "test")
+	require.NoError(t, err)
+	s, err := controllers.NewServerWithForwarderAndPlanner(env, &fakeAgentsTracker{}, &fakeResultForwarder{}, nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	aCtx := authcontext.New()
+	aCtx.Claims = srvutils.GenerateJWTForService("cloud_conn", "vizier")
+	ctx := authcontext.NewContext(context.Background(), aCtx)
+
+	limits := &querybrokerpb.AdmissionLimits{
+		MaxConcurrentQueries:        4,
+		MaxConcurrentQueriesPerUser: 2,
+		MaxQueuedQueries:            8,
+	}
+	_, err = s.UpdateAdmissionLimits(ctx, &querybrokerpb.UpdateAdmissionLimitsRequest{
+		Limits: limits,
+	})
+	require.NoError(t, err)
+
+	resp, err := s.GetAdmissionStatus(ctx, &querybrokerpb.GetAdmissionStatusRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, limits, resp.Limits)
+	assert.Equal(t, int32(0), resp.RunningQueries)
+
+	_, err = s.UpdateAdmissionLimits(ctx, &querybrokerpb.UpdateAdmissionLimitsRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestUpdateAdmissionLimits_RequiresAdmin(t *testing.T) {
+	env, err := querybrokerenv.New("qb_address", "qb_hostname", "test")
+	require.NoError(t, err)
+	s, err := controllers.NewServerWithForwarderAndPlanner(env, &fakeAgentsTracker{}, &fakeResultForwarder{}, nil, nil, nil, nil)
+	require.NoError(t, err)
+	s.SetAdmins(policy.Subjects{Emails: []string{"*@admins.pixielabs.ai"}})
+
+	userCtx := func(email string) context.Context {
+		aCtx := authcontext.New()
+		aCtx.Claims = srvutils.GenerateJWTForUser("user-id", "org-id", email, time.Now().Add(time.Hour), "withpixie.ai")
+		return authcontext.NewContext(context.Background(), aCtx)
+	}
+	req := &querybrokerpb.UpdateAdmissionLimitsRequest{
+		Limits: &querybrokerpb.AdmissionLimits{MaxConcurrentQueries: 1},
+	}
+
+	_, err = s.UpdateAdmissionLimits(userCtx("user@pixielabs.ai"), req)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = s.GetAdmissionStatus(userCtx("user@pixielabs.ai"), &querybrokerpb.GetAdmissionStatusRequest{})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = s.UpdateAdmissionLimits(context.Background(), req)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	_, err = s.UpdateAdmissionLimits(userCtx("admin@admins.pixielabs.ai"), req)
+	require.NoError(t, err)
+}
+
+func TestExecuteScript_SharedResults(t *testing.T) {
+	nc, cleanup := testingutils.MustStartTestNATS(t)
+	defer cleanup()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	plannerStatePB := new(distributedpb.LogicalPlannerState)
+	if err := proto.UnmarshalText(singleAgentDistributedState, plannerStatePB); err != nil {
+		t.Fatal("Cannot Unmarshal protobuf.")
+	}
+	at := fakeAgentsTracker{
+		agentsInfo: tracker.NewTestAgentsInfo(plannerStatePB.DistributedState),
+	}
+
+	env, err := querybrokerenv.New("qb_address", "qb_hostname", "test")
+	require.NoError(t, err)
+
+	plannerResultPB := &distributedpb.LogicalPlannerResult{}
+	if err := proto.UnmarshalText(expectedPlannerResult, plannerResultPB); err != nil {
+		t.Fatal("Cannot Unmarshal protobuf.")
+	}
+	// The second query should share the results of the first, so the script is only planned once.
+	planner := mock_controllers.NewMockPlanner(ctrl)
+	planner.EXPECT().
+		Plan(plannerStatePB, gomock.Any()).
+		Return(plannerResultPB, nil).
+		Times(1)
+
+	rf := &fakeResultForwarder{
+		ClientResultsToSend: []*vizierpb.ExecuteScriptResponse{
+			{
+				QueryID: queryIDStr,
+				Result: &vizierpb.ExecuteScriptResponse_Data{
+					Data: &vizierpb.QueryData{
+						ExecutionStats: &vizierpb.QueryExecutionStats{
+							BytesProcessed:   10,
+							RecordsProcessed: 2,
+						},
+					},
+				},
+			},
+		},
+	}
+	s, err := controllers.NewServerWithForwarderAndPlanner(env, &at, rf, nil, nil, nc, planner)
+	require.NoError(t, err)
+	// A TTL this long makes it unlikely that the two queries fall in different time buckets.
+	s.SetResultCache(controllers.NewResultCache(1000 * time.Hour))
+
+	execute := func(queryID uuid.UUID) []*vizierpb.ExecuteScriptResponse {
+		var sent []*vizierpb.ExecuteScriptResponse
+		srv := mock_vizierpb.NewMockVizierService_ExecuteScriptServer(ctrl)
+		ctx := authcontext.NewContext(context.Background(), authcontext.New())
+		srv.EXPECT().Context().Return(ctx).AnyTimes()
+		srv.EXPECT().Send(gomock.Any()).DoAndReturn(func(resp *vizierpb.ExecuteScriptResponse) error {
+			sent = append(sent, resp)
+			return nil
+		}).AnyTimes()
+
+		err := s.ExecuteScript(&vizierpb.ExecuteScriptRequest{
+			QueryStr: testQuery,
+			QueryID:  queryID.String(),
+		}, srv)
+		require.NoError(t, err)
+		require.NotEmpty(t, sent)
+		return sent
+	}
+
+	firstID := uuid.Must(uuid.NewV4())
+	first := execute(firstID)
+	stats := first[len(first)-1].GetData().GetExecutionStats()
+	require.NotNil(t, stats)
+	assert.False(t, stats.CacheHit)
+
+	secondID := uuid.Must(uuid.NewV4())
+	second := execute(secondID)
+	require.Equal(t, len(first), len(second))
+	for _, resp := range second {
+		assert.Equal(t, secondID.String(), resp.QueryID)
+	}
+	stats = second[len(second)-1].GetData().GetExecutionStats()
+	require.NotNil(t, stats)
+	assert.True(t, stats.CacheHit)
+	assert.Equal(t, int64(10), stats.BytesProcessed)
+	// The cached response is unchanged.
+	assert.False(t, first[len(first)-1].GetData().GetExecutionStats().CacheHit)
+}
+
+type fakeAuditSink struct {
+	records []*audit.Record
+}
+
+func (f *fakeAuditSink) Write(r *audit.Record) error {
+	f.records = append(f.records, r)
+	return nil
+}
+
+func (f *fakeAuditSink) Close() error {
+	return nil
+}
+
+func TestExecuteScript_Audit(t *testing.T) {
+	nc, cleanup := testingutils.MustStartTestNATS(t)
+	defer cleanup()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	plannerStatePB := new(distributedpb.LogicalPlannerState)
+	if err := proto.UnmarshalText(singleAgentDistributedState, plannerStatePB); err != nil {
+		t.Fatal("Cannot Unmarshal protobuf.")
+	}
+	at := fakeAgentsTracker{
+		agentsInfo: tracker.NewTestAgentsInfo(plannerStatePB.DistributedState),
+	}
+
+	env, err := querybrokerenv.New("qb_address", "qb_hostname", "test")
+	require.NoError(t, err)
+
+	plannerResultPB := &distributedpb.LogicalPlannerResult{}
+	if err := proto.UnmarshalText(expectedPlannerResult, plannerResultPB); err != nil {
+		t.Fatal("Cannot Unmarshal protobuf.")
+	}
+	planner := mock_controllers.NewMockPlanner(ctrl)
+	planner.EXPECT().
+		Plan(plannerStatePB, gomock.Any()).
+		Return(plannerResultPB, nil)
+
+	rf := &fakeResultForwarder{}
+	s, err := controllers.NewServerWithForwarderAndPlanner(env, &at, rf, nil, nil, nc, planner)
+	require.NoError(t, err)
+	sink := &fakeAuditSink{}
+	s.SetAuditSink(sink)
+
+	aCtx := authcontext.New()
+	aCtx.Claims = srvutils.GenerateJWTForUser("user-id", "org-id", "user@pixielabs.ai", time.Now().Add(time.Hour), "withpixie.ai")
+	ctx := authcontext.NewContext(context.Background(), aCtx)
+
+	srv := mock_vizierpb.NewMockVizierService_ExecuteScriptServer(ctrl)
+	srv.EXPECT().Context().Return(ctx).AnyTimes()
+	srv.EXPECT().Send(gomock.Any()).Return(nil).AnyTimes()
+
+	queryID := uuid.Must(uuid.NewV4())
+	err = s.ExecuteScript(&vizierpb.ExecuteScriptRequest{
+		QueryStr: testQuery,
+		QueryID:  queryID.String(),
+		ExecFuncs: []*vizierpb.ExecuteScriptRequest_FuncToExecute{
+			{
+				FuncName: "http_data",
+				ArgValues: []*vizierpb.ExecuteScriptRequest_FuncToExecute_ArgValue{
+					{Name: "start_time", Value: "-5m"},
+				},
+				OutputTablePrefix: "out",
+			},
+		},
+	}, srv)
+	require.NoError(t, err)
+
+	// Scripts with an invalid query ID are still recorded.
+	err = s.ExecuteScript(&vizierpb.ExecuteScriptRequest{
+		QueryStr: testQuery,
+		QueryID:  "not-a-uuid",
+	}, srv)
+	require.Error(t, err)
+
+	require.Len(t, sink.records, 2)
+	r := sink.records[0]
+	assert.Equal(t, queryID.String(), r.QueryID)
+	assert.Equal(t, "user-id", r.Caller.UserID)
+	assert.Equal(t, "org-id", r.Caller.OrgID)
+	assert.Equal(t, "user@pixielabs.ai", r.Caller.Email)
+	assert.Equal(t, controllers.ScriptHash(testQuery), r.ScriptHash)
+	assert.Equal(t, []audit.FuncCall{
+		{
+			Name:              "http_data",
+			OutputTablePrefix: "out",
+			Args:              map[string]string{"start_time": "-5m"},
+		},
+	}, r.FuncArgs)
+	assert.False(t, r.Mutation)
+	assert.Greater(t, r.BytesReturned, int64(0))
+	assert.Greater(t, r.DurationNs, int64(0))
+	assert.Equal(t, "OK", r.Status)
+
+	r = sink.records[1]
+	assert.Equal(t, "not-a-uuid", r.QueryID)
+	assert.Equal(t, "InvalidArgument", r.Status)
+}
+
+func TestExecuteScript_PolicyDenied(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	plannerStatePB := new(distributedpb.LogicalPlannerState)
+	if err := proto.UnmarshalText(singleAgentDistributedState, plannerStatePB); err != nil {
+		t.Fatal("Cannot Unmarshal protobuf.")
+	}
+	at := fakeAgentsTracker{
+		agentsInfo: tracker.NewTestAgentsInfo(plannerStatePB.DistributedState),
+	}
+
+	env, err := querybrokerenv.New("qb_address", "qb_hostname", "test")
+	require.NoError(t, err)
+
+	plannerResultPB := &distributedpb.LogicalPlannerResult{}
+	if err := proto.UnmarshalText(expectedPlannerResult, plannerResultPB); err != nil {
+		t.Fatal("Cannot Unmarshal protobuf.")
+	}
+	planner := mock_controllers.NewMockPlanner(ctrl)
+	planner.EXPECT().
+		Plan(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(state *distributedpb.LogicalPlannerState, req *plannerpb.QueryRequest) (*distributedpb.LogicalPlannerResult, error) {
+			// Denied tables are hidden from the planner.
+			assert.Empty(t, state.DistributedState.SchemaInfo)
+			return plannerResultPB, nil
+		})
+
+	rf := &fakeResultForwarder{}
+	s, err := controllers.NewServerWithForwarderAndPlanner(env, &at, rf, nil, nil, nil, planner)
+	require.NoError(t, err)
+	s.SetPolicyStore(policy.NewStore([]policy.Policy{
+		{
+			Name:       "no-table1",
+			Subjects:   policy.Subjects{OrgIDs: []string{"org-id"}},
+			DenyTables: []string{"table1", "perf_and_http"},
+		},
+	}))
+
+	aCtx := authcontext.New()
+	aCtx.Claims = srvutils.GenerateJWTForUser("user-id", "org-id", "user@pixielabs.ai", time.Now().Add(time.Hour), "withpixie.ai")
+	ctx := authcontext.NewContext(context.Background(), aCtx)
+
+	var sent []*vizierpb.ExecuteScriptResponse
+	srv := mock_vizierpb.NewMockVizierService_ExecuteScriptServer(ctrl)
+	srv.EXPECT().Context().Return(ctx).AnyTimes()
+	srv.EXPECT().Send(gomock.Any()).DoAndReturn(func(resp *vizierpb.ExecuteScriptResponse) error {
+		sent = append(sent, resp)
+		return nil
+	}).AnyTimes()
+
+	err = s.ExecuteScript(&vizierpb.ExecuteScriptRequest{QueryStr: testQuery}, srv)
+	require.NoError(t, err)
+
+	// The violation is reported like a compilation error and the query isn't launched.
+	require.Len(t, sent, 1)
+	assert.Equal(t, int32(codes.PermissionDenied), sent[0].Status.Code)
+	assert.Contains(t, sent[0].Status.Message, "no-table1")
+	assert.Equal(t, uuid.Nil, rf.QueryRegistered)
+	// The denied tables are still known to the agents tracker.
+	assert.Len(t, plannerStatePB.DistributedState.SchemaInfo, 1)
+	// The cached plan isn't affected by the policy.
+	assert.Equal(t, "table1", plannerResultPB.Plan.QbAddressToPlan[agent1ID].Nodes[0].Nodes[0].Op.GetMemSourceOp().Name)
+}
+
+func TestExecuteScript_Budget(t *testing.T) {
+	nc, cleanup := testingutils.MustStartTestNATS(t)
+	defer cleanup()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	plannerStatePB := new(distributedpb.LogicalPlannerState)
+	if err := proto.UnmarshalText(singleAgentDistributedState, plannerStatePB); err != nil {
+		t.Fatal("Cannot Unmarshal protobuf.")
+	}
+	at := fakeAgentsTracker{
+		agentsInfo: tracker.NewTestAgentsInfo(plannerStatePB.DistributedState),
+	}
+
+	env, err := querybrokerenv.New("qb_address", "qb_hostname", "test")
+	require.NoError(t, err)
+
+	plannerResultPB := &distributedpb.LogicalPlannerResult{}
+	if err := proto.UnmarshalText(expectedPlannerResult, plannerResultPB); err != nil {
+		t.Fatal("Cannot Unmarshal protobuf.")
+	}
+	var plannedOpts *planpb.PlanOptions
+	planner := mock_controllers.NewMockPlanner(ctrl)
+	planner.EXPECT().
+		Plan(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(state *distributedpb.LogicalPlannerState, req *plannerpb.QueryRequest) (*distributedpb.LogicalPlannerResult, error) {
+			plannedOpts = state.PlanOptions
+			return plannerResultPB, nil
+		})
+
+	queryID := uuid.Must(uuid.NewV4())
+	rf := &fakeResultForwarder{
+		Error: &controllers.BudgetExceededError{QueryID: queryID.String(), Reason: controllers.TruncatedByTimeout},
+	}
+	s, err := controllers.NewServerWithForwarderAndPlanner(env, &at, rf, nil, nil, nc, planner)
+	require.NoError(t, err)
+	s.SetQueryBudgetLimits(controllers.QueryBudget{
+		Timeout:           time.Minute,
+		MaxBytesForwarded: 1000,
+	})
+
+	sub, err := nc.SubscribeSync(fmt.Sprintf("Agent/%s", agent1ID))
+	require.NoError(t, err)
+
+	srv := mock_vizierpb.NewMockVizierService_ExecuteScriptServer(ctrl)
+	ctx := authcontext.NewContext(context.Background(), authcontext.New())
+	srv.EXPECT().Context().Return(ctx).AnyTimes()
+	srv.EXPECT().Send(gomock.Any()).Return(nil).AnyTimes()
+
+	err = s.ExecuteScript(&vizierpb.ExecuteScriptRequest{
+		QueryStr: "#px:set max_bytes_forwarded=500\n" + testQuery,
+		QueryID:  queryID.String(),
+	}, srv)
+	// The client already has its partial results, so exceeding the budget isn't an error.
+	require.NoError(t, err)
+
+	// The query can lower the broker's limits, and unset budgets default to them.
+	expectedBudget := controllers.QueryBudget{
+		Timeout:           time.Minute,
+		MaxBytesForwarded: 500,
+	}
+	assert.Equal(t, expectedBudget, rf.StreamedBudget)
+	require.NotNil(t, plannedOpts)
+	assert.Equal(t, expectedBudget, controllers.QueryBudgetFromPlanOptions(plannedOpts))
+
+	// The query is launched and then cancelled on the agents.
+	var cancelled bool
+	for !cancelled {
+		m, err := sub.NextMsg(time.Second)
+		require.NoError(t, err)
+		pb := &messagespb.VizierMessage{}
+		require.NoError(t, proto.Unmarshal(m.Data, pb))
+		if req := pb.GetCancelQueryRequest(); req != nil {
+			assert.Equal(t, queryID, utils.UUIDFromProtoOrNil(req.QueryID))
+			cancelled = true
+		}
+	}
+}
+
+func TestExecuteScript_PartialResults(t *testing.T) {
+	nc, cleanup := testingutils.MustStartTestNATS(t)
+	defer cleanup()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	plannerStatePB := new(distributedpb.LogicalPlannerState)
+	if err := proto.UnmarshalText(singleAgentDistributedState, plannerStatePB); err != nil {
+		t.Fatal("Cannot Unmarshal protobuf.")
+	}
+	at := fakeAgentsTracker{
+		agentsInfo: tracker.NewTestAgentsInfo(plannerStatePB.DistributedState),
+	}
+
+	env, err := querybrokerenv.New("qb_address", "qb_hostname", "test")
+	require.NoError(t, err)
+
+	plannerResultPB := &distributedpb.LogicalPlannerResult{}
+	if err := proto.UnmarshalText(expectedPlannerResult, plannerResultPB); err != nil {
+		t.Fatal("Cannot Unmarshal protobuf.")
+	}
+	var plannedOpts *planpb.PlanOptions
+	planner := mock_controllers.NewMockPlanner(ctrl)
+	planner.EXPECT().
+		Plan(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(state *distributedpb.LogicalPlannerState, req *plannerpb.QueryRequest) (*distributedpb.LogicalPlannerResult, error) {
+			plannedOpts = state.PlanOptions
+			return plannerResultPB, nil
+		})
+
+	queryID := uuid.Must(uuid.NewV4())
+	rf := &fakeResultForwarder{
+		Error: &controllers.PartialResultsError{QueryID: queryID.String(), MissingAgents: []string{agent1ID}},
+	}
+	s, err := controllers.NewServerWithForwarderAndPlanner(env, &at, rf, nil, nil, nc, planner)
+	require.NoError(t, err)
+	reporter := &fakeAgentFailureReporter{reported: make(chan []string, 1)}
+	s.SetAgentFailureReporter(reporter)
+
+	srv := mock_vizierpb.NewMockVizierService_ExecuteScriptServer(ctrl)
+	ctx := authcontext.NewContext(context.Background(), authcontext.New())
+	srv.EXPECT().Context().Return(ctx).AnyTimes()
+	srv.EXPECT().Send(gomock.Any()).Return(nil).AnyTimes()
+
+	err = s.ExecuteScript(&vizierpb.ExecuteScriptRequest{
+		QueryStr: "#px:set partial_results=true\n#px:set partial_results_deadline_ms=3000\n" + testQuery,
+		QueryID:  queryID.String(),
+	}, srv)
+	// The client already has the partial results, so the missing agents aren't an error.
+	require.NoError(t, err)
+
+	assert.Equal(t, &controllers.PartialResultsOpts{
+		Quorum:   0.5,
+		Deadline: 3 * time.Second,
+	}, rf.RegisteredPartialOpts)
+	require.NotNil(t, plannedOpts)
+	assert.Equal(t, int64(3*time.Second), plannedOpts.PartialResultsDeadlineNs)
+
+	// The agent that missed the deadline was only slow, so it isn't reported.
+	select {
+	case reported := <-reporter.reported:
+		t.Fatalf("Agents that missed the deadline were reported: %v", reported)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
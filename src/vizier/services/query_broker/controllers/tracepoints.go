@@ -0,0 +1,197 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gogo/protobuf/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/common/base/statuspb"
+	"px.dev/pixie/src/shared/services/authcontext"
+	"px.dev/pixie/src/utils"
+	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
+)
+
+// metadataContext forwards the caller's credentials to the metadata service.
+func metadataContext(ctx context.Context) (context.Context, error) {
+	aCtx, err := authcontext.FromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", fmt.Sprintf("bearer %s", aCtx.AuthToken)), nil
+}
+
+func tracepointDetailsToVizierTracepoint(tp *metadatapb.GetTracepointDetailsResponse_TracepointDetails, withAgents bool) *vizierpb.Tracepoint {
+	info := tp.Info
+	statuses := make([]*vizierpb.Status, len(tp.Statuses))
+	for i, s := range tp.Statuses {
+		statuses[i] = StatusToVizierStatus(s)
+	}
+	var tables []string
+	if info.Tracepoint != nil {
+		for _, p := range info.Tracepoint.Programs {
+			tables = append(tables, p.TableName)
+		}
+	}
+	vzTp := &vizierpb.Tracepoint{
+		ID:            utils.UUIDFromProtoOrNil(info.ID).String(),
+		Name:          info.Name,
+		State:         convertLifeCycleStateToVizierLifeCycleState(tp.State),
+		ExpectedState: convertLifeCycleStateToVizierLifeCycleState(info.ExpectedState),
+		Statuses:      statuses,
+		TableNames:    tables,
+		Version:       info.Version,
+		ExpiresAtNS:   tp.ExpiresAtNs,
+	}
+	if !withAgents {
+		return vzTp
+	}
+	vzTp.AgentStatuses = make([]*vizierpb.AgentTracepointStatus, len(tp.AgentStatuses))
+	for i, s := range tp.AgentStatuses {
+		agentStatus := &vizierpb.AgentTracepointStatus{
+			AgentID: utils.UUIDFromProtoOrNil(s.AgentID).String(),
+			State:   convertLifeCycleStateToVizierLifeCycleState(s.State),
+		}
+		if s.Status != nil {
+			agentStatus.Status = StatusToVizierStatus(s.Status)
+		}
+		vzTp.AgentStatuses[i] = agentStatus
+	}
+	return vzTp
+}
+
+// checkTracepointRestrictions returns an error if the caller's access policies don't allow it to
+// manage one of the tracepoints.
+func (s *Server) checkTracepointRestrictions(ctx context.Context, tracepoints []*metadatapb.GetTracepointDetailsResponse_TracepointDetails) error {
+	if s.policies == nil {
+		return nil
+	}
+	restrictions := s.policies.Resolve(claimsFromContext(ctx))
+	for _, tp := range tracepoints {
+		if st := restrictions.CheckTracepoint(tp.Info.GetTracepoint()); st != nil {
+			return status.Error(codes.PermissionDenied, st.Msg)
+		}
+	}
+	return nil
+}
+
+// ListTracepoints lists the tracepoints and their overall state.
+func (s *Server) ListTracepoints(req *vizierpb.ListTracepointsRequest, srv vizierpb.VizierTracepointService_ListTracepointsServer) error {
+	ctx, err := metadataContext(srv.Context())
+	if err != nil {
+		return err
+	}
+	resp, err := s.mdtp.GetTracepointDetails(ctx, &metadatapb.GetTracepointDetailsRequest{})
+	if err != nil {
+		return err
+	}
+	tracepoints := make([]*vizierpb.Tracepoint, len(resp.Tracepoints))
+	for i, tp := range resp.Tracepoints {
+		tracepoints[i] = tracepointDetailsToVizierTracepoint(tp, false)
+	}
+	return srv.Send(&vizierpb.ListTracepointsResponse{Tracepoints: tracepoints})
+}
+
+// GetTracepoint gets a tracepoint along with its state on each agent.
+func (s *Server) GetTracepoint(req *vizierpb.GetTracepointRequest, srv vizierpb.VizierTracepointService_GetTracepointServer) error {
+	if req.Name == "" {
+		return status.Error(codes.InvalidArgument, "missing tracepoint name")
+	}
+	ctx, err := metadataContext(srv.Context())
+	if err != nil {
+		return err
+	}
+	resp, err := s.mdtp.GetTracepointDetails(ctx, &metadatapb.GetTracepointDetailsRequest{
+		Names: []string{req.Name},
+	})
+	if err != nil {
+		return err
+	}
+	if len(resp.Tracepoints) == 0 {
+		return status.Errorf(codes.NotFound, "tracepoint %s not found", req.Name)
+	}
+	return srv.Send(&vizierpb.GetTracepointResponse{
+		Tracepoint: tracepointDetailsToVizierTracepoint(resp.Tracepoints[0], true),
+	})
+}
+
+// DeleteTracepoints starts terminating the given tracepoints on all agents.
+func (s *Server) DeleteTracepoints(req *vizierpb.DeleteTracepointsRequest, srv vizierpb.VizierTracepointService_DeleteTracepointsServer) error {
+	if len(req.Names) == 0 {
+		return status.Error(codes.InvalidArgument, "missing tracepoint names")
+	}
+	ctx, err := metadataContext(srv.Context())
+	if err != nil {
+		return err
+	}
+	// Check that the tracepoints exist first, so that unknown names are reported as such.
+	details, err := s.mdtp.GetTracepointDetails(ctx, &metadatapb.GetTracepointDetailsRequest{Names: req.Names})
+	if err != nil {
+		return err
+	}
+	if err := s.checkTracepointRestrictions(ctx, details.Tracepoints); err != nil {
+		return err
+	}
+	_, err = s.mdtp.RemoveTracepoint(ctx, &metadatapb.RemoveTracepointRequest{Names: req.Names})
+	if err != nil {
+		return err
+	}
+	return srv.Send(&vizierpb.DeleteTracepointsResponse{})
+}
+
+// ExtendTracepoints resets the TTL of the given tracepoints.
+func (s *Server) ExtendTracepoints(req *vizierpb.ExtendTracepointsRequest, srv vizierpb.VizierTracepointService_ExtendTracepointsServer) error {
+	if len(req.Names) == 0 {
+		return status.Error(codes.InvalidArgument, "missing tracepoint names")
+	}
+	if req.TTLNS <= 0 {
+		return status.Error(codes.InvalidArgument, "TTL must be positive")
+	}
+	ctx, err := metadataContext(srv.Context())
+	if err != nil {
+		return err
+	}
+	if s.policies != nil {
+		details, err := s.mdtp.GetTracepointDetails(ctx, &metadatapb.GetTracepointDetailsRequest{Names: req.Names})
+		if err != nil {
+			return err
+		}
+		if err := s.checkTracepointRestrictions(ctx, details.Tracepoints); err != nil {
+			return err
+		}
+	}
+	resp, err := s.mdtp.ExtendTracepointTTL(ctx, &metadatapb.ExtendTracepointTTLRequest{
+		Names: req.Names,
+		TTL:   types.DurationProto(time.Duration(req.TTLNS)),
+	})
+	if err != nil {
+		return err
+	}
+	if resp.Status != nil && resp.Status.ErrCode != statuspb.OK {
+		return status.Error(statusCodeToGRPCCode[resp.Status.ErrCode], resp.Status.Msg)
+	}
+	return srv.Send(&vizierpb.ExtendTracepointsResponse{})
+}
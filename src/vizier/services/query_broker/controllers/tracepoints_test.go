@@ -0,0 +1,202 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package controllers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/gogo/protobuf/types"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+	mock_vizierpb "px.dev/pixie/src/api/proto/vizierpb/mock"
+	"px.dev/pixie/src/carnot/planner/dynamic_tracing/ir/logicalpb"
+	"px.dev/pixie/src/common/base/statuspb"
+	"px.dev/pixie/src/shared/services/authcontext"
+	"px.dev/pixie/src/utils"
+	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
+	mock_metadatapb "px.dev/pixie/src/vizier/services/metadata/metadatapb/mock"
+	"px.dev/pixie/src/vizier/services/metadata/storepb"
+	"px.dev/pixie/src/vizier/services/query_broker/controllers"
+	"px.dev/pixie/src/vizier/services/query_broker/policy"
+	"px.dev/pixie/src/vizier/services/query_broker/querybrokerenv"
+)
+
+func setupTracepointServer(t *testing.T, ctrl *gomock.Controller) (*controllers.Server, *mock_metadatapb.MockMetadataTracepointServiceClient) {
+	env, err := querybrokerenv.New("qb_address", "qb_hostname", "test")
+	require.NoError(t, err)
+	mdtp := mock_metadatapb.NewMockMetadataTracepointServiceClient(ctrl)
+	s, err := controllers.NewServerWithForwarderAndPlanner(env, &fakeAgentsTracker{}, &fakeResultForwarder{}, mdtp, nil, nil, nil)
+	require.NoError(t, err)
+	return s, mdtp
+}
+
+func TestGetTracepoint(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s, mdtp := setupTracepointServer(t, ctrl)
+
+	tpID := uuid.Must(uuid.NewV4())
+	agentID := uuid.Must(uuid.NewV4())
+	failure := &statuspb.Status{ErrCode: statuspb.INTERNAL, Msg: "could not attach probe"}
+	mdtp.EXPECT().
+		GetTracepointDetails(gomock.Any(), &metadatapb.GetTracepointDetailsRequest{Names: []string{"http_probe"}}).
+		Return(&metadatapb.GetTracepointDetailsResponse{
+			Tracepoints: []*metadatapb.GetTracepointDetailsResponse_TracepointDetails{
+				{
+					Info: &storepb.TracepointInfo{
+						ID:   utils.ProtoFromUUID(tpID),
+						Name: "http_probe",
+						Tracepoint: &logicalpb.TracepointDeployment{
+							Programs: []*logicalpb.TracepointDeployment_TracepointProgram{
+								{TableName: "http_table"},
+							},
+						},
+						ExpectedState: statuspb.RUNNING_STATE,
+						Version:       3,
+					},
+					AgentStatuses: []*storepb.AgentTracepointStatus{
+						{
+							ID:      utils.ProtoFromUUID(tpID),
+							AgentID: utils.ProtoFromUUID(agentID),
+							State:   statuspb.FAILED_STATE,
+							Status:  failure,
+						},
+					},
+					ExpiresAtNs: 1000,
+					State:       statuspb.FAILED_STATE,
+					Statuses:    []*statuspb.Status{failure},
+				},
+			},
+		}, nil)
+
+	srv := mock_vizierpb.NewMockVizierTracepointService_GetTracepointServer(ctrl)
+	srv.EXPECT().Context().Return(authcontext.NewContext(context.Background(), authcontext.New())).AnyTimes()
+	var resp *vizierpb.GetTracepointResponse
+	srv.EXPECT().Send(gomock.Any()).DoAndReturn(func(r *vizierpb.GetTracepointResponse) error {
+		resp = r
+		return nil
+	})
+
+	err := s.GetTracepoint(&vizierpb.GetTracepointRequest{Name: "http_probe"}, srv)
+	require.NoError(t, err)
+
+	vzFailure := &vizierpb.Status{Code: int32(codes.Internal), Message: "could not attach probe"}
+	assert.Equal(t, &vizierpb.Tracepoint{
+		ID:            tpID.String(),
+		Name:          "http_probe",
+		State:         vizierpb.FAILED_STATE,
+		ExpectedState: vizierpb.RUNNING_STATE,
+		Statuses:      []*vizierpb.Status{vzFailure},
+		TableNames:    []string{"http_table"},
+		Version:       3,
+		ExpiresAtNS:   1000,
+		AgentStatuses: []*vizierpb.AgentTracepointStatus{
+			{
+				AgentID: agentID.String(),
+				State:   vizierpb.FAILED_STATE,
+				Status:  vzFailure,
+			},
+		},
+	}, resp.Tracepoint)
+}
+
+func TestExtendTracepoints(t *testing.T) {
+	tests := []struct {
+		name         string
+		statusCode   statuspb.Code
+		expectedCode codes.Code
+	}{
+		{
+			name:         "extended",
+			statusCode:   statuspb.OK,
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "not found",
+			statusCode:   statuspb.NOT_FOUND,
+			expectedCode: codes.NotFound,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			s, mdtp := setupTracepointServer(t, ctrl)
+
+			mdtp.EXPECT().
+				ExtendTracepointTTL(gomock.Any(), &metadatapb.ExtendTracepointTTLRequest{
+					Names: []string{"http_probe"},
+					TTL:   types.DurationProto(time.Hour),
+				}).
+				Return(&metadatapb.ExtendTracepointTTLResponse{
+					Status: &statuspb.Status{ErrCode: test.statusCode},
+				}, nil)
+
+			srv := mock_vizierpb.NewMockVizierTracepointService_ExtendTracepointsServer(ctrl)
+			srv.EXPECT().Context().Return(authcontext.NewContext(context.Background(), authcontext.New())).AnyTimes()
+			if test.expectedCode == codes.OK {
+				srv.EXPECT().Send(&vizierpb.ExtendTracepointsResponse{}).Return(nil)
+			}
+
+			err := s.ExtendTracepoints(&vizierpb.ExtendTracepointsRequest{
+				Names: []string{"http_probe"},
+				TTLNS: int64(time.Hour),
+			}, srv)
+			assert.Equal(t, test.expectedCode, status.Code(err))
+		})
+	}
+}
+
+func TestDeleteTracepoints_PolicyDenied(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s, mdtp := setupTracepointServer(t, ctrl)
+	s.SetPolicyStore(policy.NewStore([]policy.Policy{
+		{Name: "team-a-only", Namespaces: []string{"team-a"}},
+	}))
+
+	mdtp.EXPECT().
+		GetTracepointDetails(gomock.Any(), &metadatapb.GetTracepointDetailsRequest{Names: []string{"team_b_probe"}}).
+		Return(&metadatapb.GetTracepointDetailsResponse{
+			Tracepoints: []*metadatapb.GetTracepointDetailsResponse_TracepointDetails{
+				{
+					Info: &storepb.TracepointInfo{
+						Name:       "team_b_probe",
+						Tracepoint: podTracepointDeployment("team_b_probe", "team-b/server"),
+					},
+				},
+			},
+		}, nil)
+	// The tracepoint isn't removed.
+
+	srv := mock_vizierpb.NewMockVizierTracepointService_DeleteTracepointsServer(ctrl)
+	srv.EXPECT().Context().Return(authcontext.NewContext(context.Background(), authcontext.New())).AnyTimes()
+
+	err := s.DeleteTracepoints(&vizierpb.DeleteTracepointsRequest{Names: []string{"team_b_probe"}}, srv)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
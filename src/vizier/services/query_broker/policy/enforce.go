@@ -0,0 +1,355 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package policy
+
+import (
+	"fmt"
+
+	"px.dev/pixie/src/carnot/planner/distributedpb"
+	"px.dev/pixie/src/carnot/planpb"
+	"px.dev/pixie/src/common/base/statuspb"
+	"px.dev/pixie/src/shared/types/typespb"
+)
+
+// RedactedValue replaces the values of redacted string columns.
+const RedactedValue = "<redacted>"
+
+const upidColumn = "upid"
+
+// Apply checks the plan against the restrictions and rewrites it so that the agents filter and
+// redact the rows of each table they read. It returns a status describing the violation if the
+// plan reads a denied table. The plan is modified in place.
+func (e *Effective) Apply(plan *distributedpb.DistributedPlan, schemas []*distributedpb.SchemaInfo) (*statuspb.Status, error) {
+	if e.Empty() {
+		return nil, nil
+	}
+	if e.RestrictNamespaces && len(e.Namespaces) == 0 {
+		return permissionDenied("policy '%s' allows no namespaces", e.NamespacePolicy), nil
+	}
+
+	relations := make(map[string]*distributedpb.SchemaInfo)
+	for _, s := range schemas {
+		relations[s.Name] = s
+	}
+
+	for _, agentPlan := range plan.QbAddressToPlan {
+		r := newPlanRewriter(agentPlan)
+		for _, fragment := range agentPlan.Nodes {
+			// Rewriting appends to fragment.Nodes, so only visit the original nodes.
+			nodes := fragment.Nodes
+			for _, node := range nodes {
+				memSrc := node.Op.GetMemSourceOp()
+				if memSrc == nil {
+					continue
+				}
+				if policyName, ok := e.DenyTables[memSrc.Name]; ok {
+					return permissionDenied("access to table '%s' is denied by policy '%s'", memSrc.Name, policyName), nil
+				}
+				st, err := e.restrictSource(r, fragment, node, relations[memSrc.Name])
+				if st != nil || err != nil {
+					return st, err
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+func permissionDenied(format string, args ...interface{}) *statuspb.Status {
+	return &statuspb.Status{
+		ErrCode: statuspb.PERMISSION_DENIED,
+		Msg:     fmt.Sprintf(format, args...),
+	}
+}
+
+// planRewriter allocates node and function IDs that are unused in a plan.
+type planRewriter struct {
+	nextNodeID uint64
+	nextFuncID int64
+}
+
+func newPlanRewriter(plan *planpb.Plan) *planRewriter {
+	r := &planRewriter{}
+	var visitExpr func(e *planpb.ScalarExpression)
+	visitExpr = func(e *planpb.ScalarExpression) {
+		if f := e.GetFunc(); f != nil {
+			if f.Id >= r.nextFuncID {
+				r.nextFuncID = f.Id + 1
+			}
+			for _, arg := range f.Args {
+				visitExpr(arg)
+			}
+		}
+	}
+	for _, fragment := range plan.Nodes {
+		for _, node := range fragment.Nodes {
+			if node.Id >= r.nextNodeID {
+				r.nextNodeID = node.Id + 1
+			}
+			switch op := node.Op.Op.(type) {
+			case *planpb.Operator_MapOp:
+				for _, e := range op.MapOp.Expressions {
+					visitExpr(e)
+				}
+			case *planpb.Operator_FilterOp:
+				visitExpr(op.FilterOp.Expression)
+			case *planpb.Operator_AggOp:
+				for _, v := range op.AggOp.Values {
+					if v.Id >= r.nextFuncID {
+						r.nextFuncID = v.Id + 1
+					}
+				}
+			}
+		}
+	}
+	return r
+}
+
+func (r *planRewriter) nodeID() uint64 {
+	id := r.nextNodeID
+	r.nextNodeID++
+	return id
+}
+
+func (r *planRewriter) funcID() int64 {
+	id := r.nextFuncID
+	r.nextFuncID++
+	return id
+}
+
+// restrictSource inserts a filter and a map after the memory source, to restrict rows to the allowed
+// namespaces and redact columns. The memory source is given a new ID and the last inserted node takes
+// its ID, so that the operators reading from the memory source read the restricted rows instead.
+func (e *Effective) restrictSource(r *planRewriter, fragment *planpb.PlanFragment, node *planpb.PlanNode,
+	schema *distributedpb.SchemaInfo) (*statuspb.Status, error) {
+	memSrc := node.Op.GetMemSourceOp()
+	redacted := e.RedactColumns[memSrc.Name]
+	redact := false
+	for _, name := range memSrc.ColumnNames {
+		if redacted[name] {
+			redact = true
+		}
+	}
+	if !e.RestrictNamespaces && !redact {
+		return nil, nil
+	}
+
+	origID := node.Id
+	node.Id = r.nodeID()
+	chain := []uint64{node.Id}
+	numCols := len(memSrc.ColumnNames)
+
+	if e.RestrictNamespaces {
+		upidIdx := -1
+		for i, name := range memSrc.ColumnNames {
+			if name == upidColumn {
+				upidIdx = i
+			}
+		}
+		if upidIdx == -1 {
+			// The query doesn't read the upid, so read it for the filter and drop it afterwards.
+			if schema != nil && schema.Relation != nil {
+				for i, col := range schema.Relation.Columns {
+					if col.ColumnName == upidColumn {
+						upidIdx = len(memSrc.ColumnNames)
+						memSrc.ColumnIdxs = append(memSrc.ColumnIdxs, int64(i))
+						memSrc.ColumnNames = append(memSrc.ColumnNames, upidColumn)
+						memSrc.ColumnTypes = append(memSrc.ColumnTypes, col.ColumnType)
+					}
+				}
+			}
+		}
+		if upidIdx == -1 {
+			return permissionDenied("table '%s' can't be restricted to the namespaces allowed by policy '%s'",
+				memSrc.Name, e.NamespacePolicy), nil
+		}
+
+		filterID := origID
+		if redact {
+			filterID = r.nodeID()
+		}
+		columns := make([]*planpb.Column, numCols)
+		for i := range columns {
+			columns[i] = &planpb.Column{Node: node.Id, Index: uint64(i)}
+		}
+		fragment.Nodes = append(fragment.Nodes, &planpb.PlanNode{
+			Id: filterID,
+			Op: &planpb.Operator{
+				OpType: planpb.FILTER_OPERATOR,
+				Op: &planpb.Operator_FilterOp{
+					FilterOp: &planpb.FilterOperator{
+						Expression: e.namespacePredicate(r, node.Id, uint64(upidIdx)),
+						Columns:    columns,
+					},
+				},
+			},
+		})
+		chain = append(chain, filterID)
+	}
+
+	if redact {
+		parent := chain[len(chain)-1]
+		exprs := make([]*planpb.ScalarExpression, numCols)
+		for i := 0; i < numCols; i++ {
+			if !redacted[memSrc.ColumnNames[i]] {
+				exprs[i] = &planpb.ScalarExpression{
+					Value: &planpb.ScalarExpression_Column{
+						Column: &planpb.Column{Node: parent, Index: uint64(i)},
+					},
+				}
+				continue
+			}
+			val, err := redactedValue(memSrc.ColumnTypes[i])
+			if err != nil {
+				return nil, err
+			}
+			exprs[i] = &planpb.ScalarExpression{
+				Value: &planpb.ScalarExpression_Constant{Constant: val},
+			}
+		}
+		fragment.Nodes = append(fragment.Nodes, &planpb.PlanNode{
+			Id: origID,
+			Op: &planpb.Operator{
+				OpType: planpb.MAP_OPERATOR,
+				Op: &planpb.Operator_MapOp{
+					MapOp: &planpb.MapOperator{
+						Expressions: exprs,
+						ColumnNames: append([]string{}, memSrc.ColumnNames[:numCols]...),
+					},
+				},
+			},
+		})
+		chain = append(chain, origID)
+	}
+
+	relinkDAG(fragment, chain)
+	return nil, nil
+}
+
+// relinkDAG inserts the chain of nodes into the fragment's DAG. The last node of the chain has the
+// original ID of the memory source and keeps its children, the first node is the memory source.
+func relinkDAG(fragment *planpb.PlanFragment, chain []uint64) {
+	origID := chain[len(chain)-1]
+	var dagNodes []*planpb.DAG_DAGNode
+	for _, n := range fragment.Dag.Nodes {
+		if n.Id != origID {
+			dagNodes = append(dagNodes, n)
+			continue
+		}
+		for i, id := range chain {
+			if id == origID {
+				n.SortedParents = []uint64{chain[i-1]}
+				dagNodes = append(dagNodes, n)
+				continue
+			}
+			dagNode := &planpb.DAG_DAGNode{
+				Id:             id,
+				SortedChildren: []uint64{chain[i+1]},
+			}
+			if i > 0 {
+				dagNode.SortedParents = []uint64{chain[i-1]}
+			}
+			dagNodes = append(dagNodes, dagNode)
+		}
+	}
+	fragment.Dag.Nodes = dagNodes
+
+	// Limits may abort the memory source early, which now has a new ID.
+	for _, node := range fragment.Nodes {
+		if limit := node.Op.GetLimitOp(); limit != nil {
+			for i, src := range limit.AbortableSrcs {
+				if src == origID {
+					limit.AbortableSrcs[i] = chain[0]
+				}
+			}
+		}
+	}
+}
+
+// namespacePredicate returns an expression which is true for rows of processes in the allowed namespaces.
+func (e *Effective) namespacePredicate(r *planRewriter, node uint64, upidIdx uint64) *planpb.ScalarExpression {
+	var pred *planpb.ScalarExpression
+	for _, ns := range e.Namespaces {
+		namespace := &planpb.ScalarExpression{
+			Value: &planpb.ScalarExpression_Func{
+				Func: &planpb.ScalarFunc{
+					Name: "upid_to_namespace",
+					Id:   r.funcID(),
+					Args: []*planpb.ScalarExpression{
+						{Value: &planpb.ScalarExpression_Column{Column: &planpb.Column{Node: node, Index: upidIdx}}},
+					},
+					ArgsDataTypes: []typespb.DataType{typespb.UINT128},
+				},
+			},
+		}
+		eq := &planpb.ScalarExpression{
+			Value: &planpb.ScalarExpression_Func{
+				Func: &planpb.ScalarFunc{
+					Name: "equal",
+					Id:   r.funcID(),
+					Args: []*planpb.ScalarExpression{
+						namespace,
+						{Value: &planpb.ScalarExpression_Constant{Constant: &planpb.ScalarValue{
+							DataType: typespb.STRING,
+							Value:    &planpb.ScalarValue_StringValue{StringValue: ns},
+						}}},
+					},
+					ArgsDataTypes: []typespb.DataType{typespb.STRING, typespb.STRING},
+				},
+			},
+		}
+		if pred == nil {
+			pred = eq
+			continue
+		}
+		pred = &planpb.ScalarExpression{
+			Value: &planpb.ScalarExpression_Func{
+				Func: &planpb.ScalarFunc{
+					Name:          "logicalOr",
+					Id:            r.funcID(),
+					Args:          []*planpb.ScalarExpression{pred, eq},
+					ArgsDataTypes: []typespb.DataType{typespb.BOOLEAN, typespb.BOOLEAN},
+				},
+			},
+		}
+	}
+	return pred
+}
+
+// redactedValue returns the value that replaces redacted values of the given type.
+func redactedValue(t typespb.DataType) (*planpb.ScalarValue, error) {
+	v := &planpb.ScalarValue{DataType: t}
+	switch t {
+	case typespb.STRING:
+		v.Value = &planpb.ScalarValue_StringValue{StringValue: RedactedValue}
+	case typespb.BOOLEAN:
+		v.Value = &planpb.ScalarValue_BoolValue{BoolValue: false}
+	case typespb.INT64:
+		v.Value = &planpb.ScalarValue_Int64Value{Int64Value: 0}
+	case typespb.FLOAT64:
+		v.Value = &planpb.ScalarValue_Float64Value{Float64Value: 0}
+	case typespb.TIME64NS:
+		v.Value = &planpb.ScalarValue_Time64NsValue{Time64NsValue: 0}
+	case typespb.UINT128:
+		v.Value = &planpb.ScalarValue_Uint128Value{Uint128Value: &typespb.UInt128{}}
+	default:
+		return nil, fmt.Errorf("can't redact column of type %s", t.String())
+	}
+	return v, nil
+}
@@ -0,0 +1,275 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package policy_test
+
+import (
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/carnot/planner/distributedpb"
+	"px.dev/pixie/src/carnot/planpb"
+	"px.dev/pixie/src/common/base/statuspb"
+	"px.dev/pixie/src/shared/types/typespb"
+	"px.dev/pixie/src/table_store/schemapb"
+	"px.dev/pixie/src/vizier/services/query_broker/policy"
+)
+
+const agentAddr = "21285cdd-1de9-4ab1-ae6a-0ba08c8c676c"
+
+// testPlan returns a plan which reads time_ and req_body from http_events, then limits and sends them.
+func testPlan(table string) *distributedpb.DistributedPlan {
+	return &distributedpb.DistributedPlan{
+		QbAddressToPlan: map[string]*planpb.Plan{
+			agentAddr: {
+				Nodes: []*planpb.PlanFragment{
+					{
+						Dag: &planpb.DAG{
+							Nodes: []*planpb.DAG_DAGNode{
+								{Id: 0, SortedChildren: []uint64{1}},
+								{Id: 1, SortedParents: []uint64{0}, SortedChildren: []uint64{2}},
+								{Id: 2, SortedParents: []uint64{1}},
+							},
+						},
+						Nodes: []*planpb.PlanNode{
+							{
+								Id: 0,
+								Op: &planpb.Operator{
+									OpType: planpb.MEMORY_SOURCE_OPERATOR,
+									Op: &planpb.Operator_MemSourceOp{
+										MemSourceOp: &planpb.MemorySourceOperator{
+											Name:        table,
+											ColumnIdxs:  []int64{0, 2},
+											ColumnNames: []string{"time_", "req_body"},
+											ColumnTypes: []typespb.DataType{typespb.TIME64NS, typespb.STRING},
+										},
+									},
+								},
+							},
+							{
+								Id: 1,
+								Op: &planpb.Operator{
+									OpType: planpb.LIMIT_OPERATOR,
+									Op: &planpb.Operator_LimitOp{
+										LimitOp: &planpb.LimitOperator{
+											Limit: 10,
+											Columns: []*planpb.Column{
+												{Node: 0, Index: 0},
+												{Node: 0, Index: 1},
+											},
+											AbortableSrcs: []uint64{0},
+										},
+									},
+								},
+							},
+							{
+								Id: 2,
+								Op: &planpb.Operator{
+									OpType: planpb.GRPC_SINK_OPERATOR,
+									Op:     &planpb.Operator_GRPCSinkOp{GRPCSinkOp: &planpb.GRPCSinkOperator{}},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+var testSchemas = []*distributedpb.SchemaInfo{
+	{
+		Name: "http_events",
+		Relation: &schemapb.Relation{
+			Columns: []*schemapb.Relation_ColumnInfo{
+				{ColumnName: "time_", ColumnType: typespb.TIME64NS},
+				{ColumnName: "upid", ColumnType: typespb.UINT128},
+				{ColumnName: "req_body", ColumnType: typespb.STRING},
+			},
+		},
+	},
+	{
+		Name: "no_upid",
+		Relation: &schemapb.Relation{
+			Columns: []*schemapb.Relation_ColumnInfo{
+				{ColumnName: "time_", ColumnType: typespb.TIME64NS},
+				{ColumnName: "other", ColumnType: typespb.INT64},
+				{ColumnName: "req_body", ColumnType: typespb.STRING},
+			},
+		},
+	},
+}
+
+func fragment(plan *distributedpb.DistributedPlan) *planpb.PlanFragment {
+	return plan.QbAddressToPlan[agentAddr].Nodes[0]
+}
+
+func planNode(f *planpb.PlanFragment, id uint64) *planpb.PlanNode {
+	for _, n := range f.Nodes {
+		if n.Id == id {
+			return n
+		}
+	}
+	return nil
+}
+
+func dagNode(f *planpb.PlanFragment, id uint64) *planpb.DAG_DAGNode {
+	for _, n := range f.Dag.Nodes {
+		if n.Id == id {
+			return n
+		}
+	}
+	return nil
+}
+
+func TestApply_NoRestrictions(t *testing.T) {
+	plan := testPlan("http_events")
+	st, err := policy.Resolve(nil, nil).Apply(plan, testSchemas)
+	require.NoError(t, err)
+	assert.Nil(t, st)
+	assert.True(t, proto.Equal(testPlan("http_events"), plan))
+}
+
+func TestApply_DenyTable(t *testing.T) {
+	e := policy.Resolve([]policy.Policy{{Name: "no-http", DenyTables: []string{"http_events"}}}, nil)
+	st, err := e.Apply(testPlan("http_events"), testSchemas)
+	require.NoError(t, err)
+	require.NotNil(t, st)
+	assert.Equal(t, statuspb.PERMISSION_DENIED, st.ErrCode)
+	assert.Contains(t, st.Msg, "http_events")
+	assert.Contains(t, st.Msg, "no-http")
+}
+
+func TestApply_Namespaces(t *testing.T) {
+	e := policy.Resolve([]policy.Policy{{Name: "ns", Namespaces: []string{"default", "staging"}}}, nil)
+	plan := testPlan("http_events")
+	st, err := e.Apply(plan, testSchemas)
+	require.NoError(t, err)
+	require.Nil(t, st)
+
+	f := fragment(plan)
+	// The memory source has a new ID and also reads the upid.
+	memSrcNode := planNode(f, 3)
+	require.NotNil(t, memSrcNode)
+	memSrc := memSrcNode.Op.GetMemSourceOp()
+	require.NotNil(t, memSrc)
+	assert.Equal(t, []int64{0, 2, 1}, memSrc.ColumnIdxs)
+	assert.Equal(t, []string{"time_", "req_body", "upid"}, memSrc.ColumnNames)
+
+	// The filter takes the memory source's ID and drops the upid.
+	filter := planNode(f, 0).Op.GetFilterOp()
+	require.NotNil(t, filter)
+	assert.Equal(t, []*planpb.Column{{Node: 3, Index: 0}, {Node: 3, Index: 1}}, filter.Columns)
+	or := filter.Expression.GetFunc()
+	require.NotNil(t, or)
+	assert.Equal(t, "logicalOr", or.Name)
+	require.Len(t, or.Args, 2)
+	eq := or.Args[1].GetFunc()
+	assert.Equal(t, "equal", eq.Name)
+	assert.Equal(t, "staging", eq.Args[1].GetConstant().GetStringValue())
+	ns := eq.Args[0].GetFunc()
+	assert.Equal(t, "upid_to_namespace", ns.Name)
+	assert.Equal(t, &planpb.Column{Node: 3, Index: 2}, ns.Args[0].GetColumn())
+	// Every function has a unique ID.
+	ids := map[int64]bool{or.Id: true, eq.Id: true, ns.Id: true,
+		or.Args[0].GetFunc().Id: true, or.Args[0].GetFunc().Args[0].GetFunc().Id: true}
+	assert.Len(t, ids, 5)
+
+	assert.Equal(t, &planpb.DAG_DAGNode{Id: 3, SortedChildren: []uint64{0}}, dagNode(f, 3))
+	assert.Equal(t, &planpb.DAG_DAGNode{Id: 0, SortedParents: []uint64{3}, SortedChildren: []uint64{1}}, dagNode(f, 0))
+	assert.Equal(t, []uint64{3}, planNode(f, 1).Op.GetLimitOp().AbortableSrcs)
+}
+
+func TestApply_NamespacesAndRedaction(t *testing.T) {
+	e := policy.Resolve([]policy.Policy{{
+		Name:          "p",
+		Namespaces:    []string{"default"},
+		RedactColumns: map[string][]string{"http_events": {"req_body"}},
+	}}, nil)
+	plan := testPlan("http_events")
+	st, err := e.Apply(plan, testSchemas)
+	require.NoError(t, err)
+	require.Nil(t, st)
+
+	f := fragment(plan)
+	require.NotNil(t, planNode(f, 3).Op.GetMemSourceOp())
+	filter := planNode(f, 4).Op.GetFilterOp()
+	require.NotNil(t, filter)
+	assert.Equal(t, "equal", filter.Expression.GetFunc().Name)
+
+	m := planNode(f, 0).Op.GetMapOp()
+	require.NotNil(t, m)
+	assert.Equal(t, []string{"time_", "req_body"}, m.ColumnNames)
+	assert.Equal(t, &planpb.Column{Node: 4, Index: 0}, m.Expressions[0].GetColumn())
+	assert.Equal(t, policy.RedactedValue, m.Expressions[1].GetConstant().GetStringValue())
+
+	assert.Equal(t, &planpb.DAG_DAGNode{Id: 3, SortedChildren: []uint64{4}}, dagNode(f, 3))
+	assert.Equal(t, &planpb.DAG_DAGNode{Id: 4, SortedParents: []uint64{3}, SortedChildren: []uint64{0}}, dagNode(f, 4))
+	assert.Equal(t, &planpb.DAG_DAGNode{Id: 0, SortedParents: []uint64{4}, SortedChildren: []uint64{1}}, dagNode(f, 0))
+}
+
+func TestApply_RedactionOnly(t *testing.T) {
+	e := policy.Resolve([]policy.Policy{{
+		Name:          "p",
+		RedactColumns: map[string][]string{"http_events": {"req_body"}},
+	}}, nil)
+	plan := testPlan("http_events")
+	st, err := e.Apply(plan, testSchemas)
+	require.NoError(t, err)
+	require.Nil(t, st)
+
+	f := fragment(plan)
+	assert.Equal(t, []string{"time_", "req_body"}, planNode(f, 3).Op.GetMemSourceOp().ColumnNames)
+	m := planNode(f, 0).Op.GetMapOp()
+	require.NotNil(t, m)
+	assert.Equal(t, &planpb.Column{Node: 3, Index: 0}, m.Expressions[0].GetColumn())
+	assert.Equal(t, policy.RedactedValue, m.Expressions[1].GetConstant().GetStringValue())
+
+	// Tables without redacted columns are unchanged.
+	plan = testPlan("no_upid")
+	st, err = policy.Resolve([]policy.Policy{{
+		Name:          "p",
+		RedactColumns: map[string][]string{"http_events": {"req_body"}},
+	}}, nil).Apply(plan, testSchemas)
+	require.NoError(t, err)
+	require.Nil(t, st)
+	assert.True(t, proto.Equal(testPlan("no_upid"), plan))
+}
+
+func TestApply_NamespacesWithoutUPID(t *testing.T) {
+	e := policy.Resolve([]policy.Policy{{Name: "ns", Namespaces: []string{"default"}}}, nil)
+	st, err := e.Apply(testPlan("no_upid"), testSchemas)
+	require.NoError(t, err)
+	require.NotNil(t, st)
+	assert.Equal(t, statuspb.PERMISSION_DENIED, st.ErrCode)
+	assert.Contains(t, st.Msg, "no_upid")
+}
+
+func TestApply_NoAllowedNamespaces(t *testing.T) {
+	e := policy.Resolve([]policy.Policy{
+		{Name: "a", Namespaces: []string{"default"}},
+		{Name: "b", Namespaces: []string{"staging"}},
+	}, nil)
+	st, err := e.Apply(testPlan("http_events"), testSchemas)
+	require.NoError(t, err)
+	require.NotNil(t, st)
+	assert.Equal(t, statuspb.PERMISSION_DENIED, st.ErrCode)
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package policy
+
+import (
+	"strings"
+
+	"px.dev/pixie/src/carnot/planner/distributedpb"
+	"px.dev/pixie/src/carnot/planner/dynamic_tracing/ir/logicalpb"
+	"px.dev/pixie/src/common/base/statuspb"
+)
+
+// FilterSchemas returns the schemas without the denied tables. Scripts are planned against the
+// filtered schemas, so the logical plan can't read a denied table on any agent.
+func (e *Effective) FilterSchemas(schemas []*distributedpb.SchemaInfo) []*distributedpb.SchemaInfo {
+	if e.Empty() || len(e.DenyTables) == 0 {
+		return schemas
+	}
+	filtered := make([]*distributedpb.SchemaInfo, 0, len(schemas))
+	for _, s := range schemas {
+		if _, ok := e.DenyTables[s.Name]; !ok {
+			filtered = append(filtered, s)
+		}
+	}
+	return filtered
+}
+
+// CheckTracepoint returns a status describing the violation if the caller can't deploy or delete
+// the tracepoint. Tracepoints can't write to denied tables, and callers restricted to namespaces
+// can only trace pods in those namespaces.
+func (e *Effective) CheckTracepoint(tp *logicalpb.TracepointDeployment) *statuspb.Status {
+	if e.Empty() || tp == nil {
+		return nil
+	}
+	for _, p := range tp.Programs {
+		if policyName, ok := e.DenyTables[p.TableName]; ok {
+			return permissionDenied("tracepoint '%s' writes to table '%s', which is denied by policy '%s'",
+				tp.Name, p.TableName, policyName)
+		}
+	}
+	if !e.RestrictNamespaces {
+		return nil
+	}
+	for _, p := range tp.Programs {
+		// BPFTrace programs trace the whole node.
+		if p.BPFTrace != nil {
+			return permissionDenied("tracepoint '%s' traces every process on the node, which is denied by policy '%s'",
+				tp.Name, e.NamespacePolicy)
+		}
+	}
+	// Pods are named '<namespace>/<pod>'. Other targets can't be tied to a namespace.
+	pod := tp.GetDeploymentSpec().GetPodProcess().GetPod()
+	idx := strings.Index(pod, "/")
+	if idx == -1 || !contains(e.Namespaces, pod[:idx]) {
+		return permissionDenied("tracepoint '%s' must target a pod in the namespaces allowed by policy '%s'",
+			tp.Name, e.NamespacePolicy)
+	}
+	return nil
+}
+
+// CheckConfigUpdate returns a status describing the violation if the caller can't update the
+// config of agents. Agents aren't scoped to namespaces, so callers restricted to namespaces can't.
+func (e *Effective) CheckConfigUpdate() *statuspb.Status {
+	if e.Empty() || !e.RestrictNamespaces {
+		return nil
+	}
+	return permissionDenied("updating the config of agents is denied by policy '%s'", e.NamespacePolicy)
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package policy_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/carnot/planner/distributedpb"
+	"px.dev/pixie/src/carnot/planner/dynamic_tracing/ir/logicalpb"
+	"px.dev/pixie/src/common/base/statuspb"
+	"px.dev/pixie/src/vizier/services/query_broker/policy"
+)
+
+func podTracepoint(pod string, table string) *logicalpb.TracepointDeployment {
+	return &logicalpb.TracepointDeployment{
+		Name: "probe",
+		DeploymentSpec: &logicalpb.DeploymentSpec{
+			TargetOneof: &logicalpb.DeploymentSpec_PodProcess_{
+				PodProcess: &logicalpb.DeploymentSpec_PodProcess{Pod: pod},
+			},
+		},
+		Programs: []*logicalpb.TracepointDeployment_TracepointProgram{
+			{TableName: table},
+		},
+	}
+}
+
+func TestFilterSchemas(t *testing.T) {
+	schemas := []*distributedpb.SchemaInfo{{Name: "http_events"}, {Name: "mysql_events"}}
+
+	var e *policy.Effective
+	assert.Equal(t, schemas, e.FilterSchemas(schemas))
+
+	e = &policy.Effective{DenyTables: map[string]string{"mysql_events": "no-mysql"}}
+	filtered := e.FilterSchemas(schemas)
+	require.Len(t, filtered, 1)
+	assert.Equal(t, "http_events", filtered[0].Name)
+}
+
+func TestCheckTracepoint(t *testing.T) {
+	restricted := &policy.Effective{
+		DenyTables:         map[string]string{"mysql_events": "no-mysql"},
+		RestrictNamespaces: true,
+		Namespaces:         []string{"team-a"},
+		NamespacePolicy:    "team-a-only",
+	}
+	bpftrace := podTracepoint("team-a/server", "tcp_drops")
+	bpftrace.DeploymentSpec = nil
+	bpftrace.Programs[0].BPFTrace = &logicalpb.BPFTrace{Program: "kprobe:tcp_drop {}"}
+	upid := podTracepoint("", "probe_table")
+	upid.DeploymentSpec = &logicalpb.DeploymentSpec{
+		TargetOneof: &logicalpb.DeploymentSpec_Upid{Upid: &logicalpb.UPID{Pid: 123}},
+	}
+
+	tests := []struct {
+		name         string
+		restrictions *policy.Effective
+		tracepoint   *logicalpb.TracepointDeployment
+		allowed      bool
+	}{
+		{"no restrictions", nil, podTracepoint("team-b/server", "probe_table"), true},
+		{"allowed namespace", restricted, podTracepoint("team-a/server", "probe_table"), true},
+		{"other namespace", restricted, podTracepoint("team-b/server", "probe_table"), false},
+		{"pod without namespace", restricted, podTracepoint("server", "probe_table"), false},
+		{"upid target", restricted, upid, false},
+		{"bpftrace", restricted, bpftrace, false},
+		{"denied table", restricted, podTracepoint("team-a/server", "mysql_events"), false},
+		{
+			"denied table without namespaces",
+			&policy.Effective{DenyTables: map[string]string{"mysql_events": "no-mysql"}},
+			podTracepoint("team-b/server", "mysql_events"),
+			false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			st := test.restrictions.CheckTracepoint(test.tracepoint)
+			if test.allowed {
+				assert.Nil(t, st)
+				return
+			}
+			require.NotNil(t, st)
+			assert.Equal(t, statuspb.PERMISSION_DENIED, st.ErrCode)
+		})
+	}
+}
+
+func TestCheckConfigUpdate(t *testing.T) {
+	var e *policy.Effective
+	assert.Nil(t, e.CheckConfigUpdate())
+
+	e = &policy.Effective{DenyTables: map[string]string{"mysql_events": "no-mysql"}}
+	assert.Nil(t, e.CheckConfigUpdate())
+
+	e = &policy.Effective{RestrictNamespaces: true, Namespaces: []string{"team-a"}, NamespacePolicy: "team-a-only"}
+	st := e.CheckConfigUpdate()
+	require.NotNil(t, st)
+	assert.Equal(t, statuspb.PERMISSION_DENIED, st.ErrCode)
+}
@@ -0,0 +1,208 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+// Package policy restricts the data callers can query, based on the claims of their JWT.
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"px.dev/pixie/src/shared/services/jwtpb"
+)
+
+// Policy restricts the data that a set of callers can access.
+type Policy struct {
+	Name string `json:"name"`
+	// The callers the policy applies to. A policy without subjects applies to every caller.
+	Subjects Subjects `json:"subjects,omitempty"`
+	// Tables that can't be queried.
+	DenyTables []string `json:"denyTables,omitempty"`
+	// Restricts rows to processes running in these namespaces. Empty means all namespaces.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Columns whose values are replaced, by table name.
+	RedactColumns map[string][]string `json:"redactColumns,omitempty"`
+}
+
+// Subjects selects callers by the claims of their JWT. A caller matches if any of the values match.
+type Subjects struct {
+	OrgIDs  []string `json:"orgIDs,omitempty"`
+	UserIDs []string `json:"userIDs,omitempty"`
+	// Emails may start with "*@" to match every email of a domain.
+	Emails     []string `json:"emails,omitempty"`
+	ServiceIDs []string `json:"serviceIDs,omitempty"`
+}
+
+func (s Subjects) empty() bool {
+	return len(s.OrgIDs) == 0 && len(s.UserIDs) == 0 && len(s.Emails) == 0 && len(s.ServiceIDs) == 0
+}
+
+func contains(vals []string, v string) bool {
+	if v == "" {
+		return false
+	}
+	for _, val := range vals {
+		if val == v {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesEmail(patterns []string, email string) bool {
+	if email == "" {
+		return false
+	}
+	email = strings.ToLower(email)
+	for _, p := range patterns {
+		p = strings.ToLower(p)
+		if strings.HasPrefix(p, "*@") {
+			if strings.HasSuffix(email, p[1:]) {
+				return true
+			}
+		} else if p == email {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches returns whether the policy applies to the caller with the given claims.
+func (p *Policy) Matches(claims *jwtpb.JWTClaims) bool {
+	if p.Subjects.empty() {
+		return true
+	}
+	return p.Subjects.Match(claims)
+}
+
+// Match returns whether the caller with the given claims is one of the subjects. No caller
+// matches an empty set of subjects.
+func (s Subjects) Match(claims *jwtpb.JWTClaims) bool {
+	if claims == nil {
+		return false
+	}
+	if uc := claims.GetUserClaims(); uc != nil {
+		if contains(s.OrgIDs, uc.OrgID) || contains(s.UserIDs, uc.UserID) ||
+			matchesEmail(s.Emails, uc.Email) {
+			return true
+		}
+	}
+	if sc := claims.GetServiceClaims(); sc != nil {
+		if contains(s.ServiceIDs, sc.ServiceID) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that the policies are well formed.
+func Validate(policies []Policy) error {
+	names := make(map[string]bool)
+	for _, p := range policies {
+		if p.Name == "" {
+			return fmt.Errorf("policy is missing a name")
+		}
+		if names[p.Name] {
+			return fmt.Errorf("duplicate policy '%s'", p.Name)
+		}
+		names[p.Name] = true
+		for table, cols := range p.RedactColumns {
+			if len(cols) == 0 {
+				return fmt.Errorf("policy '%s' redacts no columns of table '%s'", p.Name, table)
+			}
+		}
+	}
+	return nil
+}
+
+// Effective is the combination of all policies that apply to a caller.
+type Effective struct {
+	// The names of the policies that apply, sorted.
+	Policies []string
+	// Denied tables, mapped to the policy that denies them.
+	DenyTables map[string]string
+	// Whether rows are restricted to Namespaces.
+	RestrictNamespaces bool
+	// The namespaces rows are restricted to, allowed by all policies.
+	Namespaces []string
+	// The policy which restricts namespaces, for error messages.
+	NamespacePolicy string
+	// Redacted columns, by table.
+	RedactColumns map[string]map[string]bool
+}
+
+// Resolve combines the policies that apply to the caller with the given claims. Denied tables and
+// redacted columns of all policies apply, and rows are restricted to namespaces allowed by every policy.
+func Resolve(policies []Policy, claims *jwtpb.JWTClaims) *Effective {
+	e := &Effective{
+		DenyTables:    make(map[string]string),
+		RedactColumns: make(map[string]map[string]bool),
+	}
+	for i := range policies {
+		p := &policies[i]
+		if !p.Matches(claims) {
+			continue
+		}
+		e.Policies = append(e.Policies, p.Name)
+		for _, t := range p.DenyTables {
+			if _, ok := e.DenyTables[t]; !ok {
+				e.DenyTables[t] = p.Name
+			}
+		}
+		for t, cols := range p.RedactColumns {
+			if _, ok := e.RedactColumns[t]; !ok {
+				e.RedactColumns[t] = make(map[string]bool)
+			}
+			for _, c := range cols {
+				e.RedactColumns[t][c] = true
+			}
+		}
+		if len(p.Namespaces) > 0 {
+			if !e.RestrictNamespaces {
+				e.RestrictNamespaces = true
+				e.NamespacePolicy = p.Name
+				e.Namespaces = append([]string{}, p.Namespaces...)
+			} else {
+				var allowed []string
+				for _, ns := range e.Namespaces {
+					if contains(p.Namespaces, ns) {
+						allowed = append(allowed, ns)
+					}
+				}
+				e.Namespaces = allowed
+			}
+		}
+	}
+	sort.Strings(e.Policies)
+	sort.Strings(e.Namespaces)
+	return e
+}
+
+// Empty returns whether no restrictions apply.
+func (e *Effective) Empty() bool {
+	return e == nil || (len(e.DenyTables) == 0 && !e.RestrictNamespaces && len(e.RedactColumns) == 0)
+}
+
+// Key identifies the restrictions, so that callers with different restrictions don't share results.
+func (e *Effective) Key() string {
+	if e.Empty() {
+		return ""
+	}
+	return strings.Join(e.Policies, ",")
+}
@@ -0,0 +1,155 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package policy_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/shared/services/jwtpb"
+	"px.dev/pixie/src/vizier/services/query_broker/policy"
+)
+
+const testPolicies = `
+policies:
+- name: everyone
+  redactColumns:
+    http_events: [req_body, resp_body]
+- name: contractors
+  subjects:
+    emails: ["*@contractor.com"]
+  denyTables: [mysql_events]
+  namespaces: [default, staging]
+- name: staging-only
+  subjects:
+    orgIDs: [org2]
+  namespaces: [staging, prod]
+`
+
+func userClaims(orgID, userID, email string) *jwtpb.JWTClaims {
+	return &jwtpb.JWTClaims{
+		Subject: userID,
+		CustomClaims: &jwtpb.JWTClaims_UserClaims{
+			UserClaims: &jwtpb.UserJWTClaims{
+				UserID: userID,
+				OrgID:  orgID,
+				Email:  email,
+			},
+		},
+	}
+}
+
+func TestParse(t *testing.T) {
+	policies, err := policy.Parse([]byte(testPolicies))
+	require.NoError(t, err)
+	require.Len(t, policies, 3)
+	assert.Equal(t, []string{"req_body", "resp_body"}, policies[0].RedactColumns["http_events"])
+	assert.Equal(t, []string{"*@contractor.com"}, policies[1].Subjects.Emails)
+
+	_, err = policy.Parse([]byte("policies:\n- denyTables: [a]\n"))
+	assert.Error(t, err)
+	_, err = policy.Parse([]byte("policies:\n- name: a\n- name: a\n"))
+	assert.Error(t, err)
+	_, err = policy.Parse([]byte("policies:\n- name: a\n  denyTable: [a]\n"))
+	assert.Error(t, err)
+}
+
+func TestPolicy_Matches(t *testing.T) {
+	p := policy.Policy{
+		Name: "p",
+		Subjects: policy.Subjects{
+			OrgIDs:     []string{"org1"},
+			Emails:     []string{"admin@pixielabs.ai", "*@contractor.com"},
+			ServiceIDs: []string{"cron"},
+		},
+	}
+	assert.True(t, p.Matches(userClaims("org1", "u", "")))
+	assert.True(t, p.Matches(userClaims("org2", "u", "Admin@pixielabs.ai")))
+	assert.True(t, p.Matches(userClaims("org2", "u", "someone@contractor.com")))
+	assert.False(t, p.Matches(userClaims("org2", "u", "someone@pixielabs.ai")))
+	assert.False(t, p.Matches(nil))
+	assert.True(t, p.Matches(&jwtpb.JWTClaims{
+		CustomClaims: &jwtpb.JWTClaims_ServiceClaims{ServiceClaims: &jwtpb.ServiceJWTClaims{ServiceID: "cron"}},
+	}))
+
+	// Policies without subjects apply to everyone.
+	assert.True(t, (&policy.Policy{Name: "all"}).Matches(nil))
+}
+
+func TestResolve(t *testing.T) {
+	policies, err := policy.Parse([]byte(testPolicies))
+	require.NoError(t, err)
+
+	e := policy.Resolve(policies, userClaims("org1", "u", "a@pixielabs.ai"))
+	assert.Equal(t, []string{"everyone"}, e.Policies)
+	assert.False(t, e.RestrictNamespaces)
+	assert.Empty(t, e.DenyTables)
+	assert.True(t, e.RedactColumns["http_events"]["req_body"])
+
+	e = policy.Resolve(policies, userClaims("org1", "u", "a@contractor.com"))
+	assert.Equal(t, []string{"contractors", "everyone"}, e.Policies)
+	assert.Equal(t, "contractors", e.DenyTables["mysql_events"])
+	assert.True(t, e.RestrictNamespaces)
+	assert.Equal(t, []string{"default", "staging"}, e.Namespaces)
+
+	// Namespaces are restricted by every matching policy.
+	e = policy.Resolve(policies, userClaims("org2", "u", "a@contractor.com"))
+	assert.Equal(t, []string{"staging"}, e.Namespaces)
+	assert.Equal(t, "contractors,everyone,staging-only", e.Key())
+
+	assert.True(t, policy.Resolve(nil, nil).Empty())
+	assert.Equal(t, "", policy.Resolve(nil, nil).Key())
+}
+
+func TestStore_LoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.yaml")
+	s := policy.NewStore(nil)
+
+	// A missing file means no policies.
+	require.NoError(t, s.LoadFile(path))
+	assert.True(t, s.Resolve(nil).Empty())
+
+	require.NoError(t, os.WriteFile(path, []byte(testPolicies), 0600))
+	require.NoError(t, s.LoadFile(path))
+	assert.Equal(t, []string{"everyone"}, s.Resolve(nil).Policies)
+
+	// Invalid files are rejected and the previous policies are kept.
+	require.NoError(t, os.WriteFile(path, []byte("policies: [{}]"), 0600))
+	assert.Error(t, s.LoadFile(path))
+	assert.Equal(t, []string{"everyone"}, s.Resolve(nil).Policies)
+}
+
+func TestStore_WatchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.yaml")
+	s := policy.NewStore(nil)
+
+	done := make(chan struct{})
+	defer close(done)
+	go s.WatchFile(path, time.Millisecond, done)
+
+	require.NoError(t, os.WriteFile(path, []byte(testPolicies), 0600))
+	assert.Eventually(t, func() bool {
+		return !s.Resolve(nil).Empty()
+	}, 5*time.Second, time.Millisecond)
+}
@@ -0,0 +1,119 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+
+	"px.dev/pixie/src/shared/services/jwtpb"
+)
+
+// Config is the format of the policy file, as stored in the access policy ConfigMap.
+type Config struct {
+	Policies []Policy `json:"policies"`
+}
+
+// Parse parses and validates a policy file.
+func Parse(b []byte) ([]Policy, error) {
+	var c Config
+	if err := yaml.UnmarshalStrict(b, &c); err != nil {
+		return nil, err
+	}
+	if err := Validate(c.Policies); err != nil {
+		return nil, err
+	}
+	return c.Policies, nil
+}
+
+// Store holds the current policies.
+type Store struct {
+	mu       sync.RWMutex
+	policies []Policy
+}
+
+// NewStore creates a store with the given policies.
+func NewStore(policies []Policy) *Store {
+	return &Store{policies: policies}
+}
+
+// Set replaces the policies.
+func (s *Store) Set(policies []Policy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policies = policies
+}
+
+// Resolve returns the restrictions that apply to the caller with the given claims.
+func (s *Store) Resolve(claims *jwtpb.JWTClaims) *Effective {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return Resolve(s.policies, claims)
+}
+
+// LoadFile loads the policies from the file at path. A missing file means there are no policies,
+// since the ConfigMap is optional.
+func (s *Store) LoadFile(path string) error {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.Set(nil)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	policies, err := Parse(b)
+	if err != nil {
+		return err
+	}
+	s.Set(policies)
+	return nil
+}
+
+// WatchFile reloads the policies whenever the file at path changes, until done is closed.
+// Kubernetes updates mounted ConfigMaps in place, so the file is polled. If the file is
+// invalid, the previous policies are kept.
+func (s *Store) WatchFile(path string, interval time.Duration, done <-chan struct{}) {
+	var prev []byte
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-t.C:
+			b, _ := os.ReadFile(path)
+			if bytes.Equal(b, prev) {
+				continue
+			}
+			prev = b
+			if err := s.LoadFile(path); err != nil {
+				log.WithError(err).WithField("path", path).Error("Failed to reload access policies, keeping the previous policies")
+				continue
+			}
+			log.WithField("path", path).Info("Reloaded access policies")
+		}
+	}
+}
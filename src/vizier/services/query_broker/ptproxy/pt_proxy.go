@@ -53,13 +53,15 @@ type RequestState struct {
 // PassThroughProxy listens to NATS for any stream API requests and makes the necessary grpc
 // requests to downstream services.
 type PassThroughProxy struct {
-	vzClient vizierpb.VizierServiceClient
-	nc       *nats.Conn
-	requests map[string]*RequestState
-	mu       sync.Mutex // Mutex for requests map.
-	subCh    chan *nats.Msg
-	sub      *nats.Subscription
-	quitCh   chan bool
+	vzClient    vizierpb.VizierServiceClient
+	schedClient vizierpb.VizierScheduledScriptServiceClient
+	tpClient    vizierpb.VizierTracepointServiceClient
+	nc          *nats.Conn
+	requests    map[string]*RequestState
+	mu          sync.Mutex // Mutex for requests map.
+	subCh       chan *nats.Msg
+	sub         *nats.Subscription
+	quitCh      chan bool
 }
 
 // Stream is a wrapper around a GRPC stream.
@@ -69,7 +71,9 @@ type Stream interface {
 }
 
 // NewPassThroughProxy creates a new stream API listener.
-func NewPassThroughProxy(nc *nats.Conn, vzClient vizierpb.VizierServiceClient) (*PassThroughProxy, error) {
+func NewPassThroughProxy(nc *nats.Conn, vzClient vizierpb.VizierServiceClient,
+	schedClient vizierpb.VizierScheduledScriptServiceClient,
+	tpClient vizierpb.VizierTracepointServiceClient) (*PassThroughProxy, error) {
 	requests := make(map[string]*RequestState)
 	quitCh := make(chan bool)
 	// Buffer channel so we don't drop passthrough requests.
@@ -78,7 +82,16 @@ func NewPassThroughProxy(nc *nats.Conn, vzClient vizierpb.VizierServiceClient) (
 	if err != nil {
 		return nil, err
 	}
-	return &PassThroughProxy{nc: nc, requests: requests, quitCh: quitCh, vzClient: vzClient, subCh: subCh, sub: sub}, nil
+	return &PassThroughProxy{
+		nc:          nc,
+		requests:    requests,
+		quitCh:      quitCh,
+		vzClient:    vzClient,
+		schedClient: schedClient,
+		tpClient:    tpClient,
+		subCh:       subCh,
+		sub:         sub,
+	}, nil
 }
 
 // Run starts the stream listener.
@@ -181,6 +194,22 @@ func (s *PassThroughProxy) runRequest(reqState *RequestState, msg *cvmsgspb.C2VA
 		stream = NewExecuteScriptStream(s.vzClient)
 	case *cvmsgspb.C2VAPIStreamRequest_HcReq:
 		stream = NewHealthCheckStream(s.vzClient)
+	case *cvmsgspb.C2VAPIStreamRequest_UpsertScheduledScriptReq:
+		stream = NewUpsertScheduledScriptStream(s.schedClient)
+	case *cvmsgspb.C2VAPIStreamRequest_ListScheduledScriptsReq:
+		stream = NewListScheduledScriptsStream(s.schedClient)
+	case *cvmsgspb.C2VAPIStreamRequest_DeleteScheduledScriptReq:
+		stream = NewDeleteScheduledScriptStream(s.schedClient)
+	case *cvmsgspb.C2VAPIStreamRequest_GetScheduledScriptResultsReq:
+		stream = NewScheduledScriptResultsStream(s.schedClient)
+	case *cvmsgspb.C2VAPIStreamRequest_ListTracepointsReq:
+		stream = NewListTracepointsStream(s.tpClient)
+	case *cvmsgspb.C2VAPIStreamRequest_GetTracepointReq:
+		stream = NewGetTracepointStream(s.tpClient)
+	case *cvmsgspb.C2VAPIStreamRequest_DeleteTracepointsReq:
+		stream = NewDeleteTracepointsStream(s.tpClient)
+	case *cvmsgspb.C2VAPIStreamRequest_ExtendTracepointsReq:
+		stream = NewExtendTracepointsStream(s.tpClient)
 	default:
 		log.Error("Unhandled message type")
 		return
@@ -373,3 +402,300 @@ func (e *HealthCheckStream) Recv() (*cvmsgspb.V2CAPIStreamResponse, error) {
 
 	return resp, nil
 }
+
+// UpsertScheduledScriptStream is a wrapper around the UpsertScheduledScript stream.
+type UpsertScheduledScriptStream struct {
+	schedClient vizierpb.VizierScheduledScriptServiceClient
+	stream      vizierpb.VizierScheduledScriptService_UpsertScheduledScriptClient
+	reqID       string
+}
+
+// NewUpsertScheduledScriptStream creates a new UpsertScheduledScriptStream.
+func NewUpsertScheduledScriptStream(schedClient vizierpb.VizierScheduledScriptServiceClient) *UpsertScheduledScriptStream {
+	return &UpsertScheduledScriptStream{schedClient: schedClient}
+}
+
+// StartStream starts the UpsertScheduledScript stream with the given request.
+func (e *UpsertScheduledScriptStream) StartStream(ctx context.Context, reqID string, req *cvmsgspb.C2VAPIStreamRequest) error {
+	e.reqID = reqID
+	stream, err := e.schedClient.UpsertScheduledScript(ctx, req.GetUpsertScheduledScriptReq())
+	if err != nil {
+		return err
+	}
+	e.stream = stream
+	return nil
+}
+
+// Recv gets the next message on the stream.
+func (e *UpsertScheduledScriptStream) Recv() (*cvmsgspb.V2CAPIStreamResponse, error) {
+	msg, err := e.stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	return &cvmsgspb.V2CAPIStreamResponse{
+		RequestID: e.reqID,
+		Msg: &cvmsgspb.V2CAPIStreamResponse_UpsertScheduledScriptResp{
+			UpsertScheduledScriptResp: msg,
+		},
+	}, nil
+}
+
+// ListScheduledScriptsStream is a wrapper around the ListScheduledScripts stream.
+type ListScheduledScriptsStream struct {
+	schedClient vizierpb.VizierScheduledScriptServiceClient
+	stream      vizierpb.VizierScheduledScriptService_ListScheduledScriptsClient
+	reqID       string
+}
+
+// NewListScheduledScriptsStream creates a new ListScheduledScriptsStream.
+func NewListScheduledScriptsStream(schedClient vizierpb.VizierScheduledScriptServiceClient) *ListScheduledScriptsStream {
+	return &ListScheduledScriptsStream{schedClient: schedClient}
+}
+
+// StartStream starts the ListScheduledScripts stream with the given request.
+func (e *ListScheduledScriptsStream) StartStream(ctx context.Context, reqID string, req *cvmsgspb.C2VAPIStreamRequest) error {
+	e.reqID = reqID
+	stream, err := e.schedClient.ListScheduledScripts(ctx, req.GetListScheduledScriptsReq())
+	if err != nil {
+		return err
+	}
+	e.stream = stream
+	return nil
+}
+
+// Recv gets the next message on the stream.
+func (e *ListScheduledScriptsStream) Recv() (*cvmsgspb.V2CAPIStreamResponse, error) {
+	msg, err := e.stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	return &cvmsgspb.V2CAPIStreamResponse{
+		RequestID: e.reqID,
+		Msg: &cvmsgspb.V2CAPIStreamResponse_ListScheduledScriptsResp{
+			ListScheduledScriptsResp: msg,
+		},
+	}, nil
+}
+
+// DeleteScheduledScriptStream is a wrapper around the DeleteScheduledScript stream.
+type DeleteScheduledScriptStream struct {
+	schedClient vizierpb.VizierScheduledScriptServiceClient
+	stream      vizierpb.VizierScheduledScriptService_DeleteScheduledScriptClient
+	reqID       string
+}
+
+// NewDeleteScheduledScriptStream creates a new DeleteScheduledScriptStream.
+func NewDeleteScheduledScriptStream(schedClient vizierpb.VizierScheduledScriptServiceClient) *DeleteScheduledScriptStream {
+	return &DeleteScheduledScriptStream{schedClient: schedClient}
+}
+
+// StartStream starts the DeleteScheduledScript stream with the given request.
+func (e *DeleteScheduledScriptStream) StartStream(ctx context.Context, reqID string, req *cvmsgspb.C2VAPIStreamRequest) error {
+	e.reqID = reqID
+	stream, err := e.schedClient.DeleteScheduledScript(ctx, req.GetDeleteScheduledScriptReq())
+	if err != nil {
+		return err
+	}
+	e.stream = stream
+	return nil
+}
+
+// Recv gets the next message on the stream.
+func (e *DeleteScheduledScriptStream) Recv() (*cvmsgspb.V2CAPIStreamResponse, error) {
+	msg, err := e.stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	return &cvmsgspb.V2CAPIStreamResponse{
+		RequestID: e.reqID,
+		Msg: &cvmsgspb.V2CAPIStreamResponse_DeleteScheduledScriptResp{
+			DeleteScheduledScriptResp: msg,
+		},
+	}, nil
+}
+
+// ScheduledScriptResultsStream is a wrapper around the GetScheduledScriptResults stream.
+type ScheduledScriptResultsStream struct {
+	schedClient vizierpb.VizierScheduledScriptServiceClient
+	stream      vizierpb.VizierScheduledScriptService_GetScheduledScriptResultsClient
+	reqID       string
+}
+
+// NewScheduledScriptResultsStream creates a new ScheduledScriptResultsStream.
+func NewScheduledScriptResultsStream(schedClient vizierpb.VizierScheduledScriptServiceClient) *ScheduledScriptResultsStream {
+	return &ScheduledScriptResultsStream{schedClient: schedClient}
+}
+
+// StartStream starts the GetScheduledScriptResults stream with the given request.
+func (e *ScheduledScriptResultsStream) StartStream(ctx context.Context, reqID string, req *cvmsgspb.C2VAPIStreamRequest) error {
+	e.reqID = reqID
+	stream, err := e.schedClient.GetScheduledScriptResults(ctx, req.GetGetScheduledScriptResultsReq())
+	if err != nil {
+		return err
+	}
+	e.stream = stream
+	return nil
+}
+
+// Recv gets the next message on the stream. The stored results are sent in the same form as
+// the results of ExecuteScript.
+func (e *ScheduledScriptResultsStream) Recv() (*cvmsgspb.V2CAPIStreamResponse, error) {
+	msg, err := e.stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	return &cvmsgspb.V2CAPIStreamResponse{
+		RequestID: e.reqID,
+		Msg: &cvmsgspb.V2CAPIStreamResponse_ExecResp{
+			ExecResp: msg,
+		},
+	}, nil
+}
+
+// ListTracepointsStream is a wrapper around the ListTracepoints stream.
+type ListTracepointsStream struct {
+	tpClient vizierpb.VizierTracepointServiceClient
+	stream   vizierpb.VizierTracepointService_ListTracepointsClient
+	reqID    string
+}
+
+// NewListTracepointsStream creates a new ListTracepointsStream.
+func NewListTracepointsStream(tpClient vizierpb.VizierTracepointServiceClient) *ListTracepointsStream {
+	return &ListTracepointsStream{tpClient: tpClient}
+}
+
+// StartStream starts the ListTracepoints stream with the given request.
+func (e *ListTracepointsStream) StartStream(ctx context.Context, reqID string, req *cvmsgspb.C2VAPIStreamRequest) error {
+	e.reqID = reqID
+	stream, err := e.tpClient.ListTracepoints(ctx, req.GetListTracepointsReq())
+	if err != nil {
+		return err
+	}
+	e.stream = stream
+	return nil
+}
+
+// Recv gets the next message on the stream.
+func (e *ListTracepointsStream) Recv() (*cvmsgspb.V2CAPIStreamResponse, error) {
+	msg, err := e.stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	return &cvmsgspb.V2CAPIStreamResponse{
+		RequestID: e.reqID,
+		Msg: &cvmsgspb.V2CAPIStreamResponse_ListTracepointsResp{
+			ListTracepointsResp: msg,
+		},
+	}, nil
+}
+
+// GetTracepointStream is a wrapper around the GetTracepoint stream.
+type GetTracepointStream struct {
+	tpClient vizierpb.VizierTracepointServiceClient
+	stream   vizierpb.VizierTracepointService_GetTracepointClient
+	reqID    string
+}
+
+// NewGetTracepointStream creates a new GetTracepointStream.
+func NewGetTracepointStream(tpClient vizierpb.VizierTracepointServiceClient) *GetTracepointStream {
+	return &GetTracepointStream{tpClient: tpClient}
+}
+
+// StartStream starts the GetTracepoint stream with the given request.
+func (e *GetTracepointStream) StartStream(ctx context.Context, reqID string, req *cvmsgspb.C2VAPIStreamRequest) error {
+	e.reqID = reqID
+	stream, err := e.tpClient.GetTracepoint(ctx, req.GetGetTracepointReq())
+	if err != nil {
+		return err
+	}
+	e.stream = stream
+	return nil
+}
+
+// Recv gets the next message on the stream.
+func (e *GetTracepointStream) Recv() (*cvmsgspb.V2CAPIStreamResponse, error) {
+	msg, err := e.stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	return &cvmsgspb.V2CAPIStreamResponse{
+		RequestID: e.reqID,
+		Msg: &cvmsgspb.V2CAPIStreamResponse_GetTracepointResp{
+			GetTracepointResp: msg,
+		},
+	}, nil
+}
+
+// DeleteTracepointsStream is a wrapper around the DeleteTracepoints stream.
+type DeleteTracepointsStream struct {
+	tpClient vizierpb.VizierTracepointServiceClient
+	stream   vizierpb.VizierTracepointService_DeleteTracepointsClient
+	reqID    string
+}
+
+// NewDeleteTracepointsStream creates a new DeleteTracepointsStream.
+func NewDeleteTracepointsStream(tpClient vizierpb.VizierTracepointServiceClient) *DeleteTracepointsStream {
+	return &DeleteTracepointsStream{tpClient: tpClient}
+}
+
+// StartStream starts the DeleteTracepoints stream with the given request.
+func (e *DeleteTracepointsStream) StartStream(ctx context.Context, reqID string, req *cvmsgspb.C2VAPIStreamRequest) error {
+	e.reqID = reqID
+	stream, err := e.tpClient.DeleteTracepoints(ctx, req.GetDeleteTracepointsReq())
+	if err != nil {
+		return err
+	}
+	e.stream = stream
+	return nil
+}
+
+// Recv gets the next message on the stream.
+func (e *DeleteTracepointsStream) Recv() (*cvmsgspb.V2CAPIStreamResponse, error) {
+	msg, err := e.stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	return &cvmsgspb.V2CAPIStreamResponse{
+		RequestID: e.reqID,
+		Msg: &cvmsgspb.V2CAPIStreamResponse_DeleteTracepointsResp{
+			DeleteTracepointsResp: msg,
+		},
+	}, nil
+}
+
+// ExtendTracepointsStream is a wrapper around the ExtendTracepoints stream.
+type ExtendTracepointsStream struct {
+	tpClient vizierpb.VizierTracepointServiceClient
+	stream   vizierpb.VizierTracepointService_ExtendTracepointsClient
+	reqID    string
+}
+
+// NewExtendTracepointsStream creates a new ExtendTracepointsStream.
+func NewExtendTracepointsStream(tpClient vizierpb.VizierTracepointServiceClient) *ExtendTracepointsStream {
+	return &ExtendTracepointsStream{tpClient: tpClient}
+}
+
+// StartStream starts the ExtendTracepoints stream with the given request.
+func (e *ExtendTracepointsStream) StartStream(ctx context.Context, reqID string, req *cvmsgspb.C2VAPIStreamRequest) error {
+	e.reqID = reqID
+	stream, err := e.tpClient.ExtendTracepoints(ctx, req.GetExtendTracepointsReq())
+	if err != nil {
+		return err
+	}
+	e.stream = stream
+	return nil
+}
+
+// Recv gets the next message on the stream.
+func (e *ExtendTracepointsStream) Recv() (*cvmsgspb.V2CAPIStreamResponse, error) {
+	msg, err := e.stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	return &cvmsgspb.V2CAPIStreamResponse{
+		RequestID: e.reqID,
+		Msg: &cvmsgspb.V2CAPIStreamResponse_ExtendTracepointsResp{
+			ExtendTracepointsResp: msg,
+		},
+	}, nil
+}
@@ -89,6 +89,48 @@ func (m *MockVzServer) HealthCheck(req *vizierpb.HealthCheckRequest, srv vizierp
 	return nil
 }
 
+type MockSchedServer struct{}
+
+func (m *MockSchedServer) UpsertScheduledScript(req *vizierpb.UpsertScheduledScriptRequest, srv vizierpb.VizierScheduledScriptService_UpsertScheduledScriptServer) error {
+	return srv.Send(&vizierpb.UpsertScheduledScriptResponse{ID: "script1"})
+}
+
+func (m *MockSchedServer) ListScheduledScripts(req *vizierpb.ListScheduledScriptsRequest, srv vizierpb.VizierScheduledScriptService_ListScheduledScriptsServer) error {
+	return srv.Send(&vizierpb.ListScheduledScriptsResponse{
+		Scripts: []*vizierpb.ScheduledScript{{ID: "script1", Name: "errors", CronExpression: "@hourly"}},
+	})
+}
+
+func (m *MockSchedServer) DeleteScheduledScript(req *vizierpb.DeleteScheduledScriptRequest, srv vizierpb.VizierScheduledScriptService_DeleteScheduledScriptServer) error {
+	return srv.Send(&vizierpb.DeleteScheduledScriptResponse{})
+}
+
+func (m *MockSchedServer) GetScheduledScriptResults(req *vizierpb.GetScheduledScriptResultsRequest, srv vizierpb.VizierScheduledScriptService_GetScheduledScriptResultsServer) error {
+	return srv.Send(&vizierpb.ExecuteScriptResponse{QueryID: req.RunID})
+}
+
+type MockTracepointServer struct{}
+
+func (m *MockTracepointServer) ListTracepoints(req *vizierpb.ListTracepointsRequest, srv vizierpb.VizierTracepointService_ListTracepointsServer) error {
+	return srv.Send(&vizierpb.ListTracepointsResponse{
+		Tracepoints: []*vizierpb.Tracepoint{{ID: "tp1", Name: "http_probe", State: vizierpb.RUNNING_STATE}},
+	})
+}
+
+func (m *MockTracepointServer) GetTracepoint(req *vizierpb.GetTracepointRequest, srv vizierpb.VizierTracepointService_GetTracepointServer) error {
+	return srv.Send(&vizierpb.GetTracepointResponse{
+		Tracepoint: &vizierpb.Tracepoint{ID: "tp1", Name: req.Name, State: vizierpb.FAILED_STATE},
+	})
+}
+
+func (m *MockTracepointServer) DeleteTracepoints(req *vizierpb.DeleteTracepointsRequest, srv vizierpb.VizierTracepointService_DeleteTracepointsServer) error {
+	return srv.Send(&vizierpb.DeleteTracepointsResponse{})
+}
+
+func (m *MockTracepointServer) ExtendTracepoints(req *vizierpb.ExtendTracepointsRequest, srv vizierpb.VizierTracepointService_ExtendTracepointsServer) error {
+	return srv.Send(&vizierpb.ExtendTracepointsResponse{})
+}
+
 type testState struct {
 	t        *testing.T
 	lis      *bufconn.Listener
@@ -105,6 +147,8 @@ func createTestState(t *testing.T) (*testState, func(t *testing.T)) {
 	nc, natsCleanup := testingutils.MustStartTestNATS(t)
 	vzServer := NewMockVzServer(t)
 	vizierpb.RegisterVizierServiceServer(s, vzServer)
+	vizierpb.RegisterVizierScheduledScriptServiceServer(s, &MockSchedServer{})
+	vizierpb.RegisterVizierTracepointServiceServer(s, &MockTracepointServer{})
 
 	eg := errgroup.Group{}
 	eg.Go(func() error { return s.Serve(lis) })
@@ -227,8 +271,10 @@ func TestPassThroughProxy(t *testing.T) {
 			defer cleanup(t)
 
 			client := vizierpb.NewVizierServiceClient(ts.conn)
+			schedClient := vizierpb.NewVizierScheduledScriptServiceClient(ts.conn)
+			tpClient := vizierpb.NewVizierTracepointServiceClient(ts.conn)
 
-			s, err := ptproxy.NewPassThroughProxy(ts.nc, client)
+			s, err := ptproxy.NewPassThroughProxy(ts.nc, client, schedClient, tpClient)
 			require.NoError(t, err)
 			go func() {
 				err := s.Run()
@@ -326,3 +372,201 @@ func TestPassThroughProxy(t *testing.T) {
 		})
 	}
 }
+
+func TestPassThroughProxy_ScheduledScripts(t *testing.T) {
+	tests := []struct {
+		name         string
+		request      *cvmsgspb.C2VAPIStreamRequest
+		expectedResp *cvmsgspb.V2CAPIStreamResponse
+	}{
+		{
+			name: "upsert",
+			request: &cvmsgspb.C2VAPIStreamRequest{
+				Msg: &cvmsgspb.C2VAPIStreamRequest_UpsertScheduledScriptReq{
+					UpsertScheduledScriptReq: &vizierpb.UpsertScheduledScriptRequest{},
+				},
+			},
+			expectedResp: &cvmsgspb.V2CAPIStreamResponse{
+				Msg: &cvmsgspb.V2CAPIStreamResponse_UpsertScheduledScriptResp{
+					UpsertScheduledScriptResp: &vizierpb.UpsertScheduledScriptResponse{ID: "script1"},
+				},
+			},
+		},
+		{
+			name: "list",
+			request: &cvmsgspb.C2VAPIStreamRequest{
+				Msg: &cvmsgspb.C2VAPIStreamRequest_ListScheduledScriptsReq{
+					ListScheduledScriptsReq: &vizierpb.ListScheduledScriptsRequest{},
+				},
+			},
+			expectedResp: &cvmsgspb.V2CAPIStreamResponse{
+				Msg: &cvmsgspb.V2CAPIStreamResponse_ListScheduledScriptsResp{
+					ListScheduledScriptsResp: &vizierpb.ListScheduledScriptsResponse{
+						Scripts: []*vizierpb.ScheduledScript{{ID: "script1", Name: "errors", CronExpression: "@hourly"}},
+					},
+				},
+			},
+		},
+		{
+			name: "delete",
+			request: &cvmsgspb.C2VAPIStreamRequest{
+				Msg: &cvmsgspb.C2VAPIStreamRequest_DeleteScheduledScriptReq{
+					DeleteScheduledScriptReq: &vizierpb.DeleteScheduledScriptRequest{ID: "script1"},
+				},
+			},
+			expectedResp: &cvmsgspb.V2CAPIStreamResponse{
+				Msg: &cvmsgspb.V2CAPIStreamResponse_DeleteScheduledScriptResp{
+					DeleteScheduledScriptResp: &vizierpb.DeleteScheduledScriptResponse{},
+				},
+			},
+		},
+		{
+			name: "results",
+			request: &cvmsgspb.C2VAPIStreamRequest{
+				Msg: &cvmsgspb.C2VAPIStreamRequest_GetScheduledScriptResultsReq{
+					GetScheduledScriptResultsReq: &vizierpb.GetScheduledScriptResultsRequest{ScriptID: "script1", RunID: "run1"},
+				},
+			},
+			expectedResp: &cvmsgspb.V2CAPIStreamResponse{
+				Msg: &cvmsgspb.V2CAPIStreamResponse_ExecResp{
+					ExecResp: &vizierpb.ExecuteScriptResponse{QueryID: "run1"},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testPassthroughRequest(t, test.name, test.request, test.expectedResp)
+		})
+	}
+}
+
+func TestPassThroughProxy_Tracepoints(t *testing.T) {
+	tests := []struct {
+		name         string
+		request      *cvmsgspb.C2VAPIStreamRequest
+		expectedResp *cvmsgspb.V2CAPIStreamResponse
+	}{
+		{
+			name: "list",
+			request: &cvmsgspb.C2VAPIStreamRequest{
+				Msg: &cvmsgspb.C2VAPIStreamRequest_ListTracepointsReq{
+					ListTracepointsReq: &vizierpb.ListTracepointsRequest{},
+				},
+			},
+			expectedResp: &cvmsgspb.V2CAPIStreamResponse{
+				Msg: &cvmsgspb.V2CAPIStreamResponse_ListTracepointsResp{
+					ListTracepointsResp: &vizierpb.ListTracepointsResponse{
+						Tracepoints: []*vizierpb.Tracepoint{{ID: "tp1", Name: "http_probe", State: vizierpb.RUNNING_STATE}},
+					},
+				},
+			},
+		},
+		{
+			name: "get",
+			request: &cvmsgspb.C2VAPIStreamRequest{
+				Msg: &cvmsgspb.C2VAPIStreamRequest_GetTracepointReq{
+					GetTracepointReq: &vizierpb.GetTracepointRequest{Name: "http_probe"},
+				},
+			},
+			expectedResp: &cvmsgspb.V2CAPIStreamResponse{
+				Msg: &cvmsgspb.V2CAPIStreamResponse_GetTracepointResp{
+					GetTracepointResp: &vizierpb.GetTracepointResponse{
+						Tracepoint: &vizierpb.Tracepoint{ID: "tp1", Name: "http_probe", State: vizierpb.FAILED_STATE},
+					},
+				},
+			},
+		},
+		{
+			name: "delete",
+			request: &cvmsgspb.C2VAPIStreamRequest{
+				Msg: &cvmsgspb.C2VAPIStreamRequest_DeleteTracepointsReq{
+					DeleteTracepointsReq: &vizierpb.DeleteTracepointsRequest{Names: []string{"http_probe"}},
+				},
+			},
+			expectedResp: &cvmsgspb.V2CAPIStreamResponse{
+				Msg: &cvmsgspb.V2CAPIStreamResponse_DeleteTracepointsResp{
+					DeleteTracepointsResp: &vizierpb.DeleteTracepointsResponse{},
+				},
+			},
+		},
+		{
+			name: "extend",
+			request: &cvmsgspb.C2VAPIStreamRequest{
+				Msg: &cvmsgspb.C2VAPIStreamRequest_ExtendTracepointsReq{
+					ExtendTracepointsReq: &vizierpb.ExtendTracepointsRequest{Names: []string{"http_probe"}, TTLNS: 1000},
+				},
+			},
+			expectedResp: &cvmsgspb.V2CAPIStreamResponse{
+				Msg: &cvmsgspb.V2CAPIStreamResponse_ExtendTracepointsResp{
+					ExtendTracepointsResp: &vizierpb.ExtendTracepointsResponse{},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testPassthroughRequest(t, test.name, test.request, test.expectedResp)
+		})
+	}
+}
+
+// testPassthroughRequest sends the request through the passthrough proxy and checks that the
+// expected response is sent back, followed by an OK status.
+func testPassthroughRequest(t *testing.T, requestID string, request *cvmsgspb.C2VAPIStreamRequest,
+	expectedResp *cvmsgspb.V2CAPIStreamResponse) {
+	ts, cleanup := createTestState(t)
+	defer cleanup(t)
+
+	s, err := ptproxy.NewPassThroughProxy(ts.nc, vizierpb.NewVizierServiceClient(ts.conn),
+		vizierpb.NewVizierScheduledScriptServiceClient(ts.conn), vizierpb.NewVizierTracepointServiceClient(ts.conn))
+	require.NoError(t, err)
+	go func() {
+		err := s.Run()
+		require.NoError(t, err)
+	}()
+
+	replyCh := make(chan *nats.Msg, 10)
+	replySub, err := ts.nc.ChanSubscribe("v2c.reply-"+requestID, replyCh)
+	require.NoError(t, err)
+	defer func() {
+		err := replySub.Unsubscribe()
+		require.NoError(t, err)
+	}()
+
+	request.RequestID = requestID
+	request.Token = "abcd"
+	reqAnyMsg, err := types.MarshalAny(request)
+	require.NoError(t, err)
+	b, err := (&cvmsgspb.C2VMessage{Msg: reqAnyMsg}).Marshal()
+	require.NoError(t, err)
+	err = ts.nc.Publish("c2v.VizierPassthroughRequest", b)
+	require.NoError(t, err)
+
+	expectedResp.RequestID = requestID
+	expectedResps := []*cvmsgspb.V2CAPIStreamResponse{
+		expectedResp,
+		{
+			RequestID: requestID,
+			Msg: &cvmsgspb.V2CAPIStreamResponse_Status{
+				Status: &vizierpb.Status{Code: int32(codes.OK)},
+			},
+		},
+	}
+	for _, expected := range expectedResps {
+		select {
+		case msg := <-replyCh:
+			v2cMsg := &cvmsgspb.V2CMessage{}
+			err := proto.Unmarshal(msg.Data, v2cMsg)
+			require.NoError(t, err)
+			resp := &cvmsgspb.V2CAPIStreamResponse{}
+			err = types.UnmarshalAny(v2cMsg.Msg, resp)
+			require.NoError(t, err)
+			assert.Equal(t, expected, resp)
+		case <-time.After(defaultTimeout):
+			t.Fatal("Timed out")
+		}
+	}
+}
@@ -27,6 +27,7 @@ import (
 
 	"github.com/cenkalti/backoff/v3"
 	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
@@ -39,10 +40,15 @@ import (
 	"px.dev/pixie/src/shared/services/httpmiddleware"
 	"px.dev/pixie/src/shared/services/server"
 	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
+	"px.dev/pixie/src/vizier/services/query_broker/audit"
 	"px.dev/pixie/src/vizier/services/query_broker/controllers"
+	"px.dev/pixie/src/vizier/services/query_broker/policy"
 	"px.dev/pixie/src/vizier/services/query_broker/ptproxy"
 	"px.dev/pixie/src/vizier/services/query_broker/querybrokerenv"
+	"px.dev/pixie/src/vizier/services/query_broker/querybrokerpb"
+	"px.dev/pixie/src/vizier/services/query_broker/scheduler"
 	"px.dev/pixie/src/vizier/services/query_broker/tracker"
+	"px.dev/pixie/src/vizier/utils/objectstore"
 )
 
 const (
@@ -56,10 +62,35 @@ func init() {
 	pflag.String("mds_service", "vizier-metadata", "The metadata service name")
 	pflag.String("mds_port", "50400", "The querybroker service port")
 	pflag.String("pod_namespace", "pl", "The namespace this pod runs in.")
+	pflag.Int("max_concurrent_queries", 0, "The maximum number of queries that can run at once. 0 means unlimited")
+	pflag.Int("max_concurrent_queries_per_user", 0, "The maximum number of queries a single user can run at once. 0 means unlimited")
+	pflag.Int("max_queued_queries", 100, "The maximum number of queries waiting for admission. 0 disables queueing")
+	pflag.Duration("query_queue_timeout", 30*time.Second, "How long a query waits for admission before being rejected")
+	pflag.StringSlice("admin_users", nil, "IDs or emails of the users that can change the admission limits. Emails may start with '*@' to match a domain")
+	pflag.Float64("max_quarantined_fraction", 0.25, "The largest fraction of PEMs or Kelvins that quarantine may exclude from query plans. At least one of each is always planned on")
+	pflag.Int("plan_cache_size", 256, "The maximum number of compiled plans to cache. 0 disables plan caching")
+	pflag.Duration("plan_cache_ttl", 10*time.Second, "How long a compiled plan is reused. Relative time ranges in a cached plan are resolved when it was compiled")
+	pflag.Duration("max_query_timeout", 0, "The longest a query can run before its results are truncated. 0 means unlimited")
+	pflag.Int64("max_query_bytes_forwarded", 0, "The most result bytes a query can send to the client before its results are truncated. 0 means unlimited")
+	pflag.Int64("max_query_rows_scanned_per_agent", 0, "The most rows a query can scan on each agent before its results are truncated. 0 means unlimited")
+	pflag.Duration("result_cache_ttl", 0, "How long the results of a query are shared with identical queries. 0 disables result sharing")
+	pflag.String("audit_log_sink", audit.SinkNone, "Where to write the audit log of executed scripts: none, stdout, file or nats")
+	pflag.String("audit_log_file", "", "The file to append the audit log to, for the file sink")
+	pflag.String("audit_log_nats_subject", "query_broker.audit", "The NATS subject to publish the audit log to, for the nats sink")
+	pflag.String("access_policy_file", "/etc/pixie/access-policies/policies.yaml", "The file containing the access policies applied to scripts. A missing file means no policies")
+	pflag.Duration("access_policy_reload_interval", 30*time.Second, "How often the access policy file is checked for changes")
+	pflag.String("scheduled_script_result_store", scheduler.ResultStoreFile, "Where the results of scheduled scripts are persisted: file or s3")
+	pflag.String("scheduled_script_result_dir", "/var/lib/pixie/scheduled-scripts", "The directory the results of scheduled scripts are written to, for the file store")
+	pflag.String("scheduled_script_s3_endpoint", "", "The S3-compatible endpoint the results of scheduled scripts are written to, for the s3 store")
+	pflag.String("scheduled_script_s3_bucket", "", "The bucket the results of scheduled scripts are written to, for the s3 store")
+	pflag.String("scheduled_script_s3_region", "us-east-1", "The region of the bucket, for the s3 store")
+	pflag.String("scheduled_script_s3_prefix", "", "The prefix of the objects the results of scheduled scripts are written to, for the s3 store")
+	pflag.String("scheduled_script_s3_access_key_id", "", "The access key ID used to write to the bucket, for the s3 store")
+	pflag.String("scheduled_script_s3_secret_access_key", "", "The secret access key used to write to the bucket, for the s3 store")
 }
 
-// NewVizierServiceClient creates a new vz RPC client stub.
-func NewVizierServiceClient(port uint) (vizierpb.VizierServiceClient, error) {
+// NewVizierServiceConn creates a new GRPC connection to the vz services of this server.
+func NewVizierServiceConn(port uint) (*grpc.ClientConn, error) {
 	dialOpts, err := services.GetGRPCClientDialOpts()
 	if err != nil {
 		return nil, err
@@ -67,12 +98,7 @@ func NewVizierServiceClient(port uint) (vizierpb.VizierServiceClient, error) {
 
 	// Note: This has to be localhost to pass the SSL cert verification.
 	addr := fmt.Sprintf("localhost:%d", port)
-	vzChannel, err := grpc.Dial(addr, dialOpts...)
-	if err != nil {
-		return nil, err
-	}
-
-	return vizierpb.NewVizierServiceClient(vzChannel), nil
+	return grpc.Dial(addr, dialOpts...)
 }
 
 func main() {
@@ -102,6 +128,8 @@ func main() {
 	}
 	mux := http.NewServeMux()
 	healthz.RegisterDefaultChecks(mux)
+	// Like the rest of the mux, the metrics require a bearer token.
+	mux.Handle("/metrics", promhttp.Handler())
 
 	// Connect to metadata service.
 	dialOpts, err := services.GetGRPCClientDialOpts()
@@ -137,6 +165,7 @@ func main() {
 	mdsClient := metadatapb.NewMetadataServiceClient(mdsConn)
 	mdtpClient := metadatapb.NewMetadataTracepointServiceClient(mdsConn)
 	mdconfClient := metadatapb.NewMetadataConfigServiceClient(mdsConn)
+	mdcronClient := metadatapb.NewMetadataCronScriptServiceClient(mdsConn)
 
 	// Connect to NATS.
 	var natsConn *nats.Conn
@@ -164,6 +193,49 @@ func main() {
 		log.WithError(err).Fatal("Failed to initialize GRPC server funcs.")
 	}
 	defer svr.Close()
+	svr.SetAgentFailureReporter(controllers.NewMetadataAgentFailureReporter(mdsClient, viper.GetString("jwt_signing_key")))
+	svr.SetAdmissionLimits(controllers.AdmissionLimits{
+		MaxConcurrentQueries:        viper.GetInt("max_concurrent_queries"),
+		MaxConcurrentQueriesPerUser: viper.GetInt("max_concurrent_queries_per_user"),
+		MaxQueuedQueries:            viper.GetInt("max_queued_queries"),
+		QueueTimeout:                viper.GetDuration("query_queue_timeout"),
+	})
+	svr.SetQueryBudgetLimits(controllers.QueryBudget{
+		Timeout:                viper.GetDuration("max_query_timeout"),
+		MaxBytesForwarded:      viper.GetInt64("max_query_bytes_forwarded"),
+		MaxRowsScannedPerAgent: viper.GetInt64("max_query_rows_scanned_per_agent"),
+	})
+	if size := viper.GetInt("plan_cache_size"); size > 0 {
+		svr.SetPlanCache(controllers.NewPlanCache(size, viper.GetDuration("plan_cache_ttl")))
+	}
+	if ttl := viper.GetDuration("result_cache_ttl"); ttl > 0 {
+		svr.SetResultCache(controllers.NewResultCache(ttl))
+	}
+
+	auditTarget := viper.GetString("audit_log_file")
+	if viper.GetString("audit_log_sink") == audit.SinkNATS {
+		auditTarget = viper.GetString("audit_log_nats_subject")
+	}
+	auditSink, err := audit.NewSink(viper.GetString("audit_log_sink"), auditTarget, natsConn)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to create audit log sink.")
+	}
+	if auditSink != nil {
+		defer auditSink.Close()
+		svr.SetAuditSink(auditSink)
+	}
+
+	policies := policy.NewStore(nil)
+	policyFile := viper.GetString("access_policy_file")
+	if err := policies.LoadFile(policyFile); err != nil {
+		log.WithError(err).Fatal("Failed to load access policies.")
+	}
+	policyDone := make(chan struct{})
+	defer close(policyDone)
+	go policies.WatchFile(policyFile, viper.GetDuration("access_policy_reload_interval"), policyDone)
+	svr.SetPolicyStore(policies)
+	adminUsers := viper.GetStringSlice("admin_users")
+	svr.SetAdmins(policy.Subjects{UserIDs: adminUsers, Emails: adminUsers})
 
 	// For query broker we bump up the max message size since resuls might be larger than 4mb.
 	maxMsgSize := grpc.MaxRecvMsgSize(8 * 1024 * 1024)
@@ -173,16 +245,42 @@ func main() {
 
 	carnotpb.RegisterResultSinkServiceServer(s.GRPCServer(), svr)
 	vizierpb.RegisterVizierServiceServer(s.GRPCServer(), svr)
+	vizierpb.RegisterVizierTracepointServiceServer(s.GRPCServer(), svr)
+	querybrokerpb.RegisterQueryBrokerServiceServer(s.GRPCServer(), svr)
 
-	// For the passthrough proxy we create a GRPC client to the current server. It appears really
+	// For the passthrough proxy and the scheduler we create a GRPC client to the current server. It appears really
 	// hard to emulate the streaming GRPC connection and this helps keep the API straightforward.
-	vzServiceClient, err := NewVizierServiceClient(servicePort)
+	vzConn, err := NewVizierServiceConn(servicePort)
 	if err != nil {
 		log.WithError(err).Fatal("Failed to init vzservice client.")
 	}
+	defer vzConn.Close()
+	vzServiceClient := vizierpb.NewVizierServiceClient(vzConn)
+	vzSchedClient := vizierpb.NewVizierScheduledScriptServiceClient(vzConn)
+	vzTracepointClient := vizierpb.NewVizierTracepointServiceClient(vzConn)
+
+	resultStore, err := scheduler.NewResultStore(scheduler.ResultStoreConfig{
+		Kind: viper.GetString("scheduled_script_result_store"),
+		Dir:  viper.GetString("scheduled_script_result_dir"),
+		S3: objectstore.S3Config{
+			Endpoint:        viper.GetString("scheduled_script_s3_endpoint"),
+			Bucket:          viper.GetString("scheduled_script_s3_bucket"),
+			Region:          viper.GetString("scheduled_script_s3_region"),
+			Prefix:          viper.GetString("scheduled_script_s3_prefix"),
+			AccessKeyID:     viper.GetString("scheduled_script_s3_access_key_id"),
+			SecretAccessKey: viper.GetString("scheduled_script_s3_secret_access_key"),
+		},
+	})
+	if err != nil {
+		log.WithError(err).Fatal("Failed to create scheduled script result store.")
+	}
+	sched := scheduler.New(mdcronClient, vzServiceClient, resultStore, viper.GetString("jwt_signing_key"))
+	vizierpb.RegisterVizierScheduledScriptServiceServer(s.GRPCServer(), sched)
+	sched.Start()
+	defer sched.Stop()
 
 	// Start passthrough proxy.
-	ptProxy, err := ptproxy.NewPassThroughProxy(natsConn, vzServiceClient)
+	ptProxy, err := ptproxy.NewPassThroughProxy(natsConn, vzServiceClient, vzSchedClient, vzTracepointClient)
 	if err != nil {
 		log.WithError(err).Fatal("Failed to start passthrough proxy.")
 	}
@@ -0,0 +1,86 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package scheduler_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/vizier/services/query_broker/scheduler"
+)
+
+func mustParseTime(t *testing.T, s string) time.Time {
+	ts, err := time.Parse(time.RFC3339, s)
+	require.NoError(t, err)
+	return ts
+}
+
+func TestSchedule_Next(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		from string
+		next string
+	}{
+		{"every minute", "* * * * *", "2021-03-04T10:15:30Z", "2021-03-04T10:16:00Z"},
+		{"hourly", "@hourly", "2021-03-04T10:15:00Z", "2021-03-04T11:00:00Z"},
+		{"daily", "@daily", "2021-03-04T10:15:00Z", "2021-03-05T00:00:00Z"},
+		{"step", "*/15 * * * *", "2021-03-04T10:15:00Z", "2021-03-04T10:30:00Z"},
+		{"range and list", "0 9-17 * * 1,3", "2021-03-04T18:00:00Z", "2021-03-08T09:00:00Z"},
+		{"sunday as 7", "0 0 * * 7", "2021-03-04T00:00:00Z", "2021-03-07T00:00:00Z"},
+		{"day of month or week", "0 0 1 * 1", "2021-03-02T00:00:00Z", "2021-03-08T00:00:00Z"},
+		{"leap day", "0 0 29 2 *", "2021-03-01T00:00:00Z", "2024-02-29T00:00:00Z"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := scheduler.ParseSchedule(test.expr)
+			require.NoError(t, err)
+			assert.Equal(t, mustParseTime(t, test.next), s.Next(mustParseTime(t, test.from)))
+		})
+	}
+}
+
+func TestSchedule_NextNever(t *testing.T) {
+	s, err := scheduler.ParseSchedule("0 0 31 2 *")
+	require.NoError(t, err)
+	assert.True(t, s.Next(mustParseTime(t, "2021-03-04T00:00:00Z")).IsZero())
+}
+
+func TestParseSchedule_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"@sometimes",
+	} {
+		_, err := scheduler.ParseSchedule(expr)
+		assert.Error(t, err, expr)
+	}
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/vizier/utils/objectstore"
+)
+
+// The result stores that can be selected with NewResultStore.
+const (
+	ResultStoreFile = objectstore.KindFile
+	ResultStoreS3   = objectstore.KindS3
+)
+
+// ErrResultsNotFound is returned when there are no stored results for a key.
+var ErrResultsNotFound = objectstore.ErrNotFound
+
+// ResultStore persists the results of scheduled script runs.
+type ResultStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	// Get returns ErrResultsNotFound if there are no results for the key.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete deletes the results for the key, if there are any.
+	Delete(ctx context.Context, key string) error
+}
+
+// ResultStoreConfig configures the result store.
+type ResultStoreConfig = objectstore.Config
+
+// NewResultStore creates the result store described by the config.
+func NewResultStore(c ResultStoreConfig) (ResultStore, error) {
+	return objectstore.New(c)
+}
+
+func resultsKey(scriptID, runID string) string {
+	return scriptID + "/" + runID
+}
+
+// encodeResults serializes the responses of a script run as a sequence of length-prefixed
+// messages.
+func encodeResults(resps []*vizierpb.ExecuteScriptResponse) ([]byte, error) {
+	var buf bytes.Buffer
+	lenBuf := make([]byte, binary.MaxVarintLen64)
+	for _, resp := range resps {
+		b, err := resp.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		n := binary.PutUvarint(lenBuf, uint64(len(b)))
+		buf.Write(lenBuf[:n])
+		buf.Write(b)
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeResults parses results serialized by encodeResults.
+func decodeResults(data []byte) ([]*vizierpb.ExecuteScriptResponse, error) {
+	r := bytes.NewReader(data)
+	var resps []*vizierpb.ExecuteScriptResponse
+	for {
+		size, err := binary.ReadUvarint(r)
+		if err == io.EOF {
+			return resps, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if size > uint64(r.Len()) {
+			return nil, errors.New("stored results are truncated")
+		}
+		b := make([]byte, size)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		resp := &vizierpb.ExecuteScriptResponse{}
+		if err := resp.Unmarshal(b); err != nil {
+			return nil, err
+		}
+		resps = append(resps, resp)
+	}
+}
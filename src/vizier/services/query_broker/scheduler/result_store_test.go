@@ -0,0 +1,43 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package scheduler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+)
+
+func TestEncodeDecodeResults(t *testing.T) {
+	resps := []*vizierpb.ExecuteScriptResponse{
+		{QueryID: "abc"},
+		{QueryID: "abc", Status: &vizierpb.Status{Code: 0}},
+	}
+	data, err := encodeResults(resps)
+	require.NoError(t, err)
+	decoded, err := decodeResults(data)
+	require.NoError(t, err)
+	assert.Equal(t, resps, decoded)
+
+	_, err = decodeResults(data[:len(data)-1])
+	assert.Error(t, err)
+}
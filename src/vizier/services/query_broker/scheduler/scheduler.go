@@ -0,0 +1,519 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gofrs/uuid"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+	"px.dev/pixie/src/shared/services/authcontext"
+	"px.dev/pixie/src/shared/services/jwtpb"
+	"px.dev/pixie/src/shared/services/utils"
+	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
+)
+
+const (
+	// How often the scheduler checks for scripts that are due.
+	checkInterval = 10 * time.Second
+	// How often the scheduler reloads the scripts from the metadata service.
+	syncInterval = time.Minute
+	// The longest a single scheduled run can take.
+	runTimeout = 10 * time.Minute
+	// How long a user's authorization to run their script lasts. Upserting the script renews it,
+	// so the runs of a user who has lost access to the cluster stop once it expires.
+	ownerAuthorizationTTL = 7 * 24 * time.Hour
+	// The most result bytes a single run may persist.
+	defaultMaxResultsBytes = 64 * 1024 * 1024
+)
+
+type scheduledScript struct {
+	script *vizierpb.ScheduledScript
+	// The claims of the user who created the script. Its runs are executed under them, so
+	// that they are subject to the same access policies as the user's own queries.
+	owner    *jwtpb.JWTClaims
+	schedule *Schedule
+	next     time.Time
+	running  bool
+}
+
+// Scheduler runs scripts on their cron schedules through the query broker, and persists their
+// results in a ResultStore. The scripts and the history of their runs are kept in the metadata
+// service. It implements the VizierScheduledScriptService.
+type Scheduler struct {
+	mds        metadatapb.MetadataCronScriptServiceClient
+	vz         vizierpb.VizierServiceClient
+	store      ResultStore
+	signingKey string
+	now        func() time.Time
+	// Runs with larger results fail rather than being buffered and persisted.
+	maxResultsBytes int
+
+	mu sync.Mutex
+	// The scripts, keyed by ID.
+	scripts map[string]*scheduledScript
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a scheduler. It runs scripts using the given client of the query broker's own
+// VizierService.
+func New(mds metadatapb.MetadataCronScriptServiceClient, vz vizierpb.VizierServiceClient,
+	store ResultStore, signingKey string) *Scheduler {
+	return &Scheduler{
+		mds:             mds,
+		vz:              vz,
+		store:           store,
+		signingKey:      signingKey,
+		now:             time.Now,
+		maxResultsBytes: defaultMaxResultsBytes,
+		scripts:         make(map[string]*scheduledScript),
+		done:            make(chan struct{}),
+	}
+}
+
+// Start starts running scripts in the background.
+func (s *Scheduler) Start() {
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop stops the scheduler and waits for running scripts to finish.
+func (s *Scheduler) Stop() {
+	close(s.done)
+	s.wg.Wait()
+}
+
+func (s *Scheduler) run() {
+	defer s.wg.Done()
+
+	if err := s.sync(context.Background()); err != nil {
+		log.WithError(err).Error("Failed to load scheduled scripts")
+	}
+	check := time.NewTicker(checkInterval)
+	defer check.Stop()
+	sync := time.NewTicker(syncInterval)
+	defer sync.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-sync.C:
+			if err := s.sync(context.Background()); err != nil {
+				log.WithError(err).Error("Failed to load scheduled scripts")
+			}
+		case <-check.C:
+			s.runDueScripts()
+		}
+	}
+}
+
+// serviceContext authenticates calls made by the scheduler itself.
+func (s *Scheduler) serviceContext(ctx context.Context) context.Context {
+	claims := utils.GenerateJWTForService("query_broker_scheduler", "vizier")
+	token, _ := utils.SignJWTClaims(claims, s.signingKey)
+	return metadata.AppendToOutgoingContext(ctx, "authorization", fmt.Sprintf("bearer %s", token))
+}
+
+// ownerContext authenticates a run of a script as its owner. The token is signed from the
+// stored claims, with a fresh expiry that lasts for the run, as long as the owner's
+// authorization hasn't expired.
+func (s *Scheduler) ownerContext(ctx context.Context, owner *jwtpb.JWTClaims) (context.Context, error) {
+	if owner == nil {
+		return nil, status.Error(codes.FailedPrecondition, "scheduled script has no owner, upsert it again to run it")
+	}
+	now := s.now()
+	if now.Unix() >= owner.ExpiresAt {
+		return nil, status.Error(codes.PermissionDenied,
+			"the owner's authorization of the scheduled script expired, upsert it again to renew it")
+	}
+	claims := *owner
+	claims.IssuedAt = now.Unix()
+	claims.NotBefore = now.Unix()
+	claims.ExpiresAt = now.Add(runTimeout).Unix()
+	if claims.ExpiresAt > owner.ExpiresAt {
+		claims.ExpiresAt = owner.ExpiresAt
+	}
+	token, err := utils.SignJWTClaims(&claims, s.signingKey)
+	if err != nil {
+		return nil, err
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", fmt.Sprintf("bearer %s", token)), nil
+}
+
+func claimsFromContext(ctx context.Context) *jwtpb.JWTClaims {
+	aCtx, err := authcontext.FromContext(ctx)
+	if err != nil {
+		return nil
+	}
+	return aCtx.Claims
+}
+
+// ownedBy returns whether the caller owns a script. Services act on behalf of users and are
+// allowed to access every script.
+func ownedBy(caller, owner *jwtpb.JWTClaims) bool {
+	if caller == nil {
+		return false
+	}
+	if utils.GetClaimsType(caller) == utils.ServiceClaimType {
+		return true
+	}
+	return owner != nil && caller.Subject != "" && caller.Subject == owner.Subject &&
+		utils.GetClaimsType(caller) == utils.GetClaimsType(owner)
+}
+
+// canModify returns whether the caller can see, update or delete a script. Scripts without an
+// owner were created before owners were recorded. Their runs fail, and any user may claim them
+// by upserting them, or delete them.
+func canModify(caller, owner *jwtpb.JWTClaims) bool {
+	return caller != nil && (owner == nil || ownedBy(caller, owner))
+}
+
+// getScript fetches a script, along with its runs and owner, from the metadata service. The
+// script is nil if it doesn't exist.
+func (s *Scheduler) getScript(ctx context.Context, id string) (*vizierpb.ScheduledScript, *jwtpb.JWTClaims, error) {
+	resp, err := s.mds.GetCronScripts(s.serviceContext(ctx), &metadatapb.GetCronScriptsRequest{})
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, script := range resp.Scripts {
+		if script.ID == id {
+			return script, resp.Owners[id], nil
+		}
+	}
+	return nil, nil, nil
+}
+
+// sync reloads the scripts from the metadata service, keeping the next run time of the scripts
+// whose schedule didn't change.
+func (s *Scheduler) sync(ctx context.Context) error {
+	resp, err := s.mds.GetCronScripts(s.serviceContext(ctx), &metadatapb.GetCronScriptsRequest{})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	scripts := make(map[string]*scheduledScript)
+	for _, script := range resp.Scripts {
+		script.Runs = nil
+		owner := resp.Owners[script.ID]
+		if prev, ok := s.scripts[script.ID]; ok && prev.script.CronExpression == script.CronExpression {
+			prev.script = script
+			prev.owner = owner
+			scripts[script.ID] = prev
+			continue
+		}
+		entry, err := s.newScheduledScript(script, owner)
+		if err != nil {
+			log.WithError(err).WithField("script", script.Name).Error("Skipping scheduled script with an invalid schedule")
+			continue
+		}
+		scripts[script.ID] = entry
+	}
+	s.scripts = scripts
+	return nil
+}
+
+func (s *Scheduler) newScheduledScript(script *vizierpb.ScheduledScript, owner *jwtpb.JWTClaims) (*scheduledScript, error) {
+	schedule, err := ParseSchedule(script.CronExpression)
+	if err != nil {
+		return nil, err
+	}
+	return &scheduledScript{
+		script:   script,
+		owner:    owner,
+		schedule: schedule,
+		next:     schedule.Next(s.now()),
+	}, nil
+}
+
+// runDueScripts starts the scripts whose next run time has passed. A script that is still
+// running skips its next run.
+func (s *Scheduler) runDueScripts() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for _, entry := range s.scripts {
+		if entry.next.IsZero() || now.Before(entry.next) {
+			continue
+		}
+		entry.next = entry.schedule.Next(now)
+		if entry.running {
+			log.WithField("script", entry.script.Name).Info("Scheduled script is still running, skipping this run")
+			continue
+		}
+		entry.running = true
+		script, owner := entry.script, entry.owner
+		s.wg.Add(1)
+		go func(entry *scheduledScript) {
+			defer s.wg.Done()
+			s.runScript(script, owner)
+			s.mu.Lock()
+			entry.running = false
+			s.mu.Unlock()
+		}(entry)
+	}
+}
+
+// runScript executes the script as its owner, persists its results and records the run.
+func (s *Scheduler) runScript(script *vizierpb.ScheduledScript, owner *jwtpb.JWTClaims) {
+	runID, err := uuid.NewV4()
+	if err != nil {
+		log.WithError(err).Error("Failed to create run ID")
+		return
+	}
+	logger := log.WithField("script", script.Name).WithField("run_id", runID)
+	run := &vizierpb.ScheduledScriptRun{
+		ID:               runID.String(),
+		StartTimestampNS: s.now().UnixNano(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), runTimeout)
+	defer cancel()
+	err = s.executeAndStore(ctx, script, owner, run.ID)
+	run.EndTimestampNS = s.now().UnixNano()
+	run.Status = &vizierpb.Status{Code: int32(status.Code(err)), Message: status.Convert(err).Message()}
+	if err != nil {
+		logger.WithError(err).Error("Scheduled script failed")
+	} else {
+		logger.Info("Scheduled script succeeded")
+	}
+
+	resp, err := s.mds.RecordCronScriptRun(s.serviceContext(ctx), &metadatapb.RecordCronScriptRunRequest{
+		ScriptID: script.ID,
+		Run:      run,
+	})
+	if err != nil {
+		logger.WithError(err).Error("Failed to record scheduled script run")
+		return
+	}
+	s.deleteResults(ctx, script.ID, resp.EvictedRunIDs)
+}
+
+func (s *Scheduler) executeAndStore(ctx context.Context, script *vizierpb.ScheduledScript,
+	owner *jwtpb.JWTClaims, runID string) error {
+	runCtx, err := s.ownerContext(ctx, owner)
+	if err != nil {
+		return err
+	}
+	stream, err := s.vz.ExecuteScript(runCtx, &vizierpb.ExecuteScriptRequest{
+		QueryStr:  script.QueryStr,
+		ExecFuncs: script.ExecFuncs,
+	})
+	if err != nil {
+		return err
+	}
+
+	var resps []*vizierpb.ExecuteScriptResponse
+	size := 0
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if st := resp.Status; st != nil && st.Code != int32(codes.OK) {
+			return status.Error(codes.Code(st.Code), st.Message)
+		}
+		size += resp.Size()
+		if size > s.maxResultsBytes {
+			return status.Errorf(codes.ResourceExhausted, "results are larger than the limit of %d bytes", s.maxResultsBytes)
+		}
+		resps = append(resps, resp)
+	}
+
+	data, err := encodeResults(resps)
+	if err != nil {
+		return err
+	}
+	return s.store.Put(ctx, resultsKey(script.ID, runID), data)
+}
+
+func (s *Scheduler) deleteResults(ctx context.Context, scriptID string, runIDs []string) {
+	for _, runID := range runIDs {
+		if err := s.store.Delete(ctx, resultsKey(scriptID, runID)); err != nil {
+			log.WithError(err).WithField("run_id", runID).Error("Failed to delete scheduled script results")
+		}
+	}
+}
+
+// UpsertScheduledScript creates or updates a scheduled script. The caller becomes its owner, and
+// authorizes its runs for ownerAuthorizationTTL.
+func (s *Scheduler) UpsertScheduledScript(req *vizierpb.UpsertScheduledScriptRequest,
+	srv vizierpb.VizierScheduledScriptService_UpsertScheduledScriptServer) error {
+	if req.Script == nil {
+		return status.Error(codes.InvalidArgument, "missing scheduled script")
+	}
+	script := *req.Script
+	script.Runs = nil
+	if script.ID == "" {
+		id, err := uuid.NewV4()
+		if err != nil {
+			return err
+		}
+		script.ID = id.String()
+	}
+	ctx := srv.Context()
+	caller := claimsFromContext(ctx)
+	if caller == nil || utils.GetClaimsType(caller) != utils.UserClaimType {
+		return status.Error(codes.PermissionDenied, "scheduled scripts must be created by a user")
+	}
+	owner := *caller
+	owner.ExpiresAt = s.now().Add(ownerAuthorizationTTL).Unix()
+	entry, err := s.newScheduledScript(&script, &owner)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	existing, prevOwner, err := s.getScript(ctx, script.ID)
+	if err != nil {
+		return err
+	}
+	if existing != nil && !canModify(caller, prevOwner) {
+		return status.Errorf(codes.PermissionDenied, "scheduled script %s is owned by another user", script.ID)
+	}
+	_, err = s.mds.UpsertCronScript(s.serviceContext(ctx), &metadatapb.UpsertCronScriptRequest{
+		Script: &script,
+		Owner:  &owner,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if prev, ok := s.scripts[script.ID]; ok {
+		entry.running = prev.running
+	}
+	s.scripts[script.ID] = entry
+	s.mu.Unlock()
+
+	return srv.Send(&vizierpb.UpsertScheduledScriptResponse{ID: script.ID})
+}
+
+// ListScheduledScripts lists the caller's scheduled scripts and their recent runs.
+func (s *Scheduler) ListScheduledScripts(req *vizierpb.ListScheduledScriptsRequest,
+	srv vizierpb.VizierScheduledScriptService_ListScheduledScriptsServer) error {
+	ctx := srv.Context()
+	resp, err := s.mds.GetCronScripts(s.serviceContext(ctx), &metadatapb.GetCronScriptsRequest{})
+	if err != nil {
+		return err
+	}
+	caller := claimsFromContext(ctx)
+	var scripts []*vizierpb.ScheduledScript
+	for _, script := range resp.Scripts {
+		if canModify(caller, resp.Owners[script.ID]) {
+			scripts = append(scripts, script)
+		}
+	}
+	return srv.Send(&vizierpb.ListScheduledScriptsResponse{Scripts: scripts})
+}
+
+// DeleteScheduledScript deletes a scheduled script and its persisted results.
+func (s *Scheduler) DeleteScheduledScript(req *vizierpb.DeleteScheduledScriptRequest,
+	srv vizierpb.VizierScheduledScriptService_DeleteScheduledScriptServer) error {
+	ctx := srv.Context()
+	script, owner, err := s.getScript(ctx, req.ID)
+	if err != nil {
+		return err
+	}
+	if script == nil {
+		return status.Errorf(codes.NotFound, "scheduled script %s not found", req.ID)
+	}
+	if !canModify(claimsFromContext(ctx), owner) {
+		return status.Errorf(codes.PermissionDenied, "scheduled script %s is owned by another user", req.ID)
+	}
+	resp, err := s.mds.DeleteCronScript(s.serviceContext(ctx), &metadatapb.DeleteCronScriptRequest{ID: req.ID})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	delete(s.scripts, req.ID)
+	s.mu.Unlock()
+
+	s.deleteResults(ctx, req.ID, resp.RunIDs)
+	return srv.Send(&vizierpb.DeleteScheduledScriptResponse{})
+}
+
+// GetScheduledScriptResults streams the persisted results of a run of a scheduled script.
+func (s *Scheduler) GetScheduledScriptResults(req *vizierpb.GetScheduledScriptResultsRequest,
+	srv vizierpb.VizierScheduledScriptService_GetScheduledScriptResultsServer) error {
+	ctx := srv.Context()
+	script, owner, err := s.getScript(ctx, req.ScriptID)
+	if err != nil {
+		return err
+	}
+	if script == nil {
+		return status.Errorf(codes.NotFound, "scheduled script %s not found", req.ScriptID)
+	}
+	// Only the owner may read the results, since they were produced under the owner's access.
+	if !ownedBy(claimsFromContext(ctx), owner) {
+		return status.Errorf(codes.PermissionDenied, "scheduled script %s is owned by another user", req.ScriptID)
+	}
+
+	// Runs are newest first.
+	var run *vizierpb.ScheduledScriptRun
+	for _, r := range script.Runs {
+		if (req.RunID == "" && r.Status.GetCode() == int32(codes.OK)) || r.ID == req.RunID {
+			run = r
+			break
+		}
+	}
+	if run == nil {
+		if req.RunID == "" {
+			return status.Errorf(codes.NotFound, "scheduled script %s has no successful runs", script.Name)
+		}
+		return status.Errorf(codes.NotFound, "run %s of scheduled script %s not found", req.RunID, script.Name)
+	}
+	if code := run.Status.GetCode(); code != int32(codes.OK) {
+		return status.Errorf(codes.FailedPrecondition, "run %s failed: %s", run.ID, run.Status.GetMessage())
+	}
+
+	data, err := s.store.Get(ctx, resultsKey(script.ID, run.ID))
+	if errors.Is(err, ErrResultsNotFound) {
+		return status.Errorf(codes.NotFound, "the results of run %s are no longer stored", run.ID)
+	}
+	if err != nil {
+		return err
+	}
+	results, err := decodeResults(data)
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		if err := srv.Send(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,461 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package scheduler
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"px.dev/pixie/src/api/proto/vizierpb"
+	mock_vizierpb "px.dev/pixie/src/api/proto/vizierpb/mock"
+	"px.dev/pixie/src/shared/services/authcontext"
+	"px.dev/pixie/src/shared/services/jwtpb"
+	"px.dev/pixie/src/shared/services/utils"
+	"px.dev/pixie/src/vizier/services/metadata/metadatapb"
+	mock_metadatapb "px.dev/pixie/src/vizier/services/metadata/metadatapb/mock"
+)
+
+const testScriptID = "11285cdd-1de9-4ab1-ae6a-0ba08c8c676c"
+
+func userClaims(userID string) *jwtpb.JWTClaims {
+	return utils.GenerateJWTForUser(userID, "6ba7b810-9dad-11d1-80b4-00c04fd430c8", userID+"@test.com",
+		time.Now().Add(time.Hour), "vizier")
+}
+
+func userContext(userID string) context.Context {
+	aCtx := authcontext.New()
+	aCtx.Claims = userClaims(userID)
+	return authcontext.NewContext(context.Background(), aCtx)
+}
+
+// claimsFromOutgoingContext parses the claims of the token the scheduler authenticated with.
+func claimsFromOutgoingContext(t *testing.T, ctx context.Context) *jwtpb.JWTClaims {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	require.True(t, ok)
+	auth := md.Get("authorization")
+	require.Len(t, auth, 1)
+	aCtx := authcontext.New()
+	require.NoError(t, aCtx.UseJWTAuth("signing_key", strings.TrimPrefix(auth[0], "bearer "), "vizier"))
+	return aCtx.Claims
+}
+
+func newTestScheduler(t *testing.T, ctrl *gomock.Controller) (*Scheduler,
+	*mock_metadatapb.MockMetadataCronScriptServiceClient, *mock_vizierpb.MockVizierServiceClient) {
+	mds := mock_metadatapb.NewMockMetadataCronScriptServiceClient(ctrl)
+	vz := mock_vizierpb.NewMockVizierServiceClient(ctrl)
+	store, err := NewResultStore(ResultStoreConfig{Kind: ResultStoreFile, Dir: t.TempDir()})
+	require.NoError(t, err)
+	s := New(mds, vz, store, "signing_key")
+	return s, mds, vz
+}
+
+func TestScheduler_RunScript(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s, mds, vz := newTestScheduler(t, ctrl)
+
+	script := &vizierpb.ScheduledScript{
+		ID:             testScriptID,
+		Name:           "errors",
+		QueryStr:       "px.display(px.DataFrame('http_events'))",
+		CronExpression: "@hourly",
+	}
+	results := []*vizierpb.ExecuteScriptResponse{
+		{QueryID: "q1", Result: &vizierpb.ExecuteScriptResponse_MetaData{
+			MetaData: &vizierpb.QueryMetadata{Name: "output"},
+		}},
+		{QueryID: "q1"},
+	}
+
+	stream := mock_vizierpb.NewMockVizierService_ExecuteScriptClient(ctrl)
+	vz.EXPECT().
+		ExecuteScript(gomock.Any(), &vizierpb.ExecuteScriptRequest{QueryStr: script.QueryStr}).
+		DoAndReturn(func(ctx context.Context, req *vizierpb.ExecuteScriptRequest, opts ...interface{}) (vizierpb.VizierService_ExecuteScriptClient, error) {
+			// The script runs as its owner, so that the owner's access policies apply.
+			claims := claimsFromOutgoingContext(t, ctx)
+			assert.Equal(t, "user1", claims.GetUserClaims().GetUserID())
+			assert.Equal(t, "user1", claims.Subject)
+			return stream, nil
+		})
+	stream.EXPECT().Recv().Return(results[0], nil)
+	stream.EXPECT().Recv().Return(results[1], nil)
+	stream.EXPECT().Recv().Return(nil, io.EOF)
+
+	var recorded *vizierpb.ScheduledScriptRun
+	mds.EXPECT().
+		RecordCronScriptRun(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, req *metadatapb.RecordCronScriptRunRequest, opts ...interface{}) (*metadatapb.RecordCronScriptRunResponse, error) {
+			assert.Equal(t, testScriptID, req.ScriptID)
+			recorded = req.Run
+			return &metadatapb.RecordCronScriptRunResponse{}, nil
+		})
+
+	s.runScript(script, userClaims("user1"))
+
+	require.NotNil(t, recorded)
+	assert.Equal(t, int32(codes.OK), recorded.Status.Code)
+	data, err := s.store.Get(context.Background(), resultsKey(testScriptID, recorded.ID))
+	require.NoError(t, err)
+	stored, err := decodeResults(data)
+	require.NoError(t, err)
+	assert.Equal(t, results, stored)
+
+	// The results can be fetched through the API.
+	mds.EXPECT().
+		GetCronScripts(gomock.Any(), &metadatapb.GetCronScriptsRequest{}).
+		Return(&metadatapb.GetCronScriptsResponse{
+			Scripts: []*vizierpb.ScheduledScript{{
+				ID:   testScriptID,
+				Name: "errors",
+				Runs: []*vizierpb.ScheduledScriptRun{recorded},
+			}},
+			Owners: map[string]*jwtpb.JWTClaims{testScriptID: userClaims("user1")},
+		}, nil).Times(2)
+	srv := mock_vizierpb.NewMockVizierScheduledScriptService_GetScheduledScriptResultsServer(ctrl)
+	srv.EXPECT().Context().Return(userContext("user1"))
+	var sent []*vizierpb.ExecuteScriptResponse
+	srv.EXPECT().Send(gomock.Any()).Times(2).DoAndReturn(func(resp *vizierpb.ExecuteScriptResponse) error {
+		sent = append(sent, resp)
+		return nil
+	})
+	require.NoError(t, s.GetScheduledScriptResults(&vizierpb.GetScheduledScriptResultsRequest{ScriptID: testScriptID}, srv))
+	assert.Equal(t, results, sent)
+
+	// Other users can't read them.
+	other := mock_vizierpb.NewMockVizierScheduledScriptService_GetScheduledScriptResultsServer(ctrl)
+	other.EXPECT().Context().Return(userContext("user2"))
+	err = s.GetScheduledScriptResults(&vizierpb.GetScheduledScriptResultsRequest{ScriptID: testScriptID}, other)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestScheduler_RunScriptWithoutOwner(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s, mds, _ := newTestScheduler(t, ctrl)
+
+	var recorded *vizierpb.ScheduledScriptRun
+	mds.EXPECT().
+		RecordCronScriptRun(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, req *metadatapb.RecordCronScriptRunRequest, opts ...interface{}) (*metadatapb.RecordCronScriptRunResponse, error) {
+			recorded = req.Run
+			return &metadatapb.RecordCronScriptRunResponse{}, nil
+		})
+
+	// The script isn't executed at all.
+	s.runScript(&vizierpb.ScheduledScript{ID: testScriptID, QueryStr: "px", CronExpression: "@hourly"}, nil)
+
+	require.NotNil(t, recorded)
+	assert.Equal(t, int32(codes.FailedPrecondition), recorded.Status.Code)
+}
+
+func TestScheduler_RunScriptOwnerAuthorizationExpired(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s, mds, _ := newTestScheduler(t, ctrl)
+
+	var recorded *vizierpb.ScheduledScriptRun
+	mds.EXPECT().
+		RecordCronScriptRun(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, req *metadatapb.RecordCronScriptRunRequest, opts ...interface{}) (*metadatapb.RecordCronScriptRunResponse, error) {
+			recorded = req.Run
+			return &metadatapb.RecordCronScriptRunResponse{}, nil
+		})
+
+	// The owner hasn't renewed their authorization, so the script isn't executed.
+	owner := userClaims("user1")
+	owner.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+	s.runScript(&vizierpb.ScheduledScript{ID: testScriptID, QueryStr: "px", CronExpression: "@hourly"}, owner)
+
+	require.NotNil(t, recorded)
+	assert.Equal(t, int32(codes.PermissionDenied), recorded.Status.Code)
+}
+
+func TestScheduler_RunScriptResultsTooLarge(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s, mds, vz := newTestScheduler(t, ctrl)
+	s.maxResultsBytes = 16
+
+	stream := mock_vizierpb.NewMockVizierService_ExecuteScriptClient(ctrl)
+	vz.EXPECT().ExecuteScript(gomock.Any(), gomock.Any()).Return(stream, nil)
+	stream.EXPECT().Recv().Return(&vizierpb.ExecuteScriptResponse{
+		QueryID: "q1",
+		Result: &vizierpb.ExecuteScriptResponse_MetaData{
+			MetaData: &vizierpb.QueryMetadata{Name: "a_table_with_a_long_name"},
+		},
+	}, nil)
+
+	var recorded *vizierpb.ScheduledScriptRun
+	mds.EXPECT().
+		RecordCronScriptRun(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, req *metadatapb.RecordCronScriptRunRequest, opts ...interface{}) (*metadatapb.RecordCronScriptRunResponse, error) {
+			recorded = req.Run
+			return &metadatapb.RecordCronScriptRunResponse{}, nil
+		})
+
+	s.runScript(&vizierpb.ScheduledScript{ID: testScriptID, QueryStr: "px", CronExpression: "@hourly"}, userClaims("user1"))
+
+	require.NotNil(t, recorded)
+	assert.Equal(t, int32(codes.ResourceExhausted), recorded.Status.Code)
+	_, err := s.store.Get(context.Background(), resultsKey(testScriptID, recorded.ID))
+	assert.ErrorIs(t, err, ErrResultsNotFound)
+}
+
+func TestScheduler_RunScriptFailed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s, mds, vz := newTestScheduler(t, ctrl)
+
+	script := &vizierpb.ScheduledScript{ID: testScriptID, Name: "errors", QueryStr: "bad", CronExpression: "@hourly"}
+	stream := mock_vizierpb.NewMockVizierService_ExecuteScriptClient(ctrl)
+	vz.EXPECT().ExecuteScript(gomock.Any(), gomock.Any()).Return(stream, nil)
+	stream.EXPECT().Recv().Return(&vizierpb.ExecuteScriptResponse{
+		Status: &vizierpb.Status{Code: int32(codes.InvalidArgument), Message: "compilation failed"},
+	}, nil)
+
+	var recorded *vizierpb.ScheduledScriptRun
+	mds.EXPECT().
+		RecordCronScriptRun(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, req *metadatapb.RecordCronScriptRunRequest, opts ...interface{}) (*metadatapb.RecordCronScriptRunResponse, error) {
+			recorded = req.Run
+			return &metadatapb.RecordCronScriptRunResponse{}, nil
+		})
+
+	s.runScript(script, userClaims("user1"))
+
+	require.NotNil(t, recorded)
+	assert.Equal(t, int32(codes.InvalidArgument), recorded.Status.Code)
+	assert.Equal(t, "compilation failed", recorded.Status.Message)
+	_, err := s.store.Get(context.Background(), resultsKey(testScriptID, recorded.ID))
+	assert.ErrorIs(t, err, ErrResultsNotFound)
+
+	// Asking for the failed run's results returns its error.
+	mds.EXPECT().
+		GetCronScripts(gomock.Any(), gomock.Any()).
+		Return(&metadatapb.GetCronScriptsResponse{
+			Scripts: []*vizierpb.ScheduledScript{{ID: testScriptID, Runs: []*vizierpb.ScheduledScriptRun{recorded}}},
+			Owners:  map[string]*jwtpb.JWTClaims{testScriptID: userClaims("user1")},
+		}, nil).Times(2)
+	srv := mock_vizierpb.NewMockVizierScheduledScriptService_GetScheduledScriptResultsServer(ctrl)
+	srv.EXPECT().Context().Return(userContext("user1")).Times(2)
+	err = s.GetScheduledScriptResults(&vizierpb.GetScheduledScriptResultsRequest{ScriptID: testScriptID, RunID: recorded.ID}, srv)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	err = s.GetScheduledScriptResults(&vizierpb.GetScheduledScriptResultsRequest{ScriptID: testScriptID}, srv)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestScheduler_EvictsOldResults(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s, mds, vz := newTestScheduler(t, ctrl)
+
+	ctx := context.Background()
+	require.NoError(t, s.store.Put(ctx, resultsKey(testScriptID, "old"), []byte("results")))
+
+	stream := mock_vizierpb.NewMockVizierService_ExecuteScriptClient(ctrl)
+	vz.EXPECT().ExecuteScript(gomock.Any(), gomock.Any()).Return(stream, nil)
+	stream.EXPECT().Recv().Return(nil, io.EOF)
+	mds.EXPECT().
+		RecordCronScriptRun(gomock.Any(), gomock.Any()).
+		Return(&metadatapb.RecordCronScriptRunResponse{EvictedRunIDs: []string{"old"}}, nil)
+
+	s.runScript(&vizierpb.ScheduledScript{ID: testScriptID, CronExpression: "@hourly"}, userClaims("user1"))
+
+	_, err := s.store.Get(ctx, resultsKey(testScriptID, "old"))
+	assert.ErrorIs(t, err, ErrResultsNotFound)
+}
+
+func TestScheduler_RunDueScripts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s, mds, vz := newTestScheduler(t, ctrl)
+
+	now := time.Date(2021, 3, 4, 10, 15, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	mds.EXPECT().
+		GetCronScripts(gomock.Any(), gomock.Any()).
+		Return(&metadatapb.GetCronScriptsResponse{
+			Scripts: []*vizierpb.ScheduledScript{{ID: testScriptID, CronExpression: "@hourly"}},
+			Owners:  map[string]*jwtpb.JWTClaims{testScriptID: userClaims("user1")},
+		}, nil)
+	require.NoError(t, s.sync(context.Background()))
+	assert.Equal(t, "user1", s.scripts[testScriptID].owner.Subject)
+	assert.Equal(t, now.Add(45*time.Minute), s.scripts[testScriptID].next)
+
+	// Nothing is due yet.
+	s.runDueScripts()
+	s.wg.Wait()
+
+	stream := mock_vizierpb.NewMockVizierService_ExecuteScriptClient(ctrl)
+	vz.EXPECT().ExecuteScript(gomock.Any(), gomock.Any()).Return(stream, nil)
+	stream.EXPECT().Recv().Return(nil, io.EOF)
+	mds.EXPECT().
+		RecordCronScriptRun(gomock.Any(), gomock.Any()).
+		Return(&metadatapb.RecordCronScriptRunResponse{}, nil)
+
+	now = now.Add(45 * time.Minute)
+	s.runDueScripts()
+	s.wg.Wait()
+	assert.Equal(t, now.Add(time.Hour), s.scripts[testScriptID].next)
+	assert.False(t, s.scripts[testScriptID].running)
+}
+
+func TestScheduler_UpsertScheduledScript(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s, mds, _ := newTestScheduler(t, ctrl)
+
+	srv := mock_vizierpb.NewMockVizierScheduledScriptService_UpsertScheduledScriptServer(ctrl)
+	srv.EXPECT().Context().Return(userContext("user1")).Times(2)
+
+	var id string
+	mds.EXPECT().
+		GetCronScripts(gomock.Any(), gomock.Any()).
+		Return(&metadatapb.GetCronScriptsResponse{}, nil)
+	mds.EXPECT().
+		UpsertCronScript(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, req *metadatapb.UpsertCronScriptRequest, opts ...interface{}) (*metadatapb.UpsertCronScriptResponse, error) {
+			id = req.Script.ID
+			assert.Equal(t, "*/5 * * * *", req.Script.CronExpression)
+			assert.Equal(t, "user1", req.Owner.Subject)
+			// Upserting renews the owner's authorization of the script's runs.
+			assert.Greater(t, req.Owner.ExpiresAt, time.Now().Add(ownerAuthorizationTTL-time.Hour).Unix())
+			return &metadatapb.UpsertCronScriptResponse{}, nil
+		})
+	srv.EXPECT().Send(gomock.Any()).DoAndReturn(func(resp *vizierpb.UpsertScheduledScriptResponse) error {
+		assert.Equal(t, id, resp.ID)
+		return nil
+	})
+
+	err := s.UpsertScheduledScript(&vizierpb.UpsertScheduledScriptRequest{
+		Script: &vizierpb.ScheduledScript{Name: "errors", QueryStr: "px", CronExpression: "*/5 * * * *"},
+	}, srv)
+	require.NoError(t, err)
+	assert.NotEmpty(t, id)
+	require.Contains(t, s.scripts, id)
+	assert.Equal(t, "user1", s.scripts[id].owner.Subject)
+
+	err = s.UpsertScheduledScript(&vizierpb.UpsertScheduledScriptRequest{
+		Script: &vizierpb.ScheduledScript{Name: "errors", QueryStr: "px", CronExpression: "every day"},
+	}, srv)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestScheduler_UpsertScheduledScriptOwnedByOther(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s, mds, _ := newTestScheduler(t, ctrl)
+
+	mds.EXPECT().
+		GetCronScripts(gomock.Any(), gomock.Any()).
+		Return(&metadatapb.GetCronScriptsResponse{
+			Scripts: []*vizierpb.ScheduledScript{{ID: testScriptID, Name: "errors", CronExpression: "@hourly"}},
+			Owners:  map[string]*jwtpb.JWTClaims{testScriptID: userClaims("user1")},
+		}, nil)
+	srv := mock_vizierpb.NewMockVizierScheduledScriptService_UpsertScheduledScriptServer(ctrl)
+	srv.EXPECT().Context().Return(userContext("user2"))
+
+	err := s.UpsertScheduledScript(&vizierpb.UpsertScheduledScriptRequest{
+		Script: &vizierpb.ScheduledScript{ID: testScriptID, Name: "errors", QueryStr: "px", CronExpression: "@daily"},
+	}, srv)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.NotContains(t, s.scripts, testScriptID)
+
+	// Services can't own scripts, since their runs wouldn't be subject to any access policy.
+	service := mock_vizierpb.NewMockVizierScheduledScriptService_UpsertScheduledScriptServer(ctrl)
+	aCtx := authcontext.New()
+	aCtx.Claims = utils.GenerateJWTForService("query_broker", "vizier")
+	service.EXPECT().Context().Return(authcontext.NewContext(context.Background(), aCtx))
+	err = s.UpsertScheduledScript(&vizierpb.UpsertScheduledScriptRequest{
+		Script: &vizierpb.ScheduledScript{Name: "errors", QueryStr: "px", CronExpression: "@daily"},
+	}, service)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestScheduler_ListScheduledScripts(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s, mds, _ := newTestScheduler(t, ctrl)
+
+	mds.EXPECT().
+		GetCronScripts(gomock.Any(), gomock.Any()).
+		Return(&metadatapb.GetCronScriptsResponse{
+			Scripts: []*vizierpb.ScheduledScript{{ID: "mine"}, {ID: "theirs"}, {ID: "unowned"}},
+			Owners: map[string]*jwtpb.JWTClaims{
+				"mine":   userClaims("user1"),
+				"theirs": userClaims("user2"),
+			},
+		}, nil)
+	srv := mock_vizierpb.NewMockVizierScheduledScriptService_ListScheduledScriptsServer(ctrl)
+	srv.EXPECT().Context().Return(userContext("user1"))
+	srv.EXPECT().Send(gomock.Any()).DoAndReturn(func(resp *vizierpb.ListScheduledScriptsResponse) error {
+		require.Len(t, resp.Scripts, 2)
+		assert.Equal(t, "mine", resp.Scripts[0].ID)
+		assert.Equal(t, "unowned", resp.Scripts[1].ID)
+		return nil
+	})
+
+	require.NoError(t, s.ListScheduledScripts(&vizierpb.ListScheduledScriptsRequest{}, srv))
+}
+
+func TestScheduler_DeleteScheduledScript(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	s, mds, _ := newTestScheduler(t, ctrl)
+
+	ctx := context.Background()
+	require.NoError(t, s.store.Put(ctx, resultsKey(testScriptID, "run1"), []byte("results")))
+	s.scripts[testScriptID] = &scheduledScript{}
+
+	mds.EXPECT().
+		GetCronScripts(gomock.Any(), gomock.Any()).
+		Return(&metadatapb.GetCronScriptsResponse{
+			Scripts: []*vizierpb.ScheduledScript{{ID: testScriptID}},
+			Owners:  map[string]*jwtpb.JWTClaims{testScriptID: userClaims("user1")},
+		}, nil).Times(2)
+
+	// Only the owner can delete the script.
+	other := mock_vizierpb.NewMockVizierScheduledScriptService_DeleteScheduledScriptServer(ctrl)
+	other.EXPECT().Context().Return(userContext("user2"))
+	err := s.DeleteScheduledScript(&vizierpb.DeleteScheduledScriptRequest{ID: testScriptID}, other)
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	assert.Contains(t, s.scripts, testScriptID)
+
+	mds.EXPECT().
+		DeleteCronScript(gomock.Any(), &metadatapb.DeleteCronScriptRequest{ID: testScriptID}).
+		Return(&metadatapb.DeleteCronScriptResponse{RunIDs: []string{"run1"}}, nil)
+	srv := mock_vizierpb.NewMockVizierScheduledScriptService_DeleteScheduledScriptServer(ctrl)
+	srv.EXPECT().Context().Return(userContext("user1"))
+	srv.EXPECT().Send(&vizierpb.DeleteScheduledScriptResponse{}).Return(nil)
+
+	require.NoError(t, s.DeleteScheduledScript(&vizierpb.DeleteScheduledScriptRequest{ID: testScriptID}, srv))
+	assert.NotContains(t, s.scripts, testScriptID)
+	_, err = s.store.Get(ctx, resultsKey(testScriptID, "run1"))
+	assert.ErrorIs(t, err, ErrResultsNotFound)
+}
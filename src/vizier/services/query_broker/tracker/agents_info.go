@@ -20,6 +20,7 @@ package tracker
 
 import (
 	"fmt"
+	"sort"
 	"sync"
 
 	"github.com/gofrs/uuid"
@@ -50,6 +51,17 @@ type AgentsInfoImpl struct {
 	dsMutex sync.Mutex
 
 	pendingDs *distributedpb.DistributedState
+	// The agents that are quarantined, which are excluded from the distributed state. Their carnot
+	// info is kept up to date so that agents have their metadata info when they are reinstated.
+	quarantined map[uuid.UUID]*quarantinedAgent
+	// The largest fraction of the agents of each kind that may be excluded from the distributed
+	// state. At least one agent of each kind is always kept.
+	maxQuarantinedFraction float64
+}
+
+type quarantinedAgent struct {
+	carnotInfo *distributedpb.CarnotInfo
+	sinceNS    int64
 }
 
 // NewAgentsInfo creates an empty agents info.
@@ -60,14 +72,18 @@ func NewAgentsInfo() AgentsInfo {
 			SchemaInfo: []*distributedpb.SchemaInfo{},
 			CarnotInfo: []*distributedpb.CarnotInfo{},
 		},
+		quarantined:            make(map[uuid.UUID]*quarantinedAgent),
+		maxQuarantinedFraction: viper.GetFloat64("max_quarantined_fraction"),
 	}
 }
 
 // NewTestAgentsInfo creates an agents info from a passed in distributed state.
 func NewTestAgentsInfo(ds *distributedpb.DistributedState) AgentsInfo {
 	return &AgentsInfoImpl{
-		ds:        *(ds),
-		pendingDs: nil,
+		ds:                     *(ds),
+		pendingDs:              nil,
+		quarantined:            make(map[uuid.UUID]*quarantinedAgent),
+		maxQuarantinedFraction: viper.GetFloat64("max_quarantined_fraction"),
 	}
 }
 
@@ -78,6 +94,7 @@ func (a *AgentsInfoImpl) ClearPendingState() {
 		SchemaInfo: []*distributedpb.SchemaInfo{},
 		CarnotInfo: []*distributedpb.CarnotInfo{},
 	}
+	a.quarantined = make(map[uuid.UUID]*quarantinedAgent)
 }
 
 // UpdateAgentsInfo creates a new agent info.
@@ -94,6 +111,10 @@ func (a *AgentsInfoImpl) UpdateAgentsInfo(update *metadatapb.AgentUpdatesRespons
 		if err != nil {
 			return err
 		}
+		// Quarantined agents may have been kept in the distributed state, but are tracked separately.
+		if _, ok := a.quarantined[agentUUID]; ok {
+			continue
+		}
 		carnotInfoMap[agentUUID] = carnotInfo
 	}
 
@@ -103,6 +124,7 @@ func (a *AgentsInfoImpl) UpdateAgentsInfo(update *metadatapb.AgentUpdatesRespons
 	deletedAgents := 0
 	updatedAgents := 0
 	updatedAgentsDataInfo := 0
+	quarantinedAgents := 0
 
 	for _, agentUpdate := range update.AgentUpdates {
 		agentUUID, err := utils.UUIDFromProto(agentUpdate.AgentID)
@@ -112,23 +134,41 @@ func (a *AgentsInfoImpl) UpdateAgentsInfo(update *metadatapb.AgentUpdatesRespons
 		// case 1: agent info update
 		agent := agentUpdate.GetAgent()
 		if agent != nil {
-			if _, present := carnotInfoMap[agentUUID]; present {
+			existing, present := carnotInfoMap[agentUUID]
+			if q, ok := a.quarantined[agentUUID]; !present && ok {
+				existing, present = q.carnotInfo, true
+			}
+			if present {
 				updatedAgents++
 			} else {
 				createdAgents++
 			}
 
+			var carnotInfo *distributedpb.CarnotInfo
 			if agent.Info.Capabilities == nil || agent.Info.Capabilities.CollectsData {
 				var metadataInfo *distributedpb.MetadataInfo
-				if carnotInfo, present := carnotInfoMap[agentUUID]; present {
-					metadataInfo = carnotInfo.MetadataInfo
+				if present {
+					metadataInfo = existing.MetadataInfo
 				}
 				// this is a PEM
-				carnotInfoMap[agentUUID] = makeAgentCarnotInfo(agentUUID, agent.ASID, metadataInfo)
+				carnotInfo = makeAgentCarnotInfo(agentUUID, agent.ASID, metadataInfo)
 			} else {
 				// this is a Kelvin
 				kelvinGRPCAddress := agent.Info.IPAddress
-				carnotInfoMap[agentUUID] = makeKelvinCarnotInfo(agentUUID, kelvinGRPCAddress, agent.ASID)
+				carnotInfo = makeKelvinCarnotInfo(agentUUID, kelvinGRPCAddress, agent.ASID)
+			}
+
+			// Quarantined agents are left out of the distributed state, so that no queries are planned on them.
+			if agent.Quarantine.GetQuarantined() {
+				quarantinedAgents++
+				a.quarantined[agentUUID] = &quarantinedAgent{
+					carnotInfo: carnotInfo,
+					sinceNS:    agent.Quarantine.SinceNS,
+				}
+				delete(carnotInfoMap, agentUUID)
+			} else {
+				delete(a.quarantined, agentUUID)
+				carnotInfoMap[agentUUID] = carnotInfo
 			}
 		}
 		// case 2: agent data info update
@@ -136,6 +176,9 @@ func (a *AgentsInfoImpl) UpdateAgentsInfo(update *metadatapb.AgentUpdatesRespons
 		if dataInfo != nil {
 			updatedAgentsDataInfo++
 			carnotInfo, present := carnotInfoMap[agentUUID]
+			if q, ok := a.quarantined[agentUUID]; !present && ok {
+				carnotInfo, present = q.carnotInfo, true
+			}
 			if !present {
 				// It's possible that an agent may be deleted, but we still receive the schema. We should be robust to this case.
 				continue
@@ -151,18 +194,22 @@ func (a *AgentsInfoImpl) UpdateAgentsInfo(update *metadatapb.AgentUpdatesRespons
 		if agentUpdate.GetDeleted() {
 			deletedAgents++
 			delete(carnotInfoMap, agentUUID)
+			delete(a.quarantined, agentUUID)
 		}
 	}
 
-	log.Infof("Created %d agents, deleted %d agents, updated %d agents, updated %d agents data info",
-		createdAgents, deletedAgents, updatedAgents, updatedAgentsDataInfo)
-	log.Infof("%d agents present in tracker after update", len(carnotInfoMap))
+	log.Infof("Created %d agents, deleted %d agents, updated %d agents, updated %d agents data info, quarantined %d agents",
+		createdAgents, deletedAgents, updatedAgents, updatedAgentsDataInfo, quarantinedAgents)
+	log.Infof("%d agents present in tracker after update, %d agents quarantined", len(carnotInfoMap), len(a.quarantined))
 
 	// reset the array and recreate.
 	a.pendingDs.CarnotInfo = []*distributedpb.CarnotInfo{}
 	for _, carnotInfo := range carnotInfoMap {
 		a.pendingDs.CarnotInfo = append(a.pendingDs.CarnotInfo, carnotInfo)
 	}
+	for _, carnotInfo := range a.plannedQuarantinedAgents(carnotInfoMap) {
+		a.pendingDs.CarnotInfo = append(a.pendingDs.CarnotInfo, carnotInfo)
+	}
 
 	// If we have reached the end of version, promote the pending DistributedState to the current external-facing
 	// distributed state accessible by clients of `Agents`.
@@ -175,6 +222,50 @@ func (a *AgentsInfoImpl) UpdateAgentsInfo(update *metadatapb.AgentUpdatesRespons
 	return nil
 }
 
+// plannedQuarantinedAgents returns the quarantined agents that are kept in the distributed state,
+// because excluding them would leave too few agents of their kind. The agents quarantined the
+// longest are excluded first.
+func (a *AgentsInfoImpl) plannedQuarantinedAgents(carnotInfoMap map[uuid.UUID]*distributedpb.CarnotInfo) []*distributedpb.CarnotInfo {
+	// PEMs and Kelvins are limited separately. Only PEMs have a data store.
+	total := make(map[bool]int)
+	for _, carnotInfo := range carnotInfoMap {
+		total[carnotInfo.HasDataStore]++
+	}
+	quarantined := make(map[bool][]uuid.UUID)
+	for agentID, q := range a.quarantined {
+		kind := q.carnotInfo.HasDataStore
+		total[kind]++
+		quarantined[kind] = append(quarantined[kind], agentID)
+	}
+
+	var planned []*distributedpb.CarnotInfo
+	for kind, agentIDs := range quarantined {
+		sort.Slice(agentIDs, func(i, j int) bool {
+			qi, qj := a.quarantined[agentIDs[i]], a.quarantined[agentIDs[j]]
+			if qi.sinceNS != qj.sinceNS {
+				return qi.sinceNS < qj.sinceNS
+			}
+			return agentIDs[i].String() < agentIDs[j].String()
+		})
+		maxExcluded := int(a.maxQuarantinedFraction * float64(total[kind]))
+		if maxExcluded > total[kind]-1 {
+			maxExcluded = total[kind] - 1
+		}
+		if maxExcluded < 0 {
+			maxExcluded = 0
+		}
+		if len(agentIDs) <= maxExcluded {
+			continue
+		}
+		log.Warnf("%d of %d agents are quarantined, planning queries on %d of them anyway",
+			len(agentIDs), total[kind], len(agentIDs)-maxExcluded)
+		for _, agentID := range agentIDs[maxExcluded:] {
+			planned = append(planned, a.quarantined[agentID].carnotInfo)
+		}
+	}
+	return planned
+}
+
 // DistributedState returns the current distributed state.
 // Returns a non-pointer because a.ds will change over time and we want the consumer of DistributedState()
 // to have a consistent result.
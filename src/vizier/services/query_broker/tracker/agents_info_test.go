@@ -350,3 +350,182 @@ func TestAgentsInfo_UpdateAgentsInfo(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, 0, len(agentsInfo.DistributedState().SchemaInfo))
 }
+
+func TestAgentsInfo_QuarantinedAgents(t *testing.T) {
+	viper.Set("pod_namespace", "pl")
+	viper.Set("max_quarantined_fraction", 0.5)
+	defer viper.Set("max_quarantined_fraction", nil)
+	uuidpbs := makeTestAgentIDs(t)
+	agents := makeTestAgents(t)
+	agentDataInfos := makeTestAgentDataInfo()
+
+	agentsInfo := tracker.NewAgentsInfo()
+
+	carnotAgentIDs := func() []*uuidpb.UUID {
+		var ids []*uuidpb.UUID
+		for _, carnotInfo := range agentsInfo.DistributedState().CarnotInfo {
+			ids = append(ids, carnotInfo.AgentID)
+		}
+		return ids
+	}
+
+	quarantinedPEM := &agentpb.Agent{
+		LastHeartbeatNS: 40,
+		CreateTimeNS:    agents[0].CreateTimeNS,
+		Info:            agents[0].Info,
+		ASID:            agents[0].ASID,
+		Quarantine: &agentpb.AgentQuarantine{
+			Quarantined: true,
+			Reason:      "health score 0.40 is below 0.50",
+		},
+	}
+
+	// The quarantined PEM is left out of the distributed state.
+	err := agentsInfo.UpdateAgentsInfo(&metadatapb.AgentUpdatesResponse{
+		AgentUpdates: []*metadatapb.AgentUpdate{
+			{
+				AgentID: uuidpbs[0],
+				Update: &metadatapb.AgentUpdate_Agent{
+					Agent: quarantinedPEM,
+				},
+			},
+			{
+				AgentID: uuidpbs[1],
+				Update: &metadatapb.AgentUpdate_Agent{
+					Agent: agents[1],
+				},
+			},
+			{
+				AgentID: uuidpbs[2],
+				Update: &metadatapb.AgentUpdate_Agent{
+					Agent: agents[2],
+				},
+			},
+			{
+				AgentID: uuidpbs[0],
+				Update: &metadatapb.AgentUpdate_DataInfo{
+					DataInfo: agentDataInfos[0],
+				},
+			},
+		},
+		EndOfVersion: true,
+	})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []*uuidpb.UUID{uuidpbs[1], uuidpbs[2]}, carnotAgentIDs())
+
+	// Once reinstated, the PEM is planned on again with the data info it sent while quarantined.
+	err = agentsInfo.UpdateAgentsInfo(&metadatapb.AgentUpdatesResponse{
+		AgentUpdates: []*metadatapb.AgentUpdate{
+			{
+				AgentID: uuidpbs[0],
+				Update: &metadatapb.AgentUpdate_Agent{
+					Agent: agents[0],
+				},
+			},
+		},
+		EndOfVersion: true,
+	})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []*uuidpb.UUID{uuidpbs[0], uuidpbs[1], uuidpbs[2]}, carnotAgentIDs())
+	for _, carnotInfo := range agentsInfo.DistributedState().CarnotInfo {
+		if carnotInfo.AgentID.Equal(uuidpbs[0]) {
+			assert.Equal(t, agentDataInfos[0].MetadataInfo, carnotInfo.MetadataInfo)
+		}
+	}
+
+	// Deleting a quarantined agent forgets it.
+	err = agentsInfo.UpdateAgentsInfo(&metadatapb.AgentUpdatesResponse{
+		AgentUpdates: []*metadatapb.AgentUpdate{
+			{
+				AgentID: uuidpbs[0],
+				Update: &metadatapb.AgentUpdate_Agent{
+					Agent: quarantinedPEM,
+				},
+			},
+			{
+				AgentID: uuidpbs[0],
+				Update: &metadatapb.AgentUpdate_Deleted{
+					Deleted: true,
+				},
+			},
+			{
+				AgentID: uuidpbs[0],
+				Update: &metadatapb.AgentUpdate_Agent{
+					Agent: agents[0],
+				},
+			},
+		},
+		EndOfVersion: true,
+	})
+	require.NoError(t, err)
+	for _, carnotInfo := range agentsInfo.DistributedState().CarnotInfo {
+		if carnotInfo.AgentID.Equal(uuidpbs[0]) {
+			assert.Nil(t, carnotInfo.MetadataInfo)
+		}
+	}
+}
+
+func TestAgentsInfo_MaxQuarantinedFraction(t *testing.T) {
+	viper.Set("pod_namespace", "pl")
+	viper.Set("max_quarantined_fraction", 0.5)
+	defer viper.Set("max_quarantined_fraction", nil)
+	uuidpbs := makeTestAgentIDs(t)
+	agents := makeTestAgents(t)
+
+	agentsInfo := tracker.NewAgentsInfo()
+	carnotAgentIDs := func() []*uuidpb.UUID {
+		var ids []*uuidpb.UUID
+		for _, carnotInfo := range agentsInfo.DistributedState().CarnotInfo {
+			ids = append(ids, carnotInfo.AgentID)
+		}
+		return ids
+	}
+	quarantine := func(agent *agentpb.Agent, sinceNS int64) *agentpb.Agent {
+		q := *agent
+		q.Quarantine = &agentpb.AgentQuarantine{Quarantined: true, SinceNS: sinceNS}
+		return &q
+	}
+
+	// Both PEMs are quarantined, but only one of the two may be excluded. The PEM quarantined
+	// first is excluded. The only Kelvin is always kept.
+	err := agentsInfo.UpdateAgentsInfo(&metadatapb.AgentUpdatesResponse{
+		AgentUpdates: []*metadatapb.AgentUpdate{
+			{
+				AgentID: uuidpbs[0],
+				Update: &metadatapb.AgentUpdate_Agent{
+					Agent: quarantine(agents[0], 20),
+				},
+			},
+			{
+				AgentID: uuidpbs[1],
+				Update: &metadatapb.AgentUpdate_Agent{
+					Agent: quarantine(agents[1], 10),
+				},
+			},
+			{
+				AgentID: uuidpbs[2],
+				Update: &metadatapb.AgentUpdate_Agent{
+					Agent: quarantine(agents[2], 10),
+				},
+			},
+		},
+		EndOfVersion: true,
+	})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []*uuidpb.UUID{uuidpbs[0], uuidpbs[1]}, carnotAgentIDs())
+
+	// Once the excluded PEM is reinstated, the other one can be excluded.
+	err = agentsInfo.UpdateAgentsInfo(&metadatapb.AgentUpdatesResponse{
+		AgentUpdates: []*metadatapb.AgentUpdate{
+			{
+				AgentID: uuidpbs[2],
+				Update: &metadatapb.AgentUpdate_Agent{
+					Agent: agents[2],
+				},
+			},
+		},
+		EndOfVersion: true,
+	})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []*uuidpb.UUID{uuidpbs[1], uuidpbs[2]}, carnotAgentIDs())
+}
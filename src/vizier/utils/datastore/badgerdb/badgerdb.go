@@ -113,6 +113,34 @@ func (w *DataStore) GetWithPrefix(prefix string) ([]string, [][]byte, error) {
 	return keys, values, nil
 }
 
+// Walk calls fn for every key in the datastore, along with the key's remaining TTL.
+func (w *DataStore) Walk(fn func(key string, value []byte, ttl time.Duration) error) error {
+	txn := w.db.NewTransaction(false)
+	defer txn.Discard()
+
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	for it.Rewind(); it.Valid(); it.Next() {
+		item := it.Item()
+		var ttl time.Duration
+		if expiresAt := item.ExpiresAt(); expiresAt != 0 {
+			ttl = time.Until(time.Unix(int64(expiresAt), 0))
+			if ttl <= 0 {
+				continue
+			}
+		}
+		v, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if err := fn(string(item.KeyCopy(nil)), v, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Set puts the given key and value in the datastore.
 func (w *DataStore) Set(key string, value string) error {
 	txn := w.db.NewTransaction(true)
@@ -193,6 +221,12 @@ func (w *DataStore) DeleteWithPrefix(prefix string) error {
 	return wb.Flush()
 }
 
+// Size returns the approximate size of the LSM tree and value log on disk, in bytes.
+func (w *DataStore) Size() (int64, error) {
+	lsm, vlog := w.db.Size()
+	return lsm + vlog, nil
+}
+
 // Close stops the TTL watcher, and closes the underlying datastore.
 // All other operations will fail after calling Close.
 func (w *DataStore) Close() error {
@@ -94,6 +94,38 @@ func (w *DataStore) GetWithPrefix(prefix string) ([]string, [][]byte, error) {
 	return keys, vals, nil
 }
 
+// Walk calls fn for every key in the datastore, along with the key's remaining TTL.
+func (w *DataStore) Walk(fn func(key string, value []byte, ttl time.Duration) error) error {
+	var keys, vals []string
+	var ttls []time.Duration
+	// Collect the keys first, so that fn doesn't run while holding the database lock.
+	err := w.db.View(func(tx *buntdb.Tx) error {
+		return tx.Ascend("", func(k, v string) bool {
+			ttl, err := tx.TTL(k)
+			if err != nil {
+				// The key expired while iterating.
+				return true
+			}
+			if ttl < 0 {
+				ttl = 0
+			}
+			keys = append(keys, k)
+			vals = append(vals, v)
+			ttls = append(ttls, ttl)
+			return true
+		})
+	})
+	if err != nil {
+		return err
+	}
+	for i := range keys {
+		if err := fn(keys[i], []byte(vals[i]), ttls[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Set puts the given key and value in the datastore.
 func (w *DataStore) Set(key string, value string) error {
 	return w.db.Update(func(tx *buntdb.Tx) error {
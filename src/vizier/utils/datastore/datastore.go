@@ -18,7 +18,11 @@
 
 package datastore
 
-import "time"
+import (
+	"time"
+
+	"px.dev/pixie/src/vizier/utils/datastore/watch"
+)
 
 // Getter is a datastore that implements a simple way to get values.
 type Getter interface {
@@ -61,6 +65,28 @@ type Closer interface {
 	Close() error
 }
 
+// Walker is a datastore that can visit every key it contains, along with the key's remaining TTL.
+type Walker interface {
+	// Walk calls fn for every key in the datastore, in key order. The ttl is 0 for keys that
+	// were set without a TTL. Walk stops at, and returns, the first error returned by fn.
+	// The keys visited are a consistent view of the datastore at the time Walk was called.
+	Walk(fn func(key string, value []byte, ttl time.Duration) error) error
+}
+
+// Watcher is a datastore that can notify subscribers of changes to its keys.
+type Watcher interface {
+	// Watch subscribes to puts, deletes and expirations of keys with the given prefix. The
+	// channel is closed when cancel is called, or if the subscriber falls too far behind, in
+	// which case the subscriber should re-read any state it needs and watch again. Datastores
+	// that wrap another datastore return watch.ErrNotSupported if the wrapped one isn't a Watcher.
+	Watch(prefix string) (events <-chan *watch.Event, cancel func(), err error)
+}
+
+// Sizer is a datastore that can report how much space it takes up on disk.
+type Sizer interface {
+	Size() (int64, error)
+}
+
 // MultiGetterSetterDeleterCloser combines MultiGetter, TTLSetter, MultiDeleter, and Closer.
 type MultiGetterSetterDeleterCloser interface {
 	MultiGetter
@@ -19,6 +19,7 @@
 package datastore
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -252,6 +253,26 @@ func TestDatastore(t *testing.T) {
 				})
 			}
 
+			t.Run("Walk", func(t *testing.T) {
+				require.NoError(t, db.Set("walk/a", "a"))
+				require.NoError(t, db.SetWithTTL("walk/b", "b", time.Hour))
+
+				var keys []string
+				ttls := make(map[string]time.Duration)
+				err := db.(Walker).Walk(func(key string, value []byte, ttl time.Duration) error {
+					if !strings.HasPrefix(key, "walk/") {
+						return nil
+					}
+					keys = append(keys, key)
+					ttls[key] = ttl
+					return nil
+				})
+				require.NoError(t, err)
+				assert.Equal(t, []string{"walk/a", "walk/b"}, keys)
+				assert.Equal(t, time.Duration(0), ttls["walk/a"])
+				assert.True(t, ttls["walk/b"] > 55*time.Minute && ttls["walk/b"] <= time.Hour)
+			})
+
 			err := db.Close()
 			assert.NoError(t, err)
 
@@ -0,0 +1,530 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+// Package encrypted wraps a datastore so that values are encrypted at rest. Keys are left in
+// plaintext, so that range and prefix scans keep working.
+package encrypted
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"px.dev/pixie/src/vizier/utils/datastore"
+	"px.dev/pixie/src/vizier/utils/datastore/watch"
+)
+
+// Values are envelope encrypted: each value is encrypted with its own data key, and the data key
+// is encrypted (wrapped) with a key encryption key from the keyring. An encrypted value is:
+// magic, key ID length (1 byte), key ID, wrapped data key, nonce, ciphertext.
+// The datastore key is authenticated along with the value, so that values can't be swapped
+// between keys. The magic starts with a zero byte, which can't start a valid protobuf, so values
+// written before encryption was enabled can be told apart from encrypted ones.
+var magic = []byte("\x00pxenc1")
+
+const (
+	dataKeySize = 32
+	nonceSize   = 12
+	tagSize     = 16
+	wrappedSize = nonceSize + dataKeySize + tagSize
+)
+
+var (
+	// ErrUnknownKey is returned when a value was encrypted with a key that isn't in the keyring.
+	ErrUnknownKey = errors.New("value was encrypted with an unknown key")
+	// ErrDecrypt is returned when a value can't be decrypted.
+	ErrDecrypt = errors.New("failed to decrypt value")
+)
+
+// Datastore is the underlying datastore that holds the encrypted values.
+type Datastore interface {
+	datastore.MultiGetterSetterDeleterCloser
+	datastore.Walker
+}
+
+// DataStore encrypts the values written to, and decrypts the values read from, a datastore.
+type DataStore struct {
+	ds Datastore
+
+	keyringMu sync.RWMutex
+	keyring   *Keyring
+
+	// Serializes writes with re-encryption and keyring changes, so that re-encryption doesn't
+	// overwrite newer values, and no value is written with a key that was replaced before the
+	// write finished. It is taken before keyringMu.
+	writeMu sync.Mutex
+	// Serializes re-encryption passes.
+	reencryptMu sync.Mutex
+}
+
+// New creates an encrypting datastore over ds.
+func New(ds Datastore, keyring *Keyring) *DataStore {
+	return &DataStore{ds: ds, keyring: keyring}
+}
+
+func (w *DataStore) getKeyring() *Keyring {
+	w.keyringMu.RLock()
+	defer w.keyringMu.RUnlock()
+	return w.keyring
+}
+
+// SetKeyring replaces the keyring. Values are encrypted with the new primary key from now on.
+// Call ReEncrypt to re-encrypt existing values with it.
+func (w *DataStore) SetKeyring(keyring *Keyring) {
+	// Wait for in-flight writes, so that every write sealed with the old primary key is in the
+	// datastore before a re-encryption pass can start.
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+	w.keyringMu.Lock()
+	defer w.keyringMu.Unlock()
+	w.keyring = keyring
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func isEncrypted(value []byte) bool {
+	return bytes.HasPrefix(value, magic)
+}
+
+// wrap encrypts the data key with the given key encryption key.
+func wrap(kek []byte, dataKey []byte, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomBytes(nonceSize)
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, dataKey, aad), nil
+}
+
+func unwrap(kek []byte, wrapped []byte, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := gcm.Open(nil, wrapped[:nonceSize], wrapped[nonceSize:], aad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return dataKey, nil
+}
+
+func seal(kr *Keyring, key string, value []byte) ([]byte, error) {
+	dataKey, err := randomBytes(dataKeySize)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := wrap(kr.keys[kr.primary], dataKey, []byte(key))
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomBytes(nonceSize)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(magic)+1+len(kr.primary)+wrappedSize+nonceSize+len(value)+tagSize)
+	out = append(out, magic...)
+	out = append(out, byte(len(kr.primary)))
+	out = append(out, kr.primary...)
+	out = append(out, wrapped...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, value, []byte(key)), nil
+}
+
+type envelope struct {
+	keyID      string
+	wrapped    []byte
+	nonce      []byte
+	ciphertext []byte
+}
+
+func parseEnvelope(value []byte) (*envelope, error) {
+	b := value[len(magic):]
+	if len(b) < 1 {
+		return nil, ErrDecrypt
+	}
+	idLen := int(b[0])
+	b = b[1:]
+	if len(b) < idLen+wrappedSize+nonceSize+tagSize {
+		return nil, ErrDecrypt
+	}
+	return &envelope{
+		keyID:      string(b[:idLen]),
+		wrapped:    b[idLen : idLen+wrappedSize],
+		nonce:      b[idLen+wrappedSize : idLen+wrappedSize+nonceSize],
+		ciphertext: b[idLen+wrappedSize+nonceSize:],
+	}, nil
+}
+
+func open(kr *Keyring, key string, value []byte) ([]byte, error) {
+	if !isEncrypted(value) {
+		// The value was written before encryption was enabled.
+		return value, nil
+	}
+	env, err := parseEnvelope(value)
+	if err != nil {
+		return nil, err
+	}
+	kek, ok := kr.keys[env.keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, env.keyID)
+	}
+	dataKey, err := unwrap(kek, env.wrapped, []byte(key))
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, env.nonce, env.ciphertext, []byte(key))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// rewrap re-encrypts the data key of an encrypted value with the primary key, or encrypts a
+// plaintext value. The value itself doesn't need to be re-encrypted. It returns nil if the value
+// is already encrypted with the primary key.
+func rewrap(kr *Keyring, key string, value []byte) ([]byte, error) {
+	if !isEncrypted(value) {
+		return seal(kr, key, value)
+	}
+	env, err := parseEnvelope(value)
+	if err != nil {
+		return nil, err
+	}
+	if env.keyID == kr.primary {
+		return nil, nil
+	}
+	kek, ok := kr.keys[env.keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKey, env.keyID)
+	}
+	dataKey, err := unwrap(kek, env.wrapped, []byte(key))
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := wrap(kr.keys[kr.primary], dataKey, []byte(key))
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(magic)+1+len(kr.primary)+wrappedSize+len(env.nonce)+len(env.ciphertext))
+	out = append(out, magic...)
+	out = append(out, byte(len(kr.primary)))
+	out = append(out, kr.primary...)
+	out = append(out, wrapped...)
+	out = append(out, env.nonce...)
+	return append(out, env.ciphertext...), nil
+}
+
+func (w *DataStore) decryptAll(keys []string, values [][]byte) ([][]byte, error) {
+	kr := w.getKeyring()
+	for i, v := range values {
+		plaintext, err := open(kr, keys[i], v)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s: %w", keys[i], err)
+		}
+		values[i] = plaintext
+	}
+	return values, nil
+}
+
+// Get gets the decrypted value for the given key.
+func (w *DataStore) Get(key string) ([]byte, error) {
+	v, err := w.ds.Get(key)
+	if err != nil || v == nil {
+		return v, err
+	}
+	plaintext, err := open(w.getKeyring(), key, v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", key, err)
+	}
+	return plaintext, nil
+}
+
+// GetWithRange gets the decrypted values of all keys within the given range.
+func (w *DataStore) GetWithRange(from string, to string) ([]string, [][]byte, error) {
+	keys, values, err := w.ds.GetWithRange(from, to)
+	if err != nil {
+		return nil, nil, err
+	}
+	values, err = w.decryptAll(keys, values)
+	if err != nil {
+		return nil, nil, err
+	}
+	return keys, values, nil
+}
+
+// GetWithPrefix gets the decrypted values of all keys with the given prefix.
+func (w *DataStore) GetWithPrefix(prefix string) ([]string, [][]byte, error) {
+	keys, values, err := w.ds.GetWithPrefix(prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+	values, err = w.decryptAll(keys, values)
+	if err != nil {
+		return nil, nil, err
+	}
+	return keys, values, nil
+}
+
+// Set encrypts the value and sets it for the given key.
+func (w *DataStore) Set(key string, value string) error {
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+	ciphertext, err := seal(w.getKeyring(), key, []byte(value))
+	if err != nil {
+		return err
+	}
+	return w.ds.Set(key, string(ciphertext))
+}
+
+// SetWithTTL encrypts the value and sets it for the given key, with a TTL.
+func (w *DataStore) SetWithTTL(key string, value string, ttl time.Duration) error {
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+	ciphertext, err := seal(w.getKeyring(), key, []byte(value))
+	if err != nil {
+		return err
+	}
+	return w.ds.SetWithTTL(key, string(ciphertext), ttl)
+}
+
+// Delete deletes the given key.
+func (w *DataStore) Delete(key string) error {
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+	return w.ds.Delete(key)
+}
+
+// DeleteAll deletes all of the given keys.
+func (w *DataStore) DeleteAll(keys []string) error {
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+	return w.ds.DeleteAll(keys)
+}
+
+// DeleteWithPrefix deletes all keys with the given prefix.
+func (w *DataStore) DeleteWithPrefix(prefix string) error {
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+	return w.ds.DeleteWithPrefix(prefix)
+}
+
+// Close closes the underlying datastore.
+func (w *DataStore) Close() error {
+	return w.ds.Close()
+}
+
+// Walk calls fn for every key in the datastore, along with its decrypted value and remaining TTL.
+func (w *DataStore) Walk(fn func(key string, value []byte, ttl time.Duration) error) error {
+	kr := w.getKeyring()
+	return w.ds.Walk(func(key string, value []byte, ttl time.Duration) error {
+		plaintext, err := open(kr, key, value)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt %s: %w", key, err)
+		}
+		return fn(key, plaintext, ttl)
+	})
+}
+
+// Watch watches the underlying datastore, and decrypts the values of put events. Values that
+// can't be decrypted are logged and their events dropped.
+func (w *DataStore) Watch(prefix string) (<-chan *watch.Event, func(), error) {
+	watcher, ok := w.ds.(datastore.Watcher)
+	if !ok {
+		return nil, nil, watch.ErrNotSupported
+	}
+	in, cancelIn, err := watcher.Watch(prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	out := make(chan *watch.Event, watch.BufferSize)
+	done := make(chan struct{})
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			close(done)
+			cancelIn()
+		})
+	}
+	go func() {
+		defer close(out)
+		for ev := range in {
+			if ev.Type == watch.Put {
+				plaintext, err := open(w.getKeyring(), ev.Key, ev.Value)
+				if err != nil {
+					log.WithError(err).WithField("key", ev.Key).Error("Failed to decrypt watched value")
+					continue
+				}
+				ev = &watch.Event{Type: ev.Type, Key: ev.Key, Value: plaintext}
+			}
+			select {
+			case out <- ev:
+			case <-done:
+				return
+			}
+		}
+	}()
+	return out, cancel, nil
+}
+
+// Size returns the on-disk size of the underlying datastore.
+func (w *DataStore) Size() (int64, error) {
+	sizer, ok := w.ds.(datastore.Sizer)
+	if !ok {
+		return 0, errors.New("datastore does not report its size")
+	}
+	return sizer.Size()
+}
+
+// ReEncryptStats summarizes a re-encryption pass.
+type ReEncryptStats struct {
+	// Keys is the number of keys visited.
+	Keys int
+	// Updated is the number of keys that were re-encrypted with the primary key.
+	Updated int
+}
+
+// ReEncrypt makes sure that every value is encrypted with the primary key. Values encrypted with
+// an older key have their data key re-wrapped, and values written before encryption was enabled
+// are encrypted. Once ReEncrypt succeeds, the older keys can be removed from the keyring.
+func (w *DataStore) ReEncrypt() (*ReEncryptStats, error) {
+	w.reencryptMu.Lock()
+	defer w.reencryptMu.Unlock()
+
+	kr := w.getKeyring()
+	stats := &ReEncryptStats{}
+	err := w.ds.Walk(func(key string, value []byte, ttl time.Duration) error {
+		stats.Keys++
+		updated, err := w.reencryptKey(kr, key, value, ttl)
+		if err != nil {
+			return fmt.Errorf("failed to re-encrypt %s: %w", key, err)
+		}
+		if updated {
+			stats.Updated++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func (w *DataStore) reencryptKey(kr *Keyring, key string, value []byte, ttl time.Duration) (bool, error) {
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+
+	// The value may have changed since the walk read it. Only update it if it hasn't, so that
+	// newer values and deletes aren't overwritten.
+	current, err := w.ds.Get(key)
+	if err != nil {
+		return false, err
+	}
+	if current == nil || !bytes.Equal(current, value) {
+		return false, nil
+	}
+	rewrapped, err := rewrap(kr, key, value)
+	if err != nil || rewrapped == nil {
+		return false, err
+	}
+	if ttl > 0 {
+		err = w.ds.SetWithTTL(key, string(rewrapped), ttl)
+	} else {
+		err = w.ds.Set(key, string(rewrapped))
+	}
+	return err == nil, err
+}
+
+// WatchKeyring reloads the keyring from dir every interval, until the quit channel is closed.
+// When the primary key changes, existing values are re-encrypted with the new primary key in
+// the background.
+func (w *DataStore) WatchKeyring(dir string, interval time.Duration, quitCh <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-quitCh:
+			return
+		case <-t.C:
+		}
+
+		kr, err := LoadKeyring(dir)
+		if err != nil {
+			log.WithError(err).Error("Failed to reload datastore encryption keys")
+			continue
+		}
+		if kr.Primary() == w.getKeyring().Primary() {
+			w.SetKeyring(kr)
+			continue
+		}
+		log.WithField("primary", kr.Primary()).Info("Datastore encryption key rotated, re-encrypting values")
+		w.SetKeyring(kr)
+		w.ReEncryptInBackground()
+	}
+}
+
+func (w *DataStore) reencryptAndLog() {
+	start := time.Now()
+	stats, err := w.ReEncrypt()
+	if err != nil {
+		log.WithError(err).Error("Failed to re-encrypt datastore")
+		return
+	}
+	log.WithField("keys", stats.Keys).WithField("updated", stats.Updated).
+		WithField("duration", time.Since(start)).
+		Info("Re-encrypted datastore, keys other than the primary can be removed")
+}
+
+// ReEncryptInBackground re-encrypts values with the primary key in a goroutine, logging the result.
+func (w *DataStore) ReEncryptInBackground() {
+	go w.reencryptAndLog()
+}
@@ -0,0 +1,255 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package encrypted_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/vizier/utils/datastore/encrypted"
+	"px.dev/pixie/src/vizier/utils/datastore/pebbledb"
+	"px.dev/pixie/src/vizier/utils/datastore/watch"
+)
+
+func setupPebble(t *testing.T) *pebbledb.DataStore {
+	db, err := pebble.Open("test", &pebble.Options{
+		FS: vfs.NewMem(),
+	})
+	require.NoError(t, err)
+	ds := pebbledb.New(db, 3*time.Second)
+	t.Cleanup(func() { ds.Close() })
+	return ds
+}
+
+func key(b byte) []byte {
+	return bytes.Repeat([]byte{b}, encrypted.KeySize)
+}
+
+func mustKeyring(t *testing.T, primary string, keys map[string][]byte) *encrypted.Keyring {
+	kr, err := encrypted.NewKeyring(primary, keys)
+	require.NoError(t, err)
+	return kr
+}
+
+func TestDataStore_EncryptsValues(t *testing.T) {
+	raw := setupPebble(t)
+	ds := encrypted.New(raw, mustKeyring(t, "k1", map[string][]byte{"k1": key(1)}))
+
+	require.NoError(t, ds.Set("/agent/1", "agent one"))
+	require.NoError(t, ds.Set("/agent/2", "agent two"))
+	require.NoError(t, ds.Set("/tracepoint/1", "tracepoint"))
+
+	v, err := ds.Get("/agent/1")
+	require.NoError(t, err)
+	assert.Equal(t, "agent one", string(v))
+
+	// The underlying datastore only has ciphertext, but the keys are in plaintext.
+	rawVal, err := raw.Get("/agent/1")
+	require.NoError(t, err)
+	assert.NotContains(t, string(rawVal), "agent one")
+
+	keys, values, err := ds.GetWithPrefix("/agent/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/agent/1", "/agent/2"}, keys)
+	assert.Equal(t, [][]byte{[]byte("agent one"), []byte("agent two")}, values)
+
+	keys, values, err = ds.GetWithRange("/agent/2", "/tracepoint/2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/agent/2", "/tracepoint/1"}, keys)
+	assert.Equal(t, [][]byte{[]byte("agent two"), []byte("tracepoint")}, values)
+
+	v, err = ds.Get("/agent/3")
+	require.NoError(t, err)
+	assert.Nil(t, v)
+
+	// Values can't be moved to another key.
+	require.NoError(t, raw.Set("/agent/3", string(rawVal)))
+	_, err = ds.Get("/agent/3")
+	assert.ErrorIs(t, err, encrypted.ErrDecrypt)
+}
+
+func TestDataStore_SetWithTTL(t *testing.T) {
+	raw := setupPebble(t)
+	ds := encrypted.New(raw, mustKeyring(t, "k1", map[string][]byte{"k1": key(1)}))
+	require.NoError(t, ds.SetWithTTL("/resourceUpdate/1", "update", time.Hour))
+
+	var walked int
+	err := ds.Walk(func(key string, value []byte, ttl time.Duration) error {
+		walked++
+		assert.Equal(t, "update", string(value))
+		assert.True(t, ttl > 55*time.Minute && ttl <= time.Hour)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, walked)
+}
+
+func TestDataStore_Watch(t *testing.T) {
+	raw := setupPebble(t)
+	ds := encrypted.New(raw, mustKeyring(t, "k1", map[string][]byte{"k1": key(1)}))
+
+	ch, cancel, err := ds.Watch("/agent/")
+	require.NoError(t, err)
+	defer cancel()
+
+	require.NoError(t, ds.Set("/agent/1", "agent one"))
+	require.NoError(t, ds.Delete("/agent/1"))
+	assert.Equal(t, &watch.Event{Type: watch.Put, Key: "/agent/1", Value: []byte("agent one")}, <-ch)
+	assert.Equal(t, &watch.Event{Type: watch.Delete, Key: "/agent/1"}, <-ch)
+
+	cancel()
+	_, ok := <-ch
+	assert.False(t, ok)
+}
+
+func TestDataStore_RotateKeys(t *testing.T) {
+	raw := setupPebble(t)
+	// Values written before encryption was enabled are still readable.
+	require.NoError(t, raw.Set("/agent/0", "plaintext agent"))
+
+	ds := encrypted.New(raw, mustKeyring(t, "k1", map[string][]byte{"k1": key(1)}))
+	require.NoError(t, ds.Set("/agent/1", "agent one"))
+	require.NoError(t, ds.SetWithTTL("/resourceUpdate/1", "update", time.Hour))
+
+	v, err := ds.Get("/agent/0")
+	require.NoError(t, err)
+	assert.Equal(t, "plaintext agent", string(v))
+
+	// Rotate to k2, keeping k1 so that existing values can still be read.
+	ds.SetKeyring(mustKeyring(t, "k2", map[string][]byte{"k1": key(1), "k2": key(2)}))
+	require.NoError(t, ds.Set("/agent/2", "agent two"))
+	v, err = ds.Get("/agent/1")
+	require.NoError(t, err)
+	assert.Equal(t, "agent one", string(v))
+
+	stats, err := ds.ReEncrypt()
+	require.NoError(t, err)
+	assert.Equal(t, 4, stats.Keys)
+	// The value that was already encrypted with k2 is left alone.
+	assert.Equal(t, 3, stats.Updated)
+
+	rawVal, err := raw.Get("/agent/0")
+	require.NoError(t, err)
+	assert.NotContains(t, string(rawVal), "plaintext agent")
+
+	// Once everything is re-encrypted, k1 can be dropped.
+	ds.SetKeyring(mustKeyring(t, "k2", map[string][]byte{"k2": key(2)}))
+	keys, values, err := ds.GetWithPrefix("/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/agent/0", "/agent/1", "/agent/2", "/resourceUpdate/1"}, keys)
+	assert.Equal(t, "agent one", string(values[1]))
+	err = ds.Walk(func(key string, value []byte, ttl time.Duration) error {
+		if key == "/resourceUpdate/1" {
+			assert.True(t, ttl > 55*time.Minute && ttl <= time.Hour)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	stats, err = ds.ReEncrypt()
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.Updated)
+
+	// Values encrypted with a key that was dropped too early can't be read.
+	ds.SetKeyring(mustKeyring(t, "k3", map[string][]byte{"k3": key(3)}))
+	_, err = ds.Get("/agent/1")
+	assert.ErrorIs(t, err, encrypted.ErrUnknownKey)
+}
+
+func TestDataStore_RotateKeysWhileWriting(t *testing.T) {
+	raw := setupPebble(t)
+	ds := encrypted.New(raw, mustKeyring(t, "k1", map[string][]byte{"k1": key(1)}))
+
+	for w := 0; w < 4; w++ {
+		for i := 0; i < 50; i++ {
+			require.NoError(t, ds.Set(fmt.Sprintf("/agent/%d/%d", w, i), "value"))
+		}
+	}
+
+	// Writers keep writing while the key is rotated and the values are re-encrypted.
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				k := fmt.Sprintf("/agent/%d/%d", w, i%50)
+				if i%2 == 0 {
+					assert.NoError(t, ds.Set(k, "value"))
+				} else {
+					assert.NoError(t, ds.SetWithTTL(k, "value", time.Hour))
+				}
+			}
+		}(w)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	ds.SetKeyring(mustKeyring(t, "k2", map[string][]byte{"k1": key(1), "k2": key(2)}))
+	_, err := ds.ReEncrypt()
+	require.NoError(t, err)
+	close(done)
+	wg.Wait()
+
+	// No write sealed with k1 landed after the re-encryption pass, so k1 can be dropped.
+	ds.SetKeyring(mustKeyring(t, "k2", map[string][]byte{"k2": key(2)}))
+	keys, _, err := ds.GetWithPrefix("/agent/")
+	require.NoError(t, err)
+	assert.Len(t, keys, 200)
+}
+
+func TestLoadKeyring(t *testing.T) {
+	dir := t.TempDir()
+	// Mimic the layout of a mounted Kubernetes Secret.
+	dataDir := filepath.Join(dir, "..data")
+	require.NoError(t, os.Mkdir(dataDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, "k1"), key(1), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, "k2"), key(2), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, "primary"), []byte("k2\n"), 0o600))
+	for _, name := range []string{"k1", "k2", "primary"} {
+		require.NoError(t, os.Symlink(filepath.Join("..data", name), filepath.Join(dir, name)))
+	}
+
+	kr, err := encrypted.LoadKeyring(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "k2", kr.Primary())
+	assert.Equal(t, []string{"k1", "k2"}, kr.IDs())
+}
+
+func TestNewKeyring_Invalid(t *testing.T) {
+	_, err := encrypted.NewKeyring("k2", map[string][]byte{"k1": key(1)})
+	assert.Error(t, err)
+	_, err = encrypted.NewKeyring("k1", map[string][]byte{"k1": []byte("too short")})
+	assert.Error(t, err)
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package encrypted
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// KeySize is the size of the key encryption keys, which are AES-256 keys.
+const KeySize = 32
+
+// primaryKeyFile is the file in a key directory that names the primary key.
+const primaryKeyFile = "primary"
+
+// Keyring holds the key encryption keys. New values are encrypted with the primary key, and the
+// other keys are kept so that values encrypted before a rotation can still be read.
+type Keyring struct {
+	primary string
+	keys    map[string][]byte
+}
+
+// NewKeyring creates a keyring from the given keys, which are indexed by key ID.
+func NewKeyring(primary string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("primary key %q is not in the keyring", primary)
+	}
+	kr := &Keyring{primary: primary, keys: make(map[string][]byte, len(keys))}
+	for id, key := range keys {
+		if id == "" || len(id) > 255 {
+			return nil, fmt.Errorf("invalid key ID %q", id)
+		}
+		if len(key) != KeySize {
+			return nil, fmt.Errorf("key %q is %d bytes, expected %d", id, len(key), KeySize)
+		}
+		kr.keys[id] = append([]byte(nil), key...)
+	}
+	return kr, nil
+}
+
+// LoadKeyring loads a keyring from a directory, which is usually a mounted Kubernetes Secret.
+// Each file in the directory is a key, named by its key ID, except for the file named "primary",
+// which holds the ID of the primary key.
+func LoadKeyring(dir string) (*Keyring, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var primary string
+	keys := make(map[string][]byte)
+	for _, e := range entries {
+		// Secret volumes contain hidden directories and symlinks used for atomic updates.
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if e.Name() == primaryKeyFile {
+			primary = strings.TrimSpace(string(data))
+			continue
+		}
+		keys[e.Name()] = data
+	}
+	if primary == "" {
+		return nil, errors.New("the key directory does not name a primary key")
+	}
+	return NewKeyring(primary, keys)
+}
+
+// Primary returns the ID of the primary key.
+func (k *Keyring) Primary() string {
+	return k.primary
+}
+
+// IDs returns the IDs of all of the keys in the keyring, in sorted order.
+func (k *Keyring) IDs() []string {
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
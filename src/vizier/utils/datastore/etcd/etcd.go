@@ -23,8 +23,11 @@ import (
 	"sync"
 	"time"
 
+	log "github.com/sirupsen/logrus"
 	"go.etcd.io/etcd/api/v3/mvccpb"
 	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"px.dev/pixie/src/vizier/utils/datastore/watch"
 )
 
 // DataStore wraps a clientv3 datastore.
@@ -106,6 +109,40 @@ func (w *DataStore) GetWithPrefix(prefix string) ([]string, [][]byte, error) {
 	return kvsToSlices(resp.Kvs)
 }
 
+// Walk calls fn for every key in the datastore, along with the remaining TTL of the key's lease.
+func (w *DataStore) Walk(fn func(key string, value []byte, ttl time.Duration) error) error {
+	resp, err := w.client.Get(context.Background(), "", clientv3.WithFromKey(), clientv3.WithSerializable())
+	if err != nil {
+		return err
+	}
+
+	// Many keys can share a lease, so only look up each lease once.
+	leaseTTLs := make(map[int64]time.Duration)
+	for _, kv := range resp.Kvs {
+		var ttl time.Duration
+		if kv.Lease != 0 {
+			var ok bool
+			ttl, ok = leaseTTLs[kv.Lease]
+			if !ok {
+				leaseResp, err := w.client.TimeToLive(context.Background(), clientv3.LeaseID(kv.Lease))
+				if err != nil {
+					return err
+				}
+				ttl = time.Duration(leaseResp.TTL) * time.Second
+				leaseTTLs[kv.Lease] = ttl
+			}
+			if ttl <= 0 {
+				// The lease has expired.
+				continue
+			}
+		}
+		if err := fn(string(kv.Key), kv.Value, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Delete deletes the value for the given key from the datastore.
 func (w *DataStore) Delete(key string) error {
 	_, err := w.client.Delete(context.Background(), key)
@@ -129,6 +166,62 @@ func (w *DataStore) DeleteWithPrefix(prefix string) error {
 	return err
 }
 
+// Watch subscribes to changes to keys with the given prefix. Deletes of keys whose lease is no
+// longer alive are reported as expirations.
+func (w *DataStore) Watch(prefix string) (<-chan *watch.Event, func(), error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	wch := w.client.Watch(ctx, prefix, clientv3.WithPrefix(), clientv3.WithPrevKV())
+
+	ch := make(chan *watch.Event, watch.BufferSize)
+	go func() {
+		defer close(ch)
+		for resp := range wch {
+			if err := resp.Err(); err != nil {
+				log.WithError(err).WithField("prefix", prefix).Error("etcd watch failed")
+				return
+			}
+			for _, ev := range resp.Events {
+				e := &watch.Event{Type: watch.Put, Key: string(ev.Kv.Key), Value: ev.Kv.Value}
+				if ev.Type == mvccpb.DELETE {
+					e.Type = watch.Delete
+					e.Value = nil
+					if ev.PrevKv != nil && ev.PrevKv.Lease != 0 && !w.leaseAlive(ctx, clientv3.LeaseID(ev.PrevKv.Lease)) {
+						e.Type = watch.Expire
+					}
+				}
+				select {
+				case ch <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return ch, cancel, nil
+}
+
+func (w *DataStore) leaseAlive(ctx context.Context, id clientv3.LeaseID) bool {
+	resp, err := w.client.TimeToLive(ctx, id)
+	if err != nil {
+		// Assume the key was deleted explicitly.
+		return true
+	}
+	return resp.TTL > 0
+}
+
+// Size returns the size of the etcd database on disk, in bytes.
+func (w *DataStore) Size() (int64, error) {
+	var err error
+	for _, ep := range w.client.Endpoints() {
+		var resp *clientv3.StatusResponse
+		resp, err = w.client.Status(context.Background(), ep)
+		if err == nil {
+			return resp.DbSize, nil
+		}
+	}
+	return 0, err
+}
+
 // Close closes the underlying datastore.
 // All other operations will fail after calling Close.
 func (w *DataStore) Close() error {
@@ -0,0 +1,190 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+// Package instrumented wraps a datastore to export Prometheus metrics about it: the latency of
+// each operation, the number of keys under each top-level prefix, and its size on disk.
+package instrumented
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"px.dev/pixie/src/vizier/utils/datastore"
+	"px.dev/pixie/src/vizier/utils/datastore/watch"
+)
+
+// otherPrefix is the prefix that keys which aren't paths are counted under.
+const otherPrefix = "other"
+
+// Datastore is the underlying datastore that is instrumented.
+type Datastore interface {
+	datastore.MultiGetterSetterDeleterCloser
+	datastore.Walker
+}
+
+// DataStore records metrics about the operations on the datastore it wraps.
+type DataStore struct {
+	ds Datastore
+}
+
+// New creates a new instrumented DataStore.
+func New(ds Datastore) *DataStore {
+	return &DataStore{ds: ds}
+}
+
+func observe(op string, start time.Time, err *error) {
+	operationSeconds.WithLabelValues(op).Observe(time.Since(start).Seconds())
+	if *err != nil {
+		operationErrorsTotal.WithLabelValues(op).Inc()
+	}
+}
+
+// Get gets the value for the given key.
+func (d *DataStore) Get(key string) (v []byte, err error) {
+	defer observe(opGet, time.Now(), &err)
+	return d.ds.Get(key)
+}
+
+// GetWithRange gets all keys and values within the given range.
+func (d *DataStore) GetWithRange(from string, to string) (keys []string, values [][]byte, err error) {
+	defer observe(opGetWithRange, time.Now(), &err)
+	return d.ds.GetWithRange(from, to)
+}
+
+// GetWithPrefix gets all keys and values with the given prefix.
+func (d *DataStore) GetWithPrefix(prefix string) (keys []string, values [][]byte, err error) {
+	defer observe(opGetWithPrefix, time.Now(), &err)
+	return d.ds.GetWithPrefix(prefix)
+}
+
+// Set puts the given key and value in the datastore.
+func (d *DataStore) Set(key string, value string) (err error) {
+	defer observe(opSet, time.Now(), &err)
+	return d.ds.Set(key, value)
+}
+
+// SetWithTTL puts the given key and value into the datastore with a TTL.
+func (d *DataStore) SetWithTTL(key string, value string, ttl time.Duration) (err error) {
+	defer observe(opSetWithTTL, time.Now(), &err)
+	return d.ds.SetWithTTL(key, value, ttl)
+}
+
+// Delete deletes the given key.
+func (d *DataStore) Delete(key string) (err error) {
+	defer observe(opDelete, time.Now(), &err)
+	return d.ds.Delete(key)
+}
+
+// DeleteAll deletes all of the given keys.
+func (d *DataStore) DeleteAll(keys []string) (err error) {
+	defer observe(opDeleteAll, time.Now(), &err)
+	return d.ds.DeleteAll(keys)
+}
+
+// DeleteWithPrefix deletes all keys with the given prefix.
+func (d *DataStore) DeleteWithPrefix(prefix string) (err error) {
+	defer observe(opDeleteWithPrefix, time.Now(), &err)
+	return d.ds.DeleteWithPrefix(prefix)
+}
+
+// Walk walks the underlying datastore. The recorded latency includes the time spent in fn.
+func (d *DataStore) Walk(fn func(key string, value []byte, ttl time.Duration) error) (err error) {
+	defer observe(opWalk, time.Now(), &err)
+	return d.ds.Walk(fn)
+}
+
+// Watch watches the underlying datastore.
+func (d *DataStore) Watch(prefix string) (<-chan *watch.Event, func(), error) {
+	w, ok := d.ds.(datastore.Watcher)
+	if !ok {
+		return nil, nil, watch.ErrNotSupported
+	}
+	return w.Watch(prefix)
+}
+
+// Size returns the on-disk size of the underlying datastore.
+func (d *DataStore) Size() (int64, error) {
+	s, ok := d.ds.(datastore.Sizer)
+	if !ok {
+		return 0, errors.New("datastore does not report its size")
+	}
+	return s.Size()
+}
+
+// Close closes the underlying datastore.
+func (d *DataStore) Close() error {
+	return d.ds.Close()
+}
+
+// keyPrefix returns the first segment of a key's path, such as "/agent/" for "/agent/1234".
+func keyPrefix(key string) string {
+	if !strings.HasPrefix(key, "/") {
+		return otherPrefix
+	}
+	idx := strings.Index(key[1:], "/")
+	if idx < 0 {
+		return otherPrefix
+	}
+	return key[:idx+2]
+}
+
+// UpdateStats walks the datastore to count its keys by prefix, and records its size on disk if the
+// underlying datastore reports it.
+func (d *DataStore) UpdateStats() error {
+	counts := make(map[string]int)
+	err := d.ds.Walk(func(key string, value []byte, ttl time.Duration) error {
+		counts[keyPrefix(key)]++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Reset so that prefixes that no longer have any keys aren't reported.
+	keys.Reset()
+	for prefix, n := range counts {
+		keys.WithLabelValues(prefix).Set(float64(n))
+	}
+
+	// Not every datastore can report its size, so failures here are only logged.
+	size, err := d.Size()
+	if err != nil {
+		log.WithError(err).Debug("Failed to get datastore size")
+		return nil
+	}
+	sizeBytes.Set(float64(size))
+	return nil
+}
+
+// RunStatsUpdater updates the stats every interval, until quitCh is closed.
+func (d *DataStore) RunStatsUpdater(interval time.Duration, quitCh <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := d.UpdateStats(); err != nil {
+			log.WithError(err).Error("Failed to update datastore stats")
+		}
+		select {
+		case <-quitCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package instrumented
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/vizier/utils/datastore/pebbledb"
+	"px.dev/pixie/src/vizier/utils/datastore/watch"
+)
+
+func setupPebble(t *testing.T) *pebbledb.DataStore {
+	db, err := pebble.Open("test", &pebble.Options{
+		FS: vfs.NewMem(),
+	})
+	require.NoError(t, err)
+	ds := pebbledb.New(db, 3*time.Second)
+	t.Cleanup(func() { ds.Close() })
+	return ds
+}
+
+// metricValues returns the values of the metric with the given name, keyed by the value of label.
+// Histograms are reported by their sample count.
+func metricValues(t *testing.T, name string, label string) map[string]float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	values := make(map[string]float64)
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			var key string
+			for _, l := range m.GetLabel() {
+				if l.GetName() == label {
+					key = l.GetValue()
+				}
+			}
+			if m.GetHistogram() != nil {
+				values[key] = float64(m.GetHistogram().GetSampleCount())
+			} else {
+				values[key] = m.GetGauge().GetValue()
+			}
+		}
+	}
+	return values
+}
+
+func TestKeyPrefix(t *testing.T) {
+	assert.Equal(t, "/agent/", keyPrefix("/agent/1234"))
+	assert.Equal(t, "/agent/", keyPrefix("/agent/1234/data"))
+	assert.Equal(t, otherPrefix, keyPrefix("/agent"))
+	assert.Equal(t, otherPrefix, keyPrefix("agent/1234"))
+}
+
+func TestDataStore(t *testing.T) {
+	operationSeconds.Reset()
+	ds := New(setupPebble(t))
+
+	require.NoError(t, ds.Set("/agent/1", "a1"))
+	require.NoError(t, ds.Set("/agent/2", "a2"))
+	require.NoError(t, ds.SetWithTTL("/tracepoint/1", "t1", time.Hour))
+	require.NoError(t, ds.Set("schema", "s"))
+	v, err := ds.Get("/agent/1")
+	require.NoError(t, err)
+	assert.Equal(t, "a1", string(v))
+
+	ch, cancel, err := ds.Watch("/agent/")
+	require.NoError(t, err)
+	require.NoError(t, ds.Delete("/agent/2"))
+	assert.Equal(t, &watch.Event{Type: watch.Delete, Key: "/agent/2"}, <-ch)
+	cancel()
+
+	counts := metricValues(t, "datastore_operation_seconds", "op")
+	assert.Equal(t, 3.0, counts[opSet])
+	assert.Equal(t, 1.0, counts[opSetWithTTL])
+	assert.Equal(t, 1.0, counts[opGet])
+	assert.Equal(t, 1.0, counts[opDelete])
+
+	require.NoError(t, ds.UpdateStats())
+	assert.Equal(t, map[string]float64{"/agent/": 1, "/tracepoint/": 1, otherPrefix: 1},
+		metricValues(t, "datastore_keys", "prefix"))
+	assert.True(t, metricValues(t, "datastore_size_bytes", "")[""] > 0)
+
+	// Prefixes that no longer have keys are dropped.
+	require.NoError(t, ds.DeleteWithPrefix("/agent/"))
+	require.NoError(t, ds.UpdateStats())
+	assert.Equal(t, map[string]float64{"/tracepoint/": 1, otherPrefix: 1},
+		metricValues(t, "datastore_keys", "prefix"))
+}
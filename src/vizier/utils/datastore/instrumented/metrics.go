@@ -0,0 +1,65 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package instrumented
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The operations that latencies are recorded for in datastore_operation_seconds.
+const (
+	opGet              = "get"
+	opGetWithRange     = "get_with_range"
+	opGetWithPrefix    = "get_with_prefix"
+	opSet              = "set"
+	opSetWithTTL       = "set_with_ttl"
+	opDelete           = "delete"
+	opDeleteAll        = "delete_all"
+	opDeleteWithPrefix = "delete_with_prefix"
+	opWalk             = "walk"
+)
+
+var (
+	operationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "datastore_operation_seconds",
+		Help:    "The latency of datastore operations, by operation.",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 2, 16),
+	}, []string{"op"})
+	operationErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "datastore_operation_errors_total",
+		Help: "The number of datastore operations that failed, by operation.",
+	}, []string{"op"})
+	keys = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "datastore_keys",
+		Help: "The number of keys in the datastore, by the first segment of the key's path.",
+	}, []string{"prefix"})
+	sizeBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "datastore_size_bytes",
+		Help: "The approximate size of the datastore on disk.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(
+		operationSeconds,
+		operationErrorsTotal,
+		keys,
+		sizeBytes,
+	)
+}
@@ -0,0 +1,268 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package migrate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"px.dev/pixie/src/vizier/utils/datastore"
+	"px.dev/pixie/src/vizier/utils/datastore/watch"
+)
+
+// Datastore is a datastore that can be migrated from.
+type Datastore interface {
+	datastore.MultiGetterSetterDeleterCloser
+	datastore.Walker
+}
+
+// DualWriteDatastore reads from a primary datastore and writes to both the primary and a secondary
+// datastore. It is used to move to a new datastore without downtime: writes made while existing
+// keys are backfilled into the secondary are not lost.
+type DualWriteDatastore struct {
+	primary   Datastore
+	secondary Datastore
+
+	// mu serializes writes with the backfill, so that the backfill never overwrites a newer value.
+	mu sync.Mutex
+}
+
+// NewDualWriteDatastore creates a new DualWriteDatastore.
+func NewDualWriteDatastore(primary Datastore, secondary Datastore) *DualWriteDatastore {
+	return &DualWriteDatastore{primary: primary, secondary: secondary}
+}
+
+func (d *DualWriteDatastore) write(op string, primaryFn, secondaryFn func() error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if err := primaryFn(); err != nil {
+		return err
+	}
+	// The primary is the source of truth, so failed secondary writes are only logged. They are
+	// caught when the datastores are verified.
+	if err := secondaryFn(); err != nil {
+		log.WithError(err).WithField("op", op).Error("Failed to write to secondary datastore")
+	}
+	return nil
+}
+
+// Get gets the value for the given key from the primary datastore.
+func (d *DualWriteDatastore) Get(key string) ([]byte, error) {
+	return d.primary.Get(key)
+}
+
+// GetWithRange gets all keys and values within the given range from the primary datastore.
+func (d *DualWriteDatastore) GetWithRange(from string, to string) ([]string, [][]byte, error) {
+	return d.primary.GetWithRange(from, to)
+}
+
+// GetWithPrefix gets all keys and values with the given prefix from the primary datastore.
+func (d *DualWriteDatastore) GetWithPrefix(prefix string) ([]string, [][]byte, error) {
+	return d.primary.GetWithPrefix(prefix)
+}
+
+// Walk walks the primary datastore.
+func (d *DualWriteDatastore) Walk(fn func(key string, value []byte, ttl time.Duration) error) error {
+	return d.primary.Walk(fn)
+}
+
+// Watch watches the primary datastore.
+func (d *DualWriteDatastore) Watch(prefix string) (<-chan *watch.Event, func(), error) {
+	w, ok := d.primary.(datastore.Watcher)
+	if !ok {
+		return nil, nil, watch.ErrNotSupported
+	}
+	return w.Watch(prefix)
+}
+
+// Size returns the on-disk size of the primary datastore.
+func (d *DualWriteDatastore) Size() (int64, error) {
+	s, ok := d.primary.(datastore.Sizer)
+	if !ok {
+		return 0, errors.New("primary datastore does not report its size")
+	}
+	return s.Size()
+}
+
+// Set puts the given key and value in both datastores.
+func (d *DualWriteDatastore) Set(key string, value string) error {
+	return d.write("Set",
+		func() error { return d.primary.Set(key, value) },
+		func() error { return d.secondary.Set(key, value) })
+}
+
+// SetWithTTL puts the given key and value into both datastores with a TTL.
+func (d *DualWriteDatastore) SetWithTTL(key string, value string, ttl time.Duration) error {
+	return d.write("SetWithTTL",
+		func() error { return d.primary.SetWithTTL(key, value, ttl) },
+		func() error { return d.secondary.SetWithTTL(key, value, ttl) })
+}
+
+// Delete deletes the value for the given key from both datastores.
+func (d *DualWriteDatastore) Delete(key string) error {
+	return d.write("Delete",
+		func() error { return d.primary.Delete(key) },
+		func() error { return d.secondary.Delete(key) })
+}
+
+// DeleteAll deletes all of the given keys from both datastores.
+func (d *DualWriteDatastore) DeleteAll(keys []string) error {
+	return d.write("DeleteAll",
+		func() error { return d.primary.DeleteAll(keys) },
+		func() error { return d.secondary.DeleteAll(keys) })
+}
+
+// DeleteWithPrefix deletes all keys and values with the given prefix from both datastores.
+func (d *DualWriteDatastore) DeleteWithPrefix(prefix string) error {
+	return d.write("DeleteWithPrefix",
+		func() error { return d.primary.DeleteWithPrefix(prefix) },
+		func() error { return d.secondary.DeleteWithPrefix(prefix) })
+}
+
+// Close closes both datastores.
+func (d *DualWriteDatastore) Close() error {
+	err := d.primary.Close()
+	if secondaryErr := d.secondary.Close(); err == nil {
+		err = secondaryErr
+	}
+	return err
+}
+
+// Backfill copies every key in the primary datastore to the secondary datastore, unless the
+// secondary already has the same value, and deletes the keys that are only in the secondary, such
+// as keys left behind by an earlier, interrupted migration. It can run while the datastore is in
+// use. Each key is re-read under the write lock, so keys that are updated or deleted during the
+// backfill are never reverted.
+func (d *DualWriteDatastore) Backfill() (*Stats, error) {
+	stats := &Stats{}
+	err := d.primary.Walk(func(key string, _ []byte, ttl time.Duration) error {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		value, err := d.primary.Get(key)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			// Deleted since the walk read it.
+			return nil
+		}
+		existing, err := d.secondary.Get(key)
+		if err != nil {
+			return err
+		}
+		if existing == nil || !bytes.Equal(existing, value) {
+			if ttl > 0 {
+				err = d.secondary.SetWithTTL(key, string(value), ttl)
+			} else {
+				err = d.secondary.Set(key, string(value))
+			}
+			if err != nil {
+				return fmt.Errorf("failed to backfill key %s: %w", key, err)
+			}
+		}
+		stats.add(key, value)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, d.secondary.Walk(func(key string, _ []byte, _ time.Duration) error {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		value, err := d.primary.Get(key)
+		if err != nil {
+			return err
+		}
+		if value != nil {
+			return nil
+		}
+		if err := d.secondary.Delete(key); err != nil {
+			return fmt.Errorf("failed to delete stale key %s: %w", key, err)
+		}
+		return nil
+	})
+}
+
+// Verify checks that the secondary datastore contains the same keys and values as the primary. It
+// returns ErrMismatch if it doesn't. It can run while the datastore is in use. Like the backfill,
+// each key is re-read from both datastores under the write lock, so keys that are updated or
+// deleted during the verification aren't reported as mismatches.
+func (d *DualWriteDatastore) Verify() (*Stats, error) {
+	stats := &Stats{}
+	mismatches := 0
+	err := d.primary.Walk(func(key string, _ []byte, _ time.Duration) error {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		value, err := d.primary.Get(key)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			// Deleted since the walk read it.
+			return nil
+		}
+		existing, err := d.secondary.Get(key)
+		if err != nil {
+			return err
+		}
+		if existing == nil || !bytes.Equal(existing, value) {
+			log.WithField("key", key).Debug("Secondary datastore has a different value")
+			mismatches++
+		}
+		stats.add(key, value)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = d.secondary.Walk(func(key string, _ []byte, _ time.Duration) error {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		value, err := d.primary.Get(key)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			log.WithField("key", key).Debug("Secondary datastore has a key that the primary doesn't")
+			mismatches++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if mismatches > 0 {
+		log.WithField("mismatches", mismatches).WithField("primary", stats.String()).
+			Error("Datastore verification failed")
+		return stats, ErrMismatch
+	}
+	return stats, nil
+}
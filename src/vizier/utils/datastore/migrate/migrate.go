@@ -0,0 +1,95 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"px.dev/pixie/src/vizier/utils/datastore"
+)
+
+// ErrMismatch is returned by Verify when the two datastores don't contain the same keys and values.
+var ErrMismatch = errors.New("datastore contents do not match")
+
+// Stats summarizes the keys that were visited in a datastore.
+type Stats struct {
+	// Keys is the number of keys visited.
+	Keys int
+	// Checksum is an order-independent checksum of all visited keys and values.
+	Checksum [sha256.Size]byte
+}
+
+func (s *Stats) add(key string, value []byte) {
+	h := sha256.New()
+	// Length-prefix the key so that key/value boundaries are unambiguous.
+	var l [8]byte
+	binary.BigEndian.PutUint64(l[:], uint64(len(key)))
+	h.Write(l[:])
+	h.Write([]byte(key))
+	h.Write(value)
+	sum := h.Sum(nil)
+	for i := range s.Checksum {
+		s.Checksum[i] ^= sum[i]
+	}
+	s.Keys++
+}
+
+// String returns a short description of the stats.
+func (s *Stats) String() string {
+	return fmt.Sprintf("%d keys, checksum %x", s.Keys, s.Checksum[:8])
+}
+
+// Checksum computes the stats of all keys in the given datastore.
+func Checksum(ds datastore.Walker) (*Stats, error) {
+	stats := &Stats{}
+	err := ds.Walk(func(key string, value []byte, ttl time.Duration) error {
+		stats.add(key, value)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// Verify checks that src and dst contain the same keys and values. It returns ErrMismatch if they
+// don't. The datastores are walked one after the other, so they must not be written to while they
+// are verified. Use DualWriteDatastore.Verify to verify a migration that is in use.
+func Verify(src, dst datastore.Walker) (*Stats, error) {
+	srcStats, err := Checksum(src)
+	if err != nil {
+		return nil, err
+	}
+	dstStats, err := Checksum(dst)
+	if err != nil {
+		return nil, err
+	}
+	if srcStats.Keys != dstStats.Keys || srcStats.Checksum != dstStats.Checksum {
+		log.WithField("src", srcStats.String()).WithField("dst", dstStats.String()).
+			Error("Datastore verification failed")
+		return srcStats, ErrMismatch
+	}
+	return srcStats, nil
+}
@@ -0,0 +1,190 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package migrate_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bunt "github.com/tidwall/buntdb"
+
+	"px.dev/pixie/src/vizier/utils/datastore/buntdb"
+	"px.dev/pixie/src/vizier/utils/datastore/migrate"
+	"px.dev/pixie/src/vizier/utils/datastore/pebbledb"
+)
+
+func setupPebble(t *testing.T) *pebbledb.DataStore {
+	db, err := pebble.Open("test", &pebble.Options{
+		FS: vfs.NewMem(),
+	})
+	require.NoError(t, err)
+	ds := pebbledb.New(db, 3*time.Second)
+	t.Cleanup(func() { ds.Close() })
+	return ds
+}
+
+func setupBunt(t *testing.T) *buntdb.DataStore {
+	db, err := bunt.Open(":memory:")
+	require.NoError(t, err)
+	ds := buntdb.New(db)
+	t.Cleanup(func() { ds.Close() })
+	return ds
+}
+
+func TestBackfillAndVerify(t *testing.T) {
+	src := setupPebble(t)
+	dst := setupBunt(t)
+
+	require.NoError(t, src.Set("/agent/1", "a1"))
+	require.NoError(t, src.Set("/agent/2", "a2"))
+	require.NoError(t, src.SetWithTTL("/resourceUpdate/1", "r1", time.Hour))
+
+	stats, err := migrate.NewDualWriteDatastore(src, dst).Backfill()
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.Keys)
+
+	v, err := dst.Get("/agent/2")
+	require.NoError(t, err)
+	assert.Equal(t, "a2", string(v))
+
+	// The TTL is carried over.
+	err = dst.Walk(func(key string, value []byte, ttl time.Duration) error {
+		if key == "/resourceUpdate/1" {
+			assert.True(t, ttl > 55*time.Minute && ttl <= time.Hour)
+		} else {
+			assert.Equal(t, time.Duration(0), ttl)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	verified, err := migrate.Verify(src, dst)
+	require.NoError(t, err)
+	assert.Equal(t, stats, verified)
+
+	// A changed value is a mismatch, even though the number of keys is the same.
+	require.NoError(t, dst.Set("/agent/2", "changed"))
+	_, err = migrate.Verify(src, dst)
+	assert.Equal(t, migrate.ErrMismatch, err)
+}
+
+func TestBackfill_RepairsSecondary(t *testing.T) {
+	primary := setupPebble(t)
+	secondary := setupBunt(t)
+
+	require.NoError(t, primary.Set("/agent/1", "a1"))
+	require.NoError(t, primary.Set("/agent/2", "a2"))
+	// Left behind by an earlier migration.
+	require.NoError(t, secondary.Set("/agent/2", "stale"))
+	require.NoError(t, secondary.Set("/agent/3", "deleted"))
+
+	ds := migrate.NewDualWriteDatastore(primary, secondary)
+	_, err := ds.Verify()
+	assert.Equal(t, migrate.ErrMismatch, err)
+
+	stats, err := ds.Backfill()
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Keys)
+
+	v, err := secondary.Get("/agent/2")
+	require.NoError(t, err)
+	assert.Equal(t, "a2", string(v))
+	v, err = secondary.Get("/agent/3")
+	require.NoError(t, err)
+	assert.Nil(t, v)
+
+	verified, err := ds.Verify()
+	require.NoError(t, err)
+	assert.Equal(t, stats, verified)
+}
+
+func TestDualWriteDatastore_Verify(t *testing.T) {
+	primary := setupPebble(t)
+	secondary := setupBunt(t)
+
+	require.NoError(t, primary.Set("/agent/1", "a1"))
+	ds := migrate.NewDualWriteDatastore(primary, secondary)
+	_, err := ds.Backfill()
+	require.NoError(t, err)
+	_, err = ds.Verify()
+	require.NoError(t, err)
+
+	// Writes through the dual write datastore keep the datastores in sync.
+	require.NoError(t, ds.Set("/agent/2", "a2"))
+	require.NoError(t, ds.Delete("/agent/1"))
+	stats, err := ds.Verify()
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Keys)
+
+	// A missing key is a mismatch.
+	require.NoError(t, primary.Set("/agent/3", "a3"))
+	_, err = ds.Verify()
+	assert.Equal(t, migrate.ErrMismatch, err)
+	require.NoError(t, primary.Delete("/agent/3"))
+
+	// So is a key that is only in the secondary.
+	require.NoError(t, secondary.Set("/agent/4", "a4"))
+	_, err = ds.Verify()
+	assert.Equal(t, migrate.ErrMismatch, err)
+}
+
+func TestDualWriteDatastore(t *testing.T) {
+	primary := setupPebble(t)
+	secondary := setupBunt(t)
+
+	require.NoError(t, primary.Set("existing", "old"))
+	require.NoError(t, primary.Set("deleted", "old"))
+	require.NoError(t, primary.Set("untouched", "value"))
+
+	ds := migrate.NewDualWriteDatastore(primary, secondary)
+	require.NoError(t, ds.Set("existing", "new"))
+	require.NoError(t, ds.Delete("deleted"))
+	require.NoError(t, ds.SetWithTTL("added", "value", time.Hour))
+
+	v, err := secondary.Get("added")
+	require.NoError(t, err)
+	assert.Equal(t, "value", string(v))
+
+	stats, err := ds.Backfill()
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.Keys)
+
+	v, err = secondary.Get("existing")
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(v))
+	v, err = secondary.Get("deleted")
+	require.NoError(t, err)
+	assert.Nil(t, v)
+	v, err = secondary.Get("untouched")
+	require.NoError(t, err)
+	assert.Equal(t, "value", string(v))
+
+	_, err = ds.Verify()
+	require.NoError(t, err)
+
+	// Reads are served from the primary.
+	require.NoError(t, secondary.Set("untouched", "other"))
+	v, err = ds.Get("untouched")
+	require.NoError(t, err)
+	assert.Equal(t, "value", string(v))
+}
@@ -19,12 +19,15 @@
 package pebbledb
 
 import (
+	"bytes"
 	"fmt"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/cockroachdb/pebble"
+
+	"px.dev/pixie/src/vizier/utils/datastore/watch"
 )
 
 const (
@@ -36,6 +39,8 @@ const (
 type DataStore struct {
 	db *pebble.DB
 
+	notifier watch.Notifier
+
 	done chan struct{}
 	once sync.Once
 }
@@ -63,7 +68,9 @@ func (w *DataStore) ttlWatcher(ttlReaperDuration time.Duration) {
 			now := time.Now()
 
 			from := fmt.Sprintf("%s/", ttlByTimePrefix)
-			to := fmt.Sprintf("%s/%20d/", ttlByTimePrefix, now.Unix()+1)
+			// Only look at seconds that have fully passed. The markers in the range are deleted
+			// below, so a key expiring later in the current second would otherwise never be reaped.
+			to := fmt.Sprintf("%s/%20d/", ttlByTimePrefix, now.Unix())
 
 			iter := w.db.NewIter(&pebble.IterOptions{
 				LowerBound: []byte(from),
@@ -71,6 +78,7 @@ func (w *DataStore) ttlWatcher(ttlReaperDuration time.Duration) {
 			})
 
 			var deleteKeys []string
+			var expired []*watch.Event
 
 			for iter.First(); iter.Valid(); iter.Next() {
 				if iter.Error() != nil {
@@ -80,7 +88,8 @@ func (w *DataStore) ttlWatcher(ttlReaperDuration time.Duration) {
 				// ensuring that this is valid across iterations.
 				k := string(iter.Key())
 
-				sp := strings.Split(k, "/")
+				// The key itself may contain slashes, so only split off the prefix and the time.
+				sp := strings.SplitN(k, "/", 3)
 				if len(sp) < 3 {
 					continue
 				}
@@ -101,12 +110,14 @@ func (w *DataStore) ttlWatcher(ttlReaperDuration time.Duration) {
 				if expiresAt.Before(now) {
 					deleteKeys = append(deleteKeys, ttlByKey)
 					deleteKeys = append(deleteKeys, keyToDelete)
+					expired = append(expired, &watch.Event{Type: watch.Expire, Key: keyToDelete})
 				}
 			}
-			err := w.DeleteAll(deleteKeys)
+			err := w.deleteAll(deleteKeys)
 			if err != nil {
 				continue
 			}
+			w.notifier.Notify(expired...)
 			err = w.db.DeleteRange([]byte(from), []byte(to), pebble.Sync)
 			if err != nil {
 				continue
@@ -118,7 +129,11 @@ func (w *DataStore) ttlWatcher(ttlReaperDuration time.Duration) {
 
 // Set puts the given key and value in the datastore.
 func (w *DataStore) Set(key string, value string) error {
-	return w.db.Set([]byte(key), []byte(value), pebble.Sync)
+	if err := w.db.Set([]byte(key), []byte(value), pebble.Sync); err != nil {
+		return err
+	}
+	w.notifier.Notify(&watch.Event{Type: watch.Put, Key: key, Value: []byte(value)})
+	return nil
 }
 
 // SetWithTTL puts the given key and value into the datastore with a TTL.
@@ -150,7 +165,64 @@ func (w *DataStore) SetWithTTL(key string, value string, ttl time.Duration) erro
 		batch.Close()
 		return err
 	}
-	return batch.Commit(pebble.Sync)
+	if err := batch.Commit(pebble.Sync); err != nil {
+		return err
+	}
+	w.notifier.Notify(&watch.Event{Type: watch.Put, Key: key, Value: []byte(value)})
+	return nil
+}
+
+func isTTLKey(key []byte) bool {
+	return bytes.HasPrefix(key, []byte(ttlByKeyPrefix)) || bytes.HasPrefix(key, []byte(ttlByTimePrefix))
+}
+
+// Walk calls fn for every key in the datastore, along with the key's remaining TTL. The keys used
+// to track TTLs are skipped.
+func (w *DataStore) Walk(fn func(key string, value []byte, ttl time.Duration) error) error {
+	// Read the keys and their TTLs from a snapshot so that the walk sees a single point in time.
+	snap := w.db.NewSnapshot()
+	defer snap.Close()
+
+	iter := snap.NewIter(&pebble.IterOptions{})
+	for iter.First(); iter.Valid(); iter.Next() {
+		if err := iter.Error(); err != nil {
+			iter.Close()
+			return err
+		}
+		if isTTLKey(iter.Key()) {
+			continue
+		}
+		// Converting from []byte -> string will copy the underlying data, so this is safe.
+		key := string(iter.Key())
+		value := make([]byte, len(iter.Value()))
+		copy(value, iter.Value())
+
+		var ttl time.Duration
+		encodedExpiry, closer, err := snap.Get([]byte(fmt.Sprintf("%s/%s", ttlByKeyPrefix, key)))
+		if err != nil && err != pebble.ErrNotFound {
+			iter.Close()
+			return err
+		}
+		if err == nil {
+			var expiresAt time.Time
+			err := expiresAt.UnmarshalBinary(encodedExpiry)
+			closer.Close()
+			if err != nil {
+				iter.Close()
+				return err
+			}
+			ttl = time.Until(expiresAt)
+			if ttl <= 0 {
+				// The key has expired, but hasn't been reaped yet.
+				continue
+			}
+		}
+		if err := fn(key, value, ttl); err != nil {
+			iter.Close()
+			return err
+		}
+	}
+	return iter.Close()
 }
 
 // Get gets the value for the given key from the datastore.
@@ -196,15 +268,32 @@ func (w *DataStore) GetWithPrefix(prefix string) ([]string, [][]byte, error) {
 
 // Delete deletes the value for the given key from the datastore.
 func (w *DataStore) Delete(key string) error {
-	return w.db.Delete([]byte(key), pebble.Sync)
+	if err := w.db.Delete([]byte(key), pebble.Sync); err != nil {
+		return err
+	}
+	w.notifier.Notify(&watch.Event{Type: watch.Delete, Key: key})
+	return nil
 }
 
 // DeleteAll deletes all of the given keys and corresponding values in the datastore if they exist.
 func (w *DataStore) DeleteAll(keys []string) error {
+	if err := w.deleteAll(keys); err != nil {
+		return err
+	}
+	events := make([]*watch.Event, len(keys))
+	for i, key := range keys {
+		events[i] = &watch.Event{Type: watch.Delete, Key: key}
+	}
+	w.notifier.Notify(events...)
+	return nil
+}
+
+func (w *DataStore) deleteAll(keys []string) error {
 	batch := w.db.NewBatch()
 	for _, key := range keys {
 		err := batch.Delete([]byte(key), pebble.Sync)
 		if err != nil {
+			batch.Close()
 			return err
 		}
 	}
@@ -213,7 +302,40 @@ func (w *DataStore) DeleteAll(keys []string) error {
 
 // DeleteWithPrefix deletes all keys and values with the given prefix.
 func (w *DataStore) DeleteWithPrefix(prefix string) error {
-	return w.db.DeleteRange([]byte(prefix), keyUpperBound([]byte(prefix)), pebble.Sync)
+	// Range deletes don't say which keys they removed, so list them first if anyone is watching.
+	var keys []string
+	if w.notifier.Watching(prefix) {
+		var err error
+		keys, _, err = w.GetWithPrefix(prefix)
+		if err != nil {
+			return err
+		}
+	}
+	if err := w.db.DeleteRange([]byte(prefix), keyUpperBound([]byte(prefix)), pebble.Sync); err != nil {
+		return err
+	}
+	events := make([]*watch.Event, 0, len(keys))
+	for _, key := range keys {
+		if isTTLKey([]byte(key)) {
+			continue
+		}
+		events = append(events, &watch.Event{Type: watch.Delete, Key: key})
+	}
+	w.notifier.Notify(events...)
+	return nil
+}
+
+// Watch subscribes to changes to keys with the given prefix. Events are sent after the change has
+// been committed. The keys used to track TTLs are never sent.
+func (w *DataStore) Watch(prefix string) (<-chan *watch.Event, func(), error) {
+	ch, cancel := w.notifier.Watch(prefix)
+	return ch, cancel, nil
+}
+
+// Size returns the approximate size of the datastore on disk, in bytes.
+func (w *DataStore) Size() (int64, error) {
+	m := w.db.Metrics()
+	return m.Total().Size + int64(m.WAL.Size), nil
 }
 
 // Close stops the TTL watcher, and closes the underlying datastore.
@@ -221,6 +343,7 @@ func (w *DataStore) DeleteWithPrefix(prefix string) error {
 func (w *DataStore) Close() error {
 	w.once.Do(func() {
 		close(w.done)
+		w.notifier.Close()
 	})
 
 	if w.db == nil {
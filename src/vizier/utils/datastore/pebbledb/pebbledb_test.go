@@ -0,0 +1,77 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package pebbledb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/vizier/utils/datastore/watch"
+)
+
+func nextEvent(t *testing.T, ch <-chan *watch.Event) *watch.Event {
+	select {
+	case ev := <-ch:
+		return ev
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for event")
+		return nil
+	}
+}
+
+func TestDataStore_Watch(t *testing.T) {
+	db, err := pebble.Open("test", &pebble.Options{FS: vfs.NewMem()})
+	require.NoError(t, err)
+	ds := New(db, 100*time.Millisecond)
+	defer ds.Close()
+
+	ch, cancel, err := ds.Watch("/watched/")
+	require.NoError(t, err)
+	defer cancel()
+
+	require.NoError(t, ds.Set("/other/a", "x"))
+	require.NoError(t, ds.Set("/watched/a", "1"))
+	assert.Equal(t, &watch.Event{Type: watch.Put, Key: "/watched/a", Value: []byte("1")}, nextEvent(t, ch))
+
+	require.NoError(t, ds.Delete("/watched/a"))
+	assert.Equal(t, &watch.Event{Type: watch.Delete, Key: "/watched/a"}, nextEvent(t, ch))
+
+	require.NoError(t, ds.Set("/watched/b", "2"))
+	require.NoError(t, ds.Set("/watched/c", "3"))
+	nextEvent(t, ch)
+	nextEvent(t, ch)
+	require.NoError(t, ds.DeleteWithPrefix("/watched/"))
+	assert.Equal(t, &watch.Event{Type: watch.Delete, Key: "/watched/b"}, nextEvent(t, ch))
+	assert.Equal(t, &watch.Event{Type: watch.Delete, Key: "/watched/c"}, nextEvent(t, ch))
+
+	require.NoError(t, ds.SetWithTTL("/watched/d", "4", time.Second))
+	assert.Equal(t, &watch.Event{Type: watch.Put, Key: "/watched/d", Value: []byte("4")}, nextEvent(t, ch))
+	// The reaper only deletes keys whose expiry second has passed, so this can take a couple of seconds.
+	assert.Equal(t, &watch.Event{Type: watch.Expire, Key: "/watched/d"}, nextEvent(t, ch))
+	assert.Len(t, ch, 0)
+
+	size, err := ds.Size()
+	require.NoError(t, err)
+	assert.True(t, size >= 0)
+}
@@ -0,0 +1,192 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package snapshot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"px.dev/pixie/src/vizier/utils/datastore"
+	"px.dev/pixie/src/vizier/utils/objectstore"
+)
+
+const (
+	namePrefix = "metadata-"
+	nameSuffix = ".snap"
+	// The time format used in snapshot names. It sorts lexicographically in time order.
+	nameTimeFormat = "20060102T150405.000000000Z"
+
+	// Latest can be passed to Restore instead of a snapshot name to restore the newest snapshot.
+	Latest = "latest"
+)
+
+// ErrNoSnapshots is returned when restoring the latest snapshot, but there aren't any.
+var ErrNoSnapshots = errors.New("no snapshots found")
+
+// StoredSnapshot is a snapshot that has been saved to the object store.
+type StoredSnapshot struct {
+	Name      string
+	CreatedAt time.Time
+	// Keys is the number of keys in the snapshot. It is only known for snapshots that were just
+	// created or restored.
+	Keys int
+	// Size is the size of the snapshot in bytes. It is only known for snapshots that were just
+	// created.
+	Size int
+}
+
+func snapshotName(t time.Time) string {
+	return namePrefix + t.UTC().Format(nameTimeFormat) + nameSuffix
+}
+
+func parseSnapshotName(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, namePrefix) || !strings.HasSuffix(name, nameSuffix) {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(nameTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, namePrefix), nameSuffix))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// Manager takes snapshots of a datastore and saves them to an object store.
+type Manager struct {
+	src   datastore.Walker
+	store objectstore.Store
+	now   func() time.Time
+	// Serializes snapshots, so that scheduled and requested snapshots don't race each other.
+	mu sync.Mutex
+}
+
+// NewManager creates a manager that snapshots src to store.
+func NewManager(src datastore.Walker, store objectstore.Store) *Manager {
+	return &Manager{src: src, store: store, now: time.Now}
+}
+
+// Snapshot takes a snapshot of the datastore and saves it.
+func (m *Manager) Snapshot(ctx context.Context) (*StoredSnapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var buf bytes.Buffer
+	info, err := Write(&buf, m.src, m.now())
+	if err != nil {
+		return nil, err
+	}
+	s := &StoredSnapshot{
+		Name:      snapshotName(info.CreatedAt),
+		CreatedAt: info.CreatedAt,
+		Keys:      info.Keys,
+		Size:      buf.Len(),
+	}
+	if err := m.store.Put(ctx, s.Name, buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// List returns the saved snapshots, newest first.
+func (m *Manager) List(ctx context.Context) ([]*StoredSnapshot, error) {
+	names, err := m.store.List(ctx, namePrefix)
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []*StoredSnapshot
+	for _, name := range names {
+		t, ok := parseSnapshotName(name)
+		if !ok {
+			continue
+		}
+		snapshots = append(snapshots, &StoredSnapshot{Name: name, CreatedAt: t})
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// Prune deletes all but the newest retain snapshots.
+func (m *Manager) Prune(ctx context.Context, retain int) error {
+	snapshots, err := m.List(ctx)
+	if err != nil {
+		return err
+	}
+	if len(snapshots) <= retain {
+		return nil
+	}
+	for _, s := range snapshots[retain:] {
+		if err := m.store.Delete(ctx, s.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Restore restores the named snapshot, or the newest snapshot if name is Latest, into dst.
+func (m *Manager) Restore(ctx context.Context, name string, dst datastore.TTLSetter) (*StoredSnapshot, error) {
+	if name == Latest {
+		snapshots, err := m.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if len(snapshots) == 0 {
+			return nil, ErrNoSnapshots
+		}
+		name = snapshots[0].Name
+	}
+	data, err := m.store.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := Restore(bytes.NewReader(data), dst, m.now())
+	if err != nil {
+		return nil, err
+	}
+	return &StoredSnapshot{Name: name, CreatedAt: info.CreatedAt, Keys: info.Keys, Size: len(data)}, nil
+}
+
+// Run takes a snapshot every interval, keeping the newest retain snapshots, until ctx is done.
+func (m *Manager) Run(ctx context.Context, interval time.Duration, retain int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s, err := m.Snapshot(ctx)
+			if err != nil {
+				log.WithError(err).Error("Failed to snapshot metadata datastore")
+				continue
+			}
+			log.WithField("name", s.Name).WithField("keys", s.Keys).Info("Snapshotted metadata datastore")
+			if err := m.Prune(ctx, retain); err != nil {
+				log.WithError(err).Error("Failed to prune metadata datastore snapshots")
+			}
+		}
+	}
+}
@@ -0,0 +1,289 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+// Package snapshot exports the contents of a datastore to a point-in-time snapshot, and restores
+// datastores from those snapshots.
+package snapshot
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+
+	"px.dev/pixie/src/vizier/utils/datastore"
+)
+
+// A snapshot starts with the magic bytes and the time it was created (varint unix ns). Each key
+// follows as an entryMarker, the key and value (each a uvarint length and the bytes), and the time
+// the key expires (varint unix ns, 0 if the key has no TTL). The snapshot ends with an endMarker,
+// the number of keys (uvarint), and the sha256 of everything before the checksum.
+var magic = []byte("PXSNAP01")
+
+const (
+	entryMarker byte = 1
+	endMarker   byte = 0
+
+	// maxFieldLen bounds the length of keys and values, so that a corrupt length can't cause a huge
+	// allocation.
+	maxFieldLen = 64 << 20
+)
+
+// ErrCorrupt is returned when a snapshot can't be parsed, or its checksum doesn't match.
+var ErrCorrupt = errors.New("snapshot is corrupt")
+
+// Info describes a snapshot.
+type Info struct {
+	// CreatedAt is the time that the snapshot was taken.
+	CreatedAt time.Time
+	// Keys is the number of keys in the snapshot.
+	Keys int
+}
+
+// Entry is a single key in a snapshot.
+type Entry struct {
+	Key   string
+	Value []byte
+	// ExpiresAt is the time that the key expires, or the zero time if the key has no TTL.
+	ExpiresAt time.Time
+}
+
+type writer struct {
+	w   *bufio.Writer
+	h   hash.Hash
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (w *writer) write(b []byte) error {
+	w.h.Write(b)
+	_, err := w.w.Write(b)
+	return err
+}
+
+func (w *writer) writeUvarint(v uint64) error {
+	return w.write(w.buf[:binary.PutUvarint(w.buf[:], v)])
+}
+
+func (w *writer) writeVarint(v int64) error {
+	return w.write(w.buf[:binary.PutVarint(w.buf[:], v)])
+}
+
+func (w *writer) writeBytes(b []byte) error {
+	if err := w.writeUvarint(uint64(len(b))); err != nil {
+		return err
+	}
+	return w.write(b)
+}
+
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// Write writes a snapshot of every key in src to out. The remaining TTL of each key is stored as
+// an absolute expiry time, relative to now.
+func Write(out io.Writer, src datastore.Walker, now time.Time) (*Info, error) {
+	w := &writer{w: bufio.NewWriter(out), h: sha256.New()}
+	if err := w.write(magic); err != nil {
+		return nil, err
+	}
+	if err := w.writeVarint(now.UnixNano()); err != nil {
+		return nil, err
+	}
+
+	info := &Info{CreatedAt: now}
+	err := src.Walk(func(key string, value []byte, ttl time.Duration) error {
+		var expiresAt time.Time
+		if ttl > 0 {
+			expiresAt = now.Add(ttl)
+		}
+		if err := w.write([]byte{entryMarker}); err != nil {
+			return err
+		}
+		if err := w.writeBytes([]byte(key)); err != nil {
+			return err
+		}
+		if err := w.writeBytes(value); err != nil {
+			return err
+		}
+		if err := w.writeVarint(unixNano(expiresAt)); err != nil {
+			return err
+		}
+		info.Keys++
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot datastore: %w", err)
+	}
+
+	if err := w.write([]byte{endMarker}); err != nil {
+		return nil, err
+	}
+	if err := w.writeUvarint(uint64(info.Keys)); err != nil {
+		return nil, err
+	}
+	if _, err := w.w.Write(w.h.Sum(nil)); err != nil {
+		return nil, err
+	}
+	return info, w.w.Flush()
+}
+
+type reader struct {
+	r *bufio.Reader
+	h hash.Hash
+}
+
+func (r *reader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	r.h.Write([]byte{b})
+	return b, nil
+}
+
+func (r *reader) readFull(b []byte) error {
+	if _, err := io.ReadFull(r.r, b); err != nil {
+		return err
+	}
+	r.h.Write(b)
+	return nil
+}
+
+func (r *reader) readBytes() ([]byte, error) {
+	l, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if l > maxFieldLen {
+		return nil, ErrCorrupt
+	}
+	b := make([]byte, l)
+	return b, r.readFull(b)
+}
+
+func fromUnixNano(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns).UTC()
+}
+
+// Read parses a snapshot and verifies its checksum. Nothing is returned unless the entire
+// snapshot is valid.
+func Read(in io.Reader) (*Info, []*Entry, error) {
+	info, entries, err := read(in)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = ErrCorrupt
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return info, entries, nil
+}
+
+func read(in io.Reader) (*Info, []*Entry, error) {
+	r := &reader{r: bufio.NewReader(in), h: sha256.New()}
+	m := make([]byte, len(magic))
+	if err := r.readFull(m); err != nil {
+		return nil, nil, err
+	}
+	if !bytes.Equal(m, magic) {
+		return nil, nil, ErrCorrupt
+	}
+	createdAt, err := binary.ReadVarint(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var entries []*Entry
+	for {
+		marker, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, err
+		}
+		if marker == endMarker {
+			break
+		}
+		if marker != entryMarker {
+			return nil, nil, ErrCorrupt
+		}
+		key, err := r.readBytes()
+		if err != nil {
+			return nil, nil, err
+		}
+		value, err := r.readBytes()
+		if err != nil {
+			return nil, nil, err
+		}
+		expiresAt, err := binary.ReadVarint(r)
+		if err != nil {
+			return nil, nil, err
+		}
+		entries = append(entries, &Entry{Key: string(key), Value: value, ExpiresAt: fromUnixNano(expiresAt)})
+	}
+
+	keys, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	expected := r.h.Sum(nil)
+	checksum := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(r.r, checksum); err != nil {
+		return nil, nil, err
+	}
+	if keys != uint64(len(entries)) || !bytes.Equal(checksum, expected) {
+		return nil, nil, ErrCorrupt
+	}
+	return &Info{CreatedAt: fromUnixNano(createdAt), Keys: len(entries)}, entries, nil
+}
+
+// Restore writes the keys in a snapshot to dst. The whole snapshot is verified before anything is
+// written. Keys are restored with their TTL remaining as of now, and keys that have already
+// expired are skipped. The returned info counts the keys that were restored.
+func Restore(in io.Reader, dst datastore.TTLSetter, now time.Time) (*Info, error) {
+	info, entries, err := Read(in)
+	if err != nil {
+		return nil, err
+	}
+
+	restored := &Info{CreatedAt: info.CreatedAt}
+	for _, e := range entries {
+		if e.ExpiresAt.IsZero() {
+			err = dst.Set(e.Key, string(e.Value))
+		} else {
+			ttl := e.ExpiresAt.Sub(now)
+			if ttl <= 0 {
+				continue
+			}
+			err = dst.SetWithTTL(e.Key, string(e.Value), ttl)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore key %s: %w", e.Key, err)
+		}
+		restored.Keys++
+	}
+	return restored, nil
+}
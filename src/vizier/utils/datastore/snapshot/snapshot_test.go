@@ -0,0 +1,203 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package snapshot_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bunt "github.com/tidwall/buntdb"
+
+	"px.dev/pixie/src/vizier/utils/datastore/buntdb"
+	"px.dev/pixie/src/vizier/utils/datastore/pebbledb"
+	"px.dev/pixie/src/vizier/utils/datastore/snapshot"
+	"px.dev/pixie/src/vizier/utils/objectstore"
+)
+
+func setupPebble(t *testing.T) *pebbledb.DataStore {
+	db, err := pebble.Open("test", &pebble.Options{
+		FS: vfs.NewMem(),
+	})
+	require.NoError(t, err)
+	ds := pebbledb.New(db, 3*time.Second)
+	t.Cleanup(func() { ds.Close() })
+	return ds
+}
+
+func setupBunt(t *testing.T) *buntdb.DataStore {
+	db, err := bunt.Open(":memory:")
+	require.NoError(t, err)
+	ds := buntdb.New(db)
+	t.Cleanup(func() { ds.Close() })
+	return ds
+}
+
+func TestWriteRestore(t *testing.T) {
+	src := setupPebble(t)
+	require.NoError(t, src.Set("/agent/1", "a1"))
+	require.NoError(t, src.Set("/agent/2", ""))
+	require.NoError(t, src.SetWithTTL("/resourceUpdate/1", "r1", time.Hour))
+
+	now := time.Now()
+	var buf bytes.Buffer
+	info, err := snapshot.Write(&buf, src, now)
+	require.NoError(t, err)
+	assert.Equal(t, 3, info.Keys)
+
+	readInfo, entries, err := snapshot.Read(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, 3, readInfo.Keys)
+	assert.Equal(t, now.UnixNano(), readInfo.CreatedAt.UnixNano())
+	require.Len(t, entries, 3)
+	assert.Equal(t, "/agent/1", entries[0].Key)
+	assert.True(t, entries[0].ExpiresAt.IsZero())
+	assert.Equal(t, "/resourceUpdate/1", entries[2].Key)
+	assert.WithinDuration(t, now.Add(time.Hour), entries[2].ExpiresAt, 5*time.Second)
+
+	// Restore half an hour later, so the TTL should be about half an hour.
+	dst := setupBunt(t)
+	restored, err := snapshot.Restore(bytes.NewReader(buf.Bytes()), dst, now.Add(30*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 3, restored.Keys)
+
+	v, err := dst.Get("/agent/1")
+	require.NoError(t, err)
+	assert.Equal(t, "a1", string(v))
+	err = dst.Walk(func(key string, value []byte, ttl time.Duration) error {
+		if key == "/resourceUpdate/1" {
+			assert.True(t, ttl > 25*time.Minute && ttl <= 30*time.Minute, ttl)
+		} else {
+			assert.Equal(t, time.Duration(0), ttl)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestRestore_SkipsExpiredKeys(t *testing.T) {
+	src := setupBunt(t)
+	require.NoError(t, src.Set("/agent/1", "a1"))
+	require.NoError(t, src.SetWithTTL("/resourceUpdate/1", "r1", time.Minute))
+
+	now := time.Now()
+	var buf bytes.Buffer
+	_, err := snapshot.Write(&buf, src, now)
+	require.NoError(t, err)
+
+	dst := setupPebble(t)
+	restored, err := snapshot.Restore(&buf, dst, now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, restored.Keys)
+
+	v, err := dst.Get("/resourceUpdate/1")
+	require.NoError(t, err)
+	assert.Nil(t, v)
+}
+
+func TestRestore_Corrupt(t *testing.T) {
+	src := setupBunt(t)
+	require.NoError(t, src.Set("/agent/1", "a1"))
+	require.NoError(t, src.Set("/agent/2", "a2"))
+
+	var buf bytes.Buffer
+	_, err := snapshot.Write(&buf, src, time.Now())
+	require.NoError(t, err)
+	data := buf.Bytes()
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"bad magic", append([]byte("NOTASNAP"), data[8:]...)},
+		{"truncated", data[:len(data)-10]},
+		{"flipped bit", func() []byte {
+			d := append([]byte{}, data...)
+			d[len(d)/2] ^= 1
+			return d
+		}()},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dst := setupBunt(t)
+			_, err := snapshot.Restore(bytes.NewReader(test.data), dst, time.Now())
+			assert.ErrorIs(t, err, snapshot.ErrCorrupt)
+
+			// Nothing is written from a corrupt snapshot.
+			v, err := dst.Get("/agent/1")
+			require.NoError(t, err)
+			assert.Nil(t, v)
+		})
+	}
+}
+
+func TestManager(t *testing.T) {
+	ctx := context.Background()
+	src := setupPebble(t)
+	require.NoError(t, src.Set("/agent/1", "a1"))
+
+	store, err := objectstore.NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	m := snapshot.NewManager(src, store)
+
+	_, err = m.Restore(ctx, snapshot.Latest, setupBunt(t))
+	assert.ErrorIs(t, err, snapshot.ErrNoSnapshots)
+
+	first, err := m.Snapshot(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, first.Keys)
+	require.NoError(t, src.Set("/agent/2", "a2"))
+	second, err := m.Snapshot(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, second.Keys)
+	// Files that aren't snapshots are ignored.
+	require.NoError(t, store.Put(ctx, "metadata-notes.txt", []byte("notes")))
+
+	snapshots, err := m.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	assert.Equal(t, second.Name, snapshots[0].Name)
+	assert.Equal(t, first.Name, snapshots[1].Name)
+
+	dst := setupBunt(t)
+	restored, err := m.Restore(ctx, snapshot.Latest, dst)
+	require.NoError(t, err)
+	assert.Equal(t, second.Name, restored.Name)
+	assert.Equal(t, 2, restored.Keys)
+
+	dst = setupBunt(t)
+	restored, err = m.Restore(ctx, first.Name, dst)
+	require.NoError(t, err)
+	assert.Equal(t, 1, restored.Keys)
+	v, err := dst.Get("/agent/2")
+	require.NoError(t, err)
+	assert.Nil(t, v)
+
+	require.NoError(t, m.Prune(ctx, 1))
+	snapshots, err = m.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	assert.Equal(t, second.Name, snapshots[0].Name)
+}
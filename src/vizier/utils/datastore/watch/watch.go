@@ -0,0 +1,148 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+// Package watch contains the events sent to datastore subscribers, and helpers for datastores that
+// implement subscriptions in-process.
+package watch
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// BufferSize is the number of events that can be queued for a subscriber. Subscribers that fall
+// further behind have their channel closed.
+const BufferSize = 1024
+
+// ErrNotSupported is returned by datastores that wrap a datastore that doesn't support watches.
+var ErrNotSupported = errors.New("datastore does not support watches")
+
+// EventType is the type of change made to a key.
+type EventType int
+
+const (
+	// Put is sent when a key is set.
+	Put EventType = iota
+	// Delete is sent when a key is deleted.
+	Delete
+	// Expire is sent when a key is deleted because its TTL expired.
+	Expire
+)
+
+func (t EventType) String() string {
+	switch t {
+	case Put:
+		return "put"
+	case Delete:
+		return "delete"
+	case Expire:
+		return "expire"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a change to a key in a datastore.
+type Event struct {
+	Type EventType
+	Key  string
+	// Value is the new value of the key, for Put events.
+	Value []byte
+}
+
+type subscription struct {
+	prefix string
+	ch     chan *Event
+}
+
+// Notifier fans out events to subscribers, by key prefix. The zero value is ready to use.
+type Notifier struct {
+	mu   sync.Mutex
+	subs map[*subscription]struct{}
+}
+
+// Watch subscribes to events for keys with the given prefix. The channel is closed when cancel is
+// called, or if the subscriber falls behind by more than BufferSize events. In that case, the
+// subscriber should re-read any state it needs and watch again.
+func (n *Notifier) Watch(prefix string) (<-chan *Event, func()) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.subs == nil {
+		n.subs = make(map[*subscription]struct{})
+	}
+	sub := &subscription{prefix: prefix, ch: make(chan *Event, BufferSize)}
+	n.subs[sub] = struct{}{}
+	return sub.ch, func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		n.removeLocked(sub)
+	}
+}
+
+func (n *Notifier) removeLocked(sub *subscription) {
+	if _, ok := n.subs[sub]; !ok {
+		return
+	}
+	delete(n.subs, sub)
+	close(sub.ch)
+}
+
+// Watching returns whether there are any subscribers to keys with the given prefix. Datastores
+// can use it to skip work, such as listing the keys deleted by a prefix delete, when there are
+// no subscribers.
+func (n *Notifier) Watching(prefix string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for sub := range n.subs {
+		if strings.HasPrefix(sub.prefix, prefix) || strings.HasPrefix(prefix, sub.prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Notify sends the events to the subscribers of their keys.
+func (n *Notifier) Notify(events ...*Event) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, ev := range events {
+		for sub := range n.subs {
+			if !strings.HasPrefix(ev.Key, sub.prefix) {
+				continue
+			}
+			select {
+			case sub.ch <- ev:
+			default:
+				log.WithField("prefix", sub.prefix).Warn("Datastore watcher fell behind, closing its channel")
+				n.removeLocked(sub)
+			}
+		}
+	}
+}
+
+// Close closes the channels of all subscribers.
+func (n *Notifier) Close() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for sub := range n.subs {
+		n.removeLocked(sub)
+	}
+}
@@ -0,0 +1,70 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package watch_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"px.dev/pixie/src/vizier/utils/datastore/watch"
+)
+
+func TestNotifier(t *testing.T) {
+	var n watch.Notifier
+	agents, cancelAgents := n.Watch("/agent/")
+	all, cancelAll := n.Watch("")
+	defer cancelAll()
+
+	assert.True(t, n.Watching("/agent/1"))
+	assert.True(t, n.Watching("/"))
+	cancelAgents()
+	// Cancelling twice is fine.
+	cancelAgents()
+	_, ok := <-agents
+	assert.False(t, ok)
+
+	agents, cancelAgents = n.Watch("/agent/")
+	defer cancelAgents()
+	n.Notify(&watch.Event{Type: watch.Put, Key: "/agent/1", Value: []byte("a")},
+		&watch.Event{Type: watch.Delete, Key: "/tracepoint/1"})
+
+	ev := <-agents
+	assert.Equal(t, &watch.Event{Type: watch.Put, Key: "/agent/1", Value: []byte("a")}, ev)
+	assert.Len(t, agents, 0)
+	assert.Equal(t, "/agent/1", (<-all).Key)
+	assert.Equal(t, "/tracepoint/1", (<-all).Key)
+}
+
+func TestNotifier_Overflow(t *testing.T) {
+	var n watch.Notifier
+	ch, cancel := n.Watch("/")
+	defer cancel()
+	for i := 0; i <= watch.BufferSize; i++ {
+		n.Notify(&watch.Event{Type: watch.Put, Key: "/key"})
+	}
+	for i := 0; i < watch.BufferSize; i++ {
+		_, ok := <-ch
+		require.True(t, ok)
+	}
+	_, ok := <-ch
+	assert.False(t, ok)
+	assert.False(t, n.Watching("/"))
+}
@@ -0,0 +1,179 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// The object stores that can be selected with New.
+const (
+	KindFile = "file"
+	KindS3   = "s3"
+)
+
+// ErrNotFound is returned when there is no object for a key.
+var ErrNotFound = errors.New("object not found")
+
+// Store persists objects, such as script results or snapshots, under slash-separated keys.
+type Store interface {
+	Put(ctx context.Context, key string, data []byte) error
+	// Get returns ErrNotFound if there is no object for the key.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete deletes the object for the key, if there is one.
+	Delete(ctx context.Context, key string) error
+	// List returns the keys of all objects that start with the prefix, in lexical order.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// Config configures an object store.
+type Config struct {
+	// Either KindFile or KindS3.
+	Kind string
+	// The directory objects are written to, for the file store.
+	Dir string
+	// The S3 store's settings.
+	S3 S3Config
+}
+
+// New creates the object store described by the config.
+func New(c Config) (Store, error) {
+	switch c.Kind {
+	case KindFile:
+		return NewFileStore(c.Dir)
+	case KindS3:
+		return NewS3Store(c.S3)
+	default:
+		return nil, fmt.Errorf("unknown object store %q", c.Kind)
+	}
+}
+
+// tmpFilePrefix is the prefix of files that are still being written.
+const tmpFilePrefix = ".tmp-"
+
+// FileStore stores objects as files in a directory, which is usually on a persistent volume.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates a store that writes objects to dir, creating it if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if dir == "" {
+		return nil, errors.New("the file object store needs a directory")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (f *FileStore) path(key string) (string, error) {
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return "", fmt.Errorf("invalid object key %q", key)
+		}
+	}
+	return filepath.Join(f.dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes the object for key. The file is written in full before it replaces any
+// previous object, so readers never see partial objects.
+func (f *FileStore) Put(ctx context.Context, key string, data []byte) error {
+	p, err := f.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), tmpFilePrefix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+// Get reads the object for key.
+func (f *FileStore) Get(ctx context.Context, key string) ([]byte, error) {
+	p, err := f.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// Delete deletes the object for key.
+func (f *FileStore) Delete(ctx context.Context, key string) error {
+	p, err := f.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	// Remove the parent directory once its last object is deleted.
+	_ = os.Remove(filepath.Dir(p))
+	return nil
+}
+
+// List returns the keys of all objects that start with the prefix.
+func (f *FileStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.WalkDir(f.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), tmpFilePrefix) {
+			return nil
+		}
+		rel, err := filepath.Rel(f.dir, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
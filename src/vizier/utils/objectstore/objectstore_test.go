@@ -0,0 +1,196 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package objectstore
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testStore(t *testing.T, store Store) {
+	ctx := context.Background()
+
+	_, err := store.Get(ctx, "script/run1")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, store.Put(ctx, "script/run1", []byte("results 1")))
+	require.NoError(t, store.Put(ctx, "script/run2", []byte("results 2")))
+
+	data, err := store.Get(ctx, "script/run1")
+	require.NoError(t, err)
+	assert.Equal(t, "results 1", string(data))
+
+	require.NoError(t, store.Delete(ctx, "script/run1"))
+	_, err = store.Get(ctx, "script/run1")
+	assert.ErrorIs(t, err, ErrNotFound)
+	// Deleting missing results is not an error.
+	require.NoError(t, store.Delete(ctx, "script/run1"))
+
+	data, err = store.Get(ctx, "script/run2")
+	require.NoError(t, err)
+	assert.Equal(t, "results 2", string(data))
+
+	require.NoError(t, store.Put(ctx, "other/run3", []byte("results 3")))
+	keys, err := store.List(ctx, "script/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"script/run2"}, keys)
+	keys, err = store.List(ctx, "")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"other/run3", "script/run2"}, keys)
+	require.NoError(t, store.Delete(ctx, "other/run3"))
+}
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	require.NoError(t, err)
+	testStore(t, store)
+
+	require.NoError(t, store.Delete(context.Background(), "script/run2"))
+	_, err = os.Stat(filepath.Join(dir, "script"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestFileStore_InvalidKey(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	require.NoError(t, err)
+	assert.Error(t, store.Put(context.Background(), "../script/run", []byte("results")))
+}
+
+// fakeS3 is a minimal in-memory S3 endpoint.
+type fakeS3 struct {
+	mu       sync.Mutex
+	objects  map[string][]byte
+	authHdrs []string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.authHdrs = append(f.authHdrs, r.Header.Get("Authorization"))
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = data
+	case http.MethodGet:
+		if r.URL.Query().Get("list-type") == "2" {
+			f.list(w, r)
+			return
+		}
+		data, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(data)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	bucket := r.URL.Path + "/"
+	prefix := r.URL.Query().Get("prefix")
+	var keys []string
+	for p := range f.objects {
+		key := strings.TrimPrefix(p, bucket)
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	// Return a single key per page to exercise the continuation token.
+	start := 0
+	if token := r.URL.Query().Get("continuation-token"); token != "" {
+		start, _ = strconv.Atoi(token)
+	}
+	var res listBucketResult
+	if start < len(keys) {
+		res.Contents = append(res.Contents, struct {
+			Key string `xml:"Key"`
+		}{Key: keys[start]})
+	}
+	if start+1 < len(keys) {
+		res.IsTruncated = true
+		res.NextContinuationToken = strconv.Itoa(start + 1)
+	}
+	_ = xml.NewEncoder(w).Encode(res)
+}
+
+func TestS3Store(t *testing.T) {
+	fake := &fakeS3{objects: make(map[string][]byte)}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	store, err := NewS3Store(S3Config{
+		Endpoint:        srv.URL,
+		Bucket:          "results",
+		Prefix:          "pixie/",
+		AccessKeyID:     "access-key",
+		SecretAccessKey: "secret-key",
+	})
+	require.NoError(t, err)
+	store.now = func() time.Time { return time.Date(2021, 3, 4, 10, 15, 0, 0, time.UTC) }
+	testStore(t, store)
+
+	assert.Contains(t, fake.objects, "/results/pixie/script/run2")
+	for _, hdr := range fake.authHdrs {
+		assert.True(t, strings.HasPrefix(hdr,
+			"AWS4-HMAC-SHA256 Credential=access-key/20210304/us-east-1/s3/aws4_request, "+
+				"SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature="), hdr)
+	}
+}
+
+func TestNewS3Store_MissingBucket(t *testing.T) {
+	_, err := NewS3Store(S3Config{Endpoint: "http://minio:9000"})
+	assert.Error(t, err)
+}
+
+func TestFileStore_ListSkipsTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	require.NoError(t, err)
+	require.NoError(t, store.Put(context.Background(), "a/b", []byte("data")))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a", tmpFilePrefix+"b"), []byte("partial"), 0600))
+
+	keys, err := store.List(context.Background(), "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a/b"}, keys)
+}
+
+func TestNew_UnknownKind(t *testing.T) {
+	_, err := New(Config{Kind: "gcs"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,271 @@
+/*
+ * Copyright 2018- The Pixie Authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3Config configures a store on an S3-compatible endpoint, such as MinIO.
+type S3Config struct {
+	// The endpoint URL, e.g. https://s3.us-west-2.amazonaws.com or http://minio.pl.svc:9000.
+	Endpoint string
+	Bucket   string
+	Region   string
+	// An optional prefix for the keys of all objects.
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+// S3Store stores objects in an S3 bucket. Requests use path-style addressing and
+// are signed with AWS Signature Version 4, which S3-compatible stores support as well.
+type S3Store struct {
+	c      S3Config
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewS3Store creates a store for the bucket described by the config.
+func NewS3Store(c S3Config) (*S3Store, error) {
+	if c.Endpoint == "" || c.Bucket == "" {
+		return nil, errors.New("the s3 object store needs an endpoint and a bucket")
+	}
+	if _, err := url.Parse(c.Endpoint); err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint: %v", err)
+	}
+	if c.Region == "" {
+		c.Region = "us-east-1"
+	}
+	return &S3Store{
+		c:      c,
+		client: &http.Client{Timeout: time.Minute},
+		now:    time.Now,
+	}, nil
+}
+
+func (s *S3Store) bucketURL(p string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSuffix(s.c.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	u.Path = p
+	u.RawPath = uriEncodePath(p)
+	return u, nil
+}
+
+func (s *S3Store) objectURL(key string) (*url.URL, error) {
+	return s.bucketURL("/" + s.c.Bucket + "/" + strings.TrimPrefix(s.c.Prefix+key, "/"))
+}
+
+func (s *S3Store) do(ctx context.Context, method, key string, body []byte) (*http.Response, error) {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.doURL(ctx, method, u, body)
+}
+
+func (s *S3Store) doURL(ctx context.Context, method string, u *url.URL, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, body)
+	return s.client.Do(req)
+}
+
+func s3Error(op, key string, resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("s3 %s %s failed with status %d: %s", op, key, resp.StatusCode, strings.TrimSpace(string(msg)))
+}
+
+// Put uploads the object for key.
+func (s *S3Store) Put(ctx context.Context, key string, data []byte) error {
+	resp, err := s.do(ctx, http.MethodPut, key, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return s3Error("put", key, resp)
+	}
+	return nil
+}
+
+// Get downloads the object for key.
+func (s *S3Store) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(resp.Body)
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, s3Error("get", key, resp)
+	}
+}
+
+// Delete deletes the object for key.
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK &&
+		resp.StatusCode != http.StatusNotFound {
+		return s3Error("delete", key, resp)
+	}
+	return nil
+}
+
+// listBucketResult is the subset of the ListObjectsV2 response that List uses.
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List returns the keys of all objects that start with the prefix, using ListObjectsV2.
+func (s *S3Store) List(ctx context.Context, prefix string) ([]string, error) {
+	u, err := s.bucketURL("/" + s.c.Bucket)
+	if err != nil {
+		return nil, err
+	}
+	fullPrefix := strings.TrimPrefix(s.c.Prefix+prefix, "/")
+
+	var keys []string
+	token := ""
+	for {
+		q := url.Values{}
+		q.Set("list-type", "2")
+		q.Set("prefix", fullPrefix)
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		// Signature Version 4 needs the query sorted by key and spaces encoded as %20.
+		u.RawQuery = strings.ReplaceAll(q.Encode(), "+", "%20")
+
+		resp, err := s.doURL(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := s3Error("list", prefix, resp)
+			resp.Body.Close()
+			return nil, err
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range result.Contents {
+			keys = append(keys, strings.TrimPrefix(c.Key, strings.TrimPrefix(s.c.Prefix, "/")))
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// uriEncodePath encodes each segment of the path as required by Signature Version 4.
+func uriEncodePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		segments[i] = strings.ReplaceAll(url.QueryEscape(seg), "+", "%20")
+	}
+	return strings.Join(segments, "/")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+// sign adds an AWS Signature Version 4 authorization header to the request.
+func (s *S3Store) sign(req *http.Request, body []byte) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	if s.c.AccessKeyID == "" {
+		// Anonymous access, for buckets that allow it.
+		return
+	}
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\n" +
+			"x-amz-content-sha256:" + payloadHash + "\n" +
+			"x-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, s.c.Region, "s3", "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.c.SecretAccessKey), date)
+	key = hmacSHA256(key, s.c.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.c.AccessKeyID, scope, signedHeaders, signature))
+}